@@ -10,9 +10,14 @@ import (
 
 	consistentbalancer "github.com/authzed/spicedb/pkg/balancer"
 	cmdutil "github.com/authzed/spicedb/pkg/cmd"
+	"github.com/authzed/spicedb/pkg/cmd/bench"
+	"github.com/authzed/spicedb/pkg/cmd/bootstrap"
+	"github.com/authzed/spicedb/pkg/cmd/datastore"
 	"github.com/authzed/spicedb/pkg/cmd/migrate"
 	"github.com/authzed/spicedb/pkg/cmd/root"
+	"github.com/authzed/spicedb/pkg/cmd/schema"
 	"github.com/authzed/spicedb/pkg/cmd/serve"
+	"github.com/authzed/spicedb/pkg/cmd/validate"
 	"github.com/authzed/spicedb/pkg/cmd/version"
 )
 
@@ -49,16 +54,92 @@ func main() {
 	migrate.RegisterMigrateFlags(migrateCmd)
 	rootCmd.AddCommand(migrateCmd)
 
+	statusCmd := migrate.NewStatusCommand(rootCmd.Use)
+	migrate.RegisterStatusFlags(statusCmd)
+	migrateCmd.AddCommand(statusCmd)
+
 	headCmd := migrate.NewHeadCommand(rootCmd.Use)
 	migrate.RegisterHeadFlags(headCmd)
 	rootCmd.AddCommand(headCmd)
 
+	// Add bootstrap command
+	var initDsConfig cmdutil.DatastoreConfig
+	initCmd := bootstrap.NewInitCommand(rootCmd.Use, &initDsConfig)
+	bootstrap.RegisterInitFlags(initCmd, &initDsConfig)
+	rootCmd.AddCommand(initCmd)
+
+	// Add datastore commands
+	datastoreCmd := datastore.NewCommand(rootCmd.Use)
+	rootCmd.AddCommand(datastoreCmd)
+
+	var analyzeDsConfig cmdutil.DatastoreConfig
+	analyzeCmd := datastore.NewAnalyzeCommand(rootCmd.Use, &analyzeDsConfig)
+	datastore.RegisterAnalyzeFlags(analyzeCmd, &analyzeDsConfig)
+	datastoreCmd.AddCommand(analyzeCmd)
+
+	var dedupeDsConfig cmdutil.DatastoreConfig
+	dedupeCmd := datastore.NewDedupeCommand(rootCmd.Use, &dedupeDsConfig)
+	datastore.RegisterDedupeFlags(dedupeCmd, &dedupeDsConfig)
+	datastoreCmd.AddCommand(dedupeCmd)
+
+	var graphDsConfig cmdutil.DatastoreConfig
+	datastoreGraphCmd := datastore.NewGraphCommand(rootCmd.Use, &graphDsConfig)
+	datastore.RegisterGraphFlags(datastoreGraphCmd, &graphDsConfig)
+	datastoreCmd.AddCommand(datastoreGraphCmd)
+
+	var auditDsConfig cmdutil.DatastoreConfig
+	auditCmd := datastore.NewAuditCommand(rootCmd.Use, &auditDsConfig)
+	datastore.RegisterAuditFlags(auditCmd, &auditDsConfig)
+	datastoreCmd.AddCommand(auditCmd)
+
+	// Add validate command
+	var validateDsConfig cmdutil.DatastoreConfig
+	validateCmd := validate.NewCommand(rootCmd.Use, &validateDsConfig)
+	validate.RegisterValidateFlags(validateCmd, &validateDsConfig)
+	rootCmd.AddCommand(validateCmd)
+
+	// Add bench command
+	var benchDsConfig cmdutil.DatastoreConfig
+	benchCmd := bench.NewCommand(rootCmd.Use, &benchDsConfig)
+	bench.RegisterBenchFlags(benchCmd, &benchDsConfig)
+	rootCmd.AddCommand(benchCmd)
+
+	// Add schema commands
+	schemaCmd := schema.NewCommand(rootCmd.Use)
+	rootCmd.AddCommand(schemaCmd)
+
+	docsCmd := schema.NewDocsCommand(rootCmd.Use)
+	schema.RegisterDocsFlags(docsCmd)
+	schemaCmd.AddCommand(docsCmd)
+
+	schemaGraphCmd := schema.NewGraphCommand(rootCmd.Use)
+	schema.RegisterGraphFlags(schemaGraphCmd)
+	schemaCmd.AddCommand(schemaGraphCmd)
+
+	schemaFormatCmd := schema.NewFormatCommand(rootCmd.Use)
+	schema.RegisterFormatFlags(schemaFormatCmd)
+	schemaCmd.AddCommand(schemaFormatCmd)
+
+	importZanzibarCmd := schema.NewImportZanzibarCommand(rootCmd.Use)
+	schema.RegisterImportZanzibarFlags(importZanzibarCmd)
+	schemaCmd.AddCommand(importZanzibarCmd)
+
+	lintCmd := schema.NewLintCommand(rootCmd.Use)
+	schema.RegisterLintFlags(lintCmd)
+	rootCmd.AddCommand(lintCmd)
+
 	// Add server commands
 	var dsConfig cmdutil.DatastoreConfig
 	serveCmd := serve.NewServeCommand(rootCmd.Use, &dsConfig)
 	serve.RegisterServeFlags(serveCmd, &dsConfig)
 	rootCmd.AddCommand(serveCmd)
 
+	configCmd := serve.NewConfigCommand(rootCmd.Use)
+	rootCmd.AddCommand(configCmd)
+
+	validateConfigCmd := serve.NewValidateConfigCommand(rootCmd.Use)
+	configCmd.AddCommand(validateConfigCmd)
+
 	devtoolsCmd := serve.NewDevtoolsCommand(rootCmd.Use)
 	serve.RegisterDevtoolsFlags(devtoolsCmd)
 	rootCmd.AddCommand(devtoolsCmd)
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+)
+
+// jsonWebKey is a public key of a JSON Web Key Set, as defined by RFC 7517.
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+
+	// N and E are the modulus and exponent of RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// Curve, X and Y are the curve and coordinates of elliptic curve keys.
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// publicKeys returns the signing keys of the set by key ID. Keys of types which are not
+// supported, or which are not for signing, are skipped.
+func (set jsonWebKeySet) publicKeys() (map[string]crypto.PublicKey, error) {
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		var (
+			publicKey crypto.PublicKey
+			err       error
+		)
+		switch key.KeyType {
+		case "RSA":
+			publicKey, err = key.rsaPublicKey()
+		case "EC":
+			publicKey, err = key.ecdsaPublicKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key `%s`: %w", key.KeyID, err)
+		}
+		keys[key.KeyID] = publicKey
+	}
+	return keys, nil
+}
+
+func (key jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(key.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(key.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("exponent is too large")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (key jsonWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch key.Curve {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve `%s`", key.Curve)
+	}
+
+	x, err := decodeBigInt(key.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(key.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(encoded string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		return nil, errors.New("missing key parameter")
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+// supportedAlgorithms are the JWT signing algorithms which are verified.
+var supportedAlgorithms = map[string]struct{}{
+	"RS256": {},
+	"RS384": {},
+	"RS512": {},
+	"ES256": {},
+	"ES384": {},
+	"ES512": {},
+}
+
+// verifySignature verifies the signature of the signing input of a JWT with the key,
+// which must be of the type used by the algorithm.
+func verifySignature(algorithm string, key crypto.PublicKey, signingInput, signature []byte) error {
+	if _, ok := supportedAlgorithms[algorithm]; !ok {
+		return fmt.Errorf("unsupported algorithm %s", algorithm)
+	}
+
+	var (
+		hashFunc crypto.Hash
+		newHash  func() hash.Hash
+	)
+	switch algorithm[2:] {
+	case "256":
+		hashFunc, newHash = crypto.SHA256, sha256.New
+	case "384":
+		hashFunc, newHash = crypto.SHA384, sha512.New384
+	case "512":
+		hashFunc, newHash = crypto.SHA512, sha512.New
+	}
+	h := newHash()
+	h.Write(signingInput)
+	digest := h.Sum(nil)
+
+	switch algorithm[:2] {
+	case "RS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not an RSA key, as required by algorithm %s", algorithm)
+		}
+		return rsa.VerifyPKCS1v15(rsaKey, hashFunc, digest, signature)
+
+	case "ES":
+		ecdsaKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not an elliptic curve key, as required by algorithm %s", algorithm)
+		}
+
+		// Signatures are the concatenated, fixed-size coordinates r and s.
+		size := (ecdsaKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("signature has the wrong length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecdsaKey, digest, r, s) {
+			return errors.New("signature does not match")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported algorithm %s", algorithm)
+	}
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ParsePresharedKeysFile parses the contents of a file of named preshared keys, with one
+// key per line in the form `name=key`. Blank lines and lines starting with `#` are ignored.
+func ParsePresharedKeysFile(contents []byte) (map[string]string, error) {
+	keys := make(map[string]string)
+	for index, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("line %d: expected a preshared key of the form name=key", index+1)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		if _, ok := keys[name]; ok {
+			return nil, fmt.Errorf("line %d: preshared key name `%s` is not unique", index+1, name)
+		}
+		keys[name] = strings.TrimSpace(parts[1])
+	}
+	return keys, nil
+}
+
+// PresharedKeysFileWatcher keeps the keys accepted by an authenticator in sync with a file
+// of named preshared keys, so that keys can be added, rotated and revoked without
+// restarting the server.
+type PresharedKeysFileWatcher struct {
+	authenticator *PresharedKeyAuthenticator
+	config        PresharedKeys
+	path          string
+
+	contents []byte
+}
+
+// NewPresharedKeysFileWatcher creates a watcher which updates the authenticator to accept
+// the configured keys and the named keys of the file, loading the file immediately.
+func NewPresharedKeysFileWatcher(authenticator *PresharedKeyAuthenticator, config PresharedKeys, path string) (*PresharedKeysFileWatcher, error) {
+	watcher := &PresharedKeysFileWatcher{
+		authenticator: authenticator,
+		config:        config,
+		path:          path,
+	}
+	if _, err := watcher.reload(); err != nil {
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// Run reloads the file every interval until the context is canceled. A file which cannot
+// be read or is invalid is reported, and the previous keys remain accepted.
+func (w *PresharedKeysFileWatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := w.reload()
+			if err != nil {
+				log.Warn().Err(err).Str("path", w.path).Msg("unable to reload preshared keys; keeping the previous keys")
+				continue
+			}
+			if changed {
+				log.Info().Str("path", w.path).Strs("names", w.names()).Msg("reloaded preshared keys")
+			}
+		}
+	}
+}
+
+// reload updates the authenticator with the keys of the file, if it changed since it was
+// last loaded, returning whether it did.
+func (w *PresharedKeysFileWatcher) reload() (bool, error) {
+	contents, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return false, fmt.Errorf("unable to read preshared keys file %s: %w", w.path, err)
+	}
+	if w.contents != nil && bytes.Equal(contents, w.contents) {
+		return false, nil
+	}
+
+	fileKeys, err := ParsePresharedKeysFile(contents)
+	if err != nil {
+		return false, fmt.Errorf("invalid preshared keys file %s: %w", w.path, err)
+	}
+
+	config := w.config
+	config.Named = make(map[string]string, len(w.config.Named)+len(fileKeys))
+	for name, key := range w.config.Named {
+		config.Named[name] = key
+	}
+	for name, key := range fileKeys {
+		if _, ok := config.Named[name]; ok {
+			return false, fmt.Errorf("invalid preshared keys file %s: preshared key name `%s` is not unique", w.path, name)
+		}
+		config.Named[name] = key
+	}
+
+	if err := w.authenticator.Update(config); err != nil {
+		return false, fmt.Errorf("invalid preshared keys file %s: %w", w.path, err)
+	}
+	w.contents = contents
+	return true, nil
+}
+
+func (w *PresharedKeysFileWatcher) names() []string {
+	fileKeys, _ := ParsePresharedKeysFile(w.contents)
+	names := make([]string, 0, len(fileKeys))
+	for name := range fileKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
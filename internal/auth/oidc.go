@@ -0,0 +1,407 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+const (
+	// DefaultPrincipalClaim is the claim of a token which names its principal, if no other
+	// is configured.
+	DefaultPrincipalClaim = "sub"
+
+	// clockSkewLeeway is the amount of time by which the expiry and not-before times of a
+	// token are relaxed, to allow for clocks which differ between the issuer and the server.
+	clockSkewLeeway = 1 * time.Minute
+
+	// minKeyRefreshInterval is the minimum amount of time between fetches of the keys of
+	// the issuer triggered by tokens signed with unknown keys, so that such tokens cannot
+	// be used to flood the issuer with requests.
+	minKeyRefreshInterval = 30 * time.Second
+
+	maxIssuerResponseSize = 1 << 20
+)
+
+var errOIDCTenancy = errors.New("tokens issued by the OIDC issuer do not grant access to tenants")
+
+// OIDCConfig configures the verification of the JWT bearer tokens of an OIDC issuer by
+// RequireOIDCTokens.
+type OIDCConfig struct {
+	// IssuerURL is the URL of the issuer, from which its discovery document and keys are
+	// fetched, and which must match the issuer of tokens.
+	IssuerURL string
+
+	// Audience is the audience which tokens must be issued for.
+	Audience string
+
+	// PrincipalClaim is the claim of a token which names its principal. Defaults to
+	// DefaultPrincipalClaim.
+	PrincipalClaim string
+
+	// WriteScopes, if not empty, restricts write APIs to tokens granted at least one of
+	// the scopes.
+	WriteScopes []string
+
+	// KeyRefreshInterval is the interval at which the keys of the issuer are fetched, to
+	// pick up rotated keys. Keys are also fetched when a token is signed with an unknown
+	// key.
+	KeyRefreshInterval time.Duration
+
+	// HTTPClient is the client with which the discovery document and keys are fetched.
+	// Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// OIDCClaims are the verified claims of a token.
+type OIDCClaims struct {
+	Principal string
+	Scopes    []string
+}
+
+// HasAnyScope returns whether the token was granted any of the scopes.
+func (c OIDCClaims) HasAnyScope(scopes []string) bool {
+	for _, granted := range c.Scopes {
+		for _, scope := range scopes {
+			if granted == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OIDCVerifier verifies the JWT bearer tokens of an OIDC issuer against the keys it
+// publishes.
+type OIDCVerifier struct {
+	config  OIDCConfig
+	jwksURL string
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+}
+
+// NewOIDCVerifier creates a verifier of the tokens of the configured issuer, fetching its
+// keys, and refreshing them every KeyRefreshInterval until the context is canceled.
+func NewOIDCVerifier(ctx context.Context, config OIDCConfig) (*OIDCVerifier, error) {
+	if config.IssuerURL == "" {
+		return nil, errors.New("missing OIDC issuer URL")
+	}
+	if config.Audience == "" {
+		return nil, errors.New("missing OIDC audience")
+	}
+	if config.PrincipalClaim == "" {
+		config.PrincipalClaim = DefaultPrincipalClaim
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURL string `json:"jwks_uri"`
+	}
+	discoveryURL := strings.TrimSuffix(config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := fetchJSON(ctx, config.HTTPClient, discoveryURL, &discovery); err != nil {
+		return nil, fmt.Errorf("unable to fetch OIDC discovery document: %w", err)
+	}
+	if discovery.Issuer != config.IssuerURL {
+		return nil, fmt.Errorf("OIDC discovery document names issuer `%s` rather than `%s`", discovery.Issuer, config.IssuerURL)
+	}
+	if discovery.JWKSURL == "" {
+		return nil, errors.New("OIDC discovery document does not name a JWKS URL")
+	}
+
+	verifier := &OIDCVerifier{config: config, jwksURL: discovery.JWKSURL}
+	if err := verifier.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	if config.KeyRefreshInterval > 0 {
+		go verifier.refreshPeriodically(ctx)
+	}
+	return verifier, nil
+}
+
+func (v *OIDCVerifier) refreshPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(v.config.KeyRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.refreshKeys(ctx); err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Str("issuer", v.config.IssuerURL).Msg("unable to refresh OIDC issuer keys; keeping the previous keys")
+			}
+		}
+	}
+}
+
+func (v *OIDCVerifier) refreshKeys(ctx context.Context) error {
+	// Failed fetches also count towards the minimum refresh interval, so that an
+	// unavailable issuer is not retried on every request.
+	v.mu.Lock()
+	v.lastRefresh = time.Now()
+	v.mu.Unlock()
+
+	var set jsonWebKeySet
+	if err := fetchJSON(ctx, v.config.HTTPClient, v.jwksURL, &set); err != nil {
+		return fmt.Errorf("unable to fetch OIDC issuer keys: %w", err)
+	}
+
+	keys, err := set.publicKeys()
+	if err != nil {
+		return fmt.Errorf("unable to parse OIDC issuer keys: %w", err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+	return nil
+}
+
+// key returns the key with the ID, fetching the keys of the issuer if it is unknown and
+// they were not fetched recently.
+func (v *OIDCVerifier) key(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.lookupKey(keyID)
+	stale := time.Since(v.lastRefresh) >= minKeyRefreshInterval
+	v.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if stale {
+		if err := v.refreshKeys(ctx); err != nil {
+			return nil, err
+		}
+
+		v.mu.RLock()
+		key, ok = v.lookupKey(keyID)
+		v.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key `%s`", keyID)
+}
+
+// lookupKey returns the key with the ID. Tokens without a key ID are signed with the only
+// key of issuers which publish a single key. The lock must be held.
+func (v *OIDCVerifier) lookupKey(keyID string) (crypto.PublicKey, bool) {
+	if keyID == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+
+	key, ok := v.keys[keyID]
+	return key, ok
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// parseJWTHeader returns the decoded header of the token, failing if it is not a JWT.
+func parseJWTHeader(token string) (jwtHeader, []string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtHeader{}, nil, errors.New("token is not a JWT")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return jwtHeader{}, nil, fmt.Errorf("invalid JWT header: %w", err)
+	}
+	if header.Algorithm == "" {
+		return jwtHeader{}, nil, errors.New("invalid JWT header: missing algorithm")
+	}
+	return header, parts, nil
+}
+
+// Verify verifies the signature and claims of the token, returning its principal and
+// scopes.
+func (v *OIDCVerifier) Verify(ctx context.Context, token string) (OIDCClaims, error) {
+	header, parts, err := parseJWTHeader(token)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+
+	if _, ok := supportedAlgorithms[header.Algorithm]; !ok {
+		return OIDCClaims{}, fmt.Errorf("unsupported signing algorithm %s", header.Algorithm)
+	}
+
+	key, err := v.key(ctx, header.KeyID)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("invalid JWT signature: %w", err)
+	}
+	if err := verifySignature(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return OIDCClaims{}, fmt.Errorf("invalid JWT signature: %w", err)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return OIDCClaims{}, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+
+	if issuer, _ := claims["iss"].(string); issuer != v.config.IssuerURL {
+		return OIDCClaims{}, fmt.Errorf("token was issued by `%s` rather than `%s`", issuer, v.config.IssuerURL)
+	}
+
+	if !stringOrStrings(claims["aud"]).contains(v.config.Audience) {
+		return OIDCClaims{}, fmt.Errorf("token was not issued for audience `%s`", v.config.Audience)
+	}
+
+	now := time.Now()
+	expiry, ok := claims["exp"].(float64)
+	if !ok {
+		return OIDCClaims{}, errors.New("token does not expire")
+	}
+	if now.After(time.Unix(int64(expiry), 0).Add(clockSkewLeeway)) {
+		return OIDCClaims{}, errors.New("token has expired")
+	}
+	if notBefore, ok := claims["nbf"].(float64); ok && now.Add(clockSkewLeeway).Before(time.Unix(int64(notBefore), 0)) {
+		return OIDCClaims{}, errors.New("token is not yet valid")
+	}
+
+	principal, _ := claims[v.config.PrincipalClaim].(string)
+	if principal == "" {
+		return OIDCClaims{}, fmt.Errorf("token is missing principal claim `%s`", v.config.PrincipalClaim)
+	}
+
+	// Scopes are granted as a space-separated `scope` claim, or as an `scp` claim holding
+	// either a list or a space-separated string.
+	var scopes []string
+	if scope, ok := claims["scope"].(string); ok {
+		scopes = strings.Fields(scope)
+	} else if scp, ok := claims["scp"].(string); ok {
+		scopes = strings.Fields(scp)
+	} else {
+		scopes = stringOrStrings(claims["scp"])
+	}
+
+	return OIDCClaims{Principal: principal, Scopes: scopes}, nil
+}
+
+// RequireOIDCTokens requires that gRPC requests bearing a JWT have a token verified by the
+// verifier, scoping the request to the default tenant and recording its principal. If the
+// verifier is configured with write scopes, write APIs additionally require one of them.
+// Requests bearing any other token are authenticated by the fallback, such as one
+// requiring preshared keys.
+func RequireOIDCTokens(verifier *OIDCVerifier, fallback grpcauth.AuthFunc) grpcauth.AuthFunc {
+	return func(ctx context.Context) (context.Context, error) {
+		token, err := grpcauth.AuthFromMD(ctx, "bearer")
+		if err != nil {
+			return fallback(ctx)
+		}
+
+		if _, _, err := parseJWTHeader(token); err != nil {
+			return fallback(ctx)
+		}
+
+		claims, err := verifier.Verify(ctx, token)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid token: %s", err)
+		}
+
+		if requestedTenant(ctx) != datastore.DefaultTenant {
+			return nil, status.Error(codes.PermissionDenied, errOIDCTenancy.Error())
+		}
+
+		if len(verifier.config.WriteScopes) > 0 && IsWriteMethod(grpc.Method(ctx)) && !claims.HasAnyScope(verifier.config.WriteScopes) {
+			return nil, status.Errorf(codes.PermissionDenied, "token of %s is not granted any of the scopes required to write: %s", claims.Principal, strings.Join(verifier.config.WriteScopes, ", "))
+		}
+
+		ctx = datastore.ContextWithTenant(ctx, datastore.DefaultTenant)
+		return ContextWithPrincipal(ctx, claims.Principal), nil
+	}
+}
+
+// IsWriteMethod returns whether the gRPC method, given by its full name, writes data:
+// relationships, schema or namespace configs.
+func IsWriteMethod(fullMethod string) bool {
+	name := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	return strings.HasPrefix(name, "Write") || strings.HasPrefix(name, "Delete")
+}
+
+type stringList []string
+
+// stringOrStrings returns the values of a claim which may be either a string or a list of
+// strings.
+func stringOrStrings(claim interface{}) stringList {
+	switch typed := claim.(type) {
+	case string:
+		return stringList{typed}
+	case []interface{}:
+		values := make(stringList, 0, len(typed))
+		for _, value := range typed {
+			if str, ok := value.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func (sl stringList) contains(value string) bool {
+	for _, str := range sl {
+		if str == value {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, into interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, into)
+}
+
+func fetchJSON(ctx context.Context, client *http.Client, url string, into interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxIssuerResponseSize)).Decode(into)
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+const testAudience = "spicedb"
+
+type testIssuer struct {
+	server *httptest.Server
+	keys   []jsonWebKey
+
+	rsaKey   *rsa.PrivateKey
+	ecdsaKey *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	issuer := &testIssuer{rsaKey: rsaKey, ecdsaKey: ecdsaKey}
+	issuer.keys = []jsonWebKey{
+		{
+			KeyType: "RSA",
+			KeyID:   "rsa",
+			Use:     "sig",
+			N:       encodeBigInt(rsaKey.N),
+			E:       encodeBigInt(big.NewInt(int64(rsaKey.E))),
+		},
+		{
+			KeyType: "EC",
+			KeyID:   "ec",
+			Curve:   "P-256",
+			X:       encodeBigInt(ecdsaKey.X),
+			Y:       encodeBigInt(ecdsaKey.Y),
+		},
+	}
+
+	mux := http.NewServeMux()
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   issuer.server.URL,
+			"jwks_uri": issuer.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jsonWebKeySet{Keys: issuer.keys})
+	})
+	return issuer
+}
+
+func encodeBigInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func (ti *testIssuer) sign(t *testing.T, algorithm, keyID string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": algorithm, "kid": keyID, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch algorithm {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, ti.rsaKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, ti.ecdsaKey, digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		signature = []byte("unsigned")
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (ti *testIssuer) claims(overrides map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss":   ti.server.URL,
+		"aud":   testAudience,
+		"sub":   "user-123",
+		"email": "someone@example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "openid spicedb:read",
+	}
+	for key, value := range overrides {
+		if value == nil {
+			delete(claims, key)
+			continue
+		}
+		claims[key] = value
+	}
+	return claims
+}
+
+type methodTransportStream struct {
+	grpc.ServerTransportStream
+	method string
+}
+
+func (mts methodTransportStream) Method() string {
+	return mts.method
+}
+
+func TestRequireOIDCTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	verifier, err := NewOIDCVerifier(ctx, OIDCConfig{
+		IssuerURL:   issuer.server.URL,
+		Audience:    testAudience,
+		WriteScopes: []string{"spicedb:write"},
+	})
+	require.NoError(t, err)
+
+	emailVerifier, err := NewOIDCVerifier(ctx, OIDCConfig{
+		IssuerURL:      issuer.server.URL,
+		Audience:       testAudience,
+		PrincipalClaim: "email",
+	})
+	require.NoError(t, err)
+
+	const (
+		readMethod  = "/authzed.api.v1.PermissionsService/CheckPermission"
+		writeMethod = "/authzed.api.v1.PermissionsService/WriteRelationships"
+	)
+
+	testCases := []struct {
+		name              string
+		verifier          *OIDCVerifier
+		token             string
+		method            string
+		tenantHeader      string
+		expectedPrincipal string
+		expectedCode      codes.Code
+	}{
+		{"rsa signed token", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(nil)), readMethod, "", "user-123", codes.OK},
+		{"ecdsa signed token", verifier, issuer.sign(t, "ES256", "ec", issuer.claims(nil)), readMethod, "", "user-123", codes.OK},
+		{"configured principal claim", emailVerifier, issuer.sign(t, "RS256", "rsa", issuer.claims(nil)), readMethod, "", "someone@example.com", codes.OK},
+		{"audience list", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"aud": []string{"other", testAudience}})), readMethod, "", "user-123", codes.OK},
+		{"write with scope", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"scope": "spicedb:write"})), writeMethod, "", "user-123", codes.OK},
+		{"write with scp list", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"scope": nil, "scp": []string{"spicedb:write"}})), writeMethod, "", "user-123", codes.OK},
+		{"write without scope", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(nil)), writeMethod, "", "", codes.PermissionDenied},
+		{"write without configured scopes", emailVerifier, issuer.sign(t, "RS256", "rsa", issuer.claims(nil)), writeMethod, "", "someone@example.com", codes.OK},
+		{"tenant requested", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(nil)), readMethod, "acme", "", codes.PermissionDenied},
+		{"expired token", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), readMethod, "", "", codes.Unauthenticated},
+		{"token without expiry", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"exp": nil})), readMethod, "", "", codes.Unauthenticated},
+		{"token not yet valid", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})), readMethod, "", "", codes.Unauthenticated},
+		{"other audience", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"aud": "other"})), readMethod, "", "", codes.Unauthenticated},
+		{"other issuer", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"iss": "https://other.example.com"})), readMethod, "", "", codes.Unauthenticated},
+		{"missing principal", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(map[string]interface{}{"sub": nil})), readMethod, "", "", codes.Unauthenticated},
+		{"unknown key", verifier, issuer.sign(t, "RS256", "unknown", issuer.claims(nil)), readMethod, "", "", codes.Unauthenticated},
+		{"key of the wrong type", verifier, issuer.sign(t, "RS256", "ec", issuer.claims(nil)), readMethod, "", "", codes.Unauthenticated},
+		{"unsigned token", verifier, issuer.sign(t, "none", "rsa", issuer.claims(nil)), readMethod, "", "", codes.Unauthenticated},
+		{"tampered token", verifier, issuer.sign(t, "RS256", "rsa", issuer.claims(nil)) + "AA", readMethod, "", "", codes.Unauthenticated},
+		{"preshared key", verifier, "primary", writeMethod, "", "", codes.OK},
+		{"unknown preshared key", verifier, "otherkey", readMethod, "", "", codes.Unknown},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			md := metadata.Pairs("authorization", "bearer "+tc.token)
+			if tc.tenantHeader != "" {
+				md.Set(TenantMetadataKey, tc.tenantHeader)
+			}
+			reqCtx := metadata.NewIncomingContext(context.Background(), md)
+			reqCtx = grpc.NewContextWithServerTransportStream(reqCtx, methodTransportStream{method: tc.method})
+
+			authFunc := RequireOIDCTokens(tc.verifier, RequirePresharedKey("primary"))
+			authCtx, err := authFunc(reqCtx)
+			require.Equal(tc.expectedCode, status.Code(err), "unexpected error: %v", err)
+			if err != nil {
+				return
+			}
+
+			require.Equal(datastore.DefaultTenant, datastore.TenantFromContext(authCtx))
+
+			principal, ok := PrincipalFromContext(authCtx)
+			require.Equal(tc.expectedPrincipal != "", ok)
+			require.Equal(tc.expectedPrincipal, principal)
+		})
+	}
+}
+
+func TestOIDCVerifierFetchesRotatedKeys(t *testing.T) {
+	require := require.New(t)
+	issuer := newTestIssuer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rotated := issuer.keys
+	issuer.keys = nil
+
+	verifier, err := NewOIDCVerifier(ctx, OIDCConfig{
+		IssuerURL: issuer.server.URL,
+		Audience:  testAudience,
+	})
+	require.NoError(err)
+
+	issuer.keys = rotated
+	claims, err := verifier.Verify(ctx, issuer.sign(t, "RS256", "rsa", issuer.claims(nil)))
+	require.Error(err, "keys must not be fetched again within the minimum refresh interval")
+
+	verifier.mu.Lock()
+	verifier.lastRefresh = time.Now().Add(-minKeyRefreshInterval)
+	verifier.mu.Unlock()
+
+	claims, err = verifier.Verify(ctx, issuer.sign(t, "RS256", "rsa", issuer.claims(nil)))
+	require.NoError(err)
+	require.Equal("user-123", claims.Principal)
+	require.Equal([]string{"openid", "spicedb:read"}, claims.Scopes)
+}
+
+func TestIsWriteMethod(t *testing.T) {
+	require.True(t, IsWriteMethod("/authzed.api.v1.PermissionsService/WriteRelationships"))
+	require.True(t, IsWriteMethod("/authzed.api.v1.PermissionsService/DeleteRelationships"))
+	require.True(t, IsWriteMethod("/authzed.api.v0.NamespaceService/DeleteConfigs"))
+	require.True(t, IsWriteMethod("/spicedb.v1.SchemaBundleService/WriteSchemaBundle"))
+	require.False(t, IsWriteMethod("/authzed.api.v1.PermissionsService/CheckPermission"))
+	require.False(t, IsWriteMethod("/authzed.api.v1.SchemaService/ReadSchema"))
+}
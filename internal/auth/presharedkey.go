@@ -5,13 +5,22 @@ import (
 	"crypto/subtle"
 	"errors"
 	"fmt"
+	"sync"
 
 	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/authzed/spicedb/internal/datastore"
 )
 
 const errInvalidPresharedKey = "invalid preshared key: %w"
 
-var errInvalidToken = errors.New("invalid token")
+var (
+	errInvalidToken       = errors.New("invalid token")
+	errTenancyUnsupported = errors.New("tenants are not supported by the configured datastore")
+	errTenantMismatch     = errors.New("preshared key does not grant access to the requested tenant")
+)
 
 // RequirePresharedKey requires that gRPC requests have a Bearer Token value
 // equivalent to the provided preshared key.
@@ -29,3 +38,203 @@ func RequirePresharedKey(presharedKey string) grpcauth.AuthFunc {
 		return ctx, nil
 	}
 }
+
+// PrimaryKeyName is the name under which the usage of the primary preshared key is
+// recorded.
+const PrimaryKeyName = "primary"
+
+var keyRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "auth",
+	Name:      "preshared_key_requests_total",
+	Help:      "total number of requests authenticated with each preshared key, by the name of the key",
+}, []string{"key"})
+
+// PresharedKeys configures the preshared keys accepted by RequirePresharedKeys.
+type PresharedKeys struct {
+	// Primary grants access to any tenant. Requests using it are scoped to the tenant
+	// named by the TenantMetadataKey header, if any, and to the default tenant otherwise.
+	Primary string
+
+	// Named maps names to additional keys which grant the same access as the primary key,
+	// so that keys can be issued to clients individually, and rotated or revoked without
+	// affecting other clients. Usage of each key is recorded under its name.
+	Named map[string]string
+
+	// Tenants maps tenant names to keys which grant access to only that tenant.
+	Tenants map[string]string
+
+	// SubjectBound maps subject bindings, in the form accepted by ParseSubjectBinding, to
+	// keys which grant access to the default tenant only on behalf of the subjects allowed
+	// by the binding.
+	SubjectBound map[string]string
+
+	// TenantsSupported is whether the datastore isolates tenants. If not, no tenant keys
+	// may be configured and requests naming a tenant are rejected.
+	TenantsSupported bool
+}
+
+type presharedKey struct {
+	key  []byte
+	name string
+
+	// unrestricted keys grant access to any tenant, and the other fields are unset.
+	unrestricted bool
+	tenant       string
+	binding      *SubjectBinding
+}
+
+// compilePresharedKeys validates the configured keys, returning them all.
+func compilePresharedKeys(config PresharedKeys) ([]presharedKey, error) {
+	if len(config.Tenants) > 0 && !config.TenantsSupported {
+		return nil, errTenancyUnsupported
+	}
+
+	keys := []presharedKey{{key: []byte(config.Primary), name: PrimaryKeyName, unrestricted: true}}
+	seen := map[string]struct{}{config.Primary: {}}
+	names := map[string]struct{}{PrimaryKeyName: {}}
+	addKey := func(description string, key presharedKey) error {
+		if len(key.key) == 0 {
+			return fmt.Errorf("missing preshared key for %s", description)
+		}
+		if _, ok := seen[string(key.key)]; ok {
+			return fmt.Errorf("preshared key for %s is not unique", description)
+		}
+		if _, ok := names[key.name]; ok {
+			return fmt.Errorf("preshared key name `%s` is not unique", key.name)
+		}
+		seen[string(key.key)] = struct{}{}
+		names[key.name] = struct{}{}
+		keys = append(keys, key)
+		return nil
+	}
+
+	for name, key := range config.Named {
+		if name == "" {
+			return nil, errors.New("missing name for preshared key")
+		}
+		if err := addKey(fmt.Sprintf("key `%s`", name), presharedKey{key: []byte(key), name: name, unrestricted: true}); err != nil {
+			return nil, err
+		}
+	}
+
+	for tenant, key := range config.Tenants {
+		if err := ValidateTenantName(tenant); err != nil {
+			return nil, err
+		}
+		if err := addKey(fmt.Sprintf("tenant `%s`", tenant), presharedKey{key: []byte(key), name: "tenant:" + tenant, tenant: tenant}); err != nil {
+			return nil, err
+		}
+	}
+
+	for bindingStr, key := range config.SubjectBound {
+		binding, err := ParseSubjectBinding(bindingStr)
+		if err != nil {
+			return nil, err
+		}
+		if err := addKey(fmt.Sprintf("subject binding `%s`", bindingStr), presharedKey{key: []byte(key), name: "binding:" + bindingStr, tenant: datastore.DefaultTenant, binding: &binding}); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// PresharedKeyAuthenticator authenticates gRPC requests against a set of preshared keys
+// which can be replaced while serving, such as to rotate them.
+type PresharedKeyAuthenticator struct {
+	tenantsSupported bool
+
+	mu   sync.RWMutex
+	keys []presharedKey
+}
+
+// NewPresharedKeyAuthenticator creates an authenticator accepting the configured keys.
+func NewPresharedKeyAuthenticator(config PresharedKeys) (*PresharedKeyAuthenticator, error) {
+	keys, err := compilePresharedKeys(config)
+	if err != nil {
+		return nil, err
+	}
+	return &PresharedKeyAuthenticator{tenantsSupported: config.TenantsSupported, keys: keys}, nil
+}
+
+// Update replaces the accepted keys with the configured keys. If the configuration is
+// invalid, the previous keys remain accepted.
+func (pka *PresharedKeyAuthenticator) Update(config PresharedKeys) error {
+	if config.TenantsSupported != pka.tenantsSupported {
+		return errors.New("support for tenants cannot be changed while serving")
+	}
+
+	keys, err := compilePresharedKeys(config)
+	if err != nil {
+		return err
+	}
+
+	pka.mu.Lock()
+	defer pka.mu.Unlock()
+	pka.keys = keys
+	return nil
+}
+
+// Authenticate requires that gRPC requests have a Bearer Token value equivalent to one of
+// the accepted keys, and scopes the request to the tenant and subject binding of that key.
+// It is a grpcauth.AuthFunc.
+func (pka *PresharedKeyAuthenticator) Authenticate(ctx context.Context) (context.Context, error) {
+	token, err := grpcauth.AuthFromMD(ctx, "bearer")
+	if err != nil {
+		return nil, fmt.Errorf(errInvalidPresharedKey, err)
+	}
+
+	requested := requestedTenant(ctx)
+	if requested != datastore.DefaultTenant {
+		if !pka.tenantsSupported {
+			return nil, errTenancyUnsupported
+		}
+		if err := ValidateTenantName(requested); err != nil {
+			return nil, err
+		}
+	}
+
+	pka.mu.RLock()
+	keys := pka.keys
+	pka.mu.RUnlock()
+
+	// Every key is compared, so that the time taken does not reveal which key matched.
+	matched := -1
+	for index, candidate := range keys {
+		if subtle.ConstantTimeCompare(candidate.key, []byte(token)) == 1 {
+			matched = index
+		}
+	}
+	if matched < 0 {
+		return nil, fmt.Errorf(errInvalidPresharedKey, errInvalidToken)
+	}
+
+	key := keys[matched]
+	if key.unrestricted {
+		keyRequestsCounter.WithLabelValues(key.name).Inc()
+		return ContextWithKeyName(datastore.ContextWithTenant(ctx, requested), key.name), nil
+	}
+
+	if requested != datastore.DefaultTenant && requested != key.tenant {
+		return nil, errTenantMismatch
+	}
+	keyRequestsCounter.WithLabelValues(key.name).Inc()
+
+	ctx = ContextWithKeyName(datastore.ContextWithTenant(ctx, key.tenant), key.name)
+	if key.binding != nil {
+		ctx = ContextWithSubjectBinding(ctx, *key.binding)
+	}
+	return ctx, nil
+}
+
+// RequirePresharedKeys requires that gRPC requests have a Bearer Token value equivalent to
+// one of the configured preshared keys, and scopes the request to the tenant and subject
+// binding of that key.
+func RequirePresharedKeys(config PresharedKeys) (grpcauth.AuthFunc, error) {
+	authenticator, err := NewPresharedKeyAuthenticator(config)
+	if err != nil {
+		return nil, err
+	}
+	return authenticator.Authenticate, nil
+}
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+func TestRequirePresharedKeys(t *testing.T) {
+	testCases := []struct {
+		name           string
+		token          string
+		tenantHeader   string
+		expectedTenant string
+		expectBinding  string
+		expectErr      bool
+	}{
+		{"primary key", "primary", "", datastore.DefaultTenant, "", false},
+		{"primary key selecting a tenant", "primary", "globex", "globex", "", false},
+		{"primary key selecting an invalid tenant", "primary", "Not/Valid", "", "", true},
+		{"tenant key", "acmekey", "", "acme", "", false},
+		{"tenant key naming its tenant", "acmekey", "acme", "acme", "", false},
+		{"tenant key naming another tenant", "acmekey", "globex", "", "", true},
+		{"subject-bound key", "edgekey", "", datastore.DefaultTenant, "user:edge-*", false},
+		{"subject-bound key naming a tenant", "edgekey", "acme", "", "", true},
+		{"named key", "billingkey", "", datastore.DefaultTenant, "", false},
+		{"named key selecting a tenant", "billingkey", "globex", "globex", "", false},
+		{"unknown key", "otherkey", "", "", "", true},
+	}
+
+	authFunc, err := RequirePresharedKeys(PresharedKeys{
+		Primary:          "primary",
+		Named:            map[string]string{"billing": "billingkey"},
+		Tenants:          map[string]string{"acme": "acmekey"},
+		SubjectBound:     map[string]string{"user:edge-*": "edgekey"},
+		TenantsSupported: true,
+	})
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			md := metadata.Pairs("authorization", "bearer "+tc.token)
+			if tc.tenantHeader != "" {
+				md.Set(TenantMetadataKey, tc.tenantHeader)
+			}
+
+			ctx, err := authFunc(metadata.NewIncomingContext(context.Background(), md))
+			if tc.expectErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tc.expectedTenant, datastore.TenantFromContext(ctx))
+
+			binding, ok := SubjectBindingFromContext(ctx)
+			require.Equal(tc.expectBinding != "", ok)
+			if ok {
+				require.Equal(tc.expectBinding, binding.String())
+			}
+		})
+	}
+}
+
+func TestRequirePresharedKeysInvalidConfig(t *testing.T) {
+	require := require.New(t)
+
+	_, err := RequirePresharedKeys(PresharedKeys{Primary: "primary", Tenants: map[string]string{"acme": "acmekey"}})
+	require.Error(err)
+
+	_, err = RequirePresharedKeys(PresharedKeys{Primary: "primary", Tenants: map[string]string{"acme": "primary"}, TenantsSupported: true})
+	require.Error(err)
+
+	_, err = RequirePresharedKeys(PresharedKeys{Primary: "primary", SubjectBound: map[string]string{"user:*": "edgekey"}})
+	require.Error(err)
+
+	_, err = RequirePresharedKeys(PresharedKeys{Primary: "primary", Named: map[string]string{"billing": "primary"}})
+	require.Error(err)
+
+	_, err = RequirePresharedKeys(PresharedKeys{Primary: "primary", Named: map[string]string{PrimaryKeyName: "otherkey"}})
+	require.Error(err)
+
+	authFunc, err := RequirePresharedKeys(PresharedKeys{Primary: "primary"})
+	require.NoError(err)
+
+	md := metadata.Pairs("authorization", "bearer primary", TenantMetadataKey, "acme")
+	_, err = authFunc(metadata.NewIncomingContext(context.Background(), md))
+	require.Error(err)
+}
+
+func authenticate(authFunc func(context.Context) (context.Context, error), token string) error {
+	_, err := authFunc(metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer "+token)))
+	return err
+}
+
+func TestPresharedKeyAuthenticatorUpdate(t *testing.T) {
+	require := require.New(t)
+
+	authenticator, err := NewPresharedKeyAuthenticator(PresharedKeys{
+		Primary: "primary",
+		Named:   map[string]string{"billing": "oldkey"},
+	})
+	require.NoError(err)
+	require.NoError(authenticate(authenticator.Authenticate, "oldkey"))
+
+	ctx, err := authenticator.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer oldkey")))
+	require.NoError(err)
+	name, ok := KeyNameFromContext(ctx)
+	require.True(ok)
+	require.Equal("billing", name)
+
+	require.NoError(authenticator.Update(PresharedKeys{
+		Primary: "primary",
+		Named:   map[string]string{"billing": "newkey"},
+	}))
+	require.NoError(authenticate(authenticator.Authenticate, "newkey"))
+	require.NoError(authenticate(authenticator.Authenticate, "primary"))
+	require.Error(authenticate(authenticator.Authenticate, "oldkey"))
+
+	// Invalid configurations leave the previous keys in place.
+	require.Error(authenticator.Update(PresharedKeys{
+		Primary: "primary",
+		Named:   map[string]string{"billing": "primary"},
+	}))
+	require.NoError(authenticate(authenticator.Authenticate, "newkey"))
+
+	require.Error(authenticator.Update(PresharedKeys{Primary: "primary", TenantsSupported: true}))
+}
+
+func TestPresharedKeysFileWatcher(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(ioutil.WriteFile(path, []byte("# keys issued to clients\nbilling=billingkey\n\nreports = reportskey\n"), 0o600))
+
+	config := PresharedKeys{Primary: "primary", Named: map[string]string{"admin": "adminkey"}}
+	authenticator, err := NewPresharedKeyAuthenticator(config)
+	require.NoError(err)
+
+	watcher, err := NewPresharedKeysFileWatcher(authenticator, config, path)
+	require.NoError(err)
+	for _, token := range []string{"primary", "adminkey", "billingkey", "reportskey"} {
+		require.NoError(authenticate(authenticator.Authenticate, token))
+	}
+
+	changed, err := watcher.reload()
+	require.NoError(err)
+	require.False(changed)
+
+	// Rotate the billing key and revoke the reports key.
+	require.NoError(ioutil.WriteFile(path, []byte("billing=rotatedkey\n"), 0o600))
+	changed, err = watcher.reload()
+	require.NoError(err)
+	require.True(changed)
+	require.Equal([]string{"billing"}, watcher.names())
+
+	require.NoError(authenticate(authenticator.Authenticate, "rotatedkey"))
+	require.NoError(authenticate(authenticator.Authenticate, "adminkey"))
+	require.Error(authenticate(authenticator.Authenticate, "billingkey"))
+	require.Error(authenticate(authenticator.Authenticate, "reportskey"))
+
+	// An invalid file leaves the previous keys in place.
+	require.NoError(ioutil.WriteFile(path, []byte("admin=otherkey\n"), 0o600))
+	_, err = watcher.reload()
+	require.Error(err)
+	require.NoError(authenticate(authenticator.Authenticate, "rotatedkey"))
+
+	require.NoError(ioutil.WriteFile(path, []byte("notakey\n"), 0o600))
+	_, err = watcher.reload()
+	require.Error(err)
+	require.NoError(authenticate(authenticator.Authenticate, "rotatedkey"))
+}
+
+func TestSubjectBinding(t *testing.T) {
+	testCases := []struct {
+		binding     string
+		subjectType string
+		objectID    string
+		allowed     bool
+	}{
+		{"user", "user", "alice", true},
+		{"user", "group", "alice", false},
+		{"user", "user", "*", false},
+		{"user:alice", "user", "alice", true},
+		{"user:alice", "user", "alice2", false},
+		{"user:edge-*", "user", "edge-alice", true},
+		{"user:edge-*", "user", "alice", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.binding+"/"+tc.subjectType+":"+tc.objectID, func(t *testing.T) {
+			require := require.New(t)
+
+			binding, err := ParseSubjectBinding(tc.binding)
+			require.NoError(err)
+			require.Equal(tc.binding, binding.String())
+			require.Equal(tc.allowed, binding.Allows(tc.subjectType, tc.objectID))
+		})
+	}
+}
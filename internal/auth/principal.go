@@ -0,0 +1,39 @@
+package auth
+
+import "context"
+
+type (
+	principalCtxKeyType struct{}
+	keyNameCtxKeyType   struct{}
+)
+
+var (
+	principalKey principalCtxKeyType = struct{}{}
+	keyNameKey   keyNameCtxKeyType   = struct{}{}
+)
+
+// ContextWithPrincipal returns a new context which records the verified identity of the
+// client making the request.
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFromContext returns the verified identity of the client making the request, if
+// its authentication identified it.
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey).(string)
+	return principal, ok
+}
+
+// ContextWithKeyName returns a new context which records the name of the preshared key
+// with which the request authenticated, such as PrimaryKeyName.
+func ContextWithKeyName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, keyNameKey, name)
+}
+
+// KeyNameFromContext returns the name of the preshared key with which the request
+// authenticated, if it authenticated with one.
+func KeyNameFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(keyNameKey).(string)
+	return name, ok
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SubjectBinding restricts the subjects on whose behalf a request may act to those of a
+// single type and, optionally, with object IDs matching an exact ID or an ID prefix.
+type SubjectBinding struct {
+	SubjectType string
+
+	// ObjectID restricts the object IDs of subjects to those starting with it, if
+	// IDPrefixOnly is set, or to exactly it otherwise. If empty, any ID is allowed.
+	ObjectID     string
+	IDPrefixOnly bool
+}
+
+// ParseSubjectBinding parses a subject binding of the form `type`, `type:id`, or
+// `type:prefix*`.
+func ParseSubjectBinding(binding string) (SubjectBinding, error) {
+	parts := strings.SplitN(binding, ":", 2)
+	if parts[0] == "" {
+		return SubjectBinding{}, fmt.Errorf("invalid subject binding `%s`: missing subject type", binding)
+	}
+
+	parsed := SubjectBinding{SubjectType: parts[0]}
+	if len(parts) == 2 {
+		if parts[1] == "" || parts[1] == "*" {
+			return SubjectBinding{}, fmt.Errorf("invalid subject binding `%s`: missing subject ID or ID prefix", binding)
+		}
+		parsed.ObjectID = strings.TrimSuffix(parts[1], "*")
+		parsed.IDPrefixOnly = parsed.ObjectID != parts[1]
+	}
+	return parsed, nil
+}
+
+// Allows returns whether the subject with the specified type and object ID is allowed by
+// the binding. Wildcard subjects are never allowed, as they stand for subjects outside
+// of the binding.
+func (sb SubjectBinding) Allows(subjectType, objectID string) bool {
+	if subjectType != sb.SubjectType || objectID == "" || objectID == "*" {
+		return false
+	}
+
+	switch {
+	case sb.ObjectID == "":
+		return true
+	case sb.IDPrefixOnly:
+		return strings.HasPrefix(objectID, sb.ObjectID)
+	default:
+		return objectID == sb.ObjectID
+	}
+}
+
+func (sb SubjectBinding) String() string {
+	switch {
+	case sb.ObjectID == "":
+		return sb.SubjectType
+	case sb.IDPrefixOnly:
+		return fmt.Sprintf("%s:%s*", sb.SubjectType, sb.ObjectID)
+	default:
+		return fmt.Sprintf("%s:%s", sb.SubjectType, sb.ObjectID)
+	}
+}
+
+type subjectBindingCtxKeyType struct{}
+
+var subjectBindingKey subjectBindingCtxKeyType = struct{}{}
+
+// ContextWithSubjectBinding returns a new context which restricts the request to act on
+// behalf of subjects allowed by the binding.
+func ContextWithSubjectBinding(ctx context.Context, binding SubjectBinding) context.Context {
+	return context.WithValue(ctx, subjectBindingKey, binding)
+}
+
+// SubjectBindingFromContext returns the subject binding of the request, if any.
+func SubjectBindingFromContext(ctx context.Context) (SubjectBinding, bool) {
+	binding, ok := ctx.Value(subjectBindingKey).(SubjectBinding)
+	return binding, ok
+}
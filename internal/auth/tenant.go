@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+// TenantMetadataKey is the key in which requests authenticated with the primary preshared
+// key select the tenant on whose behalf they operate. It is also used to propagate the
+// tenant of dispatched requests between nodes.
+const TenantMetadataKey = "x-spicedb-tenant"
+
+var tenantNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+
+// ValidateTenantName returns an error if the specified name is not a valid tenant name.
+func ValidateTenantName(name string) error {
+	if !tenantNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid tenant name `%s`: must match %s", name, tenantNameRegex)
+	}
+	return nil
+}
+
+func requestedTenant(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return datastore.DefaultTenant
+	}
+	if values := md.Get(TenantMetadataKey); len(values) > 0 {
+		return values[0]
+	}
+	return datastore.DefaultTenant
+}
@@ -0,0 +1,49 @@
+package datastore
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultBarrierPollInterval is the default interval at which WaitForRevision checks
+// the optimized revision of the datastore.
+const DefaultBarrierPollInterval = 10 * time.Millisecond
+
+// WaitForRevision blocks until the optimized revision of the datastore is at least
+// the specified revision, such that subsequent minimize latency reads are guaranteed
+// to observe all writes made at or before it. This acts as a write fence for tests and
+// sync jobs which need read-after-write behavior across quantization windows without
+// sleeping.
+//
+// A revision which is newer than the head revision of the datastore can never be
+// reached and is reported as an invalid revision.
+func WaitForRevision(ctx context.Context, ds Datastore, revision Revision, pollInterval time.Duration) error {
+	head, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	if revision.GreaterThan(head) {
+		return NewInvalidRevisionErr(revision, RevisionInFuture)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		optimized, err := ds.OptimizedRevision(ctx)
+		if err != nil {
+			return err
+		}
+
+		if optimized.GreaterThanOrEqual(revision) {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
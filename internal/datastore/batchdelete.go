@@ -0,0 +1,115 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+var errInvalidBatchSize = errors.New("batch size must be greater than zero")
+
+// BatchDeleteResult describes the outcome of deleting relationships in batches.
+type BatchDeleteResult struct {
+	// Revision is the revision at which the last batch was deleted.
+	Revision Revision
+
+	// Deleted is the number of relationships which were deleted.
+	Deleted uint64
+
+	// MoreRemaining is set if the limit was reached while relationships matching the
+	// filter remained.
+	MoreRemaining bool
+}
+
+// DeleteRelationshipsInBatches deletes the relationships matching the filter in batches of
+// at most batchSize relationships, each in its own transaction, so that a broad filter
+// does not hold locks for the duration of a single giant transaction. At most limit
+// relationships are deleted, or all of them if limit is zero. The preconditions are
+// checked only with the first batch.
+//
+// Unlike DeleteRelationships, the deletion is not atomic: if an error is returned, the
+// batches which were already deleted remain deleted.
+func DeleteRelationshipsInBatches(
+	ctx context.Context,
+	ds Datastore,
+	preconditions []*v1.Precondition,
+	filter *v1.RelationshipFilter,
+	batchSize, limit uint64,
+) (BatchDeleteResult, error) {
+	result := BatchDeleteResult{Revision: NoRevision}
+	if batchSize == 0 {
+		return result, errInvalidBatchSize
+	}
+
+	for {
+		size := batchSize
+		if limit > 0 && limit-result.Deleted < size {
+			size = limit - result.Deleted
+		}
+
+		head, err := ds.HeadRevision(ctx)
+		if err != nil {
+			return result, err
+		}
+
+		if size == 0 {
+			remaining, err := readBatch(ctx, ds, filter, head, options.LimitOne)
+			if err != nil {
+				return result, err
+			}
+			result.MoreRemaining = len(remaining) > 0
+			return result, nil
+		}
+
+		batch, err := readBatch(ctx, ds, filter, head, &size)
+		if err != nil {
+			return result, err
+		}
+
+		if len(batch) == 0 {
+			if result.Deleted == 0 {
+				// Nothing matched, but the preconditions must still be checked.
+				result.Revision, err = ds.DeleteRelationships(ctx, preconditions, filter)
+			}
+			return result, err
+		}
+
+		revision, err := ds.WriteTuples(ctx, preconditions, batch)
+		if err != nil {
+			return result, err
+		}
+		preconditions = nil
+		DeleteBatchQueue.ObserveBatch(len(batch), int(batchSize))
+
+		result.Revision = revision
+		result.Deleted += uint64(len(batch))
+		if uint64(len(batch)) < size {
+			return result, nil
+		}
+	}
+}
+
+func readBatch(ctx context.Context, ds Datastore, filter *v1.RelationshipFilter, revision Revision, limit *uint64) ([]*v1.RelationshipUpdate, error) {
+	it, err := ds.QueryTuples(ctx, filter, revision, options.WithLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var batch []*v1.RelationshipUpdate
+	for tpl := it.Next(); tpl != nil; tpl = it.Next() {
+		batch = append(batch, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+			Relationship: tuple.ToRelationship(tpl),
+		})
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+
+	return batch, nil
+}
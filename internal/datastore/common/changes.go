@@ -19,6 +19,7 @@ type Changes map[uint64]*changeRecord
 type changeRecord struct {
 	tupleTouches map[string]*v0.RelationTuple
 	tupleDeletes map[string]*v0.RelationTuple
+	metadata     *datastore.TransactionMetadata
 }
 
 // NewChanges creates a new Changes object for change tracking and de-duplication.
@@ -33,14 +34,7 @@ func (ch Changes) AddChange(
 	tpl *v0.RelationTuple,
 	op v0.RelationTupleUpdate_Operation,
 ) {
-	revisionChanges, ok := ch[revTxID]
-	if !ok {
-		revisionChanges = &changeRecord{
-			tupleTouches: make(map[string]*v0.RelationTuple),
-			tupleDeletes: make(map[string]*v0.RelationTuple),
-		}
-		ch[revTxID] = revisionChanges
-	}
+	revisionChanges := ch.record(revTxID)
 
 	tplKey := tuple.String(tpl)
 
@@ -61,6 +55,32 @@ func (ch Changes) AddChange(
 	}
 }
 
+// AddRevision records the specified revision even if it has no changes, such as when all
+// of its changes were filtered out, so that it is reported as progress of the watch.
+func (ch Changes) AddRevision(revTxID uint64) {
+	ch.record(revTxID)
+}
+
+func (ch Changes) record(revTxID uint64) *changeRecord {
+	revisionChanges, ok := ch[revTxID]
+	if !ok {
+		revisionChanges = &changeRecord{
+			tupleTouches: make(map[string]*v0.RelationTuple),
+			tupleDeletes: make(map[string]*v0.RelationTuple),
+		}
+		ch[revTxID] = revisionChanges
+	}
+	return revisionChanges
+}
+
+// SetMetadata records the metadata of the transaction which produced the changes at
+// the specified revision. Metadata for revisions without any changes is ignored.
+func (ch Changes) SetMetadata(revTxID uint64, metadata *datastore.TransactionMetadata) {
+	if revisionChanges, ok := ch[revTxID]; ok {
+		revisionChanges.metadata = metadata
+	}
+}
+
 // AsRevisionChanges returns the list of changes processed so far as a datastore watch
 // compatible, ordered, changelist.
 func (ch Changes) AsRevisionChanges() (changes []*datastore.RevisionChanges) {
@@ -73,11 +93,12 @@ func (ch Changes) AsRevisionChanges() (changes []*datastore.RevisionChanges) {
 	})
 
 	for _, revTxID := range revisionsWithChanges {
+		revisionChangeRecord := ch[revTxID]
 		revisionChange := &datastore.RevisionChanges{
 			Revision: revisionFromTransactionID(revTxID),
+			Metadata: revisionChangeRecord.metadata,
 		}
 
-		revisionChangeRecord := ch[revTxID]
 		for _, tpl := range revisionChangeRecord.tupleTouches {
 			revisionChange.Changes = append(revisionChange.Changes, &v0.RelationTupleUpdate{
 				Operation: v0.RelationTupleUpdate_TOUCH,
@@ -185,6 +185,28 @@ func TestChanges(t *testing.T) {
 	}
 }
 
+func TestChangesMetadata(t *testing.T) {
+	require := require.New(t)
+
+	ctx := context.Background()
+	metadata := &datastore.TransactionMetadata{
+		Caller:    "sync-job",
+		RequestID: "abc123",
+		Labels:    map[string]string{"source": "ldap"},
+	}
+
+	ch := NewChanges()
+	ch.AddChange(ctx, 1, tuple.MustParse(tuple1), v0.RelationTupleUpdate_TOUCH)
+	ch.AddChange(ctx, 2, tuple.MustParse(tuple2), v0.RelationTupleUpdate_TOUCH)
+	ch.SetMetadata(1, metadata)
+	ch.SetMetadata(3, metadata)
+
+	require.Equal([]*datastore.RevisionChanges{
+		{Revision: rev1, Changes: []*v0.RelationTupleUpdate{touch(tuple1)}, Metadata: metadata},
+		{Revision: rev2, Changes: []*v0.RelationTupleUpdate{touch(tuple2)}},
+	}, ch.AsRevisionChanges())
+}
+
 func TestCanonicalize(t *testing.T) {
 	testCases := []struct {
 		name            string
@@ -306,6 +328,7 @@ func canonicalize(in []*datastore.RevisionChanges) []*datastore.RevisionChanges
 		out = append(out, &datastore.RevisionChanges{
 			Revision: rev.Revision,
 			Changes:  outChanges,
+			Metadata: rev.Metadata,
 		})
 	}
 
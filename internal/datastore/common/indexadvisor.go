@@ -0,0 +1,209 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+// maxIndexNameLength is the maximum length of an identifier in Postgres; longer
+// advised index names are shortened to fit.
+const maxIndexNameLength = 63
+
+var filterShapeSampleRegex = regexp.MustCompile(
+	fmt.Sprintf(`^%s\{(?:[^}]*,)?columns="([^"]*)"[^}]*\}\s+(\S+)`, FilterShapeMetricName),
+)
+
+// ObservedFilterShape is a set of columns on which tuple queries were observed to
+// filter, along with the number of such queries.
+type ObservedFilterShape struct {
+	Columns []string
+	Queries float64
+}
+
+// ParseObservedFilterShapes reads the observed tuple query filter shapes from metrics
+// in the Prometheus text exposition format. Samples for the same shape, such as those
+// scraped from multiple instances, are summed.
+func ParseObservedFilterShapes(r io.Reader) ([]ObservedFilterShape, error) {
+	queriesByShape := make(map[string]float64)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		groups := filterShapeSampleRegex.FindStringSubmatch(scanner.Text())
+		if len(groups) == 0 {
+			continue
+		}
+
+		queries, err := strconv.ParseFloat(groups[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample for filter shape `%s`: %w", groups[1], err)
+		}
+		queriesByShape[groups[1]] += queries
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	shapes := make([]ObservedFilterShape, 0, len(queriesByShape))
+	for columns, queries := range queriesByShape {
+		if columns == "" {
+			continue
+		}
+		shapes = append(shapes, ObservedFilterShape{strings.Split(columns, ","), queries})
+	}
+
+	return shapes, nil
+}
+
+// IndexRecommendation is an index which is expected to benefit observed tuple queries.
+type IndexRecommendation struct {
+	Name    string
+	Columns []string
+
+	// Queries is the number of observed queries which would be fully served by the index,
+	// and is the estimate of its benefit.
+	Queries float64
+
+	// BestExistingIndex is the existing index which serves the most filtered columns of
+	// those queries, if any, and ServedColumns is the number of columns it serves.
+	BestExistingIndex string
+	ServedColumns     int
+}
+
+// RecommendIndexes recommends indexes over the tuple table for the observed filter shapes
+// which cannot be fully served by an existing index, ordered by estimated benefit.
+//
+// An index fully serves a shape when its leading columns are exactly the filtered columns,
+// in any order.
+func RecommendIndexes(shapes []ObservedFilterShape, existing []datastore.TupleIndex) []IndexRecommendation {
+	// Consider the widest shapes first, so that narrower shapes can reuse their
+	// recommendations when possible.
+	sorted := append([]ObservedFilterShape(nil), shapes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if len(sorted[i].Columns) != len(sorted[j].Columns) {
+			return len(sorted[i].Columns) > len(sorted[j].Columns)
+		}
+		return sorted[i].Queries > sorted[j].Queries
+	})
+
+	var recommendations []*IndexRecommendation
+	for _, shape := range sorted {
+		bestIndex, servedColumns := bestServingIndex(shape.Columns, existing)
+		if servedColumns == len(shape.Columns) {
+			continue
+		}
+
+		reused := false
+		for _, recommendation := range recommendations {
+			if servesColumns(recommendation.Columns, shape.Columns) == len(shape.Columns) {
+				recommendation.Queries += shape.Queries
+				reused = true
+				break
+			}
+		}
+		if reused {
+			continue
+		}
+
+		recommendations = append(recommendations, &IndexRecommendation{
+			Name:              AdvisedIndexName(shape.Columns),
+			Columns:           shape.Columns,
+			Queries:           shape.Queries,
+			BestExistingIndex: bestIndex,
+			ServedColumns:     servedColumns,
+		})
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Queries > recommendations[j].Queries
+	})
+
+	result := make([]IndexRecommendation, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		result = append(result, *recommendation)
+	}
+	return result
+}
+
+// AdvisedIndexName returns the name to use for an advised index over the specified columns
+// of the tuple table.
+func AdvisedIndexName(columns []string) string {
+	name := fmt.Sprintf("ix_advised_tuple_by_%s", strings.Join(columns, "_"))
+	if len(name) <= maxIndexNameLength {
+		return name
+	}
+
+	suffix := fmt.Sprintf("_%016x", xxhash.Sum64([]byte(name)))
+	return name[:maxIndexNameLength-len(suffix)] + suffix
+}
+
+func bestServingIndex(columns []string, indexes []datastore.TupleIndex) (string, int) {
+	bestIndex := ""
+	bestServed := 0
+	for _, index := range indexes {
+		if served := servesColumns(index.Columns, columns); served > bestServed {
+			bestIndex = index.Name
+			bestServed = served
+		}
+	}
+	return bestIndex, bestServed
+}
+
+// servesColumns returns the number of filtered columns which an index with the specified
+// columns can use, which is the length of its leading prefix made up of filtered columns.
+func servesColumns(indexColumns []string, filteredColumns []string) int {
+	served := 0
+	for _, indexColumn := range indexColumns {
+		found := false
+		for _, filteredColumn := range filteredColumns {
+			if indexColumn == filteredColumn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+		served++
+	}
+	return served
+}
+
+// QueryTupleIndexes runs a query which returns rows of index name and indexed column,
+// ordered by index and then by the position of the column within the index, and
+// groups the rows into indexes.
+func QueryTupleIndexes(ctx context.Context, conn *pgxpool.Pool, sql string, args ...interface{}) ([]datastore.TupleIndex, error) {
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query tuple indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []datastore.TupleIndex
+	for rows.Next() {
+		var name, column string
+		if err := rows.Scan(&name, &column); err != nil {
+			return nil, fmt.Errorf("unable to query tuple indexes: %w", err)
+		}
+
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != name {
+			indexes = append(indexes, datastore.TupleIndex{Name: name})
+		}
+		indexes[len(indexes)-1].Columns = append(indexes[len(indexes)-1].Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to query tuple indexes: %w", err)
+	}
+
+	return indexes, nil
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+const testMetrics = `# HELP spicedb_datastore_tuple_query_filter_shapes_total total number of tuple queries issued
+# TYPE spicedb_datastore_tuple_query_filter_shapes_total counter
+spicedb_datastore_tuple_query_filter_shapes_total{columns="namespace,object_id,relation"} 100
+spicedb_datastore_tuple_query_filter_shapes_total{columns="namespace,relation"} 20
+spicedb_datastore_tuple_query_filter_shapes_total{columns="userset_namespace,userset_object_id"} 50
+spicedb_datastore_tuple_query_filter_shapes_total{columns="userset_namespace"} 5
+spicedb_datastore_other_total{columns="namespace"} 1000
+`
+
+func TestParseObservedFilterShapes(t *testing.T) {
+	require := require.New(t)
+
+	shapes, err := ParseObservedFilterShapes(strings.NewReader(testMetrics + testMetrics))
+	require.NoError(err)
+	require.ElementsMatch([]ObservedFilterShape{
+		{[]string{"namespace", "object_id", "relation"}, 200},
+		{[]string{"namespace", "relation"}, 40},
+		{[]string{"userset_namespace", "userset_object_id"}, 100},
+		{[]string{"userset_namespace"}, 10},
+	}, shapes)
+}
+
+func TestRecommendIndexes(t *testing.T) {
+	require := require.New(t)
+
+	shapes, err := ParseObservedFilterShapes(strings.NewReader(testMetrics))
+	require.NoError(err)
+
+	recommendations := RecommendIndexes(shapes, []datastore.TupleIndex{
+		{Name: "pk_relation_tuple", Columns: []string{"namespace", "object_id", "relation", "userset_namespace"}},
+		{Name: "ix_relation_tuple_by_subject", Columns: []string{"userset_object_id", "userset_relation"}},
+	})
+
+	require.Equal([]IndexRecommendation{
+		{
+			Name:              "ix_advised_tuple_by_userset_namespace_userset_object_id",
+			Columns:           []string{"userset_namespace", "userset_object_id"},
+			Queries:           55,
+			BestExistingIndex: "ix_relation_tuple_by_subject",
+			ServedColumns:     1,
+		},
+		{
+			Name:              "ix_advised_tuple_by_namespace_relation",
+			Columns:           []string{"namespace", "relation"},
+			Queries:           20,
+			BestExistingIndex: "pk_relation_tuple",
+			ServedColumns:     1,
+		},
+	}, recommendations)
+}
+
+func TestAdvisedIndexName(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("ix_advised_tuple_by_namespace", AdvisedIndexName([]string{"namespace"}))
+
+	long := AdvisedIndexName(testSchema.columns())
+	require.Len(long, maxIndexNameLength)
+	require.Equal(long, AdvisedIndexName(testSchema.columns()))
+}
+
+func TestFilterShape(t *testing.T) {
+	require := require.New(t)
+
+	base := NewSchemaQueryFilterer(testSchema, sq.Select("*").From("relation_tuple")).
+		FilterToRelation("viewer").
+		FilterToResourceType("document")
+	require.Equal([]string{"namespace", "relation"}, base.FilterShape())
+
+	withUsersets := base.FilterToUsersets([]*v0.ObjectAndRelation{{Namespace: "user", ObjectId: "tom", Relation: "..."}})
+	require.Equal([]string{"namespace", "relation", "userset_namespace", "userset_object_id", "userset_relation"}, withUsersets.FilterShape())
+	require.Equal([]string{"namespace", "relation"}, base.FilterShape())
+}
@@ -0,0 +1,301 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+)
+
+// maxParallelQueries is the most queries, into which a single query was split, that are
+// executed at the same time.
+const maxParallelQueries = 4
+
+var errClosedIterator = errors.New("unable to iterate: iterator closed")
+
+// streamBufferSize is the most tuples queued by each of the queries into which a query was
+// split before it waits for the merge to consume them.
+const streamBufferSize = 1024
+
+// tupleStream is the stream of tuples found by one of the queries into which a query was
+// split. Tuples are queued as they are loaded, and the query waits once the queue is full
+// until the merge consumes them, or until the query is cancelled.
+//
+// While the queue cannot be bounded without starving the merge (see executeMerged), tuples
+// are instead queued without waiting.
+type tupleStream struct {
+	sync.Mutex
+	tuples []*v0.RelationTuple
+	done   bool
+	err    error
+
+	ctx      context.Context
+	capacity int
+
+	// bounded is closed once the query may wait for room in the queue.
+	bounded <-chan struct{}
+
+	// ready is signalled whenever tuples are queued or the stream finishes.
+	ready chan struct{}
+
+	// space is signalled whenever tuples are consumed.
+	space chan struct{}
+}
+
+func newTupleStream(ctx context.Context, capacity int, bounded <-chan struct{}) *tupleStream {
+	return &tupleStream{
+		ctx:      ctx,
+		capacity: capacity,
+		bounded:  bounded,
+		ready:    make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
+	}
+}
+
+// alwaysBounded is the bounded channel of streams whose queues are always bounded.
+var alwaysBounded = func() <-chan struct{} {
+	closed := make(chan struct{})
+	close(closed)
+	return closed
+}()
+
+// push queues a tuple found by the query, waiting for room in the queue if it is full. The
+// tuple is dropped if the query is cancelled while waiting.
+func (ts *tupleStream) push(tpl *v0.RelationTuple) {
+	for {
+		ts.Lock()
+		if len(ts.tuples) < ts.capacity || !ts.isBounded() {
+			ts.tuples = append(ts.tuples, tpl)
+			ts.Unlock()
+			signal(ts.ready)
+			return
+		}
+		ts.Unlock()
+
+		select {
+		case <-ts.space:
+		case <-ts.ctx.Done():
+			return
+		}
+	}
+}
+
+func (ts *tupleStream) isBounded() bool {
+	select {
+	case <-ts.bounded:
+		return true
+	default:
+		return false
+	}
+}
+
+// finish marks the end of the stream, with the error with which the query failed, if any.
+func (ts *tupleStream) finish(err error) {
+	ts.Lock()
+	ts.done = true
+	ts.err = err
+	ts.Unlock()
+	signal(ts.ready)
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for and returns the next tuple in the stream, or nil at its end, along with the
+// error with which the query failed, if any.
+func (ts *tupleStream) next() (*v0.RelationTuple, error) {
+	for {
+		ts.Lock()
+		if len(ts.tuples) > 0 {
+			tpl := ts.tuples[0]
+			ts.tuples[0] = nil
+			ts.tuples = ts.tuples[1:]
+			ts.Unlock()
+			signal(ts.space)
+			return tpl, nil
+		}
+		if ts.done {
+			err := ts.err
+			ts.Unlock()
+			return nil, err
+		}
+		ts.Unlock()
+
+		<-ts.ready
+	}
+}
+
+// mergingTupleIterator merges the streams of the queries into which a query was split. In
+// the absence of an order, the streams are returned one after the other; otherwise, they are
+// each expected to be in the order, and are merged into it.
+type mergingTupleIterator struct {
+	cancel      context.CancelFunc
+	streams     []*tupleStream
+	order       options.TupleOrder
+	limit       *uint64
+	deduplicate bool
+
+	// heads are the next tuples of each stream when merging them into an order, once they
+	// have all been read.
+	heads []*v0.RelationTuple
+
+	// current is the stream being returned in the absence of an order.
+	current int
+
+	seen     map[string]struct{}
+	last     *v0.RelationTuple
+	returned uint64
+	err      error
+	closed   bool
+}
+
+func newMergingTupleIterator(
+	cancel context.CancelFunc,
+	streams []*tupleStream,
+	order options.TupleOrder,
+	limit *uint64,
+	deduplicate bool,
+) *mergingTupleIterator {
+	mti := &mergingTupleIterator{
+		cancel:      cancel,
+		streams:     streams,
+		order:       order,
+		limit:       limit,
+		deduplicate: deduplicate,
+	}
+	if deduplicate && order == options.Unordered {
+		mti.seen = make(map[string]struct{})
+	}
+	return mti
+}
+
+// Next implements datastore.TupleIterator
+func (mti *mergingTupleIterator) Next() *v0.RelationTuple {
+	if mti.closed {
+		mti.err = errClosedIterator
+		return nil
+	}
+	if mti.err != nil || (mti.limit != nil && mti.returned >= *mti.limit) {
+		return nil
+	}
+
+	for {
+		tpl, err := mti.nextMerged()
+		if err != nil {
+			mti.err = err
+			mti.cancel()
+			return nil
+		}
+		if tpl == nil {
+			return nil
+		}
+
+		if mti.deduplicate && mti.isDuplicate(tpl) {
+			continue
+		}
+
+		mti.returned++
+		if mti.limit != nil && mti.returned >= *mti.limit {
+			// No more tuples are needed from the queries which are still running.
+			mti.cancel()
+		}
+		return tpl
+	}
+}
+
+// nextMerged returns the next tuple of the streams, or nil once they have all ended.
+func (mti *mergingTupleIterator) nextMerged() (*v0.RelationTuple, error) {
+	if mti.order == options.Unordered {
+		for mti.current < len(mti.streams) {
+			tpl, err := mti.streams[mti.current].next()
+			if err != nil || tpl != nil {
+				return tpl, err
+			}
+			mti.current++
+		}
+		return nil, nil
+	}
+
+	if mti.heads == nil {
+		mti.heads = make([]*v0.RelationTuple, len(mti.streams))
+		for index, stream := range mti.streams {
+			tpl, err := stream.next()
+			if err != nil {
+				return nil, err
+			}
+			mti.heads[index] = tpl
+		}
+	}
+
+	first := -1
+	for index, head := range mti.heads {
+		if head != nil && (first < 0 || mti.order.Less(head, mti.heads[first])) {
+			first = index
+		}
+	}
+	if first < 0 {
+		return nil, nil
+	}
+
+	tpl := mti.heads[first]
+	next, err := mti.streams[first].next()
+	if err != nil {
+		return nil, err
+	}
+	mti.heads[first] = next
+	return tpl, nil
+}
+
+// isDuplicate returns whether the tuple has already been returned. Ordered duplicates are
+// adjacent, so only the last tuple need be remembered.
+func (mti *mergingTupleIterator) isDuplicate(tpl *v0.RelationTuple) bool {
+	if mti.seen == nil {
+		duplicate := mti.last != nil && !mti.order.Less(mti.last, tpl) && !mti.order.Less(tpl, mti.last)
+		mti.last = tpl
+		return duplicate
+	}
+
+	key := tupleKey(tpl)
+	if _, ok := mti.seen[key]; ok {
+		return true
+	}
+	mti.seen[key] = struct{}{}
+	return false
+}
+
+func tupleKey(tpl *v0.RelationTuple) string {
+	userset := tpl.User.GetUserset()
+	return strings.Join([]string{
+		tpl.ObjectAndRelation.Namespace,
+		tpl.ObjectAndRelation.ObjectId,
+		tpl.ObjectAndRelation.Relation,
+		userset.Namespace,
+		userset.ObjectId,
+		userset.Relation,
+	}, "\x00")
+}
+
+// Err implements datastore.TupleIterator
+func (mti *mergingTupleIterator) Err() error {
+	return mti.err
+}
+
+// Close implements datastore.TupleIterator, cancelling any queries which are still running.
+func (mti *mergingTupleIterator) Close() {
+	if mti.closed {
+		panic("tuple iterator double closed")
+	}
+
+	mti.cancel()
+	mti.streams = nil
+	mti.heads = nil
+	mti.closed = true
+}
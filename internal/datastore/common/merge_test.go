@@ -0,0 +1,218 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestMergingTupleIterator(t *testing.T) {
+	limit := uint64(3)
+
+	testCases := []struct {
+		name        string
+		streams     [][]string
+		order       options.TupleOrder
+		limit       *uint64
+		deduplicate bool
+		expected    []string
+	}{
+		{
+			"unordered",
+			[][]string{
+				{"document:b#viewer@user:tom", "document:a#viewer@user:tom"},
+				{},
+				{"document:c#viewer@user:fred"},
+			},
+			options.Unordered,
+			nil,
+			false,
+			[]string{"document:b#viewer@user:tom", "document:a#viewer@user:tom", "document:c#viewer@user:fred"},
+		},
+		{
+			"unordered limited",
+			[][]string{
+				{"document:b#viewer@user:tom", "document:a#viewer@user:tom"},
+				{"document:c#viewer@user:fred", "document:d#viewer@user:fred"},
+			},
+			options.Unordered,
+			&limit,
+			false,
+			[]string{"document:b#viewer@user:tom", "document:a#viewer@user:tom", "document:c#viewer@user:fred"},
+		},
+		{
+			"unordered deduplicated",
+			[][]string{
+				{"document:b#viewer@user:tom", "document:a#viewer@user:tom"},
+				{"document:a#viewer@user:tom", "document:c#viewer@user:fred"},
+			},
+			options.Unordered,
+			nil,
+			true,
+			[]string{"document:b#viewer@user:tom", "document:a#viewer@user:tom", "document:c#viewer@user:fred"},
+		},
+		{
+			"by resource",
+			[][]string{
+				{"document:a#viewer@user:tom", "document:c#viewer@user:tom"},
+				{"document:B#viewer@user:fred", "document:b#viewer@user:fred"},
+				{"document:a#viewer@user:fred"},
+			},
+			options.ByResource,
+			nil,
+			false,
+			[]string{
+				"document:B#viewer@user:fred",
+				"document:a#viewer@user:fred",
+				"document:a#viewer@user:tom",
+				"document:b#viewer@user:fred",
+				"document:c#viewer@user:tom",
+			},
+		},
+		{
+			"by subject limited and deduplicated",
+			[][]string{
+				{"document:a#viewer@user:amy", "document:b#viewer@user:fred", "document:a#viewer@user:tom"},
+				{"document:b#viewer@user:fred", "document:c#viewer@user:fred"},
+			},
+			options.BySubject,
+			&limit,
+			true,
+			[]string{"document:a#viewer@user:amy", "document:b#viewer@user:fred", "document:c#viewer@user:fred"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			cancelled := false
+			iter := newMergingTupleIterator(func() { cancelled = true }, streamsOf(tc.streams, nil), tc.order, tc.limit, tc.deduplicate)
+			defer iter.Close()
+
+			var found []string
+			for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+				found = append(found, tuple.String(tpl))
+			}
+			require.NoError(iter.Err())
+			require.Equal(tc.expected, found)
+			require.Equal(tc.limit != nil, cancelled)
+		})
+	}
+}
+
+func TestMergingTupleIteratorError(t *testing.T) {
+	require := require.New(t)
+
+	queryErr := errors.New("query failed")
+	streams := streamsOf([][]string{{"document:a#viewer@user:tom"}, {"document:b#viewer@user:tom"}}, queryErr)
+
+	cancelled := false
+	iter := newMergingTupleIterator(func() { cancelled = true }, streams, options.Unordered, nil, false)
+	defer iter.Close()
+
+	require.Equal("document:a#viewer@user:tom", tuple.String(iter.Next()))
+	require.Equal("document:b#viewer@user:tom", tuple.String(iter.Next()))
+	require.Nil(iter.Next())
+	require.ErrorIs(iter.Err(), queryErr)
+	require.True(cancelled)
+}
+
+func TestTupleStreamWaits(t *testing.T) {
+	require := require.New(t)
+
+	stream := newTupleStream(context.Background(), streamBufferSize, alwaysBounded)
+	go func() {
+		stream.push(tuple.Parse("document:a#viewer@user:tom"))
+		stream.finish(nil)
+	}()
+
+	tpl, err := stream.next()
+	require.NoError(err)
+	require.Equal("document:a#viewer@user:tom", tuple.String(tpl))
+
+	tpl, err = stream.next()
+	require.NoError(err)
+	require.Nil(tpl)
+}
+
+func TestTupleStreamBounded(t *testing.T) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	stream := newTupleStream(ctx, 1, started)
+
+	// The queue is unbounded until the stream is bounded.
+	stream.push(tuple.Parse("document:a#viewer@user:tom"))
+	stream.push(tuple.Parse("document:b#viewer@user:tom"))
+	close(started)
+
+	pushed := make(chan struct{})
+	go func() {
+		stream.push(tuple.Parse("document:c#viewer@user:tom"))
+		close(pushed)
+	}()
+
+	tpl, err := stream.next()
+	require.NoError(err)
+	require.Equal("document:a#viewer@user:tom", tuple.String(tpl))
+
+	// The queue is still full, so the producer keeps waiting.
+	select {
+	case <-pushed:
+		require.Fail("push did not wait for the queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	tpl, err = stream.next()
+	require.NoError(err)
+	require.Equal("document:b#viewer@user:tom", tuple.String(tpl))
+	<-pushed
+
+	// Cancelling the query releases a waiting producer, dropping the tuple.
+	cancelled := make(chan struct{})
+	go func() {
+		stream.push(tuple.Parse("document:d#viewer@user:tom"))
+		close(cancelled)
+	}()
+	cancel()
+	<-cancelled
+
+	stream.finish(nil)
+	tpl, err = stream.next()
+	require.NoError(err)
+	require.Equal("document:c#viewer@user:tom", tuple.String(tpl))
+
+	tpl, err = stream.next()
+	require.NoError(err)
+	require.Nil(tpl)
+}
+
+// streamsOf returns finished streams of the tuples, the last of which fails with the error,
+// if any.
+func streamsOf(tupleStrings [][]string, err error) []*tupleStream {
+	streams := make([]*tupleStream, 0, len(tupleStrings))
+	for index, strs := range tupleStrings {
+		stream := newTupleStream(context.Background(), streamBufferSize, alwaysBounded)
+		for _, str := range strs {
+			stream.push(tuple.Parse(str))
+		}
+
+		if index == len(tupleStrings)-1 {
+			stream.finish(err)
+		} else {
+			stream.finish(nil)
+		}
+		streams = append(streams, stream)
+	}
+	return streams
+}
@@ -1,4 +1,4 @@
-package postgres
+package common
 
 import (
 	"github.com/jackc/pgx/v4/pgxpool"
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+type diagnosedPoolStat interface {
+	poolStat
+	TotalConns() int32
+	IdleConns() int32
+}
+
+// DiagnosePool describes the current use of the pool with the specified name.
+func DiagnosePool(name string, pool *pgxpool.Pool) datastore.PoolDiagnostics {
+	return diagnosePoolStat(name, pool.Stat())
+}
+
+func diagnosePoolStat(name string, stat diagnosedPoolStat) datastore.PoolDiagnostics {
+	diagnostics := datastore.PoolDiagnostics{
+		Name:          name,
+		MaxConns:      stat.MaxConns(),
+		TotalConns:    stat.TotalConns(),
+		AcquiredConns: stat.AcquiredConns(),
+		IdleConns:     stat.IdleConns(),
+	}
+	if diagnostics.MaxConns > 0 {
+		diagnostics.Saturation = float64(diagnostics.AcquiredConns) / float64(diagnostics.MaxConns)
+	}
+	if count := stat.AcquireCount(); count > 0 {
+		diagnostics.AverageAcquireWait = stat.AcquireDuration() / time.Duration(count)
+	}
+	return diagnostics
+}
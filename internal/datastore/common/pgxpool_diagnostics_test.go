@@ -0,0 +1,35 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+type fakeDiagnosedPoolStat struct {
+	fakePoolStat
+	total int32
+	idle  int32
+}
+
+func (s fakeDiagnosedPoolStat) TotalConns() int32 { return s.total }
+func (s fakeDiagnosedPoolStat) IdleConns() int32  { return s.idle }
+
+func TestDiagnosePoolStat(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(datastore.PoolDiagnostics{
+		Name:               "spicedb",
+		MaxConns:           20,
+		TotalConns:         8,
+		AcquiredConns:      5,
+		IdleConns:          3,
+		Saturation:         0.25,
+		AverageAcquireWait: 5 * time.Millisecond,
+	}, diagnosePoolStat("spicedb", fakeDiagnosedPoolStat{fakePoolStat{10, 50 * time.Millisecond, 5, 20}, 8, 3}))
+
+	require.Equal(datastore.PoolDiagnostics{Name: "empty"}, diagnosePoolStat("empty", fakeDiagnosedPoolStat{}))
+}
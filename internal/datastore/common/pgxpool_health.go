@@ -0,0 +1,70 @@
+package common
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+const healthCheckPingTimeout = 5 * time.Second
+
+var brokenConnsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "pool_broken_connections_total",
+	Help:      "total number of idle pooled connections which failed a health check ping and were recycled",
+}, []string{"db_name"})
+
+// PoolHealthChecker periodically pings the idle connections of a pool, closing those which
+// fail so that the pool replaces them before they are handed to a request.
+type PoolHealthChecker struct {
+	pool     *pgxpool.Pool
+	dbName   string
+	interval time.Duration
+}
+
+// NewPoolHealthChecker creates a health checker for the idle connections of a pool.
+func NewPoolHealthChecker(pool *pgxpool.Pool, dbName string, interval time.Duration) *PoolHealthChecker {
+	return &PoolHealthChecker{
+		pool:     pool,
+		dbName:   dbName,
+		interval: interval,
+	}
+}
+
+// Run checks the idle connections of the pool every interval until the context is canceled.
+func (phc *PoolHealthChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(phc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			phc.checkIdleConns(ctx)
+		}
+	}
+}
+
+func (phc *PoolHealthChecker) checkIdleConns(ctx context.Context) {
+	for _, conn := range phc.pool.AcquireAllIdle(ctx) {
+		pingCtx, cancel := context.WithTimeout(ctx, healthCheckPingTimeout)
+		err := conn.Conn().Ping(pingCtx)
+		cancel()
+
+		if err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Str("db", phc.dbName).Msg("recycling pooled connection which failed a health check")
+			brokenConnsCounter.WithLabelValues(phc.dbName).Inc()
+
+			// Closed connections are destroyed rather than returned to the pool on release.
+			_ = conn.Conn().Close(ctx)
+		}
+
+		conn.Release()
+	}
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// poolWaitSampleInterval is the interval over which the acquisition wait of pools is
+// averaged.
+const poolWaitSampleInterval = 1 * time.Second
+
+var trackedPoolWaits = struct {
+	sync.Mutex
+	waits map[*PoolWaitTracker]time.Duration
+}{waits: make(map[*PoolWaitTracker]time.Duration)}
+
+// PoolAcquireWait returns the longest of the average amounts of time for which the
+// acquisitions of connections from the pools of running PoolWaitTrackers recently waited.
+func PoolAcquireWait() time.Duration {
+	trackedPoolWaits.Lock()
+	defer trackedPoolWaits.Unlock()
+
+	var longest time.Duration
+	for _, wait := range trackedPoolWaits.waits {
+		if wait > longest {
+			longest = wait
+		}
+	}
+	return longest
+}
+
+// PoolWaitTracker periodically samples the average amount of time for which the
+// acquisitions of connections from a pool waited, reporting it in PoolAcquireWait so that
+// the server can shed load before requests time out waiting for connections.
+type PoolWaitTracker struct {
+	pool *pgxpool.Pool
+
+	count    int64
+	duration time.Duration
+	average  time.Duration
+}
+
+// NewPoolWaitTracker creates a tracker of the acquisition wait of a pool.
+func NewPoolWaitTracker(pool *pgxpool.Pool) *PoolWaitTracker {
+	return &PoolWaitTracker{pool: pool}
+}
+
+// Run samples the acquisition wait of the pool until the context is canceled.
+func (pwt *PoolWaitTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(poolWaitSampleInterval)
+	defer ticker.Stop()
+
+	stat := pwt.pool.Stat()
+	pwt.count, pwt.duration = stat.AcquireCount(), stat.AcquireDuration()
+
+	defer func() {
+		trackedPoolWaits.Lock()
+		delete(trackedPoolWaits.waits, pwt)
+		trackedPoolWaits.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			average := pwt.sample(pwt.pool.Stat())
+
+			trackedPoolWaits.Lock()
+			trackedPoolWaits.waits[pwt] = average
+			trackedPoolWaits.Unlock()
+		}
+	}
+}
+
+type poolStat interface {
+	AcquireCount() int64
+	AcquireDuration() time.Duration
+	AcquiredConns() int32
+	MaxConns() int32
+}
+
+// sample returns the average acquisition wait since the previous sample. The duration of
+// an acquisition is only counted once it completes, so if none completed while every
+// connection was held, the pool is assumed to be as saturated as when last sampled.
+func (pwt *PoolWaitTracker) sample(stat poolStat) time.Duration {
+	count, duration := stat.AcquireCount(), stat.AcquireDuration()
+	acquired := count - pwt.count
+
+	switch {
+	case acquired > 0:
+		pwt.average = (duration - pwt.duration) / time.Duration(acquired)
+	case stat.AcquiredConns() < stat.MaxConns():
+		pwt.average = 0
+	}
+
+	pwt.count, pwt.duration = count, duration
+	return pwt.average
+}
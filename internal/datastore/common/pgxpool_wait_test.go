@@ -0,0 +1,35 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakePoolStat struct {
+	count    int64
+	duration time.Duration
+	acquired int32
+	max      int32
+}
+
+func (s fakePoolStat) AcquireCount() int64            { return s.count }
+func (s fakePoolStat) AcquireDuration() time.Duration { return s.duration }
+func (s fakePoolStat) AcquiredConns() int32           { return s.acquired }
+func (s fakePoolStat) MaxConns() int32                { return s.max }
+
+func TestPoolWaitTrackerSample(t *testing.T) {
+	require := require.New(t)
+
+	tracker := &PoolWaitTracker{}
+
+	require.Equal(5*time.Millisecond, tracker.sample(fakePoolStat{10, 50 * time.Millisecond, 1, 10}))
+	require.Equal(100*time.Millisecond, tracker.sample(fakePoolStat{12, 250 * time.Millisecond, 10, 10}))
+
+	// No acquisition completed while the pool was exhausted.
+	require.Equal(100*time.Millisecond, tracker.sample(fakePoolStat{12, 250 * time.Millisecond, 10, 10}))
+
+	// No acquisition was made while the pool had free connections.
+	require.Equal(time.Duration(0), tracker.sample(fakePoolStat{12, 250 * time.Millisecond, 2, 10}))
+}
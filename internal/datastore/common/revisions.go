@@ -0,0 +1,82 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+// DefaultRevisionValidityCacheDuration is the default amount of time for which a revision
+// found to be valid is assumed to remain so, without being checked again.
+const DefaultRevisionValidityCacheDuration = 1 * time.Second
+
+// maxCachedRevisions is the number of valid revisions remembered by a RevisionValidator,
+// beyond which expired revisions are forgotten.
+const maxCachedRevisions = 1024
+
+// RevisionChecker checks that a revision can be read, returning a datastore.ErrInvalidRevision
+// if it cannot.
+type RevisionChecker func(ctx context.Context, revision datastore.Revision) error
+
+// RevisionValidator checks that revisions can be read before queries are executed at them, so
+// that queries at revisions which have been garbage collected, or do not yet exist, fail with
+// an invalid revision error rather than returning incomplete results or an opaque error.
+// Revisions found to be valid are remembered for a time, so that the many queries executed
+// at the same revision check it only once.
+type RevisionValidator struct {
+	check    RevisionChecker
+	validFor time.Duration
+
+	sync.Mutex
+	validUntil map[string]time.Time
+}
+
+// NewRevisionValidator creates a RevisionValidator which checks revisions with the
+// datastore's checker, and assumes they remain valid for the specified duration.
+func NewRevisionValidator(check RevisionChecker, validFor time.Duration) *RevisionValidator {
+	return &RevisionValidator{
+		check:      check,
+		validFor:   validFor,
+		validUntil: make(map[string]time.Time),
+	}
+}
+
+// Validate returns an error if the revision cannot be read. A nil validator considers every
+// revision valid.
+func (rv *RevisionValidator) Validate(ctx context.Context, revision datastore.Revision) error {
+	if rv == nil {
+		return nil
+	}
+
+	key := revision.String()
+	now := time.Now()
+
+	rv.Lock()
+	validUntil, ok := rv.validUntil[key]
+	rv.Unlock()
+	if ok && now.Before(validUntil) {
+		return nil
+	}
+
+	if err := rv.check(ctx, revision); err != nil {
+		return err
+	}
+
+	rv.Lock()
+	defer rv.Unlock()
+
+	if len(rv.validUntil) >= maxCachedRevisions {
+		for cached, until := range rv.validUntil {
+			if !now.Before(until) {
+				delete(rv.validUntil, cached)
+			}
+		}
+		if len(rv.validUntil) >= maxCachedRevisions {
+			rv.validUntil = make(map[string]time.Time)
+		}
+	}
+	rv.validUntil[key] = now.Add(rv.validFor)
+	return nil
+}
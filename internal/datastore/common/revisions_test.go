@@ -0,0 +1,53 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+func TestRevisionValidator(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	oldest, newest := decimal.NewFromInt(10), decimal.NewFromInt(20)
+	checks := 0
+	validator := NewRevisionValidator(func(ctx context.Context, revision datastore.Revision) error {
+		checks++
+		return datastore.CheckRevisionInWindow(revision, oldest, newest)
+	}, time.Hour)
+
+	// Valid revisions are only checked once.
+	require.NoError(validator.Validate(ctx, decimal.NewFromInt(15)))
+	require.NoError(validator.Validate(ctx, decimal.NewFromInt(15)))
+	require.Equal(1, checks)
+
+	// Invalid revisions are checked every time, and report the window.
+	for _, revision := range []int64{5, 5, 25} {
+		err := validator.Validate(ctx, decimal.NewFromInt(revision))
+
+		var revisionErr datastore.ErrInvalidRevision
+		require.True(errors.As(err, &revisionErr))
+
+		foundOldest, foundNewest, ok := revisionErr.ValidWindow()
+		require.True(ok)
+		require.True(oldest.Equal(foundOldest))
+		require.True(newest.Equal(foundNewest))
+	}
+	require.Equal(4, checks)
+
+	var revisionErr datastore.ErrInvalidRevision
+	require.True(errors.As(validator.Validate(ctx, decimal.NewFromInt(25)), &revisionErr))
+	require.Equal(datastore.RevisionInFuture, revisionErr.Reason())
+	require.True(errors.As(validator.Validate(ctx, decimal.NewFromInt(5)), &revisionErr))
+	require.Equal(datastore.RevisionStale, revisionErr.Reason())
+
+	var disabled *RevisionValidator
+	require.NoError(disabled.Validate(ctx, decimal.NewFromInt(5)))
+}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var clockSkewGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "clock_skew_seconds",
+	Help:      "most recently measured difference in seconds between the time of the datastore and the wall clock of the server; positive when the datastore is ahead",
+}, []string{"engine"})
+
+// ClockSkew tracks the difference between the time of a datastore, as used for its revisions,
+// and the wall clock of the server. Skew beyond the threshold is logged when first measured,
+// so that hosts whose clocks are not kept in sync with the datastore are noticed.
+type ClockSkew struct {
+	engine    string
+	threshold time.Duration
+
+	sync.RWMutex
+	skew     time.Duration
+	exceeded bool
+}
+
+// NewClockSkew creates a ClockSkew for the datastore engine, which considers skew beyond the
+// threshold excessive. A threshold of zero disables the detection of excessive skew.
+func NewClockSkew(engine string, threshold time.Duration) *ClockSkew {
+	return &ClockSkew{engine: engine, threshold: threshold}
+}
+
+// Observe records a reading of the time of the datastore, made by a request which was sent and
+// whose response was received at the specified times of the server's wall clock. The reading
+// is assumed to have been made halfway through the round trip.
+func (cs *ClockSkew) Observe(ctx context.Context, datastoreNow, sentAt, receivedAt time.Time) {
+	localNow := sentAt.Add(receivedAt.Sub(sentAt) / 2)
+	skew := datastoreNow.Sub(localNow)
+	clockSkewGauge.WithLabelValues(cs.engine).Set(skew.Seconds())
+
+	exceeded := cs.threshold > 0 && (skew > cs.threshold || skew < -cs.threshold)
+
+	cs.Lock()
+	wasExceeded := cs.exceeded
+	cs.skew = skew
+	cs.exceeded = exceeded
+	cs.Unlock()
+
+	switch {
+	case exceeded && !wasExceeded:
+		log.Ctx(ctx).Warn().
+			Dur("skew", skew).
+			Dur("threshold", cs.threshold).
+			Str("engine", cs.engine).
+			Msg("datastore clock is skewed from the wall clock of the server beyond the threshold; check time synchronization")
+	case !exceeded && wasExceeded:
+		log.Ctx(ctx).Info().
+			Dur("skew", skew).
+			Str("engine", cs.engine).
+			Msg("datastore clock skew is back within the threshold")
+	}
+}
+
+// Skew returns the most recently measured skew, which is positive when the datastore is ahead
+// of the server.
+func (cs *ClockSkew) Skew() time.Duration {
+	cs.RLock()
+	defer cs.RUnlock()
+	return cs.skew
+}
+
+// Exceeded returns whether the most recently measured skew was beyond the threshold, in which
+// case the wall clock of the server should not be relied upon to judge the age of revisions.
+func (cs *ClockSkew) Exceeded() bool {
+	cs.RLock()
+	defer cs.RUnlock()
+	return cs.exceeded
+}
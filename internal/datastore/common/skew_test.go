@@ -0,0 +1,39 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkew(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	sentAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	receivedAt := sentAt.Add(200 * time.Millisecond)
+
+	skew := NewClockSkew("test", time.Second)
+	require.Zero(skew.Skew())
+	require.False(skew.Exceeded())
+
+	// The reading is assumed to have been made halfway through the round trip.
+	skew.Observe(ctx, sentAt.Add(100*time.Millisecond), sentAt, receivedAt)
+	require.Zero(skew.Skew())
+	require.False(skew.Exceeded())
+
+	skew.Observe(ctx, sentAt.Add(-2*time.Second), sentAt, receivedAt)
+	require.Equal(-2100*time.Millisecond, skew.Skew())
+	require.True(skew.Exceeded())
+
+	skew.Observe(ctx, receivedAt.Add(500*time.Millisecond), sentAt, receivedAt)
+	require.Equal(600*time.Millisecond, skew.Skew())
+	require.False(skew.Exceeded())
+
+	disabled := NewClockSkew("test", 0)
+	disabled.Observe(ctx, sentAt.Add(time.Hour), sentAt, receivedAt)
+	require.Equal(time.Hour-100*time.Millisecond, disabled.Skew())
+	require.False(disabled.Exceeded())
+}
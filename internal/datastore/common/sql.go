@@ -2,8 +2,14 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/alecthomas/units"
@@ -12,14 +18,21 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jzelinskie/stringz"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/redact"
 )
 
 const (
 	errUnableToQueryTuples = "unable to query tuples: %w"
+
+	explainTimeout = 5 * time.Second
 )
 
 var (
@@ -46,18 +59,81 @@ var (
 	// ID.
 	SubObjectIDKey = attribute.Key("authzed.com/spicedb/sql/subObjectId")
 
+	// TenantKey is a tracing attribute representing the tenant to which the query is
+	// scoped.
+	TenantKey = attribute.Key("authzed.com/spicedb/sql/tenant")
+
 	limitKey = attribute.Key("authzed.com/spicedb/sql/limit")
+	hintKey  = attribute.Key("authzed.com/spicedb/sql/hint")
+
+	subjectIDCountKey = attribute.Key("authzed.com/spicedb/sql/subjectIdCount")
 )
 
+// FilterShapeMetricName is the fully qualified name of the metric counting tuple queries
+// by the set of columns on which they filter.
+const FilterShapeMetricName = "spicedb_datastore_tuple_query_filter_shapes_total"
+
+var filterShapeCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "tuple_query_filter_shapes_total",
+	Help:      "total number of tuple queries issued, by the comma-separated columns on which they filter",
+}, []string{"columns"})
+
+var operationLatencyHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "operation_duration_seconds",
+	Help:      "distribution in seconds of the latency of datastore operations, by the number of queries into which they were split",
+	Buckets:   []float64{.001, .003, .006, .01, .02, .05, .1, .25, .5, 1, 2.5, 5, 10},
+}, []string{"operation", "engine", "split_count"})
+
+var rowsScannedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "rows_scanned_total",
+	Help:      "total number of tuple rows scanned from query results",
+}, []string{"operation", "engine"})
+
+// ObserveOperationLatency records the latency of a datastore operation which started at the
+// specified time and was executed as splitCount queries.
+func ObserveOperationLatency(operation, engine string, splitCount int, start time.Time) {
+	operationLatencyHistogram.
+		WithLabelValues(operation, engine, strconv.Itoa(splitCount)).
+		Observe(time.Since(start).Seconds())
+}
+
 // DefaultSplitAtEstimatedQuerySize is the default allowed estimated query size before the
 // TupleQuerySplitter will split the query into multiple calls.
 //
 // In Postgres, it appears to be 1GB: https://dba.stackexchange.com/questions/131399/is-there-a-maximum-length-constraint-for-a-postgres-query
 // In CockroachDB, the maximum is 16MiB: https://www.cockroachlabs.com/docs/stable/known-limitations.html#size-limits-on-statement-input-from-sql-clients
-// As a result, we go with half of that to be on the safe side, since the estimate doesn't include
-// the field names or operators.
+// As a result, we go with half of that to be on the safe side.
 const DefaultSplitAtEstimatedQuerySize = 8 * units.MiB
 
+// DefaultSplitAtParameterCount is the default number of parameters bound to a query before
+// the TupleQuerySplitter will split the query into multiple calls, which is the most the
+// wire protocol allows.
+const DefaultSplitAtParameterCount = MaxStatementParameters
+
+// estimatedClauseOverhead is the estimated size of the text of a clause comparing a column to
+// a bound parameter, apart from the name of the column: the operator, the largest placeholder
+// and the conjunction joining it to the next clause, as in ` = $65535 AND `.
+const estimatedClauseOverhead = len(" = $65535 AND ")
+
+// estimatedNonStringParameterSize is the estimated size of a bound parameter which is not a
+// string, such as a transaction ID.
+const estimatedNonStringParameterSize = 8
+
+// parametersPerUserset is the number of parameters bound to a query for each userset to which
+// it is filtered.
+const parametersPerUserset = 3
+
+// estimatedSubjectIDOverhead is the estimated size of the text of a subject ID in the list to
+// which a query is filtered, apart from its bound value: the largest placeholder and the comma
+// separating it from the next subject ID.
+const estimatedSubjectIDOverhead = len("$65535,")
+
 // SchemaInformation holds the schema information from the SQL datastore implementation.
 type SchemaInformation struct {
 	TableTuple          string
@@ -67,15 +143,98 @@ type SchemaInformation struct {
 	ColUsersetNamespace string
 	ColUsersetObjectID  string
 	ColUsersetRelation  string
+
+	// ColTenant is the column holding the tenant of each tuple, or empty if the
+	// datastore does not isolate tenants.
+	ColTenant string
+
+	// OrderCollation is the collation with which columns are compared when queries are
+	// ordered, so that they are ordered bytewise, as options.TupleOrder orders tuples. If
+	// empty, the columns are compared with their own collation, which must be bytewise.
+	OrderCollation string
+}
+
+// estimatedUsersetSize returns the estimated size of the clause filtering a query to the
+// userset, including its bound values.
+func (si SchemaInformation) estimatedUsersetSize(userset *v0.ObjectAndRelation) int {
+	return len(si.ColUsersetNamespace) + len(si.ColUsersetObjectID) + len(si.ColUsersetRelation) +
+		parametersPerUserset*estimatedClauseOverhead +
+		len("() OR ") +
+		len(userset.Namespace) + len(userset.ObjectId) + len(userset.Relation)
+}
+
+// estimatedSubjectIDsClauseSize returns the estimated size of the clause filtering a query to
+// a list of subject IDs, apart from the subject IDs themselves.
+func (si SchemaInformation) estimatedSubjectIDsClauseSize() int {
+	return len(si.ColUsersetObjectID) + len(" IN () AND ")
+}
+
+// orderedColumn returns the column compared with the collation in which queries are ordered.
+func (si SchemaInformation) orderedColumn(column string) string {
+	if si.OrderCollation == "" {
+		return column
+	}
+	return fmt.Sprintf(`%s COLLATE "%s"`, column, si.OrderCollation)
+}
+
+func (si SchemaInformation) columns() []string {
+	return []string{
+		si.ColNamespace,
+		si.ColObjectID,
+		si.ColRelation,
+		si.ColUsersetNamespace,
+		si.ColUsersetObjectID,
+		si.ColUsersetRelation,
+	}
+}
+
+// QueryShape identifies a kind of generated query, for the purposes of applying
+// operator-supplied planner hints.
+type QueryShape string
+
+const (
+	// QueryShapeQueryTuples is the shape of queries which start from the resource side.
+	QueryShapeQueryTuples QueryShape = "query-tuples"
+
+	// QueryShapeReverseQueryTuples is the shape of queries which start from the subject side.
+	QueryShapeReverseQueryTuples QueryShape = "reverse-query-tuples"
+)
+
+// QueryHints maps a query shape to the planner hint to apply to queries of that shape.
+type QueryHints map[QueryShape]string
+
+// ParseQueryHints converts a map of query shape names to hints into QueryHints,
+// returning an error for any unknown shape.
+func ParseQueryHints(hints map[string]string) (QueryHints, error) {
+	parsed := make(QueryHints, len(hints))
+	for shapeName, hint := range hints {
+		shape := QueryShape(shapeName)
+		switch shape {
+		case QueryShapeQueryTuples, QueryShapeReverseQueryTuples:
+			parsed[shape] = hint
+		default:
+			return nil, fmt.Errorf("unknown query shape for hint: %s", shapeName)
+		}
+	}
+	return parsed, nil
+}
+
+// QueryHinter applies a driver-specific planner hint to a query.
+type QueryHinter func(query sq.SelectBuilder, hint string) sq.SelectBuilder
+
+// CommentQueryHinter applies hints as a leading hint comment, in the format
+// understood by pg_hint_plan, e.g. `/*+ IndexScan(relation_tuple ix_name) */`.
+func CommentQueryHinter(query sq.SelectBuilder, hint string) sq.SelectBuilder {
+	return query.Prefix(fmt.Sprintf("/*+ %s */", hint))
 }
 
 // SchemaQueryFilterer wraps a SchemaInformation and SelectBuilder to give an opinionated
 // way to build query objects.
 type SchemaQueryFilterer struct {
-	schema               SchemaInformation
-	queryBuilder         sq.SelectBuilder
-	currentEstimatedSize int
-	tracerAttributes     []attribute.KeyValue
+	schema           SchemaInformation
+	queryBuilder     sq.SelectBuilder
+	tracerAttributes []attribute.KeyValue
+	filteredColumns  []string
 }
 
 // NewSchemaQueryFilterer creates a new SchemaQueryFilterer object.
@@ -86,12 +245,21 @@ func NewSchemaQueryFilterer(schema SchemaInformation, initialQuery sq.SelectBuil
 	}
 }
 
+// FilterToTenant returns a new SchemaQueryFilterer that is limited to tuples of the specified
+// tenant. Every tuple query is filtered by tenant in datastores which isolate tenants, so the
+// tenant is not considered part of the shape of the query.
+func (sqf SchemaQueryFilterer) FilterToTenant(tenant string) SchemaQueryFilterer {
+	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColTenant: tenant})
+	sqf.tracerAttributes = append(sqf.tracerAttributes, TenantKey.String(redact.Tenant(tenant)))
+	return sqf
+}
+
 // FilterToResourceType returns a new SchemaQueryFilterer that is limited to resources of the
 // specified type.
 func (sqf SchemaQueryFilterer) FilterToResourceType(resourceType string) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColNamespace: resourceType})
-	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjNamespaceNameKey.String(resourceType))
-	sqf.currentEstimatedSize += len(resourceType)
+	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjNamespaceNameKey.String(redact.Type(resourceType)))
+	sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColNamespace)
 	return sqf
 }
 
@@ -99,17 +267,37 @@ func (sqf SchemaQueryFilterer) FilterToResourceType(resourceType string) SchemaQ
 // specified ID.
 func (sqf SchemaQueryFilterer) FilterToResourceID(objectID string) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColObjectID: objectID})
-	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjIDKey.String(objectID))
-	sqf.currentEstimatedSize += len(objectID)
+	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjIDKey.String(redact.ID(objectID)))
+	sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColObjectID)
+	return sqf
+}
+
+// FilterToResourceIDPrefix returns a new SchemaQueryFilterer that is limited to resources
+// with IDs starting with the specified prefix.
+func (sqf SchemaQueryFilterer) FilterToResourceIDPrefix(prefix string) SchemaQueryFilterer {
+	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Like{sqf.schema.ColObjectID: likeEscaper.Replace(prefix) + "%"})
+	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjIDKey.String(redact.ID(prefix)+"*"))
+	sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColObjectID)
+	return sqf
+}
+
+// FilterToResourceIDsAfter returns a new SchemaQueryFilterer that is limited to resources
+// with IDs which sort after the specified ID, compared as OrderBy orders them.
+func (sqf SchemaQueryFilterer) FilterToResourceIDsAfter(objectID string) SchemaQueryFilterer {
+	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Gt{sqf.schema.orderedColumn(sqf.schema.ColObjectID): objectID})
+	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjIDKey.String(">"+redact.ID(objectID)))
 	return sqf
 }
 
+// likeEscaper escapes the characters which have special meaning in LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // FilterToRelation returns a new SchemaQueryFilterer that is limited to resources with the
 // specified relation.
 func (sqf SchemaQueryFilterer) FilterToRelation(relation string) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColRelation: relation})
-	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjRelationNameKey.String(relation))
-	sqf.currentEstimatedSize += len(relation)
+	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjRelationNameKey.String(redact.Relation(relation)))
+	sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColRelation)
 	return sqf
 }
 
@@ -117,26 +305,95 @@ func (sqf SchemaQueryFilterer) FilterToRelation(relation string) SchemaQueryFilt
 // subjects that match the specified filter.
 func (sqf SchemaQueryFilterer) FilterToSubjectFilter(filter *v1.SubjectFilter) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColUsersetNamespace: filter.SubjectType})
-	sqf.tracerAttributes = append(sqf.tracerAttributes, SubNamespaceNameKey.String(filter.SubjectType))
+	sqf.tracerAttributes = append(sqf.tracerAttributes, SubNamespaceNameKey.String(redact.Type(filter.SubjectType)))
+	sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColUsersetNamespace)
 
 	if filter.OptionalSubjectId != "" {
 		sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColUsersetObjectID: filter.OptionalSubjectId})
-		sqf.tracerAttributes = append(sqf.tracerAttributes, SubObjectIDKey.String(filter.OptionalSubjectId))
+		sqf.tracerAttributes = append(sqf.tracerAttributes, SubObjectIDKey.String(redact.ID(filter.OptionalSubjectId)))
+		sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColUsersetObjectID)
 	}
 
-	sqf.currentEstimatedSize += len(filter.SubjectType) + len(filter.OptionalSubjectId)
-
 	if filter.OptionalRelation != nil {
 		dsRelationName := stringz.DefaultEmpty(filter.OptionalRelation.Relation, datastore.Ellipsis)
 
 		sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColUsersetRelation: dsRelationName})
-		sqf.tracerAttributes = append(sqf.tracerAttributes, SubRelationNameKey.String(dsRelationName))
-		sqf.currentEstimatedSize += len(dsRelationName)
+		sqf.tracerAttributes = append(sqf.tracerAttributes, SubRelationNameKey.String(redact.Relation(dsRelationName)))
+		sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColUsersetRelation)
+	}
+
+	return sqf
+}
+
+// FilterToRelationshipFilter returns a new SchemaQueryFilterer that is limited to the
+// relationships matching the specified filter. An optional resource ID ending in
+// datastore.ResourceIDPrefixWildcard is matched as a prefix.
+func (sqf SchemaQueryFilterer) FilterToRelationshipFilter(filter *v1.RelationshipFilter) SchemaQueryFilterer {
+	sqf = sqf.FilterToResourceType(filter.ResourceType)
+
+	if prefix, ok := datastore.ResourceIDPrefix(filter); ok {
+		sqf = sqf.FilterToResourceIDPrefix(prefix)
+	} else if filter.OptionalResourceId != "" {
+		sqf = sqf.FilterToResourceID(filter.OptionalResourceId)
+	}
+
+	if filter.OptionalRelation != "" {
+		sqf = sqf.FilterToRelation(filter.OptionalRelation)
+	}
+
+	if filter.OptionalSubjectFilter != nil {
+		sqf = sqf.FilterToSubjectFilter(filter.OptionalSubjectFilter)
 	}
 
 	return sqf
 }
 
+// FilterToAnyRelationshipFilter returns a new SchemaQueryFilterer that is limited to the
+// relationships matching any of the specified filters, whose optional resource IDs are
+// matched exactly.
+func (sqf SchemaQueryFilterer) FilterToAnyRelationshipFilter(filters []*v1.RelationshipFilter) SchemaQueryFilterer {
+	if len(filters) == 0 {
+		panic("Got empty relationship filters")
+	}
+
+	orClause := sq.Or{}
+	for _, filter := range filters {
+		clause := sq.Eq{sqf.schema.ColNamespace: filter.ResourceType}
+		sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColNamespace)
+
+		if filter.OptionalResourceId != "" {
+			clause[sqf.schema.ColObjectID] = filter.OptionalResourceId
+			sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColObjectID)
+		}
+
+		if filter.OptionalRelation != "" {
+			clause[sqf.schema.ColRelation] = filter.OptionalRelation
+			sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColRelation)
+		}
+
+		if subjectFilter := filter.OptionalSubjectFilter; subjectFilter != nil {
+			clause[sqf.schema.ColUsersetNamespace] = subjectFilter.SubjectType
+			sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColUsersetNamespace)
+
+			if subjectFilter.OptionalSubjectId != "" {
+				clause[sqf.schema.ColUsersetObjectID] = subjectFilter.OptionalSubjectId
+				sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColUsersetObjectID)
+			}
+
+			if subjectFilter.OptionalRelation != nil {
+				dsRelationName := stringz.DefaultEmpty(subjectFilter.OptionalRelation.Relation, datastore.Ellipsis)
+				clause[sqf.schema.ColUsersetRelation] = dsRelationName
+				sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColUsersetRelation)
+			}
+		}
+
+		orClause = append(orClause, clause)
+	}
+
+	sqf.queryBuilder = sqf.queryBuilder.Where(orClause)
+	return sqf
+}
+
 // FilterToUsersets returns a new SchemaQueryFilterer that is limited to resources with subjects
 // in the specified list of usersets.
 func (sqf SchemaQueryFilterer) FilterToUsersets(usersets []*v0.ObjectAndRelation) SchemaQueryFilterer {
@@ -151,11 +408,92 @@ func (sqf SchemaQueryFilterer) FilterToUsersets(usersets []*v0.ObjectAndRelation
 			sqf.schema.ColUsersetObjectID:  userset.ObjectId,
 			sqf.schema.ColUsersetRelation:  userset.Relation,
 		})
-		sqf.currentEstimatedSize += len(userset.Namespace) + len(userset.ObjectId) + len(userset.Relation)
 	}
 
 	sqf.queryBuilder = sqf.queryBuilder.Where(orClause)
+	sqf.filteredColumns = sqf.withFilteredColumns(
+		sqf.schema.ColUsersetNamespace,
+		sqf.schema.ColUsersetObjectID,
+		sqf.schema.ColUsersetRelation,
+	)
+
+	return sqf
+}
+
+// FilterToSubjectIDs returns a new SchemaQueryFilterer that is limited to resources with
+// subjects having any of the specified IDs.
+func (sqf SchemaQueryFilterer) FilterToSubjectIDs(subjectIDs []string) SchemaQueryFilterer {
+	if len(subjectIDs) == 0 {
+		panic("Got empty subject IDs filter")
+	}
+
+	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColUsersetObjectID: subjectIDs})
+	sqf.tracerAttributes = append(sqf.tracerAttributes, subjectIDCountKey.Int(len(subjectIDs)))
+	sqf.filteredColumns = sqf.withFilteredColumns(sqf.schema.ColUsersetObjectID)
+	return sqf
+}
+
+// withFilteredColumns returns a copy of the filtered columns with the specified columns
+// added, so that filterers derived from the same parent do not share them.
+func (sqf SchemaQueryFilterer) withFilteredColumns(columns ...string) []string {
+	filtered := make([]string, 0, len(sqf.filteredColumns)+len(columns))
+	filtered = append(filtered, sqf.filteredColumns...)
+	return append(filtered, columns...)
+}
 
+// FilterShape returns the distinct columns on which the query filters, in schema order.
+func (sqf SchemaQueryFilterer) FilterShape() []string {
+	var shape []string
+	for _, column := range sqf.schema.columns() {
+		if stringz.SliceIndex(sqf.filteredColumns, column) >= 0 {
+			shape = append(shape, column)
+		}
+	}
+	return shape
+}
+
+// WithHint returns a new SchemaQueryFilterer which has the hint configured for the
+// specified query shape, if any, applied by the driver's hinter.
+func (sqf SchemaQueryFilterer) WithHint(shape QueryShape, hints QueryHints, hinter QueryHinter) SchemaQueryFilterer {
+	hint, ok := hints[shape]
+	if !ok || hint == "" {
+		return sqf
+	}
+
+	sqf.queryBuilder = hinter(sqf.queryBuilder, hint)
+	sqf.tracerAttributes = append(sqf.tracerAttributes, hintKey.String(hint))
+	return sqf
+}
+
+// FilterToRevision returns a new SchemaQueryFilterer that is limited to the tuples which were
+// live at the revision, according to the datastore's revision filter.
+func (sqf SchemaQueryFilterer) FilterToRevision(filter RevisionFilter, revision datastore.Revision) SchemaQueryFilterer {
+	if filter.FilterQuery != nil {
+		sqf.queryBuilder = filter.FilterQuery(sqf.queryBuilder, revision)
+	}
+	return sqf
+}
+
+// OrderBy returns a new SchemaQueryFilterer whose results are returned in the specified order.
+func (sqf SchemaQueryFilterer) OrderBy(order options.TupleOrder) SchemaQueryFilterer {
+	resourceColumns := []string{sqf.schema.ColNamespace, sqf.schema.ColObjectID, sqf.schema.ColRelation}
+	subjectColumns := []string{sqf.schema.ColUsersetNamespace, sqf.schema.ColUsersetObjectID, sqf.schema.ColUsersetRelation}
+
+	var columns []string
+	switch order {
+	case options.ByResource:
+		columns = append(resourceColumns, subjectColumns...)
+	case options.BySubject:
+		columns = append(subjectColumns, resourceColumns...)
+	default:
+		return sqf
+	}
+
+	for i, column := range columns {
+		columns[i] = sqf.schema.orderedColumn(column)
+	}
+
+	sqf.queryBuilder = sqf.queryBuilder.OrderBy(columns...)
 	return sqf
 }
 
@@ -166,116 +504,347 @@ func (sqf SchemaQueryFilterer) Limit(limit uint64) SchemaQueryFilterer {
 	return sqf
 }
 
+// ToSql builds the query, returning its SQL and arguments.
+func (sqf SchemaQueryFilterer) ToSql() (string, []interface{}, error) {
+	return sqf.queryBuilder.ToSql()
+}
+
+// estimatedSize returns the estimated size of the query, including its bound values, and the
+// number of parameters bound to it.
+func (sqf SchemaQueryFilterer) estimatedSize() (size int, parameterCount int, err error) {
+	sql, args, err := sqf.queryBuilder.ToSql()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size = len(sql)
+	for _, arg := range args {
+		if str, ok := arg.(string); ok {
+			size += len(str)
+		} else {
+			size += estimatedNonStringParameterSize
+		}
+	}
+	return size, len(args), nil
+}
+
+// SlowQueryLog configures the logging of tuple queries which run for longer than a threshold.
+type SlowQueryLog struct {
+	// Threshold is the duration at or above which a query is logged. Zero disables logging.
+	Threshold time.Duration
+
+	// ExplainSampleRate is the fraction, between 0 and 1, of logged queries for which the
+	// query plan is captured with EXPLAIN and included in the log.
+	ExplainSampleRate float64
+}
+
 // TransactionPreparer is a function provided by the datastore to prepare the transaction before
 // the tuple query is run.
 type TransactionPreparer func(ctx context.Context, tx pgx.Tx, revision datastore.Revision) error
 
+// RevisionFilter limits tuple queries to the tuples which were live at a revision, by filtering
+// the rows of each query, by preparing the transaction in which each query is run to read as of
+// the revision, or both.
+type RevisionFilter struct {
+	// FilterQuery limits a query to the rows which were live at the revision. Nil leaves
+	// queries unfiltered.
+	FilterQuery func(query sq.SelectBuilder, revision datastore.Revision) sq.SelectBuilder
+
+	// PrepareTransaction prepares the transaction in which a query is run to read as of the
+	// revision. Nil leaves transactions unprepared.
+	PrepareTransaction TransactionPreparer
+}
+
 // TupleQuerySplitter is a tuple query runner shared by SQL implementations of the datastore.
+//
+// Queries filtered to many usersets or subject IDs are split into multiple queries, each
+// filtered to some of them, so that no query exceeds the estimated size or the number of bound
+// parameters at which queries are split, nor, for usersets, the number of usersets. Split
+// queries are executed in parallel, and their results are merged as they are loaded.
 type TupleQuerySplitter struct {
 	Conn                      *pgxpool.Pool
-	PrepareTransaction        TransactionPreparer
 	SplitAtEstimatedQuerySize units.Base2Bytes
 
+	// SplitAtParameterCount is the number of bound parameters above which a query is split.
+	// Zero, or a count above MaxStatementParameters, splits at MaxStatementParameters.
+	SplitAtParameterCount int
+
+	// MaxUsersetsPerQuery is the most usersets to which a single query is filtered, since
+	// large disjunctions degrade query planning well before queries grow too large. Zero
+	// places no limit on the usersets of a query.
+	MaxUsersetsPerQuery int
+
+	// RevisionFilter limits the query, and every query into which it is split, to the tuples
+	// which were live at the revision.
+	RevisionFilter RevisionFilter
+
 	FilteredQueryBuilder SchemaQueryFilterer
 	Revision             datastore.Revision
+	RevisionValidator    *RevisionValidator
 	Limit                *uint64
 	Usersets             []*v0.ObjectAndRelation
+	SubjectIDs           []string
+	Order                options.TupleOrder
+	Timeout              time.Duration
+	SlowQueryLog         SlowQueryLog
+
+	// Deduplicate removes tuples found by more than one of the queries into which the query
+	// is split from the merged results.
+	Deduplicate bool
 
+	// DebugName names the operation in traces and metrics, and Engine names the datastore
+	// engine in metrics.
 	DebugName string
+	Engine    string
 	Tracer    trace.Tracer
 }
 
 // SplitAndExecute executes one or more SQL queries based on the data bound to the
 // TupleQuerySplitter instance.
 func (ctq TupleQuerySplitter) SplitAndExecute(ctx context.Context) (datastore.TupleIterator, error) {
-	// Determine split points for the query based on the usersets, if any.
-	queries := []SchemaQueryFilterer{}
-	if len(ctq.Usersets) > 0 {
-		splitIndexes := []int{}
-
-		currentEstimatedDataSize := ctq.FilteredQueryBuilder.currentEstimatedSize
-		currentUsersetCount := 0
-
-		for index, userset := range ctq.Usersets {
-			estimatedUsersetSize := len(userset.Namespace) + len(userset.ObjectId) + len(userset.Relation)
-			if currentUsersetCount > 0 && estimatedUsersetSize+currentEstimatedDataSize >= int(ctq.SplitAtEstimatedQuerySize) {
-				currentEstimatedDataSize = ctq.FilteredQueryBuilder.currentEstimatedSize
-				splitIndexes = append(splitIndexes, index)
-			}
+	if err := ctq.RevisionValidator.Validate(ctx, ctq.Revision); err != nil {
+		return nil, fmt.Errorf(errUnableToQueryTuples, err)
+	}
 
-			currentUsersetCount++
-			currentEstimatedDataSize += estimatedUsersetSize
+	if ctq.FilteredQueryBuilder.schema.ColTenant != "" {
+		ctq.FilteredQueryBuilder = ctq.FilteredQueryBuilder.FilterToTenant(datastore.TenantFromContext(ctx))
+	}
+
+	ctq.FilteredQueryBuilder = ctq.FilteredQueryBuilder.
+		FilterToRevision(ctq.RevisionFilter, ctq.Revision).
+		OrderBy(ctq.Order)
+
+	queries, err := ctq.splitQueries()
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToQueryTuples, err)
+	}
+
+	var limit uint64
+	if ctq.Limit != nil {
+		if *ctq.Limit == 0 {
+			return datastore.NewSliceTupleIterator(nil), nil
 		}
 
-		startIndex := 0
-		for _, splitIndex := range splitIndexes {
-			queries = append(queries, ctq.FilteredQueryBuilder.FilterToUsersets(ctq.Usersets[startIndex:splitIndex]))
-			startIndex = splitIndex
+		// Any of the queries may contribute the first tuples of the merged results.
+		limit = *ctq.Limit
+		for index := range queries {
+			queries[index] = queries[index].Limit(limit)
 		}
+	}
 
-		queries = append(queries, ctq.FilteredQueryBuilder.FilterToUsersets(ctq.Usersets[startIndex:]))
-	} else {
-		queries = append(queries, ctq.FilteredQueryBuilder)
+	if len(queries) > 1 {
+		return ctq.executeMerged(ctx, queries, limit), nil
 	}
 
-	// Execute each query.
-	// TODO: make parallel.
+	defer ObserveOperationLatency(ctq.DebugName, ctq.Engine, 1, time.Now())
+
 	name := fmt.Sprintf("Execute%s", ctq.DebugName)
-	ctx, span := ctq.Tracer.Start(ctx, name)
+	ctx, span := datastore.StartSpan(ctx, ctq.Tracer, name)
 	defer span.End()
 
 	var tuples []*v0.RelationTuple
-	for index, query := range queries {
-		var newLimit uint64
-		if ctq.Limit != nil {
-			newLimit = *ctq.Limit - uint64(len(tuples))
-			if newLimit <= 0 {
-				break
+	err = ctq.executeSingleQuery(datastore.SeparateContextWithTracing(ctx), queries[0], 0, limit, func(tpl *v0.RelationTuple) {
+		tuples = append(tuples, tpl)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	iter := datastore.NewSliceTupleIterator(tuples)
+	runtime.SetFinalizer(iter, datastore.BuildFinalizerFunction())
+	return iter, nil
+}
+
+// executeMerged executes the queries in parallel, returning an iterator which merges their
+// results as they are loaded. Closing the iterator, or reaching the limit of the results,
+// cancels any queries which are still running.
+func (ctq TupleQuerySplitter) executeMerged(ctx context.Context, queries []SchemaQueryFilterer, limit uint64) datastore.TupleIterator {
+	start := time.Now()
+	ctx, cancel := context.WithCancel(datastore.SeparateContextWithTracing(ctx))
+
+	name := fmt.Sprintf("Execute%s", ctq.DebugName)
+	ctx, span := datastore.StartSpan(ctx, ctq.Tracer, name)
+
+	// Streams are consumed one after the other in the absence of an order, so the queries
+	// are started in the same order and a query waiting for the merge never holds a slot
+	// needed by the stream being consumed. Merging into an order instead needs the first
+	// tuple of every stream, so the queries only wait for the merge once they have all
+	// started; until then, the running queries queue their tuples without bound so as to
+	// free their slots.
+	bounded := alwaysBounded
+	started := make(chan struct{})
+	if ctq.Order != options.Unordered && len(queries) > maxParallelQueries {
+		bounded = started
+	}
+
+	streams := make([]*tupleStream, 0, len(queries))
+	for range queries {
+		streams = append(streams, newTupleStream(ctx, streamBufferSize, bounded))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(queries))
+	go func() {
+		defer close(started)
+
+		running := make(chan struct{}, maxParallelQueries)
+		for index, query := range queries {
+			stream := streams[index]
+
+			select {
+			case running <- struct{}{}:
+			case <-ctx.Done():
+				stream.finish(ctx.Err())
+				wg.Done()
+				continue
 			}
 
-			query = query.Limit(newLimit)
+			go func(index int, query SchemaQueryFilterer) {
+				defer wg.Done()
+				defer func() { <-running }()
+
+				stream.finish(ctq.executeSingleQuery(ctx, query, index, limit, stream.push))
+			}(index, query)
 		}
+	}()
 
-		foundTuples, err := ctq.executeSingleQuery(ctx, query, index, newLimit)
-		if err != nil {
-			return nil, err
+	go func() {
+		wg.Wait()
+		span.End()
+		ObserveOperationLatency(ctq.DebugName, ctq.Engine, len(queries), start)
+	}()
+
+	return newMergingTupleIterator(cancel, streams, ctq.Order, ctq.Limit, ctq.Deduplicate)
+}
+
+// splitQueries returns the queries into which the query is split, based on the usersets or
+// subject IDs, if any.
+func (ctq TupleQuerySplitter) splitQueries() ([]SchemaQueryFilterer, error) {
+	schema := ctq.FilteredQueryBuilder.schema
+
+	switch {
+	case len(ctq.Usersets) > 0 && len(ctq.SubjectIDs) > 0:
+		return nil, fmt.Errorf("cannot filter a query to both usersets and subject IDs")
+
+	case len(ctq.Usersets) > 0:
+		return ctq.splitFilter(
+			len(ctq.Usersets),
+			0,
+			func(index int) int { return schema.estimatedUsersetSize(ctq.Usersets[index]) },
+			parametersPerUserset,
+			ctq.MaxUsersetsPerQuery,
+			func(start, end int) SchemaQueryFilterer {
+				return ctq.FilteredQueryBuilder.FilterToUsersets(ctq.Usersets[start:end])
+			},
+		)
+
+	case len(ctq.SubjectIDs) > 0:
+		return ctq.splitFilter(
+			len(ctq.SubjectIDs),
+			schema.estimatedSubjectIDsClauseSize(),
+			func(index int) int { return estimatedSubjectIDOverhead + len(ctq.SubjectIDs[index]) },
+			1,
+			0,
+			func(start, end int) SchemaQueryFilterer {
+				return ctq.FilteredQueryBuilder.FilterToSubjectIDs(ctq.SubjectIDs[start:end])
+			},
+		)
+
+	default:
+		return []SchemaQueryFilterer{ctq.FilteredQueryBuilder}, nil
+	}
+}
+
+// splitFilter splits the query into queries each filtered to a contiguous range of the count
+// values to which it is filtered, given the size of the clause filtering to any values, the
+// estimated size and parameter count of each value, the most values of any query (if
+// positive), and a function filtering the query to a range of the values.
+func (ctq TupleQuerySplitter) splitFilter(
+	count int,
+	clauseSize int,
+	valueSize func(index int) int,
+	parametersPerValue int,
+	maxValuesPerQuery int,
+	filterToRange func(start, end int) SchemaQueryFilterer,
+) ([]SchemaQueryFilterer, error) {
+	baseSize, baseParameterCount, err := ctq.FilteredQueryBuilder.estimatedSize()
+	if err != nil {
+		return nil, err
+	}
+	baseSize += clauseSize
+
+	maxParameterCount := ctq.SplitAtParameterCount
+	if maxParameterCount <= 0 || maxParameterCount > MaxStatementParameters {
+		maxParameterCount = MaxStatementParameters
+	}
+
+	var queries []SchemaQueryFilterer
+	startIndex := 0
+	currentSize, currentParameterCount := baseSize, baseParameterCount
+	for index := 0; index < count; index++ {
+		size := valueSize(index)
+		if index > startIndex &&
+			(currentSize+size >= int(ctq.SplitAtEstimatedQuerySize) ||
+				currentParameterCount+parametersPerValue > maxParameterCount ||
+				(maxValuesPerQuery > 0 && index-startIndex >= maxValuesPerQuery)) {
+			queries = append(queries, filterToRange(startIndex, index))
+			startIndex = index
+			currentSize, currentParameterCount = baseSize, baseParameterCount
 		}
-		tuples = append(tuples, foundTuples...)
+
+		currentSize += size
+		currentParameterCount += parametersPerValue
 	}
 
-	iter := datastore.NewSliceTupleIterator(tuples)
-	runtime.SetFinalizer(iter, datastore.BuildFinalizerFunction())
-	return iter, nil
+	return append(queries, filterToRange(startIndex, count)), nil
 }
 
-func (ctq TupleQuerySplitter) executeSingleQuery(ctx context.Context, query SchemaQueryFilterer, index int, limit uint64) ([]*v0.RelationTuple, error) {
-	ctx = datastore.SeparateContextWithTracing(ctx)
+// executeSingleQuery executes the query, emitting each tuple it finds as it is loaded.
+func (ctq TupleQuerySplitter) executeSingleQuery(ctx context.Context, query SchemaQueryFilterer, index int, limit uint64, emit func(*v0.RelationTuple)) error {
+	if ctq.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ctq.Timeout)
+		defer cancel()
+	}
 
 	name := fmt.Sprintf("Query-%d", index)
-	ctx, span := ctq.Tracer.Start(ctx, name)
+	ctx, span := datastore.StartSpan(ctx, ctq.Tracer, name)
 	defer span.End()
 
 	span.SetAttributes(query.tracerAttributes...)
+	filterShapeCounter.WithLabelValues(strings.Join(query.FilterShape(), ",")).Inc()
 
 	sql, args, err := query.queryBuilder.ToSql()
 	if err != nil {
-		return nil, fmt.Errorf(errUnableToQueryTuples, err)
+		return ctq.queryError(ctx, err)
 	}
 
 	span.AddEvent("Query converted to SQL")
 
+	start := time.Now()
+	err = ctq.loadTuples(ctx, sql, args, limit, emit)
+	if elapsed := time.Since(start); ctq.SlowQueryLog.Threshold > 0 && elapsed >= ctq.SlowQueryLog.Threshold {
+		ctq.logSlowQuery(ctx, query, sql, args, elapsed, err)
+	}
+
+	return err
+}
+
+func (ctq TupleQuerySplitter) loadTuples(ctx context.Context, sql string, args []interface{}, limit uint64, emit func(*v0.RelationTuple)) error {
+	span := trace.SpanFromContext(ctx)
+
 	tx, err := ctq.Conn.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
-		return nil, fmt.Errorf(errUnableToQueryTuples, err)
+		return ctq.queryError(ctx, err)
 	}
 	defer tx.Rollback(ctx)
 
 	span.AddEvent("DB transaction established")
 
-	if ctq.PrepareTransaction != nil {
-		err = ctq.PrepareTransaction(ctx, tx, ctq.Revision)
+	if ctq.RevisionFilter.PrepareTransaction != nil {
+		err = ctq.RevisionFilter.PrepareTransaction(ctx, tx, ctq.Revision)
 		if err != nil {
-			return nil, fmt.Errorf(errUnableToQueryTuples, err)
+			return ctq.queryError(ctx, err)
 		}
 
 		span.AddEvent("Transaction prepared")
@@ -283,26 +852,24 @@ func (ctq TupleQuerySplitter) executeSingleQuery(ctx context.Context, query Sche
 
 	rows, err := tx.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, fmt.Errorf(errUnableToQueryTuples, err)
+		return ctq.queryError(ctx, err)
 	}
 	defer rows.Close()
 
 	span.AddEvent("Query issued to database")
 
-	var tuples []*v0.RelationTuple
+	scanned := 0
+	defer func() {
+		rowsScannedCounter.WithLabelValues(ctq.DebugName, ctq.Engine).Add(float64(scanned))
+	}()
+
+	allocator := newTupleAllocator(limit)
 	for rows.Next() {
-		if limit > 0 && len(tuples) >= int(limit) {
-			return tuples, nil
+		if limit > 0 && uint64(scanned) >= limit {
+			return nil
 		}
 
-		nextTuple := &v0.RelationTuple{
-			ObjectAndRelation: &v0.ObjectAndRelation{},
-			User: &v0.User{
-				UserOneof: &v0.User_Userset{
-					Userset: &v0.ObjectAndRelation{},
-				},
-			},
-		}
+		nextTuple := allocator.next()
 		userset := nextTuple.User.GetUserset()
 		err := rows.Scan(
 			&nextTuple.ObjectAndRelation.Namespace,
@@ -313,15 +880,141 @@ func (ctq TupleQuerySplitter) executeSingleQuery(ctx context.Context, query Sche
 			&userset.Relation,
 		)
 		if err != nil {
-			return nil, fmt.Errorf(errUnableToQueryTuples, err)
+			return ctq.queryError(ctx, err)
 		}
+		scanned++
 
-		tuples = append(tuples, nextTuple)
+		emit(nextTuple)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf(errUnableToQueryTuples, err)
+		return ctq.queryError(ctx, err)
+	}
+
+	span.AddEvent("Tuples loaded", trace.WithAttributes(attribute.Int("tupleCount", scanned)))
+	return nil
+}
+
+// tupleSlabSize is the number of tuples whose protos are allocated at once when tuples
+// are loaded.
+const tupleSlabSize = 64
+
+// tupleAllocator allocates the protos of loaded tuples in slabs, so that loading a slab of
+// tuples takes four allocations rather than five for each tuple. The tuples are owned by
+// the callers of queries, which may keep them indefinitely, so they are never reused.
+//
+// Keeping any one tuple keeps its whole slab alive, so callers which keep tuples, such as
+// the query cache of the datastore proxy, may retain up to tupleSlabSize tuples for each
+// tuple they keep, beyond the size they estimate for it.
+type tupleAllocator struct {
+	slabSize int
+	tuples   []v0.RelationTuple
+	onrs     []v0.ObjectAndRelation
+	users    []v0.User
+	usersets []v0.User_Userset
+}
+
+// newTupleAllocator creates a tupleAllocator whose slabs are no larger than the limit
+// of the query, if any.
+func newTupleAllocator(limit uint64) *tupleAllocator {
+	slabSize := tupleSlabSize
+	if limit > 0 && limit < tupleSlabSize {
+		slabSize = int(limit)
+	}
+	return &tupleAllocator{slabSize: slabSize}
+}
+
+// next returns an empty tuple whose user is a userset.
+func (ta *tupleAllocator) next() *v0.RelationTuple {
+	if len(ta.tuples) == 0 {
+		ta.tuples = make([]v0.RelationTuple, ta.slabSize)
+		ta.onrs = make([]v0.ObjectAndRelation, 2*ta.slabSize)
+		ta.users = make([]v0.User, ta.slabSize)
+		ta.usersets = make([]v0.User_Userset, ta.slabSize)
+	}
+
+	tpl, user, userset := &ta.tuples[0], &ta.users[0], &ta.usersets[0]
+	userset.Userset = &ta.onrs[1]
+	user.UserOneof = userset
+	tpl.ObjectAndRelation = &ta.onrs[0]
+	tpl.User = user
+
+	ta.tuples, ta.users, ta.usersets, ta.onrs = ta.tuples[1:], ta.users[1:], ta.usersets[1:], ta.onrs[2:]
+	return tpl
+}
+
+// logSlowQuery logs a query which exceeded the slow query threshold. The query arguments
+// are never logged, since they contain object IDs; the filter attributes are logged in
+// their place, obfuscated as they are attached to traces.
+func (ctq TupleQuerySplitter) logSlowQuery(ctx context.Context, query SchemaQueryFilterer, sql string, args []interface{}, elapsed time.Duration, queryErr error) {
+	filters := make([]string, 0, len(query.tracerAttributes))
+	for _, attr := range query.tracerAttributes {
+		filters = append(filters, fmt.Sprintf("%s=%s", attr.Key, attr.Value.Emit()))
+	}
+
+	event := log.Warn().
+		Str("name", ctq.DebugName).
+		Dur("duration", elapsed).
+		Str("sql", sql).
+		Strs("filters", filters)
+
+	if queryErr != nil {
+		event = event.Err(queryErr)
+	}
+
+	// Plans contain the values of the query arguments, which cannot be obfuscated.
+	if ctq.SlowQueryLog.ExplainSampleRate > 0 && !redact.Enabled() && rand.Float64() < ctq.SlowQueryLog.ExplainSampleRate {
+		plan, err := ctq.explain(ctx, sql, args)
+		if err != nil {
+			event = event.AnErr("explainError", err)
+		} else {
+			event = event.Str("plan", plan)
+		}
+	}
+
+	event.Msg("slow tuple query")
+}
+
+// explain captures the plan for a query, without executing it.
+func (ctq TupleQuerySplitter) explain(ctx context.Context, sql string, args []interface{}) (string, error) {
+	// The query's own context may have expired, which is frequently why it was slow.
+	ctx, cancel := context.WithTimeout(datastore.SeparateContextWithTracing(ctx), explainTimeout)
+	defer cancel()
+
+	rows, err := ctq.Conn.Query(ctx, "EXPLAIN "+sql, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
 	}
 
-	span.AddEvent("Tuples loaded", trace.WithAttributes(attribute.Int("tupleCount", len(tuples))))
-	return tuples, nil
+	return strings.Join(lines, "\n"), nil
+}
+
+// queryError converts an error raised while executing a query into a query timeout error if
+// the query's statement timeout was exceeded.
+func (ctq TupleQuerySplitter) queryError(ctx context.Context, err error) error {
+	if ctq.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf(errUnableToQueryTuples, datastore.NewQueryTimeoutErr(ctq.Timeout))
+	}
+	return fmt.Errorf(errUnableToQueryTuples, err)
+}
+
+// QueryTimeout returns the timeout to apply to a query, preferring the timeout specified for
+// the query itself over the datastore-wide default.
+func QueryTimeout(queryTimeout time.Duration, defaultTimeout time.Duration) time.Duration {
+	if queryTimeout > 0 {
+		return queryTimeout
+	}
+	return defaultTimeout
 }
@@ -0,0 +1,381 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/alecthomas/units"
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+)
+
+var testSchema = SchemaInformation{
+	TableTuple:          "relation_tuple",
+	ColNamespace:        "namespace",
+	ColObjectID:         "object_id",
+	ColRelation:         "relation",
+	ColUsersetNamespace: "userset_namespace",
+	ColUsersetObjectID:  "userset_object_id",
+	ColUsersetRelation:  "userset_relation",
+}
+
+func TestWithHint(t *testing.T) {
+	testCases := []struct {
+		name        string
+		shape       QueryShape
+		hints       QueryHints
+		expectedSQL string
+	}{
+		{
+			"no hints",
+			QueryShapeQueryTuples,
+			nil,
+			"SELECT * FROM relation_tuple WHERE namespace = ?",
+		},
+		{
+			"hint for other shape",
+			QueryShapeQueryTuples,
+			QueryHints{QueryShapeReverseQueryTuples: "IndexScan(relation_tuple)"},
+			"SELECT * FROM relation_tuple WHERE namespace = ?",
+		},
+		{
+			"hint for matching shape",
+			QueryShapeQueryTuples,
+			QueryHints{QueryShapeQueryTuples: "IndexScan(relation_tuple)"},
+			"/*+ IndexScan(relation_tuple) */ SELECT * FROM relation_tuple WHERE namespace = ?",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			filterer := NewSchemaQueryFilterer(testSchema, sq.Select("*").From("relation_tuple")).
+				FilterToResourceType("document").
+				WithHint(tc.shape, tc.hints, CommentQueryHinter)
+
+			sql, args, err := filterer.queryBuilder.ToSql()
+			require.NoError(err)
+			require.Equal(tc.expectedSQL, sql)
+			require.Equal([]interface{}{"document"}, args)
+		})
+	}
+}
+
+func TestParseQueryHints(t *testing.T) {
+	require := require.New(t)
+
+	hints, err := ParseQueryHints(map[string]string{"reverse-query-tuples": "SeqScan(relation_tuple)"})
+	require.NoError(err)
+	require.Equal(QueryHints{QueryShapeReverseQueryTuples: "SeqScan(relation_tuple)"}, hints)
+
+	_, err = ParseQueryHints(map[string]string{"unknown": "SeqScan(relation_tuple)"})
+	require.Error(err)
+}
+
+func TestFilterToTenant(t *testing.T) {
+	require := require.New(t)
+
+	schema := testSchema
+	schema.ColTenant = "tenant"
+
+	filterer := NewSchemaQueryFilterer(schema, sq.Select("*").From("relation_tuple")).
+		FilterToTenant("acme").
+		FilterToResourceType("document")
+
+	sql, args, err := filterer.queryBuilder.ToSql()
+	require.NoError(err)
+	require.Equal("SELECT * FROM relation_tuple WHERE tenant = ? AND namespace = ?", sql)
+	require.Equal([]interface{}{"acme", "document"}, args)
+	require.Equal([]string{"namespace"}, filterer.FilterShape())
+}
+
+func TestFilterToAnyRelationshipFilter(t *testing.T) {
+	require := require.New(t)
+
+	filterer := NewSchemaQueryFilterer(testSchema, sq.Select("*").From("relation_tuple")).
+		FilterToAnyRelationshipFilter([]*v1.RelationshipFilter{
+			{ResourceType: "document", OptionalRelation: "viewer"},
+			{
+				ResourceType:          "folder",
+				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "user", OptionalSubjectId: "tom"},
+			},
+		})
+
+	sql, args, err := filterer.queryBuilder.ToSql()
+	require.NoError(err)
+	require.Equal("SELECT * FROM relation_tuple WHERE ((namespace = ? AND relation = ?) OR (namespace = ? AND userset_namespace = ? AND userset_object_id = ?))", sql)
+	require.Equal([]interface{}{"document", "viewer", "folder", "user", "tom"}, args)
+	require.Equal([]string{"namespace", "relation", "userset_namespace", "userset_object_id"}, filterer.FilterShape())
+}
+
+func TestFilterToRevision(t *testing.T) {
+	require := require.New(t)
+
+	revisionFilter := RevisionFilter{
+		FilterQuery: func(query sq.SelectBuilder, revision datastore.Revision) sq.SelectBuilder {
+			return query.Where(sq.LtOrEq{"created_transaction": revision.IntPart()})
+		},
+	}
+
+	base := NewSchemaQueryFilterer(testSchema, sq.Select("*").From("relation_tuple")).
+		FilterToResourceType("document")
+
+	sql, args, err := base.FilterToRevision(revisionFilter, decimal.NewFromInt(42)).ToSql()
+	require.NoError(err)
+	require.Equal("SELECT * FROM relation_tuple WHERE namespace = ? AND created_transaction <= ?", sql)
+	require.Equal([]interface{}{"document", int64(42)}, args)
+
+	sql, _, err = base.FilterToRevision(RevisionFilter{}, decimal.NewFromInt(42)).ToSql()
+	require.NoError(err)
+	require.Equal("SELECT * FROM relation_tuple WHERE namespace = ?", sql)
+}
+
+func TestFilterToResourceIDsAfter(t *testing.T) {
+	require := require.New(t)
+
+	filterer := NewSchemaQueryFilterer(testSchema, sq.Select("*").From("relation_tuple")).
+		FilterToResourceType("document").
+		FilterToResourceIDsAfter("masterplan")
+
+	sql, args, err := filterer.queryBuilder.ToSql()
+	require.NoError(err)
+	require.Equal("SELECT * FROM relation_tuple WHERE namespace = ? AND object_id > ?", sql)
+	require.Equal([]interface{}{"document", "masterplan"}, args)
+
+	// IDs are compared with the collation in which queries are ordered.
+	collatedSchema := testSchema
+	collatedSchema.OrderCollation = "C"
+	sql, _, err = NewSchemaQueryFilterer(collatedSchema, sq.Select("*").From("relation_tuple")).
+		FilterToResourceIDsAfter("masterplan").
+		ToSql()
+	require.NoError(err)
+	require.Equal(`SELECT * FROM relation_tuple WHERE object_id COLLATE "C" > ?`, sql)
+}
+
+func TestOrderBy(t *testing.T) {
+	collatedSchema := testSchema
+	collatedSchema.OrderCollation = "C"
+
+	testCases := []struct {
+		name        string
+		schema      SchemaInformation
+		order       options.TupleOrder
+		expectedSQL string
+	}{
+		{
+			"unordered",
+			testSchema,
+			options.Unordered,
+			"SELECT * FROM relation_tuple WHERE namespace = ?",
+		},
+		{
+			"by resource",
+			testSchema,
+			options.ByResource,
+			"SELECT * FROM relation_tuple WHERE namespace = ? ORDER BY namespace, object_id, relation, userset_namespace, userset_object_id, userset_relation",
+		},
+		{
+			"by subject",
+			testSchema,
+			options.BySubject,
+			"SELECT * FROM relation_tuple WHERE namespace = ? ORDER BY userset_namespace, userset_object_id, userset_relation, namespace, object_id, relation",
+		},
+		{
+			"collated",
+			collatedSchema,
+			options.ByResource,
+			`SELECT * FROM relation_tuple WHERE namespace = ? ORDER BY namespace COLLATE "C", object_id COLLATE "C", relation COLLATE "C", userset_namespace COLLATE "C", userset_object_id COLLATE "C", userset_relation COLLATE "C"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			filterer := NewSchemaQueryFilterer(tc.schema, sq.Select("*").From("relation_tuple")).
+				FilterToResourceType("document").
+				OrderBy(tc.order)
+
+			sql, _, err := filterer.queryBuilder.ToSql()
+			require.NoError(err)
+			require.Equal(tc.expectedSQL, sql)
+		})
+	}
+}
+
+func TestSplitQueries(t *testing.T) {
+	usersets := make([]*v0.ObjectAndRelation, 0, 10)
+	for i := 0; i < cap(usersets); i++ {
+		usersets = append(usersets, &v0.ObjectAndRelation{Namespace: "user", ObjectId: fmt.Sprintf("%03d", i), Relation: "..."})
+	}
+
+	base := NewSchemaQueryFilterer(testSchema, sq.Select("*").From("relation_tuple")).
+		FilterToResourceType("document")
+	baseSize, baseParameterCount, err := base.estimatedSize()
+	require.NoError(t, err)
+	require.Equal(t, 1, baseParameterCount)
+
+	usersetSize := testSchema.estimatedUsersetSize(usersets[0])
+	require.Greater(t, usersetSize, len("user")+len("000")+len("..."))
+
+	testCases := []struct {
+		name                string
+		splitAtSize         int
+		splitAtParameters   int
+		maxUsersets         int
+		expectedSplitCounts []int
+	}{
+		{"no splitting", 1024 * 1024, 0, 0, []int{10}},
+		{"by size", baseSize + 4*usersetSize + 1, 0, 0, []int{4, 4, 2}},
+		{"by parameters", 1024 * 1024, baseParameterCount + 3*parametersPerUserset, 0, []int{3, 3, 3, 1}},
+		{"by both", baseSize + 4*usersetSize + 1, baseParameterCount + 3*parametersPerUserset, 0, []int{3, 3, 3, 1}},
+		{"by userset count", 1024 * 1024, 0, 6, []int{6, 4}},
+		{"by userset count and size", baseSize + 4*usersetSize + 1, 0, 2, []int{2, 2, 2, 2, 2}},
+		{"always at least one userset", 1, 1, 0, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			queries, err := TupleQuerySplitter{
+				SplitAtEstimatedQuerySize: units.Base2Bytes(tc.splitAtSize),
+				SplitAtParameterCount:     tc.splitAtParameters,
+				MaxUsersetsPerQuery:       tc.maxUsersets,
+				FilteredQueryBuilder:      base,
+				Usersets:                  usersets,
+			}.splitQueries()
+			require.NoError(err)
+
+			splitCounts := make([]int, 0, len(queries))
+			for _, query := range queries {
+				_, args, err := query.ToSql()
+				require.NoError(err)
+				splitCounts = append(splitCounts, (len(args)-baseParameterCount)/parametersPerUserset)
+			}
+			require.Equal(tc.expectedSplitCounts, splitCounts)
+		})
+	}
+}
+
+func TestSplitQueriesBySubjectIDs(t *testing.T) {
+	subjectIDs := make([]string, 0, 10)
+	for i := 0; i < cap(subjectIDs); i++ {
+		subjectIDs = append(subjectIDs, fmt.Sprintf("%03d", i))
+	}
+
+	base := NewSchemaQueryFilterer(testSchema, sq.Select("*").From("relation_tuple")).
+		FilterToResourceType("document")
+	baseSize, baseParameterCount, err := base.estimatedSize()
+	require.NoError(t, err)
+
+	clauseSize := testSchema.estimatedSubjectIDsClauseSize()
+	subjectIDSize := estimatedSubjectIDOverhead + len("000")
+
+	testCases := []struct {
+		name                string
+		splitAtSize         int
+		splitAtParameters   int
+		expectedSplitCounts []int
+	}{
+		{"no splitting", 1024 * 1024, 0, []int{10}},
+		{"by size", baseSize + clauseSize + 4*subjectIDSize + 1, 0, []int{4, 4, 2}},
+		{"by parameters", 1024 * 1024, baseParameterCount + 3, []int{3, 3, 3, 1}},
+		{"always at least one subject ID", 1, 1, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			queries, err := TupleQuerySplitter{
+				SplitAtEstimatedQuerySize: units.Base2Bytes(tc.splitAtSize),
+				SplitAtParameterCount:     tc.splitAtParameters,
+				MaxUsersetsPerQuery:       1,
+				FilteredQueryBuilder:      base,
+				SubjectIDs:                subjectIDs,
+			}.splitQueries()
+			require.NoError(err)
+
+			var splitCounts []int
+			var found []interface{}
+			for _, query := range queries {
+				sql, args, err := query.ToSql()
+				require.NoError(err)
+				require.Contains(sql, "userset_object_id IN (")
+				splitCounts = append(splitCounts, len(args)-baseParameterCount)
+				found = append(found, args[baseParameterCount:]...)
+			}
+			require.Equal(tc.expectedSplitCounts, splitCounts)
+			require.Len(found, len(subjectIDs))
+		})
+	}
+
+	_, err = TupleQuerySplitter{
+		FilteredQueryBuilder: base,
+		Usersets:             []*v0.ObjectAndRelation{{Namespace: "user", ObjectId: "tom", Relation: "..."}},
+		SubjectIDs:           subjectIDs,
+	}.splitQueries()
+	require.Error(t, err)
+}
+
+func TestTupleAllocator(t *testing.T) {
+	require := require.New(t)
+
+	allocator := newTupleAllocator(0)
+	tuples := make([]*v0.RelationTuple, 0, 2*tupleSlabSize+1)
+	for i := 0; i < cap(tuples); i++ {
+		tpl := allocator.next()
+		tpl.ObjectAndRelation.ObjectId = fmt.Sprintf("resource%d", i)
+		tpl.User.GetUserset().ObjectId = fmt.Sprintf("subject%d", i)
+		tuples = append(tuples, tpl)
+	}
+
+	for i, tpl := range tuples {
+		require.Equal(fmt.Sprintf("resource%d", i), tpl.ObjectAndRelation.ObjectId)
+		require.Equal(fmt.Sprintf("subject%d", i), tpl.User.GetUserset().ObjectId)
+	}
+
+	limited := newTupleAllocator(2)
+	limited.next()
+	require.Len(limited.tuples, 1)
+}
+
+func BenchmarkTupleAllocation(b *testing.B) {
+	const rows = 1000
+
+	b.Run("per tuple", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			tuples := make([]*v0.RelationTuple, 0, rows)
+			for j := 0; j < rows; j++ {
+				tuples = append(tuples, &v0.RelationTuple{
+					ObjectAndRelation: &v0.ObjectAndRelation{},
+					User: &v0.User{
+						UserOneof: &v0.User_Userset{
+							Userset: &v0.ObjectAndRelation{},
+						},
+					},
+				})
+			}
+		}
+	})
+
+	b.Run("slab", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			allocator := newTupleAllocator(0)
+			tuples := make([]*v0.RelationTuple, 0, rows)
+			for j := 0; j < rows; j++ {
+				tuples = append(tuples, allocator.next())
+			}
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"github.com/alecthomas/units"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+)
+
+// MaxStatementParameters is the maximum number of parameters which may be bound to a single
+// statement over the Postgres wire protocol, which is also spoken by CockroachDB.
+const MaxStatementParameters = 65535
+
+// WriteSplitter chunks the relationships of a bulk write, so that the statements inserting or
+// deleting them stay within the limits on the size of a statement. Every chunk is meant to be
+// written by its own statement within the same transaction, so that the write as a whole
+// remains atomic.
+type WriteSplitter struct {
+	// SplitAtEstimatedQuerySize is the estimated size of the data of a statement at which a
+	// new chunk is started.
+	SplitAtEstimatedQuerySize units.Base2Bytes
+
+	// ParametersPerRelationship is the number of parameters bound to a statement for each
+	// relationship it writes.
+	ParametersPerRelationship int
+}
+
+// Split returns the relationships in chunks, in their original order. No chunk is empty,
+// so no chunks are returned for no relationships.
+func (ws WriteSplitter) Split(relationships []*v1.Relationship) [][]*v1.Relationship {
+	maxPerChunk := len(relationships)
+	if ws.ParametersPerRelationship > 0 {
+		maxPerChunk = MaxStatementParameters / ws.ParametersPerRelationship
+	}
+
+	var chunks [][]*v1.Relationship
+	startIndex := 0
+	currentEstimatedDataSize := 0
+	for index, rel := range relationships {
+		estimatedSize := estimatedRelationshipSize(rel)
+		currentCount := index - startIndex
+		if currentCount > 0 &&
+			(currentEstimatedDataSize+estimatedSize >= int(ws.SplitAtEstimatedQuerySize) || currentCount >= maxPerChunk) {
+			chunks = append(chunks, relationships[startIndex:index])
+			startIndex = index
+			currentEstimatedDataSize = 0
+		}
+		currentEstimatedDataSize += estimatedSize
+	}
+
+	if startIndex < len(relationships) {
+		chunks = append(chunks, relationships[startIndex:])
+	}
+	return chunks
+}
+
+func estimatedRelationshipSize(rel *v1.Relationship) int {
+	return len(rel.Resource.ObjectType) +
+		len(rel.Resource.ObjectId) +
+		len(rel.Relation) +
+		len(rel.Subject.Object.ObjectType) +
+		len(rel.Subject.Object.ObjectId) +
+		len(rel.Subject.OptionalRelation)
+}
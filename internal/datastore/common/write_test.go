@@ -0,0 +1,75 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/units"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestWriteSplitter(t *testing.T) {
+	relationships := make([]*v1.Relationship, 0, 10)
+	for i := 0; i < 10; i++ {
+		// Each relationship has an estimated size of 27 bytes.
+		relationships = append(relationships, tuple.ParseRel(fmt.Sprintf("document:doc%d#viewer@user:user%d", i, i)))
+	}
+
+	testCases := []struct {
+		name               string
+		splitter           WriteSplitter
+		relationships      []*v1.Relationship
+		expectedChunkSizes []int
+	}{
+		{
+			"no relationships",
+			WriteSplitter{SplitAtEstimatedQuerySize: units.KiB},
+			nil,
+			nil,
+		},
+		{
+			"single chunk",
+			WriteSplitter{SplitAtEstimatedQuerySize: units.KiB},
+			relationships,
+			[]int{10},
+		},
+		{
+			"split by size",
+			WriteSplitter{SplitAtEstimatedQuerySize: 100},
+			relationships,
+			[]int{3, 3, 3, 1},
+		},
+		{
+			"oversized relationship",
+			WriteSplitter{SplitAtEstimatedQuerySize: 10},
+			relationships[:3],
+			[]int{1, 1, 1},
+		},
+		{
+			"split by parameters",
+			WriteSplitter{SplitAtEstimatedQuerySize: units.KiB, ParametersPerRelationship: MaxStatementParameters / 4},
+			relationships,
+			[]int{4, 4, 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			chunks := tc.splitter.Split(tc.relationships)
+
+			var chunkSizes []int
+			var rejoined []*v1.Relationship
+			for _, chunk := range chunks {
+				chunkSizes = append(chunkSizes, len(chunk))
+				rejoined = append(rejoined, chunk...)
+			}
+			require.Equal(tc.expectedChunkSizes, chunkSizes)
+			require.Equal(tc.relationships, rejoined)
+		})
+	}
+}
@@ -9,15 +9,17 @@ import (
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/alecthomas/units"
+	"github.com/benbjohnson/clock"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/log/zerologadapter"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
 	"go.opentelemetry.io/otel"
 
 	"github.com/authzed/spicedb/internal/datastore"
-	"github.com/authzed/spicedb/internal/datastore/crdb/migrations"
+	"github.com/authzed/spicedb/internal/datastore/common"
 )
 
 var (
@@ -29,9 +31,12 @@ var (
 )
 
 const (
+	engineName = "cockroachdb"
+
 	tableNamespace    = "namespace_config"
 	tableTuple        = "relation_tuple"
 	tableTransactions = "transactions"
+	tableMetadata     = "metadata"
 
 	colNamespace        = "namespace"
 	colConfig           = "serialized_config"
@@ -42,6 +47,7 @@ const (
 	colUsersetNamespace = "userset_namespace"
 	colUsersetObjectID  = "userset_object_id"
 	colUsersetRelation  = "userset_relation"
+	colUniqueID         = "unique_id"
 
 	errUnableToInstantiate = "unable to instantiate datastore: %w"
 	errRevision            = "unable to find revision: %w"
@@ -87,6 +93,13 @@ func NewCRDBDatastore(url string, options ...Option) (datastore.Datastore, error
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
+	if config.enablePrometheusStats {
+		err = prometheus.Register(common.NewPgxpoolStatsCollector(conn, "spicedb"))
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
 	gcWindowNanos := config.gcWindow.Nanoseconds()
 	clusterTTLNanos, err := readClusterTTLNanos(conn)
 	if err != nil {
@@ -126,7 +139,13 @@ func NewCRDBDatastore(url string, options ...Option) (datastore.Datastore, error
 
 	followerReadDelayNanos := config.followerReadDelay.Nanoseconds()
 
-	return &crdbDatastore{
+	healthCheckCtx, cancelHealthCheck := context.WithCancel(context.Background())
+	go common.NewPoolWaitTracker(conn).Run(healthCheckCtx)
+	if config.connPingInterval > 0 {
+		go common.NewPoolHealthChecker(conn, "spicedb", config.connPingInterval).Run(healthCheckCtx)
+	}
+
+	cds := &crdbDatastore{
 		dburl:                     url,
 		conn:                      conn,
 		watchBufferLength:         config.watchBufferLength,
@@ -135,9 +154,20 @@ func NewCRDBDatastore(url string, options ...Option) (datastore.Datastore, error
 		gcWindowNanos:             gcWindowNanos,
 		followerReadDelayNanos:    followerReadDelayNanos,
 		splitAtEstimatedQuerySize: config.splitAtEstimatedQuerySize,
+		splitAtParameterCount:     config.splitAtParameterCount,
+		maxUsersetsPerQuery:       config.maxUsersetsPerQuery,
+		queryHints:                config.queryHints,
+		queryTimeout:              config.queryTimeout,
+		slowQueryLog:              config.slowQueryLog,
 		execute:                   executeWithMaxRetries(config.maxRetries),
 		overlapKeyer:              keyer,
-	}, nil
+		cancelHealthCheck:         cancelHealthCheck,
+		timeSource:                config.timeSource,
+		clockSkew:                 common.NewClockSkew(engineName, config.maxClockSkew),
+	}
+	cds.revisionValidator = common.NewRevisionValidator(cds.CheckRevision, common.DefaultRevisionValidityCacheDuration)
+
+	return cds, nil
 }
 
 type crdbDatastore struct {
@@ -149,26 +179,25 @@ type crdbDatastore struct {
 	gcWindowNanos             int64
 	followerReadDelayNanos    int64
 	splitAtEstimatedQuerySize units.Base2Bytes
+	splitAtParameterCount     int
+	maxUsersetsPerQuery       int
+	queryHints                common.QueryHints
+	queryTimeout              time.Duration
+	slowQueryLog              common.SlowQueryLog
 	execute                   executeTxRetryFunc
 	overlapKeyer              overlapKeyer
+	timeSource                clock.Clock
+	clockSkew                 *common.ClockSkew
+	revisionValidator         *common.RevisionValidator
 
 	lastQuantizedRevision decimal.Decimal
 	revisionValidThrough  time.Time
+
+	cancelHealthCheck context.CancelFunc
 }
 
 func (cds *crdbDatastore) IsReady(ctx context.Context) (bool, error) {
-	headMigration, err := migrations.CRDBMigrations.HeadRevision()
-	if err != nil {
-		return false, fmt.Errorf("invalid head migration found for postgres: %w", err)
-	}
-
-	currentRevision, err := migrations.NewCRDBDriver(cds.dburl)
-	if err != nil {
-		return false, err
-	}
-	defer currentRevision.Dispose()
-
-	version, err := currentRevision.Version()
+	version, headMigration, err := cds.migrationRevisions()
 	if err != nil {
 		return false, err
 	}
@@ -177,6 +206,7 @@ func (cds *crdbDatastore) IsReady(ctx context.Context) (bool, error) {
 }
 
 func (cds *crdbDatastore) Close() error {
+	cds.cancelHealthCheck()
 	cds.conn.Close()
 	return nil
 }
@@ -185,7 +215,7 @@ func (cds *crdbDatastore) OptimizedRevision(ctx context.Context) (datastore.Revi
 	ctx, span := tracer.Start(ctx, "OptimizedRevision")
 	defer span.End()
 
-	localNow := time.Now()
+	localNow := cds.timeSource.Now()
 	if localNow.Before(cds.revisionValidThrough) {
 		log.Ctx(ctx).Debug().Time("now", localNow).Time("valid", cds.revisionValidThrough).Msg("returning cached revision")
 		return cds.lastQuantizedRevision, nil
@@ -197,6 +227,7 @@ func (cds *crdbDatastore) OptimizedRevision(ctx context.Context) (datastore.Revi
 	if err != nil {
 		return datastore.NoRevision, err
 	}
+	cds.clockSkew.Observe(ctx, time.Unix(0, nowHLC.IntPart()), localNow, cds.timeSource.Now())
 
 	// Round the revision down to the nearest quantization
 	// Apply a delay to enable follower reads: https://www.cockroachlabs.com/docs/stable/follower-reads.html
@@ -212,6 +243,11 @@ func (cds *crdbDatastore) OptimizedRevision(ctx context.Context) (datastore.Revi
 	cds.revisionValidThrough = localNow.
 		Add(time.Duration(validForNanos) * time.Nanosecond).
 		Add(cds.maxRevisionStaleness)
+	if cds.clockSkew.Exceeded() {
+		// The local clock cannot be relied upon to measure how long the revision remains
+		// valid, so it is recomputed from the cluster's clock for every request.
+		cds.revisionValidThrough = localNow
+	}
 	log.Ctx(ctx).Debug().Time("now", localNow).Time("valid", cds.revisionValidThrough).Int64("validForNanos", validForNanos).Msg("setting valid through")
 	cds.lastQuantizedRevision = decimal.NewFromInt(quantized)
 
@@ -248,17 +284,18 @@ func (cds *crdbDatastore) CheckRevision(ctx context.Context, revision datastore.
 
 	nowNanos := now.IntPart()
 	revisionNanos := revision.IntPart()
+	oldest := decimal.NewFromInt(nowNanos - cds.gcWindowNanos)
 
 	staleRevision := revisionNanos < (nowNanos - cds.gcWindowNanos)
 	if staleRevision {
 		log.Ctx(ctx).Debug().Stringer("now", now).Stringer("revision", revision).Msg("stale revision")
-		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
+		return datastore.NewRevisionOutsideWindowErr(revision, oldest, now)
 	}
 
 	futureRevision := revisionNanos > nowNanos
 	if futureRevision {
 		log.Ctx(ctx).Debug().Stringer("now", now).Stringer("revision", revision).Msg("future revision")
-		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionInFuture)
+		return datastore.NewRevisionOutsideWindowErr(revision, oldest, now)
 	}
 
 	return nil
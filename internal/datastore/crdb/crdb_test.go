@@ -16,7 +16,9 @@ import (
 
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/crdb/migrations"
-	"github.com/authzed/spicedb/internal/datastore/test"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/datastore/benchmark"
+	"github.com/authzed/spicedb/pkg/datastore/test"
 	"github.com/authzed/spicedb/pkg/migrate"
 	"github.com/authzed/spicedb/pkg/secrets"
 )
@@ -79,7 +81,28 @@ func TestCRDBDatastore(t *testing.T) {
 	tester := newTester(crdbContainer, "root:fake", 26257)
 	defer tester.cleanup()
 
-	test.All(t, tester)
+	test.RunAll(t, tester)
+}
+
+func TestCRDBDifferential(t *testing.T) {
+	tester := newTester(crdbContainer, "root:fake", 26257)
+	defer tester.cleanup()
+
+	oracle := test.DatastoreBuilder(func(revisionFuzzingTimedelta, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error) {
+		return memdb.NewMemdbDatastore(watchBufferLength, revisionFuzzingTimedelta, gcWindow, 0)
+	})
+	test.RunDifferential(t, oracle, tester, time.Now().UnixNano(), 500)
+}
+
+func BenchmarkCRDBDatastore(b *testing.B) {
+	tester := newTester(crdbContainer, "root:fake", 26257)
+	defer tester.cleanup()
+
+	ds, err := tester.New(0, 24*time.Hour, 1)
+	require.NoError(b, err)
+	defer ds.Close()
+
+	benchmark.RunBenchmarks(b, ds)
 }
 
 func TestCRDBDatastoreWithFollowerReads(t *testing.T) {
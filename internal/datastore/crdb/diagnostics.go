@@ -0,0 +1,49 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/crdb/migrations"
+)
+
+const errUnableToDiagnose = "unable to diagnose datastore: %w"
+
+// Diagnostics implements datastore.Diagnoser. CockroachDB replicates writes synchronously
+// and garbage collects expired revisions itself, so neither lag is reported.
+func (cds *crdbDatastore) Diagnostics(ctx context.Context) (datastore.Diagnostics, error) {
+	currentMigration, headMigration, err := cds.migrationRevisions()
+	if err != nil {
+		return datastore.Diagnostics{}, fmt.Errorf(errUnableToDiagnose, err)
+	}
+
+	return datastore.Diagnostics{
+		MigrationRevision:         currentMigration,
+		ExpectedMigrationRevision: headMigration,
+		Pools:                     []datastore.PoolDiagnostics{common.DiagnosePool("spicedb", cds.conn)},
+	}, nil
+}
+
+// migrationRevisions returns the current migration revision of the database, and the head
+// migration revision which it is expected to be at.
+func (cds *crdbDatastore) migrationRevisions() (string, string, error) {
+	headMigration, err := migrations.CRDBMigrations.HeadRevision()
+	if err != nil {
+		return "", "", fmt.Errorf("invalid head migration found for cockroach: %w", err)
+	}
+
+	currentRevision, err := migrations.NewCRDBDriver(cds.dburl)
+	if err != nil {
+		return "", "", err
+	}
+	defer currentRevision.Dispose()
+
+	version, err := currentRevision.Version()
+	if err != nil {
+		return "", "", err
+	}
+
+	return version, headMigration, nil
+}
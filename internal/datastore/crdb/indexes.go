@@ -0,0 +1,27 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const queryTupleIndexes = `
+SELECT index_name, column_name
+FROM information_schema.statistics
+WHERE table_name = $1 AND table_schema = current_schema() AND NOT storing
+ORDER BY index_name, seq_in_index`
+
+// TupleIndexes implements datastore.IndexInspector.
+func (cds *crdbDatastore) TupleIndexes(ctx context.Context) ([]datastore.TupleIndex, error) {
+	return common.QueryTupleIndexes(ctx, cds.conn, queryTupleIndexes, tableTuple)
+}
+
+// CreateTupleIndexDDL implements datastore.IndexInspector. CockroachDB builds indexes
+// online, without blocking writes.
+func (cds *crdbDatastore) CreateTupleIndexDDL(name string, columns []string) string {
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s);", name, tableTuple, strings.Join(columns, ", "))
+}
@@ -24,6 +24,9 @@ const (
 // datastore.
 type CRDBDriver struct {
 	db *pgx.Conn
+
+	recording bool
+	recorded  []string
 }
 
 // NewCRDBDriver creates a new driver with active connections to the database
@@ -41,7 +44,7 @@ func NewCRDBDriver(url string) (*CRDBDriver, error) {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
-	return &CRDBDriver{db}, nil
+	return &CRDBDriver{db: db}, nil
 }
 
 // Version returns the version of the schema to which the connected database
@@ -80,6 +83,55 @@ func (apd *CRDBDriver) WriteVersion(version, replaced string) error {
 	return nil
 }
 
+// StartRecording causes the statements of migrations to be recorded rather than executed.
+func (apd *CRDBDriver) StartRecording() {
+	apd.recording = true
+	apd.recorded = nil
+}
+
+// StopRecording returns the statements recorded and resumes executing statements.
+func (apd *CRDBDriver) StopRecording() []string {
+	recorded := apd.recorded
+	apd.recording = false
+	apd.recorded = nil
+	return recorded
+}
+
+// exec executes a statement of a migration outside of a transaction, or records it if the
+// driver is recording.
+func (apd *CRDBDriver) exec(ctx context.Context, stmt string) error {
+	if apd.recording {
+		apd.recorded = append(apd.recorded, stmt)
+		return nil
+	}
+
+	_, err := apd.db.Exec(ctx, stmt)
+	return err
+}
+
+// execInTransaction executes the statements of a migration in a single transaction, or
+// records them if the driver is recording.
+func (apd *CRDBDriver) execInTransaction(ctx context.Context, statements ...string) error {
+	if apd.recording {
+		apd.recorded = append(apd.recorded, statements...)
+		return nil
+	}
+
+	tx, err := apd.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
 // Dispose disposes the driver.
 func (apd *CRDBDriver) Dispose() {
 	apd.db.Close(context.Background())
@@ -36,33 +36,18 @@ func init() {
 	if err := CRDBMigrations.Register("initial", "", func(apd *CRDBDriver) error {
 		ctx := context.Background()
 
-		_, err := apd.db.Exec(ctx, enableRangefeeds)
-		if err != nil {
+		if err := apd.exec(ctx, enableRangefeeds); err != nil {
 			return err
 		}
 
-		tx, err := apd.db.Begin(ctx)
-		if err != nil {
-			return err
-		}
-		defer tx.Rollback(ctx)
-
-		statements := []string{
+		return apd.execInTransaction(ctx,
 			createNamespaceConfig,
 			createRelationTuple,
 			createSchemaVersion,
 			insertEmptyVersion,
 			createReverseQueryIndex,
 			createReverseCheckIndex,
-		}
-		for _, stmt := range statements {
-			_, err := tx.Exec(ctx, stmt)
-			if err != nil {
-				return err
-			}
-		}
-
-		return tx.Commit(ctx)
+		)
 	}); err != nil {
 		panic("failed to register migration: " + err.Error())
 	}
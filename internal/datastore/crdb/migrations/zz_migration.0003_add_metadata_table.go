@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	createMetadataTable = `CREATE TABLE metadata (
+    unique_id VARCHAR PRIMARY KEY
+);`
+
+	// The unique ID is generated when the table is created, so that it identifies the data of
+	// the datastore for as long as it exists, including when it is restored from a backup
+	// into another cluster.
+	insertUniqueID = `INSERT INTO metadata (unique_id) VALUES ('%s');`
+)
+
+func init() {
+	if err := CRDBMigrations.Register("add-metadata-table", "add-transactions-table", func(apd *CRDBDriver) error {
+		return apd.execInTransaction(context.Background(), createMetadataTable, fmt.Sprintf(insertUniqueID, uuid.NewString()))
+	}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/alecthomas/units"
+	"github.com/benbjohnson/clock"
 
 	"github.com/authzed/spicedb/internal/datastore/common"
 )
@@ -15,6 +16,9 @@ type crdbOptions struct {
 	minOpenConns    *int
 	maxOpenConns    *int
 
+	connPingInterval      time.Duration
+	enablePrometheusStats bool
+
 	watchBufferLength           uint16
 	revisionQuantization        time.Duration
 	followerReadDelay           time.Duration
@@ -22,12 +26,20 @@ type crdbOptions struct {
 	gcWindow                    time.Duration
 	maxRetries                  int
 	splitAtEstimatedQuerySize   units.Base2Bytes
+	maxUsersetsPerQuery         int
+	splitAtParameterCount       int
+	queryHints                  common.QueryHints
+	queryTimeout                time.Duration
+	slowQueryLog                common.SlowQueryLog
 	overlapStrategy             string
 	overlapKey                  string
+	timeSource                  clock.Clock
+	maxClockSkew                time.Duration
 }
 
 const (
 	errQuantizationTooLarge = "revision quantization (%s) must be less than GC window (%s)"
+	errTooManyParameters    = "split parameter count (%d) must be at most %d"
 
 	overlapStrategyPrefix   = "prefix"
 	overlapStrategyStatic   = "static"
@@ -36,6 +48,7 @@ const (
 	defaultRevisionQuantization        = 5 * time.Second
 	defaultFollowerReadDelay           = 0 * time.Second
 	defaultMaxRevisionStalenessPercent = 0.1
+	defaultMaxClockSkew                = 500 * time.Millisecond
 	defaultWatchBufferLength           = 128
 
 	defaultMaxRetries      = 50
@@ -55,9 +68,12 @@ func generateConfig(options []Option) (crdbOptions, error) {
 		followerReadDelay:           defaultFollowerReadDelay,
 		maxRevisionStalenessPercent: defaultMaxRevisionStalenessPercent,
 		splitAtEstimatedQuerySize:   common.DefaultSplitAtEstimatedQuerySize,
+		splitAtParameterCount:       common.DefaultSplitAtParameterCount,
 		maxRetries:                  defaultMaxRetries,
 		overlapKey:                  defaultOverlapKey,
 		overlapStrategy:             defaultOverlapStrategy,
+		timeSource:                  clock.New(),
+		maxClockSkew:                defaultMaxClockSkew,
 	}
 
 	for _, option := range options {
@@ -73,6 +89,14 @@ func generateConfig(options []Option) (crdbOptions, error) {
 		)
 	}
 
+	if computed.splitAtParameterCount > common.MaxStatementParameters {
+		return computed, fmt.Errorf(
+			errTooManyParameters,
+			computed.splitAtParameterCount,
+			common.MaxStatementParameters,
+		)
+	}
+
 	return computed, nil
 }
 
@@ -86,6 +110,68 @@ func SplitAtEstimatedQuerySize(splitAtEstimatedQuerySize units.Base2Bytes) Optio
 	}
 }
 
+// SplitAtParameterCount is the number of parameters bound to a query above
+// which it is split into two (or more) queries. It cannot exceed
+// `common.MaxStatementParameters`, the most the wire protocol allows.
+//
+// This value defaults to `common.DefaultSplitAtParameterCount`.
+func SplitAtParameterCount(count int) Option {
+	return func(po *crdbOptions) {
+		po.splitAtParameterCount = count
+	}
+}
+
+// MaxUsersetsPerQuery is the most usersets to which a single query is
+// filtered, beyond which it is split into two (or more) queries.
+//
+// This value defaults to zero, which places no limit on the usersets of a query.
+func MaxUsersetsPerQuery(count int) Option {
+	return func(po *crdbOptions) {
+		po.maxUsersetsPerQuery = count
+	}
+}
+
+// QueryHints are the planner hints to apply to generated queries, by query
+// shape. Hints are applied as index hints, e.g. `ix_relation_tuple_by_subject` becomes `relation_tuple@{FORCE_INDEX=ix_relation_tuple_by_subject}`.
+//
+// This value defaults to no hints.
+func QueryHints(hints common.QueryHints) Option {
+	return func(po *crdbOptions) {
+		po.queryHints = hints
+	}
+}
+
+// QueryTimeout is the default maximum amount of time a single tuple query may
+// run before it is canceled. A timeout specified on the query itself takes
+// precedence.
+//
+// This value defaults to having no timeout.
+func QueryTimeout(timeout time.Duration) Option {
+	return func(po *crdbOptions) {
+		po.queryTimeout = timeout
+	}
+}
+
+// SlowQueryThreshold is the duration at or above which a tuple query is logged,
+// along with its SQL and filters.
+//
+// This value defaults to zero, which disables slow query logging.
+func SlowQueryThreshold(threshold time.Duration) Option {
+	return func(po *crdbOptions) {
+		po.slowQueryLog.Threshold = threshold
+	}
+}
+
+// SlowQueryExplainSampleRate is the fraction, between 0 and 1, of logged slow
+// queries for which the query plan is captured with EXPLAIN and logged.
+//
+// This value defaults to zero.
+func SlowQueryExplainSampleRate(rate float64) Option {
+	return func(po *crdbOptions) {
+		po.slowQueryLog.ExplainSampleRate = rate
+	}
+}
+
 // ConnMaxIdleTime is the duration after which an idle connection will be
 // automatically closed by the health check.
 //
@@ -106,6 +192,26 @@ func ConnMaxLifetime(lifetime time.Duration) Option {
 	}
 }
 
+// ConnPingInterval is the interval at which idle connections in the connection
+// pool are actively pinged, closing and replacing those which fail.
+//
+// This value defaults to zero, which disables pinging.
+func ConnPingInterval(interval time.Duration) Option {
+	return func(po *crdbOptions) {
+		po.connPingInterval = interval
+	}
+}
+
+// EnablePrometheusStats enables Prometheus metrics provided by the connection
+// pool being used by the datastore.
+//
+// Prometheus metrics are disabled by default.
+func EnablePrometheusStats() Option {
+	return func(po *crdbOptions) {
+		po.enablePrometheusStats = true
+	}
+}
+
 // MinOpenConns is the minimum size of the connection pool.
 // The health check will increase the number of connections to this amount if
 // it had dropped below.
@@ -200,3 +306,24 @@ func OverlapKey(key string) Option {
 		po.overlapKey = key
 	}
 }
+
+// Clock is the source of time used to determine how long a quantized revision may
+// be reused.
+//
+// This value defaults to the system clock.
+func Clock(timeSource clock.Clock) Option {
+	return func(po *crdbOptions) {
+		po.timeSource = timeSource
+	}
+}
+
+// MaxClockSkew is the difference between the time of the CockroachDB cluster and the wall
+// clock of the server beyond which the wall clock is not trusted to judge for how long a
+// quantized revision may be reused. Zero disables the detection of skew.
+//
+// This value defaults to 500 milliseconds, the default maximum offset of CockroachDB.
+func MaxClockSkew(threshold time.Duration) Option {
+	return func(po *crdbOptions) {
+		po.maxClockSkew = threshold
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	sq "github.com/Masterminds/squirrel"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/jackc/pgx/v4"
 
@@ -14,6 +15,7 @@ import (
 
 const (
 	querySetTransactionTime = "SET TRANSACTION AS OF SYSTEM TIME %s"
+	queryForceIndexTable    = "%s@{FORCE_INDEX=%s}"
 )
 
 var queryTuples = psql.Select(
@@ -34,6 +36,10 @@ var schema = common.SchemaInformation{
 	ColUsersetRelation:  colUsersetRelation,
 }
 
+// revisionFilter limits tuple queries to the rows which were live at the revision, by reading
+// them as of the time of the revision.
+var revisionFilter = common.RevisionFilter{PrepareTransaction: prepareTransaction}
+
 func (cds *crdbDatastore) QueryTuples(
 	ctx context.Context,
 	filter *v1.RelationshipFilter,
@@ -57,17 +63,30 @@ func (cds *crdbDatastore) QueryTuples(
 
 	queryOpts := options.NewQueryOptionsWithOptions(opts...)
 
+	if queryOpts.AfterResourceID != "" {
+		qBuilder = qBuilder.FilterToResourceIDsAfter(queryOpts.AfterResourceID)
+	}
+
+	qBuilder = qBuilder.WithHint(common.QueryShapeQueryTuples, cds.queryHints, indexQueryHinter)
+
 	ctq := common.TupleQuerySplitter{
 		Conn:                      cds.conn,
-		PrepareTransaction:        prepareTransaction,
-		SplitAtEstimatedQuerySize: common.DefaultSplitAtEstimatedQuerySize,
+		RevisionFilter:            revisionFilter,
+		SplitAtEstimatedQuerySize: cds.splitAtEstimatedQuerySize,
+		SplitAtParameterCount:     cds.splitAtParameterCount,
+		MaxUsersetsPerQuery:       cds.maxUsersetsPerQuery,
 
 		FilteredQueryBuilder: qBuilder,
 		Revision:             revision,
+		RevisionValidator:    cds.revisionValidator,
 		Limit:                queryOpts.Limit,
 		Usersets:             queryOpts.Usersets,
+		Order:                queryOpts.Order,
+		Timeout:              common.QueryTimeout(queryOpts.Timeout, cds.queryTimeout),
+		SlowQueryLog:         cds.slowQueryLog,
 
 		Tracer:    tracer,
+		Engine:    engineName,
 		DebugName: "QueryTuples",
 	}
 
@@ -91,17 +110,25 @@ func (cds *crdbDatastore) ReverseQueryTuples(
 			FilterToRelation(queryOpts.ResRelation.Relation)
 	}
 
+	qBuilder = qBuilder.WithHint(common.QueryShapeReverseQueryTuples, cds.queryHints, indexQueryHinter)
+
 	ctq := common.TupleQuerySplitter{
 		Conn:                      cds.conn,
-		PrepareTransaction:        nil,
-		SplitAtEstimatedQuerySize: common.DefaultSplitAtEstimatedQuerySize,
+		RevisionFilter:            revisionFilter,
+		SplitAtEstimatedQuerySize: cds.splitAtEstimatedQuerySize,
+		SplitAtParameterCount:     cds.splitAtParameterCount,
+		MaxUsersetsPerQuery:       cds.maxUsersetsPerQuery,
 
 		FilteredQueryBuilder: qBuilder,
 		Revision:             revision,
+		RevisionValidator:    cds.revisionValidator,
 		Limit:                queryOpts.ReverseLimit,
-		Usersets:             nil,
+		SubjectIDs:           queryOpts.SubjectIDs,
+		Timeout:              common.QueryTimeout(queryOpts.ReverseTimeout, cds.queryTimeout),
+		SlowQueryLog:         cds.slowQueryLog,
 
 		Tracer:    tracer,
+		Engine:    engineName,
 		DebugName: "ReverseQueryTuples",
 	}
 
@@ -113,3 +140,9 @@ func prepareTransaction(ctx context.Context, tx pgx.Tx, revision datastore.Revis
 	_, err := tx.Exec(ctx, setTxTime)
 	return err
 }
+
+// indexQueryHinter applies hints by forcing the use of the named index on the
+// tuple table.
+func indexQueryHinter(query sq.SelectBuilder, hint string) sq.SelectBuilder {
+	return query.From(fmt.Sprintf(queryForceIndexTable, tableTuple, hint))
+}
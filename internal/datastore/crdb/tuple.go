@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -14,11 +15,16 @@ import (
 
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/redact"
 )
 
 const (
 	errUnableToWriteTuples  = "unable to write tuples: %w"
 	errUnableToDeleteTuples = "unable to delete tuples: %w"
+
+	// parametersPerRelationship is the number of parameters bound to a write statement for
+	// each relationship, both for an inserted row and for a deleted one.
+	parametersPerRelationship = 6
 )
 
 var (
@@ -60,29 +66,6 @@ var (
 	queryTupleExists = psql.Select(colObjectID).From(tableTuple)
 )
 
-func selectQueryForFilter(filter *v1.RelationshipFilter) sq.SelectBuilder {
-	query := queryTupleExists.Where(sq.Eq{colNamespace: filter.ResourceType})
-
-	if filter.OptionalResourceId != "" {
-		query = query.Where(sq.Eq{colObjectID: filter.OptionalResourceId})
-	}
-	if filter.OptionalRelation != "" {
-		query = query.Where(sq.Eq{colRelation: filter.OptionalRelation})
-	}
-
-	if subjectFilter := filter.OptionalSubjectFilter; subjectFilter != nil {
-		query = query.Where(sq.Eq{colUsersetNamespace: subjectFilter.SubjectType})
-		if subjectFilter.OptionalSubjectId != "" {
-			query = query.Where(sq.Eq{colUsersetObjectID: subjectFilter.OptionalSubjectId})
-		}
-		if relationFilter := subjectFilter.OptionalRelation; relationFilter != nil {
-			query = query.Where(sq.Eq{colUsersetRelation: stringz.DefaultEmpty(relationFilter.Relation, datastore.Ellipsis)})
-		}
-	}
-
-	return query
-}
-
 func (cds *crdbDatastore) checkPreconditions(ctx context.Context, tx pgx.Tx, keySet keySet, preconditions []*v1.Precondition) error {
 	ctx, span := tracer.Start(ctx, "checkPreconditions")
 	defer span.End()
@@ -94,7 +77,10 @@ func (cds *crdbDatastore) checkPreconditions(ctx context.Context, tx pgx.Tx, key
 		}
 		switch precond.Operation {
 		case v1.Precondition_OPERATION_MUST_NOT_MATCH, v1.Precondition_OPERATION_MUST_MATCH:
-			sql, args, err := selectQueryForFilter(precond.Filter).Limit(1).ToSql()
+			sql, args, err := common.NewSchemaQueryFilterer(schema, queryTupleExists).
+				FilterToRelationshipFilter(precond.Filter).
+				Limit(1).
+				ToSql()
 			if err != nil {
 				return err
 			}
@@ -125,20 +111,24 @@ func (cds *crdbDatastore) checkPreconditions(ctx context.Context, tx pgx.Tx, key
 func (cds *crdbDatastore) WriteTuples(ctx context.Context, preconditions []*v1.Precondition, mutations []*v1.RelationshipUpdate) (datastore.Revision, error) {
 	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "WriteTuples")
 	defer span.End()
+
+	statementCount := 0
+	defer func(start time.Time) {
+		common.ObserveOperationLatency("WriteTuples", engineName, statementCount, start)
+	}(time.Now())
 	var nowRevision datastore.Revision
 
 	if err := cds.execute(ctx, cds.conn, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		// The transaction may be retried, so only the statements of the final attempt
+		// are counted.
+		statementCount = 0
+
 		keySet := newKeySet()
 		if err := cds.checkPreconditions(ctx, tx, keySet, preconditions); err != nil {
 			return err
 		}
-		bulkWrite := queryWriteTuple
-		var bulkWriteCount int64
-
-		bulkTouch := queryTouchTuple
-		var bulkTouchCount int64
 
-		// Process the actual updates
+		var creates, touches, deletes []*v1.Relationship
 		for _, mutation := range mutations {
 			rel := mutation.Relationship
 			cds.AddOverlapKey(keySet, rel.Resource.ObjectType)
@@ -146,46 +136,45 @@ func (cds *crdbDatastore) WriteTuples(ctx context.Context, preconditions []*v1.P
 
 			switch mutation.Operation {
 			case v1.RelationshipUpdate_OPERATION_TOUCH:
-				bulkTouch = bulkTouch.Values(
-					rel.Resource.ObjectType,
-					rel.Resource.ObjectId,
-					rel.Relation,
-					rel.Subject.Object.ObjectType,
-					rel.Subject.Object.ObjectId,
-					stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
-				)
-				bulkTouchCount++
+				touches = append(touches, rel)
 			case v1.RelationshipUpdate_OPERATION_CREATE:
-				bulkWrite = bulkWrite.Values(
-					rel.Resource.ObjectType,
-					rel.Resource.ObjectId,
-					rel.Relation,
-					rel.Subject.Object.ObjectType,
-					rel.Subject.Object.ObjectId,
-					stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
-				)
-				bulkWriteCount++
+				creates = append(creates, rel)
 			case v1.RelationshipUpdate_OPERATION_DELETE:
-				sql, args, err := queryDeleteTuples.Where(exactRelationshipClause(rel)).ToSql()
-				if err != nil {
-					return err
-				}
-
-				if _, err := tx.Exec(ctx, sql, args...); err != nil {
-					return err
-				}
+				deletes = append(deletes, rel)
 			default:
 				log.Ctx(ctx).Error().Stringer("operation", mutation.Operation).Msg("unknown operation type")
 				return fmt.Errorf("unknown mutation operation: %s", mutation.Operation)
 			}
 		}
 
-		bulkUpdateQueries := []sq.InsertBuilder{}
-		if bulkWriteCount > 0 {
-			bulkUpdateQueries = append(bulkUpdateQueries, bulkWrite)
+		splitter := common.WriteSplitter{
+			SplitAtEstimatedQuerySize: cds.splitAtEstimatedQuerySize,
+			ParametersPerRelationship: parametersPerRelationship,
+		}
+
+		for _, chunk := range splitter.Split(deletes) {
+			clauses := make(sq.Or, 0, len(chunk))
+			for _, rel := range chunk {
+				clauses = append(clauses, exactRelationshipClause(rel))
+			}
+
+			sql, args, err := queryDeleteTuples.Where(clauses).ToSql()
+			if err != nil {
+				return err
+			}
+
+			statementCount++
+			if _, err := tx.Exec(ctx, sql, args...); err != nil {
+				return err
+			}
+		}
+
+		var bulkUpdateQueries []sq.InsertBuilder
+		for _, chunk := range splitter.Split(creates) {
+			bulkUpdateQueries = append(bulkUpdateQueries, bulkInsert(queryWriteTuple, chunk))
 		}
-		if bulkTouchCount > 0 {
-			bulkUpdateQueries = append(bulkUpdateQueries, bulkTouch)
+		for _, chunk := range splitter.Split(touches) {
+			bulkUpdateQueries = append(bulkUpdateQueries, bulkInsert(queryTouchTuple, chunk))
 		}
 
 		for _, updateQuery := range bulkUpdateQueries {
@@ -194,6 +183,7 @@ func (cds *crdbDatastore) WriteTuples(ctx context.Context, preconditions []*v1.P
 				return err
 			}
 
+			statementCount++
 			if err := tx.QueryRow(ctx, sql, args...).Scan(&nowRevision); err != nil {
 				return err
 			}
@@ -223,6 +213,21 @@ func (cds *crdbDatastore) WriteTuples(ctx context.Context, preconditions []*v1.P
 	return nowRevision, nil
 }
 
+// bulkInsert adds a row for each of the relationships to the insert query.
+func bulkInsert(query sq.InsertBuilder, relationships []*v1.Relationship) sq.InsertBuilder {
+	for _, rel := range relationships {
+		query = query.Values(
+			rel.Resource.ObjectType,
+			rel.Resource.ObjectId,
+			rel.Relation,
+			rel.Subject.Object.ObjectType,
+			rel.Subject.Object.ObjectId,
+			stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
+		)
+	}
+	return query
+}
+
 func exactRelationshipClause(r *v1.Relationship) sq.Eq {
 	return sq.Eq{
 		colNamespace:        r.Resource.ObjectType,
@@ -237,6 +242,7 @@ func exactRelationshipClause(r *v1.Relationship) sq.Eq {
 func (cds *crdbDatastore) DeleteRelationships(ctx context.Context, preconditions []*v1.Precondition, filter *v1.RelationshipFilter) (datastore.Revision, error) {
 	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "DeleteRelationships")
 	defer span.End()
+	defer common.ObserveOperationLatency("DeleteRelationships", engineName, 1, time.Now())
 	var nowRevision datastore.Revision
 
 	if err := cds.execute(ctx, cds.conn, pgx.TxOptions{}, func(tx pgx.Tx) error {
@@ -247,28 +253,28 @@ func (cds *crdbDatastore) DeleteRelationships(ctx context.Context, preconditions
 
 		// Add clauses for the ResourceFilter
 		query := queryDeleteTuples.Suffix(queryReturningTimestamp).Where(sq.Eq{colNamespace: filter.ResourceType})
-		tracerAttributes := []attribute.KeyValue{common.ObjNamespaceNameKey.String(filter.ResourceType)}
+		tracerAttributes := []attribute.KeyValue{common.ObjNamespaceNameKey.String(redact.Type(filter.ResourceType))}
 		if filter.OptionalResourceId != "" {
 			query = query.Where(sq.Eq{colObjectID: filter.OptionalResourceId})
-			tracerAttributes = append(tracerAttributes, common.ObjIDKey.String(filter.OptionalResourceId))
+			tracerAttributes = append(tracerAttributes, common.ObjIDKey.String(redact.ID(filter.OptionalResourceId)))
 		}
 		if filter.OptionalRelation != "" {
 			query = query.Where(sq.Eq{colRelation: filter.OptionalRelation})
-			tracerAttributes = append(tracerAttributes, common.ObjRelationNameKey.String(filter.OptionalRelation))
+			tracerAttributes = append(tracerAttributes, common.ObjRelationNameKey.String(redact.Relation(filter.OptionalRelation)))
 		}
 		cds.AddOverlapKey(keySet, filter.ResourceType)
 
 		// Add clauses for the SubjectFilter
 		if subjectFilter := filter.OptionalSubjectFilter; subjectFilter != nil {
 			query = query.Where(sq.Eq{colUsersetNamespace: subjectFilter.SubjectType})
-			tracerAttributes = append(tracerAttributes, common.SubNamespaceNameKey.String(subjectFilter.SubjectType))
+			tracerAttributes = append(tracerAttributes, common.SubNamespaceNameKey.String(redact.Type(subjectFilter.SubjectType)))
 			if subjectFilter.OptionalSubjectId != "" {
 				query = query.Where(sq.Eq{colUsersetObjectID: subjectFilter.OptionalSubjectId})
-				tracerAttributes = append(tracerAttributes, common.SubObjectIDKey.String(subjectFilter.OptionalSubjectId))
+				tracerAttributes = append(tracerAttributes, common.SubObjectIDKey.String(redact.ID(subjectFilter.OptionalSubjectId)))
 			}
 			if relationFilter := subjectFilter.OptionalRelation; relationFilter != nil {
 				query = query.Where(sq.Eq{colUsersetRelation: stringz.DefaultEmpty(relationFilter.Relation, datastore.Ellipsis)})
-				tracerAttributes = append(tracerAttributes, common.SubRelationNameKey.String(relationFilter.Relation))
+				tracerAttributes = append(tracerAttributes, common.SubRelationNameKey.String(redact.Relation(relationFilter.Relation)))
 			}
 			cds.AddOverlapKey(keySet, subjectFilter.SubjectType)
 		}
@@ -11,11 +11,13 @@ import (
 	"github.com/shopspring/decimal"
 
 	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
 )
 
 const queryChangefeed = "EXPERIMENTAL CHANGEFEED FOR %s WITH updated, cursor = '%s', resolved = '1s';"
 
-func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
+func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	watchOpts := options.NewWatchOptionsWithOptions(opts...)
 	updates := make(chan *datastore.RevisionChanges, cds.watchBufferLength)
 	errs := make(chan error, 1)
 
@@ -24,6 +26,7 @@ func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Rev
 	go func() {
 		defer close(updates)
 		defer close(errs)
+		defer datastore.WatchBufferQueue.Track(func() int { return len(updates) }, cap(updates))()
 
 		pendingChanges := make(map[string]*datastore.RevisionChanges)
 
@@ -86,6 +89,12 @@ func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Rev
 					return toEmit[i].Revision.LessThan(toEmit[j].Revision)
 				})
 
+				// All changes at or before the resolved timestamp have been received, so it is
+				// reported as progress even if there were none.
+				if len(toEmit) == 0 || toEmit[len(toEmit)-1].Revision.LessThan(resolved) {
+					toEmit = append(toEmit, &datastore.RevisionChanges{Revision: resolved})
+				}
+
 				for _, change := range toEmit {
 					select {
 					case updates <- change:
@@ -142,7 +151,125 @@ func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Rev
 				}
 				pendingChanges[changeDetails.Updated] = pending
 			}
-			pending.Changes = append(pending.Changes, oneChange)
+
+			// The changefeed cannot be filtered, so changes are filtered as they arrive. The
+			// revisions of filtered out changes are still sent, to report progress.
+			if datastore.TupleMatchesAnyFilter(oneChange.Tuple, watchOpts.Filters) {
+				pending.Changes = append(pending.Changes, oneChange)
+			}
+		}
+		if changes.Err() != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				errs <- datastore.NewWatchCanceledErr()
+			} else {
+				errs <- changes.Err()
+			}
+			return
+		}
+	}()
+	return updates, errs
+}
+
+func (cds *crdbDatastore) WatchNamespaces(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.NamespaceChanges, <-chan error) {
+	updates := make(chan *datastore.NamespaceChanges, cds.watchBufferLength)
+	errs := make(chan error, 1)
+
+	interpolated := fmt.Sprintf(queryChangefeed, tableNamespace, afterRevision)
+
+	go func() {
+		defer close(updates)
+		defer close(errs)
+		defer datastore.NamespaceWatchBufferQueue.Track(func() int { return len(updates) }, cap(updates))()
+
+		pendingChanges := make(map[string][]decimal.Decimal)
+
+		changes, err := cds.conn.Query(ctx, interpolated)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				errs <- datastore.NewWatchCanceledErr()
+			} else {
+				errs <- err
+			}
+			return
+		}
+
+		// We call Close async here because it can be slow and blocks closing the channels.
+		defer func() { go changes.Close() }()
+
+		for changes.Next() {
+			var unused interface{}
+			var changeJSON []byte
+			var primaryKeyValuesJSON []byte
+
+			if err := changes.Scan(&unused, &primaryKeyValuesJSON, &changeJSON); err != nil {
+				if errors.Is(ctx.Err(), context.Canceled) {
+					errs <- datastore.NewWatchCanceledErr()
+				} else {
+					errs <- err
+				}
+				return
+			}
+
+			var changeDetails struct {
+				Resolved string
+				Updated  string
+			}
+			if err := json.Unmarshal(changeJSON, &changeDetails); err != nil {
+				errs <- err
+				return
+			}
+
+			if changeDetails.Resolved != "" {
+				// All changes at or before the resolved timestamp have been received, so
+				// they can be reported along with the resolved revision.
+				resolved, err := decimal.NewFromString(changeDetails.Resolved)
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				toEmit := &datastore.NamespaceChanges{Revision: resolved}
+				for nsName, revisions := range pendingChanges {
+					var remaining []decimal.Decimal
+					for _, revision := range revisions {
+						if revision.GreaterThan(resolved) {
+							remaining = append(remaining, revision)
+						}
+					}
+
+					if len(remaining) < len(revisions) {
+						toEmit.Changed = append(toEmit.Changed, nsName)
+					}
+					if len(remaining) == 0 {
+						delete(pendingChanges, nsName)
+					} else {
+						pendingChanges[nsName] = remaining
+					}
+				}
+
+				select {
+				case updates <- toEmit:
+				default:
+					errs <- datastore.NewWatchDisconnectedErr()
+					return
+				}
+
+				continue
+			}
+
+			var pkValues [1]string
+			if err := json.Unmarshal(primaryKeyValuesJSON, &pkValues); err != nil {
+				errs <- err
+				return
+			}
+
+			revision, err := decimal.NewFromString(changeDetails.Updated)
+			if err != nil {
+				errs <- fmt.Errorf("malformed update timestamp: %w", err)
+				return
+			}
+
+			pendingChanges[pkValues[0]] = append(pendingChanges[pkValues[0]], revision)
 		}
 		if changes.Err() != nil {
 			if errors.Is(ctx.Err(), context.Canceled) {
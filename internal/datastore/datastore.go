@@ -2,6 +2,8 @@ package datastore
 
 import (
 	"context"
+	"reflect"
+	"time"
 
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -18,6 +20,21 @@ const Ellipsis = "..."
 type RevisionChanges struct {
 	Revision Revision
 	Changes  []*v0.RelationTupleUpdate
+
+	// Metadata describes the origin of the transaction, if it was recorded by the
+	// writer and the datastore supports tracking it.
+	Metadata *TransactionMetadata
+}
+
+// NamespaceChanges represents the namespaces which changed in the transactions up to
+// and including a revision.
+type NamespaceChanges struct {
+	// Revision is the revision through which all namespace changes have been reported.
+	Revision Revision
+
+	// Changed holds the names of the namespaces written or deleted since the previously
+	// reported revision. It is empty for events which only report progress.
+	Changed []string
 }
 
 // Datastore represents tuple access for a single namespace.
@@ -43,8 +60,10 @@ type Datastore interface {
 
 	// Watch notifies the caller about all changes to tuples.
 	//
-	// All events following afterRevision will be sent to the caller.
-	Watch(ctx context.Context, afterRevision Revision) (<-chan *RevisionChanges, <-chan error)
+	// All events following afterRevision will be sent to the caller. When the watch is
+	// filtered, only the changes of matching tuples are sent, and events without any
+	// changes may be sent to report that no matching tuples changed up to their revision.
+	Watch(ctx context.Context, afterRevision Revision, opts ...options.WatchOptionsOption) (<-chan *RevisionChanges, <-chan error)
 
 	// WriteNamespace takes a proto namespace definition and persists it,
 	// returning the version of the namespace that was created.
@@ -69,6 +88,208 @@ type Datastore interface {
 	Close() error
 }
 
+// Unwrapper is implemented by datastores which wrap another datastore, such as proxies,
+// allowing the optional interfaces of the wrapped datastore to be found.
+type Unwrapper interface {
+	// Unwrap returns the wrapped datastore.
+	Unwrap() Datastore
+}
+
+// As finds the first datastore in the chain of datastores wrapped by ds, starting with ds
+// itself, which implements the interface to which target points, and if one is found, sets
+// target to it and returns true. Wrapping datastores which restrict an optional interface
+// of the datastore they wrap implement it themselves, so that they are found first.
+func As(ds Datastore, target interface{}) bool {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Interface {
+		panic("datastore: target must be a non-nil pointer to an interface")
+	}
+	targetType := val.Type().Elem()
+
+	for ds != nil {
+		if reflect.TypeOf(ds).Implements(targetType) {
+			val.Elem().Set(reflect.ValueOf(ds))
+			return true
+		}
+
+		unwrapper, ok := ds.(Unwrapper)
+		if !ok {
+			return false
+		}
+		ds = unwrapper.Unwrap()
+	}
+	return false
+}
+
+// NamespaceWatcher is implemented by datastores which can report changes to namespace
+// definitions.
+type NamespaceWatcher interface {
+	// WatchNamespaces notifies the caller about all changes to namespace definitions.
+	//
+	// All changes following afterRevision will be sent to the caller. Events without
+	// any changes are also sent periodically, to report that no namespaces changed up to
+	// their revision. Datastores which isolate tenants report the changes of all tenants.
+	WatchNamespaces(ctx context.Context, afterRevision Revision) (<-chan *NamespaceChanges, <-chan error)
+}
+
+// GarbageCollector is implemented by datastores which garbage collect expired
+// revisions themselves, allowing a collection pass to be explicitly triggered.
+type GarbageCollector interface {
+	// CollectGarbage performs a single garbage collection pass, returning the number of
+	// relationships and transactions which were removed.
+	CollectGarbage(ctx context.Context) (relationshipsRemoved int64, transactionsRemoved int64, err error)
+}
+
+// TupleIndex describes an index over the stored relationships.
+type TupleIndex struct {
+	Name string
+
+	// Columns are the indexed columns, in index order.
+	Columns []string
+}
+
+// IndexInspector is implemented by datastores which can report the indexes over the
+// stored relationships, allowing missing indexes to be recommended.
+type IndexInspector interface {
+	// TupleIndexes returns the indexes which currently exist over the stored relationships.
+	TupleIndexes(ctx context.Context) ([]TupleIndex, error)
+
+	// CreateTupleIndexDDL returns the statement which creates an index with the
+	// specified name over the specified columns of the stored relationships.
+	CreateTupleIndexDDL(name string, columns []string) string
+}
+
+// DuplicateTuple is a relationship which is stored in more than one row with overlapping
+// liveness.
+type DuplicateTuple struct {
+	Tuple *v0.RelationTuple
+
+	// Tenant is the tenant to which the tuple belongs, in datastores which isolate tenants.
+	Tenant string
+
+	// Rows is the number of rows which overlap.
+	Rows int
+}
+
+// AuditedTuple is a live relationship along with the origin of the transaction which
+// wrote it.
+type AuditedTuple struct {
+	Tuple *v0.RelationTuple
+
+	// WrittenAt is the time at which the transaction which wrote the relationship was
+	// committed.
+	WrittenAt time.Time
+
+	// Metadata describes the origin of the transaction, if it was recorded by the writer.
+	Metadata *TransactionMetadata
+}
+
+// TupleAuditor is implemented by datastores which record the transaction which wrote each
+// relationship, allowing the origin of a permission to be determined.
+type TupleAuditor interface {
+	// AuditTuples returns up to limit relationships matching the filter which are live at
+	// the specified revision, along with the origin of the transaction which wrote each.
+	AuditTuples(ctx context.Context, filter *v1.RelationshipFilter, revision Revision, limit uint64) ([]AuditedTuple, error)
+}
+
+// TupleDeduplicator is implemented by datastores which store the history of relationships as
+// rows with liveness ranges, which can contain duplicate rows as the result of past bugs or
+// manual edits.
+type TupleDeduplicator interface {
+	// FindDuplicateTuples returns the relationships which are stored in more than one row
+	// with overlapping liveness.
+	FindDuplicateTuples(ctx context.Context) ([]DuplicateTuple, error)
+
+	// RepairDuplicateTuples collapses each set of overlapping rows into a single row which
+	// is live over their combined liveness, within a single transaction, returning the
+	// relationships which were repaired.
+	RepairDuplicateTuples(ctx context.Context) ([]DuplicateTuple, error)
+}
+
+// PoolDiagnostics describes the use of a pool of connections to a datastore.
+type PoolDiagnostics struct {
+	Name          string
+	MaxConns      int32
+	TotalConns    int32
+	AcquiredConns int32
+	IdleConns     int32
+
+	// Saturation is the fraction of the maximum number of connections which are acquired.
+	Saturation float64
+
+	// AverageAcquireWait is the average amount of time for which the acquisition of a
+	// connection has waited.
+	AverageAcquireWait time.Duration
+}
+
+// Diagnostics describes the state of a datastore, for debugging an unhealthy server.
+type Diagnostics struct {
+	// MigrationRevision is the migration revision of the datastore, and
+	// ExpectedMigrationRevision the one which the server requires.
+	MigrationRevision         string
+	ExpectedMigrationRevision string
+
+	// ReplicationLag is the longest amount of time by which a replica of the datastore
+	// trails it, if known.
+	ReplicationLag time.Duration
+
+	// GCLag is the amount of time for which the oldest revision has been retained past the
+	// garbage collection window, if the datastore garbage collects revisions itself.
+	GCLag time.Duration
+
+	Pools []PoolDiagnostics
+}
+
+// Diagnoser is implemented by datastores which can describe their state, allowing an
+// unhealthy server to be debugged without access to its datastore.
+type Diagnoser interface {
+	// Diagnostics returns a description of the current state of the datastore.
+	Diagnostics(ctx context.Context) (Diagnostics, error)
+}
+
+// BulkLoader is implemented by datastores which can load relationships in bulk faster than
+// they can write them, for importing relationships into a datastore.
+type BulkLoader interface {
+	// BulkLoad creates the relationships, none of which may already exist, within a single
+	// transaction, returning the revision at which they were created.
+	BulkLoad(ctx context.Context, relationships []*v1.Relationship) (Revision, error)
+}
+
+// BulkLoad creates the relationships, none of which may already exist, within a single
+// transaction, with the datastore's bulk loader if it has one, or by writing them otherwise.
+func BulkLoad(ctx context.Context, ds Datastore, relationships []*v1.Relationship) (Revision, error) {
+	var loader BulkLoader
+	if As(ds, &loader) {
+		return loader.BulkLoad(ctx, relationships)
+	}
+
+	mutations := make([]*v1.RelationshipUpdate, 0, len(relationships))
+	for _, rel := range relationships {
+		mutations = append(mutations, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+			Relationship: rel,
+		})
+	}
+	return ds.WriteTuples(ctx, nil, mutations)
+}
+
+// Identifier is implemented by datastores which have a unique ID, persisted along with their
+// data, which distinguishes their revisions from those of any other datastore, such as one
+// into which the same relationships were imported.
+type Identifier interface {
+	// UniqueID returns the unique ID of the datastore.
+	UniqueID(ctx context.Context) (string, error)
+}
+
+// UniqueID returns the unique ID of the datastore, or an empty ID if it has none.
+func UniqueID(ctx context.Context, ds Datastore) (string, error) {
+	var identifier Identifier
+	if As(ds, &identifier) {
+		return identifier.UniqueID(ctx)
+	}
+	return "", nil
+}
+
 // GraphDatastore is a subset of the datastore interface that is passed to
 // graph resolvers.
 type GraphDatastore interface {
@@ -93,6 +314,24 @@ type GraphDatastore interface {
 	CheckRevision(ctx context.Context, revision Revision) error
 }
 
+// SizeHinter is implemented by TupleIterators which can estimate the number of tuples
+// remaining in them, allowing their consumers to choose how to process the tuples.
+type SizeHinter interface {
+	// SizeHint returns the estimated number of remaining tuples, and whether the estimate
+	// is exact.
+	SizeHint() (count int, exact bool)
+}
+
+// SizeHint returns the estimated number of tuples remaining in the iterator and whether the
+// estimate is exact, or false for both if the iterator provides no estimate.
+func SizeHint(iter TupleIterator) (count int, ok bool) {
+	hinter, isHinter := iter.(SizeHinter)
+	if !isHinter {
+		return 0, false
+	}
+	return hinter.SizeHint()
+}
+
 // TupleIterator is an iterator over matched tuples.
 type TupleIterator interface {
 	// Next returns the next tuple in the result set.
@@ -2,9 +2,12 @@ package datastore
 
 import (
 	"fmt"
+	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/rs/zerolog"
+
+	"github.com/authzed/spicedb/internal/redact"
 )
 
 // ErrNamespaceNotFound occurs when a namespace was not found.
@@ -45,6 +48,51 @@ type ErrWatchCanceled struct{ error }
 // read-only mode.
 type ErrReadOnly struct{ error }
 
+// ErrMaintenanceMode is returned when the operation cannot be completed because the
+// datastore has been placed into maintenance mode.
+type ErrMaintenanceMode struct{ error }
+
+// ErrQueryTimeout is returned when a query did not complete within its allotted
+// statement timeout.
+type ErrQueryTimeout struct {
+	error
+	timeout time.Duration
+}
+
+// Timeout is the statement timeout which was exceeded.
+func (eqt ErrQueryTimeout) Timeout() time.Duration {
+	return eqt.timeout
+}
+
+// MarshalZerologObject implements zerolog object marshalling.
+func (eqt ErrQueryTimeout) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("error", eqt.Error()).Dur("timeout", eqt.timeout)
+}
+
+// ErrStaleRevision is returned when the current revision could not be determined because
+// the datastore is unavailable, but a previously determined revision can still be used by
+// callers which tolerate stale results.
+type ErrStaleRevision struct {
+	error
+	revision Revision
+	age      time.Duration
+}
+
+// StaleRevision is the most recently determined revision.
+func (esr ErrStaleRevision) StaleRevision() Revision {
+	return esr.revision
+}
+
+// Age is the amount of time since the stale revision was determined.
+func (esr ErrStaleRevision) Age() time.Duration {
+	return esr.age
+}
+
+// MarshalZerologObject implements zerolog object marshalling.
+func (esr ErrStaleRevision) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("error", esr.Error()).Stringer("revision", esr.revision).Dur("age", esr.age)
+}
+
 // InvalidRevisionReason is the reason the revision could not be used.
 type InvalidRevisionReason int
 
@@ -67,6 +115,10 @@ type ErrInvalidRevision struct {
 	error
 	revision Revision
 	reason   InvalidRevisionReason
+
+	oldestValid Revision
+	newestValid Revision
+	hasWindow   bool
 }
 
 // InvalidRevision is the revision that failed.
@@ -79,8 +131,18 @@ func (eri ErrInvalidRevision) Reason() InvalidRevisionReason {
 	return eri.reason
 }
 
+// ValidWindow is the window of revisions which were valid when the revision was
+// checked, if known.
+func (eri ErrInvalidRevision) ValidWindow() (oldest Revision, newest Revision, ok bool) {
+	return eri.oldestValid, eri.newestValid, eri.hasWindow
+}
+
 // MarshalZerologObject implements zerolog object marshalling.
 func (eri ErrInvalidRevision) MarshalZerologObject(e *zerolog.Event) {
+	if eri.hasWindow {
+		e.Stringer("oldestValid", eri.oldestValid).Stringer("newestValid", eri.newestValid)
+	}
+
 	switch eri.reason {
 	case RevisionStale:
 		e.Str("error", eri.Error()).Str("reason", "stale")
@@ -104,7 +166,7 @@ func NewNamespaceNotFoundErr(nsName string) error {
 // NewPreconditionFailedErr constructs a new precondition failed error.
 func NewPreconditionFailedErr(precondition *v1.Precondition) error {
 	return ErrPreconditionFailed{
-		error:        fmt.Errorf("unable to satisfy write precondition `%s`", precondition),
+		error:        fmt.Errorf("unable to satisfy write precondition `%s %s`", precondition.Operation, redact.RelationshipFilter(precondition.Filter)),
 		precondition: precondition,
 	}
 }
@@ -131,6 +193,32 @@ func NewReadonlyErr() error {
 	}
 }
 
+// NewMaintenanceModeErr constructs an error for when a request has failed because
+// the datastore has been placed into maintenance mode.
+func NewMaintenanceModeErr() error {
+	return ErrMaintenanceMode{
+		error: fmt.Errorf("datastore is in maintenance mode"),
+	}
+}
+
+// NewQueryTimeoutErr constructs a new query timeout error.
+func NewQueryTimeoutErr(timeout time.Duration) error {
+	return ErrQueryTimeout{
+		error:   fmt.Errorf("query exceeded statement timeout of %s", timeout),
+		timeout: timeout,
+	}
+}
+
+// NewStaleRevisionErr constructs a new stale revision error, caused by the error which
+// prevented the current revision from being determined.
+func NewStaleRevisionErr(cause error, revision Revision, age time.Duration) error {
+	return ErrStaleRevision{
+		error:    fmt.Errorf("datastore unavailable, last revision determined %s ago: %w", age, cause),
+		revision: revision,
+		age:      age,
+	}
+}
+
 // NewInvalidRevisionErr constructs a new invalid revision error.
 func NewInvalidRevisionErr(revision Revision, reason InvalidRevisionReason) error {
 	switch reason {
@@ -156,3 +244,32 @@ func NewInvalidRevisionErr(revision Revision, reason InvalidRevisionReason) erro
 		}
 	}
 }
+
+// NewRevisionOutsideWindowErr constructs a new invalid revision error for a revision which
+// is older than the oldest valid revision, or newer than the newest.
+func NewRevisionOutsideWindowErr(revision Revision, oldestValid Revision, newestValid Revision) error {
+	reason := RevisionStale
+	message := "revision has expired"
+	if revision.GreaterThan(newestValid) {
+		reason = RevisionInFuture
+		message = "revision is for a future time"
+	}
+
+	return ErrInvalidRevision{
+		error:       fmt.Errorf("%s; revisions from %s through %s are valid", message, oldestValid, newestValid),
+		revision:    revision,
+		reason:      reason,
+		oldestValid: oldestValid,
+		newestValid: newestValid,
+		hasWindow:   true,
+	}
+}
+
+// CheckRevisionInWindow returns an invalid revision error if the revision is not within
+// the window of valid revisions, inclusive of both ends.
+func CheckRevisionInWindow(revision Revision, oldestValid Revision, newestValid Revision) error {
+	if revision.LessThan(oldestValid) || revision.GreaterThan(newestValid) {
+		return NewRevisionOutsideWindowErr(revision, oldestValid, newestValid)
+	}
+	return nil
+}
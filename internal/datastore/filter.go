@@ -0,0 +1,53 @@
+package datastore
+
+import (
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jzelinskie/stringz"
+)
+
+// TupleMatchesFilter returns whether the tuple matches the filter. The optional resource
+// ID of the filter is matched exactly.
+func TupleMatchesFilter(tpl *v0.RelationTuple, filter *v1.RelationshipFilter) bool {
+	onr := tpl.ObjectAndRelation
+	switch {
+	case filter.ResourceType != onr.Namespace:
+		return false
+	case filter.OptionalResourceId != "" && filter.OptionalResourceId != onr.ObjectId:
+		return false
+	case filter.OptionalRelation != "" && filter.OptionalRelation != onr.Relation:
+		return false
+	}
+
+	subjectFilter := filter.OptionalSubjectFilter
+	if subjectFilter == nil {
+		return true
+	}
+
+	subject := tpl.User.GetUserset()
+	switch {
+	case subjectFilter.SubjectType != subject.Namespace:
+		return false
+	case subjectFilter.OptionalSubjectId != "" && subjectFilter.OptionalSubjectId != subject.ObjectId:
+		return false
+	case subjectFilter.OptionalRelation != nil &&
+		stringz.DefaultEmpty(subjectFilter.OptionalRelation.Relation, Ellipsis) != subject.Relation:
+		return false
+	}
+	return true
+}
+
+// TupleMatchesAnyFilter returns whether the tuple matches any of the filters, which is
+// always the case when there are no filters.
+func TupleMatchesAnyFilter(tpl *v0.RelationTuple, filters []*v1.RelationshipFilter) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	for _, filter := range filters {
+		if TupleMatchesFilter(tpl, filter) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package datastore
+
+import "context"
+
+type uniqueIDCtxKeyType struct{}
+
+var uniqueIDKey uniqueIDCtxKeyType = struct{}{}
+
+// ContextWithUniqueID returns a new context which carries the unique ID of the datastore
+// serving the operations performed using it.
+func ContextWithUniqueID(ctx context.Context, uniqueID string) context.Context {
+	return context.WithValue(ctx, uniqueIDKey, uniqueID)
+}
+
+// UniqueIDFromContext returns the unique ID of the datastore carried by the context, or an
+// empty ID if none is present.
+func UniqueIDFromContext(ctx context.Context) string {
+	if uniqueID, ok := ctx.Value(uniqueIDKey).(string); ok {
+		return uniqueID
+	}
+	return ""
+}
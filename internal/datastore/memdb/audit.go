@@ -0,0 +1,75 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/hashicorp/go-memdb"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+const errUnableToAuditTuples = "unable to audit tuples: %w"
+
+// AuditTuples implements datastore.TupleAuditor.
+func (mds *memdbDatastore) AuditTuples(ctx context.Context, filter *v1.RelationshipFilter, revision datastore.Revision, limit uint64) ([]datastore.AuditedTuple, error) {
+	mds.RLock()
+	db := mds.db
+	mds.RUnlock()
+	if db == nil {
+		return nil, fmt.Errorf("memdb closed")
+	}
+
+	txn := db.Txn(false)
+	defer txn.Abort()
+
+	bestIterator, err := iteratorForFilter(txn, filter)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToAuditTuples, err)
+	}
+
+	matchingRelationshipsFilterFunc := filterFuncForFilters(
+		filter.ResourceType,
+		filter.OptionalResourceId,
+		filter.OptionalRelation,
+		filter.OptionalSubjectFilter,
+		nil,
+	)
+	filteredIterator := memdb.NewFilterIterator(bestIterator, matchingRelationshipsFilterFunc)
+	filteredAlive := memdb.NewFilterIterator(filteredIterator, filterToLiveObjects(revision))
+
+	var found []*relationship
+	for foundRaw := filteredAlive.Next(); foundRaw != nil; foundRaw = filteredAlive.Next() {
+		found = append(found, foundRaw.(*relationship))
+	}
+
+	// Transaction IDs are allocated in commit order, so sorting by them orders the
+	// relationships by the time at which they were written.
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].createdTxn < found[j].createdTxn
+	})
+	if uint64(len(found)) > limit {
+		found = found[:limit]
+	}
+
+	audited := make([]datastore.AuditedTuple, 0, len(found))
+	for _, rel := range found {
+		entry := datastore.AuditedTuple{Tuple: rel.RelationTuple()}
+
+		createdRaw, err := txn.First(tableTransaction, indexID, rel.createdTxn)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToAuditTuples, err)
+		}
+		if created, ok := createdRaw.(*transaction); ok {
+			entry.WrittenAt = time.Unix(0, int64(created.timestamp))
+			entry.Metadata = created.metadata
+		}
+
+		audited = append(audited, entry)
+	}
+
+	return audited, nil
+}
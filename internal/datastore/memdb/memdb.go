@@ -10,6 +10,8 @@ import (
 
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/benbjohnson/clock"
+	"github.com/google/uuid"
 	"github.com/hashicorp/go-memdb"
 	"github.com/jzelinskie/stringz"
 	"github.com/shopspring/decimal"
@@ -41,6 +43,7 @@ const (
 	indexDeletedTxn                 = "deletedTxn"
 
 	defaultWatchBufferLength = 128
+	defaultSnapshotInterval  = 5 * time.Minute
 
 	deletedTransactionID = ^uint64(0)
 
@@ -72,6 +75,7 @@ var _ hasLifetime = &namespace{}
 type transaction struct {
 	id        uint64
 	timestamp uint64
+	metadata  *datastore.TransactionMetadata
 }
 
 type relationship struct {
@@ -328,10 +332,13 @@ var schema = &memdb.DBSchema{
 type memdbDatastore struct {
 	sync.RWMutex
 	db                       *memdb.MemDB
+	persister                *persister
 	watchBufferLength        uint16
 	revisionFuzzingTimedelta time.Duration
 	gcWindowInverted         time.Duration
 	simulatedLatency         time.Duration
+	timeSource               clock.Clock
+	uniqueID                 string
 }
 
 // NewMemdbDatastore creates a new Datastore compliant datastore backed by memdb.
@@ -342,6 +349,55 @@ func NewMemdbDatastore(
 	revisionFuzzingTimedelta,
 	gcWindow time.Duration,
 	simulatedLatency time.Duration,
+) (datastore.Datastore, error) {
+	return newMemdbDatastore(clock.New(), watchBufferLength, revisionFuzzingTimedelta, gcWindow, simulatedLatency, nil)
+}
+
+// NewMemdbDatastoreWithClock creates a new Datastore compliant datastore backed by memdb,
+// which timestamps transactions and computes its revision and garbage collection windows
+// using the provided clock.
+func NewMemdbDatastoreWithClock(
+	timeSource clock.Clock,
+	watchBufferLength uint16,
+	revisionFuzzingTimedelta,
+	gcWindow time.Duration,
+	simulatedLatency time.Duration,
+) (datastore.Datastore, error) {
+	return newMemdbDatastore(timeSource, watchBufferLength, revisionFuzzingTimedelta, gcWindow, simulatedLatency, nil)
+}
+
+// NewPersistentMemdbDatastore creates a new Datastore compliant datastore backed by memdb,
+// which is persisted to the directory at the specified path and restored from it when
+// created.
+//
+// The full database is snapshotted at startup, at every snapshotInterval and when the
+// datastore is closed, and every committed change is appended to a log between snapshots.
+func NewPersistentMemdbDatastore(
+	path string,
+	snapshotInterval,
+	revisionFuzzingTimedelta,
+	gcWindow time.Duration,
+) (datastore.Datastore, error) {
+	if snapshotInterval <= 0 {
+		snapshotInterval = defaultSnapshotInterval
+	}
+
+	timeSource := clock.New()
+	persister, err := newPersister(timeSource, path, snapshotInterval)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiateTuplestore, err)
+	}
+
+	return newMemdbDatastore(timeSource, 0, revisionFuzzingTimedelta, gcWindow, 0, persister)
+}
+
+func newMemdbDatastore(
+	timeSource clock.Clock,
+	watchBufferLength uint16,
+	revisionFuzzingTimedelta,
+	gcWindow time.Duration,
+	simulatedLatency time.Duration,
+	persister *persister,
 ) (datastore.Datastore, error) {
 	if revisionFuzzingTimedelta > gcWindow {
 		return nil, fmt.Errorf(
@@ -355,17 +411,41 @@ func NewMemdbDatastore(
 		return nil, fmt.Errorf(errUnableToInstantiateTuplestore, err)
 	}
 
-	txn := db.Txn(true)
-	defer txn.Abort()
+	restored := false
+	if persister != nil {
+		restored, err = persister.restore(db)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiateTuplestore, err)
+		}
+	}
 
-	// Add a changelog entry to make the first revision non-zero, matching the other datastore
-	// implementations.
-	_, err = createNewTransaction(txn)
-	if err != nil {
-		return nil, fmt.Errorf(errUnableToInstantiateTuplestore, err)
+	if !restored {
+		txn := db.Txn(true)
+		defer txn.Abort()
+
+		// Add a changelog entry to make the first revision non-zero, matching the other datastore
+		// implementations.
+		_, err = createNewTransaction(context.Background(), txn, timeSource.Now())
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiateTuplestore, err)
+		}
+
+		txn.Commit()
 	}
 
-	txn.Commit()
+	// The data of a datastore which is not persisted does not outlive it, and neither do
+	// the revisions at which it was read.
+	uniqueID := uuid.NewString()
+	if persister != nil {
+		if err := persister.start(db); err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiateTuplestore, err)
+		}
+
+		uniqueID, err = persister.uniqueID()
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiateTuplestore, err)
+		}
+	}
 
 	if watchBufferLength == 0 {
 		watchBufferLength = defaultWatchBufferLength
@@ -373,11 +453,14 @@ func NewMemdbDatastore(
 
 	return &memdbDatastore{
 		db:                       db,
+		persister:                persister,
 		watchBufferLength:        watchBufferLength,
 		revisionFuzzingTimedelta: revisionFuzzingTimedelta,
 
 		gcWindowInverted: -1 * gcWindow,
 		simulatedLatency: simulatedLatency,
+		timeSource:       timeSource,
+		uniqueID:         uniqueID,
 	}, nil
 }
 
@@ -385,6 +468,11 @@ func (mds *memdbDatastore) IsReady(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
+// UniqueID implements datastore.Identifier.
+func (mds *memdbDatastore) UniqueID(ctx context.Context) (string, error) {
+	return mds.uniqueID, nil
+}
+
 func revisionFromVersion(version uint64) datastore.Revision {
 	return decimal.NewFromInt(int64(version))
 }
@@ -393,10 +481,33 @@ func (mds *memdbDatastore) Close() error {
 	mds.Lock()
 	mds.db = nil
 	mds.Unlock()
+
+	if mds.persister != nil {
+		return mds.persister.close()
+	}
+	return nil
+}
+
+// newWriteTxn starts a write transaction, tracking its changes if they must be persisted.
+func (mds *memdbDatastore) newWriteTxn(db *memdb.MemDB) *memdb.Txn {
+	txn := db.Txn(true)
+	if mds.persister != nil {
+		txn.TrackChanges()
+	}
+	return txn
+}
+
+// commit commits a write transaction started with newWriteTxn, persisting its changes
+// first if required.
+func (mds *memdbDatastore) commit(txn *memdb.Txn) error {
+	if mds.persister != nil {
+		return mds.persister.commit(txn)
+	}
+	txn.Commit()
 	return nil
 }
 
-func createNewTransaction(txn *memdb.Txn) (uint64, error) {
+func createNewTransaction(ctx context.Context, txn *memdb.Txn, now time.Time) (uint64, error) {
 	var newTransactionID uint64 = 1
 
 	lastChangeRaw, err := txn.Last(tableTransaction, indexID)
@@ -410,7 +521,8 @@ func createNewTransaction(txn *memdb.Txn) (uint64, error) {
 
 	newChangelogEntry := &transaction{
 		id:        newTransactionID,
-		timestamp: uint64(time.Now().UnixNano()),
+		timestamp: uint64(now.UnixNano()),
+		metadata:  datastore.TransactionMetadataFromContext(ctx),
 	}
 
 	if err := txn.Insert(tableTransaction, newChangelogEntry); err != nil {
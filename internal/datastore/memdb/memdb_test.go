@@ -1,19 +1,130 @@
 package memdb
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
 	"github.com/authzed/spicedb/internal/datastore"
-	"github.com/authzed/spicedb/internal/datastore/test"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore/benchmark"
+	"github.com/authzed/spicedb/pkg/datastore/test"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
-type memDBTest struct{}
+func TestMemdbDatastore(t *testing.T) {
+	test.RunAll(t, test.DatastoreBuilder(func(revisionFuzzingTimedelta, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error) {
+		return NewMemdbDatastore(watchBufferLength, revisionFuzzingTimedelta, gcWindow, 0)
+	}))
+}
 
-func (mdbt memDBTest) New(revisionFuzzingTimedelta, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error) {
-	return NewMemdbDatastore(watchBufferLength, revisionFuzzingTimedelta, gcWindow, 0)
+func TestMemdbDifferential(t *testing.T) {
+	builder := test.DatastoreBuilder(func(revisionFuzzingTimedelta, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error) {
+		return NewMemdbDatastore(watchBufferLength, revisionFuzzingTimedelta, gcWindow, 0)
+	})
+	test.RunDifferential(t, builder, builder, time.Now().UnixNano(), 200)
 }
 
-func TestMemdbDatastore(t *testing.T) {
-	test.All(t, memDBTest{})
+func BenchmarkMemdbDatastore(b *testing.B) {
+	ds, err := NewMemdbDatastore(0, 0, DisableGC, 0)
+	require.NoError(b, err)
+	defer ds.Close()
+
+	benchmark.RunBenchmarks(b, ds)
+}
+
+func TestPersistentMemdbDatastoreRestore(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	path := t.TempDir()
+
+	ds, err := NewPersistentMemdbDatastore(path, time.Hour, 0, time.Hour)
+	require.NoError(err)
+
+	// Changes made after the initial snapshot are restored from the change log.
+	ds, revision := testfixtures.StandardDatastoreWithData(ds, require)
+	require.NoError(ds.Close())
+
+	// Simulate a commit which was only partially written when the process stopped.
+	changelog, err := os.OpenFile(filepath.Join(path, changelogFileName), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(err)
+	_, err = changelog.WriteString(`{"changes":[{"transaction":`)
+	require.NoError(err)
+	require.NoError(changelog.Close())
+
+	restored, err := NewPersistentMemdbDatastore(path, time.Hour, 0, time.Hour)
+	require.NoError(err)
+	defer restored.Close()
+
+	head, err := restored.HeadRevision(ctx)
+	require.NoError(err)
+	require.True(head.Equal(revision))
+
+	tRequire := testfixtures.TupleChecker{Require: require, DS: restored}
+	for _, tpl := range testfixtures.StandardTuples {
+		tRequire.TupleExists(ctx, tuple.Parse(tpl), revision)
+	}
+
+	_, _, err = restored.ReadNamespace(ctx, testfixtures.DocumentNS.Name, revision)
+	require.NoError(err)
+
+	// New writes continue from the restored revision.
+	written, err := restored.DeleteNamespace(ctx, testfixtures.UserNS.Name)
+	require.NoError(err)
+	require.True(written.GreaterThan(revision))
+}
+
+func TestMemdbClock(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	timeSource := clock.NewMock()
+	timeSource.Set(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	ds, err := NewMemdbDatastoreWithClock(timeSource, 0, 0, time.Hour, 0)
+	require.NoError(err)
+	ds, revision := testfixtures.StandardDatastoreWithSchema(ds, require)
+	defer ds.Close()
+
+	require.NoError(ds.CheckRevision(ctx, revision))
+
+	// Once the revision falls out of the GC window and a newer one exists, it is stale.
+	timeSource.Add(2 * time.Hour)
+	_, err = ds.DeleteNamespace(ctx, testfixtures.UserNS.Name)
+	require.NoError(err)
+	require.ErrorAs(ds.CheckRevision(ctx, revision), &datastore.ErrInvalidRevision{})
+}
+
+func TestMemdbAuditTuples(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	ds, err := NewMemdbDatastore(0, 0, DisableGC, 0)
+	require.NoError(err)
+	ds, _ = testfixtures.StandardDatastoreWithSchema(ds, require)
+	defer ds.Close()
+
+	metadata := &datastore.TransactionMetadata{Caller: "admin", Reason: "TICKET-123"}
+	tpl := tuple.Parse("document:audited#viewer@user:tom#...")
+	revision, err := ds.WriteTuples(
+		datastore.ContextWithTransactionMetadata(ctx, metadata),
+		nil,
+		[]*v1.RelationshipUpdate{tuple.UpdateToRelationshipUpdate(tuple.Create(tpl))},
+	)
+	require.NoError(err)
+
+	audited, err := ds.(datastore.TupleAuditor).AuditTuples(ctx, &v1.RelationshipFilter{
+		ResourceType:       "document",
+		OptionalResourceId: "audited",
+	}, revision, 10)
+	require.NoError(err)
+	require.Len(audited, 1)
+	require.Equal(tuple.String(tpl), tuple.String(audited[0].Tuple))
+	require.Equal(metadata, audited[0].Metadata)
+	require.False(audited[0].WrittenAt.IsZero())
 }
@@ -30,11 +30,11 @@ func (mds *memdbDatastore) WriteNamespace(
 		return datastore.NoRevision, fmt.Errorf("memdb closed")
 	}
 
-	txn := db.Txn(true)
+	txn := mds.newWriteTxn(db)
 	defer txn.Abort()
 
 	time.Sleep(mds.simulatedLatency)
-	newVersion, err := createNewTransaction(txn)
+	newVersion, err := createNewTransaction(ctx, txn, mds.timeSource.Now())
 	if err != nil {
 		return datastore.NoRevision, fmt.Errorf(errUnableToWriteConfig, err)
 	}
@@ -70,7 +70,9 @@ func (mds *memdbDatastore) WriteNamespace(
 		return datastore.NoRevision, fmt.Errorf(errUnableToWriteConfig, err)
 	}
 
-	txn.Commit()
+	if err := mds.commit(txn); err != nil {
+		return datastore.NoRevision, fmt.Errorf(errUnableToWriteConfig, err)
+	}
 
 	return revisionFromVersion(newVersion), nil
 }
@@ -121,7 +123,7 @@ func (mds *memdbDatastore) DeleteNamespace(ctx context.Context, nsName string) (
 		return datastore.NoRevision, fmt.Errorf("memdb closed")
 	}
 
-	txn := db.Txn(true)
+	txn := mds.newWriteTxn(db)
 	defer txn.Abort()
 
 	time.Sleep(mds.simulatedLatency)
@@ -136,7 +138,7 @@ func (mds *memdbDatastore) DeleteNamespace(ctx context.Context, nsName string) (
 	found := foundRaw.(*namespace)
 
 	time.Sleep(mds.simulatedLatency)
-	newChangelogID, err := createNewTransaction(txn)
+	newChangelogID, err := createNewTransaction(ctx, txn, mds.timeSource.Now())
 	if err != nil {
 		return datastore.NoRevision, fmt.Errorf(errUnableToDeleteConfig, err)
 	}
@@ -161,7 +163,9 @@ func (mds *memdbDatastore) DeleteNamespace(ctx context.Context, nsName string) (
 		return datastore.NoRevision, fmt.Errorf(errUnableToDeleteConfig, err)
 	}
 
-	txn.Commit()
+	if err := mds.commit(txn); err != nil {
+		return datastore.NoRevision, fmt.Errorf(errUnableToDeleteConfig, err)
+	}
 
 	return revisionFromVersion(writeTxnID), nil
 }
@@ -0,0 +1,466 @@
+package memdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-memdb"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+const (
+	snapshotFileName  = "snapshot.json"
+	changelogFileName = "changelog.jsonl"
+	uniqueIDFileName  = "unique_id"
+
+	errUnableToPersist = "unable to persist changes: %w"
+	errUnableToRestore = "unable to restore persisted data: %w"
+)
+
+type persistedNamespace struct {
+	Name        string `json:"name"`
+	ConfigBytes []byte `json:"config"`
+	CreatedTxn  uint64 `json:"created_txn"`
+	DeletedTxn  uint64 `json:"deleted_txn"`
+}
+
+type persistedTransaction struct {
+	ID        uint64                         `json:"id"`
+	Timestamp uint64                         `json:"timestamp"`
+	Metadata  *datastore.TransactionMetadata `json:"metadata,omitempty"`
+}
+
+type persistedRelationship struct {
+	Namespace        string `json:"namespace"`
+	ResourceID       string `json:"resource_id"`
+	Relation         string `json:"relation"`
+	SubjectNamespace string `json:"subject_namespace"`
+	SubjectObjectID  string `json:"subject_object_id"`
+	SubjectRelation  string `json:"subject_relation"`
+	CreatedTxn       uint64 `json:"created_txn"`
+	DeletedTxn       uint64 `json:"deleted_txn"`
+}
+
+// persistedSnapshot is the full contents of the database at the time of a snapshot.
+type persistedSnapshot struct {
+	Namespaces    []persistedNamespace    `json:"namespaces"`
+	Transactions  []persistedTransaction  `json:"transactions"`
+	Relationships []persistedRelationship `json:"relationships"`
+}
+
+// persistedChange is a single object inserted into or deleted from a table. Exactly one
+// of the objects is set.
+type persistedChange struct {
+	Deleted      bool                   `json:"deleted,omitempty"`
+	Namespace    *persistedNamespace    `json:"namespace,omitempty"`
+	Transaction  *persistedTransaction  `json:"transaction,omitempty"`
+	Relationship *persistedRelationship `json:"relationship,omitempty"`
+}
+
+// persistedCommit is a single entry of the change log, holding all of the changes made by
+// a committed write transaction.
+type persistedCommit struct {
+	Changes []persistedChange `json:"changes"`
+}
+
+// persister makes a memdb durable by writing periodic snapshots of the database to disk,
+// along with a log of the changes committed since the last snapshot.
+type persister struct {
+	sync.Mutex
+	path             string
+	snapshotInterval time.Duration
+	timeSource       clock.Clock
+
+	db        *memdb.MemDB
+	changelog *os.File
+	encoder   *json.Encoder
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func newPersister(timeSource clock.Clock, path string, snapshotInterval time.Duration) (*persister, error) {
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &persister{
+		path:             path,
+		snapshotInterval: snapshotInterval,
+		timeSource:       timeSource,
+	}, nil
+}
+
+// uniqueID returns the unique ID of the persisted datastore, generating it when the datastore
+// is first persisted.
+func (p *persister) uniqueID() (string, error) {
+	idPath := filepath.Join(p.path, uniqueIDFileName)
+	existing, err := os.ReadFile(idPath)
+	switch {
+	case err == nil:
+		return string(existing), nil
+	case !errors.Is(err, os.ErrNotExist):
+		return "", fmt.Errorf(errUnableToRestore, err)
+	}
+
+	id := uuid.NewString()
+	if err := os.WriteFile(idPath, []byte(id), 0o600); err != nil {
+		return "", fmt.Errorf(errUnableToPersist, err)
+	}
+	return id, nil
+}
+
+// restore loads the last snapshot into the database and replays the change log on top
+// of it, returning whether any persisted data was found.
+func (p *persister) restore(db *memdb.MemDB) (bool, error) {
+	txn := db.Txn(true)
+	defer txn.Abort()
+
+	snapshotFile, err := os.Open(filepath.Join(p.path, snapshotFileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return false, fmt.Errorf(errUnableToRestore, err)
+	default:
+		defer snapshotFile.Close()
+
+		var snapshot persistedSnapshot
+		if err := json.NewDecoder(snapshotFile).Decode(&snapshot); err != nil {
+			return false, fmt.Errorf(errUnableToRestore, err)
+		}
+		for _, ns := range snapshot.Namespaces {
+			if err := txn.Insert(tableNamespace, ns.namespace()); err != nil {
+				return false, fmt.Errorf(errUnableToRestore, err)
+			}
+		}
+		for _, transaction := range snapshot.Transactions {
+			if err := txn.Insert(tableTransaction, transaction.transaction()); err != nil {
+				return false, fmt.Errorf(errUnableToRestore, err)
+			}
+		}
+		for _, rel := range snapshot.Relationships {
+			if err := txn.Insert(tableRelationship, rel.relationship()); err != nil {
+				return false, fmt.Errorf(errUnableToRestore, err)
+			}
+		}
+	}
+
+	changelogFile, err := os.Open(filepath.Join(p.path, changelogFileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return false, fmt.Errorf(errUnableToRestore, err)
+	default:
+		defer changelogFile.Close()
+
+		decoder := json.NewDecoder(changelogFile)
+		replayed := 0
+		for {
+			var commit persistedCommit
+			err := decoder.Decode(&commit)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				// A commit which was only partially written when the process stopped was
+				// never acknowledged, so it is dropped.
+				log.Warn().Err(err).Int("replayed", replayed).Msg("ignoring incomplete memdb changelog entry")
+				break
+			}
+
+			for _, change := range commit.Changes {
+				if err := applyChange(txn, change); err != nil {
+					return false, fmt.Errorf(errUnableToRestore, err)
+				}
+			}
+			replayed++
+		}
+	}
+
+	lastTxn, err := txn.Last(tableTransaction, indexID)
+	if err != nil {
+		return false, fmt.Errorf(errUnableToRestore, err)
+	}
+
+	txn.Commit()
+	return lastTxn != nil, nil
+}
+
+// start writes a fresh snapshot of the database, which makes the existing change log
+// redundant, and then begins logging changes and snapshotting periodically.
+func (p *persister) start(db *memdb.MemDB) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.db = db
+	if err := p.snapshot(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := p.timeSource.Ticker(p.snapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.Lock()
+				err := p.snapshot()
+				p.Unlock()
+				if err != nil {
+					log.Warn().Err(err).Str("path", p.path).Msg("unable to snapshot memdb")
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// commit appends the changes tracked by the write transaction to the change log and then
+// commits the transaction. Both happen under the lock, so that a snapshot always contains
+// exactly the changes which were logged before it.
+func (p *persister) commit(txn *memdb.Txn) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.changelog == nil {
+		return errors.New("memdb closed")
+	}
+
+	var commit persistedCommit
+	for _, change := range txn.Changes() {
+		persisted, err := toPersistedChange(change)
+		if err != nil {
+			return fmt.Errorf(errUnableToPersist, err)
+		}
+		commit.Changes = append(commit.Changes, persisted)
+	}
+
+	if len(commit.Changes) > 0 {
+		if err := p.encoder.Encode(commit); err != nil {
+			return fmt.Errorf(errUnableToPersist, err)
+		}
+		if err := p.changelog.Sync(); err != nil {
+			return fmt.Errorf(errUnableToPersist, err)
+		}
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// close stops the periodic snapshots and writes a final snapshot.
+func (p *persister) close() error {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.changelog == nil {
+		return nil
+	}
+
+	err := p.snapshot()
+	if closeErr := p.changelog.Close(); err == nil {
+		err = closeErr
+	}
+	p.changelog = nil
+	return err
+}
+
+// snapshot writes the full contents of the database to a new snapshot file, replaces the
+// previous snapshot with it and truncates the change log. It must be called with the
+// lock held.
+func (p *persister) snapshot() error {
+	txn := p.db.Txn(false)
+	defer txn.Abort()
+
+	var snapshot persistedSnapshot
+	if err := forEach(txn, tableNamespace, func(obj interface{}) {
+		snapshot.Namespaces = append(snapshot.Namespaces, toPersistedNamespace(obj.(*namespace)))
+	}); err != nil {
+		return err
+	}
+	if err := forEach(txn, tableTransaction, func(obj interface{}) {
+		snapshot.Transactions = append(snapshot.Transactions, toPersistedTransaction(obj.(*transaction)))
+	}); err != nil {
+		return err
+	}
+	if err := forEach(txn, tableRelationship, func(obj interface{}) {
+		snapshot.Relationships = append(snapshot.Relationships, toPersistedRelationship(obj.(*relationship)))
+	}); err != nil {
+		return err
+	}
+
+	// The snapshot is written beside the previous one and renamed over it, so that a
+	// failure part way through leaves the previous snapshot and change log intact.
+	tmpPath := filepath.Join(p.path, snapshotFileName+".tmp")
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(tmpFile).Encode(snapshot); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(p.path, snapshotFileName)); err != nil {
+		return err
+	}
+
+	if p.changelog != nil {
+		p.changelog.Close()
+	}
+	changelog, err := os.Create(filepath.Join(p.path, changelogFileName))
+	if err != nil {
+		return err
+	}
+	p.changelog = changelog
+	p.encoder = json.NewEncoder(changelog)
+
+	return nil
+}
+
+func forEach(txn *memdb.Txn, table string, fn func(obj interface{})) error {
+	iter, err := txn.Get(table, indexID)
+	if err != nil {
+		return err
+	}
+	for obj := iter.Next(); obj != nil; obj = iter.Next() {
+		fn(obj)
+	}
+	return nil
+}
+
+func applyChange(txn *memdb.Txn, change persistedChange) error {
+	var table string
+	var obj interface{}
+	switch {
+	case change.Namespace != nil:
+		table, obj = tableNamespace, change.Namespace.namespace()
+	case change.Transaction != nil:
+		table, obj = tableTransaction, change.Transaction.transaction()
+	case change.Relationship != nil:
+		table, obj = tableRelationship, change.Relationship.relationship()
+	default:
+		return errors.New("empty changelog entry")
+	}
+
+	if change.Deleted {
+		return txn.Delete(table, obj)
+	}
+	return txn.Insert(table, obj)
+}
+
+func toPersistedChange(change memdb.Change) (persistedChange, error) {
+	obj := change.After
+	persisted := persistedChange{}
+	if change.Deleted() {
+		obj = change.Before
+		persisted.Deleted = true
+	}
+
+	switch typed := obj.(type) {
+	case *namespace:
+		ns := toPersistedNamespace(typed)
+		persisted.Namespace = &ns
+	case *transaction:
+		transaction := toPersistedTransaction(typed)
+		persisted.Transaction = &transaction
+	case *relationship:
+		rel := toPersistedRelationship(typed)
+		persisted.Relationship = &rel
+	default:
+		return persisted, fmt.Errorf("unknown object of type %T in table %s", obj, change.Table)
+	}
+
+	return persisted, nil
+}
+
+func toPersistedNamespace(ns *namespace) persistedNamespace {
+	return persistedNamespace{
+		Name:        ns.name,
+		ConfigBytes: ns.configBytes,
+		CreatedTxn:  ns.createdTxn,
+		DeletedTxn:  ns.deletedTxn,
+	}
+}
+
+func (ns persistedNamespace) namespace() *namespace {
+	return &namespace{
+		name:        ns.Name,
+		configBytes: ns.ConfigBytes,
+		createdTxn:  ns.CreatedTxn,
+		deletedTxn:  ns.DeletedTxn,
+	}
+}
+
+func toPersistedTransaction(txn *transaction) persistedTransaction {
+	return persistedTransaction{
+		ID:        txn.id,
+		Timestamp: txn.timestamp,
+		Metadata:  txn.metadata,
+	}
+}
+
+func (txn persistedTransaction) transaction() *transaction {
+	return &transaction{
+		id:        txn.ID,
+		timestamp: txn.Timestamp,
+		metadata:  txn.Metadata,
+	}
+}
+
+func toPersistedRelationship(r *relationship) persistedRelationship {
+	return persistedRelationship{
+		Namespace:        r.namespace,
+		ResourceID:       r.resourceID,
+		Relation:         r.relation,
+		SubjectNamespace: r.subjectNamespace,
+		SubjectObjectID:  r.subjectObjectID,
+		SubjectRelation:  r.subjectRelation,
+		CreatedTxn:       r.createdTxn,
+		DeletedTxn:       r.deletedTxn,
+	}
+}
+
+func (r persistedRelationship) relationship() *relationship {
+	return &relationship{
+		namespace:        r.Namespace,
+		resourceID:       r.ResourceID,
+		relation:         r.Relation,
+		subjectNamespace: r.SubjectNamespace,
+		subjectObjectID:  r.SubjectObjectID,
+		subjectRelation:  r.SubjectRelation,
+		createdTxn:       r.CreatedTxn,
+		deletedTxn:       r.DeletedTxn,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"sort"
 	"time"
 
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
@@ -63,6 +64,11 @@ func (mds *memdbDatastore) QueryTuples(
 
 	time.Sleep(mds.simulatedLatency)
 
+	if err := mds.checkRevision(txn, revision); err != nil {
+		txn.Abort()
+		return nil, fmt.Errorf(errUnableToQueryTuples, err)
+	}
+
 	bestIterator, err := iteratorForFilter(txn, filter)
 	if err != nil {
 		txn.Abort()
@@ -78,6 +84,14 @@ func (mds *memdbDatastore) QueryTuples(
 	)
 	filteredIterator := memdb.NewFilterIterator(bestIterator, matchingRelationshipsFilterFunc)
 	filteredAlive := memdb.NewFilterIterator(filteredIterator, filterToLiveObjects(revision))
+	if queryOpts.AfterResourceID != "" {
+		filteredAlive = memdb.NewFilterIterator(filteredAlive, filterToResourceIDsAfter(queryOpts.AfterResourceID))
+	}
+
+	if queryOpts.Order != options.Unordered {
+		defer txn.Abort()
+		return orderedTuples(filteredAlive, queryOpts.Order, queryOpts.Limit), nil
+	}
 
 	iter := &memdbTupleIterator{
 		txn:   txn,
@@ -94,6 +108,26 @@ func (mds *memdbDatastore) QueryTuples(
 	return iter, nil
 }
 
+// orderedTuples returns an iterator over the relationships found by the iterator, in the
+// specified order, since the indexes cannot return them in every order.
+func orderedTuples(it memdb.ResultIterator, order options.TupleOrder, limit *uint64) datastore.TupleIterator {
+	var tuples []*v0.RelationTuple
+	for foundRaw := it.Next(); foundRaw != nil; foundRaw = it.Next() {
+		tuples = append(tuples, foundRaw.(*relationship).RelationTuple())
+	}
+
+	sort.SliceStable(tuples, func(i, j int) bool {
+		return order.Less(tuples[i], tuples[j])
+	})
+	if limit != nil && uint64(len(tuples)) > *limit {
+		tuples = tuples[:*limit]
+	}
+
+	iter := datastore.NewSliceTupleIterator(tuples)
+	runtime.SetFinalizer(iter, datastore.BuildFinalizerFunction())
+	return iter
+}
+
 type memdbTupleIterator struct {
 	txn   *memdb.Txn
 	it    memdb.ResultIterator
@@ -144,6 +178,14 @@ func filterFuncForFilters(optionalObjectType, optionalObjectID, optionalRelation
 	}
 }
 
+// filterToResourceIDsAfter filters out relationships whose resource IDs do not sort after
+// the ID.
+func filterToResourceIDsAfter(afterResourceID string) memdb.FilterFunc {
+	return func(tupleRaw interface{}) bool {
+		return tupleRaw.(*relationship).resourceID <= afterResourceID
+	}
+}
+
 func (mti *memdbTupleIterator) Next() *v0.RelationTuple {
 	foundRaw := mti.it.Next()
 	if foundRaw == nil {
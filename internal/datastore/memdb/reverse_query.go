@@ -33,6 +33,11 @@ func (mds *memdbDatastore) ReverseQueryTuples(
 
 	time.Sleep(mds.simulatedLatency)
 
+	if err := mds.checkRevision(txn, revision); err != nil {
+		txn.Abort()
+		return nil, fmt.Errorf(errUnableToQueryTuples, err)
+	}
+
 	var err error
 	var bestIterator memdb.ResultIterator
 	if queryOpts.ResRelation != nil {
@@ -79,6 +84,9 @@ func (mds *memdbDatastore) ReverseQueryTuples(
 	)
 	filteredIterator := memdb.NewFilterIterator(bestIterator, matchingRelationshipsFilterFunc)
 	filteredAlive := memdb.NewFilterIterator(filteredIterator, filterToLiveObjects(revision))
+	if len(queryOpts.SubjectIDs) > 0 {
+		filteredAlive = memdb.NewFilterIterator(filteredAlive, filterToSubjectIDs(queryOpts.SubjectIDs))
+	}
 
 	iter := &memdbTupleIterator{
 		txn:   txn,
@@ -94,3 +102,17 @@ func (mds *memdbDatastore) ReverseQueryTuples(
 
 	return iter, nil
 }
+
+// filterToSubjectIDs returns a filter which excludes relationships whose subjects have none of
+// the specified IDs.
+func filterToSubjectIDs(subjectIDs []string) memdb.FilterFunc {
+	allowed := make(map[string]struct{}, len(subjectIDs))
+	for _, subjectID := range subjectIDs {
+		allowed[subjectID] = struct{}{}
+	}
+
+	return func(tupleRaw interface{}) bool {
+		_, ok := allowed[tupleRaw.(*relationship).subjectObjectID]
+		return !ok
+	}
+}
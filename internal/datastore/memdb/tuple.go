@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/hashicorp/go-memdb"
 	"github.com/jzelinskie/stringz"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/authzed/spicedb/internal/datastore"
 )
@@ -25,12 +27,24 @@ func (mds *memdbDatastore) checkPrecondition(txn *memdb.Txn, preconditions []*v1
 	for _, precond := range preconditions {
 		switch precond.Operation {
 		case v1.Precondition_OPERATION_MUST_NOT_MATCH, v1.Precondition_OPERATION_MUST_MATCH:
-			bestIter, err := iteratorForFilter(txn, precond.Filter)
+			filter := precond.Filter
+			prefix, hasPrefix := datastore.ResourceIDPrefix(filter)
+			if hasPrefix {
+				filter = proto.Clone(filter).(*v1.RelationshipFilter)
+				filter.OptionalResourceId = ""
+			}
+
+			bestIter, err := iteratorForFilter(txn, filter)
 			if err != nil {
 				return err
 			}
 
-			filteredIter := memdb.NewFilterIterator(bestIter, relationshipFilterFilterFunc(precond.Filter))
+			filteredIter := memdb.NewFilterIterator(bestIter, relationshipFilterFilterFunc(filter))
+			if hasPrefix {
+				filteredIter = memdb.NewFilterIterator(filteredIter, func(tupleRaw interface{}) bool {
+					return !strings.HasPrefix(tupleRaw.(*relationship).resourceID, prefix)
+				})
+			}
 
 			exists := filteredIter.Next() != nil
 			if (precond.Operation == v1.Precondition_OPERATION_MUST_MATCH && !exists) ||
@@ -53,7 +67,7 @@ func (mds *memdbDatastore) WriteTuples(ctx context.Context, preconditions []*v1.
 		return datastore.NoRevision, fmt.Errorf("memdb closed")
 	}
 
-	txn := db.Txn(true)
+	txn := mds.newWriteTxn(db)
 	defer txn.Abort()
 
 	if err := mds.checkPrecondition(txn, preconditions); err != nil {
@@ -65,7 +79,9 @@ func (mds *memdbDatastore) WriteTuples(ctx context.Context, preconditions []*v1.
 		return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
 	}
 
-	txn.Commit()
+	if err := mds.commit(txn); err != nil {
+		return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
+	}
 
 	return revisionFromVersion(newChangelogID), nil
 }
@@ -73,7 +89,7 @@ func (mds *memdbDatastore) WriteTuples(ctx context.Context, preconditions []*v1.
 func (mds *memdbDatastore) write(ctx context.Context, txn *memdb.Txn, mutations []*v1.RelationshipUpdate) (uint64, error) {
 	// Create the changelog entry
 	time.Sleep(mds.simulatedLatency)
-	newTxnID, err := createNewTransaction(txn)
+	newTxnID, err := createNewTransaction(ctx, txn, mds.timeSource.Now())
 	if err != nil {
 		return 0, err
 	}
@@ -132,7 +148,7 @@ func (mds *memdbDatastore) DeleteRelationships(ctx context.Context, precondition
 		return datastore.NoRevision, fmt.Errorf("memdb closed")
 	}
 
-	txn := db.Txn(true)
+	txn := mds.newWriteTxn(db)
 	defer txn.Abort()
 
 	if err := mds.checkPrecondition(txn, preconditions); err != nil {
@@ -144,7 +160,9 @@ func (mds *memdbDatastore) DeleteRelationships(ctx context.Context, precondition
 		return datastore.NoRevision, fmt.Errorf(errUnableToDeleteTuples, err)
 	}
 
-	txn.Commit()
+	if err := mds.commit(txn); err != nil {
+		return datastore.NoRevision, fmt.Errorf(errUnableToDeleteTuples, err)
+	}
 
 	return revisionFromVersion(newChangelogID), nil
 }
@@ -207,7 +225,7 @@ func (mds *memdbDatastore) OptimizedRevision(ctx context.Context) (datastore.Rev
 	txn := db.Txn(false)
 	defer txn.Abort()
 
-	lowerBound := uint64(time.Now().Add(-1 * mds.revisionFuzzingTimedelta).UnixNano())
+	lowerBound := uint64(mds.timeSource.Now().Add(-1 * mds.revisionFuzzingTimedelta).UnixNano())
 
 	time.Sleep(mds.simulatedLatency)
 	iter, err := txn.LowerBound(tableTransaction, indexTimestamp, lowerBound)
@@ -237,8 +255,14 @@ func (mds *memdbDatastore) CheckRevision(ctx context.Context, revision datastore
 	txn := db.Txn(false)
 	defer txn.Abort()
 
-	// We need to know the highest possible revision
 	time.Sleep(mds.simulatedLatency)
+	return mds.checkRevision(txn, revision)
+}
+
+// checkRevision checks that the revision is within the window of valid revisions, as of
+// the transaction.
+func (mds *memdbDatastore) checkRevision(txn *memdb.Txn, revision datastore.Revision) error {
+	// We need to know the highest possible revision
 	lastRaw, err := txn.Last(tableTransaction, indexID)
 	if err != nil {
 		return fmt.Errorf(errCheckRevision, err)
@@ -249,27 +273,19 @@ func (mds *memdbDatastore) CheckRevision(ctx context.Context, revision datastore
 
 	highest := revisionFromVersion(lastRaw.(*transaction).id)
 
-	if revision.GreaterThan(highest) {
-		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionInFuture)
-	}
-
-	lowerBound := uint64(time.Now().Add(mds.gcWindowInverted).UnixNano())
-	time.Sleep(mds.simulatedLatency)
+	lowerBound := uint64(mds.timeSource.Now().Add(mds.gcWindowInverted).UnixNano())
 	iter, err := txn.LowerBound(tableTransaction, indexTimestamp, lowerBound)
 	if err != nil {
 		return fmt.Errorf(errCheckRevision, err)
 	}
 
-	firstValid := iter.Next()
-	if firstValid == nil && !revision.Equal(highest) {
-		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
-	}
-
-	if firstValid != nil && revision.LessThan(revisionFromVersion(firstValid.(*transaction).id)) {
-		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
+	// When every transaction is older than the window, only the highest remains valid.
+	oldest := highest
+	if firstValid := iter.Next(); firstValid != nil {
+		oldest = revisionFromVersion(firstValid.(*transaction).id)
 	}
 
-	return nil
+	return datastore.CheckRevisionInWindow(revision, oldest, highest)
 }
 
 func relationshipFilterFilterFunc(filter *v1.RelationshipFilter) func(interface{}) bool {
@@ -6,21 +6,25 @@ import (
 	"fmt"
 
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/hashicorp/go-memdb"
 
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/options"
 )
 
 const errWatchError = "watch error: %w"
 
-func (mds *memdbDatastore) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
+func (mds *memdbDatastore) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	watchOpts := options.NewWatchOptionsWithOptions(opts...)
 	updates := make(chan *datastore.RevisionChanges, mds.watchBufferLength)
 	errs := make(chan error, 1)
 
 	go func() {
 		defer close(updates)
 		defer close(errs)
+		defer datastore.WatchBufferQueue.Track(func() int { return len(updates) }, cap(updates))()
 
 		currentTxn := uint64(afterRevision.IntPart())
 
@@ -28,7 +32,7 @@ func (mds *memdbDatastore) Watch(ctx context.Context, afterRevision datastore.Re
 			var stagedUpdates []*datastore.RevisionChanges
 			var watchChan <-chan struct{}
 			var err error
-			stagedUpdates, currentTxn, watchChan, err = mds.loadChanges(ctx, currentTxn)
+			stagedUpdates, currentTxn, watchChan, err = mds.loadChanges(ctx, currentTxn, watchOpts.Filters)
 			if err != nil {
 				errs <- err
 				return
@@ -64,7 +68,7 @@ func (mds *memdbDatastore) Watch(ctx context.Context, afterRevision datastore.Re
 	return updates, errs
 }
 
-func (mds *memdbDatastore) loadChanges(ctx context.Context, currentTxn uint64) ([]*datastore.RevisionChanges, uint64, <-chan struct{}, error) {
+func (mds *memdbDatastore) loadChanges(ctx context.Context, currentTxn uint64, filters []*v1.RelationshipFilter) ([]*datastore.RevisionChanges, uint64, <-chan struct{}, error) {
 	mds.RLock()
 	db := mds.db
 	mds.RUnlock()
@@ -79,16 +83,20 @@ func (mds *memdbDatastore) loadChanges(ctx context.Context, currentTxn uint64) (
 		return nil, 0, nil, fmt.Errorf(errWatchError, err)
 	}
 
+	startTxn := currentTxn
 	stagedChanges := make(common.Changes)
 	for newChangeRaw := it.Next(); newChangeRaw != nil; newChangeRaw = it.Next() {
-		currentTxn = newChangeRaw.(*transaction).id
+		newChange := newChangeRaw.(*transaction)
+		currentTxn = newChange.id
 		createdIt, err := loadNewTxn.Get(tableRelationship, indexCreatedTxn, currentTxn)
 		if err != nil {
 			return nil, 0, nil, fmt.Errorf(errWatchError, err)
 		}
 		for rawCreated := createdIt.Next(); rawCreated != nil; rawCreated = createdIt.Next() {
-			created := rawCreated.(*relationship)
-			stagedChanges.AddChange(ctx, currentTxn, created.RelationTuple(), v0.RelationTupleUpdate_TOUCH)
+			created := rawCreated.(*relationship).RelationTuple()
+			if datastore.TupleMatchesAnyFilter(created, filters) {
+				stagedChanges.AddChange(ctx, currentTxn, created, v0.RelationTupleUpdate_TOUCH)
+			}
 		}
 
 		deletedIt, err := loadNewTxn.Get(tableRelationship, indexDeletedTxn, currentTxn)
@@ -96,9 +104,19 @@ func (mds *memdbDatastore) loadChanges(ctx context.Context, currentTxn uint64) (
 			return nil, 0, nil, fmt.Errorf(errWatchError, err)
 		}
 		for rawDeleted := deletedIt.Next(); rawDeleted != nil; rawDeleted = deletedIt.Next() {
-			deleted := rawDeleted.(*relationship)
-			stagedChanges.AddChange(ctx, currentTxn, deleted.RelationTuple(), v0.RelationTupleUpdate_DELETE)
+			deleted := rawDeleted.(*relationship).RelationTuple()
+			if datastore.TupleMatchesAnyFilter(deleted, filters) {
+				stagedChanges.AddChange(ctx, currentTxn, deleted, v0.RelationTupleUpdate_DELETE)
+			}
 		}
+
+		stagedChanges.SetMetadata(currentTxn, newChange.metadata)
+	}
+
+	if currentTxn > startTxn {
+		// Report the progress of the watch even if the transactions had no changes of
+		// relationships, or all of them were filtered out.
+		stagedChanges.AddRevision(currentTxn)
 	}
 
 	watchChan, _, err := loadNewTxn.LastWatch(tableTransaction, indexID)
@@ -108,3 +126,91 @@ func (mds *memdbDatastore) loadChanges(ctx context.Context, currentTxn uint64) (
 
 	return stagedChanges.AsRevisionChanges(), currentTxn, watchChan, nil
 }
+
+func (mds *memdbDatastore) WatchNamespaces(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.NamespaceChanges, <-chan error) {
+	updates := make(chan *datastore.NamespaceChanges, mds.watchBufferLength)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(updates)
+		defer close(errs)
+		defer datastore.NamespaceWatchBufferQueue.Track(func() int { return len(updates) }, cap(updates))()
+
+		currentTxn := uint64(afterRevision.IntPart())
+
+		for {
+			var changes *datastore.NamespaceChanges
+			var watchChan <-chan struct{}
+			var err error
+			changes, currentTxn, watchChan, err = mds.loadNamespaceChanges(currentTxn)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if changes != nil {
+				select {
+				case updates <- changes:
+				default:
+					errs <- datastore.NewWatchDisconnectedErr()
+					return
+				}
+			}
+
+			// Wait for new changes
+			ws := memdb.NewWatchSet()
+			ws.Add(watchChan)
+
+			err = ws.WatchCtx(ctx)
+			if err != nil {
+				switch {
+				case errors.Is(err, context.Canceled):
+					errs <- datastore.NewWatchCanceledErr()
+				default:
+					errs <- fmt.Errorf(errWatchError, err)
+				}
+				return
+			}
+		}
+	}()
+
+	return updates, errs
+}
+
+func (mds *memdbDatastore) loadNamespaceChanges(currentTxn uint64) (*datastore.NamespaceChanges, uint64, <-chan struct{}, error) {
+	mds.RLock()
+	db := mds.db
+	mds.RUnlock()
+	if db == nil {
+		return nil, 0, nil, fmt.Errorf("memdb closed")
+	}
+	loadNewTxn := db.Txn(false)
+	defer loadNewTxn.Abort()
+
+	watchChan, lastRaw, err := loadNewTxn.LastWatch(tableTransaction, indexID)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf(errWatchError, err)
+	}
+
+	if lastRaw == nil || lastRaw.(*transaction).id <= currentTxn {
+		return nil, currentTxn, watchChan, nil
+	}
+	newTxn := lastRaw.(*transaction).id
+
+	it, err := loadNewTxn.Get(tableNamespace, indexID)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf(errWatchError, err)
+	}
+
+	changes := &datastore.NamespaceChanges{Revision: revisionFromVersion(newTxn)}
+	for nsRaw := it.Next(); nsRaw != nil; nsRaw = it.Next() {
+		ns := nsRaw.(*namespace)
+		createdInRange := ns.createdTxn > currentTxn && ns.createdTxn <= newTxn
+		deletedInRange := ns.deletedTxn > currentTxn && ns.deletedTxn <= newTxn
+		if createdInRange || deletedInRange {
+			changes.Changed = append(changes.Changed, ns.name)
+		}
+	}
+
+	return changes, newTxn, watchChan, nil
+}
@@ -1,21 +1,87 @@
 package options
 
 import (
+	"time"
+
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 )
 
-//go:generate go run github.com/ecordell/optgen -output zz_generated.query_options.go . QueryOptions ReverseQueryOptions
+//go:generate go run github.com/ecordell/optgen -output zz_generated.query_options.go . QueryOptions ReverseQueryOptions WatchOptions
 
 // QueryOptions are the options that can affect the results of a normal forward query.
 type QueryOptions struct {
 	Limit    *uint64
 	Usersets []*v0.ObjectAndRelation
+	Timeout  time.Duration
+	Order    TupleOrder
+
+	// AfterResourceID limits the query to tuples whose resource IDs sort after it, bytewise
+	// as ByResource orders them, so that a query ordered by resource can be resumed after
+	// the last resource it returned.
+	AfterResourceID string
 }
 
 // ReverseQueryOptions are the options that can affect the results of a reverse query.
 type ReverseQueryOptions struct {
-	ReverseLimit *uint64
-	ResRelation  *ResourceRelation
+	ReverseLimit   *uint64
+	ResRelation    *ResourceRelation
+	SubjectIDs     []string
+	ReverseTimeout time.Duration
+}
+
+// WatchOptions are the options that can affect the changes reported by a watch.
+type WatchOptions struct {
+	// Filters limit the changes to those of relationships matching any of the filters.
+	// The resource IDs of the filters are matched exactly, never as prefixes.
+	Filters []*v1.RelationshipFilter
+}
+
+// TupleOrder is the order in which the tuples found by a query are returned.
+type TupleOrder int8
+
+const (
+	// Unordered returns tuples in whichever order the datastore finds most efficient.
+	Unordered TupleOrder = iota
+
+	// ByResource orders tuples by resource type, ID and relation, and then by subject
+	// type, ID and relation.
+	ByResource
+
+	// BySubject orders tuples by subject type, ID and relation, and then by resource
+	// type, ID and relation.
+	BySubject
+)
+
+// Less returns whether the first tuple is ordered before the second. Unordered considers
+// no tuple to be ordered before another.
+func (o TupleOrder) Less(first, second *v0.RelationTuple) bool {
+	firstKey, secondKey := o.key(first), o.key(second)
+	for i := range firstKey {
+		if firstKey[i] != secondKey[i] {
+			return firstKey[i] < secondKey[i]
+		}
+	}
+	return false
+}
+
+// key returns the values by which a tuple is ordered, from most to least significant.
+func (o TupleOrder) key(tpl *v0.RelationTuple) []string {
+	resource, subject := tpl.ObjectAndRelation, tpl.User.GetUserset()
+	switch o {
+	case ByResource:
+		return []string{
+			resource.Namespace, resource.ObjectId, resource.Relation,
+			subject.Namespace, subject.ObjectId, subject.Relation,
+		}
+	case BySubject:
+		return []string{
+			subject.Namespace, subject.ObjectId, subject.Relation,
+			resource.Namespace, resource.ObjectId, resource.Relation,
+		}
+	default:
+		return nil
+	}
 }
 
 // ResourceRelations combines a resource object type and relation.
@@ -1,7 +1,11 @@
 // Code generated by github.com/ecordell/optgen. DO NOT EDIT.
 package options
 
-import v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+import (
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"time"
+)
 
 type QueryOptionsOption func(q *QueryOptions)
 
@@ -43,6 +47,27 @@ func SetUsersets(usersets []*v0.ObjectAndRelation) QueryOptionsOption {
 	}
 }
 
+// WithTimeout returns an option that can set Timeout on a QueryOptions
+func WithTimeout(timeout time.Duration) QueryOptionsOption {
+	return func(q *QueryOptions) {
+		q.Timeout = timeout
+	}
+}
+
+// WithOrder returns an option that can set Order on a QueryOptions
+func WithOrder(order TupleOrder) QueryOptionsOption {
+	return func(q *QueryOptions) {
+		q.Order = order
+	}
+}
+
+// WithAfterResourceID returns an option that can set AfterResourceID on a QueryOptions
+func WithAfterResourceID(afterResourceID string) QueryOptionsOption {
+	return func(q *QueryOptions) {
+		q.AfterResourceID = afterResourceID
+	}
+}
+
 type ReverseQueryOptionsOption func(r *ReverseQueryOptions)
 
 // NewReverseQueryOptionsWithOptions creates a new ReverseQueryOptions with the passed in options set
@@ -75,3 +100,57 @@ func WithResRelation(resRelation *ResourceRelation) ReverseQueryOptionsOption {
 		r.ResRelation = resRelation
 	}
 }
+
+// WithSubjectIDs returns an option that can append SubjectIDss to ReverseQueryOptions.SubjectIDs
+func WithSubjectIDs(subjectIDs string) ReverseQueryOptionsOption {
+	return func(r *ReverseQueryOptions) {
+		r.SubjectIDs = append(r.SubjectIDs, subjectIDs)
+	}
+}
+
+// SetSubjectIDs returns an option that can set SubjectIDs on a ReverseQueryOptions
+func SetSubjectIDs(subjectIDs []string) ReverseQueryOptionsOption {
+	return func(r *ReverseQueryOptions) {
+		r.SubjectIDs = subjectIDs
+	}
+}
+
+// WithReverseTimeout returns an option that can set ReverseTimeout on a ReverseQueryOptions
+func WithReverseTimeout(reverseTimeout time.Duration) ReverseQueryOptionsOption {
+	return func(r *ReverseQueryOptions) {
+		r.ReverseTimeout = reverseTimeout
+	}
+}
+
+type WatchOptionsOption func(w *WatchOptions)
+
+// NewWatchOptionsWithOptions creates a new WatchOptions with the passed in options set
+func NewWatchOptionsWithOptions(opts ...WatchOptionsOption) *WatchOptions {
+	w := &WatchOptions{}
+	for _, o := range opts {
+		o(w)
+	}
+	return w
+}
+
+// WatchOptionsWithOptions configures an existing WatchOptions with the passed in options set
+func WatchOptionsWithOptions(w *WatchOptions, opts ...WatchOptionsOption) *WatchOptions {
+	for _, o := range opts {
+		o(w)
+	}
+	return w
+}
+
+// WithFilters returns an option that can append Filterss to WatchOptions.Filters
+func WithFilters(filters *v1.RelationshipFilter) WatchOptionsOption {
+	return func(w *WatchOptions) {
+		w.Filters = append(w.Filters, filters)
+	}
+}
+
+// SetFilters returns an option that can set Filters on a WatchOptions
+func SetFilters(filters []*v1.RelationshipFilter) WatchOptionsOption {
+	return func(w *WatchOptions) {
+		w.Filters = filters
+	}
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+const errUnableToAuditTuples = "unable to audit tuples: %w"
+
+var queryAuditTuples = psql.Select(
+	colNamespace,
+	colObjectID,
+	colRelation,
+	colUsersetNamespace,
+	colUsersetObjectID,
+	colUsersetRelation,
+	tableTransaction+"."+colTimestamp,
+	tableTransaction+"."+colMetadata,
+).From(tableTuple).Join(fmt.Sprintf(
+	"%s ON %s.%s = %s.%s",
+	tableTransaction,
+	tableTuple,
+	colCreatedTxn,
+	tableTransaction,
+	colID,
+))
+
+// AuditTuples implements datastore.TupleAuditor.
+func (pgd *pgDatastore) AuditTuples(ctx context.Context, filter *v1.RelationshipFilter, revision datastore.Revision, limit uint64) ([]datastore.AuditedTuple, error) {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "AuditTuples")
+	defer span.End()
+
+	query := filterRelationships(filterToLivingObjects(queryAuditTuples, revision), datastore.TenantFromContext(ctx), filter)
+	sql, args, err := query.OrderBy(tableTransaction + "." + colTimestamp).Limit(limit).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToAuditTuples, err)
+	}
+
+	tx, err := pgd.poolForContext(ctx).BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToAuditTuples, err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToAuditTuples, err)
+	}
+	defer rows.Close()
+
+	var audited []datastore.AuditedTuple
+	for rows.Next() {
+		tpl := &v0.RelationTuple{
+			ObjectAndRelation: &v0.ObjectAndRelation{},
+			User: &v0.User{
+				UserOneof: &v0.User_Userset{
+					Userset: &v0.ObjectAndRelation{},
+				},
+			},
+		}
+		userset := tpl.User.GetUserset()
+
+		var writtenAt time.Time
+		var metadataJSON pgtype.JSONB
+		err := rows.Scan(
+			&tpl.ObjectAndRelation.Namespace,
+			&tpl.ObjectAndRelation.ObjectId,
+			&tpl.ObjectAndRelation.Relation,
+			&userset.Namespace,
+			&userset.ObjectId,
+			&userset.Relation,
+			&writtenAt,
+			&metadataJSON,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToAuditTuples, err)
+		}
+
+		entry := datastore.AuditedTuple{Tuple: tpl, WrittenAt: writtenAt}
+		if metadataJSON.Status == pgtype.Present {
+			entry.Metadata = &datastore.TransactionMetadata{}
+			if err := metadataJSON.AssignTo(entry.Metadata); err != nil {
+				return nil, fmt.Errorf(errUnableToAuditTuples, err)
+			}
+		}
+		audited = append(audited, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(errUnableToAuditTuples, err)
+	}
+
+	return audited, nil
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jzelinskie/stringz"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	errUnableToBulkLoad = "unable to bulk load tuples: %w"
+
+	// featureNotSupportedErrorCode is the SQLSTATE with which servers and poolers which do
+	// not support the COPY protocol reject it.
+	featureNotSupportedErrorCode = "0A000"
+)
+
+// copyTupleColumns are the columns of the tuple table into which bulk loaded rows are copied.
+var copyTupleColumns = []string{
+	colNamespace,
+	colObjectID,
+	colRelation,
+	colUsersetNamespace,
+	colUsersetObjectID,
+	colUsersetRelation,
+	colCreatedTxn,
+	colTenant,
+}
+
+// BulkLoad implements datastore.BulkLoader, copying the relationships into the tuple table
+// with the COPY protocol. If the connection does not support COPY, the relationships are
+// inserted instead.
+func (pgd *pgDatastore) BulkLoad(ctx context.Context, relationships []*v1.Relationship) (datastore.Revision, error) {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "BulkLoad")
+	defer span.End()
+	defer common.ObserveOperationLatency("BulkLoad", engineName, 1, time.Now())
+
+	revision, err := pgd.copyRelationships(ctx, relationships)
+	if isCopyUnsupported(err) {
+		log.Ctx(ctx).Warn().Err(err).Msg("connection does not support COPY; inserting bulk loaded relationships instead")
+
+		mutations := make([]*v1.RelationshipUpdate, 0, len(relationships))
+		for _, rel := range relationships {
+			mutations = append(mutations, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+				Relationship: rel,
+			})
+		}
+		return pgd.WriteTuples(ctx, nil, mutations)
+	}
+	if err != nil {
+		return datastore.NoRevision, fmt.Errorf(errUnableToBulkLoad, err)
+	}
+
+	return revision, nil
+}
+
+// copyRelationships copies the relationships into the tuple table in a single transaction.
+func (pgd *pgDatastore) copyRelationships(ctx context.Context, relationships []*v1.Relationship) (datastore.Revision, error) {
+	tx, err := pgd.dbpool.Begin(ctx)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+	defer tx.Rollback(ctx)
+
+	newTxnID, err := createNewTransaction(ctx, tx)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+
+	tenant := datastore.TenantFromContext(ctx)
+	rows := pgx.CopyFromSlice(len(relationships), func(i int) ([]interface{}, error) {
+		rel := relationships[i]
+		return []interface{}{
+			rel.Resource.ObjectType,
+			rel.Resource.ObjectId,
+			rel.Relation,
+			rel.Subject.Object.ObjectType,
+			rel.Subject.Object.ObjectId,
+			stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
+			newTxnID,
+			tenant,
+		}, nil
+	})
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{tableTuple}, copyTupleColumns, rows); err != nil {
+		return datastore.NoRevision, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return datastore.NoRevision, err
+	}
+
+	return revisionFromTransaction(newTxnID), nil
+}
+
+// isCopyUnsupported returns whether the error reports that the connection does not support
+// the COPY protocol.
+func isCopyUnsupported(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == featureNotSupportedErrorCode
+}
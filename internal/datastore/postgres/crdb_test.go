@@ -12,8 +12,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
-	"github.com/authzed/spicedb/internal/datastore/test"
 	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore/test"
 )
 
 var crdbContainer = &dockertest.RunOptions{
@@ -26,7 +26,7 @@ func TestCRDBPGDatastore(t *testing.T) {
 	tester := newTester(crdbContainer, "root:fake", 26257)
 	defer tester.cleanup()
 
-	test.All(t, tester)
+	test.RunAll(t, tester)
 }
 
 func BenchmarkCRDBQuery(b *testing.B) {
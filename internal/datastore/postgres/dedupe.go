@@ -0,0 +1,241 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	sq "github.com/Masterminds/squirrel"
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const errUnableToDedupe = "unable to deduplicate tuples: %w"
+
+// queryOverlappingTuples finds each pair of rows which store the same relationship with
+// overlapping liveness.
+const queryOverlappingTuples = `
+SELECT a.id, a.created_transaction, a.deleted_transaction,
+	b.id, b.created_transaction, b.deleted_transaction,
+	a.namespace, a.object_id, a.relation, a.userset_namespace, a.userset_object_id, a.userset_relation,
+	a.tenant
+FROM relation_tuple a
+JOIN relation_tuple b
+	ON a.tenant = b.tenant
+	AND a.namespace = b.namespace
+	AND a.object_id = b.object_id
+	AND a.relation = b.relation
+	AND a.userset_namespace = b.userset_namespace
+	AND a.userset_object_id = b.userset_object_id
+	AND a.userset_relation = b.userset_relation
+	AND a.id < b.id
+	AND a.created_transaction < b.deleted_transaction
+	AND b.created_transaction < a.deleted_transaction`
+
+type tupleRow struct {
+	id         uint64
+	createdTxn uint64
+	deletedTxn uint64
+}
+
+// duplicateRows is a set of rows which store the same relationship and which are
+// connected by overlapping liveness.
+type duplicateRows struct {
+	tpl    *v0.RelationTuple
+	tenant string
+	rows   []tupleRow
+}
+
+// FindDuplicateTuples implements datastore.TupleDeduplicator.
+func (pgd *pgDatastore) FindDuplicateTuples(ctx context.Context) ([]datastore.DuplicateTuple, error) {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "FindDuplicateTuples")
+	defer span.End()
+
+	tx, err := pgd.dbpool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToDedupe, err)
+	}
+	defer tx.Rollback(ctx)
+
+	duplicates, err := loadDuplicateRows(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToDedupe, err)
+	}
+
+	return toDuplicateTuples(duplicates), nil
+}
+
+// RepairDuplicateTuples implements datastore.TupleDeduplicator. Of each set of duplicate
+// rows, the row created first is kept and made live until the latest deletion among them,
+// and the others are removed.
+func (pgd *pgDatastore) RepairDuplicateTuples(ctx context.Context) ([]datastore.DuplicateTuple, error) {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "RepairDuplicateTuples")
+	defer span.End()
+
+	tx, err := pgd.dbpool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToDedupe, err)
+	}
+	defer tx.Rollback(ctx)
+
+	duplicates, err := loadDuplicateRows(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToDedupe, err)
+	}
+
+	for _, duplicate := range duplicates {
+		canonical := duplicate.rows[0]
+		deletedTxn := canonical.deletedTxn
+		var redundantIDs []uint64
+		for _, row := range duplicate.rows[1:] {
+			if row.createdTxn < canonical.createdTxn {
+				redundantIDs = append(redundantIDs, canonical.id)
+				canonical = row
+			} else {
+				redundantIDs = append(redundantIDs, row.id)
+			}
+			if row.deletedTxn > deletedTxn {
+				deletedTxn = row.deletedTxn
+			}
+		}
+
+		// The redundant rows must be removed first, as one of them may already be live
+		// until the deletion which the canonical row is being extended to.
+		sql, args, err := psql.Delete(tableTuple).Where(sq.Eq{colID: redundantIDs}).ToSql()
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToDedupe, err)
+		}
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return nil, fmt.Errorf(errUnableToDedupe, err)
+		}
+
+		sql, args, err = psql.Update(tableTuple).
+			Set(colDeletedTxn, deletedTxn).
+			Where(sq.Eq{colID: canonical.id}).
+			ToSql()
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToDedupe, err)
+		}
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return nil, fmt.Errorf(errUnableToDedupe, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf(errUnableToDedupe, err)
+	}
+
+	return toDuplicateTuples(duplicates), nil
+}
+
+func loadDuplicateRows(ctx context.Context, tx pgx.Tx) ([]duplicateRows, error) {
+	rows, err := tx.Query(ctx, queryOverlappingTuples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tuplesByID := make(map[uint64]*v0.RelationTuple)
+	tenantsByID := make(map[uint64]string)
+	rowsByID := make(map[uint64]tupleRow)
+	parents := make(map[uint64]uint64)
+
+	var find func(id uint64) uint64
+	find = func(id uint64) uint64 {
+		parent, ok := parents[id]
+		if !ok || parent == id {
+			return id
+		}
+		root := find(parent)
+		parents[id] = root
+		return root
+	}
+
+	for rows.Next() {
+		var first, second tupleRow
+		var tenant string
+		tpl := &v0.RelationTuple{
+			ObjectAndRelation: &v0.ObjectAndRelation{},
+			User: &v0.User{
+				UserOneof: &v0.User_Userset{
+					Userset: &v0.ObjectAndRelation{},
+				},
+			},
+		}
+		userset := tpl.User.GetUserset()
+		err := rows.Scan(
+			&first.id,
+			&first.createdTxn,
+			&first.deletedTxn,
+			&second.id,
+			&second.createdTxn,
+			&second.deletedTxn,
+			&tpl.ObjectAndRelation.Namespace,
+			&tpl.ObjectAndRelation.ObjectId,
+			&tpl.ObjectAndRelation.Relation,
+			&userset.Namespace,
+			&userset.ObjectId,
+			&userset.Relation,
+			&tenant,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range []tupleRow{first, second} {
+			rowsByID[row.id] = row
+			tuplesByID[row.id] = tpl
+			tenantsByID[row.id] = tenant
+		}
+
+		// Rows which overlap, directly or through other rows, are collapsed together.
+		firstRoot, secondRoot := find(first.id), find(second.id)
+		if firstRoot != secondRoot {
+			parents[secondRoot] = firstRoot
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	byRoot := make(map[uint64]*duplicateRows)
+	var duplicates []*duplicateRows
+	for id, row := range rowsByID {
+		root := find(id)
+		duplicate, ok := byRoot[root]
+		if !ok {
+			duplicate = &duplicateRows{tpl: tuplesByID[id], tenant: tenantsByID[id]}
+			byRoot[root] = duplicate
+			duplicates = append(duplicates, duplicate)
+		}
+		duplicate.rows = append(duplicate.rows, row)
+	}
+
+	result := make([]duplicateRows, 0, len(duplicates))
+	for _, duplicate := range duplicates {
+		result = append(result, *duplicate)
+	}
+	return result, nil
+}
+
+func toDuplicateTuples(duplicates []duplicateRows) []datastore.DuplicateTuple {
+	found := make([]datastore.DuplicateTuple, 0, len(duplicates))
+	for _, duplicate := range duplicates {
+		found = append(found, datastore.DuplicateTuple{
+			Tuple:  duplicate.tpl,
+			Tenant: duplicate.tenant,
+			Rows:   len(duplicate.rows),
+		})
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Tenant != found[j].Tenant {
+			return found[i].Tenant < found[j].Tenant
+		}
+		return tuple.String(found[i].Tuple) < tuple.String(found[j].Tuple)
+	})
+	return found
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	dbsql "database/sql"
+	"fmt"
+	"time"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/postgres/migrations"
+)
+
+const errUnableToDiagnose = "unable to diagnose datastore: %w"
+
+var (
+	getOldestTransactionTimestamp = psql.Select("MIN(" + colTimestamp + ")").From(tableTransaction)
+
+	// The replay lag of replicas is only visible to roles granted pg_monitor, and is
+	// otherwise reported as unknown.
+	getReplicationLag = psql.Select("EXTRACT(EPOCH FROM MAX(replay_lag))::float8").From("pg_stat_replication")
+)
+
+// Diagnostics implements datastore.Diagnoser.
+func (pgd *pgDatastore) Diagnostics(ctx context.Context) (datastore.Diagnostics, error) {
+	ctx, span := tracer.Start(ctx, "Diagnostics")
+	defer span.End()
+
+	var diagnostics datastore.Diagnostics
+
+	currentMigration, headMigration, err := pgd.migrationRevisions()
+	if err != nil {
+		return diagnostics, fmt.Errorf(errUnableToDiagnose, err)
+	}
+	diagnostics.MigrationRevision = currentMigration
+	diagnostics.ExpectedMigrationRevision = headMigration
+
+	diagnostics.GCLag, err = pgd.gcLag(ctx)
+	if err != nil {
+		return diagnostics, fmt.Errorf(errUnableToDiagnose, err)
+	}
+
+	diagnostics.ReplicationLag, err = pgd.replicationLag(ctx)
+	if err != nil {
+		return diagnostics, fmt.Errorf(errUnableToDiagnose, err)
+	}
+
+	diagnostics.Pools = []datastore.PoolDiagnostics{common.DiagnosePool("spicedb", pgd.dbpool)}
+	if pgd.lowPriorityPool != pgd.dbpool {
+		diagnostics.Pools = append(diagnostics.Pools, common.DiagnosePool(lowPriorityPoolName, pgd.lowPriorityPool))
+	}
+
+	return diagnostics, nil
+}
+
+// migrationRevisions returns the current migration revision of the database, and the head
+// migration revision which it is expected to be at.
+func (pgd *pgDatastore) migrationRevisions() (string, string, error) {
+	headMigration, err := migrations.DatabaseMigrations.HeadRevision()
+	if err != nil {
+		return "", "", fmt.Errorf("invalid head migration found for postgres: %w", err)
+	}
+
+	currentRevision, err := migrations.NewAlembicPostgresDriver(pgd.dburl)
+	if err != nil {
+		return "", "", err
+	}
+	defer currentRevision.Dispose()
+
+	version, err := currentRevision.Version()
+	if err != nil {
+		return "", "", err
+	}
+
+	return version, headMigration, nil
+}
+
+// gcLag returns the amount of time for which the oldest transaction has been retained past
+// the garbage collection window.
+func (pgd *pgDatastore) gcLag(ctx context.Context) (time.Duration, error) {
+	now, err := pgd.getNow(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sql, args, err := getOldestTransactionTimestamp.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var oldest dbsql.NullTime
+	if err := pgd.dbpool.QueryRow(datastore.SeparateContextWithTracing(ctx), sql, args...).Scan(&oldest); err != nil {
+		return 0, err
+	}
+	if !oldest.Valid {
+		return 0, nil
+	}
+
+	lag := now.Add(pgd.gcWindowInverted).Sub(oldest.Time)
+	if lag < 0 {
+		return 0, nil
+	}
+	return lag, nil
+}
+
+// replicationLag returns the longest replay lag of the replicas of the database, or zero if
+// it has none or the lag is not visible.
+func (pgd *pgDatastore) replicationLag(ctx context.Context) (time.Duration, error) {
+	sql, args, err := getReplicationLag.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var lagSeconds dbsql.NullFloat64
+	if err := pgd.dbpool.QueryRow(datastore.SeparateContextWithTracing(ctx), sql, args...).Scan(&lagSeconds); err != nil {
+		return 0, err
+	}
+	if !lagSeconds.Valid {
+		return 0, nil
+	}
+	return time.Duration(lagSeconds.Float64 * float64(time.Second)), nil
+}
@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	errUnableToCheckPrimary = "unable to check the primary: %w"
+
+	queryInRecovery = "SELECT pg_is_in_recovery()"
+
+	failoverCheckTimeout   = 5 * time.Second
+	failoverInitialBackoff = 250 * time.Millisecond
+	failoverMaxBackoff     = 30 * time.Second
+)
+
+var errPrimaryInRecovery = errors.New("connected server is in recovery and no longer the writable primary")
+
+var (
+	failoversCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "postgres_failovers_total",
+		Help:      "number of times the writable primary of postgres was found to have changed.",
+	})
+
+	failoverCheckFailuresCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "postgres_primary_check_failures_total",
+		Help:      "number of checks of the writable primary of postgres which failed and were retried with backoff.",
+	})
+
+	primaryGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "postgres_primary",
+		Help:      "address of the writable primary of postgres to which connections are established, set to 1.",
+	}, []string{"address"})
+)
+
+// hasMultipleHosts returns whether the connection configuration lists more than one host,
+// any of which may be the writable primary.
+func hasMultipleHosts(connConfig *pgx.ConnConfig) bool {
+	for _, fallback := range connConfig.Fallbacks {
+		if fallback.Host != connConfig.Host || fallback.Port != connConfig.Port {
+			return true
+		}
+	}
+	return false
+}
+
+// failoverDetector periodically checks that the connections of the pools are to the writable
+// primary of the database, so that a planned failover, such as one of a Patroni or RDS
+// cluster, does not require a restart. When the server to which they are connected is
+// demoted, or another server is found to have been promoted, every connection established
+// until then is retired, and is replaced by one which resolves the hosts of the connection
+// string again and validates that it is writable.
+type failoverDetector struct {
+	pool       *pgxpool.Pool
+	interval   time.Duration
+	timeSource clock.Clock
+	primary    string
+
+	sync.Mutex
+	conns map[*pgx.Conn]struct{}
+}
+
+func newFailoverDetector(interval time.Duration, timeSource clock.Clock) *failoverDetector {
+	return &failoverDetector{
+		interval:   interval,
+		timeSource: timeSource,
+		conns:      make(map[*pgx.Conn]struct{}),
+	}
+}
+
+// instrument tracks the connections established by a pool with the configuration, so that
+// those which were retired are destroyed rather than being handed to a request.
+func (fd *failoverDetector) instrument(pgxConfig *pgxpool.Config) {
+	pgxConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		fd.track(conn)
+		return nil
+	}
+	pgxConfig.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		return fd.isCurrent(conn)
+	}
+	pgxConfig.AfterRelease = fd.isCurrent
+}
+
+func (fd *failoverDetector) track(conn *pgx.Conn) {
+	fd.Lock()
+	defer fd.Unlock()
+
+	// Connections closed by the pool itself are forgotten when the next one is established.
+	for tracked := range fd.conns {
+		if tracked.IsClosed() {
+			delete(fd.conns, tracked)
+		}
+	}
+	fd.conns[conn] = struct{}{}
+}
+
+func (fd *failoverDetector) isCurrent(conn *pgx.Conn) bool {
+	fd.Lock()
+	defer fd.Unlock()
+
+	_, ok := fd.conns[conn]
+	return ok
+}
+
+// retire marks every connection established so far as stale.
+func (fd *failoverDetector) retire() {
+	fd.Lock()
+	defer fd.Unlock()
+
+	fd.conns = make(map[*pgx.Conn]struct{})
+}
+
+// Run checks the primary every interval until the context is canceled, retrying checks
+// which fail with an exponential backoff.
+func (fd *failoverDetector) Run(ctx context.Context) {
+	var backoff time.Duration
+	for {
+		wait := fd.interval
+		if backoff > 0 {
+			wait = backoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-fd.timeSource.After(wait):
+		}
+
+		err := fd.check(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			backoff = nextFailoverBackoff(backoff)
+			failoverCheckFailuresCounter.Inc()
+			log.Warn().Err(err).Dur("retryIn", backoff).Msg("unable to verify the writable primary of postgres")
+			continue
+		}
+		backoff = 0
+	}
+}
+
+func nextFailoverBackoff(previous time.Duration) time.Duration {
+	if previous == 0 {
+		return failoverInitialBackoff
+	}
+	if previous*2 > failoverMaxBackoff {
+		return failoverMaxBackoff
+	}
+	return previous * 2
+}
+
+func (fd *failoverDetector) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, failoverCheckTimeout)
+	defer cancel()
+
+	// A pool which cannot hand out a connection may simply be busy, so its connections are
+	// only retired once one of them is found to be broken or demoted.
+	conn, err := fd.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf(errUnableToCheckPrimary, err)
+	}
+	defer conn.Release()
+
+	var inRecovery bool
+	if err := conn.QueryRow(ctx, queryInRecovery).Scan(&inRecovery); err != nil {
+		if conn.Conn().IsClosed() {
+			fd.retire()
+		}
+		return fmt.Errorf(errUnableToCheckPrimary, err)
+	}
+	if inRecovery {
+		fd.retire()
+		return fmt.Errorf(errUnableToCheckPrimary, errPrimaryInRecovery)
+	}
+
+	fd.observePrimary(conn.Conn().PgConn().Conn().RemoteAddr().String())
+	return nil
+}
+
+// observePrimary records the address of the writable primary, retiring the connections
+// established until then if it changed.
+func (fd *failoverDetector) observePrimary(address string) {
+	if address == fd.primary {
+		return
+	}
+
+	if fd.primary != "" {
+		log.Warn().Str("previous", fd.primary).Str("primary", address).Msg("postgres primary changed, reestablishing connections")
+		failoversCounter.Inc()
+		fd.retire()
+		primaryGauge.DeleteLabelValues(fd.primary)
+	}
+
+	fd.primary = address
+	primaryGauge.WithLabelValues(address).Set(1)
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const queryTupleIndexes = `
+SELECT index_class.relname, attribute.attname
+FROM pg_index idx
+JOIN pg_class table_class ON table_class.oid = idx.indrelid
+JOIN pg_class index_class ON index_class.oid = idx.indexrelid
+CROSS JOIN LATERAL unnest(idx.indkey) WITH ORDINALITY AS key(attnum, position)
+JOIN pg_attribute attribute ON attribute.attrelid = table_class.oid AND attribute.attnum = key.attnum
+WHERE table_class.relname = $1 AND pg_table_is_visible(table_class.oid)
+ORDER BY index_class.relname, key.position`
+
+// TupleIndexes implements datastore.IndexInspector.
+func (pgd *pgDatastore) TupleIndexes(ctx context.Context) ([]datastore.TupleIndex, error) {
+	return common.QueryTupleIndexes(ctx, pgd.dbpool, queryTupleIndexes, tableTuple)
+}
+
+// CreateTupleIndexDDL implements datastore.IndexInspector. Indexes are built concurrently
+// so that the statement can be run against a live datastore without blocking writes.
+func (pgd *pgDatastore) CreateTupleIndexDDL(name string, columns []string) string {
+	return fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s (%s);", name, tableTuple, strings.Join(columns, ", "))
+}
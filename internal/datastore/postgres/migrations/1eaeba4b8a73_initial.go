@@ -39,28 +39,14 @@ const insertEmptyVersion = `INSERT INTO alembic_version (version_num) VALUES (''
 
 func init() {
 	if err := DatabaseMigrations.Register("1eaeba4b8a73", "", func(apd *AlembicPostgresDriver) error {
-		tx, err := apd.db.Beginx()
-		if err != nil {
-			return err
-		}
-		defer tx.Rollback()
-
-		statements := []string{
+		return apd.execInTransaction(
 			createRelationTupleTransaction,
 			createNamespaceConfig,
 			createRelationTuple,
 			insertFirstTransaction,
 			createAlembicVersion,
 			insertEmptyVersion,
-		}
-		for _, stmt := range statements {
-			_, err := tx.Exec(stmt)
-			if err != nil {
-				return err
-			}
-		}
-
-		return tx.Commit()
+		)
 	}); err != nil {
 		panic("failed to register migration: " + err.Error())
 	}
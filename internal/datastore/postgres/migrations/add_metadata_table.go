@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const createMetadataTable = `CREATE TABLE metadata (
+	unique_id VARCHAR PRIMARY KEY
+);`
+
+// The unique ID is generated when the table is created, so that it identifies the data of
+// the datastore for as long as it exists, including when it is restored from a backup into
+// another database.
+const insertUniqueID = `INSERT INTO metadata (unique_id) VALUES ('%s');`
+
+func init() {
+	if err := DatabaseMigrations.Register("add-metadata-table", "add-tenant", func(apd *AlembicPostgresDriver) error {
+		return apd.execInTransaction(createMetadataTable, fmt.Sprintf(insertUniqueID, uuid.NewString()))
+	}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -7,23 +7,10 @@ const (
 
 func init() {
 	if err := DatabaseMigrations.Register("add-reverse-index", "1eaeba4b8a73", func(apd *AlembicPostgresDriver) error {
-		tx, err := apd.db.Beginx()
-		if err != nil {
-			return err
-		}
-		defer tx.Rollback()
-
-		for _, stmt := range []string{
+		return apd.execInTransaction(
 			createReverseQueryIndex,
 			createReverseCheckIndex,
-		} {
-			_, err := tx.Exec(stmt)
-			if err != nil {
-				return err
-			}
-		}
-
-		return tx.Commit()
+		)
 	}); err != nil {
 		panic("failed to register migration: " + err.Error())
 	}
@@ -0,0 +1,30 @@
+package migrations
+
+// The tenant is added as the trailing column of each constraint, so that the indexes
+// backing the constraints continue to serve the queries which they served before.
+var addTenantStatements = []string{
+	`ALTER TABLE relation_tuple ADD COLUMN tenant VARCHAR NOT NULL DEFAULT '';`,
+	`ALTER TABLE namespace_config ADD COLUMN tenant VARCHAR NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE relation_tuple
+		DROP CONSTRAINT uq_relation_tuple_namespace,
+		ADD CONSTRAINT uq_relation_tuple_namespace UNIQUE (namespace, object_id, relation, userset_namespace, userset_object_id, userset_relation, created_transaction, deleted_transaction, tenant);`,
+	`ALTER TABLE relation_tuple
+		DROP CONSTRAINT uq_relation_tuple_living,
+		ADD CONSTRAINT uq_relation_tuple_living UNIQUE (namespace, object_id, relation, userset_namespace, userset_object_id, userset_relation, deleted_transaction, tenant);`,
+
+	`ALTER TABLE namespace_config
+		DROP CONSTRAINT pk_namespace_config,
+		ADD CONSTRAINT pk_namespace_config PRIMARY KEY (namespace, created_transaction, tenant);`,
+	`ALTER TABLE namespace_config
+		DROP CONSTRAINT uq_namespace_living,
+		ADD CONSTRAINT uq_namespace_living UNIQUE (namespace, deleted_transaction, tenant);`,
+}
+
+func init() {
+	if err := DatabaseMigrations.Register("add-tenant", "add-transaction-metadata", func(apd *AlembicPostgresDriver) error {
+		return apd.execInTransaction(addTenantStatements...)
+	}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -0,0 +1,13 @@
+package migrations
+
+const addTransactionMetadataColumn = `
+	ALTER TABLE relation_tuple_transaction ADD COLUMN metadata JSONB;
+`
+
+func init() {
+	if err := DatabaseMigrations.Register("add-transaction-metadata", "add-transaction-timestamp-index", func(apd *AlembicPostgresDriver) error {
+		return apd.execInTransaction(addTransactionMetadataColumn)
+	}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -6,18 +6,7 @@ const createIndexOnTupleTransactionTimestamp = `
 
 func init() {
 	if err := DatabaseMigrations.Register("add-transaction-timestamp-index", "add-unique-living-ns", func(apd *AlembicPostgresDriver) error {
-		tx, err := apd.db.Beginx()
-		if err != nil {
-			return err
-		}
-		defer tx.Rollback()
-
-		_, err = tx.Exec(createIndexOnTupleTransactionTimestamp)
-		if err != nil {
-			return err
-		}
-
-		return tx.Commit()
+		return apd.execInTransaction(createIndexOnTupleTransactionTimestamp)
 	}); err != nil {
 		panic("failed to register migration: " + err.Error())
 	}
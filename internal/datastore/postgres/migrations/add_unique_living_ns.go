@@ -13,23 +13,10 @@ const deleteAllButNewestNamespace = `
 
 func init() {
 	if err := DatabaseMigrations.Register("add-unique-living-ns", "add-reverse-index", func(apd *AlembicPostgresDriver) error {
-		tx, err := apd.db.Beginx()
-		if err != nil {
-			return err
-		}
-		defer tx.Rollback()
-
-		_, err = tx.Exec(deleteAllButNewestNamespace)
-		if err != nil {
-			return err
-		}
-
-		_, err = tx.Exec(createUniqueLivingTupleConstraint)
-		if err != nil {
-			return err
-		}
-
-		return tx.Commit()
+		return apd.execInTransaction(
+			deleteAllButNewestNamespace,
+			createUniqueLivingTupleConstraint,
+		)
 	}); err != nil {
 		panic("failed to register migration: " + err.Error())
 	}
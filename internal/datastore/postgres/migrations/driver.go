@@ -18,6 +18,9 @@ const postgresMissingTableErrorCode = "42P01"
 // It is compatible with the popular Python library, Alembic
 type AlembicPostgresDriver struct {
 	db *sqlx.DB
+
+	recording bool
+	recorded  []string
 }
 
 // NewAlembicPostgresDriver creates a new driver with active connections to the database specified.
@@ -32,7 +35,7 @@ func NewAlembicPostgresDriver(url string) (*AlembicPostgresDriver, error) {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
-	return &AlembicPostgresDriver{db}, nil
+	return &AlembicPostgresDriver{db: db}, nil
 }
 
 // Version returns the version of the schema to which the connected database
@@ -71,6 +74,43 @@ func (apd *AlembicPostgresDriver) WriteVersion(version, replaced string) error {
 	return nil
 }
 
+// StartRecording causes the statements of migrations to be recorded rather than executed.
+func (apd *AlembicPostgresDriver) StartRecording() {
+	apd.recording = true
+	apd.recorded = nil
+}
+
+// StopRecording returns the statements recorded and resumes executing statements.
+func (apd *AlembicPostgresDriver) StopRecording() []string {
+	recorded := apd.recorded
+	apd.recording = false
+	apd.recorded = nil
+	return recorded
+}
+
+// execInTransaction executes the statements of a migration in a single transaction, or
+// records them if the driver is recording.
+func (apd *AlembicPostgresDriver) execInTransaction(statements ...string) error {
+	if apd.recording {
+		apd.recorded = append(apd.recorded, statements...)
+		return nil
+	}
+
+	tx, err := apd.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 // Dispose disposes the driver.
 func (apd *AlembicPostgresDriver) Dispose() {
 	apd.db.Close()
@@ -14,6 +14,7 @@ import (
 
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/redact"
 )
 
 const (
@@ -28,6 +29,7 @@ var (
 		colNamespace,
 		colConfig,
 		colCreatedTxn,
+		colTenant,
 	)
 
 	readNamespace   = psql.Select(colConfig, colCreatedTxn).From(tableNamespace)
@@ -42,7 +44,7 @@ func (pgd *pgDatastore) WriteNamespace(ctx context.Context, newConfig *v0.Namesp
 	ctx, span := tracer.Start(ctx, "WriteNamespace")
 	defer span.End()
 
-	span.SetAttributes(common.ObjNamespaceNameKey.String(newConfig.Name))
+	span.SetAttributes(common.ObjNamespaceNameKey.String(redact.Type(newConfig.Name)))
 
 	serialized, err := proto.Marshal(newConfig)
 	if err != nil {
@@ -63,9 +65,10 @@ func (pgd *pgDatastore) WriteNamespace(ctx context.Context, newConfig *v0.Namesp
 	}
 	span.AddEvent("Model transaction created")
 
+	tenant := datastore.TenantFromContext(ctx)
 	delSQL, delArgs, err := deleteNamespace.
 		Set(colDeletedTxn, newTxnID).
-		Where(sq.Eq{colNamespace: newConfig.Name, colDeletedTxn: liveDeletedTxnID}).
+		Where(sq.Eq{colTenant: tenant, colNamespace: newConfig.Name, colDeletedTxn: liveDeletedTxnID}).
 		ToSql()
 	if err != nil {
 		return datastore.NoRevision, fmt.Errorf(errUnableToWriteConfig, err)
@@ -76,7 +79,7 @@ func (pgd *pgDatastore) WriteNamespace(ctx context.Context, newConfig *v0.Namesp
 		return datastore.NoRevision, fmt.Errorf(errUnableToWriteConfig, err)
 	}
 
-	sql, args, err := writeNamespace.Values(newConfig.Name, serialized, newTxnID).ToSql()
+	sql, args, err := writeNamespace.Values(newConfig.Name, serialized, newTxnID, tenant).ToSql()
 	if err != nil {
 		return datastore.NoRevision, fmt.Errorf(errUnableToWriteConfig, err)
 	}
@@ -98,11 +101,11 @@ func (pgd *pgDatastore) WriteNamespace(ctx context.Context, newConfig *v0.Namesp
 
 func (pgd *pgDatastore) ReadNamespace(ctx context.Context, nsName string, revision datastore.Revision) (*v0.NamespaceDefinition, datastore.Revision, error) {
 	ctx, span := tracer.Start(ctx, "ReadNamespace", trace.WithAttributes(
-		attribute.String("name", nsName),
+		attribute.String("name", redact.Type(nsName)),
 	))
 	defer span.End()
 
-	tx, err := pgd.dbpool.Begin(ctx)
+	tx, err := pgd.poolForContext(ctx).Begin(ctx)
 	if err != nil {
 		return nil, datastore.NoRevision, fmt.Errorf(errUnableToReadConfig, err)
 	}
@@ -144,9 +147,10 @@ func (pgd *pgDatastore) DeleteNamespace(ctx context.Context, nsName string) (dat
 		return datastore.NoRevision, fmt.Errorf(errUnableToDeleteConfig, err)
 	}
 
+	tenant := datastore.TenantFromContext(ctx)
 	delSQL, delArgs, err := deleteNamespace.
 		Set(colDeletedTxn, newTxnID).
-		Where(sq.Eq{colNamespace: nsName, colCreatedTxn: createdAt}).
+		Where(sq.Eq{colTenant: tenant, colNamespace: nsName, colCreatedTxn: createdAt}).
 		ToSql()
 	if err != nil {
 		return datastore.NoRevision, fmt.Errorf(errUnableToDeleteConfig, err)
@@ -159,7 +163,7 @@ func (pgd *pgDatastore) DeleteNamespace(ctx context.Context, nsName string) (dat
 
 	deleteTupleSQL, deleteTupleArgs, err := deleteNamespaceTuples.
 		Set(colDeletedTxn, newTxnID).
-		Where(sq.Eq{colNamespace: nsName}).
+		Where(sq.Eq{colTenant: tenant, colNamespace: nsName}).
 		ToSql()
 	if err != nil {
 		return datastore.NoRevision, fmt.Errorf(errUnableToDeleteConfig, err)
@@ -184,7 +188,10 @@ func loadNamespace(ctx context.Context, namespace string, tx pgx.Tx, baseQuery s
 	ctx, span := tracer.Start(ctx, "loadNamespace")
 	defer span.End()
 
-	sql, args, err := baseQuery.Where(sq.Eq{colNamespace: namespace}).ToSql()
+	sql, args, err := baseQuery.Where(sq.Eq{
+		colTenant:    datastore.TenantFromContext(ctx),
+		colNamespace: namespace,
+	}).ToSql()
 	if err != nil {
 		return nil, datastore.NoRevision, err
 	}
@@ -211,13 +218,15 @@ func loadNamespace(ctx context.Context, namespace string, tx pgx.Tx, baseQuery s
 func (pgd *pgDatastore) ListNamespaces(ctx context.Context, revision datastore.Revision) ([]*v0.NamespaceDefinition, error) {
 	ctx = datastore.SeparateContextWithTracing(ctx)
 
-	tx, err := pgd.dbpool.Begin(ctx)
+	tx, err := pgd.poolForContext(ctx).Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
-	sql, args, err := filterToLivingObjects(readNamespace, revision).ToSql()
+	sql, args, err := filterToLivingObjects(readNamespace, revision).
+		Where(sq.Eq{colTenant: datastore.TenantFromContext(ctx)}).
+		ToSql()
 	if err != nil {
 		return nil, err
 	}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/alecthomas/units"
+	"github.com/benbjohnson/clock"
 
 	"github.com/authzed/spicedb/internal/datastore/common"
 )
@@ -13,28 +14,42 @@ type postgresOptions struct {
 	connMaxIdleTime   *time.Duration
 	connMaxLifetime   *time.Duration
 	healthCheckPeriod *time.Duration
+	connPingInterval  time.Duration
+	failoverInterval  time.Duration
 	maxOpenConns      *int
 	minOpenConns      *int
 
+	lowPriorityMaxOpenConns *int
+
 	watchBufferLength         uint16
 	revisionFuzzingTimedelta  time.Duration
 	gcWindow                  time.Duration
 	gcInterval                time.Duration
 	gcMaxOperationTime        time.Duration
 	splitAtEstimatedQuerySize units.Base2Bytes
+	maxUsersetsPerQuery       int
+	splitAtParameterCount     int
+	queryHints                common.QueryHints
+	queryTimeout              time.Duration
+	slowQueryLog              common.SlowQueryLog
 
 	enablePrometheusStats bool
+	poolerCompat          bool
 
-	logger *tracingLogger
+	logger       *tracingLogger
+	timeSource   clock.Clock
+	maxClockSkew time.Duration
 }
 
 const (
-	errFuzzingTooLarge = "revision fuzzing timedelta (%s) must be less than GC window (%s)"
+	errFuzzingTooLarge   = "revision fuzzing timedelta (%s) must be less than GC window (%s)"
+	errTooManyParameters = "split parameter count (%d) must be at most %d"
 
 	defaultWatchBufferLength                 = 128
 	defaultGarbageCollectionWindow           = 24 * time.Hour
 	defaultGarbageCollectionInterval         = time.Minute * 3
 	defaultGarbageCollectionMaxOperationTime = time.Minute
+	defaultMaxClockSkew                      = 500 * time.Millisecond
 )
 
 // Option provides the facility to configure how clients within the
@@ -48,6 +63,9 @@ func generateConfig(options []Option) (postgresOptions, error) {
 		gcMaxOperationTime:        defaultGarbageCollectionMaxOperationTime,
 		watchBufferLength:         defaultWatchBufferLength,
 		splitAtEstimatedQuerySize: common.DefaultSplitAtEstimatedQuerySize,
+		splitAtParameterCount:     common.DefaultSplitAtParameterCount,
+		timeSource:                clock.New(),
+		maxClockSkew:              defaultMaxClockSkew,
 	}
 
 	for _, option := range options {
@@ -63,6 +81,14 @@ func generateConfig(options []Option) (postgresOptions, error) {
 		)
 	}
 
+	if computed.splitAtParameterCount > common.MaxStatementParameters {
+		return computed, fmt.Errorf(
+			errTooManyParameters,
+			computed.splitAtParameterCount,
+			common.MaxStatementParameters,
+		)
+	}
+
 	return computed, nil
 }
 
@@ -76,6 +102,68 @@ func SplitAtEstimatedQuerySize(splitAtEstimatedQuerySize units.Base2Bytes) Optio
 	}
 }
 
+// SplitAtParameterCount is the number of parameters bound to a query above
+// which it is split into two (or more) queries. It cannot exceed
+// `common.MaxStatementParameters`, the most the wire protocol allows.
+//
+// This value defaults to `common.DefaultSplitAtParameterCount`.
+func SplitAtParameterCount(count int) Option {
+	return func(po *postgresOptions) {
+		po.splitAtParameterCount = count
+	}
+}
+
+// MaxUsersetsPerQuery is the most usersets to which a single query is
+// filtered, beyond which it is split into two (or more) queries.
+//
+// This value defaults to zero, which places no limit on the usersets of a query.
+func MaxUsersetsPerQuery(count int) Option {
+	return func(po *postgresOptions) {
+		po.maxUsersetsPerQuery = count
+	}
+}
+
+// QueryHints are the planner hints to apply to generated queries, by query
+// shape. Hints are applied as pg_hint_plan comments, which requires the extension to be installed.
+//
+// This value defaults to no hints.
+func QueryHints(hints common.QueryHints) Option {
+	return func(po *postgresOptions) {
+		po.queryHints = hints
+	}
+}
+
+// QueryTimeout is the default maximum amount of time a single tuple query may
+// run before it is canceled. A timeout specified on the query itself takes
+// precedence.
+//
+// This value defaults to having no timeout.
+func QueryTimeout(timeout time.Duration) Option {
+	return func(po *postgresOptions) {
+		po.queryTimeout = timeout
+	}
+}
+
+// SlowQueryThreshold is the duration at or above which a tuple query is logged,
+// along with its SQL and filters.
+//
+// This value defaults to zero, which disables slow query logging.
+func SlowQueryThreshold(threshold time.Duration) Option {
+	return func(po *postgresOptions) {
+		po.slowQueryLog.Threshold = threshold
+	}
+}
+
+// SlowQueryExplainSampleRate is the fraction, between 0 and 1, of logged slow
+// queries for which the query plan is captured with EXPLAIN and logged.
+//
+// This value defaults to zero.
+func SlowQueryExplainSampleRate(rate float64) Option {
+	return func(po *postgresOptions) {
+		po.slowQueryLog.ExplainSampleRate = rate
+	}
+}
+
 // ConnMaxIdleTime is the duration after which an idle connection will be
 // automatically closed by the health check.
 //
@@ -104,6 +192,29 @@ func HealthCheckPeriod(period time.Duration) Option {
 	}
 }
 
+// ConnPingInterval is the interval at which idle connections in the connection
+// pools are actively pinged, closing and replacing those which fail.
+//
+// This value defaults to zero, which disables pinging.
+func ConnPingInterval(interval time.Duration) Option {
+	return func(po *postgresOptions) {
+		po.connPingInterval = interval
+	}
+}
+
+// FailoverCheckInterval is the interval at which the connection pools are
+// checked to be connected to the writable primary. When it is found to have
+// been demoted or replaced, such as during a planned failover, the pooled
+// connections are reestablished against whichever of the hosts of the
+// connection string is now the writable primary.
+//
+// This value defaults to zero, which disables the detection of failovers.
+func FailoverCheckInterval(interval time.Duration) Option {
+	return func(po *postgresOptions) {
+		po.failoverInterval = interval
+	}
+}
+
 // MaxOpenConns is the maximum size of the connection pool.
 //
 // This value defaults to having no maximum.
@@ -124,6 +235,17 @@ func MinOpenConns(conns int) Option {
 	}
 }
 
+// LowPriorityMaxOpenConns is the maximum size of a separate connection pool
+// used to serve batch and background priority requests, isolating them from
+// interactive traffic.
+//
+// This value defaults to sharing the primary connection pool.
+func LowPriorityMaxOpenConns(conns int) Option {
+	return func(po *postgresOptions) {
+		po.lowPriorityMaxOpenConns = &conns
+	}
+}
+
 // WatchBufferLength is the number of entries that can be stored in the watch
 // buffer while awaiting read by the client.
 //
@@ -183,6 +305,20 @@ func EnablePrometheusStats() Option {
 	}
 }
 
+// PoolerCompatibility makes the datastore compatible with connection poolers,
+// such as PgBouncer, running in transaction pooling mode. Queries are sent
+// using the simple query protocol, prepared statements are not cached, and
+// connection strings which set session-level runtime parameters are rejected,
+// as the pooler cannot guarantee they are present on the server connection
+// which executes a given transaction.
+//
+// Pooler compatibility is disabled by default.
+func PoolerCompatibility(enabled bool) Option {
+	return func(po *postgresOptions) {
+		po.poolerCompat = enabled
+	}
+}
+
 // EnableTracing enables trace-level logging for the Postgres clients being
 // used by the datastore.
 //
@@ -192,3 +328,25 @@ func EnableTracing() Option {
 		po.logger = &tracingLogger{}
 	}
 }
+
+// Clock is the source of time used to schedule garbage collection and to poll for
+// changes when watching. Revisions and the garbage collection window are computed from
+// the time of the database itself.
+//
+// This value defaults to the system clock.
+func Clock(timeSource clock.Clock) Option {
+	return func(po *postgresOptions) {
+		po.timeSource = timeSource
+	}
+}
+
+// MaxClockSkew is the difference between the time of the database and the wall clock of
+// the server beyond which a warning is logged. The skew is measured whenever garbage is
+// collected. Zero disables the detection of skew.
+//
+// This value defaults to 500 milliseconds.
+func MaxClockSkew(threshold time.Duration) Option {
+	return func(po *postgresOptions) {
+		po.maxClockSkew = threshold
+	}
+}
@@ -6,12 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/sync/errgroup"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/alecthomas/units"
+	"github.com/benbjohnson/clock"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/log/zerologadapter"
@@ -24,13 +28,19 @@ import (
 	"go.opentelemetry.io/otel"
 
 	"github.com/authzed/spicedb/internal/datastore"
-	"github.com/authzed/spicedb/internal/datastore/postgres/migrations"
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/queuemetrics"
+	"github.com/authzed/spicedb/pkg/middleware/priority"
 )
 
 const (
+	engineName          = "postgres"
+	lowPriorityPoolName = "spicedb-low-priority"
+
 	tableNamespace   = "namespace_config"
 	tableTransaction = "relation_tuple_transaction"
 	tableTuple       = "relation_tuple"
+	tableMetadata    = "metadata"
 
 	colID               = "id"
 	colTimestamp        = "timestamp"
@@ -43,13 +53,14 @@ const (
 	colUsersetNamespace = "userset_namespace"
 	colUsersetObjectID  = "userset_object_id"
 	colUsersetRelation  = "userset_relation"
+	colMetadata         = "metadata"
+	colTenant           = "tenant"
+	colUniqueID         = "unique_id"
 
 	errUnableToInstantiate = "unable to instantiate datastore: %w"
 	errRevision            = "unable to find revision: %w"
 	errCheckRevision       = "unable to check revision: %w"
 
-	createTxn = "INSERT INTO relation_tuple_transaction DEFAULT VALUES RETURNING id"
-
 	// This is the largest positive integer possible in postgresql
 	liveDeletedTxnID = uint64(9223372036854775807)
 
@@ -80,6 +91,34 @@ var (
 		Name:      "postgres_transactions_cleared",
 		Help:      "number of transactions cleared by postgres garbage collection.",
 	})
+
+	gcNamespacesClearedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "postgres_namespaces_cleared",
+		Help:      "number of superseded namespace definition versions cleared by postgres garbage collection.",
+	})
+
+	gcOldestRevisionGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "postgres_gc_oldest_revision",
+		Help:      "oldest revision surviving postgres garbage collection.",
+	})
+
+	gcRunsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "postgres_gc_runs_total",
+		Help:      "number of postgres garbage collection passes, by trigger.",
+	}, []string{"trigger"})
+
+	gcBatchQueue = queuemetrics.NewQueue("postgres_gc_delete_batch")
+)
+
+const (
+	gcTriggerScheduled = "scheduled"
+	gcTriggerManual    = "manual"
 )
 
 func init() {
@@ -91,8 +130,12 @@ var (
 
 	getRevision = psql.Select("MAX(id)").From(tableTransaction)
 
+	getOldestRevision = psql.Select("MIN(id)").From(tableTransaction)
+
 	getRevisionRange = psql.Select("MIN(id)", "MAX(id)").From(tableTransaction)
 
+	createTxn = psql.Insert(tableTransaction).Columns(colMetadata).Suffix("RETURNING id")
+
 	getNow = psql.Select("NOW()")
 
 	tracer = otel.Tracer("spicedb/internal/datastore/postgres")
@@ -115,41 +158,52 @@ func NewPostgresDatastore(
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
-	// config must be initialized by ParseConfig
-	pgxConfig, err := pgxpool.ParseConfig(url)
+	pgxConfig, err := poolConfig(url, config, config.maxOpenConns)
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
-	if config.maxOpenConns != nil {
-		pgxConfig.MaxConns = int32(*config.maxOpenConns)
-	}
-	if config.minOpenConns != nil {
-		pgxConfig.MinConns = int32(*config.minOpenConns)
-	}
-	if config.connMaxIdleTime != nil {
-		pgxConfig.MaxConnIdleTime = *config.connMaxIdleTime
-	}
-	if config.connMaxLifetime != nil {
-		pgxConfig.MaxConnLifetime = *config.connMaxLifetime
+	var failover *failoverDetector
+	if config.failoverInterval > 0 {
+		failover = newFailoverDetector(config.failoverInterval, config.timeSource)
+		failover.instrument(pgxConfig)
 	}
-	if config.healthCheckPeriod != nil {
-		pgxConfig.HealthCheckPeriod = *config.healthCheckPeriod
-	}
-
-	pgxConfig.ConnConfig.Logger = zerologadapter.NewLogger(log.Logger)
 
 	dbpool, err := pgxpool.ConnectConfig(context.Background(), pgxConfig)
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
+	// Batch and background requests are served from their own pool, if configured,
+	// so that they cannot starve interactive requests of connections.
+	lowPriorityPool := dbpool
+	if config.lowPriorityMaxOpenConns != nil {
+		lowPriorityConfig, err := poolConfig(url, config, config.lowPriorityMaxOpenConns)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+		if failover != nil {
+			failover.instrument(lowPriorityConfig)
+		}
+
+		lowPriorityPool, err = pgxpool.ConnectConfig(context.Background(), lowPriorityConfig)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
 	if config.enablePrometheusStats {
-		collector := NewPgxpoolStatsCollector(dbpool, "spicedb")
+		collector := common.NewPgxpoolStatsCollector(dbpool, "spicedb")
 		err := prometheus.Register(collector)
 		if err != nil {
 			return nil, fmt.Errorf(errUnableToInstantiate, err)
 		}
+		if lowPriorityPool != dbpool {
+			err = prometheus.Register(common.NewPgxpoolStatsCollector(lowPriorityPool, lowPriorityPoolName))
+			if err != nil {
+				return nil, fmt.Errorf(errUnableToInstantiate, err)
+			}
+		}
 		err = prometheus.Register(gcDurationHistogram)
 		if err != nil {
 			return nil, fmt.Errorf(errUnableToInstantiate, err)
@@ -162,6 +216,43 @@ func NewPostgresDatastore(
 		if err != nil {
 			return nil, fmt.Errorf(errUnableToInstantiate, err)
 		}
+		err = prometheus.Register(gcNamespacesClearedGauge)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+		err = prometheus.Register(gcOldestRevisionGauge)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+		err = prometheus.Register(gcRunsCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+		err = prometheus.Register(failoversCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+		err = prometheus.Register(failoverCheckFailuresCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+		err = prometheus.Register(primaryGauge)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
+	healthCheckCtx, cancelHealthCheck := context.WithCancel(context.Background())
+	go common.NewPoolWaitTracker(dbpool).Run(healthCheckCtx)
+	if config.connPingInterval > 0 {
+		go common.NewPoolHealthChecker(dbpool, "spicedb", config.connPingInterval).Run(healthCheckCtx)
+		if lowPriorityPool != dbpool {
+			go common.NewPoolHealthChecker(lowPriorityPool, lowPriorityPoolName, config.connPingInterval).Run(healthCheckCtx)
+		}
+	}
+	if failover != nil {
+		failover.pool = dbpool
+		go failover.Run(healthCheckCtx)
 	}
 
 	gcCtx, cancelGc := context.WithCancel(context.Background())
@@ -169,15 +260,25 @@ func NewPostgresDatastore(
 	datastore := &pgDatastore{
 		dburl:                     url,
 		dbpool:                    dbpool,
+		lowPriorityPool:           lowPriorityPool,
 		watchBufferLength:         config.watchBufferLength,
 		revisionFuzzingTimedelta:  config.revisionFuzzingTimedelta,
 		gcWindowInverted:          -1 * config.gcWindow,
 		gcInterval:                config.gcInterval,
 		gcMaxOperationTime:        config.gcMaxOperationTime,
 		splitAtEstimatedQuerySize: config.splitAtEstimatedQuerySize,
+		splitAtParameterCount:     config.splitAtParameterCount,
+		maxUsersetsPerQuery:       config.maxUsersetsPerQuery,
+		queryHints:                config.queryHints,
+		queryTimeout:              config.queryTimeout,
+		slowQueryLog:              config.slowQueryLog,
 		gcCtx:                     gcCtx,
 		cancelGc:                  cancelGc,
+		cancelHealthCheck:         cancelHealthCheck,
+		timeSource:                config.timeSource,
+		clockSkew:                 common.NewClockSkew(engineName, config.maxClockSkew),
 	}
+	datastore.revisionValidator = common.NewRevisionValidator(datastore.CheckRevision, common.DefaultRevisionValidityCacheDuration)
 
 	// Start a goroutine for garbage collection.
 	if datastore.gcInterval > 0*time.Minute {
@@ -193,19 +294,35 @@ func NewPostgresDatastore(
 type pgDatastore struct {
 	dburl                     string
 	dbpool                    *pgxpool.Pool
+	lowPriorityPool           *pgxpool.Pool
 	watchBufferLength         uint16
 	revisionFuzzingTimedelta  time.Duration
 	gcWindowInverted          time.Duration
 	gcInterval                time.Duration
 	gcMaxOperationTime        time.Duration
 	splitAtEstimatedQuerySize units.Base2Bytes
+	splitAtParameterCount     int
+	maxUsersetsPerQuery       int
+	queryHints                common.QueryHints
+	queryTimeout              time.Duration
+	slowQueryLog              common.SlowQueryLog
+	timeSource                clock.Clock
+	clockSkew                 *common.ClockSkew
+	revisionValidator         *common.RevisionValidator
 
 	gcGroup  *errgroup.Group
 	gcCtx    context.Context
 	cancelGc context.CancelFunc
+
+	// gcLock serializes the garbage collection passes, so that those triggered manually
+	// do not run concurrently with the scheduled ones.
+	gcLock sync.Mutex
+
+	cancelHealthCheck context.CancelFunc
 }
 
 func (pgd *pgDatastore) Close() error {
+	pgd.cancelHealthCheck()
 	pgd.cancelGc()
 
 	if pgd.gcGroup != nil {
@@ -214,6 +331,89 @@ func (pgd *pgDatastore) Close() error {
 	}
 
 	pgd.dbpool.Close()
+	if pgd.lowPriorityPool != pgd.dbpool {
+		pgd.lowPriorityPool.Close()
+	}
+	return nil
+}
+
+// poolForContext returns the connection pool which should serve the request
+// carried by the context, based on its priority class. Only reads made on
+// behalf of a request go through here: writes always use the primary pool so
+// that low priority traffic can never hold back a transaction, and internal
+// housekeeping (revisions, garbage collection, deduplication, unique IDs and
+// diagnostics) stays on the primary pool whichever request triggered it.
+func (pgd *pgDatastore) poolForContext(ctx context.Context) *pgxpool.Pool {
+	if priority.FromContext(ctx).IsLowPriority() {
+		return pgd.lowPriorityPool
+	}
+	return pgd.dbpool
+}
+
+func poolConfig(url string, config postgresOptions, maxOpenConns *int) (*pgxpool.Config, error) {
+	// config must be initialized by ParseConfig
+	pgxConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxOpenConns != nil {
+		pgxConfig.MaxConns = int32(*maxOpenConns)
+	}
+	if config.minOpenConns != nil {
+		pgxConfig.MinConns = int32(*config.minOpenConns)
+	}
+	if config.connMaxIdleTime != nil {
+		pgxConfig.MaxConnIdleTime = *config.connMaxIdleTime
+	}
+	if config.connMaxLifetime != nil {
+		pgxConfig.MaxConnLifetime = *config.connMaxLifetime
+	}
+	if config.healthCheckPeriod != nil {
+		pgxConfig.HealthCheckPeriod = *config.healthCheckPeriod
+	}
+
+	// The minimum cannot exceed the maximum, which may be smaller for secondary pools.
+	if pgxConfig.MinConns > pgxConfig.MaxConns {
+		pgxConfig.MinConns = pgxConfig.MaxConns
+	}
+
+	if config.poolerCompat {
+		if err := checkPoolerCompatible(pgxConfig); err != nil {
+			return nil, err
+		}
+
+		pgxConfig.ConnConfig.PreferSimpleProtocol = true
+		pgxConfig.ConnConfig.BuildStatementCache = nil
+	}
+
+	// When several hosts are configured, such as the members of a replicated cluster,
+	// connections are only established to the one which is the writable primary.
+	if pgxConfig.ConnConfig.ValidateConnect == nil && hasMultipleHosts(pgxConfig.ConnConfig) {
+		pgxConfig.ConnConfig.ValidateConnect = pgconn.ValidateConnectTargetSessionAttrsReadWrite
+	}
+
+	pgxConfig.ConnConfig.Logger = zerologadapter.NewLogger(log.Logger)
+	return pgxConfig, nil
+}
+
+// poolerSafeRuntimeParams are the startup parameters which PgBouncer tracks
+// per client and restores onto server connections, and are therefore safe to
+// set when running behind a pooler in transaction mode.
+var poolerSafeRuntimeParams = map[string]struct{}{
+	"application_name":            {},
+	"client_encoding":             {},
+	"datestyle":                   {},
+	"timezone":                    {},
+	"standard_conforming_strings": {},
+}
+
+func checkPoolerCompatible(pgxConfig *pgxpool.Config) error {
+	for param := range pgxConfig.ConnConfig.RuntimeParams {
+		if _, ok := poolerSafeRuntimeParams[strings.ToLower(param)]; !ok {
+			return fmt.Errorf("runtime parameter `%s` sets session-level state, which is not supported in pooler compatibility mode", param)
+		}
+	}
 	return nil
 }
 
@@ -226,8 +426,10 @@ func (pgd *pgDatastore) runGarbageCollector() error {
 			log.Info().Msg("shutting down garbage collection worker for postgres driver")
 			return pgd.gcCtx.Err()
 
-		case <-time.After(pgd.gcInterval):
-			err := pgd.collectGarbage()
+		case <-pgd.timeSource.After(pgd.gcInterval):
+			ctx, cancel := context.WithTimeout(pgd.gcCtx, pgd.gcMaxOperationTime)
+			_, _, err := pgd.collectGarbage(ctx, gcTriggerScheduled)
+			cancel()
 			if err != nil {
 				log.Warn().Err(err).Msg("error when attempting to perform garbage collection")
 			} else {
@@ -237,18 +439,30 @@ func (pgd *pgDatastore) runGarbageCollector() error {
 	}
 }
 
+// CollectGarbage immediately performs a garbage collection pass, outside of the
+// regular garbage collection interval. This is useful to reclaim space after large
+// deletions.
+func (pgd *pgDatastore) CollectGarbage(ctx context.Context) (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, pgd.gcMaxOperationTime)
+	defer cancel()
+
+	return pgd.collectGarbage(ctx, gcTriggerManual)
+}
+
 func (pgd *pgDatastore) getNow(ctx context.Context) (time.Time, error) {
 	// Retrieve the `now` time from the database.
 	nowSQL, nowArgs, err := getNow.ToSql()
 	if err != nil {
-		return time.Now(), err
+		return pgd.timeSource.Now(), err
 	}
 
 	var now time.Time
+	sentAt := pgd.timeSource.Now()
 	err = pgd.dbpool.QueryRow(datastore.SeparateContextWithTracing(ctx), nowSQL, nowArgs...).Scan(&now)
 	if err != nil {
-		return time.Now(), err
+		return pgd.timeSource.Now(), err
 	}
+	pgd.clockSkew.Observe(ctx, now, sentAt, pgd.timeSource.Now())
 
 	// RelationTupleTransaction is not timezone aware
 	// Explicitly use UTC before using as a query arg
@@ -257,35 +471,35 @@ func (pgd *pgDatastore) getNow(ctx context.Context) (time.Time, error) {
 	return now, nil
 }
 
-func (pgd *pgDatastore) collectGarbage() error {
-	startTime := time.Now()
+func (pgd *pgDatastore) collectGarbage(ctx context.Context, trigger string) (int64, int64, error) {
+	startTime := pgd.timeSource.Now()
 	defer func() {
-		gcDurationHistogram.Observe(time.Since(startTime).Seconds())
+		gcDurationHistogram.Observe(pgd.timeSource.Since(startTime).Seconds())
 	}()
+	gcRunsCounter.WithLabelValues(trigger).Inc()
 
-	ctx, cancel := context.WithTimeout(context.Background(), pgd.gcMaxOperationTime)
-	defer cancel()
+	pgd.gcLock.Lock()
+	defer pgd.gcLock.Unlock()
 
 	// Ensure the database is ready.
 	ready, err := pgd.IsReady(ctx)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
 	if !ready {
 		log.Ctx(ctx).Warn().Msg("cannot perform postgres garbage collection: postgres driver is not yet ready")
-		return nil
+		return 0, 0, nil
 	}
 
 	now, err := pgd.getNow(ctx)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
 	before := now.Add(pgd.gcWindowInverted)
-	log.Ctx(ctx).Debug().Time("before", before).Msg("running postgres garbage collection")
-	_, _, err = pgd.collectGarbageBefore(ctx, before)
-	return err
+	log.Ctx(ctx).Debug().Time("before", before).Str("trigger", trigger).Msg("running postgres garbage collection")
+	return pgd.collectGarbageBefore(ctx, before)
 }
 
 func (pgd *pgDatastore) collectGarbageBefore(ctx context.Context, before time.Time) (int64, int64, error) {
@@ -329,6 +543,16 @@ func (pgd *pgDatastore) collectGarbageForTransaction(ctx context.Context, highes
 	log.Ctx(ctx).Trace().Uint64("highestTransactionId", highest).Int64("relationshipsDeleted", relCount).Msg("deleted stale relationships")
 	gcRelationshipsClearedGauge.Set(float64(relCount))
 
+	// Delete any namespace definition versions with deleted_transaction <= the transaction ID,
+	// which were superseded or deleted before the oldest revision which remains valid.
+	namespaceCount, err := pgd.deleteStaleNamespaces(ctx, highest)
+	if err != nil {
+		return relCount, 0, err
+	}
+
+	log.Ctx(ctx).Trace().Uint64("highestTransactionId", highest).Int64("namespacesDeleted", namespaceCount).Msg("deleted stale namespace definitions")
+	gcNamespacesClearedGauge.Set(float64(namespaceCount))
+
 	// Delete all transaction rows with ID < the transaction ID. We don't delete the transaction
 	// itself to ensure there is always at least one transaction present.
 	transactionCount, err := pgd.batchDelete(ctx, tableTransaction, sq.Lt{colID: highest})
@@ -338,11 +562,51 @@ func (pgd *pgDatastore) collectGarbageForTransaction(ctx context.Context, highes
 
 	log.Ctx(ctx).Trace().Uint64("highestTransactionId", highest).Int64("transactionsDeleted", transactionCount).Msg("deleted stale transactions")
 	gcTransactionsClearedGauge.Set(float64(transactionCount))
+
+	oldest, err := pgd.oldestRevision(ctx)
+	if err != nil {
+		return relCount, transactionCount, err
+	}
+	gcOldestRevisionGauge.Set(float64(oldest))
 	return relCount, transactionCount, nil
 }
 
+// oldestRevision returns the ID of the oldest transaction remaining in the datastore.
+func (pgd *pgDatastore) oldestRevision(ctx context.Context) (uint64, error) {
+	sql, args, err := getOldestRevision.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	value := pgtype.Int8{}
+	err = pgd.dbpool.QueryRow(datastore.SeparateContextWithTracing(ctx), sql, args...).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+
+	var oldest uint64
+	err = value.AssignTo(&oldest)
+	return oldest, err
+}
+
+// deleteStaleNamespaces deletes the namespace definition versions which are not visible at
+// the transaction or any later one. The namespace table has no ID by which to delete them in
+// batches, but holds a version only per schema write, so they are deleted at once.
+func (pgd *pgDatastore) deleteStaleNamespaces(ctx context.Context, highest uint64) (int64, error) {
+	sql, args, err := psql.Delete(tableNamespace).Where(sq.LtOrEq{colDeletedTxn: highest}).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	cr, err := pgd.dbpool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return cr.RowsAffected(), nil
+}
+
 func (pgd *pgDatastore) batchDelete(ctx context.Context, tableName string, filter sqlFilter) (int64, error) {
-	sql, args, err := psql.Select("id").From(tableName).Where(filter).ToSql()
+	sql, args, err := psql.Select("id").From(tableName).Where(filter).Limit(batchDeleteSize).ToSql()
 	if err != nil {
 		return -1, err
 	}
@@ -360,6 +624,7 @@ func (pgd *pgDatastore) batchDelete(ctx context.Context, tableName string, filte
 		}
 
 		rowsDeleted := cr.RowsAffected()
+		gcBatchQueue.ObserveBatch(int(rowsDeleted), batchDeleteSize)
 		deletedCount += rowsDeleted
 		if rowsDeleted < batchDeleteSize {
 			break
@@ -370,18 +635,7 @@ func (pgd *pgDatastore) batchDelete(ctx context.Context, tableName string, filte
 }
 
 func (pgd *pgDatastore) IsReady(ctx context.Context) (bool, error) {
-	headMigration, err := migrations.DatabaseMigrations.HeadRevision()
-	if err != nil {
-		return false, fmt.Errorf("invalid head migration found for postgres: %w", err)
-	}
-
-	currentRevision, err := migrations.NewAlembicPostgresDriver(pgd.dburl)
-	if err != nil {
-		return false, err
-	}
-	defer currentRevision.Dispose()
-
-	version, err := currentRevision.Version()
+	version, headMigration, err := pgd.migrationRevisions()
 	if err != nil {
 		return false, err
 	}
@@ -434,10 +688,8 @@ func (pgd *pgDatastore) CheckRevision(ctx context.Context, revision datastore.Re
 
 	lower, upper, err := pgd.computeRevisionRange(ctx, pgd.gcWindowInverted)
 	if err == nil {
-		if revisionTx < lower {
-			return datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
-		} else if revisionTx > upper {
-			return datastore.NewInvalidRevisionErr(revision, datastore.RevisionInFuture)
+		if revisionTx < lower || revisionTx > upper {
+			return datastore.NewRevisionOutsideWindowErr(revision, revisionFromTransaction(lower), revisionFromTransaction(upper))
 		}
 
 		return nil
@@ -464,10 +716,8 @@ func (pgd *pgDatastore) CheckRevision(ctx context.Context, revision datastore.Re
 		return fmt.Errorf(errCheckRevision, err)
 	}
 
-	if revisionTx < highest {
-		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
-	} else if revisionTx > highest {
-		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionInFuture)
+	if revisionTx != highest {
+		return datastore.NewRevisionOutsideWindowErr(revision, revisionFromTransaction(highest), revisionFromTransaction(highest))
 	}
 
 	return nil
@@ -542,7 +792,22 @@ func createNewTransaction(ctx context.Context, tx pgx.Tx) (newTxnID uint64, err
 	ctx, span := tracer.Start(ctx, "computeNewTransaction")
 	defer span.End()
 
-	err = tx.QueryRow(ctx, createTxn).Scan(&newTxnID)
+	// Record the origin of the transaction, if known, so that it can be surfaced to
+	// consumers of the change feed.
+	metadata := pgtype.JSONB{Status: pgtype.Null}
+	if txMetadata := datastore.TransactionMetadataFromContext(ctx); txMetadata != nil {
+		err = metadata.Set(txMetadata)
+		if err != nil {
+			return
+		}
+	}
+
+	sql, args, err := createTxn.Values(metadata).ToSql()
+	if err != nil {
+		return
+	}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&newTxnID)
 	return
 }
 
@@ -7,21 +7,26 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alecthomas/units"
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/benbjohnson/clock"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/ory/dockertest/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
 	"github.com/authzed/spicedb/internal/datastore/postgres/migrations"
-	"github.com/authzed/spicedb/internal/datastore/test"
 	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore/benchmark"
+	"github.com/authzed/spicedb/pkg/datastore/test"
 	"github.com/authzed/spicedb/pkg/migrate"
 	"github.com/authzed/spicedb/pkg/namespace"
 	"github.com/authzed/spicedb/pkg/secrets"
@@ -86,7 +91,28 @@ func TestPostgresDatastore(t *testing.T) {
 	tester := newTester(postgresContainer, "postgres:secret", 5432)
 	defer tester.cleanup()
 
-	test.All(t, tester)
+	test.RunAll(t, tester)
+}
+
+func TestPostgresDifferential(t *testing.T) {
+	tester := newTester(postgresContainer, "postgres:secret", 5432)
+	defer tester.cleanup()
+
+	oracle := test.DatastoreBuilder(func(revisionFuzzingTimedelta, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error) {
+		return memdb.NewMemdbDatastore(watchBufferLength, revisionFuzzingTimedelta, gcWindow, 0)
+	})
+	test.RunDifferential(t, oracle, tester, time.Now().UnixNano(), 500)
+}
+
+func BenchmarkPostgresDatastore(b *testing.B) {
+	tester := newTester(postgresContainer, "postgres:secret", 5432)
+	defer tester.cleanup()
+
+	ds, err := tester.New(0, 24*time.Hour, 1)
+	require.NoError(b, err)
+	defer ds.Close()
+
+	benchmark.RunBenchmarks(b, ds)
 }
 
 func TestPostgresDatastoreWithSplit(t *testing.T) {
@@ -95,7 +121,7 @@ func TestPostgresDatastoreWithSplit(t *testing.T) {
 	tester.splitAtEstimatedQuerySize = 1 // bytes
 	defer tester.cleanup()
 
-	test.All(t, tester)
+	test.RunAll(t, tester)
 }
 
 func TestPostgresGarbageCollection(t *testing.T) {
@@ -360,6 +386,72 @@ func TestPostgresGarbageCollectionByTime(t *testing.T) {
 	tRequire.NoTupleExists(ctx, tpl, relDeletedAt)
 }
 
+func TestPostgresManualGarbageCollection(t *testing.T) {
+	require := require.New(t)
+
+	tester := newTester(postgresContainer, "postgres:secret", 5432)
+	defer tester.cleanup()
+
+	ds, err := tester.New(0, time.Millisecond*1, 1)
+	require.NoError(err)
+	defer ds.Close()
+
+	ctx := context.Background()
+	_, err = ds.WriteNamespace(ctx, namespace.Namespace(
+		"resource",
+		namespace.Relation("reader", nil),
+	))
+	require.NoError(err)
+
+	_, err = ds.WriteNamespace(ctx, namespace.Namespace("user"))
+	require.NoError(err)
+
+	relationship := tuple.MustToRelationship(tuple.MustParse("resource:someresource#reader@user:someuser#..."))
+	_, err = ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{{
+		Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+		Relationship: relationship,
+	}})
+	require.NoError(err)
+
+	relDeletedAt, err := ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{{
+		Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+		Relationship: relationship,
+	}})
+	require.NoError(err)
+
+	// Sleep to ensure the deletion is outside of the GC window.
+	time.Sleep(5 * time.Millisecond)
+
+	manualRuns := testutil.ToFloat64(gcRunsCounter.WithLabelValues(gcTriggerManual))
+
+	// Concurrent passes are serialized, so the relationship is only removed once.
+	gc := ds.(datastore.GarbageCollector)
+	var relsDeleted int64
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			removed, _, err := gc.CollectGarbage(ctx)
+			require.NoError(err)
+
+			lock.Lock()
+			defer lock.Unlock()
+			relsDeleted += removed
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(int64(1), relsDeleted)
+	require.Equal(manualRuns+2, testutil.ToFloat64(gcRunsCounter.WithLabelValues(gcTriggerManual)))
+	require.Equal(float64(0), testutil.ToFloat64(gcRelationshipsClearedGauge))
+
+	// The oldest revision which survives is that of the deletion, as the transactions
+	// before it were removed.
+	require.Equal(float64(relDeletedAt.IntPart()), testutil.ToFloat64(gcOldestRevisionGauge))
+}
+
 const chunkRelationshipCount = 2000
 
 func TestPostgresChunkedGarbageCollection(t *testing.T) {
@@ -477,6 +569,104 @@ func TestPostgresChunkedGarbageCollection(t *testing.T) {
 	require.NoError(err)
 }
 
+func TestPostgresNamespaceGarbageCollection(t *testing.T) {
+	require := require.New(t)
+
+	tester := newTester(postgresContainer, "postgres:secret", 5432)
+	defer tester.cleanup()
+
+	ds, err := tester.New(0, time.Millisecond*1, 1)
+	require.NoError(err)
+	defer ds.Close()
+
+	ctx := context.Background()
+	pds := ds.(*pgDatastore)
+
+	countVersions := func() int {
+		var count int
+		require.NoError(pds.dbpool.QueryRow(ctx, "SELECT COUNT(*) FROM namespace_config").Scan(&count))
+		return count
+	}
+
+	// Write three versions of a namespace, and another namespace which is then deleted.
+	_, err = ds.WriteNamespace(ctx, namespace.Namespace("resource"))
+	require.NoError(err)
+	secondAt, err := ds.WriteNamespace(ctx, namespace.Namespace("resource", namespace.Relation("reader", nil)))
+	require.NoError(err)
+	_, err = ds.WriteNamespace(ctx, namespace.Namespace("user"))
+	require.NoError(err)
+	_, err = ds.DeleteNamespace(ctx, "user")
+	require.NoError(err)
+	lastAt, err := ds.WriteNamespace(ctx, namespace.Namespace("resource", namespace.Relation("writer", nil)))
+	require.NoError(err)
+	require.Equal(4, countVersions())
+
+	// Collecting at the second version only removes the first, which it superseded.
+	_, _, err = pds.collectGarbageForTransaction(ctx, uint64(secondAt.IntPart()))
+	require.NoError(err)
+	require.Equal(3, countVersions())
+
+	found, _, err := ds.ReadNamespace(ctx, "resource", secondAt)
+	require.NoError(err)
+	require.Len(found.Relation, 1)
+	require.Equal("reader", found.Relation[0].Name)
+
+	// Collecting at the last version removes the second version and the deleted namespace.
+	_, _, err = pds.collectGarbageForTransaction(ctx, uint64(lastAt.IntPart()))
+	require.NoError(err)
+	require.Equal(1, countVersions())
+
+	found, _, err = ds.ReadNamespace(ctx, "resource", lastAt)
+	require.NoError(err)
+	require.Equal("writer", found.Relation[0].Name)
+
+	_, _, err = ds.ReadNamespace(ctx, "user", lastAt)
+	require.ErrorAs(err, &datastore.ErrNamespaceNotFound{})
+}
+
+func TestPostgresFailoverReestablishesConnections(t *testing.T) {
+	require := require.New(t)
+
+	tester := newTester(postgresContainer, "postgres:secret", 5432)
+	defer tester.cleanup()
+
+	ds, err := tester.New(0, 24*time.Hour, 1)
+	require.NoError(err)
+	defer ds.Close()
+
+	ctx := context.Background()
+	pds := ds.(*pgDatastore)
+
+	// Instrument a pool of the datastore as if the detection of failovers were enabled.
+	fd := newFailoverDetector(time.Hour, clock.NewMock())
+	pgxConfig, err := poolConfig(pds.dburl, postgresOptions{}, nil)
+	require.NoError(err)
+	fd.instrument(pgxConfig)
+	fd.pool, err = pgxpool.ConnectConfig(ctx, pgxConfig)
+	require.NoError(err)
+	defer fd.pool.Close()
+
+	backendPID := func() uint32 {
+		conn, err := fd.pool.Acquire(ctx)
+		require.NoError(err)
+		defer conn.Release()
+		return conn.Conn().PgConn().PID()
+	}
+
+	require.NoError(fd.check(ctx))
+	primary := fd.primary
+	require.NotEmpty(primary)
+
+	pid := backendPID()
+	require.Equal(pid, backendPID())
+
+	// Once another primary is observed, the connections established until then are replaced.
+	fd.primary = "192.0.2.1:5432"
+	require.NoError(fd.check(ctx))
+	require.Equal(primary, fd.primary)
+	require.NotEqual(pid, backendPID())
+}
+
 func BenchmarkPostgresQuery(b *testing.B) {
 	req := require.New(b)
 
@@ -26,15 +26,20 @@ var schema = common.SchemaInformation{
 	ColUsersetNamespace: colUsersetNamespace,
 	ColUsersetObjectID:  colUsersetObjectID,
 	ColUsersetRelation:  colUsersetRelation,
+	ColTenant:           colTenant,
+	OrderCollation:      "C",
 }
 
+// revisionFilter limits tuple queries to the rows which were live at the revision.
+var revisionFilter = common.RevisionFilter{FilterQuery: filterToLivingObjects}
+
 func (pgd *pgDatastore) QueryTuples(
 	ctx context.Context,
 	filter *v1.RelationshipFilter,
 	revision datastore.Revision,
 	opts ...options.QueryOptionsOption,
 ) (iter datastore.TupleIterator, err error) {
-	qBuilder := common.NewSchemaQueryFilterer(schema, filterToLivingObjects(queryTuples, revision)).
+	qBuilder := common.NewSchemaQueryFilterer(schema, queryTuples).
 		FilterToResourceType(filter.ResourceType)
 
 	if filter.OptionalResourceId != "" {
@@ -51,17 +56,30 @@ func (pgd *pgDatastore) QueryTuples(
 
 	queryOpts := options.NewQueryOptionsWithOptions(opts...)
 
+	if queryOpts.AfterResourceID != "" {
+		qBuilder = qBuilder.FilterToResourceIDsAfter(queryOpts.AfterResourceID)
+	}
+
+	qBuilder = qBuilder.WithHint(common.QueryShapeQueryTuples, pgd.queryHints, common.CommentQueryHinter)
+
 	ctq := common.TupleQuerySplitter{
-		Conn:                      pgd.dbpool,
-		PrepareTransaction:        nil,
+		Conn:                      pgd.poolForContext(ctx),
+		RevisionFilter:            revisionFilter,
 		SplitAtEstimatedQuerySize: pgd.splitAtEstimatedQuerySize,
+		SplitAtParameterCount:     pgd.splitAtParameterCount,
+		MaxUsersetsPerQuery:       pgd.maxUsersetsPerQuery,
 
 		FilteredQueryBuilder: qBuilder,
 		Revision:             revision,
+		RevisionValidator:    pgd.revisionValidator,
 		Limit:                queryOpts.Limit,
 		Usersets:             queryOpts.Usersets,
+		Order:                queryOpts.Order,
+		Timeout:              common.QueryTimeout(queryOpts.Timeout, pgd.queryTimeout),
+		SlowQueryLog:         pgd.slowQueryLog,
 
 		Tracer:    tracer,
+		Engine:    engineName,
 		DebugName: "QueryTuples",
 	}
 
@@ -74,7 +92,7 @@ func (pgd *pgDatastore) ReverseQueryTuples(
 	revision datastore.Revision,
 	opts ...options.ReverseQueryOptionsOption,
 ) (iter datastore.TupleIterator, err error) {
-	qBuilder := common.NewSchemaQueryFilterer(schema, filterToLivingObjects(queryTuples, revision)).
+	qBuilder := common.NewSchemaQueryFilterer(schema, queryTuples).
 		FilterToSubjectFilter(subjectFilter)
 
 	queryOpts := options.NewReverseQueryOptionsWithOptions(opts...)
@@ -85,17 +103,25 @@ func (pgd *pgDatastore) ReverseQueryTuples(
 			FilterToRelation(queryOpts.ResRelation.Relation)
 	}
 
+	qBuilder = qBuilder.WithHint(common.QueryShapeReverseQueryTuples, pgd.queryHints, common.CommentQueryHinter)
+
 	ctq := common.TupleQuerySplitter{
-		Conn:                      pgd.dbpool,
-		PrepareTransaction:        nil,
+		Conn:                      pgd.poolForContext(ctx),
+		RevisionFilter:            revisionFilter,
 		SplitAtEstimatedQuerySize: pgd.splitAtEstimatedQuerySize,
+		SplitAtParameterCount:     pgd.splitAtParameterCount,
+		MaxUsersetsPerQuery:       pgd.maxUsersetsPerQuery,
 
 		FilteredQueryBuilder: qBuilder,
 		Revision:             revision,
+		RevisionValidator:    pgd.revisionValidator,
 		Limit:                queryOpts.ReverseLimit,
-		Usersets:             nil,
+		SubjectIDs:           queryOpts.SubjectIDs,
+		Timeout:              common.QueryTimeout(queryOpts.ReverseTimeout, pgd.queryTimeout),
+		SlowQueryLog:         pgd.slowQueryLog,
 
 		Tracer:    tracer,
+		Engine:    engineName,
 		DebugName: "ReverseQueryTuples",
 	}
 
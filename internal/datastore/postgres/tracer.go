@@ -5,6 +5,8 @@ import (
 	"database/sql/driver"
 
 	"github.com/ngrok/sqlmw"
+
+	"github.com/authzed/spicedb/internal/datastore"
 )
 
 type traceInterceptor struct {
@@ -12,35 +14,35 @@ type traceInterceptor struct {
 }
 
 func (ti *traceInterceptor) ConnBeginTx(ctx context.Context, conn driver.ConnBeginTx, opts driver.TxOptions) (driver.Tx, error) {
-	ctx, span := tracer.Start(ctx, "ConnBeginTx")
+	ctx, span := datastore.StartSpan(ctx, tracer, "ConnBeginTx")
 	defer span.End()
 
 	return conn.BeginTx(ctx, opts)
 }
 
 func (ti *traceInterceptor) ConnPrepareContext(ctx context.Context, conn driver.ConnPrepareContext, query string) (driver.Stmt, error) {
-	ctx, span := tracer.Start(ctx, "ConnPrepareContext")
+	ctx, span := datastore.StartSpan(ctx, tracer, "ConnPrepareContext")
 	defer span.End()
 
 	return conn.PrepareContext(ctx, query)
 }
 
 func (ti *traceInterceptor) ConnPing(ctx context.Context, conn driver.Pinger) error {
-	ctx, span := tracer.Start(ctx, "ConnPing")
+	ctx, span := datastore.StartSpan(ctx, tracer, "ConnPing")
 	defer span.End()
 
 	return conn.Ping(ctx)
 }
 
 func (ti *traceInterceptor) ConnExecContext(ctx context.Context, conn driver.ExecerContext, query string, args []driver.NamedValue) (driver.Result, error) {
-	ctx, span := tracer.Start(ctx, "ConnExecContext")
+	ctx, span := datastore.StartSpan(ctx, tracer, "ConnExecContext")
 	defer span.End()
 
 	return conn.ExecContext(ctx, query, args)
 }
 
 func (ti *traceInterceptor) ConnQueryContext(ctx context.Context, conn driver.QueryerContext, query string, args []driver.NamedValue) (driver.Rows, error) {
-	ctx, span := tracer.Start(ctx, "ConnQueryContext")
+	ctx, span := datastore.StartSpan(ctx, tracer, "ConnQueryContext")
 	defer span.End()
 
 	return conn.QueryContext(ctx, query, args)
@@ -48,7 +50,7 @@ func (ti *traceInterceptor) ConnQueryContext(ctx context.Context, conn driver.Qu
 
 // Connector interceptors
 func (ti *traceInterceptor) ConnectorConnect(ctx context.Context, conn driver.Connector) (driver.Conn, error) {
-	ctx, span := tracer.Start(ctx, "ConnectorConnect")
+	ctx, span := datastore.StartSpan(ctx, tracer, "ConnectorConnect")
 	defer span.End()
 
 	return conn.Connect(ctx)
@@ -56,7 +58,7 @@ func (ti *traceInterceptor) ConnectorConnect(ctx context.Context, conn driver.Co
 
 // Rows interceptors
 func (ti *traceInterceptor) RowsNext(ctx context.Context, conn driver.Rows, dest []driver.Value) error {
-	_, span := tracer.Start(ctx, "RowsNext")
+	_, span := datastore.StartSpan(ctx, tracer, "RowsNext")
 	defer span.End()
 
 	return conn.Next(dest)
@@ -64,21 +66,21 @@ func (ti *traceInterceptor) RowsNext(ctx context.Context, conn driver.Rows, dest
 
 // Stmt interceptors
 func (ti *traceInterceptor) StmtExecContext(ctx context.Context, conn driver.StmtExecContext, query string, args []driver.NamedValue) (driver.Result, error) {
-	ctx, span := tracer.Start(ctx, "StmtExecContext")
+	ctx, span := datastore.StartSpan(ctx, tracer, "StmtExecContext")
 	defer span.End()
 
 	return conn.ExecContext(ctx, args)
 }
 
 func (ti *traceInterceptor) StmtQueryContext(ctx context.Context, conn driver.StmtQueryContext, query string, args []driver.NamedValue) (driver.Rows, error) {
-	ctx, span := tracer.Start(ctx, "StmtQueryContext")
+	ctx, span := datastore.StartSpan(ctx, tracer, "StmtQueryContext")
 	defer span.End()
 
 	return conn.QueryContext(ctx, args)
 }
 
 func (ti *traceInterceptor) StmtClose(ctx context.Context, conn driver.Stmt) error {
-	_, span := tracer.Start(ctx, "StmtClose")
+	_, span := datastore.StartSpan(ctx, tracer, "StmtClose")
 	defer span.End()
 
 	return conn.Close()
@@ -86,14 +88,14 @@ func (ti *traceInterceptor) StmtClose(ctx context.Context, conn driver.Stmt) err
 
 // Tx interceptors
 func (ti *traceInterceptor) TxCommit(ctx context.Context, conn driver.Tx) error {
-	_, span := tracer.Start(ctx, "TxCommit")
+	_, span := datastore.StartSpan(ctx, tracer, "TxCommit")
 	defer span.End()
 
 	return conn.Commit()
 }
 
 func (ti *traceInterceptor) TxRollback(ctx context.Context, conn driver.Tx) error {
-	_, span := tracer.Start(ctx, "TxRollback")
+	_, span := datastore.StartSpan(ctx, tracer, "TxRollback")
 	defer span.End()
 
 	return conn.Rollback()
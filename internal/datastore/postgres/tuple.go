@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -13,11 +14,16 @@ import (
 
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/redact"
 )
 
 const (
 	errUnableToWriteTuples  = "unable to write tuples: %w"
 	errUnableToDeleteTuples = "unable to delete tuples: %w"
+
+	// parametersPerRelationship is the most parameters bound to a write statement for each
+	// relationship: the eight values of an inserted row, or the seven of a deleted one.
+	parametersPerRelationship = 8
 )
 
 var (
@@ -29,15 +35,18 @@ var (
 		colUsersetObjectID,
 		colUsersetRelation,
 		colCreatedTxn,
+		colTenant,
 	)
 
 	deleteTuple = psql.Update(tableTuple).Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
 
-	queryTupleExists = psql.Select(colID).From(tableTuple)
+	queryTupleExists = psql.Select(colID).From(tableTuple).Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
 )
 
-func selectQueryForFilter(filter *v1.RelationshipFilter) sq.SelectBuilder {
-	query := queryTupleExists.Where(sq.Eq{colNamespace: filter.ResourceType})
+// filterRelationships limits a query over the tuple table to the relationships of the
+// tenant matching the filter.
+func filterRelationships(query sq.SelectBuilder, tenant string, filter *v1.RelationshipFilter) sq.SelectBuilder {
+	query = query.Where(sq.Eq{colTenant: tenant, colNamespace: filter.ResourceType})
 
 	if filter.OptionalResourceId != "" {
 		query = query.Where(sq.Eq{colObjectID: filter.OptionalResourceId})
@@ -66,7 +75,11 @@ func (pgd *pgDatastore) checkPreconditions(ctx context.Context, tx pgx.Tx, preco
 	for _, precond := range preconditions {
 		switch precond.Operation {
 		case v1.Precondition_OPERATION_MUST_NOT_MATCH, v1.Precondition_OPERATION_MUST_MATCH:
-			sql, args, err := selectQueryForFilter(precond.Filter).Limit(1).ToSql()
+			sql, args, err := common.NewSchemaQueryFilterer(schema, queryTupleExists).
+				FilterToTenant(datastore.TenantFromContext(ctx)).
+				FilterToRelationshipFilter(precond.Filter).
+				Limit(1).
+				ToSql()
 			if err != nil {
 				return err
 			}
@@ -98,6 +111,11 @@ func (pgd *pgDatastore) WriteTuples(ctx context.Context, preconditions []*v1.Pre
 	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "WriteTuples")
 	defer span.End()
 
+	statementCount := 0
+	defer func(start time.Time) {
+		common.ObserveOperationLatency("WriteTuples", engineName, statementCount, start)
+	}(time.Now())
+
 	tx, err := pgd.dbpool.Begin(ctx)
 	if err != nil {
 		return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
@@ -113,25 +131,44 @@ func (pgd *pgDatastore) WriteTuples(ctx context.Context, preconditions []*v1.Pre
 		return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
 	}
 
-	bulkWrite := writeTuple
-	bulkWriteHasValues := false
-
-	// Process the actual updates
+	var deletes, writes []*v1.Relationship
 	for _, mut := range mutations {
-		rel := mut.Relationship
-
 		if mut.Operation == v1.RelationshipUpdate_OPERATION_TOUCH || mut.Operation == v1.RelationshipUpdate_OPERATION_DELETE {
-			sql, args, err := deleteTuple.Where(exactRelationshipClause(rel)).Set(colDeletedTxn, newTxnID).ToSql()
-			if err != nil {
-				return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
-			}
+			deletes = append(deletes, mut.Relationship)
+		}
+		if mut.Operation == v1.RelationshipUpdate_OPERATION_TOUCH || mut.Operation == v1.RelationshipUpdate_OPERATION_CREATE {
+			writes = append(writes, mut.Relationship)
+		}
+	}
 
-			if _, err := tx.Exec(ctx, sql, args...); err != nil {
-				return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
-			}
+	splitter := common.WriteSplitter{
+		SplitAtEstimatedQuerySize: pgd.splitAtEstimatedQuerySize,
+		ParametersPerRelationship: parametersPerRelationship,
+	}
+	tenant := datastore.TenantFromContext(ctx)
+
+	// Relationships are deleted before any are written, so that touched relationships are
+	// replaced rather than removed.
+	for _, chunk := range splitter.Split(deletes) {
+		clauses := make(sq.Or, 0, len(chunk))
+		for _, rel := range chunk {
+			clauses = append(clauses, exactRelationshipClause(tenant, rel))
 		}
 
-		if mut.Operation == v1.RelationshipUpdate_OPERATION_TOUCH || mut.Operation == v1.RelationshipUpdate_OPERATION_CREATE {
+		sql, args, err := deleteTuple.Where(clauses).Set(colDeletedTxn, newTxnID).ToSql()
+		if err != nil {
+			return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
+		}
+
+		statementCount++
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
+		}
+	}
+
+	for _, chunk := range splitter.Split(writes) {
+		bulkWrite := writeTuple
+		for _, rel := range chunk {
 			bulkWrite = bulkWrite.Values(
 				rel.Resource.ObjectType,
 				rel.Resource.ObjectId,
@@ -140,19 +177,17 @@ func (pgd *pgDatastore) WriteTuples(ctx context.Context, preconditions []*v1.Pre
 				rel.Subject.Object.ObjectId,
 				stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
 				newTxnID,
+				tenant,
 			)
-			bulkWriteHasValues = true
 		}
-	}
 
-	if bulkWriteHasValues {
 		sql, args, err := bulkWrite.ToSql()
 		if err != nil {
 			return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
 		}
 
-		_, err = tx.Exec(ctx, sql, args...)
-		if err != nil {
+		statementCount++
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
 			return datastore.NoRevision, fmt.Errorf(errUnableToWriteTuples, err)
 		}
 	}
@@ -165,8 +200,9 @@ func (pgd *pgDatastore) WriteTuples(ctx context.Context, preconditions []*v1.Pre
 	return revisionFromTransaction(newTxnID), nil
 }
 
-func exactRelationshipClause(r *v1.Relationship) sq.Eq {
+func exactRelationshipClause(tenant string, r *v1.Relationship) sq.Eq {
 	return sq.Eq{
+		colTenant:           tenant,
 		colNamespace:        r.Resource.ObjectType,
 		colObjectID:         r.Resource.ObjectId,
 		colRelation:         r.Relation,
@@ -179,6 +215,7 @@ func exactRelationshipClause(r *v1.Relationship) sq.Eq {
 func (pgd *pgDatastore) DeleteRelationships(ctx context.Context, preconditions []*v1.Precondition, filter *v1.RelationshipFilter) (datastore.Revision, error) {
 	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "DeleteRelationships")
 	defer span.End()
+	defer common.ObserveOperationLatency("DeleteRelationships", engineName, 1, time.Now())
 
 	tx, err := pgd.dbpool.Begin(ctx)
 	if err != nil {
@@ -191,28 +228,31 @@ func (pgd *pgDatastore) DeleteRelationships(ctx context.Context, preconditions [
 	}
 
 	// Add clauses for the ResourceFilter
-	query := deleteTuple.Where(sq.Eq{colNamespace: filter.ResourceType})
-	tracerAttributes := []attribute.KeyValue{common.ObjNamespaceNameKey.String(filter.ResourceType)}
+	query := deleteTuple.Where(sq.Eq{
+		colTenant:    datastore.TenantFromContext(ctx),
+		colNamespace: filter.ResourceType,
+	})
+	tracerAttributes := []attribute.KeyValue{common.ObjNamespaceNameKey.String(redact.Type(filter.ResourceType))}
 	if filter.OptionalResourceId != "" {
 		query = query.Where(sq.Eq{colObjectID: filter.OptionalResourceId})
-		tracerAttributes = append(tracerAttributes, common.ObjIDKey.String(filter.OptionalResourceId))
+		tracerAttributes = append(tracerAttributes, common.ObjIDKey.String(redact.ID(filter.OptionalResourceId)))
 	}
 	if filter.OptionalRelation != "" {
 		query = query.Where(sq.Eq{colRelation: filter.OptionalRelation})
-		tracerAttributes = append(tracerAttributes, common.ObjRelationNameKey.String(filter.OptionalRelation))
+		tracerAttributes = append(tracerAttributes, common.ObjRelationNameKey.String(redact.Relation(filter.OptionalRelation)))
 	}
 
 	// Add clauses for the SubjectFilter
 	if subjectFilter := filter.OptionalSubjectFilter; subjectFilter != nil {
 		query = query.Where(sq.Eq{colUsersetNamespace: subjectFilter.SubjectType})
-		tracerAttributes = append(tracerAttributes, common.SubNamespaceNameKey.String(subjectFilter.SubjectType))
+		tracerAttributes = append(tracerAttributes, common.SubNamespaceNameKey.String(redact.Type(subjectFilter.SubjectType)))
 		if subjectFilter.OptionalSubjectId != "" {
 			query = query.Where(sq.Eq{colUsersetObjectID: subjectFilter.OptionalSubjectId})
-			tracerAttributes = append(tracerAttributes, common.SubObjectIDKey.String(subjectFilter.OptionalSubjectId))
+			tracerAttributes = append(tracerAttributes, common.SubObjectIDKey.String(redact.ID(subjectFilter.OptionalSubjectId)))
 		}
 		if relationFilter := subjectFilter.OptionalRelation; relationFilter != nil {
 			query = query.Where(sq.Eq{colUsersetRelation: stringz.DefaultEmpty(relationFilter.Relation, datastore.Ellipsis)})
-			tracerAttributes = append(tracerAttributes, common.SubRelationNameKey.String(relationFilter.Relation))
+			tracerAttributes = append(tracerAttributes, common.SubRelationNameKey.String(redact.Relation(relationFilter.Relation)))
 		}
 	}
 
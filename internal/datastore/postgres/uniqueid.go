@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+const errUnableToReadUniqueID = "unable to read unique ID: %w"
+
+var queryUniqueID = psql.Select(colUniqueID).From(tableMetadata)
+
+// UniqueID implements datastore.Identifier.
+func (pgd *pgDatastore) UniqueID(ctx context.Context) (string, error) {
+	ctx, span := tracer.Start(ctx, "UniqueID")
+	defer span.End()
+
+	sql, args, err := queryUniqueID.ToSql()
+	if err != nil {
+		return "", fmt.Errorf(errUnableToReadUniqueID, err)
+	}
+
+	var uniqueID string
+	if err := pgd.dbpool.QueryRow(datastore.SeparateContextWithTracing(ctx), sql, args...).Scan(&uniqueID); err != nil {
+		return "", fmt.Errorf(errUnableToReadUniqueID, err)
+	}
+	return uniqueID, nil
+}
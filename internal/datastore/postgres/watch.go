@@ -7,9 +7,12 @@ import (
 
 	sq "github.com/Masterminds/squirrel"
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jackc/pgtype"
 
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/options"
 )
 
 const (
@@ -27,20 +30,29 @@ var queryChanged = psql.Select(
 	colDeletedTxn,
 ).From(tableTuple)
 
-func (pgd *pgDatastore) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
+var queryTransactionMetadata = psql.Select(
+	colID,
+	colMetadata,
+).From(tableTransaction)
+
+var queryChangedNamespaces = psql.Select(colNamespace).Distinct().From(tableNamespace)
+
+func (pgd *pgDatastore) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	watchOpts := options.NewWatchOptionsWithOptions(opts...)
 	updates := make(chan *datastore.RevisionChanges, pgd.watchBufferLength)
 	errs := make(chan error, 1)
 
 	go func() {
 		defer close(updates)
 		defer close(errs)
+		defer datastore.WatchBufferQueue.Track(func() int { return len(updates) }, cap(updates))()
 
 		currentTxn := transactionFromRevision(afterRevision)
 
 		for {
 			var stagedUpdates []*datastore.RevisionChanges
 			var err error
-			stagedUpdates, currentTxn, err = pgd.loadChanges(ctx, currentTxn)
+			stagedUpdates, currentTxn, err = pgd.loadChanges(ctx, currentTxn, watchOpts.Filters)
 			if err != nil {
 				if errors.Is(ctx.Err(), context.Canceled) {
 					errs <- datastore.NewWatchCanceledErr()
@@ -62,7 +74,7 @@ func (pgd *pgDatastore) Watch(ctx context.Context, afterRevision datastore.Revis
 
 			// If there were no changes, sleep a bit
 			if len(stagedUpdates) == 0 {
-				sleep := time.NewTimer(watchSleep)
+				sleep := pgd.timeSource.Timer(watchSleep)
 
 				select {
 				case <-sleep.C:
@@ -81,6 +93,7 @@ func (pgd *pgDatastore) Watch(ctx context.Context, afterRevision datastore.Revis
 func (pgd *pgDatastore) loadChanges(
 	ctx context.Context,
 	afterRevision uint64,
+	filters []*v1.RelationshipFilter,
 ) (changes []*datastore.RevisionChanges, newRevision uint64, err error) {
 	newRevision, err = pgd.loadRevision(ctx)
 	if err != nil {
@@ -91,7 +104,7 @@ func (pgd *pgDatastore) loadChanges(
 		return
 	}
 
-	sql, args, err := queryChanged.Where(sq.Or{
+	query := common.NewSchemaQueryFilterer(schema, queryChanged.Where(sq.Or{
 		sq.And{
 			sq.Gt{colCreatedTxn: afterRevision},
 			sq.LtOrEq{colCreatedTxn: newRevision},
@@ -100,12 +113,17 @@ func (pgd *pgDatastore) loadChanges(
 			sq.Gt{colDeletedTxn: afterRevision},
 			sq.LtOrEq{colDeletedTxn: newRevision},
 		},
-	}).ToSql()
+	})).FilterToTenant(datastore.TenantFromContext(ctx))
+	if len(filters) > 0 {
+		query = query.FilterToAnyRelationshipFilter(filters)
+	}
+
+	sql, args, err := query.ToSql()
 	if err != nil {
 		return
 	}
 
-	rows, err := pgd.dbpool.Query(ctx, sql, args...)
+	rows, err := pgd.poolForContext(ctx).Query(ctx, sql, args...)
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			err = datastore.NewWatchCanceledErr()
@@ -154,7 +172,150 @@ func (pgd *pgDatastore) loadChanges(
 		return
 	}
 
+	// The new revision is reported as progress even if the transactions had no changes of
+	// relationships, or they were filtered out and so not loaded.
+	stagedChanges.AddRevision(newRevision)
+
+	if err = pgd.loadTransactionMetadata(ctx, stagedChanges, afterRevision, newRevision); err != nil {
+		return
+	}
+
 	changes = stagedChanges.AsRevisionChanges()
 
 	return
 }
+
+func (pgd *pgDatastore) loadTransactionMetadata(
+	ctx context.Context,
+	stagedChanges common.Changes,
+	afterRevision uint64,
+	newRevision uint64,
+) error {
+	sql, args, err := queryTransactionMetadata.Where(sq.And{
+		sq.Gt{colID: afterRevision},
+		sq.LtOrEq{colID: newRevision},
+		sq.NotEq{colMetadata: nil},
+	}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := pgd.poolForContext(ctx).Query(ctx, sql, args...)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			err = datastore.NewWatchCanceledErr()
+		}
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var txID uint64
+		var metadataJSON pgtype.JSONB
+		if err := rows.Scan(&txID, &metadataJSON); err != nil {
+			return err
+		}
+
+		metadata := &datastore.TransactionMetadata{}
+		if err := metadataJSON.AssignTo(metadata); err != nil {
+			return err
+		}
+		stagedChanges.SetMetadata(txID, metadata)
+	}
+	return rows.Err()
+}
+
+func (pgd *pgDatastore) WatchNamespaces(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.NamespaceChanges, <-chan error) {
+	updates := make(chan *datastore.NamespaceChanges, pgd.watchBufferLength)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(updates)
+		defer close(errs)
+		defer datastore.NamespaceWatchBufferQueue.Track(func() int { return len(updates) }, cap(updates))()
+
+		currentTxn := transactionFromRevision(afterRevision)
+
+		for {
+			var changes *datastore.NamespaceChanges
+			var err error
+			changes, currentTxn, err = pgd.loadNamespaceChanges(ctx, currentTxn)
+			if err != nil {
+				if errors.Is(ctx.Err(), context.Canceled) {
+					errs <- datastore.NewWatchCanceledErr()
+				} else {
+					errs <- err
+				}
+				return
+			}
+
+			if changes != nil {
+				select {
+				case updates <- changes:
+				default:
+					errs <- datastore.NewWatchDisconnectedErr()
+					return
+				}
+			}
+
+			sleep := pgd.timeSource.Timer(watchSleep)
+			select {
+			case <-sleep.C:
+				break
+			case <-ctx.Done():
+				errs <- datastore.NewWatchCanceledErr()
+				return
+			}
+		}
+	}()
+
+	return updates, errs
+}
+
+func (pgd *pgDatastore) loadNamespaceChanges(
+	ctx context.Context,
+	afterRevision uint64,
+) (*datastore.NamespaceChanges, uint64, error) {
+	newRevision, err := pgd.loadRevision(ctx)
+	if err != nil {
+		return nil, afterRevision, err
+	}
+
+	if newRevision == afterRevision {
+		return nil, afterRevision, nil
+	}
+
+	sql, args, err := queryChangedNamespaces.Where(sq.Or{
+		sq.And{
+			sq.Gt{colCreatedTxn: afterRevision},
+			sq.LtOrEq{colCreatedTxn: newRevision},
+		},
+		sq.And{
+			sq.Gt{colDeletedTxn: afterRevision},
+			sq.LtOrEq{colDeletedTxn: newRevision},
+		},
+	}).ToSql()
+	if err != nil {
+		return nil, afterRevision, err
+	}
+
+	rows, err := pgd.poolForContext(ctx).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, afterRevision, err
+	}
+	defer rows.Close()
+
+	changes := &datastore.NamespaceChanges{Revision: revisionFromTransaction(newRevision)}
+	for rows.Next() {
+		var nsName string
+		if err := rows.Scan(&nsName); err != nil {
+			return nil, afterRevision, err
+		}
+		changes.Changed = append(changes.Changed, nsName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, afterRevision, err
+	}
+
+	return changes, newRevision, nil
+}
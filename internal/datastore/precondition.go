@@ -0,0 +1,31 @@
+package datastore
+
+import (
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// ResourceIDPrefixWildcard ends the optional resource ID of the filter of a precondition
+// which matches the resources whose IDs start with the rest of the ID, rather than only
+// the resource with exactly that ID. Resource IDs may never contain it, so a filter
+// using it cannot be mistaken for one naming a single resource.
+const ResourceIDPrefixWildcard = "*"
+
+// ResourceIDPrefix returns the prefix to which the filter of a precondition limits the
+// IDs of resources, if it is a prefix filter.
+func ResourceIDPrefix(filter *v1.RelationshipFilter) (string, bool) {
+	if !strings.HasSuffix(filter.OptionalResourceId, ResourceIDPrefixWildcard) {
+		return "", false
+	}
+	return strings.TrimSuffix(filter.OptionalResourceId, ResourceIDPrefixWildcard), true
+}
+
+// WithResourceIDPrefix returns a copy of the filter which matches the resources whose
+// IDs start with its optional resource ID, for use in preconditions.
+func WithResourceIDPrefix(filter *v1.RelationshipFilter) *v1.RelationshipFilter {
+	prefixed := proto.Clone(filter).(*v1.RelationshipFilter)
+	prefixed.OptionalResourceId += ResourceIDPrefixWildcard
+	return prefixed
+}
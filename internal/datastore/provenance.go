@@ -0,0 +1,44 @@
+package datastore
+
+import "context"
+
+// TransactionMetadata describes the origin of a write transaction, allowing consumers
+// of the change feed to attribute changes to the caller which made them.
+type TransactionMetadata struct {
+	// Caller is the self-reported identity of the client which issued the write.
+	Caller string `json:"caller,omitempty"`
+
+	// Principal is the identity of the client which issued the write, as verified by
+	// the authentication of the request.
+	Principal string `json:"principal,omitempty"`
+
+	// RequestID is the ID of the API request which issued the write.
+	RequestID string `json:"request_id,omitempty"`
+
+	// Reason is the justification given by the client for the write, such as the ticket
+	// under which access was granted.
+	Reason string `json:"reason,omitempty"`
+
+	// Labels are arbitrary key/value pairs provided by the client, such as the
+	// name of the sync job which issued the write.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type ctxKeyType struct{}
+
+var transactionMetadataKey ctxKeyType = struct{}{}
+
+// ContextWithTransactionMetadata returns a new context which carries the metadata
+// to be recorded with any transactions written using it.
+func ContextWithTransactionMetadata(ctx context.Context, metadata *TransactionMetadata) context.Context {
+	return context.WithValue(ctx, transactionMetadataKey, metadata)
+}
+
+// TransactionMetadataFromContext returns the transaction metadata carried by the
+// context, or nil if none is present.
+func TransactionMetadataFromContext(ctx context.Context) *TransactionMetadata {
+	if metadata, ok := ctx.Value(transactionMetadataKey).(*TransactionMetadata); ok {
+		return metadata
+	}
+	return nil
+}
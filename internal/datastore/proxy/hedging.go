@@ -221,6 +221,11 @@ func (hp hedgingProxy) ReadNamespace(ctx context.Context, nsName string, revisio
 	return
 }
 
+// Unwrap implements datastore.Unwrapper.
+func (hp hedgingProxy) Unwrap() datastore.Datastore {
+	return hp.delegate
+}
+
 func (hp hedgingProxy) Close() error {
 	return hp.delegate.Close()
 }
@@ -237,8 +242,8 @@ func (hp hedgingProxy) WriteTuples(ctx context.Context, preconditions []*v1.Prec
 	return hp.delegate.WriteTuples(ctx, preconditions, updates)
 }
 
-func (hp hedgingProxy) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
-	return hp.delegate.Watch(ctx, afterRevision)
+func (hp hedgingProxy) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	return hp.delegate.Watch(ctx, afterRevision, opts...)
 }
 
 func (hp hedgingProxy) WriteNamespace(ctx context.Context, newConfig *v0.NamespaceDefinition) (datastore.Revision, error) {
@@ -16,7 +16,7 @@ import (
 	"go.uber.org/goleak"
 
 	"github.com/authzed/spicedb/internal/datastore"
-	"github.com/authzed/spicedb/internal/datastore/test"
+	"github.com/authzed/spicedb/pkg/datastore/test"
 )
 
 var (
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+)
+
+var errMaintenanceMode = datastore.NewMaintenanceModeErr()
+
+// MaintenanceDatastore is a proxy which rejects write operations to a downstream delegate
+// datastore while maintenance mode is enabled, and passes all reads through. Unlike the
+// read-only proxy, maintenance mode can be toggled while the datastore is in use.
+type MaintenanceDatastore struct {
+	delegate datastore.Datastore
+	enabled  uint32
+}
+
+// NewMaintenanceDatastore creates a proxy which can disable write operations to a downstream
+// delegate datastore at runtime.
+func NewMaintenanceDatastore(delegate datastore.Datastore, enabled bool) *MaintenanceDatastore {
+	md := &MaintenanceDatastore{delegate: delegate}
+	md.SetEnabled(enabled)
+	return md
+}
+
+// SetEnabled enables or disables maintenance mode.
+func (md *MaintenanceDatastore) SetEnabled(enabled bool) {
+	var value uint32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreUint32(&md.enabled, value)
+}
+
+// Enabled returns whether maintenance mode is currently enabled.
+func (md *MaintenanceDatastore) Enabled() bool {
+	return atomic.LoadUint32(&md.enabled) == 1
+}
+
+// Unwrap implements datastore.Unwrapper.
+func (md *MaintenanceDatastore) Unwrap() datastore.Datastore {
+	return md.delegate
+}
+
+// BulkLoad implements datastore.BulkLoader, so that the bulk loader of the delegate is not
+// used to write to it while maintenance mode is enabled.
+func (md *MaintenanceDatastore) BulkLoad(ctx context.Context, relationships []*v1.Relationship) (datastore.Revision, error) {
+	if md.Enabled() {
+		return datastore.NoRevision, errMaintenanceMode
+	}
+	return datastore.BulkLoad(ctx, md.delegate, relationships)
+}
+
+func (md *MaintenanceDatastore) Close() error {
+	return md.delegate.Close()
+}
+
+func (md *MaintenanceDatastore) IsReady(ctx context.Context) (bool, error) {
+	return md.delegate.IsReady(ctx)
+}
+
+func (md *MaintenanceDatastore) DeleteRelationships(ctx context.Context, preconditions []*v1.Precondition, filter *v1.RelationshipFilter) (datastore.Revision, error) {
+	if md.Enabled() {
+		return datastore.NoRevision, errMaintenanceMode
+	}
+	return md.delegate.DeleteRelationships(ctx, preconditions, filter)
+}
+
+func (md *MaintenanceDatastore) WriteTuples(ctx context.Context, preconditions []*v1.Precondition, mutations []*v1.RelationshipUpdate) (datastore.Revision, error) {
+	if md.Enabled() {
+		return datastore.NoRevision, errMaintenanceMode
+	}
+	return md.delegate.WriteTuples(ctx, preconditions, mutations)
+}
+
+func (md *MaintenanceDatastore) OptimizedRevision(ctx context.Context) (datastore.Revision, error) {
+	return md.delegate.OptimizedRevision(ctx)
+}
+
+func (md *MaintenanceDatastore) HeadRevision(ctx context.Context) (datastore.Revision, error) {
+	return md.delegate.HeadRevision(ctx)
+}
+
+func (md *MaintenanceDatastore) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	return md.delegate.Watch(ctx, afterRevision, opts...)
+}
+
+func (md *MaintenanceDatastore) WriteNamespace(ctx context.Context, newConfig *v0.NamespaceDefinition) (datastore.Revision, error) {
+	if md.Enabled() {
+		return datastore.NoRevision, errMaintenanceMode
+	}
+	return md.delegate.WriteNamespace(ctx, newConfig)
+}
+
+func (md *MaintenanceDatastore) ReadNamespace(ctx context.Context, nsName string, revision datastore.Revision) (*v0.NamespaceDefinition, datastore.Revision, error) {
+	return md.delegate.ReadNamespace(ctx, nsName, revision)
+}
+
+func (md *MaintenanceDatastore) DeleteNamespace(ctx context.Context, nsName string) (datastore.Revision, error) {
+	if md.Enabled() {
+		return datastore.NoRevision, errMaintenanceMode
+	}
+	return md.delegate.DeleteNamespace(ctx, nsName)
+}
+
+func (md *MaintenanceDatastore) QueryTuples(
+	ctx context.Context,
+	filter *v1.RelationshipFilter,
+	revision datastore.Revision,
+	options ...options.QueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	return md.delegate.QueryTuples(ctx, filter, revision, options...)
+}
+
+func (md *MaintenanceDatastore) ReverseQueryTuples(
+	ctx context.Context,
+	subjectFilter *v1.SubjectFilter,
+	revision datastore.Revision,
+	options ...options.ReverseQueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	return md.delegate.ReverseQueryTuples(ctx, subjectFilter, revision, options...)
+}
+
+func (md *MaintenanceDatastore) CheckRevision(ctx context.Context, revision datastore.Revision) error {
+	return md.delegate.CheckRevision(ctx, revision)
+}
+
+func (md *MaintenanceDatastore) ListNamespaces(ctx context.Context, revision datastore.Revision) ([]*v0.NamespaceDefinition, error) {
+	return md.delegate.ListNamespaces(ctx, revision)
+}
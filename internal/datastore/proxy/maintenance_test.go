@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+)
+
+func TestMaintenanceModeToggle(t *testing.T) {
+	require := require.New(t)
+
+	delegate, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	ds := NewMaintenanceDatastore(delegate, true)
+	ctx := context.Background()
+
+	rev, err := ds.WriteNamespace(ctx, &v0.NamespaceDefinition{Name: "user"})
+	require.ErrorAs(err, &datastore.ErrMaintenanceMode{})
+	require.Equal(datastore.NoRevision, rev)
+
+	rev, err = ds.DeleteNamespace(ctx, "user")
+	require.ErrorAs(err, &datastore.ErrMaintenanceMode{})
+	require.Equal(datastore.NoRevision, rev)
+
+	head, err := ds.HeadRevision(ctx)
+	require.NoError(err)
+
+	namespaces, err := ds.ListNamespaces(ctx, head)
+	require.NoError(err)
+	require.Empty(namespaces)
+
+	ds.SetEnabled(false)
+	require.False(ds.Enabled())
+
+	rev, err = ds.WriteNamespace(ctx, &v0.NamespaceDefinition{Name: "user"})
+	require.NoError(err)
+
+	found, _, err := ds.ReadNamespace(ctx, "user", rev)
+	require.NoError(err)
+	require.Equal("user", found.Name)
+
+	ds.SetEnabled(true)
+	_, err = ds.DeleteNamespace(ctx, "user")
+	require.ErrorAs(err, &datastore.ErrMaintenanceMode{})
+}
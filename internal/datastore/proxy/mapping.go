@@ -93,14 +93,28 @@ func (mp mappingProxy) HeadRevision(ctx context.Context) (datastore.Revision, er
 	return mp.delegate.HeadRevision(ctx)
 }
 
-func (mp mappingProxy) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
-	changeChan, errChan := mp.delegate.Watch(ctx, afterRevision)
-
+func (mp mappingProxy) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
 	newChangeChan := make(chan *datastore.RevisionChanges, mp.watchBufferLength)
 	newErrChan := make(chan error, 1)
 
+	watchOpts := options.NewWatchOptionsWithOptions(opts...)
+	translatedFilters := make([]*v1.RelationshipFilter, 0, len(watchOpts.Filters))
+	for _, filter := range watchOpts.Filters {
+		translatedFilter, err := translateRelFilter(filter, mp.mapper.Encode)
+		if err != nil {
+			newErrChan <- fmt.Errorf(errTranslation, err)
+			close(newErrChan)
+			close(newChangeChan)
+			return newChangeChan, newErrChan
+		}
+		translatedFilters = append(translatedFilters, translatedFilter)
+	}
+
+	changeChan, errChan := mp.delegate.Watch(ctx, afterRevision, options.SetFilters(translatedFilters))
+
 	go func() {
 		defer close(newChangeChan)
+		defer datastore.WatchBufferQueue.Track(func() int { return len(newChangeChan) }, cap(newChangeChan))()
 
 		done := false
 		for !done {
@@ -122,6 +136,7 @@ func (mp mappingProxy) Watch(ctx context.Context, afterRevision datastore.Revisi
 					newChangeChan <- &datastore.RevisionChanges{
 						Revision: change.Revision,
 						Changes:  translatedChanges,
+						Metadata: change.Metadata,
 					}
 				}
 			case err, ok := <-errChan:
@@ -215,12 +230,20 @@ func (mp mappingProxy) QueryTuples(
 		translatedUsersets = append(translatedUsersets, translatedUserset)
 	}
 
+	translatedOptions := []options.QueryOptionsOption{
+		options.WithLimit(queryOpts.Limit),
+		options.SetUsersets(translatedUsersets),
+		options.WithTimeout(queryOpts.Timeout),
+		options.WithOrder(queryOpts.Order),
+		options.WithAfterResourceID(queryOpts.AfterResourceID),
+	}
+
 	rawIter, err := mp.delegate.QueryTuples(ctx, &v1.RelationshipFilter{
 		ResourceType:          resourceType,
 		OptionalResourceId:    filter.OptionalResourceId,
 		OptionalRelation:      filter.OptionalRelation,
 		OptionalSubjectFilter: subFilter,
-	}, revision, options.WithLimit(queryOpts.Limit), options.SetUsersets(translatedUsersets))
+	}, revision, translatedOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -243,6 +266,8 @@ func (mp mappingProxy) ReverseQueryTuples(
 
 	translatedOptions := []options.ReverseQueryOptionsOption{
 		options.WithReverseLimit(queryOpts.ReverseLimit),
+		options.SetSubjectIDs(queryOpts.SubjectIDs),
+		options.WithReverseTimeout(queryOpts.ReverseTimeout),
 	}
 	if queryOpts.ResRelation != nil {
 		translatedResourceType, err := mp.mapper.Encode(queryOpts.ResRelation.Namespace)
@@ -317,6 +342,10 @@ func (mti *mappingTupleIterator) Err() error {
 	return mti.delegate.Err()
 }
 
+func (mti *mappingTupleIterator) SizeHint() (int, bool) {
+	return datastore.SizeHint(mti.delegate)
+}
+
 func (mti *mappingTupleIterator) Close() {
 	mti.delegate.Close()
 }
@@ -16,7 +16,7 @@ import (
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/memdb"
 	"github.com/authzed/spicedb/internal/datastore/options"
-	"github.com/authzed/spicedb/internal/datastore/test"
+	"github.com/authzed/spicedb/pkg/datastore/test"
 	"github.com/authzed/spicedb/pkg/namespace"
 )
 
@@ -71,7 +71,7 @@ func (mt mappingTest) New(revisionFuzzingTimedelta, gcWindow time.Duration, watc
 }
 
 func TestMappingDatastoreProxy(t *testing.T) {
-	test.All(t, mappingTest{func() namespace.Mapper {
+	test.RunAll(t, mappingTest{func() namespace.Mapper {
 		return testAutoMapper{
 			make(map[string]string),
 			make(map[string]string),
@@ -80,7 +80,7 @@ func TestMappingDatastoreProxy(t *testing.T) {
 }
 
 func TestMappingDatastoreProxyPassthrough(t *testing.T) {
-	test.All(t, mappingTest{func() namespace.Mapper {
+	test.RunAll(t, mappingTest{func() namespace.Mapper {
 		return namespace.PassthroughMapper
 	}})
 }
@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+)
+
+var namespaceCacheHitCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "namespace_cache_hits_total",
+	Help:      "total number of namespace reads served from the namespace cache",
+})
+
+var namespaceCacheMissCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "namespace_cache_misses_total",
+	Help:      "total number of namespace reads which were not found in the namespace cache",
+})
+
+const namespaceWatchRetryDelay = 1 * time.Second
+
+type nsCacheEntry struct {
+	definition  *v0.NamespaceDefinition
+	lastWritten datastore.Revision
+
+	// validThrough is the latest revision at which the definition is known to be current.
+	validThrough datastore.Revision
+}
+
+// nsCacheKey identifies a cached definition. Definitions of the same name in different
+// tenants are distinct.
+type nsCacheKey struct {
+	tenant string
+	name   string
+}
+
+type nsCachingProxy struct {
+	delegate datastore.Datastore
+	cancel   context.CancelFunc
+
+	lock sync.RWMutex
+
+	// checkpoint is the revision through which all namespace changes have been applied to
+	// the cache.
+	checkpoint datastore.Revision
+	entries    map[nsCacheKey]*nsCacheEntry
+}
+
+// NewNamespaceCachingProxy creates a proxy which caches namespace definitions read from the
+// delegate datastore. Unlike a cache keyed by revision, a cached definition is reused for
+// reads at any revision at which it is known to be current, which is determined by tailing
+// the namespace changes of the delegate. If the delegate cannot report namespace changes, it
+// is returned unchanged.
+func NewNamespaceCachingProxy(delegate datastore.Datastore) datastore.Datastore {
+	var watcher datastore.NamespaceWatcher
+	if !datastore.As(delegate, &watcher) {
+		log.Warn().Msg("datastore does not support watching namespaces; namespace caching disabled")
+		return delegate
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	proxy := &nsCachingProxy{
+		delegate: delegate,
+		cancel:   cancel,
+		entries:  make(map[nsCacheKey]*nsCacheEntry),
+	}
+	go proxy.tailNamespaceChanges(ctx, watcher)
+
+	return proxy
+}
+
+func (p *nsCachingProxy) tailNamespaceChanges(ctx context.Context, watcher datastore.NamespaceWatcher) {
+	for {
+		if err := p.watchFromHead(ctx, watcher); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("namespace change feed interrupted")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(namespaceWatchRetryDelay):
+		}
+	}
+}
+
+func (p *nsCachingProxy) watchFromHead(ctx context.Context, watcher datastore.NamespaceWatcher) error {
+	head, err := p.delegate.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Changes which occurred while the feed was not running have been missed, so no
+	// previously cached entry can be extended past the revision through which it was
+	// known to be current. Those entries remain valid up to that revision, however,
+	// which allows reads at older revisions to be served while the datastore is
+	// unavailable.
+	p.lock.Lock()
+	p.checkpoint = head
+	p.lock.Unlock()
+
+	changes, errs := watcher.WatchNamespaces(ctx, head)
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return <-errs
+			}
+			p.applyChanges(change)
+
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+func (p *nsCachingProxy) applyChanges(changes *datastore.NamespaceChanges) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// Changes are reported by name alone, so a change invalidates the definitions of that
+	// name in every tenant.
+	changed := make(map[string]struct{}, len(changes.Changed))
+	for _, nsName := range changes.Changed {
+		changed[nsName] = struct{}{}
+	}
+	for key := range p.entries {
+		if _, ok := changed[key.name]; ok {
+			delete(p.entries, key)
+		}
+	}
+
+	// Any remaining entry which was current as of the previous checkpoint has not changed
+	// since, and is therefore current as of the new one.
+	for _, entry := range p.entries {
+		if entry.validThrough.GreaterThanOrEqual(p.checkpoint) && changes.Revision.GreaterThan(entry.validThrough) {
+			entry.validThrough = changes.Revision
+		}
+	}
+
+	if changes.Revision.GreaterThan(p.checkpoint) {
+		p.checkpoint = changes.Revision
+	}
+}
+
+func (p *nsCachingProxy) ReadNamespace(ctx context.Context, nsName string, revision datastore.Revision) (*v0.NamespaceDefinition, datastore.Revision, error) {
+	key := nsCacheKey{datastore.TenantFromContext(ctx), nsName}
+
+	p.lock.RLock()
+	entry, ok := p.entries[key]
+	if ok && revision.GreaterThanOrEqual(entry.lastWritten) && revision.LessThanOrEqual(entry.validThrough) {
+		p.lock.RUnlock()
+		namespaceCacheHitCount.Inc()
+		return entry.definition, entry.lastWritten, nil
+	}
+	p.lock.RUnlock()
+
+	namespaceCacheMissCount.Inc()
+	definition, lastWritten, err := p.delegate.ReadNamespace(ctx, nsName, revision)
+	if err != nil {
+		return definition, lastWritten, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if existing, ok := p.entries[key]; !ok || revision.GreaterThan(existing.validThrough) {
+		p.entries[key] = &nsCacheEntry{
+			definition:   definition,
+			lastWritten:  lastWritten,
+			validThrough: revision,
+		}
+	}
+
+	return definition, lastWritten, nil
+}
+
+// Unwrap implements datastore.Unwrapper.
+func (p *nsCachingProxy) Unwrap() datastore.Datastore {
+	return p.delegate
+}
+
+func (p *nsCachingProxy) Close() error {
+	p.cancel()
+	return p.delegate.Close()
+}
+
+func (p *nsCachingProxy) IsReady(ctx context.Context) (bool, error) {
+	return p.delegate.IsReady(ctx)
+}
+
+func (p *nsCachingProxy) DeleteRelationships(ctx context.Context, preconditions []*v1.Precondition, filter *v1.RelationshipFilter) (datastore.Revision, error) {
+	return p.delegate.DeleteRelationships(ctx, preconditions, filter)
+}
+
+func (p *nsCachingProxy) WriteTuples(ctx context.Context, preconditions []*v1.Precondition, mutations []*v1.RelationshipUpdate) (datastore.Revision, error) {
+	return p.delegate.WriteTuples(ctx, preconditions, mutations)
+}
+
+func (p *nsCachingProxy) OptimizedRevision(ctx context.Context) (datastore.Revision, error) {
+	return p.delegate.OptimizedRevision(ctx)
+}
+
+func (p *nsCachingProxy) HeadRevision(ctx context.Context) (datastore.Revision, error) {
+	return p.delegate.HeadRevision(ctx)
+}
+
+func (p *nsCachingProxy) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	return p.delegate.Watch(ctx, afterRevision, opts...)
+}
+
+func (p *nsCachingProxy) WriteNamespace(ctx context.Context, newConfig *v0.NamespaceDefinition) (datastore.Revision, error) {
+	return p.delegate.WriteNamespace(ctx, newConfig)
+}
+
+func (p *nsCachingProxy) DeleteNamespace(ctx context.Context, nsName string) (datastore.Revision, error) {
+	return p.delegate.DeleteNamespace(ctx, nsName)
+}
+
+func (p *nsCachingProxy) QueryTuples(
+	ctx context.Context,
+	filter *v1.RelationshipFilter,
+	revision datastore.Revision,
+	options ...options.QueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	return p.delegate.QueryTuples(ctx, filter, revision, options...)
+}
+
+func (p *nsCachingProxy) ReverseQueryTuples(
+	ctx context.Context,
+	subjectFilter *v1.SubjectFilter,
+	revision datastore.Revision,
+	options ...options.ReverseQueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	return p.delegate.ReverseQueryTuples(ctx, subjectFilter, revision, options...)
+}
+
+func (p *nsCachingProxy) CheckRevision(ctx context.Context, revision datastore.Revision) error {
+	return p.delegate.CheckRevision(ctx, revision)
+}
+
+func (p *nsCachingProxy) ListNamespaces(ctx context.Context, revision datastore.Revision) ([]*v0.NamespaceDefinition, error) {
+	return p.delegate.ListNamespaces(ctx, revision)
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+)
+
+func TestNamespaceCachingProxy(t *testing.T) {
+	require := require.New(t)
+
+	delegate, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	ds := NewNamespaceCachingProxy(delegate)
+	defer ds.Close()
+	proxy := ds.(*nsCachingProxy)
+	ctx := context.Background()
+
+	firstRev, err := ds.WriteNamespace(ctx, &v0.NamespaceDefinition{Name: "document"})
+	require.NoError(err)
+	waitForCheckpoint(t, proxy, firstRev)
+
+	found, lastWritten, err := ds.ReadNamespace(ctx, "document", firstRev)
+	require.NoError(err)
+	require.Equal("document", found.Name)
+	require.True(firstRev.Equal(lastWritten))
+
+	// A write to an unrelated namespace extends the validity of the cached definition.
+	otherRev, err := ds.WriteNamespace(ctx, &v0.NamespaceDefinition{Name: "user"})
+	require.NoError(err)
+	require.Eventually(func() bool {
+		proxy.lock.RLock()
+		defer proxy.lock.RUnlock()
+		entry, ok := proxy.entries[nsCacheKey{datastore.DefaultTenant, "document"}]
+		return ok && entry.validThrough.GreaterThanOrEqual(otherRev)
+	}, 1*time.Second, 5*time.Millisecond)
+
+	found, _, err = ds.ReadNamespace(ctx, "document", otherRev)
+	require.NoError(err)
+	require.Equal("document", found.Name)
+
+	// A write to the cached namespace invalidates it.
+	updated := &v0.NamespaceDefinition{
+		Name:     "document",
+		Relation: []*v0.Relation{{Name: "viewer"}},
+	}
+	updatedRev, err := ds.WriteNamespace(ctx, updated)
+	require.NoError(err)
+	waitForCheckpoint(t, proxy, updatedRev)
+
+	found, lastWritten, err = ds.ReadNamespace(ctx, "document", updatedRev)
+	require.NoError(err)
+	require.Len(found.Relation, 1)
+	require.True(updatedRev.Equal(lastWritten))
+
+	// Deleted namespaces are not served from the cache.
+	deletedRev, err := ds.DeleteNamespace(ctx, "document")
+	require.NoError(err)
+
+	_, _, err = ds.ReadNamespace(ctx, "document", deletedRev)
+	require.ErrorAs(err, &datastore.ErrNamespaceNotFound{})
+}
+
+func waitForCheckpoint(t *testing.T, proxy *nsCachingProxy, revision datastore.Revision) {
+	require.Eventually(t, func() bool {
+		proxy.lock.RLock()
+		defer proxy.lock.RUnlock()
+		return proxy.checkpoint.GreaterThanOrEqual(revision)
+	}, 1*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,289 @@
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+)
+
+var queryCacheHitCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "query_cache_hits_total",
+	Help:      "total number of tuple queries served from the query cache",
+})
+
+var queryCacheMissCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "query_cache_misses_total",
+	Help:      "total number of tuple queries which were not found in the query cache",
+})
+
+var queryCacheEvictionCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "query_cache_evictions_total",
+	Help:      "total number of tuple query results evicted from the query cache to make room for others",
+})
+
+var queryCacheSizeGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "query_cache_size_bytes",
+	Help:      "estimated size of the tuple query results held in the query cache",
+})
+
+const (
+	// queryCacheMaxEntryFraction bounds the size of a single cached result to a fraction of
+	// the size of the cache, so that one wide query cannot evict every other result.
+	queryCacheMaxEntryFraction = 8
+
+	// queryCacheEntryOverhead is the estimated size of the bookkeeping of a cached result,
+	// and queryCacheTupleOverhead that of each of its tuples beyond their encoded size.
+	queryCacheEntryOverhead = 256
+	queryCacheTupleOverhead = 64
+)
+
+type queryCacheEntry struct {
+	key    string
+	tuples []*v0.RelationTuple
+	cost   int64
+}
+
+// queryCache is a least recently used cache of the results of tuple queries, bounded by
+// their estimated size in bytes.
+type queryCache struct {
+	sync.Mutex
+	maxCost int64
+	cost    int64
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newQueryCache(maxCost int64) *queryCache {
+	return &queryCache{
+		maxCost: maxCost,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (qc *queryCache) get(key string) ([]*v0.RelationTuple, bool) {
+	qc.Lock()
+	defer qc.Unlock()
+
+	element, ok := qc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	qc.lru.MoveToFront(element)
+	return element.Value.(*queryCacheEntry).tuples, true
+}
+
+func (qc *queryCache) add(key string, tuples []*v0.RelationTuple, cost int64) {
+	qc.Lock()
+	defer qc.Unlock()
+
+	if _, ok := qc.entries[key]; ok {
+		return
+	}
+
+	for qc.cost+cost > qc.maxCost && qc.lru.Len() > 0 {
+		oldest := qc.lru.Back()
+		qc.remove(oldest)
+		queryCacheEvictionCount.Inc()
+	}
+
+	qc.entries[key] = qc.lru.PushFront(&queryCacheEntry{key, tuples, cost})
+	qc.cost += cost
+	queryCacheSizeGauge.Add(float64(cost))
+}
+
+func (qc *queryCache) remove(element *list.Element) {
+	entry := qc.lru.Remove(element).(*queryCacheEntry)
+	delete(qc.entries, entry.key)
+	qc.cost -= entry.cost
+	queryCacheSizeGauge.Sub(float64(entry.cost))
+}
+
+func (qc *queryCache) clear() {
+	qc.Lock()
+	defer qc.Unlock()
+
+	for qc.lru.Len() > 0 {
+		qc.remove(qc.lru.Back())
+	}
+}
+
+type queryCachingProxy struct {
+	datastore.Datastore
+	cache *queryCache
+}
+
+// NewQueryCachingProxy creates a proxy which caches the results of the tuple queries of the
+// delegate datastore, up to an estimated maxBytes in total. The results of a query at a
+// revision never change, so they are cached by the query and the revision, and evicted only
+// to make room for others, least recently used first. Results are only cached once they have
+// been read completely.
+func NewQueryCachingProxy(delegate datastore.Datastore, maxBytes int64) datastore.Datastore {
+	return &queryCachingProxy{
+		Datastore: delegate,
+		cache:     newQueryCache(maxBytes),
+	}
+}
+
+func (p *queryCachingProxy) QueryTuples(
+	ctx context.Context,
+	filter *v1.RelationshipFilter,
+	revision datastore.Revision,
+	opts ...options.QueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	queryOpts := options.NewQueryOptionsWithOptions(opts...)
+
+	var key strings.Builder
+	key.WriteString("forward")
+	writeKeyPart(&key, datastore.TenantFromContext(ctx))
+	writeKeyPart(&key, revision.String())
+	writeKeyMessage(&key, filter)
+	writeKeyLimit(&key, queryOpts.Limit)
+	for _, userset := range queryOpts.Usersets {
+		writeKeyMessage(&key, userset)
+	}
+	writeKeyPart(&key, fmt.Sprint(queryOpts.Order))
+	writeKeyPart(&key, queryOpts.AfterResourceID)
+
+	return p.cachedQuery(key.String(), func() (datastore.TupleIterator, error) {
+		return p.Datastore.QueryTuples(ctx, filter, revision, opts...)
+	})
+}
+
+func (p *queryCachingProxy) ReverseQueryTuples(
+	ctx context.Context,
+	subjectFilter *v1.SubjectFilter,
+	revision datastore.Revision,
+	opts ...options.ReverseQueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	queryOpts := options.NewReverseQueryOptionsWithOptions(opts...)
+
+	var key strings.Builder
+	key.WriteString("reverse")
+	writeKeyPart(&key, datastore.TenantFromContext(ctx))
+	writeKeyPart(&key, revision.String())
+	writeKeyMessage(&key, subjectFilter)
+	writeKeyLimit(&key, queryOpts.ReverseLimit)
+	if queryOpts.ResRelation != nil {
+		writeKeyPart(&key, queryOpts.ResRelation.Namespace)
+		writeKeyPart(&key, queryOpts.ResRelation.Relation)
+	}
+	for _, subjectID := range queryOpts.SubjectIDs {
+		writeKeyPart(&key, subjectID)
+	}
+
+	return p.cachedQuery(key.String(), func() (datastore.TupleIterator, error) {
+		return p.Datastore.ReverseQueryTuples(ctx, subjectFilter, revision, opts...)
+	})
+}
+
+// writeKeyPart writes a part of a cache key, prefixed by its length so that the parts of
+// different keys cannot be confused.
+func writeKeyPart(key *strings.Builder, part string) {
+	fmt.Fprintf(key, "|%d:%s", len(part), part)
+}
+
+func writeKeyMessage(key *strings.Builder, message proto.Message) {
+	encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		// Messages which cannot be encoded are never found in the cache.
+		encoded = []byte(fmt.Sprintf("%p", message))
+	}
+	writeKeyPart(key, string(encoded))
+}
+
+func writeKeyLimit(key *strings.Builder, limit *uint64) {
+	if limit == nil {
+		writeKeyPart(key, "")
+		return
+	}
+	writeKeyPart(key, fmt.Sprint(*limit))
+}
+
+func (p *queryCachingProxy) cachedQuery(key string, query func() (datastore.TupleIterator, error)) (datastore.TupleIterator, error) {
+	if tuples, ok := p.cache.get(key); ok {
+		queryCacheHitCount.Inc()
+		return datastore.NewSliceTupleIterator(tuples), nil
+	}
+
+	queryCacheMissCount.Inc()
+	iter, err := query()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachingTupleIterator{
+		TupleIterator: iter,
+		cache:         p.cache,
+		key:           key,
+		maxCost:       p.cache.maxCost / queryCacheMaxEntryFraction,
+		cost:          queryCacheEntryOverhead,
+	}, nil
+}
+
+// cachingTupleIterator records the tuples read from a query, adding them to the cache once
+// they have all been read, unless they are too large to be cached.
+type cachingTupleIterator struct {
+	datastore.TupleIterator
+	cache   *queryCache
+	key     string
+	maxCost int64
+
+	tuples    []*v0.RelationTuple
+	cost      int64
+	oversized bool
+	done      bool
+}
+
+func (cti *cachingTupleIterator) Next() *v0.RelationTuple {
+	tpl := cti.TupleIterator.Next()
+	if cti.done || cti.oversized {
+		return tpl
+	}
+
+	if tpl == nil {
+		cti.done = true
+		if cti.TupleIterator.Err() == nil {
+			cti.cache.add(cti.key, cti.tuples, cti.cost)
+		}
+		return nil
+	}
+
+	cti.cost += int64(proto.Size(tpl)) + queryCacheTupleOverhead
+	if cti.cost > cti.maxCost {
+		cti.oversized = true
+		cti.tuples = nil
+		return tpl
+	}
+	cti.tuples = append(cti.tuples, tpl)
+	return tpl
+}
+
+// Unwrap implements datastore.Unwrapper.
+func (p *queryCachingProxy) Unwrap() datastore.Datastore {
+	return p.Datastore
+}
+
+func (p *queryCachingProxy) Close() error {
+	p.cache.clear()
+	return p.Datastore.Close()
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+type countingDatastore struct {
+	datastore.Datastore
+	queries int32
+}
+
+func (cd *countingDatastore) QueryTuples(ctx context.Context, filter *v1.RelationshipFilter, revision datastore.Revision, opts ...options.QueryOptionsOption) (datastore.TupleIterator, error) {
+	atomic.AddInt32(&cd.queries, 1)
+	return cd.Datastore.QueryTuples(ctx, filter, revision, opts...)
+}
+
+func (cd *countingDatastore) ReverseQueryTuples(ctx context.Context, subjectFilter *v1.SubjectFilter, revision datastore.Revision, opts ...options.ReverseQueryOptionsOption) (datastore.TupleIterator, error) {
+	atomic.AddInt32(&cd.queries, 1)
+	return cd.Datastore.ReverseQueryTuples(ctx, subjectFilter, revision, opts...)
+}
+
+func readAll(t *testing.T, iter datastore.TupleIterator, err error) []*v0.RelationTuple {
+	require.NoError(t, err)
+	defer iter.Close()
+
+	var found []*v0.RelationTuple
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		found = append(found, tpl)
+	}
+	require.NoError(t, iter.Err())
+	return found
+}
+
+func TestQueryCachingProxy(t *testing.T) {
+	require := require.New(t)
+
+	memdbDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	delegate := &countingDatastore{Datastore: memdbDS}
+
+	ds := NewQueryCachingProxy(delegate, 1<<20)
+	defer ds.Close()
+	ctx := context.Background()
+
+	firstRev, err := ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{
+		tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:first#viewer@user:tom"))),
+	})
+	require.NoError(err)
+
+	filter := &v1.RelationshipFilter{ResourceType: "document"}
+	subjectFilter := &v1.SubjectFilter{SubjectType: "user"}
+
+	// The first query is read from the delegate, and the next from the cache.
+	require.Len(readAll(t, ds.QueryTuples(ctx, filter, firstRev)), 1)
+	require.Len(readAll(t, ds.QueryTuples(ctx, filter, firstRev)), 1)
+	require.Equal(int32(1), atomic.LoadInt32(&delegate.queries))
+
+	require.Len(readAll(t, ds.ReverseQueryTuples(ctx, subjectFilter, firstRev)), 1)
+	require.Len(readAll(t, ds.ReverseQueryTuples(ctx, subjectFilter, firstRev)), 1)
+	require.Equal(int32(2), atomic.LoadInt32(&delegate.queries))
+
+	// Options which change the results are part of the query.
+	one, two := uint64(1), uint64(2)
+	require.Len(readAll(t, ds.QueryTuples(ctx, filter, firstRev, options.WithLimit(&one))), 1)
+	require.Equal(int32(3), atomic.LoadInt32(&delegate.queries))
+
+	// Queries at another revision are read from the delegate.
+	secondRev, err := ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{
+		tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:second#viewer@user:tom"))),
+	})
+	require.NoError(err)
+
+	require.Len(readAll(t, ds.QueryTuples(ctx, filter, secondRev)), 2)
+	require.Equal(int32(4), atomic.LoadInt32(&delegate.queries))
+	require.Len(readAll(t, ds.QueryTuples(ctx, filter, firstRev)), 1)
+	require.Equal(int32(4), atomic.LoadInt32(&delegate.queries))
+
+	// Queries of other tenants are never served from the cache.
+	require.Len(readAll(t, ds.QueryTuples(datastore.ContextWithTenant(ctx, "other"), filter, firstRev)), 0)
+	require.Equal(int32(5), atomic.LoadInt32(&delegate.queries))
+
+	// Queries which are not read completely are not cached.
+	iter, err := ds.QueryTuples(ctx, filter, secondRev, options.WithLimit(&two))
+	require.NoError(err)
+	require.NotNil(iter.Next())
+	iter.Close()
+	require.Len(readAll(t, ds.QueryTuples(ctx, filter, secondRev, options.WithLimit(&two))), 2)
+	require.Equal(int32(7), atomic.LoadInt32(&delegate.queries))
+}
+
+func TestQueryCacheEviction(t *testing.T) {
+	require := require.New(t)
+
+	cache := newQueryCache(300)
+	tuples := []*v0.RelationTuple{tuple.MustParse("document:first#viewer@user:tom")}
+
+	cache.add("first", tuples, 100)
+	cache.add("second", tuples, 100)
+	cache.add("third", tuples, 100)
+
+	// Reading the first result makes the second the least recently used.
+	_, ok := cache.get("first")
+	require.True(ok)
+
+	cache.add("fourth", tuples, 150)
+	_, ok = cache.get("second")
+	require.False(ok)
+	_, ok = cache.get("third")
+	require.False(ok)
+
+	for _, key := range []string{"first", "fourth"} {
+		found, ok := cache.get(key)
+		require.True(ok)
+		require.Equal(tuples, found)
+	}
+	require.Equal(int64(250), cache.cost)
+
+	cache.clear()
+	require.Equal(int64(0), cache.cost)
+	require.Empty(cache.entries)
+}
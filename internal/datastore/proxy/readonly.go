@@ -22,6 +22,17 @@ func NewReadonlyDatastore(delegate datastore.Datastore) datastore.Datastore {
 	return roDatastore{delegate: delegate}
 }
 
+// Unwrap implements datastore.Unwrapper.
+func (rd roDatastore) Unwrap() datastore.Datastore {
+	return rd.delegate
+}
+
+// BulkLoad implements datastore.BulkLoader, so that the bulk loader of the delegate is not
+// used to write to it.
+func (rd roDatastore) BulkLoad(ctx context.Context, _ []*v1.Relationship) (datastore.Revision, error) {
+	return datastore.NoRevision, errReadOnly
+}
+
 func (rd roDatastore) Close() error {
 	return rd.delegate.Close()
 }
@@ -46,8 +57,8 @@ func (rd roDatastore) HeadRevision(ctx context.Context) (datastore.Revision, err
 	return rd.delegate.HeadRevision(ctx)
 }
 
-func (rd roDatastore) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
-	return rd.delegate.Watch(ctx, afterRevision)
+func (rd roDatastore) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	return rd.delegate.Watch(ctx, afterRevision, opts...)
 }
 
 func (rd roDatastore) WriteNamespace(ctx context.Context, newConfig *v0.NamespaceDefinition) (datastore.Revision, error) {
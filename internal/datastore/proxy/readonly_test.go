@@ -206,7 +206,7 @@ func (dm *delegateMock) HeadRevision(ctx context.Context) (datastore.Revision, e
 	return args.Get(0).(datastore.Revision), args.Error(1)
 }
 
-func (dm *delegateMock) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
+func (dm *delegateMock) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
 	args := dm.Called(afterRevision)
 
 	return args.Get(0).(<-chan *datastore.RevisionChanges), args.Get(1).(<-chan error)
@@ -0,0 +1,672 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/shopspring/decimal"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+)
+
+const (
+	errUnknownShard    = "namespace `%s` is routed to unknown shard `%s`"
+	errCrossShardWrite = "unable to atomically write relationships of namespaces `%s` and `%s`, which are stored in different shards"
+	errShardRevision   = "unable to resolve the revision of shard `%s`: %w"
+
+	// shardNamespace holds, in the coordinator, a relationship from each shard to the
+	// latest of its revisions which is visible at a revision of the coordinator.
+	shardNamespace         = "spicedb_shard"
+	shardRevisionRelation  = "revision"
+	shardRevisionNamespace = "spicedb_shard_revision"
+
+	// shardRevisionCacheSize is the most resolved shard revisions which are cached before
+	// the cache is cleared.
+	shardRevisionCacheSize = 4096
+)
+
+type shardRevisionKey struct {
+	tenant   string
+	shard    string
+	revision string
+}
+
+type shardedDatastore struct {
+	coordinator       datastore.Datastore
+	shards            map[string]datastore.Datastore
+	routes            map[string]string
+	watchBufferLength uint16
+
+	revisionsLock sync.Mutex
+	revisions     map[shardRevisionKey]datastore.Revision
+}
+
+// NewShardedDatastore creates a datastore which stores the relationships of the namespaces
+// in the routing table in the shards to which they are routed, by shard name, and those of
+// every other namespace, along with all namespace definitions, in the coordinator.
+//
+// Revisions are those of the coordinator. Each write to a shard is followed by a write to
+// the coordinator recording the revision of the shard which it made visible, so that reads
+// at a revision of the coordinator are served from the shards at the matching revisions.
+// As a result, a write to a shard only becomes visible once it has been recorded, the
+// relationships of a shard must only be written through the sharded datastore, and a
+// single write cannot span multiple shards.
+func NewShardedDatastore(
+	coordinator datastore.Datastore,
+	shards map[string]datastore.Datastore,
+	routes map[string]string,
+	watchBufferLength uint16,
+) (datastore.Datastore, error) {
+	for namespace, shard := range routes {
+		if _, ok := shards[shard]; !ok {
+			return nil, fmt.Errorf(errUnknownShard, namespace, shard)
+		}
+	}
+
+	if watchBufferLength == 0 {
+		watchBufferLength = defaultWatchBufferLength
+	}
+
+	return &shardedDatastore{
+		coordinator:       coordinator,
+		shards:            shards,
+		routes:            routes,
+		watchBufferLength: watchBufferLength,
+		revisions:         make(map[shardRevisionKey]datastore.Revision),
+	}, nil
+}
+
+// shardFor returns the name of the shard in which the relationships of the namespace are
+// stored, or an empty name if they are stored in the coordinator.
+func (sd *shardedDatastore) shardFor(namespace string) string {
+	return sd.routes[namespace]
+}
+
+// shardForWrite returns the name of the single shard in which the relationships of all of
+// the namespaces are stored.
+func (sd *shardedDatastore) shardForWrite(namespaces []string) (string, error) {
+	if len(namespaces) == 0 {
+		return "", nil
+	}
+
+	shard := sd.shardFor(namespaces[0])
+	for _, namespace := range namespaces[1:] {
+		if sd.shardFor(namespace) != shard {
+			return "", fmt.Errorf(errCrossShardWrite, namespaces[0], namespace)
+		}
+	}
+	return shard, nil
+}
+
+func shardRevisionRelationship(shard string, revision datastore.Revision) *v1.Relationship {
+	return &v1.Relationship{
+		Resource: &v1.ObjectReference{ObjectType: shardNamespace, ObjectId: shard},
+		Relation: shardRevisionRelation,
+		Subject: &v1.SubjectReference{
+			Object: &v1.ObjectReference{ObjectType: shardRevisionNamespace, ObjectId: revision.String()},
+		},
+	}
+}
+
+// recordedShardRevisions returns the revisions of the shard recorded in the coordinator
+// which are live at the revision.
+func (sd *shardedDatastore) recordedShardRevisions(ctx context.Context, shard string, revision datastore.Revision) ([]datastore.Revision, error) {
+	iter, err := sd.coordinator.QueryTuples(ctx, &v1.RelationshipFilter{
+		ResourceType:       shardNamespace,
+		OptionalResourceId: shard,
+		OptionalRelation:   shardRevisionRelation,
+	}, revision)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var recorded []datastore.Revision
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		shardRevision, err := decimal.NewFromString(tpl.User.GetUserset().ObjectId)
+		if err != nil {
+			return nil, err
+		}
+		recorded = append(recorded, shardRevision)
+	}
+	return recorded, iter.Err()
+}
+
+// recordShardRevision records that the write to the shard at the shard revision is visible
+// from the returned revision of the coordinator onward. Concurrent writers may each record
+// their revision, so the latest of the recorded revisions is the visible one.
+func (sd *shardedDatastore) recordShardRevision(ctx context.Context, shard string, shardRevision datastore.Revision) (datastore.Revision, error) {
+	head, err := sd.coordinator.HeadRevision(ctx)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+
+	recorded, err := sd.recordedShardRevisions(ctx, shard, head)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+
+	superseded := false
+	mutations := make([]*v1.RelationshipUpdate, 0, len(recorded)+1)
+	for _, previous := range recorded {
+		if previous.LessThan(shardRevision) {
+			mutations = append(mutations, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+				Relationship: shardRevisionRelationship(shard, previous),
+			})
+		} else {
+			superseded = true
+		}
+	}
+	if !superseded {
+		mutations = append(mutations, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: shardRevisionRelationship(shard, shardRevision),
+		})
+	}
+
+	return sd.coordinator.WriteTuples(ctx, nil, mutations)
+}
+
+// shardRevision returns the revision of the shard which matches the revision of the
+// coordinator. Shards which were never written through the sharded datastore are read at
+// their head revision.
+func (sd *shardedDatastore) shardRevision(ctx context.Context, shard string, revision datastore.Revision) (datastore.Revision, error) {
+	key := shardRevisionKey{datastore.TenantFromContext(ctx), shard, revision.String()}
+
+	sd.revisionsLock.Lock()
+	cached, ok := sd.revisions[key]
+	sd.revisionsLock.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	recorded, err := sd.recordedShardRevisions(ctx, shard, revision)
+	if err != nil {
+		return datastore.NoRevision, fmt.Errorf(errShardRevision, shard, err)
+	}
+	if len(recorded) == 0 {
+		return sd.shards[shard].HeadRevision(ctx)
+	}
+
+	latest := recorded[0]
+	for _, shardRevision := range recorded[1:] {
+		if shardRevision.GreaterThan(latest) {
+			latest = shardRevision
+		}
+	}
+
+	// The shard revision matching a revision never changes once recorded.
+	sd.revisionsLock.Lock()
+	if len(sd.revisions) >= shardRevisionCacheSize {
+		sd.revisions = make(map[shardRevisionKey]datastore.Revision)
+	}
+	sd.revisions[key] = latest
+	sd.revisionsLock.Unlock()
+
+	return latest, nil
+}
+
+func (sd *shardedDatastore) WriteTuples(ctx context.Context, preconditions []*v1.Precondition, mutations []*v1.RelationshipUpdate) (datastore.Revision, error) {
+	namespaces := make([]string, 0, len(preconditions)+len(mutations))
+	for _, precondition := range preconditions {
+		namespaces = append(namespaces, precondition.Filter.ResourceType)
+	}
+	for _, mutation := range mutations {
+		namespaces = append(namespaces, mutation.Relationship.Resource.ObjectType)
+	}
+
+	shard, err := sd.shardForWrite(namespaces)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+	if shard == "" {
+		return sd.coordinator.WriteTuples(ctx, preconditions, mutations)
+	}
+
+	shardRevision, err := sd.shards[shard].WriteTuples(ctx, preconditions, mutations)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+	return sd.recordShardRevision(ctx, shard, shardRevision)
+}
+
+func (sd *shardedDatastore) DeleteRelationships(ctx context.Context, preconditions []*v1.Precondition, filter *v1.RelationshipFilter) (datastore.Revision, error) {
+	namespaces := make([]string, 0, len(preconditions)+1)
+	namespaces = append(namespaces, filter.ResourceType)
+	for _, precondition := range preconditions {
+		namespaces = append(namespaces, precondition.Filter.ResourceType)
+	}
+
+	shard, err := sd.shardForWrite(namespaces)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+	if shard == "" {
+		return sd.coordinator.DeleteRelationships(ctx, preconditions, filter)
+	}
+
+	shardRevision, err := sd.shards[shard].DeleteRelationships(ctx, preconditions, filter)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+	return sd.recordShardRevision(ctx, shard, shardRevision)
+}
+
+func (sd *shardedDatastore) OptimizedRevision(ctx context.Context) (datastore.Revision, error) {
+	return sd.coordinator.OptimizedRevision(ctx)
+}
+
+func (sd *shardedDatastore) HeadRevision(ctx context.Context) (datastore.Revision, error) {
+	return sd.coordinator.HeadRevision(ctx)
+}
+
+func (sd *shardedDatastore) CheckRevision(ctx context.Context, revision datastore.Revision) error {
+	return sd.coordinator.CheckRevision(ctx, revision)
+}
+
+func (sd *shardedDatastore) WriteNamespace(ctx context.Context, newConfig *v0.NamespaceDefinition) (datastore.Revision, error) {
+	return sd.coordinator.WriteNamespace(ctx, newConfig)
+}
+
+func (sd *shardedDatastore) ReadNamespace(ctx context.Context, nsName string, revision datastore.Revision) (*v0.NamespaceDefinition, datastore.Revision, error) {
+	return sd.coordinator.ReadNamespace(ctx, nsName, revision)
+}
+
+func (sd *shardedDatastore) ListNamespaces(ctx context.Context, revision datastore.Revision) ([]*v0.NamespaceDefinition, error) {
+	return sd.coordinator.ListNamespaces(ctx, revision)
+}
+
+func (sd *shardedDatastore) DeleteNamespace(ctx context.Context, nsName string) (datastore.Revision, error) {
+	if shard := sd.shardFor(nsName); shard != "" {
+		shardRevision, err := sd.shards[shard].DeleteRelationships(ctx, nil, &v1.RelationshipFilter{ResourceType: nsName})
+		if err != nil {
+			return datastore.NoRevision, err
+		}
+		if _, err := sd.recordShardRevision(ctx, shard, shardRevision); err != nil {
+			return datastore.NoRevision, err
+		}
+	}
+
+	return sd.coordinator.DeleteNamespace(ctx, nsName)
+}
+
+func (sd *shardedDatastore) QueryTuples(
+	ctx context.Context,
+	filter *v1.RelationshipFilter,
+	revision datastore.Revision,
+	opts ...options.QueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	shard := sd.shardFor(filter.ResourceType)
+	if shard == "" {
+		return sd.coordinator.QueryTuples(ctx, filter, revision, opts...)
+	}
+
+	shardRevision, err := sd.shardRevision(ctx, shard, revision)
+	if err != nil {
+		return nil, err
+	}
+	return sd.shards[shard].QueryTuples(ctx, filter, shardRevision, opts...)
+}
+
+func (sd *shardedDatastore) ReverseQueryTuples(
+	ctx context.Context,
+	subjectFilter *v1.SubjectFilter,
+	revision datastore.Revision,
+	opts ...options.ReverseQueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	queryOpts := options.NewReverseQueryOptionsWithOptions(opts...)
+	if queryOpts.ResRelation != nil {
+		shard := sd.shardFor(queryOpts.ResRelation.Namespace)
+		if shard == "" {
+			return sd.coordinator.ReverseQueryTuples(ctx, subjectFilter, revision, opts...)
+		}
+
+		shardRevision, err := sd.shardRevision(ctx, shard, revision)
+		if err != nil {
+			return nil, err
+		}
+		return sd.shards[shard].ReverseQueryTuples(ctx, subjectFilter, shardRevision, opts...)
+	}
+
+	// Relationships to the subject may be of any namespace, so every shard is queried in turn.
+	queries := []func() (datastore.TupleIterator, error){
+		func() (datastore.TupleIterator, error) {
+			return sd.coordinator.ReverseQueryTuples(ctx, subjectFilter, revision, opts...)
+		},
+	}
+	for _, shard := range sd.shardNames() {
+		shard := shard
+		queries = append(queries, func() (datastore.TupleIterator, error) {
+			shardRevision, err := sd.shardRevision(ctx, shard, revision)
+			if err != nil {
+				return nil, err
+			}
+			return sd.shards[shard].ReverseQueryTuples(ctx, subjectFilter, shardRevision, opts...)
+		})
+	}
+
+	return &concatTupleIterator{queries: queries, limit: queryOpts.ReverseLimit}, nil
+}
+
+func (sd *shardedDatastore) shardNames() []string {
+	names := make([]string, 0, len(sd.shards))
+	for name := range sd.shards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// concatTupleIterator iterates over the results of each of the queries in turn, running
+// each once those of the previous one have been read, up to an optional limit.
+type concatTupleIterator struct {
+	queries []func() (datastore.TupleIterator, error)
+	limit   *uint64
+
+	current datastore.TupleIterator
+	count   uint64
+	err     error
+	closed  bool
+}
+
+func (cti *concatTupleIterator) Next() *v0.RelationTuple {
+	for !cti.closed && cti.err == nil {
+		if cti.limit != nil && cti.count >= *cti.limit {
+			return nil
+		}
+
+		if cti.current == nil {
+			if len(cti.queries) == 0 {
+				return nil
+			}
+
+			cti.current, cti.err = cti.queries[0]()
+			cti.queries = cti.queries[1:]
+			continue
+		}
+
+		if tpl := cti.current.Next(); tpl != nil {
+			cti.count++
+			return tpl
+		}
+
+		cti.err = cti.current.Err()
+		cti.current.Close()
+		cti.current = nil
+	}
+	return nil
+}
+
+func (cti *concatTupleIterator) Err() error {
+	return cti.err
+}
+
+func (cti *concatTupleIterator) Close() {
+	if cti.current != nil {
+		cti.current.Close()
+		cti.current = nil
+	}
+	cti.closed = true
+}
+
+func (sd *shardedDatastore) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	newChangeChan := make(chan *datastore.RevisionChanges, sd.watchBufferLength)
+	newErrChan := make(chan error, 1)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	fail := func(err error) (<-chan *datastore.RevisionChanges, <-chan error) {
+		cancel()
+		newErrChan <- err
+		close(newErrChan)
+		close(newChangeChan)
+		return newChangeChan, newErrChan
+	}
+
+	// The recorded revisions of the shards must be reported even if the watch is filtered.
+	coordinatorOpts := opts
+	if len(options.NewWatchOptionsWithOptions(opts...).Filters) > 0 {
+		coordinatorOpts = append(append([]options.WatchOptionsOption{}, opts...), options.WithFilters(&v1.RelationshipFilter{
+			ResourceType: shardNamespace,
+		}))
+	}
+
+	// Each shard is watched from its revision matching the revision after which the
+	// coordinator is watched.
+	merger := &shardChangeMerger{
+		covered:      make(map[string]datastore.Revision, len(sd.shards)),
+		pending:      make(map[string][]*datastore.RevisionChanges, len(sd.shards)),
+		lastRevision: afterRevision,
+	}
+	shardChanges := make(chan shardChange)
+	shardErrs := make(chan error, len(sd.shards))
+	for _, shard := range sd.shardNames() {
+		shardRevision, err := sd.shardRevision(ctx, shard, afterRevision)
+		if err != nil {
+			return fail(err)
+		}
+		merger.covered[shard] = shardRevision
+
+		changes, errs := sd.shards[shard].Watch(watchCtx, shardRevision, opts...)
+		go forwardShardChanges(watchCtx, shard, changes, errs, shardChanges, shardErrs)
+	}
+
+	changeChan, errChan := sd.coordinator.Watch(watchCtx, afterRevision, coordinatorOpts...)
+
+	go func() {
+		defer cancel()
+		defer close(newChangeChan)
+		defer datastore.WatchBufferQueue.Track(func() int { return len(newChangeChan) }, cap(newChangeChan))()
+
+		send := func(changes *datastore.RevisionChanges) bool {
+			select {
+			case newChangeChan <- changes:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case change, ok := <-changeChan:
+				if !ok {
+					changeChan = nil
+					continue
+				}
+				if !send(merger.coordinatorChange(change)) {
+					return
+				}
+
+			case change := <-shardChanges:
+				if late := merger.shardChange(change); late != nil && !send(late) {
+					return
+				}
+
+			case err := <-shardErrs:
+				newErrChan <- err
+				close(newErrChan)
+				return
+
+			case err, ok := <-errChan:
+				if ok {
+					newErrChan <- err
+				}
+				close(newErrChan)
+				return
+			}
+		}
+	}()
+
+	return newChangeChan, newErrChan
+}
+
+type shardChange struct {
+	shard   string
+	changes *datastore.RevisionChanges
+}
+
+func forwardShardChanges(
+	ctx context.Context,
+	shard string,
+	changes <-chan *datastore.RevisionChanges,
+	errs <-chan error,
+	shardChanges chan<- shardChange,
+	shardErrs chan<- error,
+) {
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			select {
+			case shardChanges <- shardChange{shard, change}:
+			case <-ctx.Done():
+				return
+			}
+
+		case err, ok := <-errs:
+			if ok && ctx.Err() == nil {
+				shardErrs <- fmt.Errorf("unable to watch shard `%s`: %w", shard, err)
+			}
+			return
+		}
+	}
+}
+
+// shardChangeMerger merges the changes of the shards into those of the coordinator. The
+// changes of a shard are held until the coordinator reports the revision at which they
+// were recorded, and are then reported along with the changes of the coordinator at that
+// revision.
+type shardChangeMerger struct {
+	// covered is the latest recorded revision of each shard.
+	covered map[string]datastore.Revision
+
+	// pending holds the changes of each shard which have not yet been recorded.
+	pending map[string][]*datastore.RevisionChanges
+
+	lastRevision datastore.Revision
+}
+
+func (scm *shardChangeMerger) coordinatorChange(change *datastore.RevisionChanges) *datastore.RevisionChanges {
+	merged := &datastore.RevisionChanges{
+		Revision: change.Revision,
+		Changes:  make([]*v0.RelationTupleUpdate, 0, len(change.Changes)),
+		Metadata: change.Metadata,
+	}
+
+	for _, update := range change.Changes {
+		if update.Tuple.ObjectAndRelation.Namespace != shardNamespace {
+			merged.Changes = append(merged.Changes, update)
+			continue
+		}
+		if update.Operation == v0.RelationTupleUpdate_DELETE {
+			continue
+		}
+
+		shard := update.Tuple.ObjectAndRelation.ObjectId
+		recorded, err := decimal.NewFromString(update.Tuple.User.GetUserset().ObjectId)
+		if err == nil && recorded.GreaterThan(scm.covered[shard]) {
+			scm.covered[shard] = recorded
+		}
+	}
+
+	for shard, pending := range scm.pending {
+		remaining := pending[:0]
+		for _, shardChanges := range pending {
+			if shardChanges.Revision.GreaterThan(scm.covered[shard]) {
+				remaining = append(remaining, shardChanges)
+				continue
+			}
+			merged.Changes = append(merged.Changes, shardChanges.Changes...)
+		}
+		scm.pending[shard] = remaining
+	}
+
+	scm.lastRevision = change.Revision
+	return merged
+}
+
+// shardChange holds the changes of a shard until they are recorded, returning them to be
+// reported at the latest revision if they were already recorded.
+func (scm *shardChangeMerger) shardChange(change shardChange) *datastore.RevisionChanges {
+	if change.changes.Revision.GreaterThan(scm.covered[change.shard]) {
+		scm.pending[change.shard] = append(scm.pending[change.shard], change.changes)
+		return nil
+	}
+
+	if len(change.changes.Changes) == 0 {
+		return nil
+	}
+	return &datastore.RevisionChanges{
+		Revision: scm.lastRevision,
+		Changes:  change.changes.Changes,
+		Metadata: change.changes.Metadata,
+	}
+}
+
+func (sd *shardedDatastore) IsReady(ctx context.Context) (bool, error) {
+	ready, err := sd.coordinator.IsReady(ctx)
+	if err != nil || !ready {
+		return ready, err
+	}
+
+	for _, shard := range sd.shards {
+		ready, err := shard.IsReady(ctx)
+		if err != nil || !ready {
+			return ready, err
+		}
+	}
+	return true, nil
+}
+
+// UniqueID returns the unique ID of the coordinator, whose revisions are those of the
+// sharded datastore.
+func (sd *shardedDatastore) UniqueID(ctx context.Context) (string, error) {
+	return datastore.UniqueID(ctx, sd.coordinator)
+}
+
+// CollectGarbage performs a garbage collection pass against the coordinator and each shard
+// which garbage collects revisions itself.
+func (sd *shardedDatastore) CollectGarbage(ctx context.Context) (int64, int64, error) {
+	var relsRemoved, txnsRemoved int64
+	for _, ds := range sd.all() {
+		gc, ok := ds.(datastore.GarbageCollector)
+		if !ok {
+			continue
+		}
+
+		rels, txns, err := gc.CollectGarbage(ctx)
+		relsRemoved += rels
+		txnsRemoved += txns
+		if err != nil {
+			return relsRemoved, txnsRemoved, err
+		}
+	}
+	return relsRemoved, txnsRemoved, nil
+}
+
+func (sd *shardedDatastore) all() []datastore.Datastore {
+	all := []datastore.Datastore{sd.coordinator}
+	for _, shard := range sd.shardNames() {
+		all = append(all, sd.shards[shard])
+	}
+	return all
+}
+
+func (sd *shardedDatastore) Close() error {
+	var closeErr error
+	for _, ds := range sd.all() {
+		if err := ds.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func newShardedTestDatastore(t *testing.T) (datastore.Datastore, datastore.Datastore) {
+	coordinator, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(t, err)
+	docs, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(t, err)
+
+	ds, err := NewShardedDatastore(coordinator, map[string]datastore.Datastore{"docs": docs}, map[string]string{"document": "docs"}, 0)
+	require.NoError(t, err)
+	return ds, docs
+}
+
+func writeTuples(t *testing.T, ds datastore.Datastore, tuples ...string) datastore.Revision {
+	mutations := make([]*v1.RelationshipUpdate, 0, len(tuples))
+	for _, tpl := range tuples {
+		mutations = append(mutations, tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse(tpl))))
+	}
+
+	revision, err := ds.WriteTuples(context.Background(), nil, mutations)
+	require.NoError(t, err)
+	return revision
+}
+
+func TestShardedDatastore(t *testing.T) {
+	require := require.New(t)
+
+	ds, docs := newShardedTestDatastore(t)
+	defer ds.Close()
+	ctx := context.Background()
+
+	firstRev := writeTuples(t, ds, "folder:first#viewer@user:tom")
+	secondRev := writeTuples(t, ds, "document:first#viewer@user:tom")
+
+	// The relationships of routed namespaces are only stored in their shard.
+	shardHead, err := docs.HeadRevision(ctx)
+	require.NoError(err)
+	require.Len(readAll(t, docs.QueryTuples(ctx, &v1.RelationshipFilter{ResourceType: "document"}, shardHead)), 1)
+	require.Len(readAll(t, docs.QueryTuples(ctx, &v1.RelationshipFilter{ResourceType: "folder"}, shardHead)), 0)
+
+	// Reads at a revision see the writes of the shards recorded by then.
+	documents := &v1.RelationshipFilter{ResourceType: "document"}
+	require.Len(readAll(t, ds.QueryTuples(ctx, documents, firstRev)), 0)
+	require.Len(readAll(t, ds.QueryTuples(ctx, documents, secondRev)), 1)
+	require.Len(readAll(t, ds.QueryTuples(ctx, &v1.RelationshipFilter{ResourceType: "folder"}, secondRev)), 1)
+
+	thirdRev := writeTuples(t, ds, "document:second#viewer@user:tom")
+	require.True(thirdRev.GreaterThan(secondRev))
+	require.Len(readAll(t, ds.QueryTuples(ctx, documents, secondRev)), 1)
+	require.Len(readAll(t, ds.QueryTuples(ctx, documents, thirdRev)), 2)
+
+	// Reverse queries span every shard, unless limited to a resource namespace.
+	subjects := &v1.SubjectFilter{SubjectType: "user"}
+	require.Len(readAll(t, ds.ReverseQueryTuples(ctx, subjects, thirdRev)), 3)
+	limit := uint64(2)
+	require.Len(readAll(t, ds.ReverseQueryTuples(ctx, subjects, thirdRev, options.WithReverseLimit(&limit))), 2)
+	require.Len(readAll(t, ds.ReverseQueryTuples(ctx, subjects, thirdRev, options.WithResRelation(&options.ResourceRelation{
+		Namespace: "document",
+		Relation:  "viewer",
+	}))), 2)
+
+	// Deletions are routed in the same way.
+	deletedRev, err := ds.DeleteRelationships(ctx, nil, &v1.RelationshipFilter{ResourceType: "document", OptionalResourceId: "first"})
+	require.NoError(err)
+	require.Len(readAll(t, ds.QueryTuples(ctx, documents, deletedRev)), 1)
+	require.Len(readAll(t, ds.QueryTuples(ctx, documents, thirdRev)), 2)
+
+	// A single write cannot span shards.
+	_, err = ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{
+		tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:third#viewer@user:tom"))),
+		tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("folder:third#viewer@user:tom"))),
+	})
+	require.Error(err)
+}
+
+func TestShardedDatastoreUnknownShard(t *testing.T) {
+	coordinator, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(t, err)
+
+	_, err = NewShardedDatastore(coordinator, nil, map[string]string{"document": "docs"}, 0)
+	require.Error(t, err)
+}
+
+func TestShardedDatastoreWatch(t *testing.T) {
+	require := require.New(t)
+
+	ds, _ := newShardedTestDatastore(t)
+	defer ds.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	startRev, err := ds.HeadRevision(ctx)
+	require.NoError(err)
+	changes, errs := ds.Watch(ctx, startRev)
+
+	folderRev := writeTuples(t, ds, "folder:first#viewer@user:tom")
+	documentRev := writeTuples(t, ds, "document:first#viewer@user:tom")
+
+	var found []*v0.RelationTupleUpdate
+	for len(found) < 2 {
+		select {
+		case change := <-changes:
+			require.True(change.Revision.LessThanOrEqual(documentRev))
+			for _, update := range change.Changes {
+				if update.Tuple.ObjectAndRelation.Namespace == "document" {
+					require.True(change.Revision.GreaterThan(folderRev))
+				}
+			}
+			found = append(found, change.Changes...)
+		case err := <-errs:
+			require.NoError(err)
+		case <-time.After(5 * time.Second):
+			require.FailNow("timed out waiting for changes")
+		}
+	}
+
+	// The recorded revisions of the shards are never reported.
+	require.Len(found, 2)
+	require.Equal("folder:first#viewer@user:tom", tuple.String(found[0].Tuple))
+	require.Equal("document:first#viewer@user:tom", tuple.String(found[1].Tuple))
+}
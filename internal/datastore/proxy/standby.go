@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+)
+
+var standbyRevisionCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "standby_revisions_total",
+	Help:      "total number of times a stale revision was offered because the datastore was unavailable",
+})
+
+type standbyProxy struct {
+	delegate     datastore.Datastore
+	maxStaleness time.Duration
+
+	lock           sync.RWMutex
+	lastRevision   datastore.Revision
+	lastDetermined time.Time
+}
+
+// NewStandbyProxy creates a proxy which remembers the most recent optimized revision of the
+// delegate datastore. If the optimized revision cannot be determined, because the datastore
+// is unavailable, and the remembered revision is no older than maxStaleness, an
+// ErrStaleRevision carrying it is returned, allowing callers which tolerate stale results to
+// continue serving from their caches.
+func NewStandbyProxy(delegate datastore.Datastore, maxStaleness time.Duration) datastore.Datastore {
+	return &standbyProxy{
+		delegate:     delegate,
+		maxStaleness: maxStaleness,
+	}
+}
+
+func (p *standbyProxy) OptimizedRevision(ctx context.Context) (datastore.Revision, error) {
+	revision, err := p.delegate.OptimizedRevision(ctx)
+	if err == nil {
+		p.lock.Lock()
+		if revision.GreaterThanOrEqual(p.lastRevision) {
+			p.lastRevision = revision
+			p.lastDetermined = time.Now()
+		}
+		p.lock.Unlock()
+		return revision, nil
+	}
+
+	// The caller gave up, which says nothing about the availability of the datastore.
+	if ctx.Err() != nil {
+		return revision, err
+	}
+
+	p.lock.RLock()
+	lastRevision, lastDetermined := p.lastRevision, p.lastDetermined
+	p.lock.RUnlock()
+
+	if lastDetermined.IsZero() {
+		return revision, err
+	}
+
+	age := time.Since(lastDetermined)
+	if age > p.maxStaleness {
+		return revision, err
+	}
+
+	standbyRevisionCount.Inc()
+	log.Ctx(ctx).Warn().Err(err).Dur("age", age).Msg("datastore unavailable, offering stale revision")
+	return datastore.NoRevision, datastore.NewStaleRevisionErr(err, lastRevision, age)
+}
+
+// Unwrap implements datastore.Unwrapper.
+func (p *standbyProxy) Unwrap() datastore.Datastore {
+	return p.delegate
+}
+
+func (p *standbyProxy) Close() error {
+	return p.delegate.Close()
+}
+
+func (p *standbyProxy) IsReady(ctx context.Context) (bool, error) {
+	return p.delegate.IsReady(ctx)
+}
+
+func (p *standbyProxy) DeleteRelationships(ctx context.Context, preconditions []*v1.Precondition, filter *v1.RelationshipFilter) (datastore.Revision, error) {
+	return p.delegate.DeleteRelationships(ctx, preconditions, filter)
+}
+
+func (p *standbyProxy) WriteTuples(ctx context.Context, preconditions []*v1.Precondition, mutations []*v1.RelationshipUpdate) (datastore.Revision, error) {
+	return p.delegate.WriteTuples(ctx, preconditions, mutations)
+}
+
+func (p *standbyProxy) HeadRevision(ctx context.Context) (datastore.Revision, error) {
+	return p.delegate.HeadRevision(ctx)
+}
+
+func (p *standbyProxy) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	return p.delegate.Watch(ctx, afterRevision, opts...)
+}
+
+func (p *standbyProxy) WriteNamespace(ctx context.Context, newConfig *v0.NamespaceDefinition) (datastore.Revision, error) {
+	return p.delegate.WriteNamespace(ctx, newConfig)
+}
+
+func (p *standbyProxy) ReadNamespace(ctx context.Context, nsName string, revision datastore.Revision) (*v0.NamespaceDefinition, datastore.Revision, error) {
+	return p.delegate.ReadNamespace(ctx, nsName, revision)
+}
+
+func (p *standbyProxy) DeleteNamespace(ctx context.Context, nsName string) (datastore.Revision, error) {
+	return p.delegate.DeleteNamespace(ctx, nsName)
+}
+
+func (p *standbyProxy) QueryTuples(
+	ctx context.Context,
+	filter *v1.RelationshipFilter,
+	revision datastore.Revision,
+	options ...options.QueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	return p.delegate.QueryTuples(ctx, filter, revision, options...)
+}
+
+func (p *standbyProxy) ReverseQueryTuples(
+	ctx context.Context,
+	subjectFilter *v1.SubjectFilter,
+	revision datastore.Revision,
+	options ...options.ReverseQueryOptionsOption,
+) (datastore.TupleIterator, error) {
+	return p.delegate.ReverseQueryTuples(ctx, subjectFilter, revision, options...)
+}
+
+func (p *standbyProxy) CheckRevision(ctx context.Context, revision datastore.Revision) error {
+	return p.delegate.CheckRevision(ctx, revision)
+}
+
+func (p *standbyProxy) ListNamespaces(ctx context.Context, revision datastore.Revision) ([]*v0.NamespaceDefinition, error) {
+	return p.delegate.ListNamespaces(ctx, revision)
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+var errUnavailable = errors.New("connection refused")
+
+func TestStandbyOffersStaleRevision(t *testing.T) {
+	require := require.New(t)
+
+	delegate := &delegateMock{}
+	ds := NewStandbyProxy(delegate, 1*time.Hour)
+	ctx := context.Background()
+
+	delegate.On("OptimizedRevision").Return(expectedRevision, nil).Once()
+	revision, err := ds.OptimizedRevision(ctx)
+	require.NoError(err)
+	require.Equal(expectedRevision, revision)
+
+	delegate.On("OptimizedRevision").Return(datastore.NoRevision, errUnavailable).Once()
+	_, err = ds.OptimizedRevision(ctx)
+
+	var staleErr datastore.ErrStaleRevision
+	require.ErrorAs(err, &staleErr)
+	require.Equal(expectedRevision, staleErr.StaleRevision())
+	require.Less(staleErr.Age(), 1*time.Hour)
+	delegate.AssertExpectations(t)
+}
+
+func TestStandbyRequiresRecentRevision(t *testing.T) {
+	require := require.New(t)
+
+	delegate := &delegateMock{}
+	ds := NewStandbyProxy(delegate, 0)
+	ctx := context.Background()
+
+	delegate.On("OptimizedRevision").Return(datastore.NoRevision, errUnavailable).Once()
+	_, err := ds.OptimizedRevision(ctx)
+	require.ErrorIs(err, errUnavailable)
+
+	delegate.On("OptimizedRevision").Return(expectedRevision, nil).Once()
+	_, err = ds.OptimizedRevision(ctx)
+	require.NoError(err)
+
+	time.Sleep(1 * time.Millisecond)
+
+	delegate.On("OptimizedRevision").Return(datastore.NoRevision, errUnavailable).Once()
+	_, err = ds.OptimizedRevision(ctx)
+	require.ErrorIs(err, errUnavailable)
+	delegate.AssertExpectations(t)
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestProxyChainOptionalInterfaces(t *testing.T) {
+	require := require.New(t)
+
+	delegate, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	// The proxies are chained in the same order as by the server.
+	ds := NewNamespaceCachingProxy(delegate)
+	ds = NewHedgingProxy(ds, 10*time.Millisecond, 100, 0.9)
+	ds = NewQueryCachingProxy(ds, 1024*1024)
+	ds = NewStandbyProxy(ds, time.Second)
+	maintenanceDS := NewMaintenanceDatastore(ds, true)
+	defer maintenanceDS.Close()
+
+	ctx := context.Background()
+
+	var watcher datastore.NamespaceWatcher
+	require.True(datastore.As(maintenanceDS, &watcher))
+	require.Equal(delegate, watcher)
+
+	var auditor datastore.TupleAuditor
+	require.True(datastore.As(maintenanceDS, &auditor))
+
+	expectedID, err := datastore.UniqueID(ctx, delegate)
+	require.NoError(err)
+	foundID, err := datastore.UniqueID(ctx, maintenanceDS)
+	require.NoError(err)
+	require.NotEmpty(foundID)
+	require.Equal(expectedID, foundID)
+
+	var inspector datastore.IndexInspector
+	require.False(datastore.As(maintenanceDS, &inspector))
+
+	// Proxies which restrict writes do so for bulk loads as well.
+	relationships := []*v1.Relationship{tuple.MustToRelationship(tuple.MustParse("document:first#viewer@user:tom"))}
+	_, err = datastore.BulkLoad(ctx, maintenanceDS, relationships)
+	require.ErrorAs(err, &datastore.ErrMaintenanceMode{})
+
+	_, err = datastore.BulkLoad(ctx, NewReadonlyDatastore(ds), relationships)
+	require.ErrorAs(err, &datastore.ErrReadOnly{})
+}
@@ -0,0 +1,16 @@
+package datastore
+
+import "github.com/authzed/spicedb/internal/queuemetrics"
+
+var (
+	// WatchBufferQueue is the queue of revision changes buffered for the consumers of
+	// Watch. A watcher whose buffer fills is disconnected.
+	WatchBufferQueue = queuemetrics.NewQueue("watch_buffer")
+
+	// NamespaceWatchBufferQueue is the queue of namespace changes buffered for the
+	// consumers of WatchNamespaces.
+	NamespaceWatchBufferQueue = queuemetrics.NewQueue("namespace_watch_buffer")
+
+	// DeleteBatchQueue is the stage deleting relationships in batches.
+	DeleteBatchQueue = queuemetrics.NewQueue("delete_batch")
+)
@@ -0,0 +1,27 @@
+package datastore
+
+import "context"
+
+// DefaultTenant is the tenant of operations whose context carries no tenant. Data written
+// before tenancy was introduced belongs to it.
+const DefaultTenant = ""
+
+type tenantCtxKeyType struct{}
+
+var tenantKey tenantCtxKeyType = struct{}{}
+
+// ContextWithTenant returns a new context which scopes the datastore operations performed
+// using it to the specified tenant. Datastores which isolate tenants read and write only
+// the data of that tenant.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// TenantFromContext returns the tenant carried by the context, or DefaultTenant if none
+// is present.
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey).(string); ok {
+		return tenant
+	}
+	return DefaultTenant
+}
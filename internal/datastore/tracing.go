@@ -4,12 +4,41 @@ import (
 	"context"
 
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/authzed/spicedb/pkg/middleware/priority"
 )
 
 // SeparateContextWithTracing is a utility method which allows for severing the context between
 // grpc and the datastore to prevent context cancellation from killing database connections that
 // should otherwise go back to the connection pool.
+//
+// The tenant and transaction metadata carried by the context describe the operation itself,
+// rather than the request, and are kept. So is the priority class, which selects the
+// connection pool from which the operation is served.
 func SeparateContextWithTracing(ctx context.Context) context.Context {
 	span := trace.SpanFromContext(ctx)
-	return trace.ContextWithSpan(context.Background(), span)
+	separated := trace.ContextWithSpan(context.Background(), span)
+
+	if tenant := TenantFromContext(ctx); tenant != DefaultTenant {
+		separated = ContextWithTenant(separated, tenant)
+	}
+	if metadata := TransactionMetadataFromContext(ctx); metadata != nil {
+		separated = ContextWithTransactionMetadata(separated, metadata)
+	}
+	if class := priority.FromContext(ctx); class != priority.Interactive {
+		separated = priority.ContextWithClass(separated, class)
+	}
+
+	return separated
+}
+
+// StartSpan starts a span with the tracer, unless the context carries a trace which was
+// not sampled, in which case no span is started and the returned span does nothing.
+// Datastores start many short spans, such as one per statement or row, which would
+// otherwise be created for every request whether or not it is traced.
+func StartSpan(ctx context.Context, tracer trace.Tracer, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if parent := trace.SpanContextFromContext(ctx); parent.IsValid() && !parent.IsSampled() {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return tracer.Start(ctx, name, opts...)
 }
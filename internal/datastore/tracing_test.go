@@ -0,0 +1,62 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/authzed/spicedb/pkg/middleware/priority"
+)
+
+func TestStartSpan(t *testing.T) {
+	tracer := sdktrace.NewTracerProvider().Tracer("test")
+
+	parentWithFlags := func(flags trace.TraceFlags) context.Context {
+		return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{0x01},
+			SpanID:     trace.SpanID{0x01},
+			TraceFlags: flags,
+		}))
+	}
+
+	testCases := []struct {
+		name              string
+		ctx               context.Context
+		expectedRecording bool
+	}{
+		{"no parent", context.Background(), true},
+		{"sampled parent", parentWithFlags(trace.FlagsSampled), true},
+		{"unsampled parent", parentWithFlags(0), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, span := StartSpan(tc.ctx, tracer, "span")
+			defer span.End()
+
+			require.Equal(t, tc.expectedRecording, span.IsRecording())
+			if !tc.expectedRecording {
+				require.Equal(t, tc.ctx, ctx)
+			}
+		})
+	}
+}
+
+func TestSeparateContextWithTracing(t *testing.T) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = ContextWithTenant(ctx, "sometenant")
+	ctx = priority.ContextWithClass(ctx, priority.Batch)
+
+	separated := SeparateContextWithTracing(ctx)
+	cancel()
+
+	require.NoError(separated.Err())
+	require.Equal("sometenant", TenantFromContext(separated))
+	require.Equal(priority.Batch, priority.FromContext(separated))
+	require.Equal(priority.Interactive, priority.FromContext(SeparateContextWithTracing(context.Background())))
+}
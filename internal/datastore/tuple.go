@@ -40,6 +40,11 @@ func (sti *sliceTupleIterator) Err() error {
 	return sti.err
 }
 
+// SizeHint implements SizeHinter, with the exact number of remaining tuples.
+func (sti *sliceTupleIterator) SizeHint() (int, bool) {
+	return len(sti.tuples), true
+}
+
 // Close implements TupleIterator
 func (sti *sliceTupleIterator) Close() {
 	if sti.closed {
@@ -0,0 +1,32 @@
+package datastore
+
+import (
+	"testing"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/stretchr/testify/require"
+)
+
+type unhintedIterator struct {
+	TupleIterator
+}
+
+func TestSizeHint(t *testing.T) {
+	require := require.New(t)
+
+	iter := NewSliceTupleIterator([]*v0.RelationTuple{{}, {}})
+	defer iter.Close()
+
+	count, exact := SizeHint(iter)
+	require.Equal(2, count)
+	require.True(exact)
+
+	iter.Next()
+	count, exact = SizeHint(iter)
+	require.Equal(1, count)
+	require.True(exact)
+
+	count, exact = SizeHint(unhintedIterator{iter})
+	require.Equal(0, count)
+	require.False(exact)
+}
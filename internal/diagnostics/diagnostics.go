@@ -0,0 +1,138 @@
+// Package diagnostics reports the state of the datastore and dispatcher of a server as
+// JSON, so that an unhealthy server can be debugged without access to its host or datastore.
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/dispatch/combined"
+)
+
+// Report is the diagnostics of a server.
+type Report struct {
+	Datastore DatastoreReport `json:"datastore"`
+	Dispatch  DispatchReport  `json:"dispatch"`
+}
+
+// DatastoreReport is the diagnostics of the datastore of a server.
+type DatastoreReport struct {
+	Engine string `json:"engine"`
+	Ready  bool   `json:"ready"`
+
+	// Error is the error with which the datastore could not be diagnosed, if any.
+	Error string `json:"error,omitempty"`
+
+	MigrationRevision         string       `json:"migrationRevision,omitempty"`
+	ExpectedMigrationRevision string       `json:"expectedMigrationRevision,omitempty"`
+	ReplicationLag            string       `json:"replicationLag,omitempty"`
+	GCLag                     string       `json:"gcLag,omitempty"`
+	Pools                     []PoolReport `json:"pools,omitempty"`
+}
+
+// PoolReport is the diagnostics of a pool of connections to the datastore.
+type PoolReport struct {
+	Name               string  `json:"name"`
+	MaxConns           int32   `json:"maxConns"`
+	TotalConns         int32   `json:"totalConns"`
+	AcquiredConns      int32   `json:"acquiredConns"`
+	IdleConns          int32   `json:"idleConns"`
+	Saturation         float64 `json:"saturation"`
+	AverageAcquireWait string  `json:"averageAcquireWait"`
+}
+
+// DispatchReport is the diagnostics of the dispatcher of a server.
+type DispatchReport struct {
+	// UpstreamState is the state of the connection to the peers of the dispatch cluster, if
+	// requests are dispatched to peers.
+	UpstreamState string `json:"upstreamState,omitempty"`
+
+	CacheSizeBytes map[string]int64 `json:"cacheSizeBytes"`
+}
+
+// Dispatcher is a dispatcher which can describe its state.
+type Dispatcher interface {
+	Diagnostics() combined.Diagnostics
+}
+
+// Diagnose returns the diagnostics of the datastore of the engine and of the dispatcher.
+// Datastores which are not datastore.Diagnosers only report their readiness.
+func Diagnose(ctx context.Context, engine string, ds datastore.Datastore, dispatcher Dispatcher) Report {
+	report := Report{
+		Datastore: DatastoreReport{Engine: engine},
+	}
+
+	ready, err := ds.IsReady(ctx)
+	report.Datastore.Ready = ready
+	if err != nil {
+		report.Datastore.Error = err.Error()
+	}
+
+	var diagnoser datastore.Diagnoser
+	if datastore.As(ds, &diagnoser) && err == nil {
+		diagnostics, err := diagnoser.Diagnostics(ctx)
+		if err != nil {
+			report.Datastore.Error = err.Error()
+		}
+
+		report.Datastore.MigrationRevision = diagnostics.MigrationRevision
+		report.Datastore.ExpectedMigrationRevision = diagnostics.ExpectedMigrationRevision
+		report.Datastore.ReplicationLag = diagnostics.ReplicationLag.String()
+		report.Datastore.GCLag = diagnostics.GCLag.String()
+		for _, pool := range diagnostics.Pools {
+			report.Datastore.Pools = append(report.Datastore.Pools, PoolReport{
+				Name:               pool.Name,
+				MaxConns:           pool.MaxConns,
+				TotalConns:         pool.TotalConns,
+				AcquiredConns:      pool.AcquiredConns,
+				IdleConns:          pool.IdleConns,
+				Saturation:         pool.Saturation,
+				AverageAcquireWait: pool.AverageAcquireWait.String(),
+			})
+		}
+	}
+
+	if dispatcher != nil {
+		diagnostics := dispatcher.Diagnostics()
+		report.Dispatch = DispatchReport{
+			UpstreamState:  diagnostics.UpstreamState,
+			CacheSizeBytes: diagnostics.CacheSizes,
+		}
+	}
+
+	return report
+}
+
+// NewHandler returns a handler which responds with the diagnostics of the datastore of the
+// engine and of the dispatcher as JSON, taking at most the timeout to diagnose them. The
+// status of the response is 503 if the datastore is not ready or cannot be diagnosed.
+func NewHandler(engine string, ds datastore.Datastore, dispatcher Dispatcher, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		report := Diagnose(ctx, engine, ds, dispatcher)
+
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Datastore.Ready || report.Datastore.Error != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("unable to write diagnostics")
+		}
+	})
+}
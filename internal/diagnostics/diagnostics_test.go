@@ -0,0 +1,123 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch/combined"
+)
+
+type diagnosedDatastore struct {
+	datastore.Datastore
+	diagnostics datastore.Diagnostics
+	err         error
+}
+
+func (dd diagnosedDatastore) Diagnostics(ctx context.Context) (datastore.Diagnostics, error) {
+	return dd.diagnostics, dd.err
+}
+
+type fakeDispatcher struct{}
+
+func (fakeDispatcher) Diagnostics() combined.Diagnostics {
+	return combined.Diagnostics{
+		UpstreamState: "READY",
+		CacheSizes:    map[string]int64{"dispatch": 1024},
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, 1*time.Hour, 0)
+	require.NoError(t, err)
+	defer rawDS.Close()
+
+	testCases := []struct {
+		name           string
+		ds             datastore.Datastore
+		expectedStatus int
+		expected       Report
+	}{
+		{
+			"not a diagnoser",
+			rawDS,
+			http.StatusOK,
+			Report{
+				Datastore: DatastoreReport{Engine: "test", Ready: true},
+				Dispatch:  DispatchReport{UpstreamState: "READY", CacheSizeBytes: map[string]int64{"dispatch": 1024}},
+			},
+		},
+		{
+			"diagnoser",
+			diagnosedDatastore{rawDS, datastore.Diagnostics{
+				MigrationRevision:         "add-tenant",
+				ExpectedMigrationRevision: "add-tenant",
+				GCLag:                     5 * time.Minute,
+				Pools: []datastore.PoolDiagnostics{{
+					Name:               "spicedb",
+					MaxConns:           20,
+					AcquiredConns:      5,
+					Saturation:         0.25,
+					AverageAcquireWait: 2 * time.Millisecond,
+				}},
+			}, nil},
+			http.StatusOK,
+			Report{
+				Datastore: DatastoreReport{
+					Engine:                    "test",
+					Ready:                     true,
+					MigrationRevision:         "add-tenant",
+					ExpectedMigrationRevision: "add-tenant",
+					ReplicationLag:            "0s",
+					GCLag:                     "5m0s",
+					Pools: []PoolReport{{
+						Name:               "spicedb",
+						MaxConns:           20,
+						AcquiredConns:      5,
+						Saturation:         0.25,
+						AverageAcquireWait: "2ms",
+					}},
+				},
+				Dispatch: DispatchReport{UpstreamState: "READY", CacheSizeBytes: map[string]int64{"dispatch": 1024}},
+			},
+		},
+		{
+			"diagnosis failed",
+			diagnosedDatastore{rawDS, datastore.Diagnostics{}, errors.New("connection refused")},
+			http.StatusServiceUnavailable,
+			Report{
+				Datastore: DatastoreReport{
+					Engine:         "test",
+					Ready:          true,
+					Error:          "connection refused",
+					ReplicationLag: "0s",
+					GCLag:          "0s",
+				},
+				Dispatch: DispatchReport{UpstreamState: "READY", CacheSizeBytes: map[string]int64{"dispatch": 1024}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			recorder := httptest.NewRecorder()
+			NewHandler("test", tc.ds, fakeDispatcher{}, 1*time.Second).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/diagnostics", nil))
+			require.Equal(tc.expectedStatus, recorder.Code)
+			require.Equal("application/json", recorder.Header().Get("Content-Type"))
+
+			var report Report
+			require.NoError(json.Unmarshal(recorder.Body.Bytes(), &report))
+			require.Equal(tc.expected, report)
+		})
+	}
+}
@@ -2,14 +2,21 @@ package caching
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 	"unsafe"
 
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	"github.com/dgraph-io/ristretto"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/dispatch"
 	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
 )
@@ -20,14 +27,33 @@ const (
 	prometheusNamespace = "spicedb"
 )
 
+// Option is a function-style option for configuring a caching Dispatcher.
+type Option func(*Dispatcher)
+
+// EntryTTL sets the amount of time after which cached results expire. Zero keeps them
+// until they are evicted to make room for others.
+func EntryTTL(ttl time.Duration) Option {
+	return func(cd *Dispatcher) {
+		cd.ttl = ttl
+	}
+}
+
 type Dispatcher struct {
-	d dispatch.Dispatcher
-	c *ristretto.Cache
+	d   dispatch.Dispatcher
+	c   *ristretto.Cache
+	ttl time.Duration
+
+	// checksInFlight coalesces identical checks made concurrently, so that they share
+	// a single computation.
+	checksInFlight singleflight.Group
 
 	checkTotalCounter      prometheus.Counter
 	checkFromCacheCounter  prometheus.Counter
+	checkSharedCounter     prometheus.Counter
 	lookupTotalCounter     prometheus.Counter
 	lookupFromCacheCounter prometheus.Counter
+	expandTotalCounter     prometheus.Counter
+	expandFromCacheCounter prometheus.Counter
 }
 
 type checkResultEntry struct {
@@ -38,9 +64,16 @@ type lookupResultEntry struct {
 	response *v1.DispatchLookupResponse
 }
 
+type expandResultEntry struct {
+	response *v1.DispatchExpandResponse
+}
+
 var (
 	checkResultEntryCost       = int64(unsafe.Sizeof(checkResultEntry{}))
 	lookupResultEntryEmptyCost = int64(unsafe.Sizeof(lookupResultEntry{}))
+	expandResultEntryEmptyCost = int64(unsafe.Sizeof(expandResultEntry{}))
+	expandTreeNodeCost         = int64(unsafe.Sizeof(v0.RelationTupleTreeNode{}))
+	expandUserCost             = int64(unsafe.Sizeof(v0.User{}) + unsafe.Sizeof(v0.ObjectAndRelation{}))
 )
 
 // NewCachingDispatcher creates a new dispatch.Dispatcher which delegates dispatch requests
@@ -48,6 +81,7 @@ var (
 func NewCachingDispatcher(
 	cacheConfig *ristretto.Config,
 	prometheusSubsystem string,
+	options ...Option,
 ) (*Dispatcher, error) {
 	if cacheConfig == nil {
 		cacheConfig = &ristretto.Config{
@@ -73,6 +107,12 @@ func NewCachingDispatcher(
 		Subsystem: prometheusSubsystem,
 		Name:      "check_from_cache_total",
 	})
+	checkSharedCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "check_shared_total",
+		Help:      "number of checks whose result was shared with identical checks made concurrently.",
+	})
 
 	lookupTotalCounter := prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: prometheusNamespace,
@@ -85,6 +125,17 @@ func NewCachingDispatcher(
 		Name:      "lookup_from_cache_total",
 	})
 
+	expandTotalCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "expand_total",
+	})
+	expandFromCacheCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "expand_from_cache_total",
+	})
+
 	if prometheusSubsystem != "" {
 		err = prometheus.Register(checkTotalCounter)
 		if err != nil {
@@ -96,6 +147,11 @@ func NewCachingDispatcher(
 			return nil, fmt.Errorf(errCachingInitialization, err)
 		}
 
+		err = prometheus.Register(checkSharedCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
+
 		err = prometheus.Register(lookupTotalCounter)
 		if err != nil {
 			return nil, fmt.Errorf(errCachingInitialization, err)
@@ -106,6 +162,16 @@ func NewCachingDispatcher(
 			return nil, fmt.Errorf(errCachingInitialization, err)
 		}
 
+		err = prometheus.Register(expandTotalCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
+
+		err = prometheus.Register(expandFromCacheCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
+
 		// Export some ristretto metrics
 		err = registerMetricsFunc("cache_hits_total", prometheusSubsystem, cache.Metrics.Hits)
 		if err != nil {
@@ -126,9 +192,41 @@ func NewCachingDispatcher(
 		if err != nil {
 			return nil, fmt.Errorf(errCachingInitialization, err)
 		}
+
+		err = registerMetricsFunc("cache_evictions_total", prometheusSubsystem, cache.Metrics.KeysEvicted)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
+
+		err = prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "cache_estimated_size_bytes",
+			Help:      "estimated size of the results held in the cache",
+		}, func() float64 {
+			return float64(estimatedSize(cache))
+		}))
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
+	}
+
+	cd := &Dispatcher{
+		d:                      fakeDelegate{},
+		c:                      cache,
+		checkTotalCounter:      checkTotalCounter,
+		checkFromCacheCounter:  checkFromCacheCounter,
+		checkSharedCounter:     checkSharedCounter,
+		lookupTotalCounter:     lookupTotalCounter,
+		lookupFromCacheCounter: lookupFromCacheCounter,
+		expandTotalCounter:     expandTotalCounter,
+		expandFromCacheCounter: expandFromCacheCounter,
+	}
+	for _, option := range options {
+		option(cd)
 	}
 
-	return &Dispatcher{fakeDelegate{}, cache, checkTotalCounter, checkFromCacheCounter, lookupTotalCounter, lookupFromCacheCounter}, nil
+	return cd, nil
 }
 
 func registerMetricsFunc(name string, subsystem string, metricsFunc func() uint64) error {
@@ -149,7 +247,7 @@ func (cd *Dispatcher) SetDelegate(delegate dispatch.Dispatcher) {
 // DispatchCheck implements dispatch.Check interface
 func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
 	cd.checkTotalCounter.Inc()
-	requestKey := dispatch.CheckRequestToKey(req)
+	requestKey := scopeToTenant(ctx, dispatch.CheckRequestToKey(req))
 
 	if cachedResultRaw, found := cd.c.Get(requestKey); found {
 		cachedResult := cachedResultRaw.(checkResultEntry)
@@ -159,7 +257,7 @@ func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRe
 		}
 	}
 
-	computed, err := cd.d.DispatchCheck(ctx, req)
+	computed, err := cd.dispatchCheckOnce(ctx, requestKey, req)
 
 	// We only want to cache the result if there was no error
 	if err == nil {
@@ -168,7 +266,7 @@ func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRe
 		adjustedComputed.Metadata.DispatchCount = 0
 
 		toCache := checkResultEntry{adjustedComputed}
-		cd.c.Set(requestKey, toCache, checkResultEntryCost)
+		cd.c.SetWithTTL(requestKey, toCache, checkResultEntryCost, cd.ttl)
 	}
 
 	// Return both the computed and err in ALL cases: computed contains resolved metadata even
@@ -176,17 +274,106 @@ func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRe
 	return computed, err
 }
 
-// DispatchExpand implements dispatch.Expand interface and does not do any caching yet.
+// dispatchCheckOnce delegates the check, sharing the computation with any identical check
+// which is already in flight.
+func (cd *Dispatcher) dispatchCheckOnce(ctx context.Context, requestKey string, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	// Checks with less depth remaining may fail where others succeed, so they are only
+	// coalesced with checks of the same depth.
+	flightKey := fmt.Sprintf("%s@%d", requestKey, req.Metadata.DepthRemaining)
+	result, err, shared := cd.checksInFlight.Do(flightKey, func() (interface{}, error) {
+		return cd.d.DispatchCheck(ctx, req)
+	})
+	computed, _ := result.(*v1.DispatchCheckResponse)
+	if !shared {
+		return computed, err
+	}
+
+	// The shared computation ran with the context of whichever check started it, and
+	// may have been cancelled with it while this check is still wanted.
+	if err != nil && ctx.Err() == nil && isContextError(err) {
+		return cd.d.DispatchCheck(ctx, req)
+	}
+
+	cd.checkSharedCounter.Inc()
+	if computed != nil {
+		computed = proto.Clone(computed).(*v1.DispatchCheckResponse)
+	}
+	return computed, err
+}
+
+func isContextError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	code := status.Code(err)
+	return code == codes.Canceled || code == codes.DeadlineExceeded
+}
+
+// DispatchExpand implements dispatch.Expand interface
 func (cd *Dispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
-	resp, err := cd.d.DispatchExpand(ctx, req)
-	return resp, err
+	cd.expandTotalCounter.Inc()
+
+	requestKey := scopeToTenant(ctx, dispatch.ExpandRequestToKey(req))
+	if cachedResultRaw, found := cd.c.Get(requestKey); found {
+		cachedResult := cachedResultRaw.(expandResultEntry)
+		if req.Metadata.DepthRemaining >= cachedResult.response.Metadata.DepthRequired {
+			cd.expandFromCacheCounter.Inc()
+			return cachedResult.response, nil
+		}
+	}
+
+	computed, err := cd.d.DispatchExpand(ctx, req)
+
+	// We only want to cache the result if there was no error
+	if err == nil {
+		adjustedComputed := proto.Clone(computed).(*v1.DispatchExpandResponse)
+		adjustedComputed.Metadata.CachedDispatchCount = adjustedComputed.Metadata.DispatchCount
+		adjustedComputed.Metadata.DispatchCount = 0
+
+		toCache := expandResultEntry{adjustedComputed}
+		estimatedSize := expandResultEntryEmptyCost + estimateTreeNodeSize(toCache.response.TreeNode)
+		cd.c.SetWithTTL(requestKey, toCache, estimatedSize, cd.ttl)
+	}
+
+	// Return both the computed and err in ALL cases: computed contains resolved metadata even
+	// if there was an error.
+	return computed, err
+}
+
+// estimateTreeNodeSize estimates the memory held by an expansion tree, counting each of
+// its nodes and the subjects of its leaves.
+func estimateTreeNodeSize(node *v0.RelationTupleTreeNode) int64 {
+	if node == nil {
+		return 0
+	}
+
+	size := expandTreeNodeCost + estimateONRSize(node.Expanded)
+	if intermediate := node.GetIntermediateNode(); intermediate != nil {
+		for _, child := range intermediate.ChildNodes {
+			size += estimateTreeNodeSize(child)
+		}
+	}
+	if leaf := node.GetLeafNode(); leaf != nil {
+		for _, user := range leaf.Users {
+			size += expandUserCost + estimateONRSize(user.GetUserset())
+		}
+	}
+	return size
+}
+
+func estimateONRSize(onr *v0.ObjectAndRelation) int64 {
+	if onr == nil {
+		return 0
+	}
+	return int64(len(onr.Namespace) + len(onr.ObjectId) + len(onr.Relation))
 }
 
 // DispatchLookup implements dispatch.Lookup interface and does not do any caching yet.
 func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
 	cd.lookupTotalCounter.Inc()
 
-	requestKey := dispatch.LookupRequestToKey(req)
+	requestKey := scopeToTenant(ctx, dispatch.LookupRequestToKey(req))
 	if cachedResultRaw, found := cd.c.Get(requestKey); found {
 		cachedResult := cachedResultRaw.(lookupResultEntry)
 		if req.Metadata.DepthRemaining >= cachedResult.response.Metadata.DepthRequired {
@@ -208,7 +395,6 @@ func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookup
 		adjustedComputed.Metadata.LookupExcludedDirect = nil
 		adjustedComputed.Metadata.LookupExcludedTtu = nil
 
-		requestKey := dispatch.LookupRequestToKey(req)
 		toCache := lookupResultEntry{adjustedComputed}
 
 		estimatedSize := lookupResultEntryEmptyCost
@@ -216,7 +402,7 @@ func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookup
 			estimatedSize += int64(len(onr.Namespace) + len(onr.ObjectId) + len(onr.Relation))
 		}
 
-		cd.c.Set(requestKey, toCache, estimatedSize)
+		cd.c.SetWithTTL(requestKey, toCache, estimatedSize, cd.ttl)
 	}
 
 	// Return both the computed and err in ALL cases: computed contains resolved metadata even
@@ -224,6 +410,30 @@ func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookup
 	return computed, err
 }
 
+// scopeToTenant prefixes a cache key with the tenant of the request, so that results
+// computed for one tenant are never served to another.
+func scopeToTenant(ctx context.Context, key string) string {
+	if tenant := datastore.TenantFromContext(ctx); tenant != datastore.DefaultTenant {
+		return fmt.Sprintf("%s//%s", tenant, key)
+	}
+	return key
+}
+
+// Flush removes every cached result, so that subsequent requests are computed anew.
+func (cd *Dispatcher) Flush() {
+	cd.c.Clear()
+}
+
+// EstimatedSize returns the estimated size in bytes of the results held in the cache, if
+// the cache collects metrics.
+func (cd *Dispatcher) EstimatedSize() int64 {
+	return estimatedSize(cd.c)
+}
+
+func estimatedSize(cache *ristretto.Cache) int64 {
+	return int64(cache.Metrics.CostAdded()) - int64(cache.Metrics.CostEvicted())
+}
+
 func (cd *Dispatcher) Close() error {
 	if cache := cd.c; cache != nil {
 		cache.Close()
@@ -2,12 +2,16 @@ package caching
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 
 	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
@@ -128,6 +132,185 @@ func TestMaxDepthCaching(t *testing.T) {
 	}
 }
 
+func TestFlush(t *testing.T) {
+	require := require.New(t)
+
+	req := &v1.DispatchCheckRequest{
+		ObjectAndRelation: tuple.ParseONR("document:doc1#read"),
+		Subject:           tuple.ParseSubjectONR("user:user1#..."),
+		Metadata: &v1.ResolverMeta{
+			AtRevision:     decimal.Zero.String(),
+			DepthRemaining: 50,
+		},
+	}
+
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	delegate.On("DispatchCheck", req).Return(&v1.DispatchCheckResponse{
+		Membership: v1.DispatchCheckResponse_MEMBER,
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+	}, nil).Times(2)
+
+	dispatch, err := NewCachingDispatcher(nil, "", EntryTTL(time.Minute))
+	require.NoError(err)
+	dispatch.SetDelegate(delegate)
+	defer dispatch.Close()
+
+	// The first request is cached, the second is served from the cache and the third
+	// is computed anew once the cache is flushed.
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			dispatch.Flush()
+		}
+
+		resp, err := dispatch.DispatchCheck(context.Background(), req)
+		require.NoError(err)
+		require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	delegate.AssertExpectations(t)
+}
+
+func TestExpandCaching(t *testing.T) {
+	expandRequest := func(mode v1.DispatchExpandRequest_ExpansionMode, atRevision decimal.Decimal, depthRemaining uint32) *v1.DispatchExpandRequest {
+		return &v1.DispatchExpandRequest{
+			ObjectAndRelation: tuple.ParseONR("document:doc1#viewer"),
+			ExpansionMode:     mode,
+			Metadata: &v1.ResolverMeta{
+				AtRevision:     atRevision.String(),
+				DepthRemaining: depthRemaining,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		req               *v1.DispatchExpandRequest
+		expectPassthrough bool
+	}{
+		{"first request", expandRequest(v1.DispatchExpandRequest_SHALLOW, decimal.Zero, 50), true},
+		{"same request, hit", expandRequest(v1.DispatchExpandRequest_SHALLOW, decimal.Zero, 50), false},
+		{"other expansion mode, miss", expandRequest(v1.DispatchExpandRequest_RECURSIVE, decimal.Zero, 50), true},
+		{"other revision, miss", expandRequest(v1.DispatchExpandRequest_SHALLOW, decimal.NewFromInt(50), 50), true},
+		{"insufficient depth, miss", expandRequest(v1.DispatchExpandRequest_SHALLOW, decimal.Zero, 1), true},
+	}
+
+	require := require.New(t)
+
+	treeNode := &v0.RelationTupleTreeNode{
+		NodeType: &v0.RelationTupleTreeNode_LeafNode{
+			LeafNode: &v0.DirectUserset{
+				Users: []*v0.User{
+					{UserOneof: &v0.User_Userset{Userset: tuple.ParseSubjectONR("user:user1#...")}},
+				},
+			},
+		},
+		Expanded: tuple.ParseONR("document:doc1#viewer"),
+	}
+
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	for _, tc := range testCases {
+		if tc.expectPassthrough {
+			delegate.On("DispatchExpand", tc.req).Return(&v1.DispatchExpandResponse{
+				TreeNode: treeNode,
+				Metadata: &v1.ResponseMeta{
+					DispatchCount: 1,
+					DepthRequired: 2,
+				},
+			}, nil).Times(1)
+		}
+	}
+
+	dispatch, err := NewCachingDispatcher(nil, "")
+	require.NoError(err)
+	dispatch.SetDelegate(delegate)
+	defer dispatch.Close()
+
+	for _, tc := range testCases {
+		resp, err := dispatch.DispatchExpand(context.Background(), tc.req)
+		require.NoError(err, tc.name)
+		require.True(proto.Equal(treeNode, resp.TreeNode), tc.name)
+		if tc.expectPassthrough {
+			require.Equal(uint32(1), resp.Metadata.DispatchCount, tc.name)
+		} else {
+			require.Equal(uint32(0), resp.Metadata.DispatchCount, tc.name)
+			require.Equal(uint32(1), resp.Metadata.CachedDispatchCount, tc.name)
+		}
+
+		// We have to sleep a while to let the cache converge.
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	delegate.AssertExpectations(t)
+}
+
+type blockingDelegate struct {
+	delegateDispatchMock
+	started chan struct{}
+	release chan struct{}
+	checks  int32
+}
+
+func (bd *blockingDelegate) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	atomic.AddInt32(&bd.checks, 1)
+	bd.started <- struct{}{}
+	<-bd.release
+	return &v1.DispatchCheckResponse{
+		Membership: v1.DispatchCheckResponse_MEMBER,
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+	}, nil
+}
+
+func TestConcurrentChecksShared(t *testing.T) {
+	require := require.New(t)
+
+	delegate := &blockingDelegate{started: make(chan struct{}, 10), release: make(chan struct{})}
+	dispatch, err := NewCachingDispatcher(nil, "")
+	require.NoError(err)
+	dispatch.SetDelegate(delegate)
+	defer dispatch.Close()
+
+	req := &v1.DispatchCheckRequest{
+		ObjectAndRelation: tuple.ParseONR("document:doc1#read"),
+		Subject:           tuple.ParseSubjectONR("user:user1#..."),
+		Metadata: &v1.ResolverMeta{
+			AtRevision:     decimal.Zero.String(),
+			DepthRemaining: 50,
+		},
+	}
+
+	const concurrentChecks = 10
+	results := make(chan *v1.DispatchCheckResponse, concurrentChecks)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentChecks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := dispatch.DispatchCheck(context.Background(), req)
+			require.NoError(err)
+			results <- resp
+		}()
+	}
+
+	// Give the checks time to join the one in flight before it completes.
+	<-delegate.started
+	time.Sleep(50 * time.Millisecond)
+	close(delegate.release)
+	wg.Wait()
+	close(results)
+
+	require.Equal(int32(1), atomic.LoadInt32(&delegate.checks))
+	for resp := range results {
+		require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+	}
+}
+
 type delegateDispatchMock struct {
 	*mock.Mock
 }
@@ -138,7 +321,8 @@ func (ddm delegateDispatchMock) DispatchCheck(ctx context.Context, req *v1.Dispa
 }
 
 func (ddm delegateDispatchMock) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
-	return &v1.DispatchExpandResponse{}, nil
+	args := ddm.Called(req)
+	return args.Get(0).(*v1.DispatchExpandResponse), args.Error(1)
 }
 
 func (ddm delegateDispatchMock) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
@@ -3,31 +3,54 @@
 package combined
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/authzed/grpcutil"
+	"github.com/dgraph-io/ristretto"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/dispatch/caching"
 	"github.com/authzed/spicedb/internal/dispatch/graph"
 	"github.com/authzed/spicedb/internal/dispatch/remote"
+	localgraph "github.com/authzed/spicedb/internal/graph"
 	"github.com/authzed/spicedb/internal/namespace"
 	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
 	dispatchSvc "github.com/authzed/spicedb/internal/services/dispatch"
 )
 
+const staticPeersScheme = "spicedb-peers"
+
+const (
+	// clientCacheName is the name of the cache of the results of requests dispatched by the
+	// server, and clusterCacheName that of the requests dispatched to it by its peers.
+	clientCacheName  = "dispatch_client"
+	clusterCacheName = "dispatch"
+)
+
 // Option is a function-style option for configuring a combined Dispatcher.
 type Option func(*optionState)
 
 type optionState struct {
 	upstreamAddr     string
+	upstreamPeers    []string
 	upstreamCAPath   string
+	upstreamCreds    credentials.TransportCredentials
 	grpcPresharedKey string
 	grpcDialOpts     []grpc.DialOption
+	prefetchChecks   bool
+	cacheConfig      *ristretto.Config
+	cacheTTL         time.Duration
+	hedgingQuantile  float64
+	hedgingSlowValue time.Duration
 }
 
 // UpstreamAddr sets the optional cluster dispatching upstream address.
@@ -37,6 +60,15 @@ func UpstreamAddr(addr string) Option {
 	}
 }
 
+// UpstreamPeers sets a static list of the addresses of the peers to which cluster
+// dispatching is routed, as an alternative to an upstream address which is resolved
+// to the peers.
+func UpstreamPeers(addrs []string) Option {
+	return func(state *optionState) {
+		state.upstreamPeers = addrs
+	}
+}
+
 // UpstreamAddr sets the optional cluster dispatching upstream certificate
 // authority.
 func UpstreamCAPath(path string) Option {
@@ -45,6 +77,14 @@ func UpstreamCAPath(path string) Option {
 	}
 }
 
+// UpstreamCredentials sets the optional transport credentials of cluster dispatching, such
+// as those of mutual TLS, in place of an upstream certificate authority.
+func UpstreamCredentials(creds credentials.TransportCredentials) Option {
+	return func(state *optionState) {
+		state.upstreamCreds = creds
+	}
+}
+
 // GrpcPresharedKey sets the preshared key used to authenticate for optional
 // cluster dispatching.
 func GrpcPresharedKey(key string) Option {
@@ -61,32 +101,146 @@ func GrpcDialOpts(opts ...grpc.DialOption) Option {
 	}
 }
 
+// PrefetchChecks sets whether checks load the tuples of every relation of the checked
+// object which they may read in a single query.
+func PrefetchChecks(enabled bool) Option {
+	return func(state *optionState) {
+		state.prefetchChecks = enabled
+	}
+}
+
+// CacheConfig sets the configuration of the caches of dispatched check and lookup results.
+// Nil uses the default configuration of the caching dispatcher.
+func CacheConfig(config *ristretto.Config) Option {
+	return func(state *optionState) {
+		state.cacheConfig = config
+	}
+}
+
+// CacheTTL sets the amount of time after which cached dispatch results expire. Zero keeps
+// them until they are evicted.
+func CacheTTL(ttl time.Duration) Option {
+	return func(state *optionState) {
+		state.cacheTTL = ttl
+	}
+}
+
+// HedgingQuantile sets the quantile of the latencies of requests dispatched to peers after
+// which they are hedged with a duplicate sent to another peer. Zero disables hedging.
+func HedgingQuantile(quantile float64) Option {
+	return func(state *optionState) {
+		state.hedgingQuantile = quantile
+	}
+}
+
+// HedgingInitialSlowValue sets the latency after which requests dispatched to peers are
+// hedged before the latencies of requests have been observed.
+func HedgingInitialSlowValue(latency time.Duration) Option {
+	return func(state *optionState) {
+		state.hedgingSlowValue = latency
+	}
+}
+
+// Dispatcher is a dispatch.Dispatcher whose caches can be flushed.
+type Dispatcher interface {
+	dispatch.Dispatcher
+
+	// FlushCaches removes every cached result of the dispatcher, including those cached
+	// for the dispatch service it registered.
+	FlushCaches()
+
+	// Diagnostics returns a description of the current state of the dispatcher.
+	Diagnostics() Diagnostics
+}
+
+// Diagnostics describes the state of a Dispatcher, for debugging an unhealthy server.
+type Diagnostics struct {
+	// UpstreamState is the state of the connection to the peers of the dispatch cluster, or
+	// empty if requests are not dispatched to peers.
+	UpstreamState string
+
+	// CacheSizes are the estimated sizes in bytes of the results held by the caches of the
+	// dispatcher, by cache.
+	CacheSizes map[string]int64
+}
+
+type combinedDispatcher struct {
+	*caching.Dispatcher
+	clusterCache *caching.Dispatcher
+	upstream     *grpc.ClientConn
+}
+
+func (cd combinedDispatcher) FlushCaches() {
+	cd.Dispatcher.Flush()
+	cd.clusterCache.Flush()
+}
+
+func (cd combinedDispatcher) Diagnostics() Diagnostics {
+	diagnostics := Diagnostics{
+		CacheSizes: map[string]int64{
+			clientCacheName:  cd.Dispatcher.EstimatedSize(),
+			clusterCacheName: cd.clusterCache.EstimatedSize(),
+		},
+	}
+	if cd.upstream != nil {
+		diagnostics.UpstreamState = cd.upstream.GetState().String()
+	}
+	return diagnostics
+}
+
 // NewDispatcher initializes a Dispatcher that caches and redispatches
 // optionally to the provided upstream.
-func NewDispatcher(nsm namespace.Manager, ds datastore.Datastore, srv *grpc.Server, options ...Option) (dispatch.Dispatcher, error) {
+func NewDispatcher(nsm namespace.Manager, ds datastore.Datastore, srv *grpc.Server, options ...Option) (Dispatcher, error) {
 	var opts optionState
 	for _, fn := range options {
 		fn(&opts)
 	}
 	log.Debug().Interface("dispatchConfig", opts).Msg("configured combined dispatcher")
 
-	cachingRedispatch, err := caching.NewCachingDispatcher(nil, "dispatch_client")
+	cachingRedispatch, err := caching.NewCachingDispatcher(opts.cacheConfig, clientCacheName, caching.EntryTTL(opts.cacheTTL))
 	if err != nil {
 		return nil, err
 	}
 
-	redispatch := graph.NewDispatcher(cachingRedispatch, nsm, ds)
+	checkerOptions := []localgraph.CheckerOption{localgraph.PrefetchTuples(opts.prefetchChecks)}
+	redispatch := graph.NewDispatcher(cachingRedispatch, nsm, ds, checkerOptions...)
+
+	if len(opts.upstreamPeers) > 0 {
+		if opts.upstreamAddr != "" {
+			return nil, fmt.Errorf("an upstream address and upstream peers cannot both be specified")
+		}
+
+		// The peers are resolved statically, so that requests are routed among them by
+		// the balancer exactly as when they are discovered.
+		peers := manual.NewBuilderWithScheme(staticPeersScheme)
+		addresses := make([]resolver.Address, 0, len(opts.upstreamPeers))
+		for _, peer := range opts.upstreamPeers {
+			addresses = append(addresses, resolver.Address{Addr: peer})
+		}
+		peers.InitialState(resolver.State{Addresses: addresses})
+
+		opts.upstreamAddr = staticPeersScheme + ":///peers"
+		opts.grpcDialOpts = append(opts.grpcDialOpts, grpc.WithResolvers(peers))
+	}
 
 	// If an upstream is specified, create a cluster dispatcher.
+	var upstream *grpc.ClientConn
 	if opts.upstreamAddr != "" {
-		if opts.upstreamCAPath != "" {
+		switch {
+		case opts.upstreamCreds != nil:
+			if opts.upstreamCAPath != "" {
+				return nil, fmt.Errorf("an upstream certificate authority and upstream credentials cannot both be specified")
+			}
+			opts.grpcDialOpts = append(opts.grpcDialOpts, grpc.WithTransportCredentials(opts.upstreamCreds))
+			opts.grpcDialOpts = append(opts.grpcDialOpts, grpcutil.WithBearerToken(opts.grpcPresharedKey))
+		case opts.upstreamCAPath != "":
 			// Ensure that the CA path exists.
 			if _, err := os.Stat(opts.upstreamCAPath); err != nil {
 				return nil, err
 			}
 			opts.grpcDialOpts = append(opts.grpcDialOpts, grpcutil.WithCustomCerts(opts.upstreamCAPath, grpcutil.VerifyCA))
 			opts.grpcDialOpts = append(opts.grpcDialOpts, grpcutil.WithBearerToken(opts.grpcPresharedKey))
-		} else {
+		default:
 			opts.grpcDialOpts = append(opts.grpcDialOpts, grpcutil.WithInsecureBearerToken(opts.grpcPresharedKey))
 			opts.grpcDialOpts = append(opts.grpcDialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 		}
@@ -95,13 +249,20 @@ func NewDispatcher(nsm namespace.Manager, ds datastore.Datastore, srv *grpc.Serv
 		if err != nil {
 			return nil, err
 		}
-		redispatch = remote.NewClusterDispatcher(v1.NewDispatchServiceClient(conn))
+		upstream = conn
+		// Should no peer be available, requests are evaluated locally.
+		redispatch = remote.NewClusterDispatcher(
+			v1.NewDispatchServiceClient(conn),
+			remote.LocalFallback(graph.NewDispatcher(cachingRedispatch, nsm, ds, checkerOptions...)),
+			remote.HedgingQuantile(opts.hedgingQuantile),
+			remote.HedgingInitialSlowValue(opts.hedgingSlowValue),
+		)
 	}
 
 	cachingRedispatch.SetDelegate(redispatch)
 
-	clusterDispatch := graph.NewDispatcher(cachingRedispatch, nsm, ds)
-	cachingClusterDispatch, err := caching.NewCachingDispatcher(nil, "dispatch")
+	clusterDispatch := graph.NewDispatcher(cachingRedispatch, nsm, ds, checkerOptions...)
+	cachingClusterDispatch, err := caching.NewCachingDispatcher(opts.cacheConfig, clusterCacheName, caching.EntryTTL(opts.cacheTTL))
 	if err != nil {
 		return nil, err
 	}
@@ -109,5 +270,5 @@ func NewDispatcher(nsm namespace.Manager, ds datastore.Datastore, srv *grpc.Serv
 
 	dispatchSvc.RegisterGrpcServices(srv, cachingClusterDispatch)
 
-	return cachingRedispatch, nil
+	return combinedDispatcher{cachingRedispatch, cachingClusterDispatch, upstream}, nil
 }
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 
 	"github.com/authzed/spicedb/pkg/tuple"
 
@@ -16,6 +19,61 @@ import (
 // ErrMaxDepth is returned from CheckDepth when the max depth is exceeded.
 var ErrMaxDepth = errors.New("max depth exceeded")
 
+// MaxDepthExceededError is returned when a request exceeded the max depth, with the chain of
+// objects and relations through which it was dispatched until it did, starting with that
+// of the request. The chain only covers the requests evaluated by this node.
+type MaxDepthExceededError struct {
+	Chain []*v0.ObjectAndRelation
+}
+
+// NewMaxDepthExceededError returns a MaxDepthExceededError for a request for the object and
+// relation whose depth was exceeded.
+func NewMaxDepthExceededError(onr *v0.ObjectAndRelation) MaxDepthExceededError {
+	return MaxDepthExceededError{Chain: []*v0.ObjectAndRelation{onr}}
+}
+
+// WithParent returns the error with the object and relation of the request which
+// dispatched the one which exceeded the max depth prepended to its chain. The error may be
+// shared between requests, so it is not modified.
+func (err MaxDepthExceededError) WithParent(onr *v0.ObjectAndRelation) MaxDepthExceededError {
+	chain := make([]*v0.ObjectAndRelation, 0, len(err.Chain)+1)
+	chain = append(chain, onr)
+	return MaxDepthExceededError{Chain: append(chain, err.Chain...)}
+}
+
+// ChainString returns the chain as the objects and relations joined by arrows.
+func (err MaxDepthExceededError) ChainString() string {
+	onrs := make([]string, 0, len(err.Chain))
+	for _, onr := range err.Chain {
+		onrs = append(onrs, tuple.StringONR(onr))
+	}
+	return strings.Join(onrs, " -> ")
+}
+
+func (err MaxDepthExceededError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrMaxDepth, err.ChainString())
+}
+
+// Is returns whether the target is ErrMaxDepth, which this error refines.
+func (err MaxDepthExceededError) Is(target error) bool {
+	return target == ErrMaxDepth
+}
+
+// WithMaxDepthParent prepends the object and relation to the chain of the error if the
+// error is that the max depth was exceeded, and otherwise returns it unchanged. A chain is
+// started if the error has none, as when it was exceeded by a peer.
+func WithMaxDepthParent(err error, onr *v0.ObjectAndRelation) error {
+	var maxDepthErr MaxDepthExceededError
+	switch {
+	case errors.As(err, &maxDepthErr):
+		return maxDepthErr.WithParent(onr)
+	case errors.Is(err, ErrMaxDepth):
+		return NewMaxDepthExceededError(onr)
+	default:
+		return err
+	}
+}
+
 // Dispatcher interface describes a method for passing subchecks off to additional machines.
 type Dispatcher interface {
 	Check
@@ -71,12 +129,13 @@ func CheckRequestToKey(req *v1.DispatchCheckRequest) string {
 	return fmt.Sprintf("check//%s@%s@%s", tuple.StringONR(req.ObjectAndRelation), tuple.StringONR(req.Subject), req.Metadata.AtRevision)
 }
 
-// LookupRequestToKey converts a lookup request into a cache key
+// LookupRequestToKey converts a lookup request into a cache key. Limited lookups resolve the
+// objects with the lowest IDs after the ID at which they resume, so both are part of the key.
 func LookupRequestToKey(req *v1.DispatchLookupRequest) string {
-	return fmt.Sprintf("lookup//%s#%s@%s@%s", req.ObjectRelation.Namespace, req.ObjectRelation.Relation, tuple.StringONR(req.Subject), req.Metadata.AtRevision)
+	return fmt.Sprintf("lookup//%s#%s@%s@%s/%d>%s", req.ObjectRelation.Namespace, req.ObjectRelation.Relation, tuple.StringONR(req.Subject), req.Metadata.AtRevision, req.Limit, req.AfterResourceId)
 }
 
 // ExpandRequestToKey converts an expand request into a cache key
 func ExpandRequestToKey(req *v1.DispatchExpandRequest) string {
-	return fmt.Sprintf("expand//%s@%s", tuple.StringONR(req.ObjectAndRelation), req.Metadata.AtRevision)
+	return fmt.Sprintf("expand//%s@%s@%s", tuple.StringONR(req.ObjectAndRelation), req.ExpansionMode, req.Metadata.AtRevision)
 }
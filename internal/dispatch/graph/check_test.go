@@ -182,9 +182,9 @@ func TestMaxDepth(t *testing.T) {
 	nsm, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, testCacheConfig)
 	require.NoError(err)
 
-	dispatch := NewLocalOnlyDispatcher(nsm, ds)
+	dispatcher := NewLocalOnlyDispatcher(nsm, ds)
 
-	checkResult, err := dispatch.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{
+	checkResult, err := dispatcher.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{
 		ObjectAndRelation: ONR("folder", "oops", "owner"),
 		Subject:           ONR("user", "fake", graph.Ellipsis),
 		Metadata: &v1.ResolverMeta{
@@ -194,7 +194,15 @@ func TestMaxDepth(t *testing.T) {
 	})
 
 	require.Error(err)
+	require.ErrorIs(err, dispatch.ErrMaxDepth)
 	require.Equal(v1.DispatchCheckResponse_UNKNOWN, checkResult.Membership)
+
+	// The error identifies the cycle through which the check was dispatched.
+	var maxDepthErr dispatch.MaxDepthExceededError
+	require.ErrorAs(err, &maxDepthErr)
+	require.Len(maxDepthErr.Chain, 51)
+	require.Equal("folder:oops#owner", tuple.StringONR(maxDepthErr.Chain[0]))
+	require.Equal("folder:oops#editor", tuple.StringONR(maxDepthErr.Chain[1]))
 }
 
 func TestCheckMetadata(t *testing.T) {
@@ -291,7 +299,66 @@ func TestCheckMetadata(t *testing.T) {
 	}
 }
 
+func TestPlanPrefetch(t *testing.T) {
+	testCases := []struct {
+		relation string
+		expected []string
+	}{
+		{"owner", []string{"owner"}},
+		{"editor", []string{"editor", "owner"}},
+		{"viewer", []string{"editor", "owner", "parent", "viewer"}},
+		{"viewer_and_editor_derived", []string{"editor", "owner", "viewer_and_editor", "viewer_and_editor_derived"}},
+		{"unknown", []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.relation, func(t *testing.T) {
+			require.Equal(t, tc.expected, graph.PlanPrefetch(testfixtures.DocumentNS, tc.relation))
+		})
+	}
+}
+
+func TestCheckWithPrefetch(t *testing.T) {
+	subjects := []string{"product_manager", "chief_financial_officer", "owner", "legal", "vp_product", "eng_lead", "auditor", "villain"}
+	objects := []*v0.ObjectAndRelation{
+		ONR("document", "masterplan", "viewer"),
+		ONR("document", "masterplan", "viewer_and_editor_derived"),
+		ONR("document", "healthplan", "viewer"),
+		ONR("folder", "company", "viewer"),
+		ONR("folder", "strategy", "editor"),
+	}
+
+	for _, object := range objects {
+		for _, subject := range subjects {
+			object, subject := object, ONR("user", subject, graph.Ellipsis)
+			t.Run(fmt.Sprintf("%s@%s", tuple.StringONR(object), tuple.StringONR(subject)), func(t *testing.T) {
+				require := require.New(t)
+
+				check := func(d dispatch.Dispatcher, revision decimal.Decimal) v1.DispatchCheckResponse_Membership {
+					checkResult, err := d.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{
+						ObjectAndRelation: object,
+						Subject:           subject,
+						Metadata: &v1.ResolverMeta{
+							AtRevision:     revision.String(),
+							DepthRemaining: 50,
+						},
+					})
+					require.NoError(err)
+					return checkResult.Membership
+				}
+
+				expected := check(newLocalDispatcher(require))
+				require.Equal(expected, check(newLocalDispatcherWithOptions(require, graph.PrefetchTuples(true))))
+			})
+		}
+	}
+}
+
 func newLocalDispatcher(require *require.Assertions) (dispatch.Dispatcher, decimal.Decimal) {
+	return newLocalDispatcherWithOptions(require)
+}
+
+func newLocalDispatcherWithOptions(require *require.Assertions, checkerOptions ...graph.CheckerOption) (dispatch.Dispatcher, decimal.Decimal) {
 	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
 	require.NoError(err)
 
@@ -300,7 +367,7 @@ func newLocalDispatcher(require *require.Assertions) (dispatch.Dispatcher, decim
 	nsm, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, testCacheConfig)
 	require.NoError(err)
 
-	dispatch := NewLocalOnlyDispatcher(nsm, ds)
+	dispatch := NewLocalOnlyDispatcher(nsm, ds, checkerOptions...)
 
 	cachingDispatcher, err := caching.NewCachingDispatcher(nil, "")
 	cachingDispatcher.SetDelegate(dispatch)
@@ -16,7 +16,7 @@ import (
 	"github.com/authzed/spicedb/internal/graph"
 	"github.com/authzed/spicedb/internal/namespace"
 	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
-	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/internal/redact"
 )
 
 const errDispatch = "error dispatching request: %w"
@@ -27,10 +27,11 @@ var tracer = otel.Tracer("spicedb/internal/dispatch/local")
 func NewLocalOnlyDispatcher(
 	nsm namespace.Manager,
 	ds datastore.Datastore,
+	checkerOptions ...graph.CheckerOption,
 ) dispatch.Dispatcher {
 	d := &localDispatcher{nsm: nsm}
 
-	d.checker = graph.NewConcurrentChecker(d, ds, nsm)
+	d.checker = graph.NewConcurrentChecker(d, ds, nsm, checkerOptions...)
 	d.expander = graph.NewConcurrentExpander(d, ds, nsm)
 	d.lookupHandler = graph.NewConcurrentLookup(d, ds, nsm)
 
@@ -43,8 +44,9 @@ func NewDispatcher(
 	redispatcher dispatch.Dispatcher,
 	nsm namespace.Manager,
 	ds datastore.Datastore,
+	checkerOptions ...graph.CheckerOption,
 ) dispatch.Dispatcher {
-	checker := graph.NewConcurrentChecker(redispatcher, ds, nsm)
+	checker := graph.NewConcurrentChecker(redispatcher, ds, nsm, checkerOptions...)
 	expander := graph.NewConcurrentExpander(redispatcher, ds, nsm)
 	lookupHandler := graph.NewConcurrentLookup(redispatcher, ds, nsm)
 
@@ -86,7 +88,7 @@ type stringableOnr struct {
 }
 
 func (onr stringableOnr) String() string {
-	return tuple.StringONR(onr.ObjectAndRelation)
+	return redact.ONR(onr.ObjectAndRelation)
 }
 
 type stringableRelRef struct {
@@ -107,7 +109,7 @@ func (ld *localDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCh
 
 	err := dispatch.CheckDepth(ctx, req)
 	if err != nil {
-		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
+		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, dispatch.WithMaxDepthParent(err, req.ObjectAndRelation)
 	}
 
 	revision, err := decimal.NewFromString(req.Metadata.AtRevision)
@@ -125,7 +127,8 @@ func (ld *localDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCh
 		Revision:             revision,
 	}
 
-	return ld.checker.Check(ctx, validatedReq, relation)
+	resp, err := ld.checker.Check(ctx, validatedReq, relation)
+	return resp, dispatch.WithMaxDepthParent(err, req.ObjectAndRelation)
 }
 
 // DispatchExpand implements dispatch.Expand interface
@@ -137,7 +140,7 @@ func (ld *localDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchE
 
 	err := dispatch.CheckDepth(ctx, req)
 	if err != nil {
-		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
+		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, dispatch.WithMaxDepthParent(err, req.ObjectAndRelation)
 	}
 
 	revision, err := decimal.NewFromString(req.Metadata.AtRevision)
@@ -155,7 +158,8 @@ func (ld *localDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchE
 		Revision:              revision,
 	}
 
-	return ld.expander.Expand(ctx, validatedReq, relation)
+	resp, err := ld.expander.Expand(ctx, validatedReq, relation)
+	return resp, dispatch.WithMaxDepthParent(err, req.ObjectAndRelation)
 }
 
 // DispatchLookup implements dispatch.Lookup interface
@@ -157,6 +157,91 @@ func TestSimpleLookup(t *testing.T) {
 	}
 }
 
+func TestLookupBounds(t *testing.T) {
+	testCases := []struct {
+		start           *v0.RelationReference
+		target          *v0.ObjectAndRelation
+		limit           uint32
+		afterResourceID string
+		resolvedObjects []*v0.ObjectAndRelation
+	}{
+		{
+			RR("document", "viewer"),
+			ONR("user", "legal", "..."),
+			1,
+			"",
+			[]*v0.ObjectAndRelation{
+				ONR("document", "companyplan", "viewer"),
+			},
+		},
+		{
+			RR("document", "viewer"),
+			ONR("user", "legal", "..."),
+			1,
+			"companyplan",
+			[]*v0.ObjectAndRelation{
+				ONR("document", "masterplan", "viewer"),
+			},
+		},
+		{
+			RR("document", "viewer"),
+			ONR("user", "legal", "..."),
+			10,
+			"masterplan",
+			[]*v0.ObjectAndRelation{},
+		},
+		{
+			RR("folder", "viewer"),
+			ONR("user", "owner", "..."),
+			1,
+			"",
+			[]*v0.ObjectAndRelation{
+				ONR("folder", "company", "viewer"),
+			},
+		},
+		{
+			RR("folder", "viewer"),
+			ONR("user", "owner", "..."),
+			10,
+			"company",
+			[]*v0.ObjectAndRelation{
+				ONR("folder", "strategy", "viewer"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		name := fmt.Sprintf(
+			"%s#%s->%s limit %d after %q",
+			tc.start.Namespace,
+			tc.start.Relation,
+			tuple.StringONR(tc.target),
+			tc.limit,
+			tc.afterResourceID,
+		)
+
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+
+			dispatch, revision := newLocalDispatcher(require)
+
+			lookupResult, err := dispatch.DispatchLookup(context.Background(), &v1.DispatchLookupRequest{
+				ObjectRelation: tc.start,
+				Subject:        tc.target,
+				Metadata: &v1.ResolverMeta{
+					AtRevision:     revision.String(),
+					DepthRemaining: 50,
+				},
+				Limit:           tc.limit,
+				AfterResourceId: tc.afterResourceID,
+			})
+
+			require.NoError(err)
+			require.ElementsMatch(tc.resolvedObjects, lookupResult.ResolvedOnrs)
+		})
+	}
+}
+
 func TestMaxDepthLookup(t *testing.T) {
 	require := require.New(t)
 
@@ -0,0 +1,281 @@
+// Package mtls implements mutual TLS between the nodes of a dispatch cluster, verifying
+// the SPIFFE IDs of peers, with certificates which are reloaded as they are rotated.
+package mtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/credentials"
+)
+
+const spiffeScheme = "spiffe"
+
+// Config configures the certificates with which nodes authenticate each other.
+type Config struct {
+	// CertPath and KeyPath are the paths of the certificate and key of the node, which it
+	// presents both when serving and when dispatching to peers.
+	CertPath string
+	KeyPath  string
+
+	// CAPath is the path of the bundle of certificate authorities which issue the
+	// certificates of peers.
+	CAPath string
+
+	// AllowedPeerIDs are the SPIFFE IDs of the peers which are allowed. An ID ending in
+	// `/*` allows every ID under it, and an ID of a trust domain alone, such as
+	// `spiffe://example.org`, allows every ID of the trust domain. If empty, any peer with
+	// a certificate issued by the certificate authorities is allowed.
+	AllowedPeerIDs []string
+}
+
+type loadedFiles struct {
+	cert, key, ca []byte
+}
+
+// Credentials are the transport credentials of a node of the dispatch cluster.
+type Credentials struct {
+	config Config
+
+	mu    sync.RWMutex
+	files loadedFiles
+	cert  *tls.Certificate
+	roots *x509.CertPool
+}
+
+// NewCredentials loads the certificates of the configuration.
+func NewCredentials(config Config) (*Credentials, error) {
+	if config.CertPath == "" || config.KeyPath == "" || config.CAPath == "" {
+		return nil, errors.New("a certificate, key and certificate authority must all be given for dispatch mTLS")
+	}
+
+	for _, allowed := range config.AllowedPeerIDs {
+		if _, err := parseSPIFFEID(strings.TrimSuffix(allowed, "/*")); err != nil {
+			return nil, fmt.Errorf("invalid allowed peer ID `%s`: %w", allowed, err)
+		}
+	}
+
+	creds := &Credentials{config: config}
+	if _, err := creds.Reload(); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// Reload loads the certificates of the configuration if any of their files changed,
+// returning whether they did. If the files are invalid, the previous certificates remain
+// in use.
+func (c *Credentials) Reload() (bool, error) {
+	var files loadedFiles
+	var err error
+	if files.cert, err = ioutil.ReadFile(c.config.CertPath); err != nil {
+		return false, fmt.Errorf("unable to read dispatch certificate: %w", err)
+	}
+	if files.key, err = ioutil.ReadFile(c.config.KeyPath); err != nil {
+		return false, fmt.Errorf("unable to read dispatch key: %w", err)
+	}
+	if files.ca, err = ioutil.ReadFile(c.config.CAPath); err != nil {
+		return false, fmt.Errorf("unable to read dispatch certificate authority: %w", err)
+	}
+
+	c.mu.RLock()
+	unchanged := bytes.Equal(files.cert, c.files.cert) && bytes.Equal(files.key, c.files.key) && bytes.Equal(files.ca, c.files.ca)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(files.cert, files.key)
+	if err != nil {
+		return false, fmt.Errorf("invalid dispatch certificate: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(files.ca) {
+		return false, errors.New("invalid dispatch certificate authority: no certificates found")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = files
+	c.cert = &cert
+	c.roots = roots
+	return true, nil
+}
+
+// Watch reloads the certificates every interval, and whenever the process receives a
+// SIGHUP, until the context is canceled.
+func (c *Credentials) Watch(ctx context.Context, interval time.Duration) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigs:
+			log.Info().Msg("received SIGHUP, reloading dispatch certificates")
+		case <-tick:
+		}
+
+		reloaded, err := c.Reload()
+		if err != nil {
+			log.Warn().Err(err).Msg("unable to reload dispatch certificates; keeping the previous certificates")
+			continue
+		}
+		if reloaded {
+			log.Info().Msg("reloaded dispatch certificates")
+		}
+	}
+}
+
+func (c *Credentials) certificate() *tls.Certificate {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert
+}
+
+// ServerCredentials returns the credentials with which the dispatch server authenticates
+// itself to peers and requires peers to authenticate.
+func (c *Credentials) ServerCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAnyClientCert,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.certificate(), nil
+		},
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return c.verifyPeer(rawCerts, x509.ExtKeyUsageClientAuth)
+		},
+	})
+}
+
+// ClientCredentials returns the credentials with which nodes authenticate themselves when
+// dispatching to peers and require peers to authenticate.
+func (c *Credentials) ClientCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.certificate(), nil
+		},
+
+		// Peers are identified by their SPIFFE IDs rather than by host names, so the
+		// verification of host names is replaced by that of the peer.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return c.verifyPeer(rawCerts, x509.ExtKeyUsageServerAuth)
+		},
+	})
+}
+
+// verifyPeer verifies that the certificate chain presented by a peer was issued by the
+// certificate authorities, and that the peer has an allowed SPIFFE ID.
+func (c *Credentials) verifyPeer(rawCerts [][]byte, usage x509.ExtKeyUsage) error {
+	if len(rawCerts) == 0 {
+		return errors.New("peer presented no certificate")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("invalid peer certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	c.mu.RLock()
+	roots := c.roots
+	c.mu.RUnlock()
+
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}); err != nil {
+		return fmt.Errorf("untrusted peer certificate: %w", err)
+	}
+
+	id, err := PeerID(certs[0])
+	if err != nil {
+		return err
+	}
+	if !c.allowed(id) {
+		return fmt.Errorf("peer `%s` is not allowed", id)
+	}
+	return nil
+}
+
+func (c *Credentials) allowed(id *url.URL) bool {
+	if len(c.config.AllowedPeerIDs) == 0 {
+		return true
+	}
+
+	peer := id.String()
+	for _, allowed := range c.config.AllowedPeerIDs {
+		switch {
+		case strings.HasSuffix(allowed, "/*"):
+			if strings.HasPrefix(peer, strings.TrimSuffix(allowed, "*")) {
+				return true
+			}
+		case allowed == spiffeScheme+"://"+id.Host:
+			return true
+		case allowed == peer:
+			return true
+		}
+	}
+	return false
+}
+
+// PeerID returns the SPIFFE ID of the certificate, which must be its only URI SAN.
+func PeerID(cert *x509.Certificate) (*url.URL, error) {
+	if len(cert.URIs) != 1 {
+		return nil, fmt.Errorf("peer certificate must have exactly one URI SAN, found %d", len(cert.URIs))
+	}
+
+	id, err := parseSPIFFEID(cert.URIs[0].String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer SPIFFE ID: %w", err)
+	}
+	return id, nil
+}
+
+func parseSPIFFEID(id string) (*url.URL, error) {
+	parsed, err := url.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != spiffeScheme || parsed.Host == "" {
+		return nil, fmt.Errorf("`%s` is not a SPIFFE ID", id)
+	}
+	if parsed.User != nil || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.Port() != "" {
+		return nil, fmt.Errorf("`%s` is not a SPIFFE ID", id)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,214 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for the SPIFFE ID and its key to the directory, returning
+// their paths.
+func (ca *testCA) issue(t *testing.T, dir, name, spiffeID string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	id, err := url.Parse(spiffeID)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{id},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certPath, keyPath
+}
+
+func (ca *testCA) write(t *testing.T, dir, name string) string {
+	path := filepath.Join(dir, name+".pem")
+	require.NoError(t, ioutil.WriteFile(path, ca.pem, 0o600))
+	return path
+}
+
+func serve(t *testing.T, creds *Credentials) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(grpc.Creds(creds.ServerCredentials()))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func check(addr string, creds *Credentials) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(creds.ClientCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath := ca.write(t, dir, "ca")
+
+	serverCert, serverKey := ca.issue(t, dir, "server", "spiffe://example.org/ns/spicedb/node-a")
+	server, err := NewCredentials(Config{
+		CertPath:       serverCert,
+		KeyPath:        serverKey,
+		CAPath:         caPath,
+		AllowedPeerIDs: []string{"spiffe://example.org/ns/spicedb/*"},
+	})
+	require.NoError(t, err)
+	addr := serve(t, server)
+
+	otherCA := newTestCA(t)
+	otherCAPath := otherCA.write(t, dir, "otherca")
+
+	testCases := []struct {
+		name           string
+		issuer         *testCA
+		spiffeID       string
+		caPath         string
+		allowedPeerIDs []string
+		expectErr      bool
+	}{
+		{"allowed peer", ca, "spiffe://example.org/ns/spicedb/node-b", caPath, nil, false},
+		{"client allowing the server by ID", ca, "spiffe://example.org/ns/spicedb/node-b", caPath, []string{"spiffe://example.org/ns/spicedb/node-a"}, false},
+		{"client allowing the trust domain", ca, "spiffe://example.org/ns/spicedb/node-b", caPath, []string{"spiffe://example.org"}, false},
+		{"peer not allowed by the server", ca, "spiffe://example.org/ns/other/node-b", caPath, nil, true},
+		{"server not allowed by the client", ca, "spiffe://example.org/ns/spicedb/node-b", caPath, []string{"spiffe://other.org"}, true},
+		{"peer of another CA", otherCA, "spiffe://example.org/ns/spicedb/node-b", otherCAPath, nil, true},
+		{"client not trusting the server CA", ca, "spiffe://example.org/ns/spicedb/node-b", otherCAPath, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			certPath, keyPath := tc.issuer.issue(t, t.TempDir(), "client", tc.spiffeID)
+			client, err := NewCredentials(Config{
+				CertPath:       certPath,
+				KeyPath:        keyPath,
+				CAPath:         tc.caPath,
+				AllowedPeerIDs: tc.allowedPeerIDs,
+			})
+			require.NoError(t, err)
+
+			err = check(addr, client)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath := ca.write(t, dir, "ca")
+
+	serverCert, serverKey := ca.issue(t, dir, "server", "spiffe://example.org/node-a")
+	server, err := NewCredentials(Config{CertPath: serverCert, KeyPath: serverKey, CAPath: caPath})
+	require.NoError(err)
+	addr := serve(t, server)
+
+	reloaded, err := server.Reload()
+	require.NoError(err)
+	require.False(reloaded)
+
+	// Rotate the CA: clients issued by the new CA are only trusted once it is reloaded.
+	newCA := newTestCA(t)
+	clientCert, clientKey := newCA.issue(t, dir, "client", "spiffe://example.org/node-b")
+	clientCAPath := ca.write(t, dir, "clientca")
+	client, err := NewCredentials(Config{CertPath: clientCert, KeyPath: clientKey, CAPath: clientCAPath})
+	require.NoError(err)
+	require.Error(check(addr, client))
+
+	require.NoError(ioutil.WriteFile(caPath, append(append([]byte{}, ca.pem...), newCA.pem...), 0o600))
+	reloaded, err = server.Reload()
+	require.NoError(err)
+	require.True(reloaded)
+	require.NoError(check(addr, client))
+
+	// Invalid files leave the previous certificates in use.
+	require.NoError(ioutil.WriteFile(serverCert, []byte("not a certificate"), 0o600))
+	_, err = server.Reload()
+	require.Error(err)
+	require.NoError(check(addr, client))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	_, err := NewCredentials(Config{CertPath: "cert", KeyPath: "key"})
+	require.Error(t, err)
+
+	_, err = NewCredentials(Config{CertPath: "cert", KeyPath: "key", CAPath: "ca", AllowedPeerIDs: []string{"https://example.org"}})
+	require.Error(t, err)
+}
@@ -2,12 +2,23 @@ package remote
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
+	"github.com/authzed/spicedb/internal/auth"
+	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/dispatch"
 	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/balancer"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 type clusterClient interface {
@@ -16,14 +27,89 @@ type clusterClient interface {
 	DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest, opts ...grpc.CallOption) (*v1.DispatchLookupResponse, error)
 }
 
+// Option is a function-style option for configuring a cluster dispatcher.
+type Option func(*clusterDispatcher)
+
+// LocalFallback sets the dispatcher to which requests are dispatched when no peer is
+// available to evaluate them, which is usually one evaluating them locally.
+func LocalFallback(fallback dispatch.Dispatcher) Option {
+	return func(cr *clusterDispatcher) {
+		cr.fallback = fallback
+	}
+}
+
+// HedgingQuantile enables hedging requests dispatched to peers: a request which is not
+// answered within the quantile, e.g. 0.95, of the recent latencies of requests of its kind
+// is duplicated to another peer, and the first answer is used. Zero disables hedging.
+func HedgingQuantile(quantile float64) Option {
+	return func(cr *clusterDispatcher) {
+		cr.hedgingQuantile = quantile
+	}
+}
+
+// HedgingInitialSlowValue sets the latency after which requests are hedged before the
+// latencies of requests of their kind have been observed.
+func HedgingInitialSlowValue(latency time.Duration) Option {
+	return func(cr *clusterDispatcher) {
+		cr.hedgingInitialSlowValue = latency
+	}
+}
+
 // NewClusterDispatcher creates a dispatcher implementation that uses the provided client
 // to dispatch requests to peer nodes in the cluster.
-func NewClusterDispatcher(client clusterClient) dispatch.Dispatcher {
-	return &clusterDispatcher{client}
+func NewClusterDispatcher(client clusterClient, options ...Option) dispatch.Dispatcher {
+	cr := &clusterDispatcher{clusterClient: client}
+	for _, option := range options {
+		option(cr)
+	}
+
+	if cr.hedgingQuantile > 0 {
+		cr.latencies = map[string]*latencyTracker{
+			"check":  newLatencyTracker(cr.hedgingQuantile, cr.hedgingInitialSlowValue),
+			"expand": newLatencyTracker(cr.hedgingQuantile, cr.hedgingInitialSlowValue),
+			"lookup": newLatencyTracker(cr.hedgingQuantile, cr.hedgingInitialSlowValue),
+		}
+	}
+	return cr
 }
 
 type clusterDispatcher struct {
 	clusterClient clusterClient
+	fallback      dispatch.Dispatcher
+
+	hedgingQuantile         float64
+	hedgingInitialSlowValue time.Duration
+	latencies               map[string]*latencyTracker
+}
+
+var fallbackCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "remote_fallbacks_total",
+	Help:      "total number of dispatched requests evaluated locally because no peer was available",
+}, []string{"method"})
+
+// shouldFallback returns whether a request which failed with the error should be evaluated
+// locally instead: when the peer could not be reached or was overloaded, but not when the
+// request itself failed or was canceled by the caller.
+func (cr *clusterDispatcher) shouldFallback(ctx context.Context, err error) bool {
+	if cr.fallback == nil || ctx.Err() != nil {
+		return false
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkRoutingKey is the key on which checks are routed to peers. Unlike the cache key,
+// it leaves out the subject, so that every check of an object and relation at a revision
+// lands on the same peer, whose caches then hold the subproblems they share.
+func checkRoutingKey(req *v1.DispatchCheckRequest) string {
+	return fmt.Sprintf("check//%s@%s", tuple.StringONR(req.ObjectAndRelation), req.Metadata.AtRevision)
 }
 
 func (cr *clusterDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
@@ -31,13 +117,20 @@ func (cr *clusterDispatcher) DispatchCheck(ctx context.Context, req *v1.Dispatch
 	if err != nil {
 		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
 	}
-	ctx = context.WithValue(ctx, balancer.CtxKey, []byte(dispatch.CheckRequestToKey(req)))
-	resp, err := cr.clusterClient.DispatchCheck(ctx, req)
+	ctx = context.WithValue(ctx, balancer.CtxKey, []byte(checkRoutingKey(req)))
+	hedgedResp, err := cr.hedged(withTenant(ctx), "check", func(ctx context.Context) (interface{}, error) {
+		return cr.clusterClient.DispatchCheck(ctx, req)
+	})
 	if err != nil {
+		if cr.shouldFallback(ctx, err) {
+			log.Ctx(ctx).Debug().Err(err).Msg("no peer available for dispatched check, evaluating locally")
+			fallbackCounter.WithLabelValues("check").Inc()
+			return cr.fallback.DispatchCheck(ctx, req)
+		}
 		return &v1.DispatchCheckResponse{Metadata: requestFailureMetadata}, err
 	}
 
-	return resp, nil
+	return hedgedResp.(*v1.DispatchCheckResponse), nil
 }
 
 func (cr *clusterDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
@@ -46,12 +139,19 @@ func (cr *clusterDispatcher) DispatchExpand(ctx context.Context, req *v1.Dispatc
 		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
 	}
 	ctx = context.WithValue(ctx, balancer.CtxKey, []byte(dispatch.ExpandRequestToKey(req)))
-	resp, err := cr.clusterClient.DispatchExpand(ctx, req)
+	hedgedResp, err := cr.hedged(withTenant(ctx), "expand", func(ctx context.Context) (interface{}, error) {
+		return cr.clusterClient.DispatchExpand(ctx, req)
+	})
 	if err != nil {
+		if cr.shouldFallback(ctx, err) {
+			log.Ctx(ctx).Debug().Err(err).Msg("no peer available for dispatched expand, evaluating locally")
+			fallbackCounter.WithLabelValues("expand").Inc()
+			return cr.fallback.DispatchExpand(ctx, req)
+		}
 		return &v1.DispatchExpandResponse{Metadata: requestFailureMetadata}, err
 	}
 
-	return resp, nil
+	return hedgedResp.(*v1.DispatchExpandResponse), nil
 }
 
 func (cr *clusterDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
@@ -60,12 +160,27 @@ func (cr *clusterDispatcher) DispatchLookup(ctx context.Context, req *v1.Dispatc
 		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
 	}
 	ctx = context.WithValue(ctx, balancer.CtxKey, []byte(dispatch.LookupRequestToKey(req)))
-	resp, err := cr.clusterClient.DispatchLookup(ctx, req)
+	hedgedResp, err := cr.hedged(withTenant(ctx), "lookup", func(ctx context.Context) (interface{}, error) {
+		return cr.clusterClient.DispatchLookup(ctx, req)
+	})
 	if err != nil {
+		if cr.shouldFallback(ctx, err) {
+			log.Ctx(ctx).Debug().Err(err).Msg("no peer available for dispatched lookup, evaluating locally")
+			fallbackCounter.WithLabelValues("lookup").Inc()
+			return cr.fallback.DispatchLookup(ctx, req)
+		}
 		return &v1.DispatchLookupResponse{Metadata: requestFailureMetadata}, err
 	}
 
-	return resp, nil
+	return hedgedResp.(*v1.DispatchLookupResponse), nil
+}
+
+// withTenant propagates the tenant of a request to the peer to which it is dispatched.
+func withTenant(ctx context.Context) context.Context {
+	if tenant := datastore.TenantFromContext(ctx); tenant != datastore.DefaultTenant {
+		return metadata.AppendToOutgoingContext(ctx, auth.TenantMetadataKey, tenant)
+	}
+	return ctx
 }
 
 func (cr *clusterDispatcher) Close() error {
@@ -0,0 +1,82 @@
+package remote
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/balancer"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+type fakeClusterClient struct {
+	err        error
+	routingKey string
+}
+
+func (fc *fakeClusterClient) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest, opts ...grpc.CallOption) (*v1.DispatchCheckResponse, error) {
+	fc.routingKey = string(ctx.Value(balancer.CtxKey).([]byte))
+	if fc.err != nil {
+		return nil, fc.err
+	}
+	return &v1.DispatchCheckResponse{Membership: v1.DispatchCheckResponse_MEMBER}, nil
+}
+
+func (fc *fakeClusterClient) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest, opts ...grpc.CallOption) (*v1.DispatchExpandResponse, error) {
+	return nil, fc.err
+}
+
+func (fc *fakeClusterClient) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest, opts ...grpc.CallOption) (*v1.DispatchLookupResponse, error) {
+	return nil, fc.err
+}
+
+type fakeLocalDispatcher struct {
+	dispatch.Dispatcher
+	checks int
+}
+
+func (fd *fakeLocalDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	fd.checks++
+	return &v1.DispatchCheckResponse{Membership: v1.DispatchCheckResponse_NOT_MEMBER}, nil
+}
+
+func TestClusterDispatcherFallback(t *testing.T) {
+	testCases := []struct {
+		name               string
+		peerErr            error
+		expectedMembership v1.DispatchCheckResponse_Membership
+		expectedErr        codes.Code
+		expectedFallbacks  int
+	}{
+		{"peer answers", nil, v1.DispatchCheckResponse_MEMBER, codes.OK, 0},
+		{"peer unavailable", status.Error(codes.Unavailable, "no peers"), v1.DispatchCheckResponse_NOT_MEMBER, codes.OK, 1},
+		{"peer overloaded", status.Error(codes.ResourceExhausted, "overloaded"), v1.DispatchCheckResponse_NOT_MEMBER, codes.OK, 1},
+		{"request failed", status.Error(codes.FailedPrecondition, "unknown namespace"), v1.DispatchCheckResponse_UNKNOWN, codes.FailedPrecondition, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			client := &fakeClusterClient{err: tc.peerErr}
+			local := &fakeLocalDispatcher{}
+			dispatcher := NewClusterDispatcher(client, LocalFallback(local))
+
+			resp, err := dispatcher.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{
+				ObjectAndRelation: tuple.ParseONR("document:masterplan#view"),
+				Subject:           tuple.ParseSubjectONR("user:eng_lead"),
+				Metadata:          &v1.ResolverMeta{AtRevision: "1", DepthRemaining: 50},
+			})
+			require.Equal(tc.expectedErr, status.Code(err))
+			require.Equal(tc.expectedMembership, resp.Membership)
+			require.Equal(tc.expectedFallbacks, local.checks)
+			require.Equal("check//document:masterplan#view@1", client.routingKey)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/influxdata/tdigest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/authzed/spicedb/pkg/balancer"
+)
+
+const (
+	// maxLatencySamples is the number of latencies of a method after which the digest
+	// from which the quantile is computed is replaced by one covering fewer, more recent ones.
+	maxLatencySamples = 10000
+
+	defaultTDigestCompression = float64(1000)
+)
+
+var (
+	remoteRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "dispatch",
+		Name:      "remote_requests_total",
+		Help:      "total number of requests dispatched to peers, not counting hedges",
+	}, []string{"method"})
+
+	hedgedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "dispatch",
+		Name:      "remote_hedged_total",
+		Help:      "total number of requests dispatched to peers which were hedged with a duplicate sent to another peer",
+	}, []string{"method"})
+
+	hedgeWinsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "dispatch",
+		Name:      "remote_hedge_wins_total",
+		Help:      "total number of hedged requests answered first by the hedge",
+	}, []string{"method"})
+
+	hedgeWastedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "dispatch",
+		Name:      "remote_hedge_wasted_seconds_total",
+		Help:      "total time spent by peers on hedged requests, or their hedges, whose answers were abandoned for the other's",
+	}, []string{"method"})
+)
+
+// latencyTracker tracks the recent latencies of a method, to estimate a quantile of them.
+// As with datastore hedging, two digests are kept out of phase, so that when the current
+// one is full it is replaced by one which is already half warmed up.
+type latencyTracker struct {
+	sync.Mutex
+
+	quantile float64
+	digests  []*tdigest.TDigest
+}
+
+func newLatencyTracker(quantile float64, initialSlowValue time.Duration) *latencyTracker {
+	digests := []*tdigest.TDigest{
+		tdigest.NewWithCompression(defaultTDigestCompression),
+		tdigest.NewWithCompression(defaultTDigestCompression),
+	}
+	digests[0].Add(initialSlowValue.Seconds(), float64(maxLatencySamples)/2)
+
+	return &latencyTracker{quantile: quantile, digests: digests}
+}
+
+func (lt *latencyTracker) observe(latency time.Duration) {
+	lt.Lock()
+	defer lt.Unlock()
+
+	if lt.digests[0].Count() >= float64(maxLatencySamples) {
+		exhausted := lt.digests[0]
+		lt.digests = lt.digests[1:]
+		exhausted.Reset()
+		lt.digests = append(lt.digests, exhausted)
+	}
+
+	for _, digest := range lt.digests {
+		digest.Add(latency.Seconds(), 1)
+	}
+}
+
+// hedgeDelay returns the latency after which a request is hedged.
+func (lt *latencyTracker) hedgeDelay() time.Duration {
+	lt.Lock()
+	defer lt.Unlock()
+	return time.Duration(lt.digests[0].Quantile(lt.quantile) * float64(time.Second))
+}
+
+type hedgedResult struct {
+	resp     interface{}
+	err      error
+	hedge    bool
+	answered time.Time
+}
+
+// hedged makes the request, and, should it not be answered within the hedging quantile of
+// the latencies of the method, a duplicate of it to another peer. The first successful
+// answer is returned, and the other request is canceled.
+func (cr *clusterDispatcher) hedged(ctx context.Context, method string, request func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	remoteRequestsCounter.WithLabelValues(method).Inc()
+
+	tracker, ok := cr.latencies[method]
+	if !ok {
+		return request(ctx)
+	}
+
+	delay := tracker.hedgeDelay()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgedResult, 2)
+	send := func(ctx context.Context, hedge bool) {
+		resp, err := request(ctx)
+		results <- hedgedResult{resp, err, hedge, time.Now()}
+	}
+
+	start := time.Now()
+	go send(ctx, false)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var hedgeStart time.Time
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			hedgedCounter.WithLabelValues(method).Inc()
+			hedgeStart = time.Now()
+			pending++
+			go send(context.WithValue(ctx, balancer.HedgeCtxKey, true), true)
+
+		case result := <-results:
+			pending--
+			if result.err != nil && pending > 0 {
+				// The other request may still succeed.
+				continue
+			}
+
+			if result.err == nil {
+				// The latency of a request beaten by its hedge is at least that of the
+				// hedged request as a whole.
+				tracker.observe(result.answered.Sub(start))
+			}
+			if result.hedge {
+				hedgeWinsCounter.WithLabelValues(method).Inc()
+			}
+			if pending > 0 {
+				// The other request is abandoned, having run until now.
+				abandonedStart := hedgeStart
+				if result.hedge {
+					abandonedStart = start
+				}
+				hedgeWastedCounter.WithLabelValues(method).Add(result.answered.Sub(abandonedStart).Seconds())
+			}
+			return result.resp, result.err
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package remote
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/balancer"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// slowPeerClient answers hedged checks immediately, and the others only after a delay.
+type slowPeerClient struct {
+	fakeClusterClient
+	delay    time.Duration
+	requests int32
+	hedges   int32
+}
+
+func (sc *slowPeerClient) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest, opts ...grpc.CallOption) (*v1.DispatchCheckResponse, error) {
+	atomic.AddInt32(&sc.requests, 1)
+	if hedge, _ := ctx.Value(balancer.HedgeCtxKey).(bool); hedge {
+		atomic.AddInt32(&sc.hedges, 1)
+		return &v1.DispatchCheckResponse{Membership: v1.DispatchCheckResponse_MEMBER}, nil
+	}
+
+	select {
+	case <-time.After(sc.delay):
+		return &v1.DispatchCheckResponse{Membership: v1.DispatchCheckResponse_MEMBER}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestClusterDispatcherHedging(t *testing.T) {
+	testCases := []struct {
+		name           string
+		peerDelay      time.Duration
+		quantile       float64
+		expectedHedges int32
+	}{
+		{"slow peer is hedged", 10 * time.Second, 0.95, 1},
+		{"fast peer is not hedged", 0, 0.95, 0},
+		{"hedging disabled", 50 * time.Millisecond, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			client := &slowPeerClient{delay: tc.peerDelay}
+			dispatcher := NewClusterDispatcher(client,
+				HedgingQuantile(tc.quantile),
+				HedgingInitialSlowValue(10*time.Millisecond),
+			)
+
+			start := time.Now()
+			resp, err := dispatcher.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{
+				ObjectAndRelation: tuple.ParseONR("document:masterplan#view"),
+				Subject:           tuple.ParseSubjectONR("user:eng_lead"),
+				Metadata:          &v1.ResolverMeta{AtRevision: "1", DepthRemaining: 50},
+			})
+			require.NoError(err)
+			require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+			require.Less(time.Since(start), tc.peerDelay+time.Second)
+			require.Equal(tc.expectedHedges, atomic.LoadInt32(&client.hedges))
+			require.Equal(1+tc.expectedHedges, atomic.LoadInt32(&client.requests))
+		})
+	}
+}
+
+func TestLatencyTrackerQuantile(t *testing.T) {
+	require := require.New(t)
+
+	tracker := newLatencyTracker(0.9, 10*time.Millisecond)
+	require.InDelta(float64(10*time.Millisecond), float64(tracker.hedgeDelay()), float64(time.Millisecond))
+
+	// Once enough latencies are observed, they displace the initial value.
+	for i := 0; i < 2*maxLatencySamples; i++ {
+		tracker.observe(time.Duration(i%100) * time.Millisecond)
+	}
+	require.InDelta(float64(90*time.Millisecond), float64(tracker.hedgeDelay()), float64(5*time.Millisecond))
+}
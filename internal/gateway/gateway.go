@@ -6,6 +6,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/authzed/authzed-go/proto"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -21,6 +22,8 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/pkg/middleware/requestid"
 )
 
 var histogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
@@ -31,7 +34,7 @@ var histogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
 }, []string{"method"})
 
 // NewHandler creates an REST gateway HTTP Handler with the provided upstream
-// configuration.
+// configuration, which also serves the v1 Watch stream on WatchStreamPath.
 func NewHandler(ctx context.Context, upstreamAddr, upstreamTLSCertPath string) (http.Handler, error) {
 	opts := []grpc.DialOption{
 		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
@@ -43,7 +46,7 @@ func NewHandler(ctx context.Context, upstreamAddr, upstreamTLSCertPath string) (
 		opts = append(opts, grpcutil.WithCustomCerts(upstreamTLSCertPath, grpcutil.SkipVerifyCA))
 	}
 
-	gwMux := runtime.NewServeMux(runtime.WithMetadata(OtelAnnotator))
+	gwMux := runtime.NewServeMux(runtime.WithMetadata(OtelAnnotator), runtime.WithIncomingHeaderMatcher(HeaderMatcher))
 	if err := v1.RegisterSchemaServiceHandlerFromEndpoint(ctx, gwMux, upstreamAddr, opts); err != nil {
 		return nil, err
 	}
@@ -51,7 +54,17 @@ func NewHandler(ctx context.Context, upstreamAddr, upstreamTLSCertPath string) (
 		return nil, err
 	}
 
+	watchConn, err := grpc.DialContext(ctx, upstreamAddr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		watchConn.Close()
+	}()
+
 	mux := http.NewServeMux()
+	mux.Handle(WatchStreamPath, watchHandler{client: v1.NewWatchServiceClient(watchConn)})
 	mux.Handle("/openapi.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, _ = io.WriteString(w, proto.OpenAPISchema)
 	}))
@@ -60,6 +73,15 @@ func NewHandler(ctx context.Context, upstreamAddr, upstreamTLSCertPath string) (
 	return promhttp.InstrumentHandlerDuration(histogram, otelhttp.NewHandler(mux, "gateway")), nil
 }
 
+// HeaderMatcher forwards the request ID of HTTP requests to the gRPC server, in addition to
+// the headers forwarded by default.
+func HeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, requestid.RequestIDMetadataKey) {
+		return requestid.RequestIDMetadataKey, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
 var defaultOtelOpts = []otelgrpc.Option{
 	otelgrpc.WithPropagators(otel.GetTextMapPropagator()),
 	otelgrpc.WithTracerProvider(otel.GetTracerProvider()),
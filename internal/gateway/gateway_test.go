@@ -2,14 +2,20 @@ package gateway
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestOtelForwarding(t *testing.T) {
@@ -42,3 +48,49 @@ func TestOtelForwarding(t *testing.T) {
 	require.True(t, spanCtx.HasTraceID())
 	require.Equal(t, traceID, spanCtx.TraceID())
 }
+
+type recordingSchemaServer struct {
+	v1.UnimplementedSchemaServiceServer
+
+	authorization []string
+	requestID     []string
+}
+
+func (rs *recordingSchemaServer) ReadSchema(ctx context.Context, req *v1.ReadSchemaRequest) (*v1.ReadSchemaResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	rs.authorization = md.Get("authorization")
+	rs.requestID = md.Get("x-request-id")
+	return &v1.ReadSchemaResponse{SchemaText: "definition user {}"}, nil
+}
+
+func TestGatewayForwardsAuthorization(t *testing.T) {
+	require := require.New(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+
+	upstream := &recordingSchemaServer{}
+	srv := grpc.NewServer()
+	v1.RegisterSchemaServiceServer(srv, upstream)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handler, err := NewHandler(ctx, lis.Addr().String(), "")
+	require.NoError(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/schema/read", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer somepresharedkey")
+	req.Header.Set("X-Request-Id", "somerequestid")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	require.Equal(http.StatusOK, recorder.Code, recorder.Body.String())
+	require.JSONEq(`{"schemaText": "definition user {}"}`, recorder.Body.String())
+	require.Equal([]string{"Bearer somepresharedkey"}, upstream.authorization)
+	require.Equal([]string{"somerequestid"}, upstream.requestID)
+}
@@ -0,0 +1,266 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/authzed/spicedb/pkg/middleware/requestid"
+)
+
+// WatchStreamPath is the path on which the gateway serves the v1 Watch stream, as
+// Server-Sent Events or, for requests upgrading the connection, over a WebSocket.
+//
+// The optional_object_types query parameter, repeated or comma separated, filters the
+// changes and the optional_start_cursor query parameter resumes the watch from a token
+// received earlier. Server-Sent Events are identified by the token of their response, so
+// that reconnecting clients resume from their Last-Event-ID. The responses and their
+// checkpoints are those of the gRPC stream, encoded as JSON. Requests are authorized by
+// their Authorization header or, for browsers which cannot set it on streams, by their
+// access_token query parameter as described by RFC 6750.
+const WatchStreamPath = "/v1/watch/stream"
+
+// watchKeepaliveInterval is the interval at which idle streams are kept alive through
+// proxies, with comments for Server-Sent Events and pings for WebSockets.
+var watchKeepaliveInterval = 15 * time.Second
+
+// maxCloseReasonLength is the maximum length of the reason of a WebSocket close frame.
+const maxCloseReasonLength = 123
+
+// upgrader upgrades the connections of WebSocket watches. It only accepts requests from
+// the origin of the gateway, as browsers do not restrict WebSocket requests by origin.
+var upgrader = websocket.Upgrader{}
+
+type watchHandler struct {
+	client v1.WatchServiceClient
+}
+
+type watchEvent struct {
+	resp *v1.WatchResponse
+	err  error
+}
+
+func (wh watchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, watchMetadata(ctx, r))
+
+	if websocket.IsWebSocketUpgrade(r) {
+		wh.serveWebSocket(ctx, cancel, w, r)
+		return
+	}
+	wh.serveEvents(ctx, w, r)
+}
+
+func (wh watchHandler) serveEvents(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(watchKeepaliveInterval)
+	defer keepalive.Stop()
+
+	events := wh.watch(ctx, watchRequest(r))
+	for {
+		select {
+		case <-keepalive.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case event := <-events:
+			if event.err != nil {
+				if !errors.Is(event.err, io.EOF) {
+					data, _ := protojson.Marshal(status.Convert(event.err).Proto())
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+					flusher.Flush()
+				}
+				return
+			}
+
+			data, err := protojson.Marshal(event.resp)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\ndata: %s\n\n", event.resp.ChangesThrough.GetToken(), data); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func (wh watchHandler) serveWebSocket(ctx context.Context, cancel context.CancelFunc, w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with the error.
+		return
+	}
+	defer conn.Close()
+
+	// Clients send nothing, but reading handles their pongs and closes, and cancels the
+	// watch when they stop answering pings.
+	readTimeout := 2 * watchKeepaliveInterval
+	_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepalive := time.NewTicker(watchKeepaliveInterval)
+	defer keepalive.Stop()
+
+	events := wh.watch(ctx, watchRequest(r))
+	for {
+		select {
+		case <-keepalive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(watchKeepaliveInterval)); err != nil {
+				return
+			}
+		case event := <-events:
+			if event.err != nil {
+				_ = conn.WriteControl(websocket.CloseMessage, closeMessage(event.err), time.Now().Add(watchKeepaliveInterval))
+				return
+			}
+
+			data, err := protojson.Marshal(event.resp)
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watch starts the watch upstream and returns its responses, followed by the error which
+// ended it, which is io.EOF if the upstream ended the stream cleanly.
+func (wh watchHandler) watch(ctx context.Context, req *v1.WatchRequest) <-chan watchEvent {
+	events := make(chan watchEvent)
+
+	go func() {
+		send := func(event watchEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		stream, err := wh.client.Watch(ctx, req)
+		if err != nil {
+			send(watchEvent{err: err})
+			return
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				send(watchEvent{err: err})
+				return
+			}
+			if !send(watchEvent{resp: resp}) {
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+// closeMessage returns the WebSocket close frame reporting the end of a watch. Watches
+// ended by the server draining are closed as temporary, so that clients resume them.
+func closeMessage(err error) []byte {
+	if errors.Is(err, io.EOF) {
+		return websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	}
+
+	s := status.Convert(err)
+	code := websocket.CloseInternalServerErr
+	if s.Code() == codes.Unavailable {
+		code = websocket.CloseTryAgainLater
+	}
+
+	reason := fmt.Sprintf("%s: %s", s.Code(), s.Message())
+	if len(reason) > maxCloseReasonLength {
+		reason = reason[:maxCloseReasonLength]
+	}
+	return websocket.FormatCloseMessage(code, reason)
+}
+
+func watchRequest(r *http.Request) *v1.WatchRequest {
+	query := r.URL.Query()
+
+	req := &v1.WatchRequest{}
+	for _, objectTypes := range query["optional_object_types"] {
+		for _, objectType := range strings.Split(objectTypes, ",") {
+			if objectType = strings.TrimSpace(objectType); objectType != "" {
+				req.OptionalObjectTypes = append(req.OptionalObjectTypes, objectType)
+			}
+		}
+	}
+
+	// Reconnecting event sources repeat the URL they were created with, so the last event
+	// they received takes precedence over the cursor of the URL.
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = query.Get("optional_start_cursor")
+	}
+	if cursor != "" {
+		req.OptionalStartCursor = &v1.ZedToken{Token: cursor}
+	}
+
+	return req
+}
+
+// watchMetadata returns the metadata forwarded upstream with a watch, matching that
+// forwarded by the other routes of the gateway.
+func watchMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	md := OtelAnnotator(ctx, r)
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		if token := r.URL.Query().Get("access_token"); token != "" {
+			authorization = "Bearer " + token
+		}
+	}
+	if authorization != "" {
+		md.Set("authorization", authorization)
+	}
+
+	if id := r.Header.Get(requestid.RequestIDMetadataKey); id != "" {
+		md.Set(requestid.RequestIDMetadataKey, id)
+	}
+
+	return md
+}
@@ -0,0 +1,158 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var watchResponses = []*v1.WatchResponse{
+	{
+		Updates: []*v1.RelationshipUpdate{{
+			Operation: v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: &v1.Relationship{
+				Resource: &v1.ObjectReference{ObjectType: "document", ObjectId: "readme"},
+				Relation: "viewer",
+				Subject:  &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "tom"}},
+			},
+		}},
+		ChangesThrough: &v1.ZedToken{Token: "first"},
+	},
+	{ChangesThrough: &v1.ZedToken{Token: "second"}},
+}
+
+type recordingWatchServer struct {
+	v1.UnimplementedWatchServiceServer
+
+	authorization []string
+	request       *v1.WatchRequest
+	err           error
+}
+
+func (rw *recordingWatchServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	rw.authorization = md.Get("authorization")
+	rw.request = req
+
+	for _, resp := range watchResponses {
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return rw.err
+}
+
+func newWatchGateway(t *testing.T, upstream *recordingWatchServer) *httptest.Server {
+	require := require.New(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+
+	srv := grpc.NewServer()
+	v1.RegisterWatchServiceServer(srv, upstream)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	handler, err := NewHandler(ctx, lis.Addr().String(), "")
+	require.NoError(err)
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWatchServerSentEvents(t *testing.T) {
+	require := require.New(t)
+
+	upstream := &recordingWatchServer{err: status.Error(codes.Unavailable, "server is shutting down")}
+	server := newWatchGateway(t, upstream)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+WatchStreamPath+"?optional_object_types=document,folder&optional_start_cursor=initial", nil)
+	require.NoError(err)
+	req.Header.Set("Authorization", "Bearer somepresharedkey")
+	req.Header.Set("Last-Event-ID", "resumed")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	require.Equal(http.StatusOK, resp.StatusCode)
+	require.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	var events []map[string]string
+	event := map[string]string{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			events = append(events, event)
+			event = map[string]string{}
+			continue
+		}
+		field := strings.SplitN(line, ": ", 2)
+		event[field[0]] = field[1]
+	}
+	require.NoError(scanner.Err())
+
+	require.Len(events, 3)
+	for i, expected := range watchResponses {
+		require.Equal(expected.ChangesThrough.Token, events[i]["id"])
+
+		received := &v1.WatchResponse{}
+		require.NoError(protojson.Unmarshal([]byte(events[i]["data"]), received))
+		require.True(proto.Equal(expected, received))
+	}
+	require.Equal("error", events[2]["event"])
+	require.Contains(events[2]["data"], "server is shutting down")
+
+	require.Equal([]string{"Bearer somepresharedkey"}, upstream.authorization)
+	require.Equal([]string{"document", "folder"}, upstream.request.OptionalObjectTypes)
+	require.Equal("resumed", upstream.request.OptionalStartCursor.Token)
+}
+
+func TestWatchWebSocket(t *testing.T) {
+	require := require.New(t)
+
+	upstream := &recordingWatchServer{err: status.Error(codes.Unavailable, "server is shutting down")}
+	server := newWatchGateway(t, upstream)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + WatchStreamPath + "?access_token=somepresharedkey&optional_start_cursor=initial"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(err)
+	defer conn.Close()
+
+	for _, expected := range watchResponses {
+		messageType, data, err := conn.ReadMessage()
+		require.NoError(err)
+		require.Equal(websocket.TextMessage, messageType)
+
+		received := &v1.WatchResponse{}
+		require.NoError(protojson.Unmarshal(data, received))
+		require.True(proto.Equal(expected, received))
+	}
+
+	_, _, err = conn.ReadMessage()
+	require.True(websocket.IsCloseError(err, websocket.CloseTryAgainLater), "unexpected error: %v", err)
+
+	require.Equal([]string{"Bearer somepresharedkey"}, upstream.authorization)
+	require.Equal("initial", upstream.request.OptionalStartCursor.Token)
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
-	v1_proto "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
 
@@ -16,9 +15,25 @@ import (
 	"github.com/authzed/spicedb/pkg/tuple"
 )
 
+// CheckerOption is a function-style option for configuring a ConcurrentChecker.
+type CheckerOption func(*ConcurrentChecker)
+
+// PrefetchTuples enables loading, in a single query, the tuples of every relation of the
+// checked object which the check may read, and resolving the relations computed from
+// others on the same object without dispatching them.
+func PrefetchTuples(enabled bool) CheckerOption {
+	return func(cc *ConcurrentChecker) {
+		cc.prefetchEnabled = enabled
+	}
+}
+
 // NewConcurrentChecker creates an instance of ConcurrentChecker.
-func NewConcurrentChecker(d dispatch.Check, ds datastore.GraphDatastore, nsm namespace.Manager) *ConcurrentChecker {
-	return &ConcurrentChecker{d: d, ds: ds, nsm: nsm}
+func NewConcurrentChecker(d dispatch.Check, ds datastore.GraphDatastore, nsm namespace.Manager, options ...CheckerOption) *ConcurrentChecker {
+	cc := &ConcurrentChecker{d: d, ds: ds, nsm: nsm}
+	for _, fn := range options {
+		fn(cc)
+	}
+	return cc
 }
 
 // ConcurrentChecker exposes a method to perform Check requests, and delegates subproblems to the
@@ -27,6 +42,8 @@ type ConcurrentChecker struct {
 	d   dispatch.Check
 	ds  datastore.GraphDatastore
 	nsm namespace.Manager
+
+	prefetchEnabled bool
 }
 
 func onrEqual(lhs, rhs *v0.ObjectAndRelation) bool {
@@ -43,6 +60,18 @@ type ValidatedCheckRequest struct {
 
 // Check performs a check request with the provided request and context
 func (cc *ConcurrentChecker) Check(ctx context.Context, req ValidatedCheckRequest, relation *v0.Relation) (*v1.DispatchCheckResponse, error) {
+	if cc.prefetchEnabled {
+		var err error
+		ctx, err = cc.prefetch(ctx, req, relation)
+		if err != nil {
+			return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
+		}
+	}
+
+	return cc.check(ctx, req, relation)
+}
+
+func (cc *ConcurrentChecker) check(ctx context.Context, req ValidatedCheckRequest, relation *v0.Relation) (*v1.DispatchCheckResponse, error) {
 	var directFunc ReduceableCheckFunc
 
 	if req.Subject.ObjectId == tuple.PublicWildcard {
@@ -64,7 +93,7 @@ func (cc *ConcurrentChecker) Check(ctx context.Context, req ValidatedCheckReques
 func (cc *ConcurrentChecker) dispatch(req ValidatedCheckRequest) ReduceableCheckFunc {
 	return func(ctx context.Context, resultChan chan<- CheckResult) {
 		log.Ctx(ctx).Trace().Object("dispatch", req).Send()
-		result, err := cc.d.DispatchCheck(ctx, req.DispatchCheckRequest)
+		result, err := DispatchCheckTraced(ctx, cc.d, req.DispatchCheckRequest)
 		resultChan <- CheckResult{result, err}
 	}
 }
@@ -78,25 +107,24 @@ func (cc *ConcurrentChecker) checkDirect(ctx context.Context, req ValidatedCheck
 		log.Ctx(ctx).Trace().Object("direct", req).Send()
 
 		// TODO(jschorr): Use type information to further optimize this query.
-		it, err := cc.ds.QueryTuples(ctx, &v1_proto.RelationshipFilter{
-			ResourceType:       req.ObjectAndRelation.Namespace,
-			OptionalResourceId: req.ObjectAndRelation.ObjectId,
-			OptionalRelation:   req.ObjectAndRelation.Relation,
-		}, req.Revision)
+		it, err := cc.queryRelation(ctx, req, req.ObjectAndRelation.Relation)
 		if err != nil {
 			resultChan <- checkResultError(NewCheckFailureErr(err), emptyMetadata)
 			return
 		}
 		defer it.Close()
 
-		var requestsToDispatch []ReduceableCheckFunc
+		requestsToDispatch := make([]ReduceableCheckFunc, 0, hintedCapacity(it))
 		for tpl := it.Next(); tpl != nil; tpl = it.Next() {
 			tplUserset := tpl.User.GetUserset()
 			if onrEqualOrWildcard(tplUserset, req.Subject) {
+				traceTuple(ctx, tpl)
 				resultChan <- checkResult(v1.DispatchCheckResponse_MEMBER, emptyMetadata)
 				return
 			}
 			if tplUserset.Relation != Ellipsis {
+				traceTuple(ctx, tpl)
+
 				// We need to recursively call check here, potentially changing namespaces
 				requestsToDispatch = append(requestsToDispatch, cc.dispatch(ValidatedCheckRequest{
 					&v1.DispatchCheckRequest{
@@ -177,6 +205,25 @@ func (cc *ConcurrentChecker) checkComputedUserset(ctx context.Context, req Valid
 		return alwaysMember()
 	}
 
+	targetReq := ValidatedCheckRequest{
+		&v1.DispatchCheckRequest{
+			ObjectAndRelation: targetOnr,
+			Subject:           req.Subject,
+			Metadata:          decrementDepth(req.Metadata),
+		},
+		req.Revision,
+	}
+
+	// Relations computed from others on a prefetched object are resolved from the
+	// prefetched tuples, rather than being dispatched.
+	if prefetched := prefetchedFor(ctx, targetOnr, req.Revision); prefetched != nil {
+		targetRelation := prefetched.relation(cu.Relation)
+		if targetRelation == nil {
+			return notMember()
+		}
+		return cc.checkInline(targetReq, targetRelation)
+	}
+
 	// Check if the target relation exists. If not, return nothing.
 	err := cc.nsm.CheckNamespaceAndRelation(ctx, start.Namespace, cu.Relation, true, req.Revision)
 	if err != nil {
@@ -187,32 +234,39 @@ func (cc *ConcurrentChecker) checkComputedUserset(ctx context.Context, req Valid
 		return checkError(err)
 	}
 
-	return cc.dispatch(ValidatedCheckRequest{
-		&v1.DispatchCheckRequest{
-			ObjectAndRelation: targetOnr,
-			Subject:           req.Subject,
-			Metadata:          decrementDepth(req.Metadata),
-		},
-		req.Revision,
-	})
+	return cc.dispatch(targetReq)
+}
+
+// checkInline resolves the request without dispatching it, while still enforcing the depth
+// limit which a dispatch would have.
+func (cc *ConcurrentChecker) checkInline(req ValidatedCheckRequest, relation *v0.Relation) ReduceableCheckFunc {
+	return func(ctx context.Context, resultChan chan<- CheckResult) {
+		log.Ctx(ctx).Trace().Object("inline", req).Send()
+		if err := dispatch.CheckDepth(ctx, req); err != nil {
+			resultChan <- checkResultError(dispatch.WithMaxDepthParent(err, req.ObjectAndRelation), emptyMetadata)
+			return
+		}
+
+		result, err := traceCheck(ctx, req.DispatchCheckRequest, true, func(ctx context.Context) (*v1.DispatchCheckResponse, error) {
+			return cc.check(ctx, req, relation)
+		})
+		resultChan <- CheckResult{result, dispatch.WithMaxDepthParent(err, req.ObjectAndRelation)}
+	}
 }
 
 func (cc *ConcurrentChecker) checkTupleToUserset(ctx context.Context, req ValidatedCheckRequest, ttu *v0.TupleToUserset) ReduceableCheckFunc {
 	return func(ctx context.Context, resultChan chan<- CheckResult) {
 		log.Ctx(ctx).Trace().Object("ttu", req).Send()
-		it, err := cc.ds.QueryTuples(ctx, &v1_proto.RelationshipFilter{
-			ResourceType:       req.ObjectAndRelation.Namespace,
-			OptionalResourceId: req.ObjectAndRelation.ObjectId,
-			OptionalRelation:   ttu.Tupleset.Relation,
-		}, req.Revision)
+		it, err := cc.queryRelation(ctx, req, ttu.Tupleset.Relation)
 		if err != nil {
 			resultChan <- checkResultError(NewCheckFailureErr(err), emptyMetadata)
 			return
 		}
 		defer it.Close()
 
-		var requestsToDispatch []ReduceableCheckFunc
+		requestsToDispatch := make([]ReduceableCheckFunc, 0, hintedCapacity(it))
 		for tpl := it.Next(); tpl != nil; tpl = it.Next() {
+			traceTuple(ctx, tpl)
 			requestsToDispatch = append(requestsToDispatch, cc.checkComputedUserset(ctx, req, ttu.ComputedUserset, tpl))
 		}
 		if it.Err() != nil {
@@ -236,7 +290,7 @@ func all(ctx context.Context, requests []ReduceableCheckFunc) CheckResult {
 	defer cancelFn()
 
 	for _, req := range requests {
-		go req(childCtx, resultChan)
+		go runCheck(childCtx, req, resultChan)
 	}
 
 	for i := 0; i < len(requests); i++ {
@@ -290,7 +344,7 @@ func any(ctx context.Context, requests []ReduceableCheckFunc) CheckResult {
 	defer cancelFn()
 
 	for _, req := range requests {
-		go req(childCtx, resultChan)
+		go runCheck(childCtx, req, resultChan)
 	}
 
 	responseMetadata := emptyMetadata
@@ -324,9 +378,9 @@ func difference(ctx context.Context, requests []ReduceableCheckFunc) CheckResult
 	baseChan := make(chan CheckResult, 1)
 	othersChan := make(chan CheckResult, len(requests)-1)
 
-	go requests[0](childCtx, baseChan)
+	go runCheck(childCtx, requests[0], baseChan)
 	for _, req := range requests[1:] {
-		go req(childCtx, othersChan)
+		go runCheck(childCtx, req, othersChan)
 	}
 
 	responseMetadata := emptyMetadata
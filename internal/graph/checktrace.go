@@ -0,0 +1,153 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+type checkTraceKey struct{}
+
+// CheckTrace is a node of the trace of a check, which records a check of a relation of an
+// object, how it was resolved and the checks it led to.
+//
+// Only the checks resolved in this process are traced: a check dispatched to another node
+// of the cluster appears without the checks it led to.
+type CheckTrace struct {
+	sync.Mutex
+
+	// Resource is the object and relation checked.
+	Resource string
+
+	// Subject is the subject checked for.
+	Subject string
+
+	// Result is the membership of the subject, or the error with which the check failed.
+	Result string
+
+	// Cached is whether the result was served from the dispatch cache.
+	Cached bool
+
+	// Inline is whether the check was resolved from prefetched tuples, without being
+	// dispatched.
+	Inline bool
+
+	// Duration is the time taken by the check.
+	Duration time.Duration
+
+	// Tuples are the tuples read by the check which matched the subject or were followed.
+	Tuples []string
+
+	// Children are the checks which this check led to.
+	Children []*CheckTrace
+}
+
+// WithCheckTrace returns a context in which the checks dispatched through DispatchCheckTraced
+// are traced, and the node to which they are added as children.
+func WithCheckTrace(ctx context.Context) (context.Context, *CheckTrace) {
+	root := &CheckTrace{}
+	return context.WithValue(ctx, checkTraceKey{}, root), root
+}
+
+// IsCheckTraced returns whether the checks dispatched with the context are traced.
+func IsCheckTraced(ctx context.Context) bool {
+	_, ok := ctx.Value(checkTraceKey{}).(*CheckTrace)
+	return ok
+}
+
+// DispatchCheckTraced dispatches the check, adding it to the trace of the context if there
+// is one.
+func DispatchCheckTraced(ctx context.Context, d dispatch.Check, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	return traceCheck(ctx, req, false, func(ctx context.Context) (*v1.DispatchCheckResponse, error) {
+		return d.DispatchCheck(ctx, req)
+	})
+}
+
+func traceCheck(
+	ctx context.Context,
+	req *v1.DispatchCheckRequest,
+	inline bool,
+	check func(ctx context.Context) (*v1.DispatchCheckResponse, error),
+) (*v1.DispatchCheckResponse, error) {
+	parent, ok := ctx.Value(checkTraceKey{}).(*CheckTrace)
+	if !ok {
+		return check(ctx)
+	}
+
+	node := &CheckTrace{
+		Resource: tuple.StringONR(req.ObjectAndRelation),
+		Subject:  tuple.StringONR(req.Subject),
+		Inline:   inline,
+	}
+	parent.Lock()
+	parent.Children = append(parent.Children, node)
+	parent.Unlock()
+
+	start := time.Now()
+	resp, err := check(context.WithValue(ctx, checkTraceKey{}, node))
+
+	node.Lock()
+	defer node.Unlock()
+	node.Duration = time.Since(start)
+	switch {
+	case err != nil:
+		node.Result = err.Error()
+	case resp != nil:
+		node.Result = resp.Membership.String()
+
+		// Responses served from the cache have their dispatches counted as cached,
+		// whereas computed responses count at least their own dispatch.
+		node.Cached = resp.Metadata.GetDispatchCount() == 0 && resp.Metadata.GetCachedDispatchCount() > 0
+	}
+	return resp, err
+}
+
+// traceTuple records a tuple read by the check traced in the context, if any.
+func traceTuple(ctx context.Context, tpl *v0.RelationTuple) {
+	node, ok := ctx.Value(checkTraceKey{}).(*CheckTrace)
+	if !ok {
+		return
+	}
+
+	node.Lock()
+	defer node.Unlock()
+	node.Tuples = append(node.Tuples, tuple.String(tpl))
+}
+
+type checkTraceJSON struct {
+	Resource   string        `json:"resource"`
+	Subject    string        `json:"subject"`
+	Result     string        `json:"result"`
+	Cached     bool          `json:"cached,omitempty"`
+	Inline     bool          `json:"inline,omitempty"`
+	DurationMs float64       `json:"durationMs"`
+	Tuples     []string      `json:"tuples,omitempty"`
+	Children   []*CheckTrace `json:"children,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. Branches of a check may still be recorded after
+// the check completed, as they are abandoned once its result is known, so the trace is
+// read under its lock.
+func (ct *CheckTrace) MarshalJSON() ([]byte, error) {
+	ct.Lock()
+	encoded := checkTraceJSON{
+		Resource:   ct.Resource,
+		Subject:    ct.Subject,
+		Result:     ct.Result,
+		Cached:     ct.Cached,
+		Inline:     ct.Inline,
+		DurationMs: float64(ct.Duration) / float64(time.Millisecond),
+		Tuples:     append([]string(nil), ct.Tuples...),
+		Children:   append([]*CheckTrace(nil), ct.Children...),
+	}
+	ct.Unlock()
+
+	return json.Marshal(encoded)
+}
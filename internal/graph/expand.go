@@ -244,7 +244,7 @@ func (ce *ConcurrentExpander) expandTupleToUserset(ctx context.Context, req Vali
 		}
 		defer it.Close()
 
-		var requestsToDispatch []ReduceableExpandFunc
+		requestsToDispatch := make([]ReduceableExpandFunc, 0, hintedCapacity(it))
 		for tpl := it.Next(); tpl != nil; tpl = it.Next() {
 			requestsToDispatch = append(requestsToDispatch, ce.expandComputedUserset(ctx, req, ttu.ComputedUserset, tpl))
 		}
@@ -296,7 +296,7 @@ func expandSetOperation(
 	for _, req := range requests {
 		resultChan := make(chan ExpandResult)
 		resultChans = append(resultChans, resultChan)
-		go req(childCtx, resultChan)
+		go runExpand(childCtx, req, resultChan)
 	}
 
 	responseMetadata := emptyMetadata
@@ -353,7 +353,7 @@ func expandDifference(ctx context.Context, start *v0.ObjectAndRelation, requests
 // expandOne waits for exactly one response
 func expandOne(ctx context.Context, request ReduceableExpandFunc) ExpandResult {
 	resultChan := make(chan ExpandResult, 1)
-	go request(ctx, resultChan)
+	go runExpand(ctx, request, resultChan)
 
 	select {
 	case result := <-resultChan:
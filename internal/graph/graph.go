@@ -5,6 +5,8 @@ import (
 
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/middleware/recovery"
 	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
 )
 
@@ -61,6 +63,49 @@ type ReduceableLookupFunc func(ctx context.Context, resultChan chan<- LookupResu
 // LookupReducer is a type for the functions which combine lookup results.
 type LookupReducer func(ctx context.Context, parentReq ValidatedLookupRequest, limit uint32, requests []ReduceableLookupFunc) LookupResult
 
+// runCheck invokes the check, reporting a panic as an error result instead of
+// crashing the process.
+func runCheck(ctx context.Context, req ReduceableCheckFunc, resultChan chan<- CheckResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			select {
+			case resultChan <- checkResultError(recovery.HandlePanic(ctx, p), emptyMetadata):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	req(ctx, resultChan)
+}
+
+// runExpand invokes the expand, reporting a panic as an error result instead of
+// crashing the process.
+func runExpand(ctx context.Context, req ReduceableExpandFunc, resultChan chan<- ExpandResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			select {
+			case resultChan <- expandResultError(recovery.HandlePanic(ctx, p), emptyMetadata):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	req(ctx, resultChan)
+}
+
+// runLookup invokes the lookup, reporting a panic as an error result instead of
+// crashing the process.
+func runLookup(ctx context.Context, req ReduceableLookupFunc, resultChan chan<- LookupResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			result := LookupResult{&v1.DispatchLookupResponse{Metadata: emptyMetadata}, recovery.HandlePanic(ctx, p)}
+			select {
+			case resultChan <- result:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	req(ctx, resultChan)
+}
+
 func decrementDepth(md *v1.ResolverMeta) *v1.ResolverMeta {
 	return &v1.ResolverMeta{
 		AtRevision:     md.AtRevision,
@@ -121,3 +166,17 @@ func addCallToResponseMetadata(metadata *v1.ResponseMeta) *v1.ResponseMeta {
 		LookupExcludedTtu:    metadata.LookupExcludedTtu,
 	}
 }
+
+// maxHintedCapacity bounds the capacity preallocated from the size hints of iterators,
+// which may only be estimates.
+const maxHintedCapacity = 1024
+
+// hintedCapacity returns the capacity to preallocate for the results of processing each
+// tuple of the iterator.
+func hintedCapacity(it datastore.TupleIterator) int {
+	count, _ := datastore.SizeHint(it)
+	if count > maxHintedCapacity {
+		return maxHintedCapacity
+	}
+	return count
+}
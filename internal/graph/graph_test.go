@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func panicExpand(ctx context.Context, resultChan chan<- ExpandResult) {
+	panic("poison expand")
+}
+
+func TestExpandSetOperationRecoversPanic(t *testing.T) {
+	start := tuple.ParseONR("document:masterplan#viewer")
+
+	testCases := []struct {
+		name     string
+		reducer  func(context.Context, *v0.ObjectAndRelation, []ReduceableExpandFunc) ExpandResult
+		requests []ReduceableExpandFunc
+	}{
+		{"union", expandAny, []ReduceableExpandFunc{emptyExpansion(start), panicExpand}},
+		{"intersection", expandAll, []ReduceableExpandFunc{panicExpand, emptyExpansion(start)}},
+		{"exclusion", expandDifference, []ReduceableExpandFunc{emptyExpansion(start), panicExpand}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			results := make(chan ExpandResult, 1)
+			go func() {
+				results <- tc.reducer(context.Background(), start, tc.requests)
+			}()
+
+			select {
+			case result := <-results:
+				require.Error(t, result.Err)
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "the panic of the expansion was not reported")
+			}
+		})
+	}
+}
+
+func TestAnyRecoversPanic(t *testing.T) {
+	results := make(chan CheckResult, 1)
+	go func() {
+		results <- any(context.Background(), []ReduceableCheckFunc{
+			func(ctx context.Context, resultChan chan<- CheckResult) {
+				panic("poison check")
+			},
+		})
+	}()
+
+	select {
+	case result := <-results:
+		require.Error(t, result.Err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the panic of the check was not reported")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1_proto "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -33,11 +34,39 @@ type ConcurrentLookup struct {
 
 // ValidatedLookupRequest represents a request after it has been validated and parsed for internal
 // consumption.
+//
+// A limited request resolves the objects with the lowest IDs, up to its limit, and a request
+// with an AfterResourceId only resolves the objects whose IDs sort after it, so that a
+// limited request can be resumed after the last object it resolved. Such bounds are pushed
+// down to the queries of the relationships of the resolved objects, which are then read in
+// order of their IDs.
 type ValidatedLookupRequest struct {
 	*v1.DispatchLookupRequest
 	Revision decimal.Decimal
 }
 
+// isBounded returns whether the request resolves only some of the objects, because it is
+// limited or resumed.
+func (req ValidatedLookupRequest) isBounded() bool {
+	return req.Limit != noLimit || req.AfterResourceId != ""
+}
+
+// withBounds returns the request with the limit and the ID after which it resumes replaced.
+func (req ValidatedLookupRequest) withBounds(limit uint32, afterResourceID string) ValidatedLookupRequest {
+	return ValidatedLookupRequest{
+		&v1.DispatchLookupRequest{
+			Metadata:        req.Metadata,
+			ObjectRelation:  req.ObjectRelation,
+			Subject:         req.Subject,
+			Limit:           limit,
+			DirectStack:     req.DirectStack,
+			TtuStack:        req.TtuStack,
+			AfterResourceId: afterResourceID,
+		},
+		req.Revision,
+	}
+}
+
 // Calculate the maximum int value to allow us to effectively set no limit on certain recursive
 // lookup calls.
 const (
@@ -73,14 +102,6 @@ func (cl *ConcurrentLookup) Lookup(ctx context.Context, req ValidatedLookupReque
 func (cl *ConcurrentLookup) lookupInternal(ctx context.Context, req ValidatedLookupRequest) ReduceableLookupFunc {
 	log.Ctx(ctx).Trace().Object("lookup", req).Send()
 
-	objSet := tuple.NewONRSet()
-
-	// If we've found the target ONR, add it to the set of resolved objects. Note that we still need
-	// to continue processing, as this may also be an intermediate step in resolution.
-	if req.ObjectRelation.Namespace == req.Subject.Namespace && req.ObjectRelation.Relation == req.Subject.Relation {
-		objSet.Add(req.Subject)
-	}
-
 	nsdef, typeSystem, err := cl.nsm.ReadNamespaceAndTypes(ctx, req.ObjectRelation.Namespace, req.Revision)
 	if err != nil {
 		return returnResult(lookupResultError(req, err, emptyMetadata))
@@ -96,6 +117,38 @@ func (cl *ConcurrentLookup) lookupInternal(ctx context.Context, req ValidatedLoo
 		))
 	}
 
+	// The objects found for a relation which reaches itself are followed to find others, so
+	// objects outside the bounds of the request may lead to objects within them. Such a
+	// request is resolved without its bounds, which are then applied to the objects found.
+	if req.isBounded() {
+		reachability, err := typeSystem.Reachability(ctx, req.ObjectRelation.Relation)
+		if err != nil {
+			return returnResult(lookupResultError(req, err, emptyMetadata))
+		}
+
+		if reachability.HasEdgeTo(req.ObjectRelation.Namespace, req.ObjectRelation.Relation) {
+			return func(ctx context.Context, resultChan chan<- LookupResult) {
+				result := lookupOne(ctx, req, cl.lookupInternal(ctx, req.withBounds(noLimit, "")))
+				if result.Err != nil {
+					resultChan <- lookupResultError(req, result.Err, result.Resp.Metadata)
+					return
+				}
+
+				resolved := limitedSlice(resolvedAfter(req, result.Resp.ResolvedOnrs), req.Limit)
+				resultChan <- lookupResult(req, resolved, result.Resp.Metadata)
+			}
+		}
+	}
+
+	objSet := tuple.NewONRSet()
+
+	// If we've found the target ONR, add it to the set of resolved objects. Note that we still need
+	// to continue processing, as this may also be an intermediate step in resolution.
+	if req.ObjectRelation.Namespace == req.Subject.Namespace && req.ObjectRelation.Relation == req.Subject.Relation &&
+		req.Subject.ObjectId > req.AfterResourceId {
+		objSet.Add(req.Subject)
+	}
+
 	rewrite := relation.UsersetRewrite
 	var request ReduceableLookupFunc
 	if rewrite != nil {
@@ -112,11 +165,17 @@ func (cl *ConcurrentLookup) lookupInternal(ctx context.Context, req ValidatedLoo
 
 	objSet.Update(result.Resp.ResolvedOnrs)
 
+	// The relation of a bounded request does not reach itself, so following the objects
+	// found would find nothing more.
+	if req.isBounded() {
+		return returnResult(lookupResult(req, limitedSlice(objSet.AsSlice(), req.Limit), result.Resp.Metadata))
+	}
+
 	// Recursively perform lookup on any of the ONRs found that do not match the target ONR.
 	// This ensures that we resolve the full transitive closure of all objects.
 	toCheck := objSet
 	responseMetadata := result.Resp.Metadata
-	for toCheck.Length() != 0 && objSet.Length() < req.Limit {
+	for toCheck.Length() != 0 {
 		var requests []ReduceableLookupFunc
 		for _, obj := range toCheck.AsSlice() {
 			// If we've already found the target ONR, no further resolution is necessary.
@@ -128,7 +187,7 @@ func (cl *ConcurrentLookup) lookupInternal(ctx context.Context, req ValidatedLoo
 				&v1.DispatchLookupRequest{
 					Subject:        obj,
 					ObjectRelation: req.ObjectRelation,
-					Limit:          req.Limit - objSet.Length(),
+					Limit:          noLimit,
 					Metadata:       decrementDepth(req.Metadata),
 					DirectStack:    req.DirectStack,
 					TtuStack:       req.TtuStack,
@@ -137,7 +196,7 @@ func (cl *ConcurrentLookup) lookupInternal(ctx context.Context, req ValidatedLoo
 			}))
 		}
 
-		result := lookupAny(ctx, req, req.Limit, requests)
+		result := lookupAny(ctx, req, noLimit, requests)
 		responseMetadata = combineResponseMetadata(responseMetadata, result.Resp.Metadata)
 
 		if result.Err != nil {
@@ -154,7 +213,7 @@ func (cl *ConcurrentLookup) lookupInternal(ctx context.Context, req ValidatedLoo
 		}
 	}
 
-	return returnResult(lookupResult(req, limitedSlice(objSet.AsSlice(), req.Limit), responseMetadata))
+	return returnResult(lookupResult(req, objSet.AsSlice(), responseMetadata))
 }
 
 func (cl *ConcurrentLookup) lookupDirect(ctx context.Context, req ValidatedLookupRequest, typeSystem *namespace.NamespaceTypeSystem) ReduceableLookupFunc {
@@ -177,35 +236,13 @@ func (cl *ConcurrentLookup) lookupDirect(ctx context.Context, req ValidatedLooku
 
 	if isDirectAllowed == namespace.DirectRelationValid {
 		requests = append(requests, func(ctx context.Context, resultChan chan<- LookupResult) {
-			objects := tuple.NewONRSet()
-			it, err := cl.ds.ReverseQueryTuples(
-				ctx,
-				tuple.UsersetToSubjectFilter(req.Subject),
-				req.Revision,
-				options.WithResRelation(&options.ResourceRelation{
-					Namespace: req.ObjectRelation.Namespace,
-					Relation:  req.ObjectRelation.Relation,
-				}),
-			)
+			objects, err := cl.lookupSubjectRelationships(ctx, req, req.Subject)
 			if err != nil {
 				resultChan <- lookupResultError(req, err, emptyMetadata)
 				return
 			}
-			defer it.Close()
-
-			for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-				objects.Add(tpl.ObjectAndRelation)
-				if objects.Length() >= req.Limit {
-					break
-				}
-			}
-
-			if it.Err() != nil {
-				resultChan <- lookupResultError(req, it.Err(), emptyMetadata)
-				return
-			}
 
-			resultChan <- lookupResult(req, objects.AsSlice(), emptyMetadata)
+			resultChan <- lookupResult(req, objects, emptyMetadata)
 		})
 	}
 
@@ -217,39 +254,17 @@ func (cl *ConcurrentLookup) lookupDirect(ctx context.Context, req ValidatedLooku
 
 	if isWildcardAllowed == namespace.PublicSubjectAllowed {
 		requests = append(requests, func(ctx context.Context, resultChan chan<- LookupResult) {
-			objects := tuple.NewONRSet()
-			it, err := cl.ds.ReverseQueryTuples(
-				ctx,
-				tuple.UsersetToSubjectFilter(&v0.ObjectAndRelation{
-					Namespace: req.Subject.Namespace,
-					ObjectId:  tuple.PublicWildcard,
-					Relation:  req.Subject.Relation,
-				}),
-				req.Revision,
-				options.WithResRelation(&options.ResourceRelation{
-					Namespace: req.ObjectRelation.Namespace,
-					Relation:  req.ObjectRelation.Relation,
-				}),
-			)
+			objects, err := cl.lookupSubjectRelationships(ctx, req, &v0.ObjectAndRelation{
+				Namespace: req.Subject.Namespace,
+				ObjectId:  tuple.PublicWildcard,
+				Relation:  req.Subject.Relation,
+			})
 			if err != nil {
 				resultChan <- lookupResultError(req, err, emptyMetadata)
 				return
 			}
-			defer it.Close()
 
-			for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-				objects.Add(tpl.ObjectAndRelation)
-				if objects.Length() >= req.Limit {
-					break
-				}
-			}
-
-			if it.Err() != nil {
-				resultChan <- lookupResultError(req, it.Err(), emptyMetadata)
-				return
-			}
-
-			resultChan <- lookupResult(req, objects.AsSlice(), emptyMetadata)
+			resultChan <- lookupResult(req, objects, emptyMetadata)
 		})
 	}
 
@@ -315,39 +330,16 @@ func (cl *ConcurrentLookup) lookupDirect(ctx context.Context, req ValidatedLooku
 			}
 
 			// For each inferred object found, check for the target ONR.
-			objects := tuple.NewONRSet()
-			if len(result.Resp.ResolvedOnrs) > 0 {
-				limit := uint64(req.Limit)
-				it, err := cl.ds.QueryTuples(
-					ctx,
-					&v1_proto.RelationshipFilter{
-						ResourceType:     req.ObjectRelation.Namespace,
-						OptionalRelation: req.ObjectRelation.Relation,
-					},
-					req.Revision,
-					options.SetUsersets(result.Resp.ResolvedOnrs),
-					options.WithLimit(&limit),
-				)
-				if err != nil {
-					resultChan <- lookupResultError(req, err, emptyMetadata)
-					return
-				}
-				defer it.Close()
-
-				for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-					if it.Err() != nil {
-						resultChan <- lookupResultError(req, it.Err(), emptyMetadata)
-						return
-					}
-
-					objects.Add(tpl.ObjectAndRelation)
-					if objects.Length() >= req.Limit {
-						break
-					}
-				}
+			objects, err := cl.queryResolvedObjects(ctx, req, &v1_proto.RelationshipFilter{
+				ResourceType:     req.ObjectRelation.Namespace,
+				OptionalRelation: req.ObjectRelation.Relation,
+			}, result.Resp.ResolvedOnrs)
+			if err != nil {
+				resultChan <- lookupResultError(req, err, result.Resp.Metadata)
+				return
 			}
 
-			resultChan <- lookupResult(req, objects.AsSlice(), result.Resp.Metadata)
+			resultChan <- lookupResult(req, objects, result.Resp.Metadata)
 		})
 	}
 
@@ -357,31 +349,35 @@ func (cl *ConcurrentLookup) lookupDirect(ctx context.Context, req ValidatedLooku
 }
 
 func (cl *ConcurrentLookup) processRewrite(ctx context.Context, req ValidatedLookupRequest, nsdef *v0.NamespaceDefinition, typeSystem *namespace.NamespaceTypeSystem, usr *v0.UsersetRewrite) ReduceableLookupFunc {
+	// The objects with the lowest IDs of each child of an intersection or exclusion need not
+	// be those of the result, so only a union limits its children.
+	unlimitedReq := req.withBounds(noLimit, req.AfterResourceId)
+
 	switch rw := usr.RewriteOperation.(type) {
 	case *v0.UsersetRewrite_Union:
-		return cl.processSetOperation(ctx, req, nsdef, typeSystem, rw.Union, lookupAny)
+		return cl.processSetOperation(ctx, req, req, nsdef, typeSystem, rw.Union, lookupAny)
 	case *v0.UsersetRewrite_Intersection:
-		return cl.processSetOperation(ctx, req, nsdef, typeSystem, rw.Intersection, lookupAll)
+		return cl.processSetOperation(ctx, req, unlimitedReq, nsdef, typeSystem, rw.Intersection, lookupAll)
 	case *v0.UsersetRewrite_Exclusion:
-		return cl.processSetOperation(ctx, req, nsdef, typeSystem, rw.Exclusion, lookupExclude)
+		return cl.processSetOperation(ctx, req, unlimitedReq, nsdef, typeSystem, rw.Exclusion, lookupExclude)
 	default:
 		return returnResult(lookupResultError(req, fmt.Errorf("unknown userset rewrite kind under `%s#%s`", req.ObjectRelation.Namespace, req.ObjectRelation.Relation), emptyMetadata))
 	}
 }
 
-func (cl *ConcurrentLookup) processSetOperation(ctx context.Context, req ValidatedLookupRequest, nsdef *v0.NamespaceDefinition, typeSystem *namespace.NamespaceTypeSystem, so *v0.SetOperation, reducer LookupReducer) ReduceableLookupFunc {
+func (cl *ConcurrentLookup) processSetOperation(ctx context.Context, req ValidatedLookupRequest, childReq ValidatedLookupRequest, nsdef *v0.NamespaceDefinition, typeSystem *namespace.NamespaceTypeSystem, so *v0.SetOperation, reducer LookupReducer) ReduceableLookupFunc {
 	var requests []ReduceableLookupFunc
 
 	for _, childOneof := range so.Child {
 		switch child := childOneof.ChildType.(type) {
 		case *v0.SetOperation_Child_XThis:
-			requests = append(requests, cl.lookupDirect(ctx, req, typeSystem))
+			requests = append(requests, cl.lookupDirect(ctx, childReq, typeSystem))
 		case *v0.SetOperation_Child_ComputedUserset:
-			requests = append(requests, cl.lookupComputed(ctx, req, child.ComputedUserset))
+			requests = append(requests, cl.lookupComputed(ctx, childReq, child.ComputedUserset))
 		case *v0.SetOperation_Child_UsersetRewrite:
-			requests = append(requests, cl.processRewrite(ctx, req, nsdef, typeSystem, child.UsersetRewrite))
+			requests = append(requests, cl.processRewrite(ctx, childReq, nsdef, typeSystem, child.UsersetRewrite))
 		case *v0.SetOperation_Child_TupleToUserset:
-			requests = append(requests, cl.processTupleToUserset(ctx, req, nsdef, typeSystem, child.TupleToUserset))
+			requests = append(requests, cl.processTupleToUserset(ctx, childReq, nsdef, typeSystem, child.TupleToUserset))
 		default:
 			return returnResult(lookupResultError(req, fmt.Errorf("unknown set operation child"), emptyMetadata))
 		}
@@ -504,49 +500,16 @@ func (cl *ConcurrentLookup) processTupleToUserset(ctx context.Context, req Valid
 			}
 
 			// Perform the tupleset lookup.
-			objects := tuple.NewONRSet()
-			if len(usersets) > 0 {
-				limit := uint64(req.Limit)
-				it, err := cl.ds.QueryTuples(
-					ctx,
-					&v1_proto.RelationshipFilter{
-						ResourceType:     req.ObjectRelation.Namespace,
-						OptionalRelation: ttu.Tupleset.Relation,
-					},
-					req.Revision,
-					options.SetUsersets(usersets),
-					options.WithLimit(&limit),
-				)
-				if err != nil {
-					resultChan <- lookupResultError(req, err, result.Resp.Metadata)
-					return
-				}
-				defer it.Close()
-
-				for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-					if it.Err() != nil {
-						resultChan <- lookupResultError(req, it.Err(), result.Resp.Metadata)
-						return
-					}
-
-					if tpl.ObjectAndRelation.Namespace != req.ObjectRelation.Namespace {
-						resultChan <- lookupResultError(req, fmt.Errorf("got unexpected namespace"), result.Resp.Metadata)
-						return
-					}
-
-					objects.Add(&v0.ObjectAndRelation{
-						Namespace: req.ObjectRelation.Namespace,
-						ObjectId:  tpl.ObjectAndRelation.ObjectId,
-						Relation:  req.ObjectRelation.Relation,
-					})
-
-					if objects.Length() >= req.Limit {
-						break
-					}
-				}
+			objects, err := cl.queryResolvedObjects(ctx, req, &v1_proto.RelationshipFilter{
+				ResourceType:     req.ObjectRelation.Namespace,
+				OptionalRelation: ttu.Tupleset.Relation,
+			}, usersets)
+			if err != nil {
+				resultChan <- lookupResultError(req, err, result.Resp.Metadata)
+				return
 			}
 
-			resultChan <- lookupResult(req, objects.AsSlice(), result.Resp.Metadata)
+			resultChan <- lookupResult(req, objects, result.Resp.Metadata)
 		})
 	}
 
@@ -584,7 +547,8 @@ func (cl *ConcurrentLookup) lookupComputed(ctx context.Context, req ValidatedLoo
 				Namespace: req.ObjectRelation.Namespace,
 				Relation:  req.ObjectRelation.Relation,
 			}),
-			TtuStack: req.TtuStack,
+			TtuStack:        req.TtuStack,
+			AfterResourceId: req.AfterResourceId,
 		},
 		req.Revision,
 	}))
@@ -618,6 +582,101 @@ func (cl *ConcurrentLookup) lookupComputed(ctx context.Context, req ValidatedLoo
 	return returnResult(lookupResult(req, rewrittenResolved, result.Resp.Metadata))
 }
 
+// lookupSubjectRelationships returns the objects of the relation of the request which have
+// the subject directly.
+func (cl *ConcurrentLookup) lookupSubjectRelationships(ctx context.Context, req ValidatedLookupRequest, subject *v0.ObjectAndRelation) ([]*v0.ObjectAndRelation, error) {
+	// Only a forward query reads the objects in order of their IDs.
+	if req.isBounded() {
+		return cl.queryResolvedObjects(ctx, req, &v1_proto.RelationshipFilter{
+			ResourceType:     req.ObjectRelation.Namespace,
+			OptionalRelation: req.ObjectRelation.Relation,
+		}, []*v0.ObjectAndRelation{subject})
+	}
+
+	it, err := cl.ds.ReverseQueryTuples(
+		ctx,
+		tuple.UsersetToSubjectFilter(subject),
+		req.Revision,
+		options.WithResRelation(&options.ResourceRelation{
+			Namespace: req.ObjectRelation.Namespace,
+			Relation:  req.ObjectRelation.Relation,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	objects := tuple.NewONRSet()
+	for tpl := it.Next(); tpl != nil; tpl = it.Next() {
+		objects.Add(tpl.ObjectAndRelation)
+	}
+
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+
+	return objects.AsSlice(), nil
+}
+
+// queryResolvedObjects returns the objects of the relationships matching the filter whose
+// subjects are any of the usersets, as objects of the relation of the request. The
+// relationships of a bounded request are read in order of their resource IDs, after the ID
+// after which it resumes, until its limit is reached.
+func (cl *ConcurrentLookup) queryResolvedObjects(ctx context.Context, req ValidatedLookupRequest, filter *v1_proto.RelationshipFilter, usersets []*v0.ObjectAndRelation) ([]*v0.ObjectAndRelation, error) {
+	if len(usersets) == 0 {
+		return nil, nil
+	}
+
+	objects := tuple.NewONRSet()
+	afterResourceID := req.AfterResourceId
+	for {
+		queryOpts := []options.QueryOptionsOption{options.SetUsersets(usersets)}
+		if req.isBounded() {
+			queryOpts = append(queryOpts, options.WithOrder(options.ByResource), options.WithAfterResourceID(afterResourceID))
+		}
+
+		var limit uint64
+		if req.Limit != noLimit {
+			limit = uint64(req.Limit - objects.Length())
+			queryOpts = append(queryOpts, options.WithLimit(&limit))
+		}
+
+		it, err := cl.ds.QueryTuples(ctx, filter, req.Revision, queryOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		var read uint64
+		for tpl := it.Next(); tpl != nil; tpl = it.Next() {
+			read++
+			if tpl.ObjectAndRelation.Namespace != req.ObjectRelation.Namespace {
+				it.Close()
+				return nil, fmt.Errorf("got unexpected namespace")
+			}
+
+			objects.Add(&v0.ObjectAndRelation{
+				Namespace: req.ObjectRelation.Namespace,
+				ObjectId:  tpl.ObjectAndRelation.ObjectId,
+				Relation:  req.ObjectRelation.Relation,
+			})
+			afterResourceID = tpl.ObjectAndRelation.ObjectId
+		}
+
+		err = it.Err()
+		it.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		// Each object may have several of the relationships, so a query which read as many
+		// as its limit may have left objects unread, which the next query resumes from.
+		if req.Limit == noLimit || read < limit || objects.Length() >= req.Limit {
+			return objects.AsSlice(), nil
+		}
+	}
+}
+
 func (cl *ConcurrentLookup) dispatch(req ValidatedLookupRequest) ReduceableLookupFunc {
 	return func(ctx context.Context, resultChan chan<- LookupResult) {
 		log.Ctx(ctx).Trace().Object("dispatchLookup", req).Send()
@@ -631,7 +690,7 @@ func lookupOne(ctx context.Context, parentReq ValidatedLookupRequest, request Re
 	defer cancelFn()
 
 	resultChan := make(chan LookupResult, 1)
-	go request(childCtx, resultChan)
+	go runLookup(childCtx, request, resultChan)
 
 	select {
 	case result := <-resultChan:
@@ -655,7 +714,7 @@ func lookupAny(ctx context.Context, parentReq ValidatedLookupRequest, limit uint
 	for _, req := range requests {
 		resultChan := make(chan LookupResult, 1)
 		resultChans = append(resultChans, resultChan)
-		go req(childCtx, resultChan)
+		go runLookup(childCtx, req, resultChan)
 	}
 
 	objects := tuple.NewONRSet()
@@ -669,11 +728,9 @@ func lookupAny(ctx context.Context, parentReq ValidatedLookupRequest, limit uint
 				return lookupResultError(parentReq, result.Err, responseMetadata)
 			}
 
+			// Every request must complete, as any may find objects with lower IDs than
+			// those found so far.
 			objects.Update(result.Resp.ResolvedOnrs)
-
-			if objects.Length() >= limit {
-				return lookupResult(parentReq, limitedSlice(objects.AsSlice(), limit), responseMetadata)
-			}
 		case <-ctx.Done():
 			return lookupResultError(parentReq, NewRequestCanceledErr(), responseMetadata)
 		}
@@ -692,7 +749,7 @@ func lookupAll(ctx context.Context, parentReq ValidatedLookupRequest, limit uint
 	defer cancelFn()
 
 	for _, req := range requests {
-		go req(childCtx, resultChan)
+		go runLookup(childCtx, req, resultChan)
 	}
 
 	objSet := tuple.NewONRSet()
@@ -724,7 +781,7 @@ func lookupAll(ctx context.Context, parentReq ValidatedLookupRequest, limit uint
 		}
 	}
 
-	return lookupResult(parentReq, objSet.AsSlice(), responseMetadata)
+	return lookupResult(parentReq, limitedSlice(objSet.AsSlice(), limit), responseMetadata)
 }
 
 func lookupExclude(ctx context.Context, parentReq ValidatedLookupRequest, limit uint32, requests []ReduceableLookupFunc) LookupResult {
@@ -734,9 +791,9 @@ func lookupExclude(ctx context.Context, parentReq ValidatedLookupRequest, limit
 	baseChan := make(chan LookupResult, 1)
 	othersChan := make(chan LookupResult, len(requests)-1)
 
-	go requests[0](childCtx, baseChan)
+	go runLookup(childCtx, requests[0], baseChan)
 	for _, req := range requests[1:] {
-		go req(childCtx, othersChan)
+		go runLookup(childCtx, req, othersChan)
 	}
 
 	objSet := tuple.NewONRSet()
@@ -776,8 +833,12 @@ func returnResult(result LookupResult) ReduceableLookupFunc {
 	}
 }
 
+// limitedSlice returns the objects with the lowest IDs, up to the limit.
 func limitedSlice(slice []*v0.ObjectAndRelation, limit uint32) []*v0.ObjectAndRelation {
 	if len(slice) > int(limit) {
+		sort.Slice(slice, func(i, j int) bool {
+			return slice[i].ObjectId < slice[j].ObjectId
+		})
 		return slice[0:limit]
 	}
 
@@ -794,6 +855,22 @@ func lookupResult(req ValidatedLookupRequest, resolvedONRs []*v0.ObjectAndRelati
 	}
 }
 
+// resolvedAfter returns the objects whose IDs sort after the ID after which the request
+// resumes.
+func resolvedAfter(req ValidatedLookupRequest, resolved []*v0.ObjectAndRelation) []*v0.ObjectAndRelation {
+	if req.AfterResourceId == "" {
+		return resolved
+	}
+
+	filtered := make([]*v0.ObjectAndRelation, 0, len(resolved))
+	for _, onr := range resolved {
+		if onr.ObjectId > req.AfterResourceId {
+			filtered = append(filtered, onr)
+		}
+	}
+	return filtered
+}
+
 func lookupResultError(req ValidatedLookupRequest, err error, subProblemMetadata *v1.ResponseMeta) LookupResult {
 	return LookupResult{
 		&v1.DispatchLookupResponse{
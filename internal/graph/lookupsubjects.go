@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"context"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/membership"
+	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+)
+
+// ValidatedLookupSubjectsRequest represents a request to look up the subjects of an object
+// and relation, after it has been validated and parsed for internal consumption.
+type ValidatedLookupSubjectsRequest struct {
+	// ObjectAndRelation is the object and relation (or permission) whose subjects are
+	// looked up.
+	ObjectAndRelation *v0.ObjectAndRelation
+
+	// SubjectRelation is the type, and relation, of the subjects to find. Subjects which
+	// are objects rather than sets of subjects have the Ellipsis relation.
+	SubjectRelation *v0.RelationReference
+
+	// Metadata is the metadata of the request, as for the other dispatched requests.
+	Metadata *v1.ResolverMeta
+
+	Revision decimal.Decimal
+}
+
+// LookupSubjectsResult is the result of looking up the subjects of an object and relation.
+type LookupSubjectsResult struct {
+	// Subjects are the subjects found. A wildcard subject stands for every subject of its
+	// type, apart from those it excludes.
+	Subjects []membership.FoundSubject
+
+	Metadata *v1.ResponseMeta
+}
+
+// LookupSubjects finds every subject of the requested type and relation which has the
+// relation to the object. It walks the graph down from the object, by recursively expanding
+// the relation through the dispatcher, and then reduces the expansion to the subjects it
+// reaches, applying the intersections and exclusions of the schema along the way.
+func LookupSubjects(ctx context.Context, d dispatch.Expand, req ValidatedLookupSubjectsRequest) (LookupSubjectsResult, error) {
+	log.Ctx(ctx).Trace().
+		Str("object", req.ObjectAndRelation.String()).
+		Str("subjectRelation", req.SubjectRelation.String()).
+		Msg("lookup subjects")
+
+	resp, err := d.DispatchExpand(ctx, &v1.DispatchExpandRequest{
+		Metadata:          req.Metadata,
+		ObjectAndRelation: req.ObjectAndRelation,
+		ExpansionMode:     v1.DispatchExpandRequest_RECURSIVE,
+	})
+	if err != nil {
+		return LookupSubjectsResult{Metadata: resp.GetMetadata()}, err
+	}
+
+	found, err := membership.AccessibleExpansionSubjects(resp.TreeNode)
+	if err != nil {
+		return LookupSubjectsResult{Metadata: resp.Metadata}, err
+	}
+
+	var subjects []membership.FoundSubject
+	for _, subject := range found.WithType(req.SubjectRelation.Namespace) {
+		if subject.Subject().Relation == req.SubjectRelation.Relation {
+			subjects = append(subjects, subject)
+		}
+	}
+
+	return LookupSubjectsResult{subjects, resp.Metadata}, nil
+}
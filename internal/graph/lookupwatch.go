@@ -0,0 +1,240 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/namespace"
+	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+	"github.com/authzed/spicedb/internal/redact"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// ValidatedPermissionChangesRequest represents a request to compute how the relationship
+// changes of a revision changed which subjects have a permission, after it has been
+// validated and parsed for internal consumption.
+type ValidatedPermissionChangesRequest struct {
+	// Permission is the type of the resources, and the relation (or permission), whose
+	// subjects are compared.
+	Permission *v0.RelationReference
+
+	// SubjectRelation is the type, and relation, of the subjects compared. Subjects which
+	// are objects rather than sets of subjects have the Ellipsis relation.
+	SubjectRelation *v0.RelationReference
+
+	// OptionalResourceID limits the comparison to the resource with the ID, if set.
+	OptionalResourceID string
+
+	// Changes are the relationship changes made at the revision.
+	Changes []*v0.RelationTupleUpdate
+
+	// PreviousRevision is the revision before the changes, and Revision the revision at
+	// which they were made.
+	PreviousRevision decimal.Decimal
+	Revision         decimal.Decimal
+
+	// DepthRemaining is the depth of the requests dispatched at each revision.
+	DepthRemaining uint32
+}
+
+// PermissionChangesResult is the result of computing the permission changes of a revision.
+type PermissionChangesResult struct {
+	// Changes are the subjects which gained the permission on a resource, as touches, and
+	// those which lost it, as deletes, each as a tuple from the resource, by the permission,
+	// to the subject. A wildcard subject stands for every subject of its type, apart from
+	// those excluded from it, whose changes are reported separately.
+	Changes []*v0.RelationTupleUpdate
+
+	Metadata *v1.ResponseMeta
+}
+
+// PermissionChanges computes which subjects gained or lost the permission on which
+// resources through the relationship changes of a revision. The resources which could be
+// affected are found by looking up, at both revisions, the resources whose permission
+// reaches the objects whose relationships changed through any of their relations. Their
+// subjects are then looked up at both revisions and compared.
+//
+// The work done grows with the number of changed objects, the relations of their types and
+// the resources reaching them, so it is meant for permissions whose resources are reached
+// by a bounded number of objects.
+func PermissionChanges(ctx context.Context, d dispatch.Dispatcher, nsm namespace.Manager, req ValidatedPermissionChangesRequest) (PermissionChangesResult, error) {
+	log.Ctx(ctx).Trace().
+		Str("permission", req.Permission.String()).
+		Str("subjectRelation", req.SubjectRelation.String()).
+		Str("revision", req.Revision.String()).
+		Msg("permission changes")
+
+	metadata := emptyMetadata
+
+	resources := tuple.NewONRSet()
+	if req.OptionalResourceID != "" {
+		resources.Add(&v0.ObjectAndRelation{
+			Namespace: req.Permission.Namespace,
+			ObjectId:  req.OptionalResourceID,
+			Relation:  req.Permission.Relation,
+		})
+	} else {
+		for _, revision := range []decimal.Decimal{req.PreviousRevision, req.Revision} {
+			found, lookupMetadata, err := affectedResources(ctx, d, nsm, req, revision)
+			metadata = combineOptionalMetadata(metadata, lookupMetadata)
+			if err != nil {
+				return PermissionChangesResult{Metadata: metadata}, err
+			}
+			resources.UpdateFrom(found)
+		}
+	}
+
+	var changes []*v0.RelationTupleUpdate
+	for _, resource := range resources.AsSlice() {
+		previous, previousMetadata, err := lookupAccess(ctx, d, req, resource, req.PreviousRevision)
+		metadata = combineOptionalMetadata(metadata, previousMetadata)
+		if err != nil {
+			return PermissionChangesResult{Metadata: metadata}, err
+		}
+
+		current, currentMetadata, err := lookupAccess(ctx, d, req, resource, req.Revision)
+		metadata = combineOptionalMetadata(metadata, currentMetadata)
+		if err != nil {
+			return PermissionChangesResult{Metadata: metadata}, err
+		}
+
+		changes = append(changes, previous.changesTo(current, resource)...)
+	}
+
+	return PermissionChangesResult{changes, metadata}, nil
+}
+
+// affectedResources looks up the resources whose permission reaches, at the revision, the
+// objects whose relationships changed, through any of the relations of their types.
+func affectedResources(ctx context.Context, d dispatch.Lookup, nsm namespace.Manager, req ValidatedPermissionChangesRequest, revision decimal.Decimal) (*tuple.ONRSet, *v1.ResponseMeta, error) {
+	metadata := emptyMetadata
+	resources := tuple.NewONRSet()
+
+	changedObjects := make(map[string]*v0.ObjectAndRelation, len(req.Changes))
+	for _, change := range req.Changes {
+		onr := change.Tuple.ObjectAndRelation
+		changedObjects[tuple.StringONR(&v0.ObjectAndRelation{Namespace: onr.Namespace, ObjectId: onr.ObjectId})] = onr
+	}
+
+	for _, object := range changedObjects {
+		nsdef, err := nsm.ReadNamespace(ctx, object.Namespace, revision)
+		if err != nil {
+			if errors.As(err, &namespace.ErrNamespaceNotFound{}) {
+				// The type of the object did not exist at the revision, so nothing reached it.
+				continue
+			}
+			return nil, metadata, err
+		}
+
+		for _, relation := range nsdef.Relation {
+			resp, err := d.DispatchLookup(ctx, &v1.DispatchLookupRequest{
+				Metadata: &v1.ResolverMeta{
+					AtRevision:     revision.String(),
+					DepthRemaining: req.DepthRemaining,
+				},
+				ObjectRelation: req.Permission,
+				Subject: &v0.ObjectAndRelation{
+					Namespace: object.Namespace,
+					ObjectId:  object.ObjectId,
+					Relation:  relation.Name,
+				},
+				Limit: ^uint32(0),
+			})
+			metadata = combineOptionalMetadata(metadata, resp.GetMetadata())
+			if err != nil {
+				return nil, metadata, fmt.Errorf("failed to look up resources reaching %s#%s: %w", redact.ONR(object), redact.Relation(relation.Name), err)
+			}
+			for _, resource := range resp.ResolvedOnrs {
+				resources.Add(&v0.ObjectAndRelation{
+					Namespace: resource.Namespace,
+					ObjectId:  resource.ObjectId,
+					Relation:  req.Permission.Relation,
+				})
+			}
+		}
+	}
+
+	return resources, metadata, nil
+}
+
+// combineOptionalMetadata combines the metadata of a response with the existing metadata,
+// unless the response has none, as when its request failed before dispatching.
+func combineOptionalMetadata(existing *v1.ResponseMeta, responseMetadata *v1.ResponseMeta) *v1.ResponseMeta {
+	if responseMetadata == nil {
+		return existing
+	}
+	return combineResponseMetadata(existing, responseMetadata)
+}
+
+// access is the set of subjects which have a permission on a resource.
+type access struct {
+	subjects *tuple.ONRSet
+	wildcard *v0.ObjectAndRelation
+	excluded *tuple.ONRSet
+}
+
+func lookupAccess(ctx context.Context, d dispatch.Expand, req ValidatedPermissionChangesRequest, resource *v0.ObjectAndRelation, revision decimal.Decimal) (access, *v1.ResponseMeta, error) {
+	result, err := LookupSubjects(ctx, d, ValidatedLookupSubjectsRequest{
+		ObjectAndRelation: resource,
+		SubjectRelation:   req.SubjectRelation,
+		Metadata: &v1.ResolverMeta{
+			AtRevision:     revision.String(),
+			DepthRemaining: req.DepthRemaining,
+		},
+		Revision: revision,
+	})
+	if err != nil {
+		return access{}, result.Metadata, err
+	}
+
+	found := access{subjects: tuple.NewONRSet(), excluded: tuple.NewONRSet()}
+	for _, subject := range result.Subjects {
+		if excluded, ok := subject.ExcludedSubjectsFromWildcard(); ok {
+			found.wildcard = subject.Subject()
+			found.excluded.Update(excluded)
+			continue
+		}
+		found.subjects.Add(subject.Subject())
+	}
+	return found, result.Metadata, nil
+}
+
+func (a access) has(subject *v0.ObjectAndRelation) bool {
+	return a.subjects.Has(subject) || (a.wildcard != nil && !a.excluded.Has(subject))
+}
+
+// changesTo returns the changes from the subjects with access to those of the other access.
+func (a access) changesTo(other access, resource *v0.ObjectAndRelation) []*v0.RelationTupleUpdate {
+	var changes []*v0.RelationTupleUpdate
+	change := func(subject *v0.ObjectAndRelation, before, after bool) {
+		tpl := &v0.RelationTuple{
+			ObjectAndRelation: resource,
+			User:              &v0.User{UserOneof: &v0.User_Userset{Userset: subject}},
+		}
+		switch {
+		case !before && after:
+			changes = append(changes, tuple.Touch(tpl))
+		case before && !after:
+			changes = append(changes, tuple.Delete(tpl))
+		}
+	}
+
+	switch {
+	case a.wildcard == nil && other.wildcard != nil:
+		change(other.wildcard, false, true)
+	case a.wildcard != nil && other.wildcard == nil:
+		change(a.wildcard, true, false)
+	}
+
+	candidates := a.subjects.Union(other.subjects).Union(a.excluded).Union(other.excluded)
+	for _, subject := range candidates.AsSlice() {
+		change(subject, a.has(subject), other.has(subject))
+	}
+	return changes
+}
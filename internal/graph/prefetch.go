@@ -0,0 +1,192 @@
+package graph
+
+import (
+	"context"
+	"sort"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1_proto "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/shopspring/decimal"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+// PlanPrefetch returns the relations of the namespace whose tuples may be read for an object
+// when checking the given relation on it, without following any relationship to another
+// object. This covers the relation itself when it has direct tuples, the relations it is
+// computed from, and the tuplesets walked by arrows (`parent->view`).
+func PlanPrefetch(def *v0.NamespaceDefinition, relationName string) []string {
+	relations := make(map[string]*v0.Relation, len(def.Relation))
+	for _, relation := range def.Relation {
+		relations[relation.Name] = relation
+	}
+
+	planned := make(map[string]struct{})
+	visited := make(map[string]struct{})
+
+	var visitRelation func(name string)
+	var visitRewrite func(name string, rewrite *v0.UsersetRewrite)
+
+	visitRelation = func(name string) {
+		if _, ok := visited[name]; ok {
+			return
+		}
+		visited[name] = struct{}{}
+
+		relation, ok := relations[name]
+		if !ok {
+			return
+		}
+		if relation.UsersetRewrite == nil {
+			planned[name] = struct{}{}
+			return
+		}
+		visitRewrite(name, relation.UsersetRewrite)
+	}
+
+	visitRewrite = func(name string, rewrite *v0.UsersetRewrite) {
+		var setOp *v0.SetOperation
+		switch rw := rewrite.RewriteOperation.(type) {
+		case *v0.UsersetRewrite_Union:
+			setOp = rw.Union
+		case *v0.UsersetRewrite_Intersection:
+			setOp = rw.Intersection
+		case *v0.UsersetRewrite_Exclusion:
+			setOp = rw.Exclusion
+		default:
+			return
+		}
+
+		for _, child := range setOp.Child {
+			switch typed := child.ChildType.(type) {
+			case *v0.SetOperation_Child_XThis:
+				planned[name] = struct{}{}
+			case *v0.SetOperation_Child_ComputedUserset:
+				if typed.ComputedUserset.Object == v0.ComputedUserset_TUPLE_OBJECT {
+					visitRelation(typed.ComputedUserset.Relation)
+				}
+			case *v0.SetOperation_Child_UsersetRewrite:
+				visitRewrite(name, typed.UsersetRewrite)
+			case *v0.SetOperation_Child_TupleToUserset:
+				if _, ok := relations[typed.TupleToUserset.Tupleset.Relation]; ok {
+					planned[typed.TupleToUserset.Tupleset.Relation] = struct{}{}
+				}
+			}
+		}
+	}
+
+	visitRelation(relationName)
+
+	plan := make([]string, 0, len(planned))
+	for name := range planned {
+		plan = append(plan, name)
+	}
+	sort.Strings(plan)
+	return plan
+}
+
+// prefetchedTuples holds the tuples of the planned relations of a single object, loaded
+// at a single revision.
+type prefetchedTuples struct {
+	def        *v0.NamespaceDefinition
+	objectID   string
+	revision   decimal.Decimal
+	byRelation map[string][]*v0.RelationTuple
+}
+
+type prefetchedKey struct{}
+
+func contextWithPrefetched(ctx context.Context, prefetched *prefetchedTuples) context.Context {
+	return context.WithValue(ctx, prefetchedKey{}, prefetched)
+}
+
+// prefetchedFor returns the prefetched tuples carried by the context if they were loaded
+// for the given object at the given revision, or nil otherwise.
+func prefetchedFor(ctx context.Context, onr *v0.ObjectAndRelation, revision decimal.Decimal) *prefetchedTuples {
+	prefetched, ok := ctx.Value(prefetchedKey{}).(*prefetchedTuples)
+	if !ok ||
+		prefetched.def.Name != onr.Namespace ||
+		prefetched.objectID != onr.ObjectId ||
+		!prefetched.revision.Equal(revision) {
+		return nil
+	}
+	return prefetched
+}
+
+// relation returns the definition of the relation with the given name, or nil if the
+// namespace has no such relation.
+func (p *prefetchedTuples) relation(name string) *v0.Relation {
+	for _, relation := range p.def.Relation {
+		if relation.Name == name {
+			return relation
+		}
+	}
+	return nil
+}
+
+// prefetch loads, in a single query, the tuples of every relation which the check of the
+// given relation may read on the object of the request, returning a context which carries
+// them. If fewer than two relations would be read, nothing is loaded.
+func (cc *ConcurrentChecker) prefetch(ctx context.Context, req ValidatedCheckRequest, relation *v0.Relation) (context.Context, error) {
+	if relation.UsersetRewrite == nil || prefetchedFor(ctx, req.ObjectAndRelation, req.Revision) != nil {
+		return ctx, nil
+	}
+
+	def, err := cc.nsm.ReadNamespace(ctx, req.ObjectAndRelation.Namespace, req.Revision)
+	if err != nil {
+		return ctx, err
+	}
+
+	plan := PlanPrefetch(def, relation.Name)
+	if len(plan) < 2 {
+		return ctx, nil
+	}
+
+	prefetched := &prefetchedTuples{
+		def:        def,
+		objectID:   req.ObjectAndRelation.ObjectId,
+		revision:   req.Revision,
+		byRelation: make(map[string][]*v0.RelationTuple, len(plan)),
+	}
+	for _, name := range plan {
+		prefetched.byRelation[name] = nil
+	}
+
+	// The filter cannot name more than one relation, so all of the tuples of the object are
+	// read and those of relations outside of the plan are discarded.
+	it, err := cc.ds.QueryTuples(ctx, &v1_proto.RelationshipFilter{
+		ResourceType:       req.ObjectAndRelation.Namespace,
+		OptionalResourceId: req.ObjectAndRelation.ObjectId,
+	}, req.Revision)
+	if err != nil {
+		return ctx, NewCheckFailureErr(err)
+	}
+	defer it.Close()
+
+	for tpl := it.Next(); tpl != nil; tpl = it.Next() {
+		if tuples, ok := prefetched.byRelation[tpl.ObjectAndRelation.Relation]; ok {
+			prefetched.byRelation[tpl.ObjectAndRelation.Relation] = append(tuples, tpl)
+		}
+	}
+	if it.Err() != nil {
+		return ctx, NewCheckFailureErr(it.Err())
+	}
+
+	return contextWithPrefetched(ctx, prefetched), nil
+}
+
+// queryRelation returns an iterator over the tuples of the given relation on the object of
+// the request, serving them from the prefetched tuples when they were loaded.
+func (cc *ConcurrentChecker) queryRelation(ctx context.Context, req ValidatedCheckRequest, relationName string) (datastore.TupleIterator, error) {
+	if prefetched := prefetchedFor(ctx, req.ObjectAndRelation, req.Revision); prefetched != nil {
+		if tuples, ok := prefetched.byRelation[relationName]; ok {
+			return datastore.NewSliceTupleIterator(tuples), nil
+		}
+	}
+
+	return cc.ds.QueryTuples(ctx, &v1_proto.RelationshipFilter{
+		ResourceType:       req.ObjectAndRelation.Namespace,
+		OptionalResourceId: req.ObjectAndRelation.ObjectId,
+		OptionalRelation:   relationName,
+	}, req.Revision)
+}
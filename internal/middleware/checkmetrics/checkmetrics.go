@@ -0,0 +1,136 @@
+// Package checkmetrics records the number, latency and results of checks by the resource
+// type and permission checked, so that the object types which drive load and the
+// permissions which are slow to check can be found without tracing.
+package checkmetrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+)
+
+// OtherLabel is the label under which the checks of the resource types and permissions past
+// the maximum number of series are recorded.
+const OtherLabel = "other"
+
+const (
+	resultGranted = "granted"
+	resultDenied  = "denied"
+	resultError   = "error"
+)
+
+var checksCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "services",
+	Name:      "permission_checks_total",
+	Help:      "number of checks, by resource type, permission and result: granted, denied or error.",
+}, []string{"resource_type", "permission", "result"})
+
+var checkLatencyHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spicedb",
+	Subsystem: "services",
+	Name:      "permission_check_duration_seconds",
+	Help:      "distribution in seconds of the time taken by checks, by resource type and permission.",
+	Buckets:   []float64{.001, .003, .006, .01, .02, .05, .1, .25, .5, 1, 2.5, 5},
+}, []string{"resource_type", "permission"})
+
+type labelPair struct {
+	resourceType string
+	permission   string
+}
+
+// Recorder records the metrics of checks, labeled by their resource type and permission, up
+// to a maximum number of distinct pairs of labels. Checks of further pairs are recorded
+// under OtherLabel, bounding the cardinality of the metrics.
+type Recorder struct {
+	sync.Mutex
+	maxSeries int
+	series    map[labelPair]struct{}
+}
+
+// NewRecorder creates a new Recorder which labels the metrics of up to maxSeries pairs of
+// resource type and permission.
+func NewRecorder(maxSeries int) *Recorder {
+	return &Recorder{
+		maxSeries: maxSeries,
+		series:    make(map[labelPair]struct{}, maxSeries),
+	}
+}
+
+// labels returns the labels under which checks of the permission of the resource type
+// are recorded.
+func (r *Recorder) labels(resourceType, permission string) labelPair {
+	pair := labelPair{resourceType, permission}
+
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.series[pair]; ok {
+		return pair
+	}
+	if len(r.series) >= r.maxSeries {
+		return labelPair{OtherLabel, OtherLabel}
+	}
+	r.series[pair] = struct{}{}
+	return pair
+}
+
+// Record records a check of the permission of the resource type, which took the duration
+// and was granted or not, unless it failed with the error.
+func (r *Recorder) Record(resourceType, permission string, granted bool, err error, duration time.Duration) {
+	labels := r.labels(resourceType, permission)
+
+	result := resultDenied
+	switch {
+	case err != nil:
+		result = resultError
+	case granted:
+		result = resultGranted
+	}
+
+	checksCounter.WithLabelValues(labels.resourceType, labels.permission, result).Inc()
+	checkLatencyHistogram.WithLabelValues(labels.resourceType, labels.permission).Observe(duration.Seconds())
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that records the metrics
+// of the checks of the v0 and v1 APIs with the recorder, if any.
+func UnaryServerInterceptor(r *Recorder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if r == nil {
+			return handler(ctx, req)
+		}
+
+		var resourceType, permission string
+		switch typed := req.(type) {
+		case *v1.CheckPermissionRequest:
+			resourceType, permission = typed.Resource.GetObjectType(), typed.Permission
+		case *v0.CheckRequest:
+			resourceType, permission = typed.TestUserset.GetNamespace(), typed.TestUserset.GetRelation()
+		case *v0.ContentChangeCheckRequest:
+			resourceType, permission = typed.TestUserset.GetNamespace(), typed.TestUserset.GetRelation()
+		default:
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		r.Record(resourceType, permission, isGranted(resp), err, time.Since(start))
+		return resp, err
+	}
+}
+
+func isGranted(resp interface{}) bool {
+	switch typed := resp.(type) {
+	case *v1.CheckPermissionResponse:
+		return typed.GetPermissionship() == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+	case *v0.CheckResponse:
+		return typed.GetIsMember()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,66 @@
+package checkmetrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestRecorderBoundsSeries(t *testing.T) {
+	require := require.New(t)
+
+	r := NewRecorder(2)
+	require.Equal(labelPair{"document", "view"}, r.labels("document", "view"))
+	require.Equal(labelPair{"document", "edit"}, r.labels("document", "edit"))
+	require.Equal(labelPair{OtherLabel, OtherLabel}, r.labels("folder", "view"))
+	require.Equal(labelPair{"document", "view"}, r.labels("document", "view"))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	require := require.New(t)
+
+	interceptor := UnaryServerInterceptor(NewRecorder(10))
+	info := &grpc.UnaryServerInfo{FullMethod: "/authzed.api.v1.PermissionsService/CheckPermission"}
+	req := &v1.CheckPermissionRequest{
+		Resource:   &v1.ObjectReference{ObjectType: "metricsdoc", ObjectId: "first"},
+		Permission: "view",
+	}
+
+	results := []struct {
+		permissionship v1.CheckPermissionResponse_Permissionship
+		err            error
+	}{
+		{v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, nil},
+		{v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, nil},
+		{v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION, nil},
+		{v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED, errors.New("check failed")},
+	}
+	for _, result := range results {
+		result := result
+		_, err := interceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			time.Sleep(1 * time.Millisecond)
+			if result.err != nil {
+				return nil, result.err
+			}
+			return &v1.CheckPermissionResponse{Permissionship: result.permissionship}, nil
+		})
+		require.Equal(result.err, err)
+	}
+
+	require.Equal(2.0, testutil.ToFloat64(checksCounter.WithLabelValues("metricsdoc", "view", resultGranted)))
+	require.Equal(1.0, testutil.ToFloat64(checksCounter.WithLabelValues("metricsdoc", "view", resultDenied)))
+	require.Equal(1.0, testutil.ToFloat64(checksCounter.WithLabelValues("metricsdoc", "view", resultError)))
+
+	// Other requests are not recorded.
+	_, err := interceptor(context.Background(), &v1.ReadRelationshipsRequest{}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(err)
+	require.Equal(1, testutil.CollectAndCount(checkLatencyHistogram))
+}
@@ -1,15 +1,29 @@
+// Package consistency exchanges the Consistency block of each v1 request for the revision
+// of the datastore at which the request is served.
+//
+// The interceptors call AddRevisionToContext, which resolves the requirement to a revision:
+// minimize latency (or no block) uses the quantized OptimizedRevision, at least as fresh
+// uses the later of that revision and the ZedToken, at exact snapshot uses the ZedToken's
+// revision once CheckRevision has accepted it, and fully consistent uses HeadRevision.
+// Services read the revision back with MustRevisionFromContext and pass it as the
+// AtRevision of every dispatch. The dispatch cache keys include AtRevision, so requests
+// served at different revisions never share cached results.
 package consistency
 
 import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/internal/datastore"
@@ -17,13 +31,64 @@ import (
 	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
+var revisionSourceCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "services",
+	Name:      "consistency_revisions_total",
+	Help:      "number of requests by the source of the revision at which they were served.",
+}, []string{"source"})
+
+const (
+	// sourceQuantized is used for revisions picked from the datastore's quantization window.
+	sourceQuantized = "quantized"
+
+	// sourceHead is used for revisions at the head of the datastore.
+	sourceHead = "head"
+
+	// sourceRequested is used for revisions which were specified by the caller's ZedToken.
+	sourceRequested = "requested"
+
+	// sourceStandby is used for stale revisions offered while the datastore is unavailable.
+	sourceStandby = "standby"
+
+	// sourceWaited is used for quantized revisions which were waited for to reach the
+	// revision specified by the caller's ZedToken.
+	sourceWaited = "waited"
+)
+
+// BarrierMetadataKey is the request metadata key in which a caller can pass a ZedToken,
+// causing the request to wait until the quantized revision of the datastore has reached
+// the token's revision before it is served. Subsequent minimize latency reads are then
+// guaranteed to observe all writes made at or before the token.
+const BarrierMetadataKey = "io.spicedb.requestmeta.barrier"
+
+// StaleMetadataKey is the response header which is set, to the age of the revision at which
+// the request was served, when a minimize latency request was served at a stale revision
+// because the datastore was unavailable. Such requests can only be answered from cache.
+const StaleMetadataKey = "io.spicedb.respmeta.stale"
+
+// MaxWaitMetadataKey is the request metadata key in which a caller making an at least as
+// fresh request can pass a duration, e.g. "250ms", for which to wait for the quantized
+// revision of the datastore to reach the revision of the request's ZedToken, rather than
+// immediately reading at that revision. Requests made right after a write then share the
+// cached results of the quantized revision instead of each computing them anew. Should the
+// wait elapse, the request is served at the revision of the ZedToken as usual.
+const MaxWaitMetadataKey = "io.spicedb.requestmeta.max-wait"
+
+// MaxWaitLimit is the longest a request waits for the quantized revision, whatever the
+// duration it requested.
+const MaxWaitLimit = 5 * time.Second
+
 type hasConsistency interface {
 	GetConsistency() *v1.Consistency
 }
 
 type ctxKeyType string
 
-var revisionKey ctxKeyType = "revision"
+var (
+	revisionKey ctxKeyType = "revision"
+	staleKey    ctxKeyType = "stale"
+)
 
 var errInvalidZedToken = errors.New("invalid revision requested")
 
@@ -45,7 +110,35 @@ func MustRevisionFromContext(ctx context.Context) (decimal.Decimal, *v1.ZedToken
 		panic("consistency middleware did not inject revision")
 	}
 
-	return *rev, zedtoken.NewFromRevision(*rev)
+	return *rev, NewZedToken(ctx, *rev)
+}
+
+// IsRevisionStale returns whether the selected revision is a stale revision offered while
+// the datastore was unavailable, at which requests can only be answered from cache.
+func IsRevisionStale(ctx context.Context) bool {
+	stale, _ := ctx.Value(staleKey).(bool)
+	return stale
+}
+
+// NewZedToken mints a ZedToken for the revision, bound to the datastore whose unique ID is
+// carried by the context.
+func NewZedToken(ctx context.Context, revision decimal.Decimal) *v1.ZedToken {
+	return zedtoken.NewFromRevisionForDatastore(revision, datastore.UniqueIDFromContext(ctx))
+}
+
+// DecodeRevision extracts the revision from a ZedToken which was passed to the request, which
+// must have been minted against the datastore whose unique ID is carried by the context.
+func DecodeRevision(ctx context.Context, encoded *v1.ZedToken) (decimal.Decimal, error) {
+	revision, err := zedtoken.DecodeRevisionForDatastore(encoded, datastore.UniqueIDFromContext(ctx))
+	var foreignErr zedtoken.ErrForeignDatastore
+	switch {
+	case errors.As(err, &foreignErr):
+		return decimal.Zero, serviceerrors.NewForeignZedTokenErr(foreignErr)
+	case err != nil:
+		return decimal.Zero, errInvalidZedToken
+	default:
+		return revision, nil
+	}
 }
 
 // AddRevisionToContext adds a revision to the given context, based on the consistency block found
@@ -56,40 +149,61 @@ func AddRevisionToContext(ctx context.Context, req interface{}, ds datastore.Dat
 		return ctx, nil
 	}
 
+	if err := waitForBarrier(ctx, ds); err != nil {
+		return nil, err
+	}
+
 	var revision decimal.Decimal
 	consistency := reqWithConsistency.GetConsistency()
 
 	switch {
 	case consistency == nil || consistency.GetMinimizeLatency():
-		// Minimize Latency: Use the datastore's current revision, whatever it may be.
+		// Minimize Latency: Use the datastore's current revision, whatever it may be. Requests
+		// made within the same quantization window share a revision, and therefore the
+		// cached results of their dispatches.
 		databaseRev, err := ds.OptimizedRevision(ctx)
-		if err != nil {
+		var staleErr datastore.ErrStaleRevision
+		switch {
+		case errors.As(err, &staleErr):
+			revision = staleErr.StaleRevision()
+			markStale(ctx, staleErr)
+			ctx = context.WithValue(ctx, staleKey, true)
+			revisionSourceCounter.WithLabelValues(sourceStandby).Inc()
+
+		case err != nil:
 			return nil, rewriteDatastoreError(ctx, err)
+
+		default:
+			revision = databaseRev
+			revisionSourceCounter.WithLabelValues(sourceQuantized).Inc()
 		}
-		revision = databaseRev
 
 	case consistency.GetFullyConsistent():
-		// Fully Consistent: Use the datastore's synchronized revision.
+		// Fully Consistent: Use the datastore's synchronized revision, which sees every write
+		// committed before the request was received.
 		databaseRev, err := ds.HeadRevision(ctx)
 		if err != nil {
 			return nil, rewriteDatastoreError(ctx, err)
 		}
 		revision = databaseRev
+		revisionSourceCounter.WithLabelValues(sourceHead).Inc()
 
 	case consistency.GetAtLeastAsFresh() != nil:
 		// At least as fresh as: Pick one of the datastore's revision and that specified, which
 		// ever is later.
-		picked, err := pickBestRevision(ctx, consistency.GetAtLeastAsFresh(), ds)
+		picked, source, err := pickBestRevision(ctx, consistency.GetAtLeastAsFresh(), ds)
 		if err != nil {
 			return nil, rewriteDatastoreError(ctx, err)
 		}
 		revision = picked
+		revisionSourceCounter.WithLabelValues(source).Inc()
 
 	case consistency.GetAtExactSnapshot() != nil:
-		// Exact snapshot: Use the revision as encoded in the zed token.
-		requestedRev, err := zedtoken.DecodeRevision(consistency.GetAtExactSnapshot())
+		// Exact snapshot: Use the revision as encoded in the zed token, provided it has not
+		// yet been garbage collected.
+		requestedRev, err := DecodeRevision(ctx, consistency.GetAtExactSnapshot())
 		if err != nil {
-			return nil, errInvalidZedToken
+			return nil, err
 		}
 
 		err = ds.CheckRevision(ctx, requestedRev)
@@ -98,6 +212,7 @@ func AddRevisionToContext(ctx context.Context, req interface{}, ds datastore.Dat
 		}
 
 		revision = requestedRev
+		revisionSourceCounter.WithLabelValues(sourceRequested).Inc()
 
 	default:
 		return nil, fmt.Errorf("missing handling of consistency case in %v", consistency)
@@ -153,39 +268,130 @@ func (s *recvWrapper) RecvMsg(m interface{}) error {
 	return nil
 }
 
-func pickBestRevision(ctx context.Context, requested *v1.ZedToken, ds datastore.Datastore) (decimal.Decimal, error) {
+// waitForBarrier blocks until the datastore has reached the revision in the barrier
+// metadata of the request, if any.
+func waitForBarrier(ctx context.Context, ds datastore.Datastore) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	values := md.Get(BarrierMetadataKey)
+	if len(values) == 0 {
+		return nil
+	}
+
+	barrierRev, err := DecodeRevision(ctx, &v1.ZedToken{Token: values[0]})
+	if err != nil {
+		return err
+	}
+
+	if err := datastore.WaitForRevision(ctx, ds, barrierRev, datastore.DefaultBarrierPollInterval); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return status.FromContextError(err).Err()
+		}
+		return rewriteDatastoreError(ctx, err)
+	}
+	return nil
+}
+
+// markStale reports to the caller that the request is being served at a stale revision.
+func markStale(ctx context.Context, staleErr datastore.ErrStaleRevision) {
+	err := grpc.SetHeader(ctx, metadata.Pairs(StaleMetadataKey, staleErr.Age().String()))
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("unable to mark response as stale")
+	}
+}
+
+func pickBestRevision(ctx context.Context, requested *v1.ZedToken, ds datastore.Datastore) (decimal.Decimal, string, error) {
 	// Calculate a revision as we see fit
 	databaseRev, err := ds.OptimizedRevision(ctx)
 	if err != nil {
-		return decimal.Zero, err
+		return decimal.Zero, "", err
 	}
 
 	if requested != nil {
-		requestedRev, err := zedtoken.DecodeRevision(requested)
+		requestedRev, err := DecodeRevision(ctx, requested)
+		if err != nil {
+			return decimal.Zero, "", err
+		}
+
+		maxWait, err := maxWaitFromMetadata(ctx)
 		if err != nil {
-			return decimal.Zero, errInvalidZedToken
+			return decimal.Zero, "", err
 		}
 
 		if requestedRev.GreaterThan(databaseRev) {
-			return requestedRev, nil
+			if maxWait == 0 {
+				return requestedRev, sourceRequested, nil
+			}
+
+			waitCtx, cancel := context.WithTimeout(ctx, maxWait)
+			err = datastore.WaitForRevision(waitCtx, ds, requestedRev, datastore.DefaultBarrierPollInterval)
+			cancel()
+			switch {
+			case ctx.Err() != nil:
+				return decimal.Zero, "", status.FromContextError(ctx.Err()).Err()
+			case err != nil:
+				// The wait elapsed, or the revision cannot be reached by waiting.
+				log.Ctx(ctx).Debug().Err(err).Stringer("revision", requestedRev).Msg("serving at requested revision")
+				return requestedRev, sourceRequested, nil
+			}
+
+			waitedRev, err := ds.OptimizedRevision(ctx)
+			if err != nil {
+				return decimal.Zero, "", err
+			}
+			return waitedRev, sourceWaited, nil
 		}
-		return databaseRev, nil
+		return databaseRev, sourceQuantized, nil
+	}
+
+	return databaseRev, sourceQuantized, nil
+}
+
+// maxWaitFromMetadata returns the amount of time, in the max wait metadata of the request,
+// for which to wait for the quantized revision, or zero if it was not specified.
+func maxWaitFromMetadata(ctx context.Context) (time.Duration, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, nil
 	}
 
-	return databaseRev, nil
+	values := md.Get(MaxWaitMetadataKey)
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	maxWait, err := time.ParseDuration(values[0])
+	if err != nil || maxWait < 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid %s: %s", MaxWaitMetadataKey, values[0])
+	}
+	if maxWait > MaxWaitLimit {
+		maxWait = MaxWaitLimit
+	}
+	return maxWait, nil
 }
 
 func rewriteDatastoreError(ctx context.Context, err error) error {
+	var revisionErr datastore.ErrInvalidRevision
+
 	switch {
 	case errors.As(err, &datastore.ErrPreconditionFailed{}):
 		return status.Errorf(codes.FailedPrecondition, "failed precondition: %s", err)
 
-	case errors.As(err, &datastore.ErrInvalidRevision{}):
-		return status.Errorf(codes.OutOfRange, "invalid zookie: %s", err)
+	case errors.As(err, &revisionErr):
+		return serviceerrors.NewInvalidRevisionErr(ctx, revisionErr)
 
 	case errors.As(err, &datastore.ErrReadOnly{}):
 		return serviceerrors.ErrServiceReadOnly
 
+	case errors.As(err, &datastore.ErrMaintenanceMode{}):
+		return serviceerrors.ErrServiceInMaintenance
+
+	case errors.As(err, &datastore.ErrStaleRevision{}):
+		return status.Errorf(codes.Unavailable, "datastore unavailable: %s", err)
+
 	default:
 		log.Ctx(ctx).Err(err)
 		return err
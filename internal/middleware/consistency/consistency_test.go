@@ -5,8 +5,11 @@ import (
 	"errors"
 	"io"
 	"testing"
+	"time"
 
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
 	grpc_testing "github.com/grpc-ecosystem/go-grpc-middleware/testing"
 	pb_testproto "github.com/grpc-ecosystem/go-grpc-middleware/testing/testproto"
 	"github.com/shopspring/decimal"
@@ -14,7 +17,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 
+	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/memdb"
 	"github.com/authzed/spicedb/pkg/zedtoken"
 )
@@ -51,6 +57,7 @@ func TestAddRevisionToContextMinimizeLatency(t *testing.T) {
 	}, ds)
 	require.NoError(err)
 	require.Equal(databaseRev.BigInt(), RevisionFromContext(updated).BigInt())
+	require.False(IsRevisionStale(updated))
 }
 
 func TestAddRevisionToContextFullyConsistent(t *testing.T) {
@@ -129,6 +136,143 @@ func TestAddRevisionToContextAtInvalidExactSnapshot(t *testing.T) {
 	require.Error(err)
 }
 
+func TestAddRevisionToContextForeignZedToken(t *testing.T) {
+	require := require.New(t)
+
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	datastoreID, err := datastore.UniqueID(context.Background(), ds)
+	require.NoError(err)
+	require.NotEmpty(datastoreID)
+	ctx := datastore.ContextWithUniqueID(context.Background(), datastoreID)
+
+	databaseRev, err := ds.HeadRevision(ctx)
+	require.NoError(err)
+
+	requestAt := func(token *v1.ZedToken) *v1.ReadRelationshipsRequest {
+		return &v1.ReadRelationshipsRequest{
+			Consistency: &v1.Consistency{
+				Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: token},
+			},
+		}
+	}
+
+	// Tokens minted for the datastore, and those bound to none, are accepted.
+	updated, err := AddRevisionToContext(ctx, requestAt(zedtoken.NewFromRevisionForDatastore(databaseRev, datastoreID)), ds)
+	require.NoError(err)
+	_, minted := MustRevisionFromContext(updated)
+	requestedRev, err := DecodeRevision(ctx, minted)
+	require.NoError(err)
+	require.True(databaseRev.Equal(requestedRev))
+
+	_, err = AddRevisionToContext(ctx, requestAt(zedtoken.NewFromRevision(databaseRev)), ds)
+	require.NoError(err)
+
+	// Tokens minted against another datastore are rejected.
+	_, err = AddRevisionToContext(ctx, requestAt(zedtoken.NewFromRevisionForDatastore(databaseRev, "another")), ds)
+	require.Error(err)
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+
+	_, err = DecodeRevision(datastore.ContextWithUniqueID(ctx, "another"), minted)
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+}
+
+func TestAddRevisionToContextWithBarrier(t *testing.T) {
+	require := require.New(t)
+
+	ds, err := memdb.NewMemdbDatastore(0, 100*time.Millisecond, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	writtenRev, err := ds.WriteNamespace(context.Background(), &v0.NamespaceDefinition{Name: "test"})
+	require.NoError(err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		BarrierMetadataKey, zedtoken.NewFromRevision(writtenRev).Token,
+	))
+	updated, err := AddRevisionToContext(ctx, &v1.ReadRelationshipsRequest{}, ds)
+	require.NoError(err)
+	require.True(RevisionFromContext(updated).GreaterThanOrEqual(writtenRev))
+}
+
+func TestAddRevisionToContextWithFutureBarrier(t *testing.T) {
+	require := require.New(t)
+
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		BarrierMetadataKey, zedtoken.NewFromRevision(decimal.NewFromInt(1_000_000)).Token,
+	))
+	_, err = AddRevisionToContext(ctx, &v1.ReadRelationshipsRequest{}, ds)
+	require.Error(err)
+}
+
+func TestAddRevisionToContextAtLeastAsFreshWithMaxWait(t *testing.T) {
+	require := require.New(t)
+
+	ds, err := memdb.NewMemdbDatastore(0, 100*time.Millisecond, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	writtenRev, err := ds.WriteNamespace(context.Background(), &v0.NamespaceDefinition{Name: "test"})
+	require.NoError(err)
+
+	req := &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: zedtoken.NewFromRevision(writtenRev),
+			},
+		},
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MaxWaitMetadataKey, "1s"))
+	updated, err := AddRevisionToContext(ctx, req, ds)
+	require.NoError(err)
+	require.True(RevisionFromContext(updated).GreaterThanOrEqual(writtenRev))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(MaxWaitMetadataKey, "soon"))
+	_, err = AddRevisionToContext(ctx, req, ds)
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
+
+type unavailableDatastore struct {
+	datastore.Datastore
+	lastRevision decimal.Decimal
+}
+
+func (ud unavailableDatastore) OptimizedRevision(ctx context.Context) (datastore.Revision, error) {
+	return datastore.NoRevision, datastore.NewStaleRevisionErr(errors.New("connection refused"), ud.lastRevision, 1*time.Second)
+}
+
+func TestAddRevisionToContextStandby(t *testing.T) {
+	require := require.New(t)
+
+	memds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	ds := unavailableDatastore{memds, decimal.NewFromInt(42)}
+
+	updated, err := AddRevisionToContext(context.Background(), &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_MinimizeLatency{
+				MinimizeLatency: true,
+			},
+		},
+	}, ds)
+	require.NoError(err)
+	require.Equal(decimal.NewFromInt(42).BigInt(), RevisionFromContext(updated).BigInt())
+	require.True(IsRevisionStale(updated))
+
+	_, err = AddRevisionToContext(context.Background(), &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: zedtoken.NewFromRevision(decimal.NewFromInt(1)),
+			},
+		},
+	}, ds)
+	grpcutil.RequireStatus(t, codes.Unavailable, err)
+}
+
 func TestConsistencyTestSuite(t *testing.T) {
 	require := require.New(t)
 
@@ -0,0 +1,31 @@
+// Package datastoreid carries the unique ID of the datastore in the context of each request,
+// so that the ZedTokens minted while serving it are bound to that datastore, and those
+// minted against any other datastore are rejected.
+package datastoreid
+
+import (
+	"context"
+
+	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+// UnaryServerInterceptor returns a new interceptor which carries the unique ID of the
+// datastore in the context of each request.
+func UnaryServerInterceptor(uniqueID string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(datastore.ContextWithUniqueID(ctx, uniqueID), req)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which carries the unique ID of the
+// datastore in the context of each stream.
+func StreamServerInterceptor(uniqueID string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrapped := grpcmw.WrapServerStream(stream)
+		wrapped.WrappedContext = datastore.ContextWithUniqueID(stream.Context(), uniqueID)
+		return handler(srv, wrapped)
+	}
+}
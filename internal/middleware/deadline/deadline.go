@@ -0,0 +1,179 @@
+// Package deadline bounds the amount of time for which requests of each family of APIs may
+// run, so that a request without a deadline, or with a very distant one, cannot hold
+// dispatch and datastore resources indefinitely.
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+
+	"github.com/authzed/spicedb/internal/auth"
+)
+
+// Family is a family of APIs which share their deadlines.
+type Family string
+
+const (
+	// Checks are the APIs which check permissions.
+	Checks Family = "checks"
+
+	// Lookups are the APIs which look up and expand the resources and subjects of
+	// permissions.
+	Lookups Family = "lookups"
+
+	// Reads are the APIs which read relationships, schema and namespace configs.
+	Reads Family = "reads"
+
+	// Writes are the APIs which write or delete relationships, schema and namespace configs.
+	Writes Family = "writes"
+
+	// Watch are the APIs which stream changes.
+	Watch Family = "watch"
+)
+
+var families = []Family{Checks, Lookups, Reads, Writes, Watch}
+
+// apiPrefix is the prefix of the full names of the methods of the API services. Other
+// methods, such as those of the health service and the dispatch service, whose requests
+// inherit the deadline of the request which dispatched them, belong to no family.
+const apiPrefix = "/authzed.api."
+
+// FamilyOf returns the family of the gRPC method, given by its full name, if it belongs
+// to one.
+func FamilyOf(fullMethod string) (Family, bool) {
+	if !strings.HasPrefix(fullMethod, apiPrefix) {
+		return "", false
+	}
+
+	name := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	switch {
+	case strings.Contains(name, "Watch"):
+		return Watch, true
+	case auth.IsWriteMethod(fullMethod):
+		return Writes, true
+	case strings.Contains(name, "Check"):
+		return Checks, true
+	case strings.HasPrefix(name, "Lookup"), strings.HasPrefix(name, "Expand"):
+		return Lookups, true
+	case strings.HasPrefix(name, "Read"):
+		return Reads, true
+	default:
+		return "", false
+	}
+}
+
+// Limit is the deadline applied to the requests of a family. Requests without a deadline
+// are given the Default one, and the deadlines of requests are capped at Max. Zero
+// durations apply no deadline.
+type Limit struct {
+	Default time.Duration
+	Max     time.Duration
+}
+
+// Config is the limits of each family.
+type Config map[Family]Limit
+
+// ParseConfig parses the default and maximum deadlines of families, by family name.
+func ParseConfig(defaults, maximums map[string]string) (Config, error) {
+	config := make(Config, len(families))
+	values := []struct {
+		kind     string
+		values   map[string]string
+		duration func(*Limit) *time.Duration
+	}{
+		{"default", defaults, func(l *Limit) *time.Duration { return &l.Default }},
+		{"maximum", maximums, func(l *Limit) *time.Duration { return &l.Max }},
+	}
+	for _, v := range values {
+		for name, value := range v.values {
+			family := Family(name)
+			if !isFamily(family) {
+				return nil, fmt.Errorf("unknown API family `%s` for %s deadline: must be one of %s", name, v.kind, familyNames())
+			}
+
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration < 0 {
+				return nil, fmt.Errorf("invalid %s deadline `%s` for %s: must be a non-negative duration", v.kind, value, name)
+			}
+
+			limit := config[family]
+			*v.duration(&limit) = duration
+			config[family] = limit
+		}
+	}
+
+	for family, limit := range config {
+		if limit.Max > 0 && limit.Default > limit.Max {
+			return nil, fmt.Errorf("default deadline of %s (%s) exceeds its maximum deadline (%s)", family, limit.Default, limit.Max)
+		}
+	}
+	return config, nil
+}
+
+func isFamily(family Family) bool {
+	for _, f := range families {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+func familyNames() string {
+	names := make([]string, 0, len(families))
+	for _, family := range families {
+		names = append(names, string(family))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ContextWithDeadline returns a context with the deadline of the request of the gRPC method,
+// given by its full name: its own deadline, capped at the maximum of its family, or the
+// default of its family if it has none.
+func ContextWithDeadline(ctx context.Context, fullMethod string, config Config) (context.Context, context.CancelFunc) {
+	family, ok := FamilyOf(fullMethod)
+	if !ok {
+		return ctx, func() {}
+	}
+
+	limit := config[family]
+	deadline, hasDeadline := ctx.Deadline()
+	switch {
+	case !hasDeadline && limit.Default > 0:
+		return context.WithTimeout(ctx, limit.Default)
+	case limit.Max > 0 && (!hasDeadline || time.Until(deadline) > limit.Max):
+		return context.WithTimeout(ctx, limit.Max)
+	default:
+		return ctx, func() {}
+	}
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that applies the deadlines
+// of the family of each request.
+func UnaryServerInterceptor(config Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := ContextWithDeadline(ctx, info.FullMethod, config)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that applies the deadlines
+// of the family of each request.
+func StreamServerInterceptor(config Config) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := ContextWithDeadline(stream.Context(), info.FullMethod, config)
+		defer cancel()
+
+		wrapped := grpcmw.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
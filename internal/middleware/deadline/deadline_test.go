@@ -0,0 +1,107 @@
+package deadline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFamilyOf(t *testing.T) {
+	testCases := []struct {
+		fullMethod string
+		expected   Family
+	}{
+		{"/authzed.api.v1.PermissionsService/CheckPermission", Checks},
+		{"/authzed.api.v0.ACLService/ContentChangeCheck", Checks},
+		{"/authzed.api.v1.PermissionsService/LookupResources", Lookups},
+		{"/authzed.api.v1.PermissionsService/ExpandPermissionTree", Lookups},
+		{"/authzed.api.v1.PermissionsService/ReadRelationships", Reads},
+		{"/authzed.api.v1.SchemaService/ReadSchema", Reads},
+		{"/authzed.api.v1.PermissionsService/WriteRelationships", Writes},
+		{"/authzed.api.v1.PermissionsService/DeleteRelationships", Writes},
+		{"/authzed.api.v1.WatchService/Watch", Watch},
+		{"/dispatch.v1.DispatchService/DispatchCheck", ""},
+		{"/grpc.health.v1.Health/Check", ""},
+		{"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.fullMethod, func(t *testing.T) {
+			family, ok := FamilyOf(tc.fullMethod)
+			require.Equal(t, tc.expected != "", ok)
+			require.Equal(t, tc.expected, family)
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	require := require.New(t)
+
+	config, err := ParseConfig(
+		map[string]string{"lookups": "30s", "checks": "1s"},
+		map[string]string{"lookups": "1m", "watch": "1h"},
+	)
+	require.NoError(err)
+	require.Equal(Config{
+		Checks:  {Default: 1 * time.Second},
+		Lookups: {Default: 30 * time.Second, Max: 1 * time.Minute},
+		Watch:   {Max: 1 * time.Hour},
+	}, config)
+
+	_, err = ParseConfig(map[string]string{"expands": "1s"}, nil)
+	require.Error(err)
+
+	_, err = ParseConfig(nil, map[string]string{"reads": "soon"})
+	require.Error(err)
+
+	_, err = ParseConfig(map[string]string{"reads": "2m"}, map[string]string{"reads": "1m"})
+	require.Error(err)
+}
+
+func TestContextWithDeadline(t *testing.T) {
+	const lookupMethod = "/authzed.api.v1.PermissionsService/LookupResources"
+
+	config := Config{
+		Lookups: {Default: 10 * time.Second, Max: 1 * time.Minute},
+		Writes:  {Max: 5 * time.Second},
+	}
+
+	testCases := []struct {
+		name           string
+		fullMethod     string
+		clientDeadline time.Duration
+		expected       time.Duration
+	}{
+		{"default", lookupMethod, 0, 10 * time.Second},
+		{"within maximum", lookupMethod, 30 * time.Second, 30 * time.Second},
+		{"capped", lookupMethod, 1 * time.Hour, 1 * time.Minute},
+		{"maximum without default", "/authzed.api.v1.PermissionsService/WriteRelationships", 0, 5 * time.Second},
+		{"unconfigured family", "/authzed.api.v1.PermissionsService/CheckPermission", 0, 0},
+		{"unconfigured family with deadline", "/authzed.api.v1.PermissionsService/CheckPermission", 1 * time.Hour, 1 * time.Hour},
+		{"no family", "/dispatch.v1.DispatchService/DispatchLookup", 1 * time.Hour, 1 * time.Hour},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			ctx := context.Background()
+			if tc.clientDeadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.clientDeadline)
+				defer cancel()
+			}
+
+			ctx, cancel := ContextWithDeadline(ctx, tc.fullMethod, config)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			require.Equal(tc.expected > 0, ok)
+			if ok {
+				require.WithinDuration(time.Now().Add(tc.expected), deadline, 1*time.Second)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Package dispatchdepth allows requests to override the maximum depth to which their
+// subproblems are dispatched, within a limit set for the server.
+package dispatchdepth
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MaxDepthMetadataKey is the request metadata key under which a request may set the
+// maximum depth to which its subproblems are dispatched, in place of that of the server.
+const MaxDepthMetadataKey = "io.spicedb.requestmeta.max-depth"
+
+type ctxKeyType struct{}
+
+var depthKey ctxKeyType = struct{}{}
+
+// FromContext returns the maximum dispatch depth requested for the request of the context,
+// or the default if it requested none.
+func FromContext(ctx context.Context, defaultDepth uint32) uint32 {
+	if depth, ok := ctx.Value(depthKey).(uint32); ok {
+		return depth
+	}
+	return defaultDepth
+}
+
+// ContextWithMaxDepth returns a context in which the maximum dispatch depth of the request
+// is set from its metadata, if it requested one, which must be at most the limit.
+func ContextWithMaxDepth(ctx context.Context, limit uint32) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+
+	values := md.Get(MaxDepthMetadataKey)
+	if len(values) == 0 {
+		return ctx, nil
+	}
+
+	depth, err := strconv.ParseUint(values[0], 10, 32)
+	if err != nil || depth == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %s", MaxDepthMetadataKey, values[0])
+	}
+	if depth > uint64(limit) {
+		return nil, status.Errorf(codes.InvalidArgument, "%s may be at most %d", MaxDepthMetadataKey, limit)
+	}
+
+	return context.WithValue(ctx, depthKey, uint32(depth)), nil
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that sets the maximum
+// dispatch depth requested by each request, rejecting those above the limit.
+func UnaryServerInterceptor(limit uint32) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		newCtx, err := ContextWithMaxDepth(ctx, limit)
+		if err != nil {
+			return nil, err
+		}
+		return handler(newCtx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that sets the maximum
+// dispatch depth requested by each request, rejecting those above the limit.
+func StreamServerInterceptor(limit uint32) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		newCtx, err := ContextWithMaxDepth(stream.Context(), limit)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &wrappedStream{stream, newCtx})
+	}
+}
+
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,51 @@
+package dispatchdepth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestContextWithMaxDepth(t *testing.T) {
+	const (
+		defaultDepth = 50
+		limit        = 100
+	)
+
+	testCases := []struct {
+		name          string
+		md            metadata.MD
+		expectedDepth uint32
+		expectedCode  codes.Code
+	}{
+		{"no metadata", nil, defaultDepth, codes.OK},
+		{"no override", metadata.Pairs("other", "value"), defaultDepth, codes.OK},
+		{"lower", metadata.Pairs(MaxDepthMetadataKey, "10"), 10, codes.OK},
+		{"higher", metadata.Pairs(MaxDepthMetadataKey, "75"), 75, codes.OK},
+		{"at limit", metadata.Pairs(MaxDepthMetadataKey, "100"), 100, codes.OK},
+		{"above limit", metadata.Pairs(MaxDepthMetadataKey, "101"), 0, codes.InvalidArgument},
+		{"zero", metadata.Pairs(MaxDepthMetadataKey, "0"), 0, codes.InvalidArgument},
+		{"invalid", metadata.Pairs(MaxDepthMetadataKey, "deep"), 0, codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			ctx := context.Background()
+			if tc.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tc.md)
+			}
+
+			ctx, err := ContextWithMaxDepth(ctx, limit)
+			require.Equal(tc.expectedCode, status.Code(err))
+			if err == nil {
+				require.Equal(tc.expectedDepth, FromContext(ctx, defaultDepth))
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Package drain notifies the handlers of long-lived streams, such as watches, that the
+// server is shutting down, so that they can end cleanly instead of holding up its graceful
+// stop until they are forcibly closed.
+package drain
+
+import (
+	"context"
+	"sync"
+
+	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+)
+
+type ctxKeyType struct{}
+
+var drainKey ctxKeyType = struct{}{}
+
+// Drainer signals that the server is draining.
+type Drainer struct {
+	once     sync.Once
+	draining chan struct{}
+}
+
+// NewDrainer creates a drainer which is not yet draining.
+func NewDrainer() *Drainer {
+	return &Drainer{draining: make(chan struct{})}
+}
+
+// Drain signals the streams that the server is draining. It may be called more than once.
+func (d *Drainer) Drain() {
+	d.once.Do(func() {
+		close(d.draining)
+	})
+}
+
+// Draining returns a channel which is closed once the server is draining.
+func (d *Drainer) Draining() <-chan struct{} {
+	return d.draining
+}
+
+// FromContext returns a channel which is closed once the server handling the request is
+// draining. Without a drainer in the context, the returned channel is nil, and is never
+// closed.
+func FromContext(ctx context.Context) <-chan struct{} {
+	if d, ok := ctx.Value(drainKey).(*Drainer); ok {
+		return d.Draining()
+	}
+	return nil
+}
+
+// StreamServerInterceptor returns a new stream server interceptor which makes the drainer
+// available to stream handlers through FromContext.
+func StreamServerInterceptor(d *Drainer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrapped := grpcmw.WrapServerStream(stream)
+		wrapped.WrappedContext = context.WithValue(stream.Context(), drainKey, d)
+		return handler(srv, wrapped)
+	}
+}
@@ -0,0 +1,45 @@
+package drain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(FromContext(context.Background()))
+
+	d := NewDrainer()
+	interceptor := StreamServerInterceptor(d)
+
+	var draining <-chan struct{}
+	err := interceptor(nil, testServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+		draining = FromContext(stream.Context())
+		return nil
+	})
+	require.NoError(err)
+	require.NotNil(draining)
+
+	select {
+	case <-draining:
+		require.FailNow("drained before Drain was called")
+	default:
+	}
+
+	d.Drain()
+	d.Drain()
+	<-draining
+}
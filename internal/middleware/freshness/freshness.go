@@ -0,0 +1,66 @@
+package freshness
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+// ResultTTLMillis is the response header in which the number of milliseconds for which a
+// check result may be cached by the client is returned.
+const ResultTTLMillis responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.resultttlmillis"
+
+// ResultTTL computes the amount of time for which a check result computed at the specified
+// time with the specified consistency can be safely reused by a client cache.
+//
+// Results computed at a quantized revision would have been returned for any request
+// until the next quantized revision, and are therefore valid for the remainder of the
+// quantization window. Results computed at an exact snapshot never change, but the
+// snapshot itself can only be used until it is garbage collected. Fully consistent
+// results are never cacheable.
+func ResultTTL(consistency *v1.Consistency, quantization, gcWindow time.Duration, now time.Time) time.Duration {
+	switch {
+	case consistency == nil || consistency.GetMinimizeLatency() || consistency.GetAtLeastAsFresh() != nil:
+		remaining := quantization
+		if quantization > 0 {
+			remaining -= time.Duration(now.UnixNano() % quantization.Nanoseconds())
+		}
+		if remaining > gcWindow {
+			return gcWindow
+		}
+		return remaining
+
+	case consistency.GetAtExactSnapshot() != nil:
+		return gcWindow
+
+	default:
+		return 0
+	}
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that returns a result TTL
+// hint in the response headers of check requests, computed from the revision
+// quantization window and the garbage collection window of the datastore.
+func UnaryServerInterceptor(quantization, gcWindow time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		checkReq, ok := req.(*v1.CheckPermissionRequest)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		ttl := ResultTTL(checkReq.Consistency, quantization, gcWindow, time.Now())
+		err := responsemeta.SetResponseHeaderMetadata(ctx, map[responsemeta.ResponseMetadataHeaderKey]string{
+			ResultTTLMillis: strconv.FormatInt(ttl.Milliseconds(), 10),
+		})
+		if err != nil {
+			log.Ctx(ctx).Err(err).Msg("could not report result TTL metadata")
+		}
+
+		return handler(ctx, req)
+	}
+}
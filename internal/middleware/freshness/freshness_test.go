@@ -0,0 +1,66 @@
+package freshness
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultTTL(t *testing.T) {
+	const (
+		quantization = 5 * time.Second
+		gcWindow     = 24 * time.Hour
+	)
+
+	testCases := []struct {
+		name        string
+		consistency *v1.Consistency
+		expected    time.Duration
+	}{
+		{"unspecified", nil, quantization},
+		{
+			"minimize latency",
+			&v1.Consistency{Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true}},
+			quantization,
+		},
+		{
+			"at least as fresh",
+			&v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: &v1.ZedToken{}}},
+			quantization,
+		},
+		{
+			"at exact snapshot",
+			&v1.Consistency{Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: &v1.ZedToken{}}},
+			gcWindow,
+		},
+		{
+			"fully consistent",
+			&v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+			0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, ResultTTL(tc.consistency, quantization, gcWindow, time.Unix(0, 0)))
+		})
+	}
+}
+
+func TestResultTTLQuantizationExceedsGCWindow(t *testing.T) {
+	require.Equal(t, time.Minute, ResultTTL(nil, time.Hour, time.Minute, time.Unix(0, 0)))
+}
+
+func TestResultTTLWithinQuantizationWindow(t *testing.T) {
+	const quantization = 5 * time.Second
+
+	// Results are only valid until the next quantized revision.
+	now := time.Unix(1000, 0).Add(3200 * time.Millisecond)
+	require.Equal(t, 1800*time.Millisecond, ResultTTL(nil, quantization, 24*time.Hour, now))
+	require.Equal(t, time.Second, ResultTTL(nil, quantization, time.Second, now))
+	require.Equal(t, 24*time.Hour, ResultTTL(&v1.Consistency{
+		Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: &v1.ZedToken{}},
+	}, quantization, 24*time.Hour, now))
+}
@@ -0,0 +1,198 @@
+// Package loadshed rejects requests early once the server is overloaded, lowest priority
+// first, so that the requests it keeps serving complete instead of all of them timing out
+// together.
+//
+// The load of the server is the highest of the ratios of its signals to their limits: the
+// number of requests in flight, the number of requests dispatched by peers in flight, and
+// the time spent waiting for datastore connections. Requests are shed once the load
+// exceeds the threshold of their priority:
+//
+//	background  0.5
+//	batch       0.7
+//	reads       0.85
+//	writes      1
+package loadshed
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/auth"
+	"github.com/authzed/spicedb/pkg/middleware/priority"
+)
+
+const (
+	backgroundThreshold = 0.5
+	batchThreshold      = 0.7
+	readThreshold       = 0.85
+	writeThreshold      = 1
+)
+
+var shedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "loadshed",
+	Name:      "shed_total",
+	Help:      "total number of requests rejected because the server was overloaded, by the most loaded signal and the priority class of the request",
+}, []string{"signal", "class"})
+
+// Option instances control how the shedder is initialized.
+type Option func(*Shedder)
+
+// MaxInFlight sets the number of requests in flight at which the server is fully loaded.
+//
+// default: 0, which does not limit the number of requests in flight
+func MaxInFlight(max uint64) Option {
+	return func(s *Shedder) {
+		s.maxInFlight = int64(max)
+	}
+}
+
+// MaxDispatchInFlight sets the number of requests dispatched by peers in flight at which
+// the server is fully loaded. The requests are counted by DispatchUnaryServerInterceptor
+// and DispatchStreamServerInterceptor.
+//
+// default: 0, which does not limit the number of dispatched requests in flight
+func MaxDispatchInFlight(max uint64) Option {
+	return func(s *Shedder) {
+		s.maxDispatchInFlight = int64(max)
+	}
+}
+
+// MaxPoolWait sets the time spent waiting for datastore connections, as reported by wait,
+// at which the server is fully loaded.
+//
+// default: 0, which does not limit the time spent waiting for connections
+func MaxPoolWait(max time.Duration, wait func() time.Duration) Option {
+	return func(s *Shedder) {
+		s.maxPoolWait = max
+		s.poolWait = wait
+	}
+}
+
+// Shedder tracks the load of the server and decides which requests to shed.
+type Shedder struct {
+	inFlight         int64
+	dispatchInFlight int64
+
+	maxInFlight         int64
+	maxDispatchInFlight int64
+	maxPoolWait         time.Duration
+	poolWait            func() time.Duration
+}
+
+// NewShedder creates a shedder with the provided options.
+func NewShedder(opts ...Option) *Shedder {
+	s := &Shedder{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Load returns the load of the server and the name of the signal which is the most loaded.
+func (s *Shedder) Load() (string, float64) {
+	signal, load := "", 0.0
+	observe := func(name string, ratio float64) {
+		if ratio > load {
+			signal, load = name, ratio
+		}
+	}
+
+	if s.maxInFlight > 0 {
+		observe("in_flight", float64(atomic.LoadInt64(&s.inFlight))/float64(s.maxInFlight))
+	}
+	if s.maxDispatchInFlight > 0 {
+		observe("dispatch_in_flight", float64(atomic.LoadInt64(&s.dispatchInFlight))/float64(s.maxDispatchInFlight))
+	}
+	if s.maxPoolWait > 0 && s.poolWait != nil {
+		observe("datastore_pool_wait", float64(s.poolWait())/float64(s.maxPoolWait))
+	}
+	return signal, load
+}
+
+func threshold(ctx context.Context, fullMethod string) float64 {
+	switch priority.FromContext(ctx) {
+	case priority.Background:
+		return backgroundThreshold
+	case priority.Batch:
+		return batchThreshold
+	}
+
+	if auth.IsWriteMethod(fullMethod) {
+		return writeThreshold
+	}
+	return readThreshold
+}
+
+// admit counts the request as in flight, returning the function to call once it
+// completes, or an error if it is shed.
+func (s *Shedder) admit(ctx context.Context, fullMethod string) (func(), error) {
+	atomic.AddInt64(&s.inFlight, 1)
+	done := func() {
+		atomic.AddInt64(&s.inFlight, -1)
+	}
+
+	signal, load := s.Load()
+	if load <= threshold(ctx, fullMethod) {
+		return done, nil
+	}
+
+	done()
+	class := priority.FromContext(ctx)
+	shedCounter.WithLabelValues(signal, string(class)).Inc()
+	return nil, status.Errorf(codes.Unavailable, "server is overloaded (%s at %.0f%% of its limit), shedding %s requests; retry later", signal, load*100, class)
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that sheds requests while
+// the server is overloaded. It must follow the extraction of the priority of requests.
+func UnaryServerInterceptor(s *Shedder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done, err := s.admit(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer done()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that sheds streams while
+// the server is overloaded. It must follow the extraction of the priority of requests.
+func StreamServerInterceptor(s *Shedder) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done, err := s.admit(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer done()
+		return handler(srv, stream)
+	}
+}
+
+// DispatchUnaryServerInterceptor returns a new unary server interceptor for the dispatch
+// server which counts the requests dispatched by peers in flight. Dispatched requests are
+// never shed, as they are part of requests which were already admitted.
+func DispatchUnaryServerInterceptor(s *Shedder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		atomic.AddInt64(&s.dispatchInFlight, 1)
+		defer atomic.AddInt64(&s.dispatchInFlight, -1)
+		return handler(ctx, req)
+	}
+}
+
+// DispatchStreamServerInterceptor returns a new stream server interceptor for the dispatch
+// server which counts the streams dispatched by peers in flight.
+func DispatchStreamServerInterceptor(s *Shedder) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		atomic.AddInt64(&s.dispatchInFlight, 1)
+		defer atomic.AddInt64(&s.dispatchInFlight, -1)
+		return handler(srv, stream)
+	}
+}
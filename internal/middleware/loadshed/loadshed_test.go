@@ -0,0 +1,115 @@
+package loadshed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/pkg/middleware/priority"
+)
+
+const (
+	readMethod  = "/authzed.api.v1.PermissionsService/CheckPermission"
+	writeMethod = "/authzed.api.v1.PermissionsService/WriteRelationships"
+)
+
+func TestLoad(t *testing.T) {
+	require := require.New(t)
+
+	wait := 10 * time.Millisecond
+	s := NewShedder(MaxInFlight(10), MaxDispatchInFlight(4), MaxPoolWait(100*time.Millisecond, func() time.Duration {
+		return wait
+	}))
+
+	signal, load := s.Load()
+	require.Equal("datastore_pool_wait", signal)
+	require.InDelta(0.1, load, 0.001)
+
+	s.inFlight = 5
+	signal, load = s.Load()
+	require.Equal("in_flight", signal)
+	require.InDelta(0.5, load, 0.001)
+
+	s.dispatchInFlight = 3
+	signal, load = s.Load()
+	require.Equal("dispatch_in_flight", signal)
+	require.InDelta(0.75, load, 0.001)
+
+	wait = 200 * time.Millisecond
+	signal, load = s.Load()
+	require.Equal("datastore_pool_wait", signal)
+	require.InDelta(2, load, 0.001)
+
+	signal, load = NewShedder().Load()
+	require.Equal("", signal)
+	require.Equal(0.0, load)
+}
+
+func TestShedding(t *testing.T) {
+	testCases := []struct {
+		name     string
+		class    priority.Class
+		method   string
+		inFlight int64
+		shed     bool
+	}{
+		{"idle read", priority.Interactive, readMethod, 0, false},
+		{"loaded background", priority.Background, readMethod, 5, true},
+		{"loaded batch", priority.Batch, readMethod, 5, false},
+		{"busy batch", priority.Batch, readMethod, 7, true},
+		{"busy read", priority.Interactive, readMethod, 7, false},
+		{"saturated read", priority.Interactive, readMethod, 9, true},
+		{"saturated write", priority.Interactive, writeMethod, 9, false},
+		{"overloaded write", priority.Interactive, writeMethod, 10, true},
+		{"overloaded batch write", priority.Batch, writeMethod, 7, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			s := NewShedder(MaxInFlight(10))
+			s.inFlight = tc.inFlight
+			interceptor := UnaryServerInterceptor(s)
+
+			ctx := priority.ContextWithClass(context.Background(), tc.class)
+			var handled bool
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				handled = true
+				require.Equal(tc.inFlight+1, s.inFlight)
+				return nil, nil
+			})
+
+			require.Equal(tc.inFlight, s.inFlight)
+			if tc.shed {
+				require.Equal(codes.Unavailable, status.Code(err))
+				require.False(handled)
+				return
+			}
+			require.NoError(err)
+			require.True(handled)
+		})
+	}
+}
+
+func TestDispatchInterceptorCountsInFlight(t *testing.T) {
+	require := require.New(t)
+
+	s := NewShedder(MaxDispatchInFlight(1))
+	interceptor := DispatchUnaryServerInterceptor(s)
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, load := s.Load()
+		require.Equal(1.0, load)
+		return nil, nil
+	})
+	require.NoError(err)
+
+	_, load := s.Load()
+	require.Equal(0.0, load)
+}
@@ -0,0 +1,84 @@
+package provenance
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/internal/auth"
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/pkg/middleware/requestid"
+)
+
+const (
+	// CallerMetadataKey is the key in which clients identify themselves for the
+	// purposes of attributing writes.
+	CallerMetadataKey = "x-spicedb-caller"
+
+	// ReasonMetadataKey is the key in which clients pass the justification for their
+	// writes, to be recorded with them.
+	ReasonMetadataKey = "x-spicedb-reason"
+
+	// LabelMetadataKey is the key in which clients pass labels, in the form
+	// `key=value`, to be recorded with their writes. It may be specified more than once.
+	LabelMetadataKey = "x-spicedb-label"
+)
+
+type handleProvenance struct{}
+
+func (r *handleProvenance) ServerReporter(ctx context.Context, _ interceptors.CallMeta) (interceptors.Reporter, context.Context) {
+	txMetadata := &datastore.TransactionMetadata{}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		txMetadata.Principal = principal
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+	if values := md.Get(CallerMetadataKey); len(values) > 0 {
+		txMetadata.Caller = values[0]
+	}
+	if values := md.Get(requestid.RequestIDMetadataKey); len(values) > 0 {
+		txMetadata.RequestID = values[0]
+	}
+	if values := md.Get(ReasonMetadataKey); len(values) > 0 {
+		txMetadata.Reason = values[0]
+	}
+	for _, label := range md.Get(LabelMetadataKey) {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			log.Ctx(ctx).Debug().Str("label", label).Msg("ignoring malformed transaction label")
+			continue
+		}
+
+		if txMetadata.Labels == nil {
+			txMetadata.Labels = make(map[string]string)
+		}
+		txMetadata.Labels[parts[0]] = parts[1]
+	}
+
+	if txMetadata.Caller == "" && txMetadata.Principal == "" && txMetadata.RequestID == "" && txMetadata.Reason == "" && len(txMetadata.Labels) == 0 {
+		return interceptors.NoopReporter{}, ctx
+	}
+
+	return interceptors.NoopReporter{}, datastore.ContextWithTransactionMetadata(ctx, txMetadata)
+}
+
+// UnaryServerInterceptor returns a new interceptor which records the caller, authenticated
+// principal, request ID, reason and labels of a request, to be stored alongside any
+// transactions it writes.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return interceptors.UnaryServerInterceptor(&handleProvenance{})
+}
+
+// StreamServerInterceptor returns a new interceptor which records the caller, authenticated
+// principal, request ID, reason and labels of a request, to be stored alongside any
+// transactions it writes.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return interceptors.StreamServerInterceptor(&handleProvenance{})
+}
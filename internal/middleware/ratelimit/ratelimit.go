@@ -0,0 +1,274 @@
+// Package ratelimit limits the rate of requests, overall and by the preshared key or
+// principal and the tenant of requests, so that a single client cannot saturate the
+// dispatcher and datastore for everyone.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/auth"
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+// RetryAfterMillis is the response trailer in which the number of milliseconds after which
+// a request rejected by the rate limit may be retried is returned.
+const RetryAfterMillis responsemeta.ResponseMetadataTrailerKey = "io.spicedb.respmeta.retryaftermillis"
+
+// pruneInterval is the interval at which the buckets of clients which are no longer making
+// requests are removed.
+const pruneInterval = 1 * time.Minute
+
+var rejectedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "ratelimit",
+	Name:      "rejected_total",
+	Help:      "total number of requests rejected by the rate limit, by the limit which was exceeded",
+}, []string{"limit"})
+
+// Limit is the rate of a token bucket, which holds up to Burst requests and refills at
+// Rate requests per second. The zero Limit is unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit of the form `rate` or `rate:burst`, with the rate in requests
+// per second. If no burst is given, it is the rate, rounded up.
+func ParseLimit(limit string) (Limit, error) {
+	parts := strings.SplitN(limit, ":", 2)
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || rate <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit `%s`: rate must be a positive number of requests per second", limit)
+	}
+
+	burst := int(math.Ceil(rate))
+	if len(parts) == 2 {
+		burst, err = strconv.Atoi(parts[1])
+		if err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit `%s`: burst must be a positive number of requests", limit)
+		}
+	}
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+func (l Limit) unlimited() bool {
+	return l.Rate <= 0
+}
+
+// Config configures the limits of a Limiter.
+type Config struct {
+	// Global limits all requests together.
+	Global Limit
+
+	// PerClient limits the requests of each client, identified by the name of its preshared
+	// key or by the principal of its token. KeyLimits overrides it for the named keys.
+	PerClient Limit
+	KeyLimits map[string]Limit
+
+	// PerTenant limits the requests of each tenant. TenantLimits overrides it for the named
+	// tenants.
+	PerTenant    Limit
+	TenantLimits map[string]Limit
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens accumulated since the bucket was last used.
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+	b.last = now
+}
+
+// wait returns the amount of time until the bucket holds a token.
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+}
+
+type limitedBucket struct {
+	kind   string
+	bucket *bucket
+}
+
+// Limiter limits the rate of requests with token buckets.
+type Limiter struct {
+	config Config
+	now    func() time.Time
+
+	mu         sync.Mutex
+	global     *bucket
+	buckets    map[string]*bucket
+	lastPruned time.Time
+}
+
+// NewLimiter creates a limiter with the configured limits.
+func NewLimiter(config Config) *Limiter {
+	return newLimiter(config, time.Now)
+}
+
+func newLimiter(config Config, now func() time.Time) *Limiter {
+	limiter := &Limiter{
+		config:     config,
+		now:        now,
+		buckets:    make(map[string]*bucket),
+		lastPruned: now(),
+	}
+	if !config.Global.unlimited() {
+		limiter.global = newBucket(config.Global, now())
+	}
+	return limiter
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+// bucketFor returns the bucket of the key, creating it with the limit if it does not exist.
+// The lock must be held.
+func (l *Limiter) bucketFor(key string, limit Limit, now time.Time) *bucket {
+	found, ok := l.buckets[key]
+	if !ok {
+		found = newBucket(limit, now)
+		l.buckets[key] = found
+	}
+	return found
+}
+
+// Allow takes a token for the request from every bucket which limits it, returning the
+// name of the exceeded limit and the amount of time after which the request may be retried
+// if any bucket is empty, in which case no token is taken.
+func (l *Limiter) Allow(ctx context.Context) (string, time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	var buckets []limitedBucket
+	if l.global != nil {
+		buckets = append(buckets, limitedBucket{"global", l.global})
+	}
+
+	clientKey, clientLimit := l.clientLimit(ctx)
+	if !clientLimit.unlimited() {
+		buckets = append(buckets, limitedBucket{"client", l.bucketFor("client:"+clientKey, clientLimit, now)})
+	}
+
+	tenant := datastore.TenantFromContext(ctx)
+	tenantLimit := l.config.PerTenant
+	if override, ok := l.config.TenantLimits[tenant]; ok {
+		tenantLimit = override
+	}
+	if !tenantLimit.unlimited() {
+		buckets = append(buckets, limitedBucket{"tenant", l.bucketFor("tenant:"+tenant, tenantLimit, now)})
+	}
+
+	exceeded, retryAfter := "", time.Duration(0)
+	for _, limited := range buckets {
+		limited.bucket.refill(now)
+		if wait := limited.bucket.wait(); wait > retryAfter {
+			exceeded, retryAfter = limited.kind, wait
+		}
+	}
+	if exceeded != "" {
+		return exceeded, retryAfter
+	}
+
+	for _, limited := range buckets {
+		limited.bucket.tokens--
+	}
+	return "", 0
+}
+
+// clientLimit returns the identity of the client making the request and its limit.
+func (l *Limiter) clientLimit(ctx context.Context) (string, Limit) {
+	if name, ok := auth.KeyNameFromContext(ctx); ok {
+		if override, ok := l.config.KeyLimits[name]; ok {
+			return "key:" + name, override
+		}
+		return "key:" + name, l.config.PerClient
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return "principal:" + principal, l.config.PerClient
+	}
+	return "anonymous", l.config.PerClient
+}
+
+// prune removes the buckets which have refilled completely, as their clients have not made
+// requests for long enough that they are equivalent to new buckets. The lock must be held.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPruned) < pruneInterval {
+		return
+	}
+	l.lastPruned = now
+
+	for key, found := range l.buckets {
+		found.refill(now)
+		if found.tokens >= float64(found.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Limiter) check(ctx context.Context) error {
+	exceeded, retryAfter := l.Allow(ctx)
+	if exceeded == "" {
+		return nil
+	}
+
+	rejectedCounter.WithLabelValues(exceeded).Inc()
+
+	err := responsemeta.SetResponseTrailerMetadata(ctx, map[responsemeta.ResponseMetadataTrailerKey]string{
+		RetryAfterMillis: strconv.FormatInt(int64(math.Ceil(float64(retryAfter)/float64(time.Millisecond))), 10),
+	})
+	if err != nil {
+		log.Ctx(ctx).Err(err).Msg("could not report retry-after metadata")
+	}
+
+	return status.Errorf(codes.ResourceExhausted, "%s rate limit exceeded; retry after %s", exceeded, retryAfter.Round(time.Millisecond))
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that rejects requests
+// exceeding the limits of the limiter with RESOURCE_EXHAUSTED. It must follow the
+// authentication of requests, which identifies their clients and tenants.
+func UnaryServerInterceptor(limiter *Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := limiter.check(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that rejects streams
+// exceeding the limits of the limiter with RESOURCE_EXHAUSTED, counting each stream as a
+// single request.
+func StreamServerInterceptor(limiter *Limiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := limiter.check(stream.Context()); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
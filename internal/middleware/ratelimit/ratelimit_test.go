@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/auth"
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+func TestParseLimit(t *testing.T) {
+	testCases := []struct {
+		limit     string
+		expected  Limit
+		expectErr bool
+	}{
+		{"10", Limit{10, 10}, false},
+		{"0.5", Limit{0.5, 1}, false},
+		{"10:25", Limit{10, 25}, false},
+		{"", Limit{}, true},
+		{"0", Limit{}, true},
+		{"-1", Limit{}, true},
+		{"10:0", Limit{}, true},
+		{"10:abc", Limit{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.limit, func(t *testing.T) {
+			limit, err := ParseLimit(tc.limit)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, limit)
+		})
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(config Config) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+	return newLimiter(config, func() time.Time { return clock.now }), clock
+}
+
+func keyContext(name, tenant string) context.Context {
+	ctx := auth.ContextWithKeyName(context.Background(), name)
+	return datastore.ContextWithTenant(ctx, tenant)
+}
+
+func TestLimiter(t *testing.T) {
+	require := require.New(t)
+
+	limiter, clock := newTestLimiter(Config{
+		PerClient:    Limit{Rate: 1, Burst: 2},
+		KeyLimits:    map[string]Limit{"billing": {Rate: 10, Burst: 5}},
+		PerTenant:    Limit{Rate: 100, Burst: 4},
+		TenantLimits: map[string]Limit{"small": {Rate: 1, Burst: 1}},
+	})
+
+	// Each key has its own bucket.
+	for i := 0; i < 2; i++ {
+		exceeded, _ := limiter.Allow(keyContext("primary", datastore.DefaultTenant))
+		require.Equal("", exceeded)
+	}
+	exceeded, retryAfter := limiter.Allow(keyContext("primary", datastore.DefaultTenant))
+	require.Equal("client", exceeded)
+	require.Equal(1*time.Second, retryAfter)
+
+	exceeded, _ = limiter.Allow(keyContext("billing", datastore.DefaultTenant))
+	require.Equal("", exceeded)
+
+	// The tenant bucket is shared by all of the keys of the tenant.
+	exceeded, _ = limiter.Allow(keyContext("billing", datastore.DefaultTenant))
+	require.Equal("", exceeded)
+	exceeded, _ = limiter.Allow(keyContext("billing", datastore.DefaultTenant))
+	require.Equal("tenant", exceeded)
+
+	// Tenants may have their own limits.
+	exceeded, _ = limiter.Allow(keyContext("billing", "small"))
+	require.Equal("", exceeded)
+	exceeded, retryAfter = limiter.Allow(keyContext("billing", "small"))
+	require.Equal("tenant", exceeded)
+	require.Equal(1*time.Second, retryAfter)
+
+	// Buckets refill over time.
+	clock.advance(500 * time.Millisecond)
+	exceeded, retryAfter = limiter.Allow(keyContext("primary", datastore.DefaultTenant))
+	require.Equal("client", exceeded)
+	require.Equal(500*time.Millisecond, retryAfter)
+
+	clock.advance(500 * time.Millisecond)
+	exceeded, _ = limiter.Allow(keyContext("primary", datastore.DefaultTenant))
+	require.Equal("", exceeded)
+}
+
+func TestLimiterRejectionTakesNoTokens(t *testing.T) {
+	require := require.New(t)
+
+	limiter, _ := newTestLimiter(Config{
+		Global:    Limit{Rate: 1, Burst: 2},
+		PerClient: Limit{Rate: 1, Burst: 1},
+	})
+
+	exceeded, _ := limiter.Allow(keyContext("a", datastore.DefaultTenant))
+	require.Equal("", exceeded)
+
+	// The rejection of the second request of a does not take from the global bucket.
+	exceeded, _ = limiter.Allow(keyContext("a", datastore.DefaultTenant))
+	require.Equal("client", exceeded)
+
+	exceeded, _ = limiter.Allow(keyContext("b", datastore.DefaultTenant))
+	require.Equal("", exceeded)
+
+	exceeded, _ = limiter.Allow(keyContext("c", datastore.DefaultTenant))
+	require.Equal("global", exceeded)
+}
+
+func TestLimiterPrunesIdleBuckets(t *testing.T) {
+	require := require.New(t)
+
+	limiter, clock := newTestLimiter(Config{PerClient: Limit{Rate: 1, Burst: 1}})
+
+	limiter.Allow(keyContext("a", datastore.DefaultTenant))
+	limiter.Allow(auth.ContextWithPrincipal(context.Background(), "user"))
+	require.Len(limiter.buckets, 2)
+
+	clock.advance(pruneInterval)
+	limiter.Allow(keyContext("b", datastore.DefaultTenant))
+	require.Len(limiter.buckets, 1)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	require := require.New(t)
+
+	limiter, _ := newTestLimiter(Config{Global: Limit{Rate: 1, Burst: 1}})
+	interceptor := UnaryServerInterceptor(limiter)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/authzed.api.v1.PermissionsService/CheckPermission"}
+
+	resp, err := interceptor(context.Background(), nil, info, handler)
+	require.NoError(err)
+	require.Equal("ok", resp)
+
+	_, err = interceptor(context.Background(), nil, info, handler)
+	require.Equal(codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,280 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cespare/xxhash"
+	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultLogInterval = 1 * time.Minute
+	defaultCrashWindow = 5 * time.Minute
+
+	// maxTrackedFingerprints bounds the number of fingerprints whose last log message is
+	// remembered, as panic values may embed request data and so vary without bound.
+	maxTrackedFingerprints = 1024
+)
+
+var panicsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "services",
+	Name:      "panics_total",
+	Help:      "number of panics recovered while handling requests.",
+}, []string{"method"})
+
+// Option instances control how the recovery handler is initialized.
+type Option func(*Handler)
+
+// LogInterval sets the minimum interval between log messages for panics that
+// share the same fingerprint. Panics within the interval are counted and reported
+// with the next message.
+//
+// default: 1m
+func LogInterval(interval time.Duration) Option {
+	return func(h *Handler) {
+		h.logInterval = interval
+	}
+}
+
+// CrashThreshold sets the number of panics which, once recovered within the crash
+// window, cause onExceeded to be invoked, once. This is typically used to mark the
+// server as unready so that a poison request cannot keep the whole process in a
+// degraded state, while the occasional panic of a long-running process is tolerated.
+//
+// default: 0 (disabled)
+func CrashThreshold(threshold uint64, onExceeded func()) Option {
+	return func(h *Handler) {
+		h.crashThreshold = threshold
+		h.onCrashThresholdExceeded = onExceeded
+	}
+}
+
+// CrashWindow sets the sliding window of time within which the panics counted
+// towards the crash threshold must have been recovered.
+//
+// default: 5m
+func CrashWindow(window time.Duration) Option {
+	return func(h *Handler) {
+		h.crashWindow = window
+	}
+}
+
+// Handler converts recovered panics into errors, logging and counting them.
+type Handler struct {
+	logInterval              time.Duration
+	crashThreshold           uint64
+	crashWindow              time.Duration
+	onCrashThresholdExceeded func()
+	timeSource               clock.Clock
+
+	exceededOnce sync.Once
+
+	lock         sync.Mutex
+	recentPanics []time.Time
+	lastLogged   map[uint64]time.Time
+	suppressed   map[uint64]uint64
+}
+
+// NewHandler creates a new recovery handler with the provided options.
+func NewHandler(opts ...Option) *Handler {
+	h := &Handler{
+		logInterval: defaultLogInterval,
+		crashWindow: defaultCrashWindow,
+		timeSource:  clock.New(),
+		lastLogged:  make(map[uint64]time.Time),
+		suppressed:  make(map[uint64]uint64),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Recover handles the value returned by recover() for a panic which occurred
+// while executing the specified method, returning an INTERNAL error to be
+// returned to the caller in place of crashing.
+func (h *Handler) Recover(ctx context.Context, method string, p interface{}) error {
+	panicsCounter.WithLabelValues(method).Inc()
+
+	fingerprint := fingerprintPanic(p)
+	if shouldLog, suppressed := h.shouldLog(fingerprint); shouldLog {
+		log.Ctx(ctx).Error().
+			Str("method", method).
+			Str("fingerprint", fmt.Sprintf("%016x", fingerprint)).
+			Uint64("suppressed", suppressed).
+			Interface("panic", p).
+			Bytes("stack", debug.Stack()).
+			Msg("recovered from panic")
+	}
+
+	if count := h.countRecentPanic(); h.crashThreshold > 0 && count >= h.crashThreshold {
+		h.exceededOnce.Do(func() {
+			log.Error().Uint64("panics", count).Dur("window", h.crashWindow).Msg("panic crash threshold exceeded")
+			if h.onCrashThresholdExceeded != nil {
+				h.onCrashThresholdExceeded()
+			}
+		})
+	}
+
+	return status.Errorf(codes.Internal, "internal error (panic fingerprint %016x)", fingerprint)
+}
+
+// countRecentPanic records a panic, returning the number of panics recovered within
+// the crash window. At most the crash threshold's worth of panics are remembered.
+func (h *Handler) countRecentPanic() uint64 {
+	if h.crashThreshold == 0 {
+		return 0
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := h.timeSource.Now()
+	h.recentPanics = append(h.recentPanics, now)
+
+	kept := 0
+	for kept < len(h.recentPanics) && now.Sub(h.recentPanics[kept]) >= h.crashWindow {
+		kept++
+	}
+	if excess := len(h.recentPanics) - kept - int(h.crashThreshold); excess > 0 {
+		kept += excess
+	}
+	h.recentPanics = append(h.recentPanics[:0], h.recentPanics[kept:]...)
+
+	return uint64(len(h.recentPanics))
+}
+
+func (h *Handler) shouldLog(fingerprint uint64) (bool, uint64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := h.timeSource.Now()
+	if last, ok := h.lastLogged[fingerprint]; ok && now.Sub(last) < h.logInterval {
+		h.suppressed[fingerprint]++
+		return false, 0
+	}
+
+	if _, ok := h.lastLogged[fingerprint]; !ok && len(h.lastLogged) >= maxTrackedFingerprints {
+		h.evictFingerprints(now)
+	}
+
+	suppressed := h.suppressed[fingerprint]
+	h.lastLogged[fingerprint] = now
+	delete(h.suppressed, fingerprint)
+	return true, suppressed
+}
+
+// evictFingerprints forgets the fingerprints last logged longer than the log interval
+// ago or, if there are none, the one logged least recently, to make room for another.
+// The suppressed panics of evicted fingerprints remain counted by the panics metric.
+func (h *Handler) evictFingerprints(now time.Time) {
+	var oldest uint64
+	var oldestLogged time.Time
+	evicted := false
+	for fingerprint, last := range h.lastLogged {
+		if now.Sub(last) >= h.logInterval {
+			delete(h.lastLogged, fingerprint)
+			delete(h.suppressed, fingerprint)
+			evicted = true
+		} else if oldestLogged.IsZero() || last.Before(oldestLogged) {
+			oldest, oldestLogged = fingerprint, last
+		}
+	}
+
+	if !evicted && !oldestLogged.IsZero() {
+		delete(h.lastLogged, oldest)
+		delete(h.suppressed, oldest)
+	}
+}
+
+// fingerprintPanic computes a stable identifier for a panic from its value and
+// the stack frames which led to it, excluding those of the runtime and this
+// package.
+func fingerprintPanic(p interface{}) uint64 {
+	var digest strings.Builder
+	fmt.Fprintf(&digest, "%T:%v", p, p)
+
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(0, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") &&
+			!strings.Contains(frame.Function, "/internal/middleware/recovery.") {
+			fmt.Fprintf(&digest, "|%s:%d", frame.Function, frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+
+	return xxhash.Sum64([]byte(digest.String()))
+}
+
+type ctxKeyType struct{}
+
+var handlerKey ctxKeyType = struct{}{}
+
+type handlerHandle struct {
+	handler *Handler
+	method  string
+}
+
+var defaultHandler = NewHandler()
+
+// HandlePanic handles the value returned by recover() for a panic which occurred in
+// a goroutine serving the request carried by the context, returning an error to be
+// reported in place of a result. The handler installed by the interceptors is used
+// if present.
+func HandlePanic(ctx context.Context, p interface{}) error {
+	if handle, ok := ctx.Value(handlerKey).(*handlerHandle); ok {
+		return handle.handler.Recover(ctx, handle.method, p)
+	}
+	return defaultHandler.Recover(ctx, "unknown", p)
+}
+
+// UnaryServerInterceptor returns a new interceptor which converts panics into
+// INTERNAL errors using the provided handler.
+func UnaryServerInterceptor(h *Handler) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx = context.WithValue(ctx, handlerKey, &handlerHandle{h, info.FullMethod})
+		defer func() {
+			if p := recover(); p != nil {
+				err = h.Recover(ctx, info.FullMethod, p)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which converts panics into
+// INTERNAL errors using the provided handler.
+func StreamServerInterceptor(h *Handler) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		wrapped := grpcmw.WrapServerStream(stream)
+		wrapped.WrappedContext = context.WithValue(stream.Context(), handlerKey, &handlerHandle{h, info.FullMethod})
+		defer func() {
+			if p := recover(); p != nil {
+				err = h.Recover(wrapped.WrappedContext, info.FullMethod, p)
+			}
+		}()
+
+		return handler(srv, wrapped)
+	}
+}
@@ -0,0 +1,146 @@
+package recovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryInterceptorRecovers(t *testing.T) {
+	require := require.New(t)
+
+	interceptor := UnaryServerInterceptor(NewHandler())
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Panic"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("poison request")
+	})
+	require.Error(err)
+	require.Equal(codes.Internal, status.Code(err))
+}
+
+func TestHandlePanicUsesContextHandler(t *testing.T) {
+	require := require.New(t)
+
+	exceeded := false
+	handler := NewHandler(CrashThreshold(2, func() { exceeded = true }))
+	interceptor := UnaryServerInterceptor(handler)
+
+	for i := 0; i < 2; i++ {
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Goroutine"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			errs := make(chan error, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						errs <- HandlePanic(ctx, p)
+					}
+				}()
+				panic("poison dispatch")
+			}()
+			return nil, <-errs
+		})
+		require.Equal(codes.Internal, status.Code(err))
+	}
+
+	require.True(exceeded)
+}
+
+func TestCrashThresholdWindow(t *testing.T) {
+	require := require.New(t)
+
+	exceeded := false
+	handler := NewHandler(CrashThreshold(3, func() { exceeded = true }), CrashWindow(time.Minute))
+	mockTime := clock.NewMock()
+	handler.timeSource = mockTime
+
+	// Panics spread further apart than the window never reach the threshold.
+	for i := 0; i < 10; i++ {
+		require.Equal(uint64(1), handler.countRecentPanic())
+		mockTime.Add(time.Minute)
+	}
+
+	require.Equal(uint64(1), handler.countRecentPanic())
+	mockTime.Add(30 * time.Second)
+	require.Equal(uint64(2), handler.countRecentPanic())
+	mockTime.Add(31 * time.Second)
+	require.Equal(uint64(2), handler.countRecentPanic())
+	require.False(exceeded)
+
+	// Only the threshold's worth of panics are remembered.
+	for i := 0; i < 10; i++ {
+		handler.Recover(context.Background(), "/test/Panic", "poison request")
+	}
+	require.Len(handler.recentPanics, 3)
+	require.True(exceeded)
+}
+
+func TestLogRateLimiting(t *testing.T) {
+	require := require.New(t)
+
+	handler := NewHandler(LogInterval(1 * time.Hour))
+
+	shouldLog, suppressed := handler.shouldLog(42)
+	require.True(shouldLog)
+	require.Equal(uint64(0), suppressed)
+
+	shouldLog, _ = handler.shouldLog(42)
+	require.False(shouldLog)
+
+	shouldLog, _ = handler.shouldLog(43)
+	require.True(shouldLog)
+
+	handler.lastLogged[42] = time.Now().Add(-2 * time.Hour)
+	shouldLog, suppressed = handler.shouldLog(42)
+	require.True(shouldLog)
+	require.Equal(uint64(1), suppressed)
+}
+
+func TestLogRateLimitingEvictsFingerprints(t *testing.T) {
+	require := require.New(t)
+
+	handler := NewHandler(LogInterval(1 * time.Hour))
+	mockTime := clock.NewMock()
+	handler.timeSource = mockTime
+
+	for fingerprint := uint64(0); fingerprint < maxTrackedFingerprints; fingerprint++ {
+		shouldLog, _ := handler.shouldLog(fingerprint)
+		require.True(shouldLog)
+		mockTime.Add(time.Second)
+	}
+	handler.shouldLog(1)
+	require.Equal(uint64(1), handler.suppressed[1])
+
+	// The least recently logged fingerprint makes room for a new one.
+	shouldLog, _ := handler.shouldLog(maxTrackedFingerprints)
+	require.True(shouldLog)
+	require.Len(handler.lastLogged, maxTrackedFingerprints)
+	require.NotContains(handler.lastLogged, uint64(0))
+
+	// Fingerprints whose interval has passed are all forgotten.
+	mockTime.Add(2 * time.Hour)
+	handler.shouldLog(maxTrackedFingerprints + 1)
+	require.Len(handler.lastLogged, 1)
+	require.Empty(handler.suppressed)
+}
+
+func TestFingerprintStable(t *testing.T) {
+	require := require.New(t)
+
+	fingerprint := func(value string) uint64 {
+		var result uint64
+		func() {
+			defer func() {
+				result = fingerprintPanic(recover())
+			}()
+			panic(value)
+		}()
+		return result
+	}
+
+	require.Equal(fingerprint("same"), fingerprint("same"))
+	require.NotEqual(fingerprint("same"), fingerprint("different"))
+}
@@ -0,0 +1,151 @@
+// Package subjectbinding rejects requests made with subject-bound preshared keys which
+// reference subjects outside of their binding.
+package subjectbinding
+
+import (
+	"context"
+	"fmt"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/auth"
+)
+
+// CheckRequest returns an error if a request made under the specified binding references
+// any subject which the binding does not allow.
+//
+// Only requests which are fully scoped to their subjects are supported: checks and lookups
+// on behalf of a subject, writes of relationships to a subject, and reads and deletes
+// filtered to a subject. All other requests, including schema reads and writes, expands and
+// watches, are rejected, since their results are not limited to any subject.
+func CheckRequest(binding auth.SubjectBinding, req interface{}) error {
+	switch typed := req.(type) {
+	case *v1.CheckPermissionRequest:
+		return checkSubjectReference(binding, typed.GetSubject())
+
+	case *v1.LookupResourcesRequest:
+		return checkSubjectReference(binding, typed.GetSubject())
+
+	case *v1.ReadRelationshipsRequest:
+		return checkRelationshipFilter(binding, typed.GetRelationshipFilter())
+
+	case *v1.WriteRelationshipsRequest:
+		for _, precondition := range typed.GetOptionalPreconditions() {
+			if err := checkRelationshipFilter(binding, precondition.GetFilter()); err != nil {
+				return err
+			}
+		}
+		for _, update := range typed.GetUpdates() {
+			if err := checkSubjectReference(binding, update.GetRelationship().GetSubject()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *v1.DeleteRelationshipsRequest:
+		for _, precondition := range typed.GetOptionalPreconditions() {
+			if err := checkRelationshipFilter(binding, precondition.GetFilter()); err != nil {
+				return err
+			}
+		}
+		return checkRelationshipFilter(binding, typed.GetRelationshipFilter())
+
+	case *v0.CheckRequest:
+		return checkSubject(binding, typed.GetUser().GetUserset().GetNamespace(), typed.GetUser().GetUserset().GetObjectId())
+
+	case *v0.ContentChangeCheckRequest:
+		return checkSubject(binding, typed.GetUser().GetUserset().GetNamespace(), typed.GetUser().GetUserset().GetObjectId())
+
+	case *v0.LookupRequest:
+		return checkSubject(binding, typed.GetUser().GetNamespace(), typed.GetUser().GetObjectId())
+
+	case *v0.WriteRequest:
+		for _, condition := range typed.GetWriteConditions() {
+			userset := condition.GetUser().GetUserset()
+			if err := checkSubject(binding, userset.GetNamespace(), userset.GetObjectId()); err != nil {
+				return err
+			}
+		}
+		for _, update := range typed.GetUpdates() {
+			userset := update.GetTuple().GetUser().GetUserset()
+			if err := checkSubject(binding, userset.GetNamespace(), userset.GetObjectId()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return status.Errorf(codes.PermissionDenied, "preshared key bound to subjects %s cannot make requests of type %T", binding, req)
+	}
+}
+
+func checkSubjectReference(binding auth.SubjectBinding, subject *v1.SubjectReference) error {
+	return checkSubject(binding, subject.GetObject().GetObjectType(), subject.GetObject().GetObjectId())
+}
+
+func checkRelationshipFilter(binding auth.SubjectBinding, filter *v1.RelationshipFilter) error {
+	subjectFilter := filter.GetOptionalSubjectFilter()
+	if subjectFilter == nil {
+		return status.Errorf(codes.PermissionDenied, "preshared key bound to subjects %s requires relationship filters to specify a subject", binding)
+	}
+	return checkSubject(binding, subjectFilter.GetSubjectType(), subjectFilter.GetOptionalSubjectId())
+}
+
+func checkSubject(binding auth.SubjectBinding, subjectType, objectID string) error {
+	if !binding.Allows(subjectType, objectID) {
+		return status.Errorf(codes.PermissionDenied, "preshared key bound to subjects %s cannot act on behalf of %s", binding, subjectString(subjectType, objectID))
+	}
+	return nil
+}
+
+func subjectString(subjectType, objectID string) string {
+	if objectID == "" {
+		return fmt.Sprintf("all subjects of type %s", subjectType)
+	}
+	return fmt.Sprintf("%s:%s", subjectType, objectID)
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that rejects requests made
+// with subject-bound preshared keys which reference subjects outside of their binding.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if binding, ok := auth.SubjectBindingFromContext(ctx); ok {
+			if err := CheckRequest(binding, req); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that rejects request
+// messages sent with subject-bound preshared keys which reference subjects outside of their
+// binding.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		binding, ok := auth.SubjectBindingFromContext(stream.Context())
+		if !ok {
+			return handler(srv, stream)
+		}
+
+		return handler(srv, &recvWrapper{stream, binding})
+	}
+}
+
+type recvWrapper struct {
+	grpc.ServerStream
+	binding auth.SubjectBinding
+}
+
+func (s *recvWrapper) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return CheckRequest(s.binding, m)
+}
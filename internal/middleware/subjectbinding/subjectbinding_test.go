@@ -0,0 +1,72 @@
+package subjectbinding
+
+import (
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/auth"
+)
+
+func subject(objectType, objectID string) *v1.SubjectReference {
+	return &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: objectType, ObjectId: objectID}}
+}
+
+func TestCheckRequest(t *testing.T) {
+	binding, err := auth.ParseSubjectBinding("user:edge-*")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		req     interface{}
+		allowed bool
+	}{
+		{"check for bound subject", &v1.CheckPermissionRequest{Subject: subject("user", "edge-alice")}, true},
+		{"check for other subject", &v1.CheckPermissionRequest{Subject: subject("user", "alice")}, false},
+		{"check for other subject type", &v1.CheckPermissionRequest{Subject: subject("group", "edge-admins")}, false},
+		{"lookup for bound subject", &v1.LookupResourcesRequest{Subject: subject("user", "edge-alice")}, true},
+		{
+			"write for bound subjects",
+			&v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{
+				{Relationship: &v1.Relationship{Subject: subject("user", "edge-alice")}},
+				{Relationship: &v1.Relationship{Subject: subject("user", "edge-bob")}},
+			}},
+			true,
+		},
+		{
+			"write for a wildcard",
+			&v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{
+				{Relationship: &v1.Relationship{Subject: subject("user", "*")}},
+			}},
+			false,
+		},
+		{
+			"read filtered to bound subject",
+			&v1.ReadRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{
+				ResourceType:          "document",
+				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "user", OptionalSubjectId: "edge-alice"},
+			}},
+			true,
+		},
+		{
+			"delete without subject filter",
+			&v1.DeleteRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document"}},
+			false,
+		},
+		{"expand", &v1.ExpandPermissionTreeRequest{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckRequest(binding, tc.req)
+			if tc.allowed {
+				require.NoError(t, err)
+				return
+			}
+			require.Equal(t, codes.PermissionDenied, status.Code(err))
+		})
+	}
+}
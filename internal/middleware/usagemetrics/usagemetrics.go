@@ -47,6 +47,63 @@ var cachedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
 	Help:      "dispatches avoid by caching.",
 }, []string{"method"})
 
+// DispatchSource is where the request which was dispatched originated.
+type DispatchSource string
+
+const (
+	// LocalDispatch is the source of requests made to the APIs of this server.
+	LocalDispatch DispatchSource = "local"
+
+	// RemoteDispatch is the source of requests dispatched to this server by its peers.
+	RemoteDispatch DispatchSource = "remote"
+)
+
+var depthBuckets = []float64{1, 2, 3, 5, 8, 13, 21, 34, 50}
+
+var ratioBuckets = []float64{0, 0.1, 0.25, 0.5, 0.75, 0.9, 1}
+
+var dispatchDepthHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "depth_required",
+	Help:      "distribution of the dispatch depth required to answer a request.",
+	Buckets:   depthBuckets,
+}, []string{"source", "method"})
+
+var dispatchSubproblemsHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "subproblems",
+	Help:      "distribution of the number of sub-problems, dispatched or found in cache, needed to answer a request.",
+	Buckets:   dispatchBuckets,
+}, []string{"source", "method"})
+
+var dispatchCacheHitRatioHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "cache_hit_ratio",
+	Help:      "distribution of the fraction of the sub-problems of a request which were found in cache.",
+	Buckets:   ratioBuckets,
+}, []string{"source", "method"})
+
+// ReportDispatch records the depth, sub-problems and cache efficiency of a request from the
+// source, by the metadata of its response. Requests which needed no sub-problems, such as
+// writes, are not recorded.
+func ReportDispatch(source DispatchSource, methodName string, metadata *dispatch.ResponseMeta) {
+	if metadata == nil {
+		return
+	}
+
+	subproblems := metadata.DispatchCount + metadata.CachedDispatchCount
+	if subproblems == 0 {
+		return
+	}
+
+	dispatchDepthHistogram.WithLabelValues(string(source), methodName).Observe(float64(metadata.DepthRequired))
+	dispatchSubproblemsHistogram.WithLabelValues(string(source), methodName).Observe(float64(subproblems))
+	dispatchCacheHitRatioHistogram.WithLabelValues(string(source), methodName).Observe(float64(metadata.CachedDispatchCount) / float64(subproblems))
+}
+
 type reporter struct{}
 
 func (r *reporter) ServerReporter(ctx context.Context, callMeta interceptors.CallMeta) (interceptors.Reporter, context.Context) {
@@ -94,6 +151,8 @@ func annotateAndReportForMetadata(ctx context.Context, methodName string, metada
 	dispatchedCounter.WithLabelValues(methodName).Add(float64(metadata.DispatchCount))
 	cachedCounter.WithLabelValues(methodName).Add(float64(metadata.CachedDispatchCount))
 
+	ReportDispatch(LocalDispatch, methodName, metadata)
+
 	return responsemeta.SetResponseTrailerMetadata(ctx, map[responsemeta.ResponseMetadataTrailerKey]string{
 		responsemeta.DispatchedOperationsCount: strconv.Itoa(int(metadata.DispatchCount)),
 		responsemeta.CachedOperationsCount:     strconv.Itoa(int(metadata.CachedDispatchCount)),
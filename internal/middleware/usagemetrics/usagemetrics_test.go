@@ -0,0 +1,57 @@
+package usagemetrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	dispatch "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+)
+
+func TestReportDispatch(t *testing.T) {
+	require := require.New(t)
+
+	ReportDispatch(LocalDispatch, "CheckPermission", &dispatch.ResponseMeta{
+		DispatchCount:       3,
+		CachedDispatchCount: 1,
+		DepthRequired:       4,
+	})
+	ReportDispatch(RemoteDispatch, "DispatchCheck", &dispatch.ResponseMeta{
+		CachedDispatchCount: 2,
+		DepthRequired:       1,
+	})
+
+	// Requests without sub-problems are not recorded.
+	ReportDispatch(LocalDispatch, "WriteRelationships", &dispatch.ResponseMeta{})
+	ReportDispatch(RemoteDispatch, "DispatchLookup", nil)
+
+	require.Equal(2, testutil.CollectAndCount(dispatchDepthHistogram))
+
+	expected := `
+# HELP spicedb_dispatch_cache_hit_ratio distribution of the fraction of the sub-problems of a request which were found in cache.
+# TYPE spicedb_dispatch_cache_hit_ratio histogram
+spicedb_dispatch_cache_hit_ratio_bucket{method="CheckPermission",source="local",le="0"} 0
+spicedb_dispatch_cache_hit_ratio_bucket{method="CheckPermission",source="local",le="0.1"} 0
+spicedb_dispatch_cache_hit_ratio_bucket{method="CheckPermission",source="local",le="0.25"} 1
+spicedb_dispatch_cache_hit_ratio_bucket{method="CheckPermission",source="local",le="0.5"} 1
+spicedb_dispatch_cache_hit_ratio_bucket{method="CheckPermission",source="local",le="0.75"} 1
+spicedb_dispatch_cache_hit_ratio_bucket{method="CheckPermission",source="local",le="0.9"} 1
+spicedb_dispatch_cache_hit_ratio_bucket{method="CheckPermission",source="local",le="1"} 1
+spicedb_dispatch_cache_hit_ratio_bucket{method="CheckPermission",source="local",le="+Inf"} 1
+spicedb_dispatch_cache_hit_ratio_sum{method="CheckPermission",source="local"} 0.25
+spicedb_dispatch_cache_hit_ratio_count{method="CheckPermission",source="local"} 1
+spicedb_dispatch_cache_hit_ratio_bucket{method="DispatchCheck",source="remote",le="0"} 0
+spicedb_dispatch_cache_hit_ratio_bucket{method="DispatchCheck",source="remote",le="0.1"} 0
+spicedb_dispatch_cache_hit_ratio_bucket{method="DispatchCheck",source="remote",le="0.25"} 0
+spicedb_dispatch_cache_hit_ratio_bucket{method="DispatchCheck",source="remote",le="0.5"} 0
+spicedb_dispatch_cache_hit_ratio_bucket{method="DispatchCheck",source="remote",le="0.75"} 0
+spicedb_dispatch_cache_hit_ratio_bucket{method="DispatchCheck",source="remote",le="0.9"} 0
+spicedb_dispatch_cache_hit_ratio_bucket{method="DispatchCheck",source="remote",le="1"} 1
+spicedb_dispatch_cache_hit_ratio_bucket{method="DispatchCheck",source="remote",le="+Inf"} 1
+spicedb_dispatch_cache_hit_ratio_sum{method="DispatchCheck",source="remote"} 1
+spicedb_dispatch_cache_hit_ratio_count{method="DispatchCheck",source="remote"} 1
+`
+	require.NoError(testutil.CollectAndCompare(dispatchCacheHitRatioHistogram, strings.NewReader(expected)))
+}
@@ -27,7 +27,10 @@ type cachingManager struct {
 	readNsGroup singleflight.Group
 }
 
-func cacheKey(nsName string, revision decimal.Decimal) string {
+func cacheKey(tenant, nsName string, revision decimal.Decimal) string {
+	if tenant != datastore.DefaultTenant {
+		return fmt.Sprintf("%s/%s@%s", tenant, nsName, revision)
+	}
 	return fmt.Sprintf("%s@%s", nsName, revision)
 }
 
@@ -72,7 +75,7 @@ func (nsc *cachingManager) ReadNamespace(ctx context.Context, nsName string, rev
 	defer span.End()
 
 	// Check the cache.
-	nsRevisionKey := cacheKey(nsName, revision)
+	nsRevisionKey := cacheKey(datastore.TenantFromContext(ctx), nsName, revision)
 	value, found := nsc.c.Get(nsRevisionKey)
 	if found {
 		return value.(*v0.NamespaceDefinition), nil
@@ -90,7 +93,7 @@ func (nsc *cachingManager) ReadNamespace(ctx context.Context, nsName string, rev
 		loaded = namespace.FilterUserDefinedMetadata(loaded)
 
 		// Save it to the cache
-		nsc.c.Set(cacheKey(nsName, revision), loaded, int64(proto.Size(loaded)))
+		nsc.c.Set(nsRevisionKey, loaded, int64(proto.Size(loaded)))
 		span.AddEvent("Saved to cache")
 
 		return loaded, err
@@ -0,0 +1,252 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// ReachabilityEdgeKind is the way in which a relation contributes subjects to another.
+type ReachabilityEdgeKind string
+
+const (
+	// DirectEdge is an edge to a type of subject allowed on the relation itself.
+	DirectEdge ReachabilityEdgeKind = "direct"
+
+	// ComputedEdge is an edge to another relation of the same object.
+	ComputedEdge ReachabilityEdgeKind = "computed"
+
+	// TupleToUsersetEdge is an edge to a relation of the objects found through a tupleset
+	// relation of the object.
+	TupleToUsersetEdge ReachabilityEdgeKind = "tuple_to_userset"
+)
+
+// ReachabilityEdge is an edge of a reachability graph, from a relation to a relation, or
+// type of subject, which contributes subjects to it.
+type ReachabilityEdge struct {
+	// From is the relation, as `namespace#relation`.
+	From string
+
+	// To is the relation, as `namespace#relation`, the type of subject, as
+	// `namespace#...`, or the wildcard, as `namespace:*`, contributing to it.
+	To string
+
+	// Kind is the way in which it contributes.
+	Kind ReachabilityEdgeKind
+
+	// Via is the tupleset relation through which a TupleToUsersetEdge is followed.
+	Via string
+
+	// Operation is the set operation of the relation in which the edge appears, if any.
+	Operation string
+
+	// Excluded is whether the subjects reached through the edge are removed from the
+	// relation, rather than added to it, as for those subtracted by an exclusion.
+	Excluded bool
+}
+
+// ReachabilityGraph is the graph of the relations, and types of subjects, which contribute
+// subjects to a relation, computed from the namespace definitions alone.
+type ReachabilityGraph struct {
+	// Nodes are the relations and types of subjects of the graph, sorted.
+	Nodes []string
+
+	// Edges are the edges of the graph, in the order they were found.
+	Edges []ReachabilityEdge
+}
+
+// HasEdgeTo returns whether any edge of the graph is to the relation of the namespace, such
+// as for a relation which reaches itself.
+func (rg *ReachabilityGraph) HasEdgeTo(namespaceName, relationName string) bool {
+	node := relationNode(namespaceName, relationName)
+	for _, edge := range rg.Edges {
+		if edge.To == node {
+			return true
+		}
+	}
+	return false
+}
+
+// Reachability computes the reachability graph of the relation of the namespace.
+func (nts *NamespaceTypeSystem) Reachability(ctx context.Context, relationName string) (*ReachabilityGraph, error) {
+	if !nts.HasRelation(relationName) {
+		return nil, fmt.Errorf("unknown relation/permission `%s` under permissions system `%s`", relationName, nts.nsDef.Name)
+	}
+
+	rb := &reachabilityBuilder{
+		nodes:   map[string]struct{}{},
+		visited: map[string]struct{}{},
+	}
+	if err := rb.visit(ctx, nts, relationName); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]string, 0, len(rb.nodes))
+	for node := range rb.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	return &ReachabilityGraph{Nodes: nodes, Edges: rb.edges}, nil
+}
+
+type reachabilityBuilder struct {
+	nodes   map[string]struct{}
+	visited map[string]struct{}
+	edges   []ReachabilityEdge
+	pending []*v0.RelationReference
+}
+
+func relationNode(namespaceName, relationName string) string {
+	return fmt.Sprintf("%s#%s", namespaceName, relationName)
+}
+
+func (rb *reachabilityBuilder) visit(ctx context.Context, nts *NamespaceTypeSystem, relationName string) error {
+	node := relationNode(nts.nsDef.Name, relationName)
+	if _, ok := rb.visited[node]; ok {
+		return nil
+	}
+	rb.visited[node] = struct{}{}
+	rb.nodes[node] = struct{}{}
+
+	relation := nts.relationMap[relationName]
+	if relation.UsersetRewrite == nil {
+		if err := rb.addDirect(nts, relationName, "", false); err != nil {
+			return err
+		}
+	} else if err := rb.addRewrite(ctx, nts, relationName, relation.UsersetRewrite, false); err != nil {
+		return err
+	}
+
+	// Relations reached are visited once this one is complete, so that the edges of each
+	// relation are listed together.
+	pending := rb.pending
+	rb.pending = nil
+	for _, next := range pending {
+		nextTS, err := nts.typeSystemForNamespace(ctx, next.Namespace)
+		if err != nil {
+			return err
+		}
+		if err := rb.visit(ctx, nextTS, next.Relation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rb *reachabilityBuilder) addEdge(edge ReachabilityEdge, next *v0.RelationReference) {
+	rb.edges = append(rb.edges, edge)
+	rb.nodes[edge.To] = struct{}{}
+	if next != nil {
+		rb.pending = append(rb.pending, next)
+	}
+}
+
+func (rb *reachabilityBuilder) addDirect(nts *NamespaceTypeSystem, relationName string, operation string, excluded bool) error {
+	allowed, err := nts.AllowedDirectRelationsAndWildcards(relationName)
+	if err != nil {
+		return err
+	}
+
+	from := relationNode(nts.nsDef.Name, relationName)
+	for _, allowedRelation := range allowed {
+		edge := ReachabilityEdge{From: from, Kind: DirectEdge, Operation: operation, Excluded: excluded}
+		if allowedRelation.GetPublicWildcard() != nil {
+			edge.To = fmt.Sprintf("%s:%s", allowedRelation.Namespace, tuple.PublicWildcard)
+			rb.addEdge(edge, nil)
+			continue
+		}
+
+		edge.To = relationNode(allowedRelation.Namespace, allowedRelation.GetRelation())
+		var next *v0.RelationReference
+		if allowedRelation.GetRelation() != tuple.Ellipsis {
+			next = &v0.RelationReference{Namespace: allowedRelation.Namespace, Relation: allowedRelation.GetRelation()}
+		}
+		rb.addEdge(edge, next)
+	}
+	return nil
+}
+
+func (rb *reachabilityBuilder) addRewrite(ctx context.Context, nts *NamespaceTypeSystem, relationName string, rewrite *v0.UsersetRewrite, excluded bool) error {
+	var operation string
+	var setOperation *v0.SetOperation
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *v0.UsersetRewrite_Union:
+		operation, setOperation = "union", rw.Union
+	case *v0.UsersetRewrite_Intersection:
+		operation, setOperation = "intersection", rw.Intersection
+	case *v0.UsersetRewrite_Exclusion:
+		operation, setOperation = "exclusion", rw.Exclusion
+	default:
+		return fmt.Errorf("unknown userset rewrite operation under relation `%s`", relationName)
+	}
+
+	from := relationNode(nts.nsDef.Name, relationName)
+	for index, childOneof := range setOperation.Child {
+		// The children of an exclusion after the first are subtracted from it.
+		childExcluded := excluded != (operation == "exclusion" && index > 0)
+
+		switch child := childOneof.ChildType.(type) {
+		case *v0.SetOperation_Child_XThis:
+			if err := rb.addDirect(nts, relationName, operation, childExcluded); err != nil {
+				return err
+			}
+
+		case *v0.SetOperation_Child_ComputedUserset:
+			rb.addEdge(ReachabilityEdge{
+				From:      from,
+				To:        relationNode(nts.nsDef.Name, child.ComputedUserset.Relation),
+				Kind:      ComputedEdge,
+				Operation: operation,
+				Excluded:  childExcluded,
+			}, &v0.RelationReference{Namespace: nts.nsDef.Name, Relation: child.ComputedUserset.Relation})
+
+		case *v0.SetOperation_Child_TupleToUserset:
+			tuplesetRelation := child.TupleToUserset.Tupleset.Relation
+			allowedSubjects, err := nts.AllowedSubjectRelations(tuplesetRelation)
+			if err != nil {
+				return err
+			}
+
+			// The relation is followed on the objects of the tupleset relation, whatever
+			// the relation of the subjects allowed on it.
+			targetRelation := child.TupleToUserset.ComputedUserset.Relation
+			followed := map[string]struct{}{}
+			for _, subject := range allowedSubjects {
+				if _, ok := followed[subject.Namespace]; ok {
+					continue
+				}
+				followed[subject.Namespace] = struct{}{}
+
+				subjectTS, err := nts.typeSystemForNamespace(ctx, subject.Namespace)
+				if err != nil {
+					return err
+				}
+
+				// Objects of a type without the relation contribute no subjects.
+				if !subjectTS.HasRelation(targetRelation) {
+					continue
+				}
+
+				rb.addEdge(ReachabilityEdge{
+					From:      from,
+					To:        relationNode(subject.Namespace, targetRelation),
+					Kind:      TupleToUsersetEdge,
+					Via:       tuplesetRelation,
+					Operation: operation,
+					Excluded:  childExcluded,
+				}, &v0.RelationReference{Namespace: subject.Namespace, Relation: targetRelation})
+			}
+
+		case *v0.SetOperation_Child_UsersetRewrite:
+			if err := rb.addRewrite(ctx, nts, relationName, child.UsersetRewrite, childExcluded); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
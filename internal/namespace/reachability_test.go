@@ -0,0 +1,128 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/stretchr/testify/require"
+
+	ns "github.com/authzed/spicedb/pkg/namespace"
+)
+
+func TestReachability(t *testing.T) {
+	userNS := ns.Namespace("user")
+	groupNS := ns.Namespace(
+		"group",
+		ns.Relation("member", nil, ns.AllowedRelation("user", "..."), ns.AllowedRelation("group", "member")),
+	)
+	folderNS := ns.Namespace(
+		"folder",
+		ns.Relation("viewer", nil, ns.AllowedRelation("user", "..."), ns.AllowedPublicNamespace("user")),
+	)
+	documentNS := ns.Namespace(
+		"document",
+		ns.Relation("parent", nil, ns.AllowedRelation("folder", "..."), ns.AllowedRelation("user", "...")),
+		ns.Relation("owner", nil, ns.AllowedRelation("user", "...")),
+		ns.Relation("banned", nil, ns.AllowedRelation("group", "member")),
+		ns.Relation("viewer", ns.Exclusion(
+			ns.Rewrite(ns.Union(
+				ns.ComputedUserset("owner"),
+				ns.TupleToUserset("parent", "viewer"),
+			)),
+			ns.ComputedUserset("banned"),
+		)),
+	)
+	allDefs := []*v0.NamespaceDefinition{userNS, groupNS, folderNS, documentNS}
+
+	testCases := []struct {
+		name          string
+		namespace     *v0.NamespaceDefinition
+		relation      string
+		expectedNodes []string
+		expectedEdges []ReachabilityEdge
+		reachesItself bool
+		expectedError string
+	}{
+		{
+			"direct relation",
+			documentNS,
+			"owner",
+			[]string{"document#owner", "user#..."},
+			[]ReachabilityEdge{
+				{From: "document#owner", To: "user#...", Kind: DirectEdge},
+			},
+			false,
+			"",
+		},
+		{
+			"recursive userset",
+			groupNS,
+			"member",
+			[]string{"group#member", "user#..."},
+			[]ReachabilityEdge{
+				{From: "group#member", To: "user#...", Kind: DirectEdge},
+				{From: "group#member", To: "group#member", Kind: DirectEdge},
+			},
+			true,
+			"",
+		},
+		{
+			"permission with exclusion and arrow",
+			documentNS,
+			"viewer",
+			[]string{
+				"document#banned",
+				"document#owner",
+				"document#viewer",
+				"folder#viewer",
+				"group#member",
+				"user#...",
+				"user:*",
+			},
+			[]ReachabilityEdge{
+				{From: "document#viewer", To: "document#owner", Kind: ComputedEdge, Operation: "union"},
+				{From: "document#viewer", To: "folder#viewer", Kind: TupleToUsersetEdge, Via: "parent", Operation: "union"},
+				{From: "document#viewer", To: "document#banned", Kind: ComputedEdge, Operation: "exclusion", Excluded: true},
+				{From: "document#owner", To: "user#...", Kind: DirectEdge},
+				{From: "folder#viewer", To: "user#...", Kind: DirectEdge},
+				{From: "folder#viewer", To: "user:*", Kind: DirectEdge},
+				{From: "document#banned", To: "group#member", Kind: DirectEdge},
+				{From: "group#member", To: "user#...", Kind: DirectEdge},
+				{From: "group#member", To: "group#member", Kind: DirectEdge},
+			},
+			false,
+			"",
+		},
+		{
+			"unknown relation",
+			documentNS,
+			"unknown",
+			nil,
+			nil,
+			false,
+			"unknown relation/permission `unknown` under permissions system `document`",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			ts, err := BuildNamespaceTypeSystemForDefs(tc.namespace, allDefs)
+			require.NoError(err)
+
+			graph, err := ts.Reachability(context.Background(), tc.relation)
+			if tc.expectedError != "" {
+				require.EqualError(err, tc.expectedError)
+				return
+			}
+
+			require.NoError(err)
+			require.Equal(tc.expectedNodes, graph.Nodes)
+			require.Equal(tc.expectedEdges, graph.Edges)
+			require.Equal(tc.reachesItself, graph.HasEdgeTo(tc.namespace.Name, tc.relation))
+		})
+	}
+}
@@ -0,0 +1,248 @@
+// Package otlpmetrics pushes the metrics of a Prometheus registry to an OpenTelemetry
+// collector over OTLP, for metrics backends which only accept pushed OTLP metrics.
+package otlpmetrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog/log"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const instrumentationName = "github.com/authzed/spicedb/internal/otlpmetrics"
+
+// exportTimeout bounds the final export made when the exporter is stopped.
+const exportTimeout = 5 * time.Second
+
+// Exporter periodically gathers the metrics of a Prometheus registry and exports them to an
+// OTLP metrics endpoint as cumulative metrics.
+type Exporter struct {
+	client   colmetricpb.MetricsServiceClient
+	gatherer prometheus.Gatherer
+	resource *resourcepb.Resource
+	headers  metadata.MD
+	start    time.Time
+}
+
+// Option configures an Exporter.
+type Option func(*Exporter)
+
+// WithHeaders sets headers sent with every export, such as the API key of a vendor.
+func WithHeaders(headers map[string]string) Option {
+	return func(e *Exporter) {
+		e.headers = metadata.New(headers)
+	}
+}
+
+// WithServiceName sets the service.name attribute of the resource of the exported metrics.
+func WithServiceName(name string) Option {
+	return func(e *Exporter) {
+		e.resource.Attributes = append(e.resource.Attributes, stringAttribute("service.name", name))
+	}
+}
+
+// NewExporter creates an Exporter of the metrics gathered by the gatherer to the OTLP
+// metrics service at the other end of the connection.
+func NewExporter(conn grpc.ClientConnInterface, gatherer prometheus.Gatherer, options ...Option) *Exporter {
+	e := &Exporter{
+		client:   colmetricpb.NewMetricsServiceClient(conn),
+		gatherer: gatherer,
+		resource: &resourcepb.Resource{},
+		start:    time.Now(),
+	}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+// Run exports the metrics every interval until the context is canceled, at which point
+// the metrics are exported one last time.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			exportCtx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			defer cancel()
+			if err := e.Export(exportCtx); err != nil {
+				log.Warn().Err(err).Msg("failed to export final otlp metrics")
+			}
+			return
+		case <-ticker.C:
+			exportCtx, cancel := context.WithTimeout(ctx, interval)
+			if err := e.Export(exportCtx); err != nil {
+				log.Warn().Err(err).Msg("failed to export otlp metrics")
+			}
+			cancel()
+		}
+	}
+}
+
+// Export gathers the metrics and exports them.
+func (e *Exporter) Export(ctx context.Context) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("unable to gather metrics: %w", err)
+	}
+
+	request := &colmetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{{
+			Resource: e.resource,
+			InstrumentationLibraryMetrics: []*metricpb.InstrumentationLibraryMetrics{{
+				InstrumentationLibrary: &commonpb.InstrumentationLibrary{Name: instrumentationName},
+				Metrics:                convertFamilies(families, e.start, time.Now()),
+			}},
+		}},
+	}
+
+	if len(e.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, e.headers)
+	}
+	if _, err := e.client.Export(ctx, request); err != nil {
+		return fmt.Errorf("unable to export metrics: %w", err)
+	}
+	return nil
+}
+
+// convertFamilies converts Prometheus metric families to OTLP metrics. Counters become
+// monotonic sums, gauges and untyped metrics become gauges, and histograms and summaries
+// keep their type, all cumulative since the start time.
+func convertFamilies(families []*dto.MetricFamily, start, now time.Time) []*metricpb.Metric {
+	startNanos, nowNanos := uint64(start.UnixNano()), uint64(now.UnixNano())
+
+	metrics := make([]*metricpb.Metric, 0, len(families))
+	for _, family := range families {
+		metric := &metricpb.Metric{
+			Name:        family.GetName(),
+			Description: family.GetHelp(),
+		}
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			points := make([]*metricpb.NumberDataPoint, 0, len(family.GetMetric()))
+			for _, m := range family.GetMetric() {
+				points = append(points, numberPoint(m, m.GetCounter().GetValue(), startNanos, nowNanos))
+			}
+			metric.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+				AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+				DataPoints:             points,
+			}}
+
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			points := make([]*metricpb.NumberDataPoint, 0, len(family.GetMetric()))
+			for _, m := range family.GetMetric() {
+				value := m.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = m.GetUntyped().GetValue()
+				}
+				points = append(points, numberPoint(m, value, startNanos, nowNanos))
+			}
+			metric.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: points}}
+
+		case dto.MetricType_HISTOGRAM:
+			points := make([]*metricpb.HistogramDataPoint, 0, len(family.GetMetric()))
+			for _, m := range family.GetMetric() {
+				points = append(points, histogramPoint(m, startNanos, nowNanos))
+			}
+			metric.Data = &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{
+				AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints:             points,
+			}}
+
+		case dto.MetricType_SUMMARY:
+			points := make([]*metricpb.SummaryDataPoint, 0, len(family.GetMetric()))
+			for _, m := range family.GetMetric() {
+				summary := m.GetSummary()
+				quantiles := make([]*metricpb.SummaryDataPoint_ValueAtQuantile, 0, len(summary.GetQuantile()))
+				for _, q := range summary.GetQuantile() {
+					quantiles = append(quantiles, &metricpb.SummaryDataPoint_ValueAtQuantile{
+						Quantile: q.GetQuantile(),
+						Value:    q.GetValue(),
+					})
+				}
+				points = append(points, &metricpb.SummaryDataPoint{
+					Attributes:        attributes(m.GetLabel()),
+					StartTimeUnixNano: startNanos,
+					TimeUnixNano:      nowNanos,
+					Count:             summary.GetSampleCount(),
+					Sum:               summary.GetSampleSum(),
+					QuantileValues:    quantiles,
+				})
+			}
+			metric.Data = &metricpb.Metric_Summary{Summary: &metricpb.Summary{DataPoints: points}}
+
+		default:
+			continue
+		}
+
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+func numberPoint(m *dto.Metric, value float64, startNanos, nowNanos uint64) *metricpb.NumberDataPoint {
+	return &metricpb.NumberDataPoint{
+		Attributes:        attributes(m.GetLabel()),
+		StartTimeUnixNano: startNanos,
+		TimeUnixNano:      nowNanos,
+		Value:             &metricpb.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+// histogramPoint converts the cumulative buckets of a Prometheus histogram to the counts of
+// each bucket of an OTLP histogram, the last of which counts the values above every bound.
+func histogramPoint(m *dto.Metric, startNanos, nowNanos uint64) *metricpb.HistogramDataPoint {
+	histogram := m.GetHistogram()
+
+	bounds := make([]float64, 0, len(histogram.GetBucket()))
+	counts := make([]uint64, 0, len(histogram.GetBucket())+1)
+	var previous uint64
+	for _, bucket := range histogram.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		bounds = append(bounds, bucket.GetUpperBound())
+		counts = append(counts, bucket.GetCumulativeCount()-previous)
+		previous = bucket.GetCumulativeCount()
+	}
+	counts = append(counts, histogram.GetSampleCount()-previous)
+
+	return &metricpb.HistogramDataPoint{
+		Attributes:        attributes(m.GetLabel()),
+		StartTimeUnixNano: startNanos,
+		TimeUnixNano:      nowNanos,
+		Count:             histogram.GetSampleCount(),
+		Sum:               histogram.GetSampleSum(),
+		BucketCounts:      counts,
+		ExplicitBounds:    bounds,
+	}
+}
+
+func attributes(labels []*dto.LabelPair) []*commonpb.KeyValue {
+	converted := make([]*commonpb.KeyValue, 0, len(labels))
+	for _, label := range labels {
+		converted = append(converted, stringAttribute(label.GetName(), label.GetValue()))
+	}
+	return converted
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
@@ -0,0 +1,88 @@
+package otlpmetrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type fakeMetricsClient struct {
+	requests []*colmetricpb.ExportMetricsServiceRequest
+	headers  []metadata.MD
+}
+
+func (f *fakeMetricsClient) Export(ctx context.Context, in *colmetricpb.ExportMetricsServiceRequest, opts ...grpc.CallOption) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	f.requests = append(f.requests, in)
+	f.headers = append(f.headers, md)
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestExport(t *testing.T) {
+	require := require.New(t)
+
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "requests"}, []string{"method"})
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "in_flight"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency_seconds", Buckets: []float64{0.1, 1}})
+	registry.MustRegister(counter, gauge, histogram)
+
+	counter.WithLabelValues("check").Add(3)
+	gauge.Set(2)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(0.7)
+	histogram.Observe(5)
+
+	client := &fakeMetricsClient{}
+	exporter := NewExporter(nil, registry, WithServiceName("spicedb"), WithHeaders(map[string]string{"api-key": "secret"}))
+	exporter.client = client
+
+	require.NoError(exporter.Export(context.Background()))
+	require.Len(client.requests, 1)
+	require.Equal([]string{"secret"}, client.headers[0].Get("api-key"))
+
+	resourceMetrics := client.requests[0].ResourceMetrics[0]
+	require.Equal("service.name", resourceMetrics.Resource.Attributes[0].Key)
+	require.Equal("spicedb", resourceMetrics.Resource.Attributes[0].Value.GetStringValue())
+
+	metrics := make(map[string]*metricpb.Metric)
+	for _, metric := range resourceMetrics.InstrumentationLibraryMetrics[0].Metrics {
+		metrics[metric.Name] = metric
+	}
+	require.Len(metrics, 3)
+
+	sum := metrics["requests_total"].GetSum()
+	require.True(sum.IsMonotonic)
+	require.Equal(metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, sum.AggregationTemporality)
+	require.Equal(3.0, sum.DataPoints[0].GetAsDouble())
+	require.Equal("method", sum.DataPoints[0].Attributes[0].Key)
+	require.Equal("check", sum.DataPoints[0].Attributes[0].Value.GetStringValue())
+
+	require.Equal(2.0, metrics["in_flight"].GetGauge().DataPoints[0].GetAsDouble())
+
+	point := metrics["latency_seconds"].GetHistogram().DataPoints[0]
+	require.Equal(uint64(4), point.Count)
+	require.InDelta(6.25, point.Sum, 0.0001)
+	require.Equal([]float64{0.1, 1}, point.ExplicitBounds)
+	require.Equal([]uint64{1, 2, 1}, point.BucketCounts)
+}
+
+func TestRunExportsOnStop(t *testing.T) {
+	client := &fakeMetricsClient{}
+	exporter := NewExporter(nil, prometheus.NewRegistry())
+	exporter.client = client
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	exporter.Run(ctx, time.Hour)
+
+	require.Len(t, client.requests, 1)
+}
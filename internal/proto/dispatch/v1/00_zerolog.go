@@ -6,13 +6,13 @@ import (
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	"github.com/rs/zerolog"
 
-	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/internal/redact"
 )
 
 // MarshalZerologObject implements zerolog object marshalling.
 func (cr *DispatchCheckRequest) MarshalZerologObject(e *zerolog.Event) {
 	e.Object("metadata", cr.Metadata)
-	e.Str("request", tuple.String(&v0.RelationTuple{
+	e.Str("request", redact.Tuple(&v0.RelationTuple{
 		ObjectAndRelation: cr.ObjectAndRelation,
 		User: &v0.User{
 			UserOneof: &v0.User_Userset{
@@ -31,7 +31,7 @@ func (cr *DispatchCheckResponse) MarshalZerologObject(e *zerolog.Event) {
 // MarshalZerologObject implements zerolog object marshalling.
 func (er *DispatchExpandRequest) MarshalZerologObject(e *zerolog.Event) {
 	e.Object("metadata", er.Metadata)
-	e.Str("expand", tuple.StringONR(er.ObjectAndRelation))
+	e.Str("expand", redact.ONR(er.ObjectAndRelation))
 	e.Stringer("mode", er.ExpansionMode)
 }
 
@@ -44,7 +44,7 @@ func (cr *DispatchExpandResponse) MarshalZerologObject(e *zerolog.Event) {
 func (lr *DispatchLookupRequest) MarshalZerologObject(e *zerolog.Event) {
 	e.Object("metadata", lr.Metadata)
 	e.Str("object", fmt.Sprintf("%s#%s", lr.ObjectRelation.Namespace, lr.ObjectRelation.Relation))
-	e.Str("subject", tuple.StringONR(lr.Subject))
+	e.Str("subject", redact.ONR(lr.Subject))
 	e.Array("direct", onArray(lr.DirectStack))
 	e.Array("ttu", onArray(lr.TtuStack))
 	e.Uint32("limit", lr.Limit)
@@ -0,0 +1,179 @@
+// Package queuemetrics reports the occupancy of the bounded queues, worker pools and
+// batches of SpiceDB under a single naming scheme, so that the stage which is the
+// bottleneck can be told apart from the others:
+//
+//	spicedb_queue_capacity{queue}          total capacity of the live instances of the queue
+//	spicedb_queue_length{queue}            total number of items in, or workers of, them
+//	spicedb_queue_saturation_ratio{queue}  length over capacity
+//	spicedb_queue_instances{queue}         number of live instances
+//	spicedb_queue_wait_seconds{queue}      time spent waiting to enter the queue
+//	spicedb_queue_batch_fill_ratio{queue}  size of each batch over the maximum size
+//
+// A queue whose saturation ratio stays near 1 is full, and whatever feeds it is held up,
+// which can be alerted upon with a rule such as:
+//
+//	min_over_time(spicedb_queue_saturation_ratio[5m]) > 0.9
+package queuemetrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	prometheusNamespace = "spicedb"
+	prometheusSubsystem = "queue"
+)
+
+var (
+	capacityDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(prometheusNamespace, prometheusSubsystem, "capacity"),
+		"total capacity of the live instances of the queue.",
+		[]string{"queue"}, nil,
+	)
+	lengthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(prometheusNamespace, prometheusSubsystem, "length"),
+		"total number of items in, or busy workers of, the live instances of the queue.",
+		[]string{"queue"}, nil,
+	)
+	saturationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(prometheusNamespace, prometheusSubsystem, "saturation_ratio"),
+		"length of the queue over its capacity.",
+		[]string{"queue"}, nil,
+	)
+	instancesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(prometheusNamespace, prometheusSubsystem, "instances"),
+		"number of live instances of the queue.",
+		[]string{"queue"}, nil,
+	)
+
+	waitHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "wait_seconds",
+		Help:      "time spent waiting to enter the queue, or for a worker of the pool.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"queue"})
+
+	batchFillHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "batch_fill_ratio",
+		Help:      "size of each batch processed by the stage over the maximum size of a batch.",
+		Buckets:   []float64{.1, .25, .5, .75, .9, 1},
+	}, []string{"queue"})
+)
+
+var defaultCollector = newCollector()
+
+func init() {
+	prometheus.MustRegister(defaultCollector)
+}
+
+// Queue is a bounded queue, worker pool or batching stage, of which there may be any
+// number of live instances.
+type Queue struct {
+	name      string
+	collector *collector
+}
+
+// NewQueue returns the queue with the name, which is used as the value of the queue label
+// of its metrics. Queues of the same name share their metrics.
+func NewQueue(name string) *Queue {
+	return &Queue{name, defaultCollector}
+}
+
+// Track reports an instance of the queue, whose length is read when metrics are collected,
+// until the returned function is called.
+func (q *Queue) Track(length func() int, capacity int) (untrack func()) {
+	inst := &instance{length, capacity}
+	q.collector.add(q.name, inst)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.collector.remove(q.name, inst)
+		})
+	}
+}
+
+// ObserveWait records the time spent waiting to enter the queue.
+func (q *Queue) ObserveWait(waited time.Duration) {
+	waitHistogram.WithLabelValues(q.name).Observe(waited.Seconds())
+}
+
+// ObserveBatch records the size of a batch processed by the stage, relative to the
+// maximum size of a batch.
+func (q *Queue) ObserveBatch(size, maxSize int) {
+	if maxSize <= 0 {
+		return
+	}
+	batchFillHistogram.WithLabelValues(q.name).Observe(float64(size) / float64(maxSize))
+}
+
+type instance struct {
+	length   func() int
+	capacity int
+}
+
+type collector struct {
+	sync.Mutex
+	queues map[string]map[*instance]struct{}
+}
+
+func newCollector() *collector {
+	return &collector{queues: make(map[string]map[*instance]struct{})}
+}
+
+func (c *collector) add(name string, inst *instance) {
+	c.Lock()
+	defer c.Unlock()
+
+	instances, ok := c.queues[name]
+	if !ok {
+		instances = make(map[*instance]struct{})
+		c.queues[name] = instances
+	}
+	instances[inst] = struct{}{}
+}
+
+func (c *collector) remove(name string, inst *instance) {
+	c.Lock()
+	defer c.Unlock()
+
+	// The queue itself is kept, so that it reports being empty rather than disappearing.
+	delete(c.queues[name], inst)
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- capacityDesc
+	ch <- lengthDesc
+	ch <- saturationDesc
+	ch <- instancesDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	defer c.Unlock()
+
+	for name, instances := range c.queues {
+		var length, capacity int
+		for inst := range instances {
+			length += inst.length()
+			capacity += inst.capacity
+		}
+
+		var saturation float64
+		if capacity > 0 {
+			saturation = float64(length) / float64(capacity)
+		}
+
+		ch <- prometheus.MustNewConstMetric(capacityDesc, prometheus.GaugeValue, float64(capacity), name)
+		ch <- prometheus.MustNewConstMetric(lengthDesc, prometheus.GaugeValue, float64(length), name)
+		ch <- prometheus.MustNewConstMetric(saturationDesc, prometheus.GaugeValue, saturation, name)
+		ch <- prometheus.MustNewConstMetric(instancesDesc, prometheus.GaugeValue, float64(len(instances)), name)
+	}
+}
@@ -0,0 +1,60 @@
+package queuemetrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollect(t *testing.T) {
+	require := require.New(t)
+
+	c := newCollector()
+	queue := &Queue{"test", c}
+
+	first := make(chan struct{}, 4)
+	second := make(chan struct{}, 4)
+	untrackFirst := queue.Track(func() int { return len(first) }, cap(first))
+	untrackSecond := queue.Track(func() int { return len(second) }, cap(second))
+
+	first <- struct{}{}
+	first <- struct{}{}
+	second <- struct{}{}
+	second <- struct{}{}
+
+	require.NoError(testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP spicedb_queue_capacity total capacity of the live instances of the queue.
+# TYPE spicedb_queue_capacity gauge
+spicedb_queue_capacity{queue="test"} 8
+# HELP spicedb_queue_instances number of live instances of the queue.
+# TYPE spicedb_queue_instances gauge
+spicedb_queue_instances{queue="test"} 2
+# HELP spicedb_queue_length total number of items in, or busy workers of, the live instances of the queue.
+# TYPE spicedb_queue_length gauge
+spicedb_queue_length{queue="test"} 4
+# HELP spicedb_queue_saturation_ratio length of the queue over its capacity.
+# TYPE spicedb_queue_saturation_ratio gauge
+spicedb_queue_saturation_ratio{queue="test"} 0.5
+`)))
+
+	untrackFirst()
+	untrackSecond()
+	untrackSecond()
+
+	require.NoError(testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP spicedb_queue_capacity total capacity of the live instances of the queue.
+# TYPE spicedb_queue_capacity gauge
+spicedb_queue_capacity{queue="test"} 0
+# HELP spicedb_queue_instances number of live instances of the queue.
+# TYPE spicedb_queue_instances gauge
+spicedb_queue_instances{queue="test"} 0
+# HELP spicedb_queue_length total number of items in, or busy workers of, the live instances of the queue.
+# TYPE spicedb_queue_length gauge
+spicedb_queue_length{queue="test"} 0
+# HELP spicedb_queue_saturation_ratio length of the queue over its capacity.
+# TYPE spicedb_queue_saturation_ratio gauge
+spicedb_queue_saturation_ratio{queue="test"} 0
+`)))
+}
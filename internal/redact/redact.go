@@ -0,0 +1,214 @@
+// Package redact provides obfuscation of object and subject IDs, and optionally of other
+// classes of values, before they are recorded in logs, traces, metrics and the messages of
+// errors. By default, only IDs are obfuscated, so telemetry remains useful when IDs contain
+// sensitive data.
+package redact
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/cespare/xxhash"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// Mode determines how IDs are obfuscated.
+type Mode int
+
+const (
+	// ModeNone records IDs as-is.
+	ModeNone Mode = iota
+
+	// ModeHash replaces IDs with a salted hash, which allows correlating the same
+	// ID across telemetry without revealing it.
+	ModeHash
+
+	// ModeRedact replaces IDs with a fixed placeholder.
+	ModeRedact
+)
+
+// Redacted is the placeholder recorded in place of IDs in ModeRedact.
+const Redacted = "<redacted>"
+
+var modeNames = map[string]Mode{
+	"none":   ModeNone,
+	"hash":   ModeHash,
+	"redact": ModeRedact,
+}
+
+// Class is a class of values which may be obfuscated.
+type Class string
+
+const (
+	// ClassIDs are object and subject IDs.
+	ClassIDs Class = "ids"
+
+	// ClassTenants are the names of tenants.
+	ClassTenants Class = "tenants"
+
+	// ClassTypes are the names of object and subject types.
+	ClassTypes Class = "types"
+
+	// ClassRelations are the names of relations and permissions.
+	ClassRelations Class = "relations"
+)
+
+var allClasses = []Class{ClassIDs, ClassTenants, ClassTypes, ClassRelations}
+
+// ParseClasses parses the names of classes of values.
+func ParseClasses(names []string) ([]Class, error) {
+	classes := make([]Class, 0, len(names))
+	for _, name := range names {
+		class, ok := parseClass(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown obfuscated value class `%s`: must be one of ids, tenants, types or relations", name)
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+func parseClass(name string) (Class, bool) {
+	for _, class := range allClasses {
+		if string(class) == strings.ToLower(name) {
+			return class, true
+		}
+	}
+	return "", false
+}
+
+// ParseMode parses the name of an obfuscation mode.
+func ParseMode(name string) (Mode, error) {
+	mode, ok := modeNames[strings.ToLower(name)]
+	if !ok {
+		return ModeNone, fmt.Errorf("unknown ID obfuscation mode `%s`: must be one of none, hash or redact", name)
+	}
+	return mode, nil
+}
+
+type config struct {
+	mode    Mode
+	salt    string
+	classes map[Class]struct{}
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(config{mode: ModeNone})
+}
+
+// Configure sets the obfuscation applied to the classes of values process-wide, or to IDs
+// alone if no classes are given. The salt is only used in ModeHash.
+func Configure(mode Mode, salt string, classes ...Class) {
+	if len(classes) == 0 {
+		classes = []Class{ClassIDs}
+	}
+
+	classSet := make(map[Class]struct{}, len(classes))
+	for _, class := range classes {
+		classSet[class] = struct{}{}
+	}
+	current.Store(config{mode: mode, salt: salt, classes: classSet})
+}
+
+// Enabled returns whether any values are obfuscated. Values which cannot be obfuscated
+// individually, such as query plans, should not be recorded when it is.
+func Enabled() bool {
+	return current.Load().(config).mode != ModeNone
+}
+
+// Value returns the form of a value of the class which may be recorded in telemetry.
+func Value(class Class, value string) string {
+	cfg := current.Load().(config)
+	if _, ok := cfg.classes[class]; !ok {
+		return value
+	}
+
+	switch cfg.mode {
+	case ModeHash:
+		return fmt.Sprintf("h:%016x", xxhash.Sum64([]byte(cfg.salt+value)))
+	case ModeRedact:
+		return Redacted
+	default:
+		return value
+	}
+}
+
+// ID returns the form of an object or subject ID which may be recorded in
+// telemetry.
+func ID(id string) string {
+	return Value(ClassIDs, id)
+}
+
+// Tenant returns the form of the name of a tenant which may be recorded in telemetry.
+func Tenant(tenant string) string {
+	return Value(ClassTenants, tenant)
+}
+
+// Type returns the form of the name of an object or subject type which may be recorded in
+// telemetry.
+func Type(objectType string) string {
+	return Value(ClassTypes, objectType)
+}
+
+// Relation returns the form of the name of a relation or permission which may be recorded
+// in telemetry. The ellipsis relation of subjects is never obfuscated.
+func Relation(relation string) string {
+	if relation == tuple.Ellipsis {
+		return relation
+	}
+	return Value(ClassRelations, relation)
+}
+
+// ONR returns the string form of an object and relation with its ID obfuscated.
+func ONR(onr *v0.ObjectAndRelation) string {
+	if onr == nil {
+		return ""
+	}
+	return tuple.StringONR(&v0.ObjectAndRelation{
+		Namespace: Type(onr.Namespace),
+		ObjectId:  ID(onr.ObjectId),
+		Relation:  Relation(onr.Relation),
+	})
+}
+
+// Tuple returns the string form of a relation tuple with its IDs obfuscated.
+func Tuple(tpl *v0.RelationTuple) string {
+	if tpl == nil || tpl.ObjectAndRelation == nil || tpl.User == nil || tpl.User.GetUserset() == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s@%s", ONR(tpl.ObjectAndRelation), ONR(tpl.User.GetUserset()))
+}
+
+// RelationshipFilter returns the string form of a relationship filter with its values
+// obfuscated, in which the fields of the filter which are not set are omitted.
+func RelationshipFilter(filter *v1.RelationshipFilter) string {
+	if filter == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(Type(filter.ResourceType))
+	if filter.OptionalResourceId != "" {
+		sb.WriteString(":" + ID(filter.OptionalResourceId))
+	}
+	if filter.OptionalRelation != "" {
+		sb.WriteString("#" + Relation(filter.OptionalRelation))
+	}
+
+	if subjectFilter := filter.OptionalSubjectFilter; subjectFilter != nil {
+		sb.WriteString("@" + Type(subjectFilter.SubjectType))
+		if subjectFilter.OptionalSubjectId != "" {
+			sb.WriteString(":" + ID(subjectFilter.OptionalSubjectId))
+		}
+		if subjectFilter.OptionalRelation != nil && subjectFilter.OptionalRelation.Relation != "" {
+			sb.WriteString("#" + Relation(subjectFilter.OptionalRelation.Relation))
+		}
+	}
+	return sb.String()
+}
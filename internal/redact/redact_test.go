@@ -0,0 +1,94 @@
+package redact
+
+import (
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestObfuscation(t *testing.T) {
+	defer Configure(ModeNone, "")
+
+	tpl := tuple.MustParse("document:secret#viewer@user:alice#...")
+
+	Configure(ModeNone, "")
+	require.Equal(t, "alice", ID("alice"))
+	require.Equal(t, tuple.String(tpl), Tuple(tpl))
+
+	Configure(ModeRedact, "")
+	require.Equal(t, Redacted, ID("alice"))
+	require.Equal(t, "document:<redacted>#viewer@user:<redacted>#...", Tuple(tpl))
+
+	Configure(ModeHash, "somesalt")
+	hashed := ID("alice")
+	require.NotContains(t, hashed, "alice")
+	require.Equal(t, hashed, ID("alice"))
+	require.NotEqual(t, hashed, ID("bob"))
+	require.Equal(t, "user:"+hashed+"#...", ONR(tpl.User.GetUserset()))
+
+	Configure(ModeHash, "othersalt")
+	require.NotEqual(t, hashed, ID("alice"))
+}
+
+func TestParseMode(t *testing.T) {
+	for name, expected := range map[string]Mode{"none": ModeNone, "hash": ModeHash, "REDACT": ModeRedact} {
+		mode, err := ParseMode(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, mode)
+	}
+
+	_, err := ParseMode("encrypt")
+	require.Error(t, err)
+}
+
+func TestObfuscatedClasses(t *testing.T) {
+	defer Configure(ModeNone, "")
+
+	tpl := tuple.MustParse("document:secret#viewer@user:alice#...")
+
+	Configure(ModeRedact, "", ClassIDs, ClassTypes, ClassRelations)
+	require.Equal(t, "<redacted>:<redacted>#<redacted>@<redacted>:<redacted>#...", Tuple(tpl))
+	require.Equal(t, "acme", Tenant("acme"))
+	require.True(t, Enabled())
+
+	Configure(ModeHash, "somesalt", ClassTenants)
+	require.Equal(t, tuple.String(tpl), Tuple(tpl))
+	require.NotEqual(t, "acme", Tenant("acme"))
+	require.Equal(t, Tenant("acme"), Tenant("acme"))
+
+	Configure(ModeNone, "", ClassIDs, ClassTenants)
+	require.Equal(t, "acme", Tenant("acme"))
+	require.False(t, Enabled())
+}
+
+func TestParseClasses(t *testing.T) {
+	classes, err := ParseClasses([]string{"ids", "TENANTS"})
+	require.NoError(t, err)
+	require.Equal(t, []Class{ClassIDs, ClassTenants}, classes)
+
+	_, err = ParseClasses([]string{"emails"})
+	require.Error(t, err)
+}
+
+func TestRelationshipFilter(t *testing.T) {
+	defer Configure(ModeNone, "")
+
+	filter := &v1.RelationshipFilter{
+		ResourceType:       "document",
+		OptionalResourceId: "secret",
+		OptionalSubjectFilter: &v1.SubjectFilter{
+			SubjectType:       "user",
+			OptionalSubjectId: "alice",
+			OptionalRelation:  &v1.SubjectFilter_RelationFilter{},
+		},
+	}
+
+	require.Equal(t, "document:secret@user:alice", RelationshipFilter(filter))
+
+	Configure(ModeRedact, "")
+	require.Equal(t, "document:<redacted>@user:<redacted>", RelationshipFilter(filter))
+	require.Equal(t, "folder#viewer", RelationshipFilter(&v1.RelationshipFilter{ResourceType: "folder", OptionalRelation: "viewer"}))
+}
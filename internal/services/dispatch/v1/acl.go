@@ -10,8 +10,10 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/graph"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
 	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
 )
 
@@ -37,16 +39,19 @@ func NewDispatchServer(localDispatch dispatch.Dispatcher) v1.DispatchServiceServ
 
 func (ds *dispatchServer) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
 	resp, err := ds.localDispatch.DispatchCheck(ctx, req)
+	usagemetrics.ReportDispatch(usagemetrics.RemoteDispatch, "DispatchCheck", resp.GetMetadata())
 	return resp, rewriteGraphError(ctx, err)
 }
 
 func (ds *dispatchServer) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
 	resp, err := ds.localDispatch.DispatchExpand(ctx, req)
+	usagemetrics.ReportDispatch(usagemetrics.RemoteDispatch, "DispatchExpand", resp.GetMetadata())
 	return resp, rewriteGraphError(ctx, err)
 }
 
 func (ds *dispatchServer) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
 	resp, err := ds.localDispatch.DispatchLookup(ctx, req)
+	usagemetrics.ReportDispatch(usagemetrics.RemoteDispatch, "DispatchLookup", resp.GetMetadata())
 	return resp, rewriteGraphError(ctx, err)
 }
 
@@ -55,6 +60,9 @@ func rewriteGraphError(ctx context.Context, err error) error {
 	case errors.As(err, &graph.ErrRequestCanceled{}):
 		return status.Errorf(codes.Canceled, "request canceled: %s", err)
 
+	case errors.As(err, &datastore.ErrQueryTimeout{}):
+		return status.Errorf(codes.DeadlineExceeded, "%s", err)
+
 	case err == nil:
 		return nil
 
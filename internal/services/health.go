@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/authzed/grpcutil"
+	"github.com/rs/zerolog/log"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+// serverHealthService is the name under which the health of the server as a whole is
+// reported, as checked by health probes which do not name a service.
+const serverHealthService = ""
+
+// DatastoreReadinessChecker periodically checks whether the datastore is ready, reporting
+// the server as not serving through its health service while it is not.
+type DatastoreReadinessChecker struct {
+	healthSrv *grpcutil.AuthlessHealthServer
+	ds        datastore.Datastore
+	interval  time.Duration
+}
+
+// NewDatastoreReadinessChecker creates a readiness checker of the datastore which reports
+// to the health server.
+func NewDatastoreReadinessChecker(healthSrv *grpcutil.AuthlessHealthServer, ds datastore.Datastore, interval time.Duration) *DatastoreReadinessChecker {
+	return &DatastoreReadinessChecker{
+		healthSrv: healthSrv,
+		ds:        ds,
+		interval:  interval,
+	}
+}
+
+// Run checks the datastore immediately and then every interval until the context is
+// canceled.
+func (drc *DatastoreReadinessChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(drc.interval)
+	defer ticker.Stop()
+
+	ready := drc.check(ctx, true)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ready = drc.check(ctx, ready)
+		}
+	}
+}
+
+// check reports the readiness of the datastore if it differs from that last reported,
+// returning it.
+func (drc *DatastoreReadinessChecker) check(ctx context.Context, wasReady bool) bool {
+	checkCtx, cancel := context.WithTimeout(ctx, drc.interval)
+	ready, err := drc.ds.IsReady(checkCtx)
+	cancel()
+
+	if ctx.Err() != nil {
+		return wasReady
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to determine whether the datastore is ready")
+		ready = false
+	}
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if !ready {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+		if wasReady {
+			log.Warn().Msg("datastore is not ready; reporting the server as not serving")
+		}
+	} else if !wasReady {
+		log.Info().Msg("datastore is ready; reporting the server as serving")
+	}
+
+	// Once the health server is shut down, such as after too many panics, it ignores
+	// changes of status, so the server stays reported as not serving.
+	drc.healthSrv.SetServingStatus(serverHealthService, status)
+	return ready
+}
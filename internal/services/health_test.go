@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+)
+
+type readinessDatastore struct {
+	datastore.Datastore
+
+	ready bool
+	err   error
+}
+
+func (rd *readinessDatastore) IsReady(ctx context.Context) (bool, error) {
+	return rd.ready, rd.err
+}
+
+func TestDatastoreReadinessChecker(t *testing.T) {
+	require := require.New(t)
+
+	memds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	ds := &readinessDatastore{Datastore: memds, ready: true}
+	healthSrv := grpcutil.NewAuthlessHealthServer()
+	checker := NewDatastoreReadinessChecker(healthSrv, ds, time.Second)
+
+	requireStatus := func(expected healthpb.HealthCheckResponse_ServingStatus) {
+		resp, err := healthSrv.Check(context.Background(), &healthpb.HealthCheckRequest{Service: serverHealthService})
+		require.NoError(err)
+		require.Equal(expected, resp.Status)
+	}
+
+	require.True(checker.check(context.Background(), true))
+	requireStatus(healthpb.HealthCheckResponse_SERVING)
+
+	ds.ready = false
+	require.False(checker.check(context.Background(), true))
+	requireStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	ds.ready = true
+	require.True(checker.check(context.Background(), false))
+	requireStatus(healthpb.HealthCheckResponse_SERVING)
+
+	ds.err = errors.New("connection refused")
+	require.False(checker.check(context.Background(), true))
+	requireStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	// A shut down health server stays not serving.
+	ds.err = nil
+	healthSrv.Shutdown()
+	require.True(checker.check(context.Background(), false))
+	requireStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"time"
+
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/authzed-go/proto/authzed/api/v1alpha1"
@@ -28,16 +30,31 @@ const (
 	V1SchemaServiceEnabled SchemaServiceOption = 1
 )
 
-// RegisterGrpcServices registers all services to be exposed on the GRPC server.
+// ReflectionOption defines the options for enabling or disabling server reflection.
+type ReflectionOption int
+
+const (
+	// ReflectionDisabled indicates that server reflection is disabled.
+	ReflectionDisabled ReflectionOption = 0
+
+	// ReflectionEnabled indicates that server reflection is enabled, without requiring
+	// authentication.
+	ReflectionEnabled ReflectionOption = 1
+)
+
+// RegisterGrpcServices registers all services to be exposed on the GRPC server,
+// returning the health server which reports their status.
 func RegisterGrpcServices(
 	srv *grpc.Server,
 	ds datastore.Datastore,
 	nsm namespace.Manager,
 	dispatch dispatch.Dispatcher,
 	maxDepth uint32,
+	watchHeartbeatInterval time.Duration,
 	prefixRequired v1alpha1svc.PrefixRequiredOption,
 	schemaServiceOption SchemaServiceOption,
-) {
+	reflectionOption ReflectionOption,
+) *grpcutil.AuthlessHealthServer {
 	healthSrv := grpcutil.NewAuthlessHealthServer()
 
 	v0.RegisterACLServiceServer(srv, v0svc.NewACLServer(ds, nsm, dispatch, maxDepth))
@@ -55,15 +72,33 @@ func RegisterGrpcServices(
 	v1.RegisterPermissionsServiceServer(srv, v1svc.NewPermissionsServer(ds, nsm, dispatch, maxDepth))
 	healthSrv.SetServicesHealthy(&v1.PermissionsService_ServiceDesc)
 
-	v1.RegisterWatchServiceServer(srv, v1svc.NewWatchServer(ds))
+	v1svc.RegisterBulkPermissionsServiceServer(srv, v1svc.NewBulkPermissionsServer(ds, nsm, dispatch, maxDepth))
+	healthSrv.SetServicesHealthy(&v1svc.BulkPermissionsService_ServiceDesc)
+
+	v1svc.RegisterSubjectsServiceServer(srv, v1svc.NewSubjectsServer(ds, nsm, dispatch, maxDepth))
+	healthSrv.SetServicesHealthy(&v1svc.SubjectsService_ServiceDesc)
+
+	v1svc.RegisterLookupWatchServiceServer(srv, v1svc.NewLookupWatchServer(ds, nsm, dispatch, maxDepth))
+	healthSrv.SetServicesHealthy(&v1svc.LookupWatchService_ServiceDesc)
+
+	v1svc.RegisterSchemaExplorerServiceServer(srv, v1svc.NewSchemaExplorerServer(ds, nsm))
+	healthSrv.SetServicesHealthy(&v1svc.SchemaExplorerService_ServiceDesc)
+
+	v1.RegisterWatchServiceServer(srv, v1svc.NewWatchServer(ds, watchHeartbeatInterval))
 	healthSrv.SetServicesHealthy(&v1.WatchService_ServiceDesc)
 
 	if schemaServiceOption == V1SchemaServiceEnabled {
 		v1.RegisterSchemaServiceServer(srv, v1svc.NewSchemaServer(ds))
 		healthSrv.SetServicesHealthy(&v1.SchemaService_ServiceDesc)
+
+		v1svc.RegisterSchemaBundleServiceServer(srv, v1svc.NewSchemaBundleServer(ds))
+		healthSrv.SetServicesHealthy(&v1svc.SchemaBundleService_ServiceDesc)
 	}
 
 	healthpb.RegisterHealthServer(srv, healthSrv)
 
-	reflection.Register(grpcutil.NewAuthlessReflectionInterceptor(srv))
+	if reflectionOption == ReflectionEnabled {
+		reflection.Register(grpcutil.NewAuthlessReflectionInterceptor(srv))
+	}
+	return healthSrv
 }
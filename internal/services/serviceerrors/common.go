@@ -1,20 +1,48 @@
 package serviceerrors
 
 import (
+	"context"
+	"fmt"
+
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
 const (
 	// ReasonReadOnly is the error reason that will show up in ErrorInfo when the service is in
 	// read-only mode.
 	ReasonReadOnly = "SERVICE_READ_ONLY"
+
+	// ReasonMaintenance is the error reason that will show up in ErrorInfo when the service is
+	// in maintenance mode.
+	ReasonMaintenance = "SERVICE_IN_MAINTENANCE"
+
+	// ReasonMaxDepthExceeded is the error reason that will show up in ErrorInfo when a
+	// request exceeded its maximum dispatch depth. The chain of the objects and relations
+	// it was dispatched through is under the chain key of its metadata.
+	ReasonMaxDepthExceeded = "MAX_DEPTH_EXCEEDED"
+
+	// ReasonRevisionOutsideWindow is the error reason that will show up in ErrorInfo when a
+	// request was made at a revision which has been garbage collected, or does not yet exist.
+	ReasonRevisionOutsideWindow = "REVISION_OUTSIDE_WINDOW"
+
+	// ReasonForeignZedToken is the error reason that will show up in ErrorInfo when a request
+	// was made with a ZedToken minted against another datastore, such as the one from which
+	// the datastore of the service was restored.
+	ReasonForeignZedToken = "FOREIGN_ZEDTOKEN"
 )
 
 // ErrServiceReadOnly is an extended GRPC error returned when a service is in read-only mode.
 var ErrServiceReadOnly = mustMakeStatusReadonly()
 
+// ErrServiceInMaintenance is an extended GRPC error returned when a service is in maintenance
+// mode and is not accepting writes.
+var ErrServiceInMaintenance = mustMakeStatusMaintenance()
+
 func mustMakeStatusReadonly() error {
 	status, err := status.New(codes.Unavailable, "service read-only").WithDetails(&errdetails.ErrorInfo{
 		Reason: ReasonReadOnly,
@@ -25,3 +53,84 @@ func mustMakeStatusReadonly() error {
 	}
 	return status.Err()
 }
+
+func mustMakeStatusMaintenance() error {
+	status, err := status.New(codes.FailedPrecondition, "service in maintenance mode; writes are disabled").WithDetails(&errdetails.ErrorInfo{
+		Reason: ReasonMaintenance,
+		Domain: "authzed.com",
+	})
+	if err != nil {
+		panic("error constructing shared error type")
+	}
+	return status.Err()
+}
+
+// NewMaxDepthExceededErr constructs an extended GRPC error for a request which exceeded its
+// maximum dispatch depth after being dispatched through the chain.
+// The chain is empty if it is unknown.
+func NewMaxDepthExceededErr(chain string) error {
+	message := "max depth exceeded; it may be raised up to the limit of the server with request metadata"
+	if chain != "" {
+		message = fmt.Sprintf("%s; dispatched through: %s", message, chain)
+	}
+
+	status, err := status.New(codes.ResourceExhausted, message).WithDetails(&errdetails.ErrorInfo{
+		Reason:   ReasonMaxDepthExceeded,
+		Domain:   "authzed.com",
+		Metadata: map[string]string{"chain": chain},
+	})
+	if err != nil {
+		panic("error constructing shared error type")
+	}
+	return status.Err()
+}
+
+// NewInvalidRevisionErr constructs an extended GRPC error for a request made at an invalid
+// revision. A revision outside the window of valid revisions fails the precondition of the
+// request, with the reason it is invalid and the oldest and newest valid revisions, as
+// encoded ZedTokens bound to the datastore whose unique ID is carried by the context, in the
+// metadata of its ErrorInfo. Any other invalid revision is out of range.
+func NewInvalidRevisionErr(ctx context.Context, err datastore.ErrInvalidRevision) error {
+	oldest, newest, ok := err.ValidWindow()
+	if !ok {
+		return status.Errorf(codes.OutOfRange, "invalid zedtoken: %s", err)
+	}
+
+	datastoreID := datastore.UniqueIDFromContext(ctx)
+	reason := "stale"
+	if err.Reason() == datastore.RevisionInFuture {
+		reason = "future"
+	}
+
+	status, statusErr := status.New(codes.FailedPrecondition, fmt.Sprintf("invalid zedtoken: %s", err)).WithDetails(&errdetails.ErrorInfo{
+		Reason: ReasonRevisionOutsideWindow,
+		Domain: "authzed.com",
+		Metadata: map[string]string{
+			"reason":                reason,
+			"oldest_valid_zedtoken": zedtoken.NewFromRevisionForDatastore(oldest, datastoreID).Token,
+			"newest_valid_zedtoken": zedtoken.NewFromRevisionForDatastore(newest, datastoreID).Token,
+		},
+	})
+	if statusErr != nil {
+		panic("error constructing shared error type")
+	}
+	return status.Err()
+}
+
+// NewForeignZedTokenErr constructs an extended GRPC error for a request made with a ZedToken
+// minted against another datastore, whose revisions are unrelated to those of the datastore
+// of the service. The IDs of both datastores are in the metadata of its ErrorInfo.
+func NewForeignZedTokenErr(err zedtoken.ErrForeignDatastore) error {
+	status, statusErr := status.New(codes.FailedPrecondition, fmt.Sprintf("invalid zedtoken: %s", err)).WithDetails(&errdetails.ErrorInfo{
+		Reason: ReasonForeignZedToken,
+		Domain: "authzed.com",
+		Metadata: map[string]string{
+			"token_datastore_id": err.TokenDatastoreID(),
+			"datastore_id":       err.DatastoreID(),
+		},
+	})
+	if statusErr != nil {
+		panic("error constructing shared error type")
+	}
+	return status.Err()
+}
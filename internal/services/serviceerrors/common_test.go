@@ -0,0 +1,50 @@
+package serviceerrors
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+func TestNewInvalidRevisionErr(t *testing.T) {
+	require := require.New(t)
+
+	var revisionErr datastore.ErrInvalidRevision
+	err := datastore.NewRevisionOutsideWindowErr(decimal.NewFromInt(1), decimal.NewFromInt(5), decimal.NewFromInt(10))
+	require.True(errors.As(err, &revisionErr))
+
+	ctx := datastore.ContextWithUniqueID(context.Background(), "somedatastore")
+	st := status.Convert(NewInvalidRevisionErr(ctx, revisionErr))
+	require.Equal(codes.FailedPrecondition, st.Code())
+	require.Len(st.Details(), 1)
+
+	info := st.Details()[0].(*errdetails.ErrorInfo)
+	require.Equal(ReasonRevisionOutsideWindow, info.Reason)
+	require.Equal("stale", info.Metadata["reason"])
+
+	// The tokens of the window are bound to the datastore, so that they cannot be
+	// resubmitted to another one.
+	for key, expected := range map[string]int64{"oldest_valid_zedtoken": 5, "newest_valid_zedtoken": 10} {
+		token := &v1.ZedToken{Token: info.Metadata[key]}
+		revision, err := zedtoken.DecodeRevisionForDatastore(token, "somedatastore")
+		require.NoError(err)
+		require.True(decimal.NewFromInt(expected).Equal(revision))
+
+		_, err = zedtoken.DecodeRevisionForDatastore(token, "another")
+		require.True(errors.As(err, &zedtoken.ErrForeignDatastore{}))
+	}
+
+	// Revisions which are invalid for other reasons have no window.
+	require.True(errors.As(datastore.NewInvalidRevisionErr(decimal.NewFromInt(1), datastore.CouldNotDetermineRevision), &revisionErr))
+	require.Equal(codes.OutOfRange, status.Code(NewInvalidRevisionErr(ctx, revisionErr)))
+}
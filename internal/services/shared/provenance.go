@@ -0,0 +1,66 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+// TransactionMetadataFieldNumber is the number of the field of watch responses in which
+// the metadata of the transaction of their changes is sent, encoded as JSON. The field is
+// not part of the API definitions, so clients which do not look for it ignore it.
+const TransactionMetadataFieldNumber protowire.Number = 1000
+
+// SetTransactionMetadata adds the transaction metadata to the message, if there is any.
+func SetTransactionMetadata(msg proto.Message, metadata *datastore.TransactionMetadata) error {
+	if metadata == nil {
+		return nil
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("unable to encode transaction metadata: %w", err)
+	}
+
+	reflected := msg.ProtoReflect()
+	unknown := protowire.AppendTag(reflected.GetUnknown(), TransactionMetadataFieldNumber, protowire.BytesType)
+	reflected.SetUnknown(protowire.AppendBytes(unknown, encoded))
+	return nil
+}
+
+// TransactionMetadataFromMessage returns the transaction metadata added to the message,
+// or nil if there is none.
+func TransactionMetadataFromMessage(msg proto.Message) (*datastore.TransactionMetadata, error) {
+	unknown := msg.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		number, wireType, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return nil, fmt.Errorf("unable to decode unknown fields: %w", protowire.ParseError(n))
+		}
+		unknown = unknown[n:]
+
+		if number == TransactionMetadataFieldNumber && wireType == protowire.BytesType {
+			encoded, n := protowire.ConsumeBytes(unknown)
+			if n < 0 {
+				return nil, fmt.Errorf("unable to decode transaction metadata: %w", protowire.ParseError(n))
+			}
+
+			metadata := &datastore.TransactionMetadata{}
+			if err := json.Unmarshal(encoded, metadata); err != nil {
+				return nil, fmt.Errorf("unable to decode transaction metadata: %w", err)
+			}
+			return metadata, nil
+		}
+
+		n = protowire.ConsumeFieldValue(number, wireType, unknown)
+		if n < 0 {
+			return nil, fmt.Errorf("unable to decode unknown fields: %w", protowire.ParseError(n))
+		}
+		unknown = unknown[n:]
+	}
+	return nil, nil
+}
@@ -3,6 +3,7 @@ package shared
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -13,14 +14,45 @@ import (
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/options"
 	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
+// BreakingSchemaChange is a change to the schema which would leave existing relationships
+// without the associated schema object definitions and relations.
+type BreakingSchemaChange struct {
+	// Type is the type of the change.
+	Type namespace.DeltaType
+
+	// Definition is the name of the object definition changed.
+	Definition string
+
+	// Relation is the name of the relation changed, if any.
+	Relation string
+
+	// Subject is the type of subject no longer allowed on the relation, if any, as
+	// `namespace#relation` or `namespace:*`.
+	Subject string
+
+	// Message describes the change and the relationships it leaves behind.
+	Message string
+}
+
 // EnsureNoRelationshipsExist ensures that no relationships exist within the namespace with the given name.
 func EnsureNoRelationshipsExist(ctx context.Context, ds datastore.Datastore, namespaceName string) error {
-	headRevision, err := ds.HeadRevision(ctx)
+	changes, err := FindDefinitionRemovalBreakingChanges(ctx, ds, namespaceName)
 	if err != nil {
 		return err
 	}
+	return errorForBreakingChanges(changes)
+}
+
+// FindDefinitionRemovalBreakingChanges returns the breaking change made by removing the
+// namespace with the given name, if relationships exist within it or reference it.
+func FindDefinitionRemovalBreakingChanges(ctx context.Context, ds datastore.Datastore, namespaceName string) ([]BreakingSchemaChange, error) {
+	headRevision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	qy, qyErr := ds.QueryTuples(
 		ctx,
@@ -28,69 +60,89 @@ func EnsureNoRelationshipsExist(ctx context.Context, ds datastore.Datastore, nam
 		headRevision,
 		options.WithLimit(options.LimitOne),
 	)
-	if err := ErrorIfTupleIteratorReturnsTuples(
-		ctx,
-		qy,
-		qyErr,
-		"cannot delete Object Definition `%s`, as a Relationship exists under it",
-		namespaceName,
-	); err != nil {
-		return err
+	found, err := tupleIteratorReturnsTuples(qy, qyErr)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return []BreakingSchemaChange{{
+			Type:       namespace.NamespaceRemoved,
+			Definition: namespaceName,
+			Message:    fmt.Sprintf("cannot delete Object Definition `%s`, as a Relationship exists under it", namespaceName),
+		}}, nil
 	}
 
 	qy, qyErr = ds.ReverseQueryTuples(ctx, &v1.SubjectFilter{
 		SubjectType: namespaceName,
 	}, headRevision, options.WithReverseLimit(options.LimitOne))
-	if err := ErrorIfTupleIteratorReturnsTuples(
-		ctx,
-		qy,
-		qyErr,
-		"cannot delete Object Definition `%s`, as a Relationship references it",
-		namespaceName,
-	); err != nil {
-		return err
+	found, err = tupleIteratorReturnsTuples(qy, qyErr)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return []BreakingSchemaChange{{
+			Type:       namespace.NamespaceRemoved,
+			Definition: namespaceName,
+			Message:    fmt.Sprintf("cannot delete Object Definition `%s`, as a Relationship references it", namespaceName),
+		}}, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
 // SanityCheckExistingRelationships ensures that a namespace definition being written does not result
 // in relationships without associated defined schema object definitions and relations.
 func SanityCheckExistingRelationships(ctx context.Context, ds datastore.Datastore, nsdef *v0.NamespaceDefinition, revision decimal.Decimal) error {
+	changes, err := FindBreakingChanges(ctx, ds, nsdef, revision)
+	if err != nil {
+		return err
+	}
+	return errorForBreakingChanges(changes)
+}
+
+// FindBreakingChanges diffs a namespace definition being written against the one stored,
+// and returns every change which would leave existing relationships without associated
+// defined schema object definitions and relations.
+func FindBreakingChanges(ctx context.Context, ds datastore.Datastore, nsdef *v0.NamespaceDefinition, revision decimal.Decimal) ([]BreakingSchemaChange, error) {
 	// Ensure that the updated namespace does not break the existing tuple data.
 	//
 	// NOTE: We use the datastore here to read the namespace, rather than the namespace manager,
 	// to ensure there is no caching being used.
 	existing, _, err := ds.ReadNamespace(ctx, nsdef.Name, revision)
 	if err != nil && !errors.As(err, &datastore.ErrNamespaceNotFound{}) {
-		return err
+		return nil, err
 	}
 
 	diff, err := namespace.DiffNamespaces(existing, nsdef)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	headRevision, err := ds.HeadRevision(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var changes []BreakingSchemaChange
 	for _, delta := range diff.Deltas() {
 		switch delta.Type {
 		case namespace.RemovedRelation:
 			qy, qyErr := ds.QueryTuples(ctx, &v1.RelationshipFilter{
 				ResourceType:     nsdef.Name,
 				OptionalRelation: delta.RelationName,
-			}, headRevision)
-
-			err = ErrorIfTupleIteratorReturnsTuples(
-				ctx,
-				qy,
-				qyErr,
-				"cannot delete Relation `%s` in Object Definition `%s`, as a Relationship exists under it", delta.RelationName, nsdef.Name)
+			}, headRevision, options.WithLimit(options.LimitOne))
+			found, err := tupleIteratorReturnsTuples(qy, qyErr)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			if found {
+				changes = append(changes, BreakingSchemaChange{
+					Type:       delta.Type,
+					Definition: nsdef.Name,
+					Relation:   delta.RelationName,
+					Message:    fmt.Sprintf("cannot delete Relation `%s` in Object Definition `%s`, as a Relationship exists under it", delta.RelationName, nsdef.Name),
+				})
+				continue
 			}
 
 			// Also check for right sides of tuples.
@@ -100,13 +152,17 @@ func SanityCheckExistingRelationships(ctx context.Context, ds datastore.Datastor
 					Relation: delta.RelationName,
 				},
 			}, headRevision, options.WithReverseLimit(options.LimitOne))
-			err = ErrorIfTupleIteratorReturnsTuples(
-				ctx,
-				qy,
-				qyErr,
-				"cannot delete Relation `%s` in Object Definition `%s`, as a Relationship references it", delta.RelationName, nsdef.Name)
+			found, err = tupleIteratorReturnsTuples(qy, qyErr)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			if found {
+				changes = append(changes, BreakingSchemaChange{
+					Type:       delta.Type,
+					Definition: nsdef.Name,
+					Relation:   delta.RelationName,
+					Message:    fmt.Sprintf("cannot delete Relation `%s` in Object Definition `%s`, as a Relationship references it", delta.RelationName, nsdef.Name),
+				})
 			}
 
 		case namespace.RelationDirectTypeRemoved:
@@ -125,33 +181,81 @@ func SanityCheckExistingRelationships(ctx context.Context, ds datastore.Datastor
 				}),
 				options.WithReverseLimit(options.LimitOne),
 			)
-			err = ErrorIfTupleIteratorReturnsTuples(
-				ctx,
-				qy,
-				qyErr,
-				"cannot remove allowed direct Relation `%s#%s` from Relation `%s` in Object Definition `%s`, as a Relationship exists with it",
-				delta.DirectType.Namespace, delta.DirectType.Relation, delta.RelationName, nsdef.Name)
+			found, err := tupleIteratorReturnsTuples(qy, qyErr)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			if found {
+				changes = append(changes, BreakingSchemaChange{
+					Type:       delta.Type,
+					Definition: nsdef.Name,
+					Relation:   delta.RelationName,
+					Subject:    fmt.Sprintf("%s#%s", delta.DirectType.Namespace, delta.DirectType.Relation),
+					Message: fmt.Sprintf(
+						"cannot remove allowed direct Relation `%s#%s` from Relation `%s` in Object Definition `%s`, as a Relationship exists with it",
+						delta.DirectType.Namespace, delta.DirectType.Relation, delta.RelationName, nsdef.Name),
+				})
+			}
+
+		case namespace.RelationDirectWildcardTypeRemoved:
+			qy, qyErr := ds.QueryTuples(ctx, &v1.RelationshipFilter{
+				ResourceType:     nsdef.Name,
+				OptionalRelation: delta.RelationName,
+				OptionalSubjectFilter: &v1.SubjectFilter{
+					SubjectType:       delta.WildcardType,
+					OptionalSubjectId: tuple.PublicWildcard,
+				},
+			}, headRevision, options.WithLimit(options.LimitOne))
+			found, err := tupleIteratorReturnsTuples(qy, qyErr)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				changes = append(changes, BreakingSchemaChange{
+					Type:       delta.Type,
+					Definition: nsdef.Name,
+					Relation:   delta.RelationName,
+					Subject:    fmt.Sprintf("%s:%s", delta.WildcardType, tuple.PublicWildcard),
+					Message: fmt.Sprintf(
+						"cannot remove allowed wildcard `%s:%s` from Relation `%s` in Object Definition `%s`, as a Relationship exists with it",
+						delta.WildcardType, tuple.PublicWildcard, delta.RelationName, nsdef.Name),
+				})
 			}
 		}
 	}
-	return nil
+	return changes, nil
 }
 
-// ErrorIfTupleIteratorReturnsTuples takes a tuple iterator and any error that was generated
-// when the original iterator was created, and returns an error if iterator contains any tuples.
-func ErrorIfTupleIteratorReturnsTuples(ctx context.Context, qy datastore.TupleIterator, qyErr error, message string, args ...interface{}) error {
+func errorForBreakingChanges(changes []BreakingSchemaChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return status.Errorf(codes.InvalidArgument, "%s", changes[0].Message)
+}
+
+func tupleIteratorReturnsTuples(qy datastore.TupleIterator, qyErr error) (bool, error) {
 	if qyErr != nil {
-		return qyErr
+		return false, qyErr
 	}
 	defer qy.Close()
 
 	if rt := qy.Next(); rt != nil {
 		if qy.Err() != nil {
-			return qy.Err()
+			return false, qy.Err()
 		}
+		return true, nil
+	}
+	return false, qy.Err()
+}
 
+// ErrorIfTupleIteratorReturnsTuples takes a tuple iterator and any error that was generated
+// when the original iterator was created, and returns an error if iterator contains any tuples.
+func ErrorIfTupleIteratorReturnsTuples(ctx context.Context, qy datastore.TupleIterator, qyErr error, message string, args ...interface{}) error {
+	found, err := tupleIteratorReturnsTuples(qy, qyErr)
+	if err != nil {
+		return err
+	}
+	if found {
 		return status.Errorf(codes.InvalidArgument, message, args...)
 	}
 	return nil
@@ -20,6 +20,7 @@ import (
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/graph"
+	"github.com/authzed/spicedb/internal/middleware/dispatchdepth"
 	"github.com/authzed/spicedb/internal/middleware/handwrittenvalidation"
 	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
 	"github.com/authzed/spicedb/internal/namespace"
@@ -307,7 +308,7 @@ func (as *aclServer) commonCheck(
 	cr, err := as.dispatch.DispatchCheck(ctx, &v1.DispatchCheckRequest{
 		Metadata: &v1.ResolverMeta{
 			AtRevision:     atRevision.String(),
-			DepthRemaining: as.defaultDepth,
+			DepthRemaining: dispatchdepth.FromContext(ctx, as.defaultDepth),
 		},
 		ObjectAndRelation: start,
 		Subject:           goal,
@@ -349,7 +350,7 @@ func (as *aclServer) Expand(ctx context.Context, req *v0.ExpandRequest) (*v0.Exp
 	resp, err := as.dispatch.DispatchExpand(ctx, &v1.DispatchExpandRequest{
 		Metadata: &v1.ResolverMeta{
 			AtRevision:     atRevision.String(),
-			DepthRemaining: as.defaultDepth,
+			DepthRemaining: dispatchdepth.FromContext(ctx, as.defaultDepth),
 		},
 		ObjectAndRelation: req.Userset,
 		ExpansionMode:     v1.DispatchExpandRequest_SHALLOW,
@@ -391,7 +392,7 @@ func (as *aclServer) Lookup(ctx context.Context, req *v0.LookupRequest) (*v0.Loo
 	resp, err := as.dispatch.DispatchLookup(ctx, &v1.DispatchLookupRequest{
 		Metadata: &v1.ResolverMeta{
 			AtRevision:     atRevision.String(),
-			DepthRemaining: as.defaultDepth,
+			DepthRemaining: dispatchdepth.FromContext(ctx, as.defaultDepth),
 		},
 		ObjectRelation: req.ObjectRelation,
 		Subject:        req.User,
@@ -447,6 +448,7 @@ func (as *aclServer) pickBestRevision(ctx context.Context, requested *v0.Zookie)
 func rewriteACLError(ctx context.Context, err error) error {
 	var nsNotFoundError sharederrors.UnknownNamespaceError
 	var relNotFoundError sharederrors.UnknownRelationError
+	var maxDepthError dispatch.MaxDepthExceededError
 
 	switch {
 	case errors.Is(err, errInvalidZookie):
@@ -462,15 +464,27 @@ func rewriteACLError(ctx context.Context, err error) error {
 	case errors.As(err, &graph.ErrRequestCanceled{}):
 		return status.Errorf(codes.Canceled, "request canceled: %s", err)
 
+	case errors.As(err, &maxDepthError):
+		return serviceerrors.NewMaxDepthExceededErr(maxDepthError.ChainString())
+
+	case errors.Is(err, dispatch.ErrMaxDepth):
+		return serviceerrors.NewMaxDepthExceededErr("")
+
 	case errors.As(err, &graph.ErrInvalidArgument{}):
 		return status.Errorf(codes.InvalidArgument, "%s", err)
 
 	case errors.As(err, &datastore.ErrInvalidRevision{}):
 		return status.Errorf(codes.OutOfRange, "invalid zookie: %s", err)
 
+	case errors.As(err, &datastore.ErrQueryTimeout{}):
+		return status.Errorf(codes.DeadlineExceeded, "%s", err)
+
 	case errors.As(err, &datastore.ErrReadOnly{}):
 		return serviceerrors.ErrServiceReadOnly
 
+	case errors.As(err, &datastore.ErrMaintenanceMode{}):
+		return serviceerrors.ErrServiceInMaintenance
+
 	case errors.As(err, &graph.ErrRelationMissingTypeInfo{}):
 		return status.Errorf(codes.FailedPrecondition, "failed precondition: %s", err)
 
@@ -13,10 +13,14 @@ import (
 	"github.com/authzed/grpcutil"
 	"github.com/google/go-cmp/cmp"
 	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/encoding/prototext"
 
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
 	"github.com/authzed/spicedb/internal/membership"
+	"github.com/authzed/spicedb/internal/namespace"
 	v1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
 	"github.com/authzed/spicedb/internal/sharederrors"
 	"github.com/authzed/spicedb/pkg/schemadsl/generator"
@@ -274,6 +278,62 @@ func (ds *devServer) Validate(ctx context.Context, req *v0.ValidateRequest) (*v0
 	}, nil
 }
 
+// RunExpectations checks the assertions and expected relations of a validation file against
+// the data in the datastore at the revision, as Validate does for the data of a request. It
+// returns the failures found, along with the relations computed for the expected relations
+// so that they can be compared to those specified.
+func RunExpectations(ctx context.Context, ds datastore.Datastore, revision decimal.Decimal, expectations validationfile.Expectations) ([]*v0.DeveloperError, validationfile.ValidationMap, error) {
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer nsm.Close()
+
+	dispatcher := graph.NewLocalOnlyDispatcher(nsm, ds)
+	defer dispatcher.Close()
+
+	devContext := &DevContext{
+		Ctx:              ctx,
+		Datastore:        ds,
+		Revision:         revision,
+		Dispatcher:       dispatcher,
+		NamespaceManager: nsm,
+	}
+
+	assertTrueRelationships, aerr := expectations.Assertions.AssertTrueRelationships()
+	if aerr != nil {
+		return []*v0.DeveloperError{convertSourceError(v0.DeveloperError_ASSERTION, aerr)}, nil, nil
+	}
+
+	assertFalseRelationships, aerr := expectations.Assertions.AssertFalseRelationships()
+	if aerr != nil {
+		return []*v0.DeveloperError{convertSourceError(v0.DeveloperError_ASSERTION, aerr)}, nil, nil
+	}
+
+	trueFailures, err := runAssertions(ctx, devContext, assertTrueRelationships, true, "Expected relation or permission %s to exist")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	falseFailures, err := runAssertions(ctx, devContext, assertFalseRelationships, false, "Expected relation or permission %s to not exist")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	membershipSet, validationFailures, err := runValidation(ctx, devContext, expectations.ExpectedRelations)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var computed validationfile.ValidationMap
+	if membershipSet != nil {
+		computed = generateValidationMap(membershipSet)
+	}
+
+	failures := append(trueFailures, falseFailures...)
+	return append(failures, validationFailures...), computed, nil
+}
+
 func runAssertions(ctx context.Context, devContext *DevContext, assertions []validationfile.ParsedAssertion, expected bool, fmtString string) ([]*v0.DeveloperError, error) {
 	var failures []*v0.DeveloperError
 	for _, assertion := range assertions {
@@ -314,6 +374,10 @@ func runAssertions(ctx context.Context, devContext *DevContext, assertions []val
 }
 
 func generateValidation(membershipSet *membership.Set) (string, error) {
+	return generateValidationMap(membershipSet).AsYAML()
+}
+
+func generateValidationMap(membershipSet *membership.Set) validationfile.ValidationMap {
 	validationMap := validationfile.ValidationMap{}
 	subjectsByONR := membershipSet.SubjectsByONR()
 
@@ -347,7 +411,7 @@ func generateValidation(membershipSet *membership.Set) (string, error) {
 		validationMap[validationfile.ObjectRelationString(onrString)] = validationStrings
 	}
 
-	return validationMap.AsYAML()
+	return validationMap
 }
 
 func runValidation(ctx context.Context, devContext *DevContext, validation validationfile.ValidationMap) (*membership.Set, []*v0.DeveloperError, error) {
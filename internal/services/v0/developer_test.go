@@ -8,8 +8,11 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
 	"github.com/authzed/spicedb/pkg/testutil"
 	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/validationfile"
 )
 
 func TestDeveloperSharing(t *testing.T) {
@@ -791,3 +794,43 @@ func TestDeveloperValidateONR(t *testing.T) {
 		Context: `document:somedoc#writerIsNotValid@user:jimmy`,
 	}, resp.RequestErrors[0])
 }
+
+func TestRunExpectations(t *testing.T) {
+	require := require.New(t)
+
+	emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	ds, revision := tf.StandardDatastoreWithData(emptyDS, require)
+	defer ds.Close()
+
+	expectations, err := validationfile.ParseExpectations([]byte(`assertions:
+  assertTrue:
+    - document:masterplan#viewer@user:eng_lead
+    - document:masterplan#viewer@user:villain
+  assertFalse:
+    - document:masterplan#owner@user:eng_lead
+validation:
+  document:masterplan#owner:
+    - "[user:product_manager] is <document:masterplan#owner>"
+    - "[user:villain] is <document:masterplan#owner>"
+`))
+	require.NoError(err)
+
+	failures, computed, err := RunExpectations(context.Background(), ds, revision, expectations)
+	require.NoError(err)
+
+	var failedAssertions, failedValidations []uint32
+	for _, failure := range failures {
+		if failure.Source == v0.DeveloperError_ASSERTION {
+			failedAssertions = append(failedAssertions, failure.Line)
+		} else {
+			failedValidations = append(failedValidations, failure.Line)
+		}
+	}
+	require.Equal([]uint32{4}, failedAssertions)
+	require.Len(failedValidations, 1)
+
+	require.Equal(validationfile.ValidationMap{
+		"document:masterplan#owner": {"[user:product_manager] is <document:masterplan#owner>"},
+	}, computed)
+}
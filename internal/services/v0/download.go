@@ -13,6 +13,7 @@ const downloadPath = "/download/"
 func NewHTTPDownloadServer(addr string, shareStore ShareStore) *http.Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc(downloadPath, downloadHandler(shareStore))
+	mux.HandleFunc(schemaDocsPath, schemaDocsHandler)
 	return &http.Server{
 		Addr:    addr,
 		Handler: mux,
@@ -3,8 +3,10 @@ package v0
 import (
 	"context"
 	"errors"
+	"strconv"
 	"time"
 
+	"github.com/authzed/authzed-go/pkg/responsemeta"
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/grpcutil"
@@ -12,6 +14,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/internal/datastore"
@@ -24,6 +27,19 @@ import (
 	"github.com/authzed/spicedb/pkg/zookie"
 )
 
+const (
+	// ForceDeleteMetadataKey is the request metadata key which, when true, allows
+	// DeleteConfigs to delete definitions with relationships, by deleting the relationships
+	// under the definitions and those with subjects of the definitions first, in batches.
+	ForceDeleteMetadataKey = "io.spicedb.requestmeta.force-delete-definitions"
+
+	// CascadedRelationshipsCount is the response header in which the number of
+	// relationships deleted by a forced DeleteConfigs is returned.
+	CascadedRelationshipsCount responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.cascadedrelationshipscount"
+
+	cascadeDeleteBatchSize = 1000
+)
+
 type nsServer struct {
 	v0.UnimplementedNamespaceServiceServer
 	shared.WithUnaryServiceSpecificInterceptor
@@ -191,11 +207,41 @@ func (nss *nsServer) ReadConfig(ctx context.Context, req *v0.ReadConfigRequest)
 }
 
 func (nss *nsServer) DeleteConfigs(ctx context.Context, req *v0.DeleteConfigsRequest) (*v0.DeleteConfigsResponse, error) {
+	force, err := forceDeleteRequested(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	headRevision, err := nss.ds.HeadRevision(ctx)
 	if err != nil {
 		return nil, rewriteNamespaceError(ctx, err)
 	}
 
+	deleting := make(map[string]struct{}, len(req.Namespaces))
+	for _, nsName := range req.Namespaces {
+		deleting[nsName] = struct{}{}
+	}
+
+	// Ensure that no definition remaining after the deletion references a deleted one.
+	existing, err := nss.ds.ListNamespaces(ctx, headRevision)
+	if err != nil {
+		return nil, rewriteNamespaceError(ctx, err)
+	}
+
+	for _, nsdef := range existing {
+		if _, ok := deleting[nsdef.Name]; ok {
+			continue
+		}
+
+		for _, relation := range nsdef.Relation {
+			for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+				if _, ok := deleting[allowed.Namespace]; ok {
+					return nil, status.Errorf(codes.InvalidArgument, "cannot delete definition `%s`, as relation `%s` of definition `%s` references it", allowed.Namespace, relation.Name, nsdef.Name)
+				}
+			}
+		}
+	}
+
 	// Ensure that all the specified namespaces can be deleted.
 	for _, nsName := range req.Namespaces {
 		// Ensure the namespace exists.
@@ -204,6 +250,11 @@ func (nss *nsServer) DeleteConfigs(ctx context.Context, req *v0.DeleteConfigsReq
 			return nil, rewriteNamespaceError(ctx, err)
 		}
 
+		// Relationships are deleted along with their namespaces when forced.
+		if force {
+			continue
+		}
+
 		// Check for relationships under the namespace.
 		qy, qyErr := nss.ds.QueryTuples(
 			ctx,
@@ -217,7 +268,7 @@ func (nss *nsServer) DeleteConfigs(ctx context.Context, req *v0.DeleteConfigsReq
 			ctx,
 			qy,
 			qyErr,
-			"cannot delete definition `%s`, as a relationship exists under it", nsName)
+			"cannot delete definition `%s`, as a relationship exists under it; set %s to delete its relationships as well", nsName, ForceDeleteMetadataKey)
 		if err != nil {
 			return nil, rewriteNamespaceError(ctx, err)
 		}
@@ -230,7 +281,7 @@ func (nss *nsServer) DeleteConfigs(ctx context.Context, req *v0.DeleteConfigsReq
 			ctx,
 			qy,
 			qyErr,
-			"cannot delete definition `%s`, as a relationship references it", nsName)
+			"cannot delete definition `%s`, as a relationship references it; set %s to delete its relationships as well", nsName, ForceDeleteMetadataKey)
 		if err != nil {
 			return nil, rewriteNamespaceError(ctx, err)
 		}
@@ -240,6 +291,20 @@ func (nss *nsServer) DeleteConfigs(ctx context.Context, req *v0.DeleteConfigsReq
 		DispatchCount: uint32(len(req.Namespaces)),
 	})
 
+	if force {
+		deleted, err := nss.deleteNamespaceRelationships(ctx, req.Namespaces, existing)
+		if err != nil {
+			return nil, rewriteNamespaceError(ctx, err)
+		}
+
+		err = responsemeta.SetResponseHeaderMetadata(ctx, map[responsemeta.ResponseMetadataHeaderKey]string{
+			CascadedRelationshipsCount: strconv.FormatUint(deleted, 10),
+		})
+		if err != nil {
+			log.Ctx(ctx).Err(err).Msg("could not report cascaded deletion metadata")
+		}
+	}
+
 	// Delete all the namespaces specified.
 	for _, nsName := range req.Namespaces {
 		if _, err := nss.ds.DeleteNamespace(ctx, nsName); err != nil {
@@ -252,6 +317,51 @@ func (nss *nsServer) DeleteConfigs(ctx context.Context, req *v0.DeleteConfigsReq
 	}, nil
 }
 
+// deleteNamespaceRelationships deletes, in batches, the relationships under the namespaces
+// and those with subjects of the namespaces, returning the number of relationships deleted.
+func (nss *nsServer) deleteNamespaceRelationships(ctx context.Context, nsNames []string, existing []*v0.NamespaceDefinition) (uint64, error) {
+	var filters []*v1.RelationshipFilter
+	for _, nsName := range nsNames {
+		filters = append(filters, &v1.RelationshipFilter{ResourceType: nsName})
+		for _, nsdef := range existing {
+			filters = append(filters, &v1.RelationshipFilter{
+				ResourceType:          nsdef.Name,
+				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: nsName},
+			})
+		}
+	}
+
+	var deleted uint64
+	for _, filter := range filters {
+		result, err := datastore.DeleteRelationshipsInBatches(ctx, nss.ds, nil, filter, cascadeDeleteBatchSize, 0)
+		deleted += result.Deleted
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	log.Ctx(ctx).Info().Strs("namespaces", nsNames).Uint64("relationships", deleted).Msg("deleted relationships of deleted namespaces")
+	return deleted, nil
+}
+
+func forceDeleteRequested(ctx context.Context) (bool, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false, nil
+	}
+
+	values := md.Get(ForceDeleteMetadataKey)
+	if len(values) == 0 {
+		return false, nil
+	}
+
+	force, err := strconv.ParseBool(values[0])
+	if err != nil {
+		return false, status.Errorf(codes.InvalidArgument, "invalid %s: %s", ForceDeleteMetadataKey, values[0])
+	}
+	return force, nil
+}
+
 func rewriteNamespaceError(ctx context.Context, err error) error {
 	switch {
 	case errors.As(err, &datastore.ErrNamespaceNotFound{}):
@@ -260,6 +370,9 @@ func rewriteNamespaceError(ctx context.Context, err error) error {
 	case errors.As(err, &datastore.ErrReadOnly{}):
 		return serviceerrors.ErrServiceReadOnly
 
+	case errors.As(err, &datastore.ErrMaintenanceMode{}):
+		return serviceerrors.ErrServiceInMaintenance
+
 	default:
 		log.Ctx(ctx).Err(err)
 		return err
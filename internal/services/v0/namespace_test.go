@@ -10,6 +10,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/testing/protocmp"
 
 	"github.com/authzed/spicedb/internal/datastore/memdb"
@@ -228,6 +229,7 @@ func TestDeleteNamespace(t *testing.T) {
 		initialNamespace   *v0.NamespaceDefinition
 		namespacesToDelete []string
 		tuples             []*v0.RelationTuple
+		force              bool
 		expectedError      string
 	}{
 		{
@@ -241,6 +243,7 @@ func TestDeleteNamespace(t *testing.T) {
 			),
 			[]string{"folder", "user"},
 			[]*v0.RelationTuple{},
+			false,
 			"",
 		},
 		{
@@ -256,6 +259,7 @@ func TestDeleteNamespace(t *testing.T) {
 			[]*v0.RelationTuple{
 				tuple.MustParse("folder:somefolder#viewer@user:someuser#..."),
 			},
+			false,
 			"cannot delete definition `folder`, as a relationship exists under it",
 		},
 		{
@@ -267,12 +271,45 @@ func TestDeleteNamespace(t *testing.T) {
 					ns.AllowedRelation("user", "..."),
 				),
 			),
-			[]string{"user"},
+			[]string{"user", "folder"},
 			[]*v0.RelationTuple{
 				tuple.MustParse("folder:somefolder#viewer@user:someuser#..."),
 			},
+			false,
 			"cannot delete definition `user`, as a relationship references it",
 		},
+		{
+			"namespace referenced by another",
+			ns.Namespace(
+				"folder",
+				ns.Relation("viewer",
+					nil,
+					ns.AllowedRelation("user", "..."),
+				),
+			),
+			[]string{"user"},
+			[]*v0.RelationTuple{},
+			true,
+			"cannot delete definition `user`, as relation `viewer` of definition `folder` references it",
+		},
+		{
+			"forced deletion of namespaces with relationships",
+			ns.Namespace(
+				"folder",
+				ns.Relation("viewer",
+					nil,
+					ns.AllowedRelation("user", "..."),
+					ns.AllowedRelation("folder", "viewer"),
+				),
+			),
+			[]string{"user", "folder"},
+			[]*v0.RelationTuple{
+				tuple.MustParse("folder:somefolder#viewer@user:someuser#..."),
+				tuple.MustParse("folder:somefolder#viewer@folder:otherfolder#viewer"),
+			},
+			true,
+			"",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -304,7 +341,12 @@ func TestDeleteNamespace(t *testing.T) {
 			_, err = ds.WriteTuples(context.Background(), nil, updates)
 			require.NoError(err)
 
-			_, err = srv.DeleteConfigs(context.Background(), &v0.DeleteConfigsRequest{
+			ctx := context.Background()
+			if tc.force {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ForceDeleteMetadataKey, "true"))
+			}
+
+			_, err = srv.DeleteConfigs(ctx, &v0.DeleteConfigsRequest{
 				Namespaces: tc.namespacesToDelete,
 			})
 
@@ -329,6 +371,19 @@ func TestDeleteNamespace(t *testing.T) {
 					})
 					grpcutil.RequireStatus(t, codes.NotFound, err)
 				}
+
+				// Any relationships of the deleted namespaces were deleted with them.
+				headRevision, err := ds.HeadRevision(context.Background())
+				require.NoError(err)
+
+				iter, err := ds.QueryTuples(context.Background(), &v1.RelationshipFilter{
+					ResourceType: "folder",
+				}, headRevision)
+				require.NoError(err)
+				defer iter.Close()
+
+				require.Nil(iter.Next())
+				require.NoError(iter.Err())
 			}
 		})
 	}
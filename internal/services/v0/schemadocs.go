@@ -0,0 +1,70 @@
+package v0
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/docs"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+const (
+	schemaDocsPath = "/schema/docs"
+
+	// maxSchemaDocsBodySize is the largest schema for which documentation is generated.
+	maxSchemaDocsBodySize = 4 << 20
+)
+
+var docsContentTypes = map[docs.Format]string{
+	docs.FormatMarkdown: "text/markdown; charset=utf-8",
+	docs.FormatHTML:     "text/html; charset=utf-8",
+}
+
+// schemaDocsHandler generates documentation of the schema posted as the request body, in
+// the format named by the `format` query parameter, defaulting to Markdown.
+func schemaDocsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" {
+		formatName = string(docs.FormatMarkdown)
+	}
+	format, err := docs.ParseFormat(formatName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	schema, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSchemaDocsBodySize))
+	if err != nil {
+		http.Error(w, "unable to read schema", http.StatusBadRequest)
+		return
+	}
+
+	defs, err := compiler.Compile([]compiler.InputSchema{{
+		Source:       input.Source("schema"),
+		SchemaString: string(schema),
+	}}, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	generated, err := docs.Generate(defs, format)
+	if err != nil {
+		log.Ctx(r.Context()).Debug().Err(err).Msg("Couldn't generate schema docs")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", docsContentTypes[format])
+	if _, err := w.Write([]byte(generated)); err != nil {
+		log.Ctx(r.Context()).Debug().Err(err).Msg("Couldn't write schema docs")
+	}
+}
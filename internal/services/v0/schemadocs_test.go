@@ -0,0 +1,74 @@
+package v0
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaDocs(t *testing.T) {
+	tests := []struct {
+		name                string
+		method              string
+		query               string
+		schema              string
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "markdown by default",
+			method:              http.MethodPost,
+			schema:              "definition user {}",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/markdown; charset=utf-8",
+			expectedBody:        "## `user`",
+		},
+		{
+			name:                "html",
+			method:              http.MethodPost,
+			query:               "?format=html",
+			schema:              "definition user {}",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/html; charset=utf-8",
+			expectedBody:        "<h2><code>user</code></h2>",
+		},
+		{
+			name:         "unknown format",
+			method:       http.MethodPost,
+			query:        "?format=pdf",
+			schema:       "definition user {}",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "unknown documentation format",
+		},
+		{
+			name:         "invalid schema",
+			method:       http.MethodPost,
+			schema:       "definition user {",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid method",
+			method:       http.MethodGet,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			req := httptest.NewRequest(test.method, schemaDocsPath+test.query, strings.NewReader(test.schema))
+			rec := httptest.NewRecorder()
+			schemaDocsHandler(rec, req)
+
+			require.Equal(test.expectedCode, rec.Code)
+			if test.expectedContentType != "" {
+				require.Equal(test.expectedContentType, rec.Header().Get("Content-Type"))
+			}
+			require.Contains(rec.Body.String(), test.expectedBody)
+		})
+	}
+}
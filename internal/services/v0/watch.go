@@ -10,6 +10,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/middleware/drain"
 	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
 	"github.com/authzed/spicedb/internal/namespace"
 	dispatchv1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
@@ -70,17 +71,33 @@ func (ws *watchServer) Watch(req *v0.WatchRequest, stream v0.WatchService_WatchS
 		DispatchCount: 1,
 	})
 
+	// The revision through which changes have been sent, which is sent as a final
+	// checkpoint if the server drains, so that the client can resume from it elsewhere.
+	sentThrough := afterRevision
+
 	updates, errchan := ws.ds.Watch(ctx, afterRevision)
 	for {
 		select {
+		case <-drain.FromContext(ctx):
+			if err := stream.Send(&v0.WatchResponse{
+				EndRevision: zookie.NewFromRevision(sentThrough),
+			}); err != nil {
+				return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
+			}
+			return status.Errorf(codes.Unavailable, "server is shutting down; resume the watch from the last revision received")
 		case update, ok := <-updates:
 			if ok {
+				sentThrough = update.Revision
 				filtered := filter.filterUpdates(update.Changes)
 				if len(filtered) > 0 {
-					if err := stream.Send(&v0.WatchResponse{
+					resp := &v0.WatchResponse{
 						Updates:     update.Changes,
 						EndRevision: zookie.NewFromRevision(update.Revision),
-					}); err != nil {
+					}
+					if err := shared.SetTransactionMetadata(resp, update.Metadata); err != nil {
+						return status.Errorf(codes.Internal, "watch error: %s", err)
+					}
+					if err := stream.Send(resp); err != nil {
 						return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
 					}
 				}
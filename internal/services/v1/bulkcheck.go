@@ -0,0 +1,283 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/validator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/middleware/consistency"
+	"github.com/authzed/spicedb/internal/middleware/handwrittenvalidation"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	dispatchv1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+	"github.com/authzed/spicedb/internal/queuemetrics"
+	"github.com/authzed/spicedb/internal/services/shared"
+)
+
+const (
+	// BulkCheckErrors is the response trailer in which the checks of a BulkCheckPermission
+	// request which failed are reported, one value per failed check of the form
+	// "<index>:<code>:<message>". The responses to failed checks have an unspecified
+	// permissionship.
+	BulkCheckErrors responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.bulkcheckerrors"
+
+	// MaxBulkCheckItems is the maximum number of checks in a BulkCheckPermission request.
+	MaxBulkCheckItems = 1000
+
+	// bulkCheckConcurrency is the number of checks of a BulkCheckPermission request which
+	// are evaluated concurrently.
+	bulkCheckConcurrency = 16
+)
+
+// bulkCheckWorkers is the pool of workers evaluating the checks of BulkCheckPermission
+// requests.
+var bulkCheckWorkers = queuemetrics.NewQueue("bulk_check_workers")
+
+// BulkPermissionsServiceServer is the server API for the BulkPermissionsService, which is
+// not part of the API definitions and so exchanges the messages of CheckPermission.
+type BulkPermissionsServiceServer interface {
+	// BulkCheckPermission checks every permission sent by the client, once it has closed
+	// its side of the stream, at the revision picked by the consistency of the first. A
+	// response is sent for each check, in the order of the requests.
+	BulkCheckPermission(BulkPermissionsService_BulkCheckPermissionServer) error
+}
+
+// BulkPermissionsService_BulkCheckPermissionServer is the server side of a
+// BulkCheckPermission stream.
+type BulkPermissionsService_BulkCheckPermissionServer interface {
+	Send(*v1.CheckPermissionResponse) error
+	Recv() (*v1.CheckPermissionRequest, error)
+	grpc.ServerStream
+}
+
+type bulkCheckPermissionServer struct {
+	grpc.ServerStream
+}
+
+func (x *bulkCheckPermissionServer) Send(m *v1.CheckPermissionResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *bulkCheckPermissionServer) Recv() (*v1.CheckPermissionRequest, error) {
+	m := new(v1.CheckPermissionRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func bulkCheckPermissionHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BulkPermissionsServiceServer).BulkCheckPermission(&bulkCheckPermissionServer{stream})
+}
+
+// BulkPermissionsService_ServiceDesc is the grpc.ServiceDesc for the BulkPermissionsService.
+var BulkPermissionsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "spicedb.v1.BulkPermissionsService",
+	HandlerType: (*BulkPermissionsServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BulkCheckPermission",
+			Handler:       bulkCheckPermissionHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+// RegisterBulkPermissionsServiceServer registers the BulkPermissionsService on the server.
+func RegisterBulkPermissionsServiceServer(s grpc.ServiceRegistrar, srv BulkPermissionsServiceServer) {
+	s.RegisterService(&BulkPermissionsService_ServiceDesc, srv)
+}
+
+// BulkPermissionsServiceClient is the client API for the BulkPermissionsService.
+type BulkPermissionsServiceClient interface {
+	BulkCheckPermission(ctx context.Context, opts ...grpc.CallOption) (BulkPermissionsService_BulkCheckPermissionClient, error)
+}
+
+// BulkPermissionsService_BulkCheckPermissionClient is the client side of a
+// BulkCheckPermission stream.
+type BulkPermissionsService_BulkCheckPermissionClient interface {
+	Send(*v1.CheckPermissionRequest) error
+	Recv() (*v1.CheckPermissionResponse, error)
+	grpc.ClientStream
+}
+
+type bulkPermissionsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewBulkPermissionsServiceClient creates a client of the BulkPermissionsService.
+func NewBulkPermissionsServiceClient(cc grpc.ClientConnInterface) BulkPermissionsServiceClient {
+	return &bulkPermissionsServiceClient{cc}
+}
+
+func (c *bulkPermissionsServiceClient) BulkCheckPermission(ctx context.Context, opts ...grpc.CallOption) (BulkPermissionsService_BulkCheckPermissionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &BulkPermissionsService_ServiceDesc.Streams[0], "/spicedb.v1.BulkPermissionsService/BulkCheckPermission", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &bulkCheckPermissionClient{stream}, nil
+}
+
+type bulkCheckPermissionClient struct {
+	grpc.ClientStream
+}
+
+func (x *bulkCheckPermissionClient) Send(m *v1.CheckPermissionRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *bulkCheckPermissionClient) Recv() (*v1.CheckPermissionResponse, error) {
+	m := new(v1.CheckPermissionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewBulkPermissionsServer creates a BulkPermissionsServiceServer instance.
+func NewBulkPermissionsServer(ds datastore.Datastore,
+	nsm namespace.Manager,
+	dispatch dispatch.Dispatcher,
+	defaultDepth uint32,
+) BulkPermissionsServiceServer {
+	return &bulkPermissionServer{
+		ps: &permissionServer{
+			ds:           ds,
+			nsm:          nsm,
+			dispatch:     dispatch,
+			defaultDepth: defaultDepth,
+		},
+		// The revision is picked once for the whole request, rather than for each
+		// message by the consistency middleware.
+		WithServiceSpecificInterceptors: shared.WithServiceSpecificInterceptors{
+			Unary: grpcmw.ChainUnaryServer(
+				grpcvalidate.UnaryServerInterceptor(),
+				handwrittenvalidation.UnaryServerInterceptor,
+				usagemetrics.UnaryServerInterceptor(),
+			),
+			Stream: grpcmw.ChainStreamServer(
+				grpcvalidate.StreamServerInterceptor(),
+				handwrittenvalidation.StreamServerInterceptor,
+				usagemetrics.StreamServerInterceptor(),
+			),
+		},
+	}
+}
+
+type bulkPermissionServer struct {
+	shared.WithServiceSpecificInterceptors
+
+	ps *permissionServer
+}
+
+func (bs *bulkPermissionServer) BulkCheckPermission(stream BulkPermissionsService_BulkCheckPermissionServer) error {
+	ctx := stream.Context()
+
+	var reqs []*v1.CheckPermissionRequest
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(reqs) == MaxBulkCheckItems {
+			return status.Errorf(codes.InvalidArgument, "a bulk check may contain at most %d checks", MaxBulkCheckItems)
+		}
+		if len(reqs) > 0 && req.Consistency != nil && !proto.Equal(req.Consistency, reqs[0].Consistency) {
+			return status.Errorf(codes.InvalidArgument, "check %d has a consistency differing from that of the first check", len(reqs))
+		}
+		reqs = append(reqs, req)
+	}
+
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	revisionCtx, err := consistency.AddRevisionToContext(ctx, reqs[0], bs.ps.ds)
+	if err != nil {
+		return err
+	}
+	atRevision, checkedAt := consistency.MustRevisionFromContext(revisionCtx)
+
+	permissionships := make([]v1.CheckPermissionResponse_Permissionship, len(reqs))
+	responseMetas := make([]*dispatchv1.ResponseMeta, len(reqs))
+	errs := make([]error, len(reqs))
+
+	// Checks share the results of those sub-problems they have in common through the
+	// dispatcher, which caches them by revision.
+	sem := make(chan struct{}, bulkCheckConcurrency)
+	defer bulkCheckWorkers.Track(func() int { return len(sem) }, cap(sem))()
+
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		waitStart := time.Now()
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		bulkCheckWorkers.ObserveWait(time.Since(waitStart))
+
+		wg.Add(1)
+		go func(i int, req *v1.CheckPermissionRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			permissionships[i], responseMetas[i], errs[i] = bs.ps.checkPermission(revisionCtx, req, atRevision)
+		}(i, req)
+	}
+	wg.Wait()
+
+	total := &dispatchv1.ResponseMeta{}
+	var failures []string
+	for i, responseMeta := range responseMetas {
+		if responseMeta != nil {
+			total.DispatchCount += responseMeta.DispatchCount
+			total.CachedDispatchCount += responseMeta.CachedDispatchCount
+			if responseMeta.DepthRequired > total.DepthRequired {
+				total.DepthRequired = responseMeta.DepthRequired
+			}
+		}
+
+		if errs[i] != nil {
+			rewritten := status.Convert(rewritePermissionsError(ctx, errs[i]))
+			failures = append(failures, fmt.Sprintf("%d:%s:%s", i, rewritten.Code(), rewritten.Message()))
+		}
+	}
+	usagemetrics.SetInContext(ctx, total)
+
+	if len(failures) > 0 {
+		stream.SetTrailer(metadata.MD{string(BulkCheckErrors): failures})
+	}
+
+	for i := range reqs {
+		if err := stream.Send(&v1.CheckPermissionResponse{
+			CheckedAt:      checkedAt,
+			Permissionship: permissionships[i],
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/namespace"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+func TestBulkCheckPermission(t *testing.T) {
+	require := require.New(t)
+
+	emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	ds, revision := tf.StandardDatastoreWithData(emptyDS, require)
+
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, nil)
+	require.NoError(err)
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := tf.NewTestServer()
+	RegisterBulkPermissionsServiceServer(s, NewBulkPermissionsServer(ds, nsm, graph.NewLocalOnlyDispatcher(nsm, ds), 50))
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			panic("failed to shutdown cleanly: " + err.Error())
+		}
+	}()
+	defer func() {
+		s.Stop()
+		require.NoError(lis.Close())
+	}()
+
+	conn, err := grpc.Dial("", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(err)
+	defer conn.Close()
+
+	stream, err := NewBulkPermissionsServiceClient(conn).BulkCheckPermission(context.Background())
+	require.NoError(err)
+
+	consistency := &v1.Consistency{
+		Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: zedtoken.NewFromRevision(revision)},
+	}
+	checks := []struct {
+		resource   *v1.ObjectReference
+		permission string
+		subject    *v1.SubjectReference
+		expected   v1.CheckPermissionResponse_Permissionship
+	}{
+		{obj("document", "masterplan"), "viewer", sub("user", "eng_lead", ""), v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION},
+		{obj("document", "masterplan"), "viewer", sub("user", "villain", ""), v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION},
+		{obj("document", "masterplan"), "invalidrelation", sub("user", "eng_lead", ""), v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED},
+		{obj("document", "healthplan"), "viewer", sub("user", "chief_financial_officer", ""), v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION},
+	}
+	for i, check := range checks {
+		req := &v1.CheckPermissionRequest{
+			Resource:   check.resource,
+			Permission: check.permission,
+			Subject:    check.subject,
+		}
+		if i == 0 {
+			req.Consistency = consistency
+		}
+		require.NoError(stream.Send(req))
+	}
+	require.NoError(stream.CloseSend())
+
+	var checkedAt *v1.ZedToken
+	for _, check := range checks {
+		resp, err := stream.Recv()
+		require.NoError(err)
+		require.Equal(check.expected, resp.Permissionship)
+		if checkedAt != nil {
+			require.Equal(checkedAt.Token, resp.CheckedAt.Token)
+		}
+		checkedAt = resp.CheckedAt
+	}
+	_, err = stream.Recv()
+	require.True(errors.Is(err, io.EOF))
+
+	failures := stream.Trailer().Get(string(BulkCheckErrors))
+	require.Len(failures, 1)
+	require.Regexp("^2:FailedPrecondition:", failures[0])
+}
+
+func TestBulkCheckPermissionMismatchedConsistency(t *testing.T) {
+	require := require.New(t)
+
+	emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	ds, _ := tf.StandardDatastoreWithData(emptyDS, require)
+
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, nil)
+	require.NoError(err)
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := tf.NewTestServer()
+	RegisterBulkPermissionsServiceServer(s, NewBulkPermissionsServer(ds, nsm, graph.NewLocalOnlyDispatcher(nsm, ds), 50))
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			panic("failed to shutdown cleanly: " + err.Error())
+		}
+	}()
+	defer func() {
+		s.Stop()
+		require.NoError(lis.Close())
+	}()
+
+	conn, err := grpc.Dial("", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(err)
+	defer conn.Close()
+
+	stream, err := NewBulkPermissionsServiceClient(conn).BulkCheckPermission(context.Background())
+	require.NoError(err)
+
+	require.NoError(stream.Send(&v1.CheckPermissionRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true}},
+		Resource:    obj("document", "masterplan"),
+		Permission:  "viewer",
+		Subject:     sub("user", "eng_lead", ""),
+	}))
+	require.NoError(stream.Send(&v1.CheckPermissionRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		Resource:    obj("document", "masterplan"),
+		Permission:  "viewer",
+		Subject:     sub("user", "eng_lead", ""),
+	}))
+	require.NoError(stream.CloseSend())
+
+	_, err = stream.Recv()
+	require.Equal(codes.InvalidArgument, status.Code(err))
+}
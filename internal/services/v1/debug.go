@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/graph"
+)
+
+const (
+	// DebugMetadataKey is the request metadata key which, when true, requests that
+	// CheckPermission return the trace of the evaluation of the check in the DebugTrace
+	// trailer.
+	DebugMetadataKey = "io.spicedb.requestmeta.debug"
+
+	// DebugTrace is the response trailer in which the trace of a check is returned, as
+	// JSON: a tree of the relations checked, each with its result, whether it was cached,
+	// its duration and the tuples it matched or followed. It is binary metadata, so that
+	// it is carried regardless of its content.
+	DebugTrace responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.debug-trace-bin"
+)
+
+func debugRequested(ctx context.Context) (bool, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false, nil
+	}
+
+	values := md.Get(DebugMetadataKey)
+	if len(values) == 0 {
+		return false, nil
+	}
+
+	debug, err := strconv.ParseBool(values[0])
+	if err != nil {
+		return false, status.Errorf(codes.InvalidArgument, "invalid %s: %s", DebugMetadataKey, values[0])
+	}
+	return debug, nil
+}
+
+// setDebugTrace returns the trace of the check in the response trailer.
+func setDebugTrace(ctx context.Context, trace *graph.CheckTrace) {
+	trace.Lock()
+	children := trace.Children
+	trace.Unlock()
+	if len(children) == 0 {
+		return
+	}
+
+	encoded, err := json.Marshal(children[0])
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("unable to encode check trace")
+		return
+	}
+
+	if err := grpc.SetTrailer(ctx, metadata.Pairs(string(DebugTrace), string(encoded))); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("unable to set check trace trailer")
+	}
+}
@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"context"
+	"strings"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/shopspring/decimal"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/graph"
+	"github.com/authzed/spicedb/internal/middleware/consistency"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	checkPathDirect   = "direct"
+	checkPathDispatch = "dispatch"
+)
+
+var checkPathCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "services",
+	Name:      "check_paths_total",
+	Help:      "number of permission checks by whether they were resolved with a single relationship lookup or dispatched.",
+}, []string{"path"})
+
+// directRelation returns the relation whose relationships alone decide the check, if
+// the permission resolves to a single relation without rewrites whose subjects can only
+// be objects, and so the check can be made by looking up a single relationship.
+func (ps *permissionServer) directRelation(ctx context.Context, req *v1.CheckPermissionRequest, atRevision decimal.Decimal) (string, bool) {
+	// Traced checks are dispatched so that the trace shows how they were resolved.
+	if graph.IsCheckTraced(ctx) {
+		return "", false
+	}
+
+	// Checks at a stale revision, offered by the standby proxy while the datastore is
+	// unavailable, can only be answered from the dispatch cache.
+	if consistency.IsRevisionStale(ctx) {
+		return "", false
+	}
+
+	if normalizeSubjectRelation(req.Subject) != datastore.Ellipsis ||
+		req.Subject.Object.ObjectId == tuple.PublicWildcard ||
+		strings.HasSuffix(req.Resource.ObjectId, datastore.ResourceIDPrefixWildcard) {
+		return "", false
+	}
+
+	nsDef, err := ps.nsm.ReadNamespace(ctx, req.Resource.ObjectType, atRevision)
+	if err != nil {
+		// The check is dispatched, which reports the error.
+		return "", false
+	}
+
+	relation, ok := directRelationFor(nsDef, req.Permission)
+	if !ok {
+		return "", false
+	}
+	return relation.Name, true
+}
+
+// directRelationFor follows the permission through rewrites which are a union of only
+// the relation itself or a single computed userset, and returns the relation reached if
+// its relationships can only have objects as subjects.
+func directRelationFor(nsDef *v0.NamespaceDefinition, permission string) (*v0.Relation, bool) {
+	relations := make(map[string]*v0.Relation, len(nsDef.Relation))
+	for _, relation := range nsDef.Relation {
+		relations[relation.Name] = relation
+	}
+
+	relation, ok := relations[permission]
+	for followed := 0; ok && relation.UsersetRewrite != nil; followed++ {
+		if followed >= len(relations) {
+			// The computed usersets are cyclic.
+			return nil, false
+		}
+
+		union := relation.UsersetRewrite.GetUnion()
+		if union == nil || len(union.Child) != 1 {
+			return nil, false
+		}
+
+		child := union.Child[0]
+		if child.GetXThis() != nil {
+			break
+		}
+
+		computed := child.GetComputedUserset()
+		if computed == nil || computed.Object != v0.ComputedUserset_TUPLE_OBJECT {
+			return nil, false
+		}
+		relation, ok = relations[computed.Relation]
+	}
+	if !ok {
+		return nil, false
+	}
+
+	typeInfo := relation.GetTypeInformation()
+	if typeInfo == nil || len(typeInfo.AllowedDirectRelations) == 0 {
+		return nil, false
+	}
+	for _, allowed := range typeInfo.AllowedDirectRelations {
+		if allowed.GetPublicWildcard() != nil || allowed.GetRelation() != datastore.Ellipsis {
+			return nil, false
+		}
+	}
+	return relation, true
+}
+
+// hasDirectRelationship returns whether the subject of the check has a relationship of
+// the relation to the resource.
+func (ps *permissionServer) hasDirectRelationship(ctx context.Context, req *v1.CheckPermissionRequest, relation string, atRevision decimal.Decimal) (bool, error) {
+	limit := uint64(1)
+	iter, err := ps.ds.QueryTuples(ctx, &v1.RelationshipFilter{
+		ResourceType:       req.Resource.ObjectType,
+		OptionalResourceId: req.Resource.ObjectId,
+		OptionalRelation:   relation,
+		OptionalSubjectFilter: &v1.SubjectFilter{
+			SubjectType:       req.Subject.Object.ObjectType,
+			OptionalSubjectId: req.Subject.Object.ObjectId,
+			OptionalRelation:  &v1.SubjectFilter_RelationFilter{Relation: ""},
+		},
+	}, atRevision, options.WithLimit(&limit))
+	if err != nil {
+		return false, err
+	}
+	defer iter.Close()
+
+	found := iter.Next() != nil
+	return found, iter.Err()
+}
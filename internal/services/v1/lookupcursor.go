@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// LookupPageSizeMetadataKey is the request metadata key which limits the number of
+	// resources sent by LookupResources. If more remain, a cursor to resume after the last
+	// one sent is returned in the LookupNextCursor trailer.
+	LookupPageSizeMetadataKey = "io.spicedb.requestmeta.lookup-page-size"
+
+	// LookupCursorMetadataKey is the request metadata key under which a cursor returned by
+	// an earlier LookupResources request is sent to resume it. The resumed request is
+	// evaluated at the revision of the first, regardless of its consistency, so that the
+	// pages are consistent with one another.
+	LookupCursorMetadataKey = "io.spicedb.requestmeta.lookup-cursor"
+
+	// LookupNextCursor is the response trailer in which the cursor to resume a
+	// LookupResources request which was cut short by its page size is returned.
+	LookupNextCursor responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.lookup-next-cursor"
+)
+
+// lookupCursor is the position reached by a paginated LookupResources request. Resources
+// are sent in order of their IDs, so a request is resumed after the last one sent.
+type lookupCursor struct {
+	Revision string `json:"r"`
+	AfterID  string `json:"a"`
+
+	// Request identifies the request which the cursor resumes, so that it can't be used to
+	// resume another.
+	Request string `json:"q"`
+}
+
+func lookupCursorRequest(req *v1.LookupResourcesRequest) string {
+	return fmt.Sprintf("%s#%s@%s:%s#%s",
+		req.ResourceObjectType,
+		req.Permission,
+		req.Subject.Object.ObjectType,
+		req.Subject.Object.ObjectId,
+		normalizeSubjectRelation(req.Subject),
+	)
+}
+
+func encodeLookupCursor(req *v1.LookupResourcesRequest, revision decimal.Decimal, afterID string) string {
+	encoded, err := json.Marshal(lookupCursor{
+		Revision: revision.String(),
+		AfterID:  afterID,
+		Request:  lookupCursorRequest(req),
+	})
+	if err != nil {
+		panic(fmt.Sprintf("unable to marshal lookup cursor: %s", err))
+	}
+	return base64.URLEncoding.EncodeToString(encoded)
+}
+
+// lookupPagingFromMetadata returns the page size and cursor sent with a LookupResources
+// request, if any. A page size of zero places no limit on the resources sent.
+func lookupPagingFromMetadata(ctx context.Context, req *v1.LookupResourcesRequest) (uint64, *lookupCursor, decimal.Decimal, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, nil, decimal.Zero, nil
+	}
+
+	var pageSize uint64
+	if values := md.Get(LookupPageSizeMetadataKey); len(values) > 0 {
+		parsed, err := strconv.ParseUint(values[0], 10, 32)
+		if err != nil {
+			return 0, nil, decimal.Zero, status.Errorf(codes.InvalidArgument, "invalid %s: %s", LookupPageSizeMetadataKey, values[0])
+		}
+		pageSize = parsed
+	}
+
+	values := md.Get(LookupCursorMetadataKey)
+	if len(values) == 0 {
+		return pageSize, nil, decimal.Zero, nil
+	}
+
+	invalidCursor := status.Errorf(codes.InvalidArgument, "invalid %s", LookupCursorMetadataKey)
+	decoded, err := base64.URLEncoding.DecodeString(values[0])
+	if err != nil {
+		return 0, nil, decimal.Zero, invalidCursor
+	}
+
+	var cursor lookupCursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return 0, nil, decimal.Zero, invalidCursor
+	}
+
+	revision, err := decimal.NewFromString(cursor.Revision)
+	if err != nil {
+		return 0, nil, decimal.Zero, invalidCursor
+	}
+
+	if cursor.Request != lookupCursorRequest(req) {
+		return 0, nil, decimal.Zero, status.Errorf(codes.InvalidArgument, "%s was returned for another request", LookupCursorMetadataKey)
+	}
+
+	return pageSize, &cursor, revision, nil
+}
@@ -0,0 +1,258 @@
+package v1
+
+import (
+	"context"
+	"sort"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/validator"
+	"github.com/jzelinskie/stringz"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/graph"
+	"github.com/authzed/spicedb/internal/middleware/consistency"
+	"github.com/authzed/spicedb/internal/middleware/dispatchdepth"
+	"github.com/authzed/spicedb/internal/middleware/handwrittenvalidation"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	dispatchv1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// ExcludedSubjects is the response trailer in which the subjects excluded from a wildcard
+// subject found by LookupSubjects are returned, one value per excluded subject, e.g.
+// "user:banned". The excluded subjects do not have the permission despite the wildcard.
+const ExcludedSubjects responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.excludedsubjects"
+
+// SubjectsServiceServer is the server API for the SubjectsService, which is not part of
+// the API definitions and so exchanges the messages of ReadRelationships.
+type SubjectsServiceServer interface {
+	// LookupSubjects streams every subject of the subject type of the filter which has the
+	// permission named by the relation of the filter on its resource. Each is sent as a
+	// relationship from the resource, by the permission, to the subject.
+	LookupSubjects(*v1.ReadRelationshipsRequest, SubjectsService_LookupSubjectsServer) error
+}
+
+// SubjectsService_LookupSubjectsServer is the server side of a LookupSubjects stream.
+type SubjectsService_LookupSubjectsServer interface {
+	Send(*v1.ReadRelationshipsResponse) error
+	grpc.ServerStream
+}
+
+type lookupSubjectsServer struct {
+	grpc.ServerStream
+}
+
+func (x *lookupSubjectsServer) Send(m *v1.ReadRelationshipsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func lookupSubjectsHandler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(v1.ReadRelationshipsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SubjectsServiceServer).LookupSubjects(m, &lookupSubjectsServer{stream})
+}
+
+// SubjectsService_ServiceDesc is the grpc.ServiceDesc for the SubjectsService.
+var SubjectsService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "spicedb.v1.SubjectsService",
+	HandlerType: (*SubjectsServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "LookupSubjects",
+			Handler:       lookupSubjectsHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+// RegisterSubjectsServiceServer registers the SubjectsService on the server.
+func RegisterSubjectsServiceServer(s grpc.ServiceRegistrar, srv SubjectsServiceServer) {
+	s.RegisterService(&SubjectsService_ServiceDesc, srv)
+}
+
+// SubjectsServiceClient is the client API for the SubjectsService.
+type SubjectsServiceClient interface {
+	LookupSubjects(ctx context.Context, in *v1.ReadRelationshipsRequest, opts ...grpc.CallOption) (SubjectsService_LookupSubjectsClient, error)
+}
+
+// SubjectsService_LookupSubjectsClient is the client side of a LookupSubjects stream.
+type SubjectsService_LookupSubjectsClient interface {
+	Recv() (*v1.ReadRelationshipsResponse, error)
+	grpc.ClientStream
+}
+
+type subjectsServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewSubjectsServiceClient creates a client of the SubjectsService.
+func NewSubjectsServiceClient(cc grpc.ClientConnInterface) SubjectsServiceClient {
+	return &subjectsServiceClient{cc}
+}
+
+func (c *subjectsServiceClient) LookupSubjects(ctx context.Context, in *v1.ReadRelationshipsRequest, opts ...grpc.CallOption) (SubjectsService_LookupSubjectsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SubjectsService_ServiceDesc.Streams[0], "/spicedb.v1.SubjectsService/LookupSubjects", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lookupSubjectsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type lookupSubjectsClient struct {
+	grpc.ClientStream
+}
+
+func (x *lookupSubjectsClient) Recv() (*v1.ReadRelationshipsResponse, error) {
+	m := new(v1.ReadRelationshipsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewSubjectsServer creates a SubjectsServiceServer instance.
+func NewSubjectsServer(ds datastore.Datastore,
+	nsm namespace.Manager,
+	dispatch dispatch.Dispatcher,
+	defaultDepth uint32,
+) SubjectsServiceServer {
+	return &subjectsServer{
+		nsm:          nsm,
+		dispatch:     dispatch,
+		defaultDepth: defaultDepth,
+		WithServiceSpecificInterceptors: shared.WithServiceSpecificInterceptors{
+			Unary: grpcmw.ChainUnaryServer(
+				grpcvalidate.UnaryServerInterceptor(),
+				handwrittenvalidation.UnaryServerInterceptor,
+				usagemetrics.UnaryServerInterceptor(),
+				consistency.UnaryServerInterceptor(ds),
+			),
+			Stream: grpcmw.ChainStreamServer(
+				grpcvalidate.StreamServerInterceptor(),
+				handwrittenvalidation.StreamServerInterceptor,
+				usagemetrics.StreamServerInterceptor(),
+				consistency.StreamServerInterceptor(ds),
+			),
+		},
+	}
+}
+
+type subjectsServer struct {
+	shared.WithServiceSpecificInterceptors
+
+	nsm          namespace.Manager
+	dispatch     dispatch.Dispatcher
+	defaultDepth uint32
+}
+
+func (ss *subjectsServer) LookupSubjects(req *v1.ReadRelationshipsRequest, resp SubjectsService_LookupSubjectsServer) error {
+	ctx := resp.Context()
+	atRevision, revisionReadAt := consistency.MustRevisionFromContext(ctx)
+
+	filter := req.RelationshipFilter
+	subjectFilter := filter.OptionalSubjectFilter
+	if filter.OptionalResourceId == "" || filter.OptionalRelation == "" || subjectFilter == nil {
+		return status.Errorf(codes.InvalidArgument, "looking up subjects requires a resource ID, a permission and a subject type")
+	}
+	if subjectFilter.OptionalSubjectId != "" {
+		return status.Errorf(codes.InvalidArgument, "looking up subjects does not accept a subject ID; check the permission instead")
+	}
+
+	subjectRelation := datastore.Ellipsis
+	if subjectFilter.OptionalRelation != nil {
+		subjectRelation = stringz.DefaultEmpty(subjectFilter.OptionalRelation.Relation, datastore.Ellipsis)
+	}
+
+	if err := ss.nsm.CheckNamespaceAndRelation(ctx, filter.ResourceType, filter.OptionalRelation, false, atRevision); err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+	if err := ss.nsm.CheckNamespaceAndRelation(ctx, subjectFilter.SubjectType, subjectRelation, true, atRevision); err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+
+	result, err := graph.LookupSubjects(ctx, ss.dispatch, graph.ValidatedLookupSubjectsRequest{
+		ObjectAndRelation: &v0.ObjectAndRelation{
+			Namespace: filter.ResourceType,
+			ObjectId:  filter.OptionalResourceId,
+			Relation:  filter.OptionalRelation,
+		},
+		SubjectRelation: &v0.RelationReference{
+			Namespace: subjectFilter.SubjectType,
+			Relation:  subjectRelation,
+		},
+		Metadata: &dispatchv1.ResolverMeta{
+			AtRevision:     atRevision.String(),
+			DepthRemaining: dispatchdepth.FromContext(ctx, ss.defaultDepth),
+		},
+		Revision: atRevision,
+	})
+	if result.Metadata != nil {
+		usagemetrics.SetInContext(ctx, result.Metadata)
+	}
+	if err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+
+	subjects := result.Subjects
+	sort.Slice(subjects, func(i, j int) bool {
+		return tuple.StringONR(subjects[i].Subject()) < tuple.StringONR(subjects[j].Subject())
+	})
+
+	var excluded []string
+	for _, subject := range subjects {
+		excludedSubjects, _ := subject.ExcludedSubjectsFromWildcard()
+		for _, excludedSubject := range excludedSubjects {
+			excluded = append(excluded, tuple.StringONR(excludedSubject))
+		}
+	}
+	if len(excluded) > 0 {
+		sort.Strings(excluded)
+		resp.SetTrailer(metadata.MD{string(ExcludedSubjects): excluded})
+	}
+
+	for _, subject := range subjects {
+		found := subject.Subject()
+		err := resp.Send(&v1.ReadRelationshipsResponse{
+			ReadAt: revisionReadAt,
+			Relationship: &v1.Relationship{
+				Resource: &v1.ObjectReference{
+					ObjectType: filter.ResourceType,
+					ObjectId:   filter.OptionalResourceId,
+				},
+				Relation: filter.OptionalRelation,
+				Subject: &v1.SubjectReference{
+					Object: &v1.ObjectReference{
+						ObjectType: found.Namespace,
+						ObjectId:   found.ObjectId,
+					},
+					OptionalRelation: stringz.Default(found.Relation, "", datastore.Ellipsis),
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/namespace"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+func TestLookupSubjects(t *testing.T) {
+	testCases := []struct {
+		resourceType     string
+		resourceID       string
+		permission       string
+		subjectType      string
+		expectedCode     codes.Code
+		expectedSubjects []string
+	}{
+		{
+			"document", "masterplan", "viewer", "user",
+			codes.OK,
+			[]string{"auditor", "chief_financial_officer", "eng_lead", "legal", "owner", "product_manager", "vp_product"},
+		},
+		{
+			"document", "masterplan", "owner", "user",
+			codes.OK,
+			[]string{"product_manager"},
+		},
+		{
+			"document", "specialplan", "viewer_and_editor", "user",
+			codes.OK,
+			[]string{"multiroleguy"},
+		},
+		{
+			"document", "unknowndoc", "viewer", "user",
+			codes.OK,
+			nil,
+		},
+		{
+			"document", "masterplan", "invalidrelation", "user",
+			codes.FailedPrecondition,
+			nil,
+		},
+		{
+			"document", "masterplan", "viewer", "invalidnamespace",
+			codes.FailedPrecondition,
+			nil,
+		},
+		{
+			"document", "", "viewer", "user",
+			codes.InvalidArgument,
+			nil,
+		},
+	}
+
+	require := require.New(t)
+
+	emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	ds, revision := tf.StandardDatastoreWithData(emptyDS, require)
+
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, nil)
+	require.NoError(err)
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := tf.NewTestServer()
+	RegisterSubjectsServiceServer(s, NewSubjectsServer(ds, nsm, graph.NewLocalOnlyDispatcher(nsm, ds), 50))
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			panic("failed to shutdown cleanly: " + err.Error())
+		}
+	}()
+	defer func() {
+		s.Stop()
+		require.NoError(lis.Close())
+	}()
+
+	conn, err := grpc.Dial("", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(err)
+	defer conn.Close()
+
+	client := NewSubjectsServiceClient(conn)
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.resourceType+":"+tc.resourceID+"#"+tc.permission+"@"+tc.subjectType, func(t *testing.T) {
+			require := require.New(t)
+
+			stream, err := client.LookupSubjects(context.Background(), &v1.ReadRelationshipsRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: zedtoken.NewFromRevision(revision)},
+				},
+				RelationshipFilter: &v1.RelationshipFilter{
+					ResourceType:       tc.resourceType,
+					OptionalResourceId: tc.resourceID,
+					OptionalRelation:   tc.permission,
+					OptionalSubjectFilter: &v1.SubjectFilter{
+						SubjectType: tc.subjectType,
+					},
+				},
+			})
+			require.NoError(err)
+
+			var found []string
+			for {
+				resp, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if tc.expectedCode != codes.OK {
+					require.Equal(tc.expectedCode, status.Code(err))
+					return
+				}
+				require.NoError(err)
+
+				require.Equal(tc.resourceID, resp.Relationship.Resource.ObjectId)
+				require.Equal(tc.permission, resp.Relationship.Relation)
+				require.Equal(tc.subjectType, resp.Relationship.Subject.Object.ObjectType)
+				require.Empty(resp.Relationship.Subject.OptionalRelation)
+				found = append(found, resp.Relationship.Subject.Object.ObjectId)
+			}
+
+			require.Equal(codes.OK, tc.expectedCode)
+			require.Equal(tc.expectedSubjects, found)
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package v1
+
+import (
+	"context"
+	"errors"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/validator"
+	"github.com/jzelinskie/stringz"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/graph"
+	"github.com/authzed/spicedb/internal/middleware/consistency"
+	"github.com/authzed/spicedb/internal/middleware/dispatchdepth"
+	"github.com/authzed/spicedb/internal/middleware/drain"
+	"github.com/authzed/spicedb/internal/middleware/handwrittenvalidation"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	dispatchv1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// LookupWatchServiceServer is the server API for the LookupWatchService, which is not part
+// of the API definitions and so exchanges the messages of ReadRelationships and Watch.
+type LookupWatchServiceServer interface {
+	// LookupWatch streams the changes, following the revision of the consistency of the
+	// request, to which subjects of the subject type of the filter have the permission
+	// named by the relation of the filter on the resources of its resource type. Subjects
+	// gaining the permission are sent as touches, and those losing it as deletes, of
+	// relationships from the resource, by the permission, to the subject.
+	LookupWatch(*v1.ReadRelationshipsRequest, LookupWatchService_LookupWatchServer) error
+}
+
+// LookupWatchService_LookupWatchServer is the server side of a LookupWatch stream.
+type LookupWatchService_LookupWatchServer interface {
+	Send(*v1.WatchResponse) error
+	grpc.ServerStream
+}
+
+type lookupWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *lookupWatchServer) Send(m *v1.WatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func lookupWatchHandler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(v1.ReadRelationshipsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LookupWatchServiceServer).LookupWatch(m, &lookupWatchServer{stream})
+}
+
+// LookupWatchService_ServiceDesc is the grpc.ServiceDesc for the LookupWatchService.
+var LookupWatchService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "spicedb.v1.LookupWatchService",
+	HandlerType: (*LookupWatchServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "LookupWatch",
+			Handler:       lookupWatchHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+// RegisterLookupWatchServiceServer registers the LookupWatchService on the server.
+func RegisterLookupWatchServiceServer(s grpc.ServiceRegistrar, srv LookupWatchServiceServer) {
+	s.RegisterService(&LookupWatchService_ServiceDesc, srv)
+}
+
+// LookupWatchServiceClient is the client API for the LookupWatchService.
+type LookupWatchServiceClient interface {
+	LookupWatch(ctx context.Context, in *v1.ReadRelationshipsRequest, opts ...grpc.CallOption) (LookupWatchService_LookupWatchClient, error)
+}
+
+// LookupWatchService_LookupWatchClient is the client side of a LookupWatch stream.
+type LookupWatchService_LookupWatchClient interface {
+	Recv() (*v1.WatchResponse, error)
+	grpc.ClientStream
+}
+
+type lookupWatchServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewLookupWatchServiceClient creates a client of the LookupWatchService.
+func NewLookupWatchServiceClient(cc grpc.ClientConnInterface) LookupWatchServiceClient {
+	return &lookupWatchServiceClient{cc}
+}
+
+func (c *lookupWatchServiceClient) LookupWatch(ctx context.Context, in *v1.ReadRelationshipsRequest, opts ...grpc.CallOption) (LookupWatchService_LookupWatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LookupWatchService_ServiceDesc.Streams[0], "/spicedb.v1.LookupWatchService/LookupWatch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lookupWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type lookupWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *lookupWatchClient) Recv() (*v1.WatchResponse, error) {
+	m := new(v1.WatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewLookupWatchServer creates a LookupWatchServiceServer instance.
+func NewLookupWatchServer(ds datastore.Datastore,
+	nsm namespace.Manager,
+	dispatch dispatch.Dispatcher,
+	defaultDepth uint32,
+) LookupWatchServiceServer {
+	return &lookupWatchServiceServer{
+		ds:           ds,
+		nsm:          nsm,
+		dispatch:     dispatch,
+		defaultDepth: defaultDepth,
+		WithServiceSpecificInterceptors: shared.WithServiceSpecificInterceptors{
+			Unary: grpcmw.ChainUnaryServer(
+				grpcvalidate.UnaryServerInterceptor(),
+				handwrittenvalidation.UnaryServerInterceptor,
+				usagemetrics.UnaryServerInterceptor(),
+				consistency.UnaryServerInterceptor(ds),
+			),
+			Stream: grpcmw.ChainStreamServer(
+				grpcvalidate.StreamServerInterceptor(),
+				handwrittenvalidation.StreamServerInterceptor,
+				usagemetrics.StreamServerInterceptor(),
+				consistency.StreamServerInterceptor(ds),
+			),
+		},
+	}
+}
+
+type lookupWatchServiceServer struct {
+	shared.WithServiceSpecificInterceptors
+
+	ds           datastore.Datastore
+	nsm          namespace.Manager
+	dispatch     dispatch.Dispatcher
+	defaultDepth uint32
+}
+
+func (lws *lookupWatchServiceServer) LookupWatch(req *v1.ReadRelationshipsRequest, resp LookupWatchService_LookupWatchServer) error {
+	ctx := resp.Context()
+	afterRevision, _ := consistency.MustRevisionFromContext(ctx)
+
+	filter := req.RelationshipFilter
+	subjectFilter := filter.OptionalSubjectFilter
+	if filter.OptionalRelation == "" || subjectFilter == nil {
+		return status.Errorf(codes.InvalidArgument, "watching lookups requires a permission and a subject type")
+	}
+
+	subjectRelation := datastore.Ellipsis
+	if subjectFilter.OptionalRelation != nil {
+		subjectRelation = stringz.DefaultEmpty(subjectFilter.OptionalRelation.Relation, datastore.Ellipsis)
+	}
+
+	if err := lws.nsm.CheckNamespaceAndRelation(ctx, filter.ResourceType, filter.OptionalRelation, false, afterRevision); err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+	if err := lws.nsm.CheckNamespaceAndRelation(ctx, subjectFilter.SubjectType, subjectRelation, true, afterRevision); err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: 1,
+	})
+
+	// Every relationship change may change the permission, so the watch is not filtered.
+	sentThrough := afterRevision
+	updates, errchan := lws.ds.Watch(ctx, afterRevision)
+	for {
+		select {
+		case <-drain.FromContext(ctx):
+			if err := resp.Send(&v1.WatchResponse{
+				ChangesThrough: consistency.NewZedToken(ctx, sentThrough),
+			}); err != nil {
+				return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
+			}
+			return status.Errorf(codes.Unavailable, "server is shutting down; resume the watch from the last revision received")
+		case update, ok := <-updates:
+			if !ok {
+				// The error which closed the changes is received next.
+				updates = nil
+				continue
+			}
+
+			result, err := graph.PermissionChanges(ctx, lws.dispatch, lws.nsm, graph.ValidatedPermissionChangesRequest{
+				Permission: &v0.RelationReference{
+					Namespace: filter.ResourceType,
+					Relation:  filter.OptionalRelation,
+				},
+				SubjectRelation: &v0.RelationReference{
+					Namespace: subjectFilter.SubjectType,
+					Relation:  subjectRelation,
+				},
+				OptionalResourceID: filter.OptionalResourceId,
+				Changes:            update.Changes,
+				PreviousRevision:   sentThrough,
+				Revision:           update.Revision,
+				DepthRemaining:     dispatchdepth.FromContext(ctx, lws.defaultDepth),
+			})
+			if err != nil {
+				return rewritePermissionsError(ctx, err)
+			}
+			sentThrough = update.Revision
+
+			changes := make([]*v0.RelationTupleUpdate, 0, len(result.Changes))
+			for _, change := range result.Changes {
+				subject := change.Tuple.User.GetUserset()
+				if subjectFilter.OptionalSubjectId == "" || subjectFilter.OptionalSubjectId == subject.ObjectId {
+					changes = append(changes, change)
+				}
+			}
+			if len(changes) == 0 {
+				continue
+			}
+
+			if err := resp.Send(&v1.WatchResponse{
+				Updates:        tuple.UpdatesToRelationshipUpdates(changes),
+				ChangesThrough: consistency.NewZedToken(ctx, update.Revision),
+			}); err != nil {
+				return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
+			}
+		case err := <-errchan:
+			switch {
+			case errors.As(err, &datastore.ErrWatchCanceled{}):
+				return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
+			case errors.As(err, &datastore.ErrWatchDisconnected{}):
+				return status.Errorf(codes.ResourceExhausted, "watch disconnected: %s", err)
+			default:
+				return status.Errorf(codes.Internal, "watch error: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"context"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/namespace"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+func TestLookupWatch(t *testing.T) {
+	require := require.New(t)
+
+	emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	ds, revision := tf.StandardDatastoreWithData(emptyDS, require)
+
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, nil)
+	require.NoError(err)
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := tf.NewTestServer()
+	RegisterLookupWatchServiceServer(s, NewLookupWatchServer(ds, nsm, graph.NewLocalOnlyDispatcher(nsm, ds), 50))
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			panic("failed to shutdown cleanly: " + err.Error())
+		}
+	}()
+	defer func() {
+		s.Stop()
+		require.NoError(lis.Close())
+	}()
+
+	conn, err := grpc.Dial("", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(err)
+	defer conn.Close()
+
+	client := NewLookupWatchServiceClient(conn)
+
+	lookupWatch := func(filter *v1.RelationshipFilter) LookupWatchService_LookupWatchClient {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+
+		stream, err := client.LookupWatch(ctx, &v1.ReadRelationshipsRequest{
+			Consistency: &v1.Consistency{
+				Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: zedtoken.NewFromRevision(revision)},
+			},
+			RelationshipFilter: filter,
+		})
+		require.NoError(err)
+		return stream
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		stream := lookupWatch(&v1.RelationshipFilter{ResourceType: "document", OptionalRelation: "viewer"})
+		_, err := stream.Recv()
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("unknown permission", func(t *testing.T) {
+		stream := lookupWatch(&v1.RelationshipFilter{
+			ResourceType:          "document",
+			OptionalRelation:      "invalidrelation",
+			OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "user"},
+		})
+		_, err := stream.Recv()
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	allViewers := lookupWatch(&v1.RelationshipFilter{
+		ResourceType:          "document",
+		OptionalRelation:      "viewer",
+		OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "user"},
+	})
+	masterplanViewers := lookupWatch(&v1.RelationshipFilter{
+		ResourceType:          "document",
+		OptionalResourceId:    "masterplan",
+		OptionalRelation:      "viewer",
+		OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "user"},
+	})
+
+	// Viewers of a folder become viewers of the documents in it, and removing a direct
+	// viewer only removes it from the document.
+	granted, err := ds.WriteTuples(context.Background(), nil, []*v1.RelationshipUpdate{
+		tuple.UpdateToRelationshipUpdate(tuple.Touch(tuple.MustParse("folder:plans#viewer@user:newbie#..."))),
+	})
+	require.NoError(err)
+	revoked, err := ds.WriteTuples(context.Background(), nil, []*v1.RelationshipUpdate{
+		tuple.UpdateToRelationshipUpdate(tuple.Delete(tuple.MustParse("document:masterplan#viewer@user:eng_lead#..."))),
+	})
+	require.NoError(err)
+
+	requireChanges := func(stream LookupWatchService_LookupWatchClient, at datastore.Revision, expected ...string) {
+		resp, err := stream.Recv()
+		require.NoError(err)
+
+		changesThrough, err := zedtoken.DecodeRevision(resp.ChangesThrough)
+		require.NoError(err)
+		require.True(changesThrough.Equal(at))
+
+		var changes []string
+		for _, update := range resp.Updates {
+			changes = append(changes, update.Operation.String()+"("+tuple.RelString(update.Relationship)+")")
+		}
+		sort.Strings(changes)
+		require.Equal(expected, changes)
+	}
+
+	requireChanges(allViewers, granted,
+		"OPERATION_TOUCH(document:healthplan#viewer@user:newbie)",
+		"OPERATION_TOUCH(document:masterplan#viewer@user:newbie)",
+	)
+	requireChanges(allViewers, revoked,
+		"OPERATION_DELETE(document:masterplan#viewer@user:eng_lead)",
+	)
+
+	requireChanges(masterplanViewers, granted,
+		"OPERATION_TOUCH(document:masterplan#viewer@user:newbie)",
+	)
+	requireChanges(masterplanViewers, revoked,
+		"OPERATION_DELETE(document:masterplan#viewer@user:eng_lead)",
+	)
+}
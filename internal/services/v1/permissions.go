@@ -3,14 +3,18 @@ package v1
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/jzelinskie/stringz"
+	"github.com/shopspring/decimal"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/authzed/spicedb/internal/graph"
 	"github.com/authzed/spicedb/internal/middleware/consistency"
+	"github.com/authzed/spicedb/internal/middleware/dispatchdepth"
 	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
 	dispatch "github.com/authzed/spicedb/internal/proto/dispatch/v1"
 )
@@ -18,6 +22,33 @@ import (
 func (ps *permissionServer) CheckPermission(ctx context.Context, req *v1.CheckPermissionRequest) (*v1.CheckPermissionResponse, error) {
 	atRevision, checkedAt := consistency.MustRevisionFromContext(ctx)
 
+	debug, err := debugRequested(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if debug {
+		var trace *graph.CheckTrace
+		ctx, trace = graph.WithCheckTrace(ctx)
+		defer setDebugTrace(ctx, trace)
+	}
+
+	permissionship, responseMeta, err := ps.checkPermission(ctx, req, atRevision)
+	if responseMeta != nil {
+		usagemetrics.SetInContext(ctx, responseMeta)
+	}
+	if err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	return &v1.CheckPermissionResponse{
+		CheckedAt:      checkedAt,
+		Permissionship: permissionship,
+	}, nil
+}
+
+// checkPermission checks the permission at the revision, returning the metadata of the
+// dispatched check if it was dispatched.
+func (ps *permissionServer) checkPermission(ctx context.Context, req *v1.CheckPermissionRequest, atRevision decimal.Decimal) (v1.CheckPermissionResponse_Permissionship, *dispatch.ResponseMeta, error) {
 	// Perform our preflight checks in parallel
 	errG, checksCtx := errgroup.WithContext(ctx)
 	errG.Go(func() error {
@@ -39,13 +70,28 @@ func (ps *permissionServer) CheckPermission(ctx context.Context, req *v1.CheckPe
 		)
 	})
 	if err := errG.Wait(); err != nil {
-		return nil, rewritePermissionsError(ctx, err)
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED, nil, err
 	}
 
-	cr, err := ps.dispatch.DispatchCheck(ctx, &dispatch.DispatchCheckRequest{
+	if relation, ok := ps.directRelation(ctx, req, atRevision); ok {
+		checkPathCounter.WithLabelValues(checkPathDirect).Inc()
+		found, err := ps.hasDirectRelationship(ctx, req, relation, atRevision)
+		if err != nil {
+			return v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED, nil, err
+		}
+
+		meta := &dispatch.ResponseMeta{DispatchCount: 1, DepthRequired: 1}
+		if found {
+			return v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, meta, nil
+		}
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION, meta, nil
+	}
+
+	checkPathCounter.WithLabelValues(checkPathDispatch).Inc()
+	cr, err := graph.DispatchCheckTraced(ctx, ps.dispatch, &dispatch.DispatchCheckRequest{
 		Metadata: &dispatch.ResolverMeta{
 			AtRevision:     atRevision.String(),
-			DepthRemaining: ps.defaultDepth,
+			DepthRemaining: dispatchdepth.FromContext(ctx, ps.defaultDepth),
 		},
 		ObjectAndRelation: &v0.ObjectAndRelation{
 			Namespace: req.Resource.ObjectType,
@@ -58,25 +104,18 @@ func (ps *permissionServer) CheckPermission(ctx context.Context, req *v1.CheckPe
 			Relation:  normalizeSubjectRelation(req.Subject),
 		},
 	})
-	usagemetrics.SetInContext(ctx, cr.Metadata)
 	if err != nil {
-		return nil, rewritePermissionsError(ctx, err)
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED, cr.GetMetadata(), err
 	}
 
-	var permissionship v1.CheckPermissionResponse_Permissionship
 	switch cr.Membership {
 	case dispatch.DispatchCheckResponse_MEMBER:
-		permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, cr.Metadata, nil
 	case dispatch.DispatchCheckResponse_NOT_MEMBER:
-		permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION, cr.Metadata, nil
 	default:
-		permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED, cr.Metadata, nil
 	}
-
-	return &v1.CheckPermissionResponse{
-		CheckedAt:      checkedAt,
-		Permissionship: permissionship,
-	}, nil
 }
 
 func (ps *permissionServer) ExpandPermissionTree(ctx context.Context, req *v1.ExpandPermissionTreeRequest) (*v1.ExpandPermissionTreeResponse, error) {
@@ -90,7 +129,7 @@ func (ps *permissionServer) ExpandPermissionTree(ctx context.Context, req *v1.Ex
 	resp, err := ps.dispatch.DispatchExpand(ctx, &dispatch.DispatchExpandRequest{
 		Metadata: &dispatch.ResolverMeta{
 			AtRevision:     atRevision.String(),
-			DepthRemaining: ps.defaultDepth,
+			DepthRemaining: dispatchdepth.FromContext(ctx, ps.defaultDepth),
 		},
 		ObjectAndRelation: &v0.ObjectAndRelation{
 			Namespace: req.Resource.ObjectType,
@@ -259,10 +298,22 @@ func translateExpansionTree(node *v0.RelationTupleTreeNode) *v1.PermissionRelati
 	}
 }
 
+// lookupStreamBatchSize is the number of resources looked up at a time by LookupResources,
+// each batch being sent before the next is looked up.
+const lookupStreamBatchSize = 100
+
 func (ps *permissionServer) LookupResources(req *v1.LookupResourcesRequest, resp v1.PermissionsService_LookupResourcesServer) error {
 	ctx := resp.Context()
 	atRevision, revisionReadAt := consistency.MustRevisionFromContext(ctx)
 
+	pageSize, cursor, cursorRevision, err := lookupPagingFromMetadata(ctx, req)
+	if err != nil {
+		return err
+	}
+	if cursor != nil {
+		atRevision, revisionReadAt = cursorRevision, consistency.NewZedToken(ctx, cursorRevision)
+	}
+
 	// Perform our preflight checks in parallel
 	errG, checksCtx := errgroup.WithContext(ctx)
 	errG.Go(func() error {
@@ -281,47 +332,90 @@ func (ps *permissionServer) LookupResources(req *v1.LookupResourcesRequest, resp
 		return rewritePermissionsError(ctx, err)
 	}
 
-	// TODO(jschorr): Change the internal dispatched lookup to also be streamed.
-	lookupResp, err := ps.dispatch.DispatchLookup(ctx, &dispatch.DispatchLookupRequest{
-		Metadata: &dispatch.ResolverMeta{
-			AtRevision:     atRevision.String(),
-			DepthRemaining: ps.defaultDepth,
-		},
-		ObjectRelation: &v0.RelationReference{
-			Namespace: req.ResourceObjectType,
-			Relation:  req.Permission,
-		},
-		Subject: &v0.ObjectAndRelation{
-			Namespace: req.Subject.Object.ObjectType,
-			ObjectId:  req.Subject.Object.ObjectId,
-			Relation:  normalizeSubjectRelation(req.Subject),
-		},
-		Limit:       ^uint32(0), // Set no limit for now
-		DirectStack: nil,
-		TtuStack:    nil,
-	})
-	usagemetrics.SetInContext(ctx, lookupResp.Metadata)
-	if err != nil {
-		return rewritePermissionsError(ctx, err)
+	// Resources are looked up and sent in batches, in order of their IDs, each batch resuming
+	// after the last resource sent, so that a paginated request can be resumed in the same way.
+	var afterID string
+	if cursor != nil {
+		afterID = cursor.AfterID
 	}
 
-	for _, found := range lookupResp.ResolvedOnrs {
-		if found.Namespace != req.ResourceObjectType {
-			return rewritePermissionsError(
-				ctx,
-				fmt.Errorf("got invalid resolved object %v (expected %v)", found.Namespace, req.ResourceObjectType),
-			)
+	var sent uint64
+	responseMeta := &dispatch.ResponseMeta{}
+	for {
+		// A page looks up one resource beyond its size, to know whether any remain.
+		limit := uint32(lookupStreamBatchSize)
+		if pageSize > 0 && pageSize-sent+1 < uint64(limit) {
+			limit = uint32(pageSize - sent + 1)
 		}
 
-		err := resp.Send(&v1.LookupResourcesResponse{
-			LookedUpAt:       revisionReadAt,
-			ResourceObjectId: found.ObjectId,
+		lookupResp, err := ps.dispatch.DispatchLookup(ctx, &dispatch.DispatchLookupRequest{
+			Metadata: &dispatch.ResolverMeta{
+				AtRevision:     atRevision.String(),
+				DepthRemaining: dispatchdepth.FromContext(ctx, ps.defaultDepth),
+			},
+			ObjectRelation: &v0.RelationReference{
+				Namespace: req.ResourceObjectType,
+				Relation:  req.Permission,
+			},
+			Subject: &v0.ObjectAndRelation{
+				Namespace: req.Subject.Object.ObjectType,
+				ObjectId:  req.Subject.Object.ObjectId,
+				Relation:  normalizeSubjectRelation(req.Subject),
+			},
+			Limit:           limit,
+			DirectStack:     nil,
+			TtuStack:        nil,
+			AfterResourceId: afterID,
 		})
+
+		responseMeta.DispatchCount += lookupResp.GetMetadata().GetDispatchCount()
+		responseMeta.CachedDispatchCount += lookupResp.GetMetadata().GetCachedDispatchCount()
+		if depthRequired := lookupResp.GetMetadata().GetDepthRequired(); depthRequired > responseMeta.DepthRequired {
+			responseMeta.DepthRequired = depthRequired
+		}
+		usagemetrics.SetInContext(ctx, responseMeta)
 		if err != nil {
-			return err
+			return rewritePermissionsError(ctx, err)
+		}
+
+		objectIds := make([]string, 0, len(lookupResp.ResolvedOnrs))
+		for _, found := range lookupResp.ResolvedOnrs {
+			if found.Namespace != req.ResourceObjectType {
+				return rewritePermissionsError(
+					ctx,
+					fmt.Errorf("got invalid resolved object %v (expected %v)", found.Namespace, req.ResourceObjectType),
+				)
+			}
+			objectIds = append(objectIds, found.ObjectId)
+		}
+		sort.Strings(objectIds)
+
+		for _, objectID := range objectIds {
+			if pageSize > 0 && sent == pageSize {
+				resp.SetTrailer(metadata.Pairs(
+					string(LookupNextCursor),
+					encodeLookupCursor(req, atRevision, afterID),
+				))
+				return nil
+			}
+
+			err := resp.Send(&v1.LookupResourcesResponse{
+				LookedUpAt:       revisionReadAt,
+				ResourceObjectId: objectID,
+			})
+			if err != nil {
+				return err
+			}
+
+			sent++
+			afterID = objectID
+		}
+
+		// A batch which found fewer resources than its limit found all that remained.
+		if uint32(len(objectIds)) < limit {
+			return nil
 		}
 	}
-	return nil
 }
 
 func normalizeSubjectRelation(sub *v1.SubjectReference) string {
@@ -2,12 +2,14 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/grpcutil"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
@@ -25,12 +28,20 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/datastore/proxy"
+	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/middleware/consistency"
 	"github.com/authzed/spicedb/internal/namespace"
+	dispatchv1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
 	tf "github.com/authzed/spicedb/internal/testfixtures"
 	pgraph "github.com/authzed/spicedb/pkg/graph"
+	ns "github.com/authzed/spicedb/pkg/namespace"
 	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/authzed/spicedb/pkg/zedtoken"
 )
@@ -280,6 +291,320 @@ func TestCheckPermissions(t *testing.T) {
 	}
 }
 
+func TestCheckPermissionConsistency(t *testing.T) {
+	require := require.New(t)
+	client, stop, revision := newPermissionsServicer(require, 0, memdb.DisableGC, 0)
+	defer stop()
+
+	testCases := []struct {
+		name            string
+		consistency     *v1.Consistency
+		expectExactRead bool
+		expectedCode    codes.Code
+	}{
+		{"unspecified", nil, false, codes.OK},
+		{"minimize latency", &v1.Consistency{
+			Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true},
+		}, false, codes.OK},
+		{"at least as fresh", &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: zedtoken.NewFromRevision(revision)},
+		}, false, codes.OK},
+		{"at exact snapshot", &v1.Consistency{
+			Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: zedtoken.NewFromRevision(revision)},
+		}, true, codes.OK},
+		{"fully consistent", &v1.Consistency{
+			Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true},
+		}, false, codes.OK},
+		{"invalid token", &v1.Consistency{
+			Requirement: &v1.Consistency_AtExactSnapshot{AtExactSnapshot: &v1.ZedToken{Token: "invalid"}},
+		}, false, codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			resp, err := client.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+				Consistency: tc.consistency,
+				Resource:    obj("document", "masterplan"),
+				Permission:  "viewer",
+				Subject:     sub("user", "eng_lead", ""),
+			})
+			if tc.expectedCode != codes.OK {
+				grpcutil.RequireStatus(t, tc.expectedCode, err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, resp.Permissionship)
+
+			checkedAt, err := zedtoken.DecodeRevision(resp.CheckedAt)
+			require.NoError(err)
+			if tc.expectExactRead {
+				require.True(checkedAt.Equal(revision))
+			}
+		})
+	}
+}
+
+func TestCheckPermissionDebugTrace(t *testing.T) {
+	require := require.New(t)
+	client, stop, revision := newPermissionsServicer(require, 0, memdb.DisableGC, 0)
+	defer stop()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), DebugMetadataKey, "true")
+
+	var trailer metadata.MD
+	checkResp, err := client.CheckPermission(ctx, &v1.CheckPermissionRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: zedtoken.NewFromRevision(revision),
+			},
+		},
+		Resource:   obj("document", "masterplan"),
+		Permission: "viewer",
+		Subject:    sub("user", "vp_product", ""),
+	}, grpc.Trailer(&trailer))
+	require.NoError(err)
+	require.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, checkResp.Permissionship)
+
+	encoded := trailer.Get(string(DebugTrace))
+	require.Len(encoded, 1)
+
+	type traceNode struct {
+		Resource string       `json:"resource"`
+		Subject  string       `json:"subject"`
+		Result   string       `json:"result"`
+		Tuples   []string     `json:"tuples"`
+		Children []*traceNode `json:"children"`
+	}
+	var trace traceNode
+	require.NoError(json.Unmarshal([]byte(encoded[0]), &trace))
+	require.Equal("document:masterplan#viewer", trace.Resource)
+	require.Equal("user:vp_product#...", trace.Subject)
+	require.Equal("MEMBER", trace.Result)
+
+	// The subject is the owner of the parent folder of the document, so its access is
+	// found by following the parent tuple of the document.
+	var findTuple func(node *traceNode, tpl string) bool
+	findTuple = func(node *traceNode, tpl string) bool {
+		for _, found := range node.Tuples {
+			if found == tpl {
+				return true
+			}
+		}
+		for _, child := range node.Children {
+			if findTuple(child, tpl) {
+				return true
+			}
+		}
+		return false
+	}
+	require.True(findTuple(&trace, "document:masterplan#parent@folder:strategy#..."))
+	require.True(findTuple(&trace, "folder:strategy#owner@user:vp_product#..."))
+
+	_, err = client.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+		Resource:   obj("document", "masterplan"),
+		Permission: "viewer",
+		Subject:    sub("user", "vp_product", ""),
+	}, grpc.Trailer(&trailer))
+	require.NoError(err)
+	require.Empty(trailer.Get(string(DebugTrace)))
+}
+
+func TestCheckPermissionDirectRelation(t *testing.T) {
+	client, stop, revision := newPermissionsServicer(require.New(t), 0, memdb.DisableGC, 0)
+	defer stop()
+
+	testCases := []struct {
+		name         string
+		resource     *v1.ObjectReference
+		permission   string
+		subject      *v1.SubjectReference
+		expected     v1.CheckPermissionResponse_Permissionship
+		expectedPath string
+	}{
+		{
+			"direct relation",
+			obj("document", "masterplan"),
+			"owner",
+			sub("user", "product_manager", ""),
+			v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
+			checkPathDirect,
+		},
+		{
+			"missing direct relation",
+			obj("document", "masterplan"),
+			"owner",
+			sub("user", "eng_lead", ""),
+			v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION,
+			checkPathDirect,
+		},
+		{
+			"subject relation",
+			obj("document", "masterplan"),
+			"owner",
+			sub("folder", "auditors", "viewer"),
+			v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION,
+			checkPathDispatch,
+		},
+		{
+			"rewritten permission",
+			obj("document", "masterplan"),
+			"viewer",
+			sub("user", "vp_product", ""),
+			v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
+			checkPathDispatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			before := testutil.ToFloat64(checkPathCounter.WithLabelValues(tc.expectedPath))
+			resp, err := client.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.NewFromRevision(revision),
+					},
+				},
+				Resource:   tc.resource,
+				Permission: tc.permission,
+				Subject:    tc.subject,
+			})
+			require.NoError(err)
+			require.Equal(tc.expected, resp.Permissionship)
+			require.Equal(before+1, testutil.ToFloat64(checkPathCounter.WithLabelValues(tc.expectedPath)))
+		})
+	}
+}
+
+// unavailableDatastore fails to determine revisions or read relationships once it has been
+// made unavailable.
+type unavailableDatastore struct {
+	datastore.Datastore
+	unavailable int32
+}
+
+var errDatastoreUnavailable = errors.New("connection refused")
+
+func (ud *unavailableDatastore) OptimizedRevision(ctx context.Context) (datastore.Revision, error) {
+	if atomic.LoadInt32(&ud.unavailable) != 0 {
+		return datastore.NoRevision, errDatastoreUnavailable
+	}
+	return ud.Datastore.OptimizedRevision(ctx)
+}
+
+func (ud *unavailableDatastore) QueryTuples(ctx context.Context, filter *v1.RelationshipFilter, revision datastore.Revision, opts ...options.QueryOptionsOption) (datastore.TupleIterator, error) {
+	if atomic.LoadInt32(&ud.unavailable) != 0 {
+		return nil, errDatastoreUnavailable
+	}
+	return ud.Datastore.QueryTuples(ctx, filter, revision, opts...)
+}
+
+// cachedCheckDispatcher answers every check as a member, standing in for a dispatcher whose
+// cache holds the results of the checks.
+type cachedCheckDispatcher struct {
+	dispatch.Dispatcher
+	checks int32
+}
+
+func (cd *cachedCheckDispatcher) DispatchCheck(ctx context.Context, req *dispatchv1.DispatchCheckRequest) (*dispatchv1.DispatchCheckResponse, error) {
+	atomic.AddInt32(&cd.checks, 1)
+	return &dispatchv1.DispatchCheckResponse{
+		Metadata:   &dispatchv1.ResponseMeta{DispatchCount: 1},
+		Membership: dispatchv1.DispatchCheckResponse_MEMBER,
+	}, nil
+}
+
+func TestCheckPermissionDirectRelationStandby(t *testing.T) {
+	require := require.New(t)
+
+	emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	rawDS, _ := tf.StandardDatastoreWithData(emptyDS, require)
+	unavailableDS := &unavailableDatastore{Datastore: rawDS}
+	ds := proxy.NewStandbyProxy(unavailableDS, 1*time.Hour)
+
+	// Namespaces are read from the underlying datastore, as if from the namespace cache.
+	nsm, err := namespace.NewCachingNamespaceManager(rawDS, 1*time.Second, nil)
+	require.NoError(err)
+
+	dispatcher := &cachedCheckDispatcher{}
+	client, _ := RunForTesting(t, ds, nsm, dispatcher, 50)
+
+	req := &v1.CheckPermissionRequest{
+		Resource:   obj("document", "masterplan"),
+		Permission: "owner",
+		Subject:    sub("user", "product_manager", ""),
+	}
+
+	// While the datastore is available, the check is made by reading the relationship.
+	before := testutil.ToFloat64(checkPathCounter.WithLabelValues(checkPathDirect))
+	resp, err := client.CheckPermission(context.Background(), req)
+	require.NoError(err)
+	require.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, resp.Permissionship)
+	require.Equal(before+1, testutil.ToFloat64(checkPathCounter.WithLabelValues(checkPathDirect)))
+	require.Zero(atomic.LoadInt32(&dispatcher.checks))
+
+	// Once it is unavailable, the check is served at the stale revision offered by the
+	// standby proxy, and so is dispatched to be answered from cache.
+	atomic.StoreInt32(&unavailableDS.unavailable, 1)
+
+	var header metadata.MD
+	before = testutil.ToFloat64(checkPathCounter.WithLabelValues(checkPathDispatch))
+	resp, err = client.CheckPermission(context.Background(), req, grpc.Header(&header))
+	require.NoError(err)
+	require.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, resp.Permissionship)
+	require.Equal(before+1, testutil.ToFloat64(checkPathCounter.WithLabelValues(checkPathDispatch)))
+	require.Equal(int32(1), atomic.LoadInt32(&dispatcher.checks))
+	require.Len(header.Get(consistency.StaleMetadataKey), 1)
+}
+
+func TestDirectRelationFor(t *testing.T) {
+	testCases := []struct {
+		name             string
+		nsDef            *v0.NamespaceDefinition
+		permission       string
+		expectedRelation string
+	}{
+		{"direct relation", tf.DocumentNS, "owner", "owner"},
+		{"rewritten relation", tf.DocumentNS, "editor", ""},
+		{"relation without types", tf.DocumentNS, "lock", ""},
+		{"missing relation", tf.DocumentNS, "unknown", ""},
+		{"subject relations allowed", tf.FolderNS, "viewer", ""},
+		{"computed relation", ns.Namespace("document",
+			ns.Relation("owner", nil, ns.AllowedRelation("user", "...")),
+			ns.Relation("admin", ns.Union(ns.ComputedUserset("owner"))),
+			ns.Relation("manage", ns.Union(ns.ComputedUserset("admin"))),
+		), "manage", "owner"},
+		{"union of this", ns.Namespace("document",
+			ns.Relation("owner", ns.Union(ns.This()), ns.AllowedRelation("user", "...")),
+		), "owner", "owner"},
+		{"wildcard allowed", ns.Namespace("document",
+			ns.Relation("viewer", nil, ns.AllowedPublicNamespace("user")),
+		), "viewer", ""},
+		{"cyclic computed relations", ns.Namespace("document",
+			ns.Relation("first", ns.Union(ns.ComputedUserset("second"))),
+			ns.Relation("second", ns.Union(ns.ComputedUserset("first"))),
+		), "first", ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			relation, ok := directRelationFor(tc.nsDef, tc.permission)
+			require.Equal(t, tc.expectedRelation != "", ok)
+			if ok {
+				require.Equal(t, tc.expectedRelation, relation.Name)
+			}
+		})
+	}
+}
+
 func TestLookupResources(t *testing.T) {
 	testCases := []struct {
 		objectType        string
@@ -458,6 +783,65 @@ func TestLookupResources(t *testing.T) {
 	}
 }
 
+func TestLookupResourcesPaginated(t *testing.T) {
+	require := require.New(t)
+	client, stop, revision := newPermissionsServicer(require, 0, memdb.DisableGC, 0)
+	defer stop()
+
+	req := &v1.LookupResourcesRequest{
+		ResourceObjectType: "document",
+		Permission:         "viewer",
+		Subject:            sub("user", "owner", ""),
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: zedtoken.NewFromRevision(revision),
+			},
+		},
+	}
+
+	lookupPage := func(ctx context.Context) ([]string, []string) {
+		var trailer metadata.MD
+		lookupClient, err := client.LookupResources(ctx, req, grpc.Trailer(&trailer))
+		require.NoError(err)
+
+		var resolvedObjectIds []string
+		for {
+			resp, err := lookupClient.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(err)
+			resolvedObjectIds = append(resolvedObjectIds, resp.ResourceObjectId)
+		}
+		return resolvedObjectIds, trailer.Get(string(LookupNextCursor))
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), LookupPageSizeMetadataKey, "1")
+	firstPage, cursor := lookupPage(ctx)
+	require.Equal([]string{"companyplan"}, firstPage)
+	require.Len(cursor, 1)
+
+	secondPage, cursor := lookupPage(metadata.AppendToOutgoingContext(ctx, LookupCursorMetadataKey, cursor[0]))
+	require.Equal([]string{"masterplan"}, secondPage)
+	require.Empty(cursor)
+
+	allPages, cursor := lookupPage(context.Background())
+	require.Equal([]string{"companyplan", "masterplan"}, allPages)
+	require.Empty(cursor)
+
+	fullPage, cursor := lookupPage(metadata.AppendToOutgoingContext(context.Background(), LookupPageSizeMetadataKey, "2"))
+	require.Equal([]string{"companyplan", "masterplan"}, fullPage)
+	require.Empty(cursor)
+
+	otherReq := proto.Clone(req).(*v1.LookupResourcesRequest)
+	otherReq.Subject = sub("user", "legal", "")
+	_, firstCursor := lookupPage(ctx)
+	lookupClient, err := client.LookupResources(metadata.AppendToOutgoingContext(ctx, LookupCursorMetadataKey, firstCursor[0]), otherReq)
+	require.NoError(err)
+	_, err = lookupClient.Recv()
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
+
 func TestExpand(t *testing.T) {
 	testCases := []struct {
 		startObjectType    string
@@ -3,7 +3,10 @@ package v1
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 
+	"github.com/authzed/authzed-go/pkg/responsemeta"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/validator"
@@ -12,6 +15,7 @@ import (
 	"github.com/shopspring/decimal"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/internal/datastore"
@@ -26,7 +30,36 @@ import (
 	"github.com/authzed/spicedb/internal/services/shared"
 	"github.com/authzed/spicedb/internal/sharederrors"
 	"github.com/authzed/spicedb/pkg/tuple"
-	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+const (
+	// DeleteLimitMetadataKey is the key in which clients pass the maximum number of
+	// relationships to be deleted by a DeleteRelationships request. Relationships beyond
+	// the limit are left in place, to be deleted by subsequent requests.
+	DeleteLimitMetadataKey = "x-spicedb-delete-limit"
+
+	// DeleteBatchSizeMetadataKey is the key in which clients pass the number of
+	// relationships to be deleted in each transaction of a DeleteRelationships request.
+	// Specifying either it or a limit deletes the relationships in batches, which is not
+	// atomic but does not lock the matching rows for the duration of the whole deletion.
+	DeleteBatchSizeMetadataKey = "x-spicedb-delete-batch-size"
+
+	// PreconditionResourceIDPrefixMetadataKey is the key in which clients pass the
+	// comma-separated indexes of the preconditions of a write or delete request whose
+	// optional resource ID is matched as a prefix of the IDs of resources, rather than
+	// exactly.
+	PreconditionResourceIDPrefixMetadataKey = "x-spicedb-precondition-resource-id-prefix"
+
+	// DeletedRelationshipsCount is the response header in which the number of
+	// relationships deleted by a batched DeleteRelationships request is returned.
+	DeletedRelationshipsCount responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.deletedrelationshipscount"
+
+	// DeletionMoreRemaining is the response header which indicates whether relationships
+	// matching the filter of a batched DeleteRelationships request remain after its limit
+	// was reached.
+	DeletionMoreRemaining responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.deletionmoreremaining"
+
+	defaultDeleteBatchSize = 1000
 )
 
 // NewPermissionsServer creates a PermissionsServiceServer instance.
@@ -257,13 +290,18 @@ func (ps *permissionServer) WriteRelationships(ctx context.Context, req *v1.Writ
 		DispatchCount: uint32(len(req.OptionalPreconditions)) + 1,
 	})
 
-	revision, err := ps.ds.WriteTuples(ctx, req.OptionalPreconditions, req.Updates)
+	preconditions, err := preconditionsWithPrefixes(ctx, req.OptionalPreconditions)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := ps.ds.WriteTuples(ctx, preconditions, req.Updates)
 	if err != nil {
 		return nil, rewritePermissionsError(ctx, err)
 	}
 
 	return &v1.WriteRelationshipsResponse{
-		WrittenAt: zedtoken.NewFromRevision(revision),
+		WrittenAt: consistency.NewZedToken(ctx, revision),
 	}, nil
 }
 
@@ -282,19 +320,126 @@ func (ps *permissionServer) DeleteRelationships(ctx context.Context, req *v1.Del
 		DispatchCount: uint32(len(req.OptionalPreconditions)) + 1,
 	})
 
-	revision, err := ps.ds.DeleteRelationships(ctx, req.OptionalPreconditions, req.RelationshipFilter)
+	preconditions, err := preconditionsWithPrefixes(ctx, req.OptionalPreconditions)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize, limit, batched, err := deleteBatching(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !batched {
+		revision, err := ps.ds.DeleteRelationships(ctx, preconditions, req.RelationshipFilter)
+		if err != nil {
+			return nil, rewritePermissionsError(ctx, err)
+		}
+
+		return &v1.DeleteRelationshipsResponse{
+			DeletedAt: consistency.NewZedToken(ctx, revision),
+		}, nil
+	}
+
+	result, err := datastore.DeleteRelationshipsInBatches(ctx, ps.ds, preconditions, req.RelationshipFilter, batchSize, limit)
 	if err != nil {
 		return nil, rewritePermissionsError(ctx, err)
 	}
 
+	err = responsemeta.SetResponseHeaderMetadata(ctx, map[responsemeta.ResponseMetadataHeaderKey]string{
+		DeletedRelationshipsCount: strconv.FormatUint(result.Deleted, 10),
+		DeletionMoreRemaining:     strconv.FormatBool(result.MoreRemaining),
+	})
+	if err != nil {
+		log.Ctx(ctx).Err(err).Msg("could not report batched deletion metadata")
+	}
+
 	return &v1.DeleteRelationshipsResponse{
-		DeletedAt: zedtoken.NewFromRevision(revision),
+		DeletedAt: consistency.NewZedToken(ctx, result.Revision),
 	}, nil
 }
 
+// deleteBatching returns the batch size and limit requested in the metadata of a delete
+// request, and whether the deletion should be made in batches at all.
+func deleteBatching(ctx context.Context) (batchSize, limit uint64, batched bool, err error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, 0, false, nil
+	}
+
+	parse := func(key string) (uint64, bool, error) {
+		values := md.Get(key)
+		if len(values) == 0 {
+			return 0, false, nil
+		}
+		value, err := strconv.ParseUint(values[0], 10, 64)
+		if err != nil || value == 0 {
+			return 0, false, status.Errorf(codes.InvalidArgument, "%s must be a positive integer", key)
+		}
+		return value, true, nil
+	}
+
+	limit, hasLimit, err := parse(DeleteLimitMetadataKey)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	batchSize, hasBatchSize, err := parse(DeleteBatchSizeMetadataKey)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if !hasLimit && !hasBatchSize {
+		return 0, 0, false, nil
+	}
+
+	if !hasBatchSize {
+		batchSize = defaultDeleteBatchSize
+	}
+	if hasLimit && limit < batchSize {
+		batchSize = limit
+	}
+	return batchSize, limit, true, nil
+}
+
+// preconditionsWithPrefixes returns the preconditions of a request, with those whose
+// indexes are named in the metadata of the request matching their optional resource ID
+// as a prefix.
+func preconditionsWithPrefixes(ctx context.Context, preconditions []*v1.Precondition) ([]*v1.Precondition, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return preconditions, nil
+	}
+
+	values := md.Get(PreconditionResourceIDPrefixMetadataKey)
+	if len(values) == 0 {
+		return preconditions, nil
+	}
+
+	prefixed := make([]*v1.Precondition, len(preconditions))
+	copy(prefixed, preconditions)
+	for _, value := range strings.Split(values[0], ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || index < 0 || index >= len(preconditions) {
+			return nil, status.Errorf(codes.InvalidArgument, "%s must list indexes of preconditions: %s", PreconditionResourceIDPrefixMetadataKey, value)
+		}
+
+		precond := preconditions[index]
+		if precond.Filter.OptionalResourceId == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "precondition %d has no resource ID to match as a prefix", index)
+		}
+
+		prefixed[index] = &v1.Precondition{
+			Operation: precond.Operation,
+			Filter:    datastore.WithResourceIDPrefix(precond.Filter),
+		}
+	}
+	return prefixed, nil
+}
+
 func rewritePermissionsError(ctx context.Context, err error) error {
 	var nsNotFoundError sharederrors.UnknownNamespaceError
 	var relNotFoundError sharederrors.UnknownRelationError
+	var maxDepthError dispatch.MaxDepthExceededError
+	var revisionErr datastore.ErrInvalidRevision
 
 	switch {
 	case errors.As(err, &nsNotFoundError):
@@ -310,12 +455,24 @@ func rewritePermissionsError(ctx context.Context, err error) error {
 	case errors.As(err, &graph.ErrRequestCanceled{}):
 		return status.Errorf(codes.Canceled, "request canceled: %s", err)
 
-	case errors.As(err, &datastore.ErrInvalidRevision{}):
-		return status.Errorf(codes.OutOfRange, "invalid zedtoken: %s", err)
+	case errors.As(err, &maxDepthError):
+		return serviceerrors.NewMaxDepthExceededErr(maxDepthError.ChainString())
+
+	case errors.Is(err, dispatch.ErrMaxDepth):
+		return serviceerrors.NewMaxDepthExceededErr("")
+
+	case errors.As(err, &revisionErr):
+		return serviceerrors.NewInvalidRevisionErr(ctx, revisionErr)
+
+	case errors.As(err, &datastore.ErrQueryTimeout{}):
+		return status.Errorf(codes.DeadlineExceeded, "%s", err)
 
 	case errors.As(err, &datastore.ErrReadOnly{}):
 		return serviceerrors.ErrServiceReadOnly
 
+	case errors.As(err, &datastore.ErrMaintenanceMode{}):
+		return serviceerrors.ErrServiceInMaintenance
+
 	case errors.As(err, &graph.ErrRelationMissingTypeInfo{}):
 		return status.Errorf(codes.FailedPrecondition, "failed precondition: %s", err)
 
@@ -12,7 +12,9 @@ import (
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/grpcutil"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/internal/datastore/memdb"
@@ -800,6 +802,84 @@ func TestDeleteRelationships(t *testing.T) {
 	}
 }
 
+func TestDeleteRelationshipsInBatches(t *testing.T) {
+	require := require.New(t)
+	client, stop, _ := newPermissionsServicer(require, 0, memdb.DisableGC, 0)
+	defer stop()
+
+	req := &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:     "document",
+			OptionalRelation: "parent",
+		},
+	}
+	deleteBatch := func() (*v1.DeleteRelationshipsResponse, metadata.MD, error) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(),
+			DeleteLimitMetadataKey, "3",
+			DeleteBatchSizeMetadataKey, "2",
+		)
+		var header metadata.MD
+		resp, err := client.DeleteRelationships(ctx, req, grpc.Header(&header))
+		return resp, header, err
+	}
+
+	_, header, err := deleteBatch()
+	require.NoError(err)
+	require.Equal([]string{"3"}, header.Get(string(DeletedRelationshipsCount)))
+	require.Equal([]string{"true"}, header.Get(string(DeletionMoreRemaining)))
+
+	resp, header, err := deleteBatch()
+	require.NoError(err)
+	require.Equal([]string{"1"}, header.Get(string(DeletedRelationshipsCount)))
+	require.Equal([]string{"false"}, header.Get(string(DeletionMoreRemaining)))
+	require.EqualValues(standardTuplesWithout(map[string]struct{}{
+		"document:companyplan#parent@folder:company#...": {},
+		"document:masterplan#parent@folder:strategy#...": {},
+		"document:masterplan#parent@folder:plans#...":    {},
+		"document:healthplan#parent@folder:plans#...":    {},
+	}), readAll(require, client, resp.DeletedAt))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), DeleteLimitMetadataKey, "none")
+	_, err = client.DeleteRelationships(ctx, req)
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
+
+func TestWritePreconditionResourceIDPrefix(t *testing.T) {
+	require := require.New(t)
+	client, stop, _ := newPermissionsServicer(require, 0, memdb.DisableGC, 0)
+	defer stop()
+
+	req := &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.ParseRel("document:newplan#viewer@user:eng_lead"),
+		}},
+		OptionalPreconditions: []*v1.Precondition{{
+			Operation: v1.Precondition_OPERATION_MUST_MATCH,
+			Filter: &v1.RelationshipFilter{
+				ResourceType:       "document",
+				OptionalResourceId: "master",
+				OptionalRelation:   "viewer",
+				OptionalSubjectFilter: &v1.SubjectFilter{
+					SubjectType:       "user",
+					OptionalSubjectId: "eng_lead",
+				},
+			},
+		}},
+	}
+
+	_, err := client.WriteRelationships(context.Background(), req)
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), PreconditionResourceIDPrefixMetadataKey, "0")
+	_, err = client.WriteRelationships(ctx, req)
+	require.NoError(err)
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), PreconditionResourceIDPrefixMetadataKey, "1")
+	_, err = client.WriteRelationships(ctx, req)
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
+
 func readAll(require *require.Assertions, client v1.PermissionsServiceClient, token *v1.ZedToken) map[string]struct{} {
 	got := make(map[string]struct{})
 	namespaces := []string{"document", "folder"}
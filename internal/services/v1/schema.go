@@ -74,20 +74,28 @@ func (ss *schemaServer) ReadSchema(ctx context.Context, in *v1.ReadSchemaRequest
 func (ss *schemaServer) WriteSchema(ctx context.Context, in *v1.WriteSchemaRequest) (*v1.WriteSchemaResponse, error) {
 	log.Ctx(ctx).Trace().Str("schema", in.GetSchema()).Msg("requested Schema to be written")
 
-	readRevision, err := ss.ds.HeadRevision(ctx)
-	if err != nil {
-		return nil, rewritePermissionsError(ctx, err)
-	}
-
-	inputSchema := compiler.InputSchema{
+	if err := ss.writeSchema(ctx, []compiler.InputSchema{{
 		Source:       input.Source("schema"),
 		SchemaString: in.GetSchema(),
+	}}); err != nil {
+		return nil, err
+	}
+
+	return &v1.WriteSchemaResponse{}, nil
+}
+
+// writeSchema compiles the schemas together and replaces the stored schema with the
+// definitions compiled.
+func (ss *schemaServer) writeSchema(ctx context.Context, inputSchemas []compiler.InputSchema) error {
+	readRevision, err := ss.ds.HeadRevision(ctx)
+	if err != nil {
+		return rewritePermissionsError(ctx, err)
 	}
 
 	// Build a map of existing definitions to determine those being removed, if any.
 	existingDefs, err := ss.ds.ListNamespaces(ctx, readRevision)
 	if err != nil {
-		return nil, rewriteSchemaError(ctx, err)
+		return rewriteSchemaError(ctx, err)
 	}
 
 	existingDefMap := map[string]bool{}
@@ -97,50 +105,66 @@ func (ss *schemaServer) WriteSchema(ctx context.Context, in *v1.WriteSchemaReque
 
 	// Compile the schema into the namespace definitions.
 	emptyDefaultPrefix := ""
-	nsdefs, err := compiler.Compile([]compiler.InputSchema{inputSchema}, &emptyDefaultPrefix)
+	nsdefs, err := compiler.Compile(inputSchemas, &emptyDefaultPrefix)
 	if err != nil {
-		return nil, rewriteSchemaError(ctx, err)
+		return rewriteSchemaError(ctx, err)
 	}
 	log.Ctx(ctx).Trace().Interface("namespaceDefinitions", nsdefs).Msg("compiled namespace definitions")
 
-	// For each definition, perform a diff and ensure the changes will not result in any
+	allowBreaking, err := breakingSchemaChangesAllowed(ctx)
+	if err != nil {
+		return err
+	}
+
+	// For each definition, perform a diff and find the changes which would result in
 	// relationships left without associated schema.
+	var breakingChanges []shared.BreakingSchemaChange
 	for _, nsdef := range nsdefs {
 		ts, err := namespace.BuildNamespaceTypeSystemForDefs(nsdef, nsdefs)
 		if err != nil {
-			return nil, rewriteSchemaError(ctx, err)
+			return rewriteSchemaError(ctx, err)
 		}
 
 		if err := ts.Validate(ctx); err != nil {
-			return nil, rewriteSchemaError(ctx, err)
+			return rewriteSchemaError(ctx, err)
 		}
 
-		if err := shared.SanityCheckExistingRelationships(ctx, ss.ds, nsdef, readRevision); err != nil {
-			return nil, rewriteSchemaError(ctx, err)
+		changes, err := shared.FindBreakingChanges(ctx, ss.ds, nsdef, readRevision)
+		if err != nil {
+			return rewriteSchemaError(ctx, err)
 		}
+		breakingChanges = append(breakingChanges, changes...)
 
 		existingDefMap[nsdef.Name] = false
 	}
 	log.Ctx(ctx).Trace().Interface("namespaceDefinitions", nsdefs).Msg("validated namespace definitions")
 
-	// Ensure that deleting namespaces will not result in any relationships left without associated
+	// Find the deleted namespaces which would result in relationships left without associated
 	// schema.
-	for nsdefName, removed := range existingDefMap {
-		if !removed {
+	for _, existingDef := range existingDefs {
+		if !existingDefMap[existingDef.Name] {
 			continue
 		}
 
-		err := shared.EnsureNoRelationshipsExist(ctx, ss.ds, nsdefName)
+		changes, err := shared.FindDefinitionRemovalBreakingChanges(ctx, ss.ds, existingDef.Name)
 		if err != nil {
-			return nil, rewriteSchemaError(ctx, err)
+			return rewriteSchemaError(ctx, err)
+		}
+		breakingChanges = append(breakingChanges, changes...)
+	}
+
+	if len(breakingChanges) > 0 {
+		if !allowBreaking {
+			return newBreakingSchemaChangesErr(breakingChanges)
 		}
+		setBreakingSchemaChanges(ctx, breakingChanges)
 	}
 
 	// Write the new namespaces.
 	names := make([]string, 0, len(nsdefs))
 	for _, nsdef := range nsdefs {
 		if _, err := ss.ds.WriteNamespace(ctx, nsdef); err != nil {
-			return nil, rewriteSchemaError(ctx, err)
+			return rewriteSchemaError(ctx, err)
 		}
 
 		names = append(names, nsdef.Name)
@@ -157,7 +181,7 @@ func (ss *schemaServer) WriteSchema(ctx context.Context, in *v1.WriteSchemaReque
 			continue
 		}
 		if _, err := ss.ds.DeleteNamespace(ctx, nsdefName); err != nil {
-			return nil, rewriteSchemaError(ctx, err)
+			return rewriteSchemaError(ctx, err)
 		}
 		removedNames = append(removedNames, nsdefName)
 	}
@@ -168,7 +192,7 @@ func (ss *schemaServer) WriteSchema(ctx context.Context, in *v1.WriteSchemaReque
 
 	log.Ctx(ctx).Trace().Interface("namespaceDefinitions", nsdefs).Strs("addedOrChanged", names).Strs("removed", removedNames).Msg("wrote namespace definitions")
 
-	return &v1.WriteSchemaResponse{}, nil
+	return nil
 }
 
 func rewriteSchemaError(ctx context.Context, err error) error {
@@ -182,6 +206,8 @@ func rewriteSchemaError(ctx context.Context, err error) error {
 		return status.Errorf(codes.InvalidArgument, "%s", err)
 	case errors.As(err, &datastore.ErrReadOnly{}):
 		return serviceerrors.ErrServiceReadOnly
+	case errors.As(err, &datastore.ErrMaintenanceMode{}):
+		return serviceerrors.ErrServiceInMaintenance
 	default:
 		log.Ctx(ctx).Err(err)
 		return err
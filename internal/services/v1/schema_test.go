@@ -10,9 +10,12 @@ import (
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/grpcutil"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/authzed/spicedb/internal/datastore/memdb"
@@ -213,3 +216,73 @@ func TestSchemaDeleteDefinition(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, `definition example/user {}`, readback.SchemaText)
 }
+
+func TestSchemaBreakingChanges(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(t, err)
+
+	srv := NewSchemaServer(ds)
+
+	// Write a basic schema.
+	_, err = srv.WriteSchema(context.Background(), &v1.WriteSchemaRequest{
+		Schema: `definition example/user {}
+
+		definition example/document {
+			relation somerelation: example/user
+			relation viewer: example/user | example/user:*
+		}`,
+	})
+	require.NoError(t, err)
+
+	// Write relationships for both relations, one of them with the wildcard.
+	ns, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, nil)
+	require.NoError(t, err)
+
+	dispatch := graph.NewLocalOnlyDispatcher(ns, ds)
+	aclSrv := v0svc.NewACLServer(ds, ns, dispatch, 50)
+
+	_, err = aclSrv.Write(context.Background(), &v0.WriteRequest{
+		Updates: []*v0.RelationTupleUpdate{
+			tuple.Create(tuple.MustParse("example/document:somedoc#somerelation@example/user:someuser#...")),
+			tuple.Create(tuple.MustParse("example/document:somedoc#viewer@example/user:*#...")),
+		},
+	})
+	require.NoError(t, err)
+
+	// Removing the relation and the wildcard strands both relationships.
+	breakingSchema := `definition example/user {}
+
+	definition example/document {
+		relation viewer: example/user
+	}`
+
+	_, err = srv.WriteSchema(context.Background(), &v1.WriteSchemaRequest{Schema: breakingSchema})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	var violations []*errdetails.PreconditionFailure_Violation
+	for _, detail := range status.Convert(err).Details() {
+		if failure, ok := detail.(*errdetails.PreconditionFailure); ok {
+			violations = failure.Violations
+		}
+	}
+	require.Len(t, violations, 2)
+	require.Equal(t, "removed-relation", violations[0].Type)
+	require.Equal(t, "example/document#somerelation", violations[0].Subject)
+	require.Equal(t, "relation-wildcard-type-removed", violations[1].Type)
+	require.Equal(t, "example/document#viewer@example/user:*", violations[1].Subject)
+
+	// An invalid override is rejected.
+	invalidCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AllowBreakingSchemaChangesMetadataKey, "maybe"))
+	_, err = srv.WriteSchema(invalidCtx, &v1.WriteSchemaRequest{Schema: breakingSchema})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	// With the override, the schema is written regardless.
+	allowCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AllowBreakingSchemaChangesMetadataKey, "true"))
+	_, err = srv.WriteSchema(allowCtx, &v1.WriteSchemaRequest{Schema: breakingSchema})
+	require.NoError(t, err)
+
+	readback, err := srv.ReadSchema(context.Background(), &v1.ReadSchemaRequest{})
+	require.NoError(t, err)
+	require.Contains(t, readback.SchemaText, "relation viewer: example/user\n")
+	require.NotContains(t, readback.SchemaText, "somerelation")
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"context"
+	"sort"
+
+	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/validator"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+// SchemaBundleServiceServer is the server API for the SchemaBundleService, which is not
+// part of the API definitions and so exchanges structs.
+type SchemaBundleServiceServer interface {
+	// WriteSchemaBundle writes a schema split across named files, which may import one
+	// another and add to the definitions of one another with partial definitions, as
+	// WriteSchema does for a single file.
+	//
+	// The request has the field `files`, a struct of the source of each file by its name,
+	// by which the files import it. The response is empty.
+	WriteSchemaBundle(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+func writeSchemaBundleHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchemaBundleServiceServer).WriteSchemaBundle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spicedb.v1.SchemaBundleService/WriteSchemaBundle",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchemaBundleServiceServer).WriteSchemaBundle(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SchemaBundleService_ServiceDesc is the grpc.ServiceDesc for the SchemaBundleService.
+var SchemaBundleService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "spicedb.v1.SchemaBundleService",
+	HandlerType: (*SchemaBundleServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "WriteSchemaBundle",
+			Handler:    writeSchemaBundleHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterSchemaBundleServiceServer registers the SchemaBundleService on the server.
+func RegisterSchemaBundleServiceServer(s grpc.ServiceRegistrar, srv SchemaBundleServiceServer) {
+	s.RegisterService(&SchemaBundleService_ServiceDesc, srv)
+}
+
+// SchemaBundleServiceClient is the client API for the SchemaBundleService.
+type SchemaBundleServiceClient interface {
+	WriteSchemaBundle(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+}
+
+type schemaBundleServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewSchemaBundleServiceClient creates a client of the SchemaBundleService.
+func NewSchemaBundleServiceClient(cc grpc.ClientConnInterface) SchemaBundleServiceClient {
+	return &schemaBundleServiceClient{cc}
+}
+
+func (c *schemaBundleServiceClient) WriteSchemaBundle(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := c.cc.Invoke(ctx, "/spicedb.v1.SchemaBundleService/WriteSchemaBundle", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NewSchemaBundleServer creates a SchemaBundleServiceServer instance.
+func NewSchemaBundleServer(ds datastore.Datastore) SchemaBundleServiceServer {
+	return &schemaServer{
+		ds: ds,
+		WithServiceSpecificInterceptors: shared.WithServiceSpecificInterceptors{
+			Unary:  grpcvalidate.UnaryServerInterceptor(),
+			Stream: grpcvalidate.StreamServerInterceptor(),
+		},
+	}
+}
+
+func (ss *schemaServer) WriteSchemaBundle(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	files := req.Fields["files"].GetStructValue().GetFields()
+	if len(files) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "writing a schema bundle requires at least one file")
+	}
+
+	// The files are compiled in the order of their names, so that conflicts between them
+	// are reported consistently.
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	inputSchemas := make([]compiler.InputSchema, 0, len(names))
+	for _, name := range names {
+		source, ok := files[name].GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "the source of file `%s` must be a string", name)
+		}
+
+		inputSchemas = append(inputSchemas, compiler.InputSchema{
+			Source:       input.Source(name),
+			SchemaString: source.StringValue,
+		})
+	}
+	log.Ctx(ctx).Trace().Strs("files", names).Msg("requested Schema bundle to be written")
+
+	if err := ss.writeSchema(ctx, inputSchemas); err != nil {
+		return nil, err
+	}
+
+	return &structpb.Struct{}, nil
+}
@@ -0,0 +1,73 @@
+package v1
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+)
+
+func TestWriteSchemaBundle(t *testing.T) {
+	require := require.New(t)
+
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	bundleSrv := NewSchemaBundleServer(ds)
+	schemaSrv := NewSchemaServer(ds)
+
+	bundle := func(files map[string]interface{}) *structpb.Struct {
+		req, err := structpb.NewStruct(map[string]interface{}{"files": files})
+		require.NoError(err)
+		return req
+	}
+
+	_, err = bundleSrv.WriteSchemaBundle(context.Background(), bundle(map[string]interface{}{
+		"base.zed": `definition user {}
+
+		definition document {
+			relation viewer: user
+		}`,
+		"teams.zed": `import "base.zed"
+
+		definition team {
+			relation member: user
+		}
+
+		partial definition document {
+			relation team: team
+			permission view = viewer + team->member
+		}`,
+	}))
+	require.NoError(err)
+
+	readback, err := schemaSrv.ReadSchema(context.Background(), &v1.ReadSchemaRequest{})
+	require.NoError(err)
+	require.Equal(`definition document {
+	relation viewer: user
+	relation team: team
+	permission view = viewer + team->member
+}
+
+definition team {
+	relation member: user
+}
+
+definition user {}`, readback.SchemaText)
+
+	_, err = bundleSrv.WriteSchemaBundle(context.Background(), bundle(map[string]interface{}{
+		"base.zed":  `definition user {}`,
+		"teams.zed": `definition user {}`,
+	}))
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+	require.Contains(err.Error(), "definition `user` is defined in both `base.zed` and `teams.zed`")
+
+	_, err = bundleSrv.WriteSchemaBundle(context.Background(), bundle(map[string]interface{}{}))
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
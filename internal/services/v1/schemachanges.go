@@ -0,0 +1,110 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/services/shared"
+)
+
+const (
+	// AllowBreakingSchemaChangesMetadataKey is the request metadata key which, when true,
+	// allows WriteSchema to write a schema which leaves existing relationships without their
+	// object definitions, relations or allowed subject types. The breaking changes made are
+	// returned in the BreakingSchemaChanges trailer.
+	AllowBreakingSchemaChangesMetadataKey = "io.spicedb.requestmeta.allow-breaking-schema-changes"
+
+	// BreakingSchemaChanges is the response trailer in which the breaking changes made by an
+	// allowed WriteSchema are returned, one value per change of the form
+	// "<type>:<definition>[#<relation>][@<subject type>]".
+	BreakingSchemaChanges responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.breaking-schema-changes"
+
+	// ReasonBreakingSchemaChanges is the error reason that will show up in ErrorInfo when a
+	// WriteSchema is rejected for leaving existing relationships without associated schema.
+	// Each breaking change is described by a violation of the PreconditionFailure details.
+	ReasonBreakingSchemaChanges = "BREAKING_SCHEMA_CHANGES"
+)
+
+func breakingSchemaChangesAllowed(ctx context.Context) (bool, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false, nil
+	}
+
+	values := md.Get(AllowBreakingSchemaChangesMetadataKey)
+	if len(values) == 0 {
+		return false, nil
+	}
+
+	allowed, err := strconv.ParseBool(values[0])
+	if err != nil {
+		return false, status.Errorf(codes.InvalidArgument, "invalid %s: %s", AllowBreakingSchemaChangesMetadataKey, values[0])
+	}
+	return allowed, nil
+}
+
+func breakingSchemaChangeSubject(change shared.BreakingSchemaChange) string {
+	subject := change.Definition
+	if change.Relation != "" {
+		subject = fmt.Sprintf("%s#%s", subject, change.Relation)
+	}
+	if change.Subject != "" {
+		subject = fmt.Sprintf("%s@%s", subject, change.Subject)
+	}
+	return subject
+}
+
+// newBreakingSchemaChangesErr constructs the error with which a WriteSchema making the
+// breaking changes is rejected.
+func newBreakingSchemaChangesErr(changes []shared.BreakingSchemaChange) error {
+	violations := make([]*errdetails.PreconditionFailure_Violation, 0, len(changes))
+	for _, change := range changes {
+		violations = append(violations, &errdetails.PreconditionFailure_Violation{
+			Type:        string(change.Type),
+			Subject:     breakingSchemaChangeSubject(change),
+			Description: change.Message,
+		})
+	}
+
+	message := changes[0].Message
+	if len(changes) > 1 {
+		message = fmt.Sprintf("%s (and %d other breaking changes); set %s to write the schema regardless", message, len(changes)-1, AllowBreakingSchemaChangesMetadataKey)
+	} else {
+		message = fmt.Sprintf("%s; set %s to write the schema regardless", message, AllowBreakingSchemaChangesMetadataKey)
+	}
+
+	status, err := status.New(codes.InvalidArgument, message).WithDetails(
+		&errdetails.ErrorInfo{
+			Reason: ReasonBreakingSchemaChanges,
+			Domain: "authzed.com",
+		},
+		&errdetails.PreconditionFailure{Violations: violations},
+	)
+	if err != nil {
+		panic("error constructing breaking schema changes error")
+	}
+	return status.Err()
+}
+
+// setBreakingSchemaChanges returns the breaking changes made by the schema in the response
+// trailer.
+func setBreakingSchemaChanges(ctx context.Context, changes []shared.BreakingSchemaChange) {
+	values := make([]string, 0, len(changes))
+	for _, change := range changes {
+		values = append(values, fmt.Sprintf("%s:%s", change.Type, breakingSchemaChangeSubject(change)))
+	}
+
+	log.Ctx(ctx).Warn().Strs("changes", values).Msg("writing schema with breaking changes")
+	if err := grpc.SetTrailer(ctx, metadata.MD{string(BreakingSchemaChanges): values}); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("unable to set breaking schema changes trailer")
+	}
+}
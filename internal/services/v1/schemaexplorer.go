@@ -0,0 +1,376 @@
+package v1
+
+import (
+	"context"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/validator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/schemadsl/lint"
+)
+
+// SchemaExplorerServiceServer is the server API for the SchemaExplorerService, which is
+// not part of the API definitions and so exchanges structs.
+type SchemaExplorerServiceServer interface {
+	// ReachabilityGraph returns the graph of the relations, and types of subjects, which
+	// can contribute subjects to a permission, as computed from the schema at the head
+	// revision.
+	//
+	// The request has the fields `resource_type` and `permission`. The response has the
+	// field `nodes`, listing the relations and types of subjects of the graph, and the
+	// field `edges`, listing the ways in which they contribute to one another, each with
+	// the fields `from`, `to`, `kind`, and, when they apply, `via`, `operation` and
+	// `excluded`.
+	ReachabilityGraph(context.Context, *structpb.Struct) (*structpb.Struct, error)
+
+	// LintSchema returns the issues found by the schema linter in the schema of the field
+	// `schema`, or in the schema at the head revision if it is empty.
+	//
+	// The response has the field `findings`, listing the issues found, each with the
+	// fields `rule`, `definition`, `message` and, if the issue is found in one, `relation`.
+	LintSchema(context.Context, *structpb.Struct) (*structpb.Struct, error)
+
+	// DeadDefinitions cross-references the schema at the head revision with the
+	// relationships stored at that revision, to find the parts of the schema which can
+	// likely be removed.
+	//
+	// The response has the field `revision`, at which the relationships were read, the
+	// field `empty_relations`, listing the relations without any relationship, and the
+	// field `unreferenced_permissions`, listing the permissions not read by any other
+	// permission, each with the fields `definition` and `relation`.
+	DeadDefinitions(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+func reachabilityGraphHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchemaExplorerServiceServer).ReachabilityGraph(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spicedb.v1.SchemaExplorerService/ReachabilityGraph",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchemaExplorerServiceServer).ReachabilityGraph(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func lintSchemaHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchemaExplorerServiceServer).LintSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spicedb.v1.SchemaExplorerService/LintSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchemaExplorerServiceServer).LintSchema(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func deadDefinitionsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchemaExplorerServiceServer).DeadDefinitions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spicedb.v1.SchemaExplorerService/DeadDefinitions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchemaExplorerServiceServer).DeadDefinitions(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SchemaExplorerService_ServiceDesc is the grpc.ServiceDesc for the SchemaExplorerService.
+var SchemaExplorerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "spicedb.v1.SchemaExplorerService",
+	HandlerType: (*SchemaExplorerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReachabilityGraph",
+			Handler:    reachabilityGraphHandler,
+		},
+		{
+			MethodName: "LintSchema",
+			Handler:    lintSchemaHandler,
+		},
+		{
+			MethodName: "DeadDefinitions",
+			Handler:    deadDefinitionsHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterSchemaExplorerServiceServer registers the SchemaExplorerService on the server.
+func RegisterSchemaExplorerServiceServer(s grpc.ServiceRegistrar, srv SchemaExplorerServiceServer) {
+	s.RegisterService(&SchemaExplorerService_ServiceDesc, srv)
+}
+
+// SchemaExplorerServiceClient is the client API for the SchemaExplorerService.
+type SchemaExplorerServiceClient interface {
+	ReachabilityGraph(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	LintSchema(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	DeadDefinitions(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+}
+
+type schemaExplorerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewSchemaExplorerServiceClient creates a client of the SchemaExplorerService.
+func NewSchemaExplorerServiceClient(cc grpc.ClientConnInterface) SchemaExplorerServiceClient {
+	return &schemaExplorerServiceClient{cc}
+}
+
+func (c *schemaExplorerServiceClient) ReachabilityGraph(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := c.cc.Invoke(ctx, "/spicedb.v1.SchemaExplorerService/ReachabilityGraph", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *schemaExplorerServiceClient) LintSchema(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := c.cc.Invoke(ctx, "/spicedb.v1.SchemaExplorerService/LintSchema", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *schemaExplorerServiceClient) DeadDefinitions(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := c.cc.Invoke(ctx, "/spicedb.v1.SchemaExplorerService/DeadDefinitions", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NewSchemaExplorerServer creates a SchemaExplorerServiceServer instance.
+func NewSchemaExplorerServer(ds datastore.Datastore, nsm namespace.Manager) SchemaExplorerServiceServer {
+	return &schemaExplorerServer{
+		ds:  ds,
+		nsm: nsm,
+		WithServiceSpecificInterceptors: shared.WithServiceSpecificInterceptors{
+			Unary:  grpcvalidate.UnaryServerInterceptor(),
+			Stream: grpcvalidate.StreamServerInterceptor(),
+		},
+	}
+}
+
+type schemaExplorerServer struct {
+	shared.WithServiceSpecificInterceptors
+
+	ds  datastore.Datastore
+	nsm namespace.Manager
+}
+
+func (es *schemaExplorerServer) ReachabilityGraph(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	resourceType := req.Fields["resource_type"].GetStringValue()
+	permission := req.Fields["permission"].GetStringValue()
+	if resourceType == "" || permission == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "computing a reachability graph requires a resource_type and a permission")
+	}
+
+	readRevision, err := es.ds.HeadRevision(ctx)
+	if err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	if err := es.nsm.CheckNamespaceAndRelation(ctx, resourceType, permission, false, readRevision); err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	_, ts, err := es.nsm.ReadNamespaceAndTypes(ctx, resourceType, readRevision)
+	if err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	graph, err := ts.Reachability(ctx, permission)
+	if err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	nodes := make([]interface{}, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		nodes = append(nodes, node)
+	}
+
+	edges := make([]interface{}, 0, len(graph.Edges))
+	for _, edge := range graph.Edges {
+		encoded := map[string]interface{}{
+			"from": edge.From,
+			"to":   edge.To,
+			"kind": string(edge.Kind),
+		}
+		if edge.Via != "" {
+			encoded["via"] = edge.Via
+		}
+		if edge.Operation != "" {
+			encoded["operation"] = edge.Operation
+		}
+		if edge.Excluded {
+			encoded["excluded"] = true
+		}
+		edges = append(edges, encoded)
+	}
+
+	resp, err := structpb.NewStruct(map[string]interface{}{
+		"nodes": nodes,
+		"edges": edges,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to encode reachability graph: %s", err)
+	}
+	return resp, nil
+}
+
+func (es *schemaExplorerServer) LintSchema(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	var nsdefs []*v0.NamespaceDefinition
+	if schema := req.Fields["schema"].GetStringValue(); schema != "" {
+		emptyDefaultPrefix := ""
+		compiled, err := compiler.Compile([]compiler.InputSchema{{
+			Source:       input.Source("schema"),
+			SchemaString: schema,
+		}}, &emptyDefaultPrefix)
+		if err != nil {
+			return nil, rewriteSchemaError(ctx, err)
+		}
+		nsdefs = compiled
+	} else {
+		readRevision, err := es.ds.HeadRevision(ctx)
+		if err != nil {
+			return nil, rewritePermissionsError(ctx, err)
+		}
+
+		nsdefs, err = es.ds.ListNamespaces(ctx, readRevision)
+		if err != nil {
+			return nil, rewriteSchemaError(ctx, err)
+		}
+	}
+
+	findings := lint.Lint(nsdefs)
+	encodedFindings := make([]interface{}, 0, len(findings))
+	for _, finding := range findings {
+		encoded := map[string]interface{}{
+			"rule":       string(finding.Rule),
+			"definition": finding.Definition,
+			"message":    finding.Message,
+		}
+		if finding.Relation != "" {
+			encoded["relation"] = finding.Relation
+		}
+		encodedFindings = append(encodedFindings, encoded)
+	}
+
+	resp, err := structpb.NewStruct(map[string]interface{}{
+		"findings": encodedFindings,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to encode lint findings: %s", err)
+	}
+	return resp, nil
+}
+
+func (es *schemaExplorerServer) DeadDefinitions(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	readRevision, err := es.ds.HeadRevision(ctx)
+	if err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	nsdefs, err := es.ds.ListNamespaces(ctx, readRevision)
+	if err != nil {
+		return nil, rewriteSchemaError(ctx, err)
+	}
+
+	// Relations are found to be empty by reading a single relationship of each, rather
+	// than counting their relationships, so that the analysis remains cheap for large
+	// relations.
+	emptyRelations := []interface{}{}
+	for _, nsdef := range nsdefs {
+		for _, relation := range nsdef.Relation {
+			if relation.UsersetRewrite != nil {
+				continue
+			}
+
+			iter, err := es.ds.QueryTuples(ctx, &v1.RelationshipFilter{
+				ResourceType:     nsdef.Name,
+				OptionalRelation: relation.Name,
+			}, readRevision, options.WithLimit(options.LimitOne))
+			if err != nil {
+				return nil, rewritePermissionsError(ctx, err)
+			}
+
+			found := iter.Next() != nil
+			err = iter.Err()
+			iter.Close()
+			if err != nil {
+				return nil, rewritePermissionsError(ctx, err)
+			}
+
+			if !found {
+				emptyRelations = append(emptyRelations, map[string]interface{}{
+					"definition": nsdef.Name,
+					"relation":   relation.Name,
+				})
+			}
+		}
+	}
+
+	permissions := make(map[string]struct{})
+	for _, nsdef := range nsdefs {
+		for _, relation := range nsdef.Relation {
+			if relation.UsersetRewrite != nil {
+				permissions[nsdef.Name+"#"+relation.Name] = struct{}{}
+			}
+		}
+	}
+
+	unreferencedPermissions := []interface{}{}
+	for _, unused := range lint.UnusedRelations(nsdefs) {
+		if _, ok := permissions[unused.Namespace+"#"+unused.Relation]; !ok {
+			continue
+		}
+
+		unreferencedPermissions = append(unreferencedPermissions, map[string]interface{}{
+			"definition": unused.Namespace,
+			"relation":   unused.Relation,
+		})
+	}
+
+	resp, err := structpb.NewStruct(map[string]interface{}{
+		"revision":                 readRevision.String(),
+		"empty_relations":          emptyRelations,
+		"unreferenced_permissions": unreferencedPermissions,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to encode dead definitions: %s", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,218 @@
+package v1
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/namespace"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+)
+
+func TestReachabilityGraph(t *testing.T) {
+	testCases := []struct {
+		resourceType  string
+		permission    string
+		expectedCode  codes.Code
+		expectedNodes []interface{}
+		expectedEdges []interface{}
+	}{
+		{
+			"document", "owner",
+			codes.OK,
+			[]interface{}{"document#owner", "user#..."},
+			[]interface{}{
+				map[string]interface{}{"from": "document#owner", "to": "user#...", "kind": "direct"},
+			},
+		},
+		{
+			"document", "viewer",
+			codes.OK,
+			[]interface{}{
+				"document#editor",
+				"document#owner",
+				"document#viewer",
+				"folder#editor",
+				"folder#owner",
+				"folder#viewer",
+				"user#...",
+			},
+			[]interface{}{
+				map[string]interface{}{"from": "document#viewer", "to": "user#...", "kind": "direct", "operation": "union"},
+				map[string]interface{}{"from": "document#viewer", "to": "document#editor", "kind": "computed", "operation": "union"},
+				map[string]interface{}{"from": "document#viewer", "to": "folder#viewer", "kind": "tuple_to_userset", "via": "parent", "operation": "union"},
+				map[string]interface{}{"from": "document#editor", "to": "user#...", "kind": "direct", "operation": "union"},
+				map[string]interface{}{"from": "document#editor", "to": "document#owner", "kind": "computed", "operation": "union"},
+				map[string]interface{}{"from": "document#owner", "to": "user#...", "kind": "direct"},
+				map[string]interface{}{"from": "folder#viewer", "to": "user#...", "kind": "direct", "operation": "union"},
+				map[string]interface{}{"from": "folder#viewer", "to": "folder#viewer", "kind": "direct", "operation": "union"},
+				map[string]interface{}{"from": "folder#viewer", "to": "folder#editor", "kind": "computed", "operation": "union"},
+				map[string]interface{}{"from": "folder#viewer", "to": "folder#viewer", "kind": "tuple_to_userset", "via": "parent", "operation": "union"},
+				map[string]interface{}{"from": "folder#editor", "to": "user#...", "kind": "direct", "operation": "union"},
+				map[string]interface{}{"from": "folder#editor", "to": "folder#owner", "kind": "computed", "operation": "union"},
+				map[string]interface{}{"from": "folder#owner", "to": "user#...", "kind": "direct"},
+			},
+		},
+		{"document", "invalidrelation", codes.FailedPrecondition, nil, nil},
+		{"invalidnamespace", "viewer", codes.FailedPrecondition, nil, nil},
+		{"document", "", codes.InvalidArgument, nil, nil},
+	}
+
+	require := require.New(t)
+
+	emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	ds, _ := tf.StandardDatastoreWithSchema(emptyDS, require)
+
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, nil)
+	require.NoError(err)
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := tf.NewTestServer()
+	RegisterSchemaExplorerServiceServer(s, NewSchemaExplorerServer(ds, nsm))
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			panic("failed to shutdown cleanly: " + err.Error())
+		}
+	}()
+	defer func() {
+		s.Stop()
+		require.NoError(lis.Close())
+	}()
+
+	conn, err := grpc.Dial("", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(err)
+	defer conn.Close()
+
+	client := NewSchemaExplorerServiceClient(conn)
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.resourceType+"#"+tc.permission, func(t *testing.T) {
+			require := require.New(t)
+
+			req, err := structpb.NewStruct(map[string]interface{}{
+				"resource_type": tc.resourceType,
+				"permission":    tc.permission,
+			})
+			require.NoError(err)
+
+			resp, err := client.ReachabilityGraph(context.Background(), req)
+			if tc.expectedCode != codes.OK {
+				require.Equal(tc.expectedCode, status.Code(err))
+				return
+			}
+			require.NoError(err)
+
+			decoded := resp.AsMap()
+			require.Equal(tc.expectedNodes, decoded["nodes"])
+			require.Equal(tc.expectedEdges, decoded["edges"])
+		})
+	}
+}
+
+func TestLintSchema(t *testing.T) {
+	require := require.New(t)
+
+	emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	ds, _ := tf.StandardDatastoreWithSchema(emptyDS, require)
+
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, nil)
+	require.NoError(err)
+
+	srv := NewSchemaExplorerServer(ds, nsm)
+
+	// Without a schema, the stored schema is linted.
+	resp, err := srv.LintSchema(context.Background(), &structpb.Struct{})
+	require.NoError(err)
+	require.Equal([]interface{}{
+		map[string]interface{}{
+			"rule":       "unused-relation",
+			"definition": "document",
+			"relation":   "lock",
+			"message":    "relation is not used by any permission or allowed as a subject",
+		},
+	}, resp.AsMap()["findings"])
+
+	req, err := structpb.NewStruct(map[string]interface{}{
+		"schema": `definition user {}
+
+		definition document {
+			relation viewer: user
+			permission view = viewer & nothing
+			permission nothing = nothing
+		}`,
+	})
+	require.NoError(err)
+
+	resp, err = srv.LintSchema(context.Background(), req)
+	require.NoError(err)
+	require.Equal([]interface{}{
+		map[string]interface{}{
+			"rule":       "empty-permission",
+			"definition": "document",
+			"relation":   "view",
+			"message":    "permission can never be granted to any subject",
+		},
+		map[string]interface{}{
+			"rule":       "empty-permission",
+			"definition": "document",
+			"relation":   "nothing",
+			"message":    "permission can never be granted to any subject",
+		},
+		map[string]interface{}{
+			"rule":       "unbounded-recursion",
+			"definition": "document",
+			"relation":   "nothing",
+			"message":    "permission references itself without walking a relation: nothing -> nothing",
+		},
+	}, resp.AsMap()["findings"])
+
+	req, err = structpb.NewStruct(map[string]interface{}{"schema": "invalid schema"})
+	require.NoError(err)
+
+	_, err = srv.LintSchema(context.Background(), req)
+	require.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func TestDeadDefinitions(t *testing.T) {
+	require := require.New(t)
+
+	emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	ds, _ := tf.StandardDatastoreWithData(emptyDS, require)
+
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 1*time.Second, nil)
+	require.NoError(err)
+
+	srv := NewSchemaExplorerServer(ds, nsm)
+
+	revision, err := ds.HeadRevision(context.Background())
+	require.NoError(err)
+
+	resp, err := srv.DeadDefinitions(context.Background(), &structpb.Struct{})
+	require.NoError(err)
+
+	decoded := resp.AsMap()
+	require.Equal(revision.String(), decoded["revision"])
+	require.Equal([]interface{}{
+		map[string]interface{}{"definition": "document", "relation": "lock"},
+	}, decoded["empty_relations"])
+	require.ElementsMatch([]interface{}{
+		map[string]interface{}{"definition": "document", "relation": "viewer"},
+		map[string]interface{}{"definition": "document", "relation": "viewer_and_editor_derived"},
+	}, decoded["unreferenced_permissions"])
+}
@@ -2,8 +2,8 @@ package v1
 
 import (
 	"errors"
+	"time"
 
-	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/validator"
 	"github.com/shopspring/decimal"
@@ -11,24 +11,35 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/middleware/consistency"
+	"github.com/authzed/spicedb/internal/middleware/drain"
 	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
 	dispatchv1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+	"github.com/authzed/spicedb/internal/services/serviceerrors"
 	"github.com/authzed/spicedb/internal/services/shared"
 	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
+// DefaultWatchHeartbeatInterval is the default interval at which watches send checkpoints.
+const DefaultWatchHeartbeatInterval = 30 * time.Second
+
 type watchServer struct {
 	v1.UnimplementedWatchServiceServer
 	shared.WithStreamServiceSpecificInterceptor
 
-	ds datastore.Datastore
+	ds                datastore.Datastore
+	heartbeatInterval time.Duration
 }
 
-// NewWatchServer creates an instance of the watch server.
-func NewWatchServer(ds datastore.Datastore) v1.WatchServiceServer {
+// NewWatchServer creates an instance of the watch server. Watches send a checkpoint of the
+// revision through which changes have been sent every heartbeat interval, even if no
+// changes were sent since the last; a zero interval disables the checkpoints.
+func NewWatchServer(ds datastore.Datastore, heartbeatInterval time.Duration) v1.WatchServiceServer {
 	s := &watchServer{
-		ds: ds,
+		ds:                ds,
+		heartbeatInterval: heartbeatInterval,
 		WithStreamServiceSpecificInterceptor: shared.WithStreamServiceSpecificInterceptor{
 			Stream: grpcvalidate.StreamServerInterceptor(),
 		},
@@ -39,15 +50,21 @@ func NewWatchServer(ds datastore.Datastore) v1.WatchServiceServer {
 func (ws *watchServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchServer) error {
 	ctx := stream.Context()
 
-	objectTypesMap := make(map[string]struct{})
+	// The filters are pushed down into the datastore, so that changes of other object
+	// types are never loaded.
+	filters := make([]*v1.RelationshipFilter, 0, len(req.GetOptionalObjectTypes()))
 	for _, objectType := range req.GetOptionalObjectTypes() {
-		objectTypesMap[objectType] = struct{}{}
+		filters = append(filters, &v1.RelationshipFilter{ResourceType: objectType})
 	}
 
 	var afterRevision decimal.Decimal
 	if req.OptionalStartCursor != nil && req.OptionalStartCursor.Token != "" {
-		decodedRevision, err := zedtoken.DecodeRevision(req.OptionalStartCursor)
-		if err != nil {
+		decodedRevision, err := zedtoken.DecodeRevisionForDatastore(req.OptionalStartCursor, datastore.UniqueIDFromContext(ctx))
+		var foreignErr zedtoken.ErrForeignDatastore
+		switch {
+		case errors.As(err, &foreignErr):
+			return serviceerrors.NewForeignZedTokenErr(foreignErr)
+		case err != nil:
 			return status.Errorf(codes.InvalidArgument, "failed to decode start revision: %s", err)
 		}
 
@@ -64,17 +81,47 @@ func (ws *watchServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchS
 		DispatchCount: 1,
 	})
 
-	updates, errchan := ws.ds.Watch(ctx, afterRevision)
+	// The revision through which changes have been sent, which is sent as a final
+	// checkpoint if the server drains, so that the client can resume from it elsewhere.
+	sentThrough := afterRevision
+
+	// Checkpoints let clients tell a stream without changes from a dead one, and resume
+	// from a recent revision after reconnecting.
+	var heartbeats <-chan time.Time
+	if ws.heartbeatInterval > 0 {
+		ticker := time.NewTicker(ws.heartbeatInterval)
+		defer ticker.Stop()
+		heartbeats = ticker.C
+	}
+
+	updates, errchan := ws.ds.Watch(ctx, afterRevision, options.SetFilters(filters))
 	for {
 		select {
+		case <-heartbeats:
+			if err := stream.Send(&v1.WatchResponse{
+				ChangesThrough: consistency.NewZedToken(ctx, sentThrough),
+			}); err != nil {
+				return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
+			}
+		case <-drain.FromContext(ctx):
+			if err := stream.Send(&v1.WatchResponse{
+				ChangesThrough: consistency.NewZedToken(ctx, sentThrough),
+			}); err != nil {
+				return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
+			}
+			return status.Errorf(codes.Unavailable, "server is shutting down; resume the watch from the last revision received")
 		case update, ok := <-updates:
 			if ok {
-				filtered := filterUpdates(objectTypesMap, update.Changes)
-				if len(filtered) > 0 {
-					if err := stream.Send(&v1.WatchResponse{
-						Updates:        filtered,
-						ChangesThrough: zedtoken.NewFromRevision(update.Revision),
-					}); err != nil {
+				sentThrough = update.Revision
+				if len(update.Changes) > 0 {
+					resp := &v1.WatchResponse{
+						Updates:        tuple.UpdatesToRelationshipUpdates(update.Changes),
+						ChangesThrough: consistency.NewZedToken(ctx, update.Revision),
+					}
+					if err := shared.SetTransactionMetadata(resp, update.Metadata); err != nil {
+						return status.Errorf(codes.Internal, "watch error: %s", err)
+					}
+					if err := stream.Send(resp); err != nil {
 						return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
 					}
 				}
@@ -91,22 +138,3 @@ func (ws *watchServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchS
 		}
 	}
 }
-
-func filterUpdates(objectTypes map[string]struct{}, candidates []*v0.RelationTupleUpdate) []*v1.RelationshipUpdate {
-	updates := tuple.UpdatesToRelationshipUpdates(candidates)
-
-	if len(objectTypes) == 0 {
-		return updates
-	}
-
-	var filtered []*v1.RelationshipUpdate
-	for _, update := range updates {
-		objectType := update.GetRelationship().GetResource().GetObjectType()
-
-		if _, ok := objectTypes[objectType]; ok {
-			filtered = append(filtered, update)
-		}
-	}
-
-	return filtered
-}
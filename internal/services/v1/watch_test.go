@@ -23,6 +23,8 @@ import (
 
 	"github.com/authzed/spicedb/internal/datastore"
 	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/middleware/drain"
+	"github.com/authzed/spicedb/internal/services/shared"
 	"github.com/authzed/spicedb/internal/testfixtures"
 	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/authzed/spicedb/pkg/zedtoken"
@@ -113,7 +115,7 @@ func TestWatch(t *testing.T) {
 			ds, revision := testfixtures.StandardDatastoreWithData(rawDS, require)
 			require.True(revision.GreaterThan(decimal.Zero))
 
-			client, stop := newWatchServicer(require, ds)
+			client, stop := newWatchServicer(require, ds, drain.NewDrainer(), 0)
 			defer stop()
 
 			cursor := zedtoken.NewFromRevision(revision)
@@ -182,14 +184,222 @@ func TestWatch(t *testing.T) {
 	}
 }
 
+func TestWatchDrain(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	ds, revision := testfixtures.StandardDatastoreWithData(rawDS, require)
+
+	drainer := drain.NewDrainer()
+	client, stop := newWatchServicer(require, ds, drainer, 0)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &v1.WatchRequest{
+		OptionalObjectTypes: []string{"folder"},
+		OptionalStartCursor: zedtoken.NewFromRevision(revision),
+	})
+	require.NoError(err)
+
+	// The change is filtered out, but the final checkpoint may cover it if it is received
+	// before the server drains.
+	written, err := ds.WriteTuples(context.Background(), nil, []*v1.RelationshipUpdate{
+		update(v1.RelationshipUpdate_OPERATION_CREATE, "document", "document1", "viewer", "user", "user1"),
+	})
+	require.NoError(err)
+
+	drainer.Drain()
+
+	var checkpoint *v1.WatchResponse
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			grpcutil.RequireStatus(t, codes.Unavailable, err)
+			break
+		}
+		checkpoint = resp
+	}
+
+	require.NotNil(checkpoint)
+	require.Empty(checkpoint.Updates)
+
+	checkpointRevision, err := zedtoken.DecodeRevision(checkpoint.ChangesThrough)
+	require.NoError(err)
+	require.True(checkpointRevision.GreaterThanOrEqual(revision))
+	require.True(checkpointRevision.LessThanOrEqual(written))
+}
+
+func TestWatchHeartbeat(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	ds, revision := testfixtures.StandardDatastoreWithData(rawDS, require)
+
+	client, stop := newWatchServicer(require, ds, drain.NewDrainer(), 10*time.Millisecond)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &v1.WatchRequest{
+		OptionalStartCursor: zedtoken.NewFromRevision(revision),
+	})
+	require.NoError(err)
+
+	// Without changes, the checkpoints carry the revision the watch was started from.
+	for i := 0; i < 2; i++ {
+		resp, err := stream.Recv()
+		require.NoError(err)
+		require.Empty(resp.Updates)
+
+		checkpointRevision, err := zedtoken.DecodeRevision(resp.ChangesThrough)
+		require.NoError(err)
+		require.True(checkpointRevision.Equal(revision))
+	}
+
+	written, err := ds.WriteTuples(context.Background(), nil, []*v1.RelationshipUpdate{
+		update(v1.RelationshipUpdate_OPERATION_CREATE, "document", "document1", "viewer", "user", "user1"),
+	})
+	require.NoError(err)
+
+	// Checkpoints sent after a change cover it, so reconnecting from them does not
+	// replay the change.
+	for {
+		resp, err := stream.Recv()
+		require.NoError(err)
+		if len(resp.Updates) > 0 {
+			continue
+		}
+
+		checkpointRevision, err := zedtoken.DecodeRevision(resp.ChangesThrough)
+		require.NoError(err)
+		if checkpointRevision.Equal(written) {
+			break
+		}
+	}
+}
+
+func TestWatchHeartbeatAdvancesWithoutChanges(t *testing.T) {
+	testCases := []struct {
+		name        string
+		objectTypes []string
+	}{
+		{"unfiltered", nil},
+		{"filtered", []string{"user"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+			require.NoError(err)
+
+			startRevision, err := rawDS.HeadRevision(context.Background())
+			require.NoError(err)
+
+			// Only the schema is written after the start of the watch, so there are no
+			// changes of relationships to send.
+			ds, revision := testfixtures.StandardDatastoreWithSchema(rawDS, require)
+			require.True(revision.GreaterThan(startRevision))
+
+			client, stop := newWatchServicer(require, ds, drain.NewDrainer(), 10*time.Millisecond)
+			defer stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			stream, err := client.Watch(ctx, &v1.WatchRequest{
+				OptionalObjectTypes: tc.objectTypes,
+				OptionalStartCursor: zedtoken.NewFromRevision(startRevision),
+			})
+			require.NoError(err)
+
+			for {
+				resp, err := stream.Recv()
+				require.NoError(err)
+				require.Empty(resp.Updates)
+
+				checkpointRevision, err := zedtoken.DecodeRevision(resp.ChangesThrough)
+				require.NoError(err)
+				require.True(checkpointRevision.LessThanOrEqual(revision))
+				if checkpointRevision.Equal(revision) {
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWatchTransactionMetadata(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	ds, revision := testfixtures.StandardDatastoreWithData(rawDS, require)
+
+	client, stop := newWatchServicer(require, ds, drain.NewDrainer(), 0)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &v1.WatchRequest{
+		OptionalStartCursor: zedtoken.NewFromRevision(revision),
+	})
+	require.NoError(err)
+
+	txMetadata := &datastore.TransactionMetadata{
+		Caller:    "sync-job",
+		RequestID: "1234",
+		Labels:    map[string]string{"job": "nightly"},
+	}
+	_, err = ds.WriteTuples(datastore.ContextWithTransactionMetadata(context.Background(), txMetadata), nil, []*v1.RelationshipUpdate{
+		update(v1.RelationshipUpdate_OPERATION_CREATE, "document", "document1", "viewer", "user", "user1"),
+	})
+	require.NoError(err)
+
+	_, err = ds.WriteTuples(context.Background(), nil, []*v1.RelationshipUpdate{
+		update(v1.RelationshipUpdate_OPERATION_CREATE, "document", "document2", "viewer", "user", "user1"),
+	})
+	require.NoError(err)
+
+	resp, err := stream.Recv()
+	require.NoError(err)
+	require.Len(resp.Updates, 1)
+
+	found, err := shared.TransactionMetadataFromMessage(resp)
+	require.NoError(err)
+	require.Equal(txMetadata, found)
+
+	// Changes written without metadata are sent without it.
+	resp, err = stream.Recv()
+	require.NoError(err)
+	require.Len(resp.Updates, 1)
+
+	found, err = shared.TransactionMetadataFromMessage(resp)
+	require.NoError(err)
+	require.Nil(found)
+}
+
 func newWatchServicer(
 	require *require.Assertions,
 	ds datastore.Datastore,
+	drainer *drain.Drainer,
+	heartbeatInterval time.Duration,
 ) (v1.WatchServiceClient, func()) {
 	lis := bufconn.Listen(1024 * 1024)
-	s := testfixtures.NewTestServer()
+	s := testfixtures.NewTestServer(grpc.ChainStreamInterceptor(drain.StreamServerInterceptor(drainer)))
 
-	v1.RegisterWatchServiceServer(s, NewWatchServer(ds))
+	v1.RegisterWatchServiceServer(s, NewWatchServer(ds, heartbeatInterval))
 	go func() {
 		if err := s.Serve(lis); err != nil {
 			panic("failed to shutdown cleanly: " + err.Error())
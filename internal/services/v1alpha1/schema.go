@@ -215,6 +215,8 @@ func rewriteError(ctx context.Context, err error) error {
 		return status.Errorf(codes.InvalidArgument, "%s", err)
 	case errors.As(err, &datastore.ErrReadOnly{}):
 		return serviceerrors.ErrServiceReadOnly
+	case errors.As(err, &datastore.ErrMaintenanceMode{}):
+		return serviceerrors.ErrServiceInMaintenance
 	case errors.As(err, &errPreconditionFailure):
 		return status.Errorf(codes.FailedPrecondition, "%s", err)
 	default:
@@ -7,10 +7,10 @@ import (
 )
 
 // NewTestServer creates a grpc.Server instance that has the service specific
-// interceptor running middleware preinstalled.
-func NewTestServer() *grpc.Server {
-	return grpc.NewServer(
+// interceptor running middleware preinstalled, followed by any additional options.
+func NewTestServer(opts ...grpc.ServerOption) *grpc.Server {
+	return grpc.NewServer(append([]grpc.ServerOption{
 		grpc.UnaryInterceptor(servicespecific.UnaryServerInterceptor),
 		grpc.StreamInterceptor(servicespecific.StreamServerInterceptor),
-	)
+	}, opts...)...)
 }
@@ -76,8 +76,8 @@ func (vd validatingDatastore) HeadRevision(ctx context.Context) (datastore.Revis
 	return vd.delegate.HeadRevision(ctx)
 }
 
-func (vd validatingDatastore) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
-	return vd.delegate.Watch(ctx, afterRevision)
+func (vd validatingDatastore) Watch(ctx context.Context, afterRevision datastore.Revision, opts ...options.WatchOptionsOption) (<-chan *datastore.RevisionChanges, <-chan error) {
+	return vd.delegate.Watch(ctx, afterRevision, opts...)
 }
 
 func (vd validatingDatastore) WriteNamespace(ctx context.Context, newConfig *v0.NamespaceDefinition) (datastore.Revision, error) {
@@ -21,6 +21,12 @@ const (
 	// CtxKey is the key for the grpc request's context.Context which points to
 	// the key to hash for the request. The value it points to must be []byte
 	CtxKey ctxKey = "requestKey"
+
+	// HedgeCtxKey is the key for the grpc request's context.Context which, when it points
+	// to true, marks the request as a hedge of another with the same key. Hedges are sent
+	// to the member following those among which the request is spread, so that they don't
+	// land on the member which may be slow to answer the original.
+	HedgeCtxKey ctxKey = "hedge"
 )
 
 var logger = grpclog.Component("consistenthashring")
@@ -86,6 +92,16 @@ type consistentHashringPicker struct {
 
 func (p *consistentHashringPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 	key := info.Ctx.Value(CtxKey).([]byte)
+	if hedge, _ := info.Ctx.Value(HedgeCtxKey).(bool); hedge {
+		// With too few members to avoid those of the spread, hedges are spread as any
+		// other request is.
+		if members, err := p.hashring.FindN(key, p.spread+1); err == nil {
+			return balancer.PickResult{
+				SubConn: members[p.spread].(subConnMember).SubConn,
+			}, nil
+		}
+	}
+
 	members, err := p.hashring.FindN(key, p.spread)
 	if err != nil {
 		return balancer.PickResult{}, err
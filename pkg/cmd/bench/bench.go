@@ -0,0 +1,109 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jzelinskie/cobrautil"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/authzed/spicedb/pkg/cmd"
+	"github.com/authzed/spicedb/pkg/datastore/benchmark"
+)
+
+func RegisterBenchFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
+	cmdutil.RegisterDatastoreFlags(cmd, dsConfig)
+
+	cmd.Flags().StringSlice("workloads", nil, "workloads to run, of fan-out-read, deep-check, bulk-write and reverse-lookup (default all)")
+	cmd.Flags().Uint32("operations", 1000, "number of operations run by each workload")
+	cmd.Flags().Uint32("concurrency", 1, "number of operations of a workload run at once")
+	cmd.Flags().Uint32("fan-out", uint32(benchmark.DefaultConfig.FanOut), "number of relationships read by each fan-out read and reverse lookup")
+	cmd.Flags().Uint32("depth", uint32(benchmark.DefaultConfig.Depth), "number of nested folders traversed by each deep check")
+	cmd.Flags().Uint32("batch-size", uint32(benchmark.DefaultConfig.BatchSize), "number of relationships written by each bulk write")
+}
+
+func NewCommand(programName string, dsConfig *cmdutil.DatastoreConfig) *cobra.Command {
+	return &cobra.Command{
+		Use:   "bench",
+		Short: "benchmark the datastore with standardized workloads",
+		Long: "Runs standardized workloads (wide fan-out reads, deep nesting checks, bulk writes and reverse lookups) against the configured datastore, and prints their latencies and throughputs. " +
+			"The workloads write their own schema and relationships, so they must be run against a dedicated datastore.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return benchRun(cmd, dsConfig)
+		},
+		Args: cobra.ExactArgs(0),
+	}
+}
+
+func benchRun(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) error {
+	config := benchmark.Config{
+		FanOut:    int(cobrautil.MustGetUint32(cmd, "fan-out")),
+		Depth:     int(cobrautil.MustGetUint32(cmd, "depth")),
+		BatchSize: int(cobrautil.MustGetUint32(cmd, "batch-size")),
+	}
+	if config.Depth < 1 {
+		return fmt.Errorf("depth must be at least 1")
+	}
+
+	workloads, err := selectWorkloads(benchmark.Workloads(config), cobrautil.MustGetStringSlice(cmd, "workloads"))
+	if err != nil {
+		return err
+	}
+
+	ds, err := cmdutil.NewDatastore(dsConfig.ToOption())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init datastore")
+	}
+	defer ds.Close()
+
+	operations := int(cobrautil.MustGetUint32(cmd, "operations"))
+	concurrency := int(cobrautil.MustGetUint32(cmd, "concurrency"))
+
+	results := make([]benchmark.Result, 0, len(workloads))
+	for _, workload := range workloads {
+		log.Info().Str("workload", workload.Name).Int("operations", operations).Msg("running workload")
+		result, err := benchmark.Run(context.Background(), ds, workload, operations, concurrency)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	printResults(cmd.OutOrStdout(), results)
+	return nil
+}
+
+func selectWorkloads(all []benchmark.Workload, names []string) ([]benchmark.Workload, error) {
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	byName := make(map[string]benchmark.Workload, len(all))
+	for _, workload := range all {
+		byName[workload.Name] = workload
+	}
+
+	selected := make([]benchmark.Workload, 0, len(names))
+	for _, name := range names {
+		workload, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown workload: %s", name)
+		}
+		selected = append(selected, workload)
+	}
+	return selected, nil
+}
+
+func printResults(out io.Writer, results []benchmark.Result) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKLOAD\tOPERATIONS\tCONCURRENCY\tOPS/S\tP50\tP95\tP99\tMAX")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\n", r.Workload, r.Operations, r.Concurrency, r.Throughput(), r.P50, r.P95, r.P99, r.Max)
+	}
+	w.Flush()
+}
@@ -0,0 +1,263 @@
+package bootstrap
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jackc/pgx/v4"
+	"github.com/jzelinskie/cobrautil"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	crdbmigrations "github.com/authzed/spicedb/internal/datastore/crdb/migrations"
+	"github.com/authzed/spicedb/internal/datastore/postgres/migrations"
+	"github.com/authzed/spicedb/internal/namespace"
+	cmdutil "github.com/authzed/spicedb/pkg/cmd"
+	"github.com/authzed/spicedb/pkg/migrate"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// maintenanceDatabases are the databases, present on every server of an engine, to which a
+// connection is made in order to create the database of SpiceDB.
+var maintenanceDatabases = map[string]string{
+	"postgres":    "postgres",
+	"cockroachdb": "defaultdb",
+}
+
+func RegisterInitFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
+	cmdutil.RegisterDatastoreFlags(cmd, dsConfig)
+	cmd.Flags().Bool("create-database", true, "create the database named in the connection string if it does not exist")
+	cmd.Flags().String("schema", "", "path to a file containing the initial schema")
+	cmd.Flags().String("relationships", "", "path to a file containing relationships to seed, one per line (e.g. \"document:readme#viewer@user:alice\")")
+
+	if err := cmd.MarkFlagRequired("schema"); err != nil {
+		panic("failed to mark flag as required: " + err.Error())
+	}
+}
+
+func NewInitCommand(programName string, dsConfig *cmdutil.DatastoreConfig) *cobra.Command {
+	return &cobra.Command{
+		Use:   "init",
+		Short: "bootstrap a new environment",
+		Long: "Prepares a datastore for a new environment: creates its database if absent, migrates it to the latest revision, " +
+			"writes the initial schema and seeds relationships. A datastore which already has a schema is only migrated.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return initRun(cmd, dsConfig)
+		},
+		Args: cobra.ExactArgs(0),
+	}
+}
+
+func initRun(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) error {
+	ctx := context.Background()
+
+	maintenanceDatabase, ok := maintenanceDatabases[dsConfig.Engine]
+	if !ok {
+		return fmt.Errorf("cannot initialize datastore engine type: %s", dsConfig.Engine)
+	}
+
+	// Read the inputs before touching the datastore, so that mistakes in them do not
+	// leave it half initialized.
+	schema, err := os.ReadFile(cobrautil.MustGetString(cmd, "schema"))
+	if err != nil {
+		return fmt.Errorf("unable to read schema: %w", err)
+	}
+	emptyDefaultPrefix := ""
+	nsdefs, err := compiler.Compile([]compiler.InputSchema{{
+		Source:       input.Source("schema"),
+		SchemaString: string(schema),
+	}}, &emptyDefaultPrefix)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	for _, nsdef := range nsdefs {
+		ts, err := namespace.BuildNamespaceTypeSystemForDefs(nsdef, nsdefs)
+		if err != nil {
+			return fmt.Errorf("invalid schema: %w", err)
+		}
+		if err := ts.Validate(ctx); err != nil {
+			return fmt.Errorf("invalid schema: %w", err)
+		}
+	}
+
+	var relationships []*v1.Relationship
+	if path := cobrautil.MustGetString(cmd, "relationships"); path != "" {
+		relationships, err = readRelationships(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cobrautil.MustGetBool(cmd, "create-database") {
+		if err := createDatabase(ctx, dsConfig.URI, maintenanceDatabase); err != nil {
+			return err
+		}
+	}
+
+	migrationRevision, err := migrateToHead(dsConfig.Engine, dsConfig.URI)
+	if err != nil {
+		return err
+	}
+
+	// Initialization is short lived, so the datastore must not collect garbage in the background.
+	dsConfig.GCInterval = 0
+	ds, err := cmdutil.NewDatastore(dsConfig.ToOption())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init datastore")
+	}
+	defer ds.Close()
+
+	revision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ds.ListNamespaces(ctx, revision)
+	if err != nil {
+		return err
+	}
+
+	if len(existing) > 0 {
+		log.Info().Int("definitions", len(existing)).Msg("datastore already has a schema; skipping schema and relationships")
+	} else {
+		for _, nsdef := range nsdefs {
+			if revision, err = ds.WriteNamespace(ctx, nsdef); err != nil {
+				return fmt.Errorf("unable to write schema: %w", err)
+			}
+		}
+		log.Info().Int("definitions", len(nsdefs)).Msg("wrote schema")
+
+		if len(relationships) > 0 {
+			if revision, err = datastore.BulkLoad(ctx, ds, relationships); err != nil {
+				return fmt.Errorf("unable to seed relationships: %w", err)
+			}
+			log.Info().Int("relationships", len(relationships)).Msg("seeded relationships")
+		}
+	}
+
+	fmt.Printf("datastore-engine:   %s\n", dsConfig.Engine)
+	fmt.Printf("datastore-conn-uri: %s\n", redactedURI(dsConfig.URI))
+	fmt.Printf("migration:          %s\n", migrationRevision)
+	fmt.Printf("revision:           %s\n", revision)
+	return nil
+}
+
+// readRelationships reads the distinct relationships in the file. Blank lines and lines
+// starting with `//` are skipped.
+func readRelationships(path string) ([]*v1.Relationship, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read relationships: %w", err)
+	}
+	defer file.Close()
+
+	var relationships []*v1.Relationship
+	seen := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		rel := tuple.ParseRel(line)
+		if rel == nil {
+			return nil, fmt.Errorf("invalid relationship on line %d of %s: %s", lineNumber, path, line)
+		}
+
+		key := tuple.RelString(rel)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		relationships = append(relationships, rel)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read relationships: %w", err)
+	}
+
+	return relationships, nil
+}
+
+// createDatabase creates the database named in the connection string, connecting to the
+// maintenance database of the server to do so, unless it already exists.
+func createDatabase(ctx context.Context, uri, maintenanceDatabase string) error {
+	config, err := pgx.ParseConfig(uri)
+	if err != nil {
+		return fmt.Errorf("invalid connection string: %w", err)
+	}
+	name := config.Database
+	if name == "" || name == maintenanceDatabase {
+		return nil
+	}
+
+	config.Database = maintenanceDatabase
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return fmt.Errorf("unable to connect to create database: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&exists); err != nil {
+		return fmt.Errorf("unable to determine whether database exists: %w", err)
+	}
+	if exists {
+		log.Info().Str("database", name).Msg("database already exists")
+		return nil
+	}
+
+	if _, err := conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{name}.Sanitize()); err != nil {
+		return fmt.Errorf("unable to create database: %w", err)
+	}
+	log.Info().Str("database", name).Msg("created database")
+	return nil
+}
+
+// migrateToHead migrates the datastore to the latest revision, returning that revision.
+func migrateToHead(engine, uri string) (string, error) {
+	switch engine {
+	case "cockroachdb":
+		driver, err := crdbmigrations.NewCRDBDriver(uri)
+		if err != nil {
+			return "", fmt.Errorf("unable to create migration driver: %w", err)
+		}
+		defer driver.Dispose()
+
+		if err := crdbmigrations.CRDBMigrations.Run(driver, migrate.Head, migrate.LiveRun); err != nil {
+			return "", fmt.Errorf("unable to complete migrations: %w", err)
+		}
+		return crdbmigrations.CRDBMigrations.HeadRevision()
+	case "postgres":
+		driver, err := migrations.NewAlembicPostgresDriver(uri)
+		if err != nil {
+			return "", fmt.Errorf("unable to create migration driver: %w", err)
+		}
+		defer driver.Dispose()
+
+		if err := migrations.DatabaseMigrations.Run(driver, migrate.Head, migrate.LiveRun); err != nil {
+			return "", fmt.Errorf("unable to complete migrations: %w", err)
+		}
+		return migrations.DatabaseMigrations.HeadRevision()
+	default:
+		return "", fmt.Errorf("cannot migrate datastore engine type: %s", engine)
+	}
+}
+
+// redactedURI returns the connection string with any password replaced.
+func redactedURI(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "(unparseable)"
+	}
+	return parsed.Redacted()
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jzelinskie/cobrautil"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileFlag is the flag naming the YAML file from which the flags of a command are
+// loaded.
+const ConfigFileFlag = "config"
+
+// RegisterConfigFileFlag registers the flag naming the YAML file from which the flags of
+// the command are loaded.
+func RegisterConfigFileFlag(cmd *cobra.Command) {
+	cmd.Flags().String(ConfigFileFlag, "", "YAML file from which flags are loaded, keyed by flag name or nested by its dash-separated parts; ${VAR} and ${VAR:-default} are replaced by environment variables, and flags given on the command line or through the environment take precedence")
+}
+
+// ConfigFilePreRunE loads the flags of the command from the file named by its config
+// flag, if it has one and it is set.
+func ConfigFilePreRunE(cmd *cobra.Command, _ []string) error {
+	if cmd.Flags().Lookup(ConfigFileFlag) == nil {
+		return nil
+	}
+
+	path := cobrautil.MustGetStringExpanded(cmd, ConfigFileFlag)
+	if path == "" {
+		return nil
+	}
+
+	values, err := LoadConfigFile(cmd, path)
+	if err != nil {
+		return err
+	}
+	return ApplyConfig(cmd, values)
+}
+
+// LoadConfigFile reads the values of the flags of the command from the YAML file at the
+// path.
+func LoadConfigFile(cmd *cobra.Command, path string) (map[string][]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	values, err := ParseConfig(cmd, contents)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// ParseConfig parses the values of the flags of the command from YAML. Keys are flag names, or parts of them
+// under which the rest are nested, so that `grpc: {preshared-key: ...}` sets
+// --grpc-preshared-key. Lists set repeated flags and maps set key-value flags.
+func ParseConfig(cmd *cobra.Command, contents []byte) (map[string][]string, error) {
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]string)
+	if err := parseConfigNode(cmd, "", document, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func parseConfigNode(cmd *cobra.Command, prefix string, node map[string]interface{}, values map[string][]string) error {
+	for _, key := range sortedKeys(node) {
+		name := key
+		if prefix != "" {
+			name = prefix + "-" + key
+		}
+		value := node[key]
+
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || name == ConfigFileFlag {
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unknown flag `%s`", name)
+			}
+			if err := parseConfigNode(cmd, name, nested, values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, ok := values[name]; ok {
+			return fmt.Errorf("flag `%s` is set more than once", name)
+		}
+
+		flagValues, err := configValues(flag.Value.Type(), value)
+		if err != nil {
+			return fmt.Errorf("invalid value for flag `%s`: %w", name, err)
+		}
+		values[name] = flagValues
+	}
+	return nil
+}
+
+func configValues(flagType string, value interface{}) ([]string, error) {
+	switch typed := value.(type) {
+	case []interface{}:
+		if !strings.HasSuffix(flagType, "Slice") && !strings.HasSuffix(flagType, "Array") {
+			return nil, fmt.Errorf("a list was given for a flag of type %s", flagType)
+		}
+
+		values := make([]string, 0, len(typed))
+		for _, item := range typed {
+			str, err := configScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, str)
+		}
+		return values, nil
+
+	case map[string]interface{}:
+		if !strings.HasPrefix(flagType, "stringTo") {
+			return nil, fmt.Errorf("a map was given for a flag of type %s", flagType)
+		}
+
+		values := make([]string, 0, len(typed))
+		for _, key := range sortedKeys(typed) {
+			str, err := configScalar(typed[key])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, key+"="+str)
+		}
+		return values, nil
+
+	default:
+		str, err := configScalar(value)
+		if err != nil {
+			return nil, err
+		}
+		return []string{str}, nil
+	}
+}
+
+func configScalar(value interface{}) (string, error) {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return "", fmt.Errorf("expected a scalar value")
+	case nil:
+		return "", nil
+	}
+	return expandConfigEnv(fmt.Sprint(value))
+}
+
+var configEnvPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// expandConfigEnv replaces ${VAR} with the value of the environment variable, and
+// ${VAR:-default} with the default if the variable is unset or empty. Variables which are
+// unset without a default are an error, so that a missing secret is not silently replaced
+// by an empty value.
+func expandConfigEnv(value string) (string, error) {
+	var missing []string
+	expanded := configEnvPattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := match[2 : len(match)-1]
+		fallback, hasFallback := "", false
+		if i := strings.Index(name, ":-"); i >= 0 {
+			name, fallback, hasFallback = name[:i], name[i+2:], true
+		}
+
+		if envValue, ok := os.LookupEnv(name); ok && (envValue != "" || !hasFallback) {
+			return envValue
+		}
+		if hasFallback {
+			return fallback
+		}
+		missing = append(missing, name)
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variables are not set: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ApplyConfig sets the flags of the command to the values loaded from a config file, except for those
+// which were already set on the command line or through the environment.
+func ApplyConfig(cmd *cobra.Command, values map[string][]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if cmd.Flags().Changed(name) {
+			continue
+		}
+		for _, value := range values[name] {
+			if err := cmd.Flags().Set(name, value); err != nil {
+				return fmt.Errorf("invalid value for flag `%s` in config file: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jzelinskie/cobrautil"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func newConfigTestCommand() *cobra.Command {
+	cmd := &cobra.Command{Use: "test"}
+	RegisterConfigFileFlag(cmd)
+	cmd.Flags().String("grpc-preshared-key", "", "")
+	cmd.Flags().Duration("grpc-shutdown-grace-period", 0, "")
+	cmd.Flags().Bool("datastore-readonly", false, "")
+	cmd.Flags().StringSlice("datastore-bootstrap-files", []string{"default.yaml"}, "")
+	cmd.Flags().StringToString("grpc-named-preshared-keys", map[string]string{}, "")
+	cmd.Flags().Uint32("dispatch-max-depth", 50, "")
+	return cmd
+}
+
+func TestParseConfig(t *testing.T) {
+	require.NoError(t, os.Setenv("SPICEDB_CONFIG_TEST_KEY", "fromenv"))
+	defer os.Unsetenv("SPICEDB_CONFIG_TEST_KEY")
+
+	testCases := []struct {
+		name          string
+		config        string
+		expected      map[string][]string
+		expectedError string
+	}{
+		{
+			"flat",
+			"grpc-preshared-key: somekey\ndispatch-max-depth: 25\ndatastore-readonly: true\n",
+			map[string][]string{
+				"grpc-preshared-key": {"somekey"},
+				"dispatch-max-depth": {"25"},
+				"datastore-readonly": {"true"},
+			},
+			"",
+		},
+		{
+			"nested",
+			"grpc:\n  preshared-key: somekey\n  shutdown:\n    grace-period: 10s\n",
+			map[string][]string{
+				"grpc-preshared-key":         {"somekey"},
+				"grpc-shutdown-grace-period": {"10s"},
+			},
+			"",
+		},
+		{
+			"list and map",
+			"datastore-bootstrap-files: [a.yaml, b.yaml]\ngrpc-named-preshared-keys:\n  billing: key1\n  audit: key2\n",
+			map[string][]string{
+				"datastore-bootstrap-files": {"a.yaml", "b.yaml"},
+				"grpc-named-preshared-keys": {"audit=key2", "billing=key1"},
+			},
+			"",
+		},
+		{
+			"environment interpolation",
+			"grpc-preshared-key: ${SPICEDB_CONFIG_TEST_KEY}\ngrpc-named-preshared-keys:\n  billing: ${SPICEDB_CONFIG_TEST_MISSING:-fallback}\n",
+			map[string][]string{
+				"grpc-preshared-key":        {"fromenv"},
+				"grpc-named-preshared-keys": {"billing=fallback"},
+			},
+			"",
+		},
+		{
+			"missing environment variable",
+			"grpc-preshared-key: ${SPICEDB_CONFIG_TEST_MISSING}\n",
+			nil,
+			"environment variables are not set: SPICEDB_CONFIG_TEST_MISSING",
+		},
+		{
+			"unknown flag",
+			"grpc:\n  unknown: value\n",
+			nil,
+			"unknown flag `grpc-unknown`",
+		},
+		{
+			"set twice",
+			"grpc-preshared-key: a\ngrpc:\n  preshared-key: b\n",
+			nil,
+			"flag `grpc-preshared-key` is set more than once",
+		},
+		{
+			"list for a scalar flag",
+			"grpc-preshared-key: [a, b]\n",
+			nil,
+			"a list was given for a flag of type string",
+		},
+		{
+			"config file in config file",
+			"config: other.yaml\n",
+			nil,
+			"unknown flag `config`",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			values, err := ParseConfig(newConfigTestCommand(), []byte(tc.config))
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, values)
+		})
+	}
+}
+
+func TestConfigFilePreRunE(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(os.WriteFile(path, []byte(`
+grpc:
+  preshared-key: fromfile
+  shutdown-grace-period: 5s
+datastore-bootstrap-files:
+  - a.yaml
+  - b.yaml
+dispatch-max-depth: 10
+`), 0o600))
+
+	cmd := newConfigTestCommand()
+	require.NoError(cmd.ParseFlags([]string{"--config", path, "--dispatch-max-depth", "20"}))
+	require.NoError(ConfigFilePreRunE(cmd, nil))
+
+	require.Equal("fromfile", cobrautil.MustGetString(cmd, "grpc-preshared-key"))
+	require.Equal(5*time.Second, cobrautil.MustGetDuration(cmd, "grpc-shutdown-grace-period"))
+	require.Equal([]string{"a.yaml", "b.yaml"}, cobrautil.MustGetStringSlice(cmd, "datastore-bootstrap-files"))
+
+	// Flags given on the command line take precedence.
+	require.Equal(uint32(20), cobrautil.MustGetUint32(cmd, "dispatch-max-depth"))
+
+	// Invalid values are reported once applied.
+	require.NoError(os.WriteFile(path, []byte("dispatch-max-depth: many\n"), 0o600))
+	cmd = newConfigTestCommand()
+	require.NoError(cmd.ParseFlags([]string{"--config", path}))
+	require.Error(ConfigFilePreRunE(cmd, nil))
+}
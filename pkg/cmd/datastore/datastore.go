@@ -0,0 +1,356 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jzelinskie/cobrautil"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/common"
+	cmdutil "github.com/authzed/spicedb/pkg/cmd"
+	"github.com/authzed/spicedb/pkg/graphexport"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func NewCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "datastore",
+		Short: "operate on the datastore",
+	}
+}
+
+func RegisterAnalyzeFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
+	cmdutil.RegisterDatastoreFlags(cmd, dsConfig)
+	cmd.Flags().StringSlice("metrics-source", []string{"http://localhost:9090/metrics"}, "metrics endpoints or files, in the Prometheus text format, from which to read observed query filter shapes; samples from all sources are summed")
+}
+
+func NewAnalyzeCommand(programName string, dsConfig *cmdutil.DatastoreConfig) *cobra.Command {
+	return &cobra.Command{
+		Use:     "analyze",
+		Short:   "recommend missing datastore indexes",
+		Long:    "Compares the filter shapes of tuple queries observed by running instances against the indexes in the datastore and recommends missing indexes, printing the DDL to create them for review.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return analyzeRun(cmd, dsConfig)
+		},
+		Args: cobra.ExactArgs(0),
+	}
+}
+
+func analyzeRun(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) error {
+	var shapes []common.ObservedFilterShape
+	for _, source := range cobrautil.MustGetStringSlice(cmd, "metrics-source") {
+		sourceShapes, err := readFilterShapes(source)
+		if err != nil {
+			return fmt.Errorf("unable to read metrics from %s: %w", source, err)
+		}
+		shapes = append(shapes, sourceShapes...)
+	}
+
+	// Analysis is read-only, so the datastore must not collect garbage in the background.
+	dsConfig.GCInterval = 0
+	ds, err := cmdutil.NewDatastore(dsConfig.ToOption())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init datastore")
+	}
+	defer ds.Close()
+
+	var inspector datastore.IndexInspector
+	if !datastore.As(ds, &inspector) {
+		return fmt.Errorf("datastore engine %s does not support index analysis", dsConfig.Engine)
+	}
+
+	indexes, err := inspector.TupleIndexes(context.Background())
+	if err != nil {
+		return err
+	}
+
+	recommendations := common.RecommendIndexes(shapes, indexes)
+	if len(recommendations) == 0 {
+		fmt.Printf("-- all %d observed filter shapes are served by existing indexes\n", len(shapes))
+		return nil
+	}
+
+	for _, recommendation := range recommendations {
+		columns := strings.Join(recommendation.Columns, ", ")
+		if recommendation.BestExistingIndex == "" {
+			fmt.Printf("-- %.0f observed queries filter on (%s); no existing index serves them\n", recommendation.Queries, columns)
+		} else {
+			fmt.Printf("-- %.0f observed queries filter on (%s); the best existing index, %s, serves %d of %d columns\n",
+				recommendation.Queries,
+				columns,
+				recommendation.BestExistingIndex,
+				recommendation.ServedColumns,
+				len(recommendation.Columns),
+			)
+		}
+		fmt.Println(inspector.CreateTupleIndexDDL(recommendation.Name, recommendation.Columns))
+	}
+
+	return nil
+}
+
+func readFilterShapes(source string) ([]common.ObservedFilterShape, error) {
+	var reader io.ReadCloser
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+		reader = resp.Body
+	} else {
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		reader = file
+	}
+	defer reader.Close()
+
+	return common.ParseObservedFilterShapes(reader)
+}
+
+func RegisterDedupeFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
+	cmdutil.RegisterDatastoreFlags(cmd, dsConfig)
+	cmd.Flags().Bool("repair", false, "collapse each set of duplicate rows into a single row within a transaction, instead of only reporting them")
+}
+
+func NewDedupeCommand(programName string, dsConfig *cmdutil.DatastoreConfig) *cobra.Command {
+	return &cobra.Command{
+		Use:     "dedupe",
+		Short:   "audit and repair duplicate relationship rows",
+		Long:    "Scans the datastore for relationships which are stored in more than one row with overlapping liveness and reports them. With --repair, each set of duplicate rows is collapsed into a single row within a transaction.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return dedupeRun(cmd, dsConfig)
+		},
+		Args: cobra.ExactArgs(0),
+	}
+}
+
+func dedupeRun(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) error {
+	// Rows must not be removed by garbage collection while they are being audited.
+	dsConfig.GCInterval = 0
+	ds, err := cmdutil.NewDatastore(dsConfig.ToOption())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init datastore")
+	}
+	defer ds.Close()
+
+	var deduplicator datastore.TupleDeduplicator
+	if !datastore.As(ds, &deduplicator) {
+		return fmt.Errorf("datastore engine %s cannot store duplicate relationships", dsConfig.Engine)
+	}
+
+	repair := cobrautil.MustGetBool(cmd, "repair")
+
+	var duplicates []datastore.DuplicateTuple
+	if repair {
+		duplicates, err = deduplicator.RepairDuplicateTuples(context.Background())
+	} else {
+		duplicates, err = deduplicator.FindDuplicateTuples(context.Background())
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, duplicate := range duplicates {
+		if duplicate.Tenant != datastore.DefaultTenant {
+			fmt.Printf("%s (tenant %s) stored in %d overlapping rows\n", tuple.String(duplicate.Tuple), duplicate.Tenant, duplicate.Rows)
+			continue
+		}
+		fmt.Printf("%s stored in %d overlapping rows\n", tuple.String(duplicate.Tuple), duplicate.Rows)
+	}
+
+	switch {
+	case len(duplicates) == 0:
+		fmt.Println("no duplicate relationships found")
+	case repair:
+		fmt.Printf("repaired %d duplicate relationships\n", len(duplicates))
+	default:
+		fmt.Printf("found %d duplicate relationships; rerun with --repair to collapse them\n", len(duplicates))
+	}
+
+	return nil
+}
+
+func RegisterGraphFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
+	cmdutil.RegisterDatastoreFlags(cmd, dsConfig)
+	cmd.Flags().String("object", "", `object, as "type:id", around which to export relationships; empty exports only the type graph of the schema`)
+	cmd.Flags().Uint32("depth", 2, "number of hops from the object within which relationships are exported")
+	cmd.Flags().Uint64("max-relationships", 1000, "maximum number of relationships to load; the exported graph is marked as truncated when reached")
+	cmd.Flags().String("format", string(graphexport.FormatDOT), `format of the exported graph ("dot", "graphml")`)
+	cmd.Flags().String("output", "", "file to which the graph is written; empty writes to stdout")
+}
+
+func NewGraphCommand(programName string, dsConfig *cmdutil.DatastoreConfig) *cobra.Command {
+	return &cobra.Command{
+		Use:     "graph",
+		Short:   "export the schema or the relationships around an object as a graph",
+		Long:    "Exports the type graph of the schema stored in the datastore or, with --object, the relationships within a bounded number of hops of an object, for rendering by graph visualization tools.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return graphRun(cmd, dsConfig)
+		},
+		Args: cobra.ExactArgs(0),
+	}
+}
+
+func graphRun(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) error {
+	format, err := graphexport.ParseFormat(cobrautil.MustGetString(cmd, "format"))
+	if err != nil {
+		return err
+	}
+
+	var objectType, objectID string
+	if object := cobrautil.MustGetString(cmd, "object"); object != "" {
+		parts := strings.SplitN(object, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid object `%s`: must be of the form type:id", object)
+		}
+		objectType, objectID = parts[0], parts[1]
+	}
+
+	// Exporting is read-only, so the datastore must not collect garbage in the background.
+	dsConfig.GCInterval = 0
+	ds, err := cmdutil.NewDatastore(dsConfig.ToOption())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init datastore")
+	}
+	defer ds.Close()
+
+	ctx := context.Background()
+	revision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	var g *graphexport.Graph
+	if objectType == "" {
+		defs, err := ds.ListNamespaces(ctx, revision)
+		if err != nil {
+			return err
+		}
+		g = graphexport.SchemaGraph(defs)
+	} else {
+		g, err = graphexport.Neighborhood(
+			ctx,
+			ds,
+			revision,
+			objectType,
+			objectID,
+			cobrautil.MustGetUint32(cmd, "depth"),
+			cobrautil.MustGetUint64(cmd, "max-relationships"),
+		)
+		if err != nil {
+			return err
+		}
+		if g.Truncated {
+			log.Warn().Msg("relationship limit reached; the exported graph is incomplete")
+		}
+	}
+
+	rendered, err := graphexport.Render(g, format)
+	if err != nil {
+		return err
+	}
+
+	return cmdutil.WriteOutput(cobrautil.MustGetString(cmd, "output"), rendered)
+}
+
+func RegisterAuditFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
+	cmdutil.RegisterDatastoreFlags(cmd, dsConfig)
+	cmd.Flags().String("resource-type", "", "type of the resources whose relationships are audited")
+	cmd.Flags().String("resource-id", "", "ID of the resource whose relationships are audited; empty audits all resources of the type")
+	cmd.Flags().String("relation", "", "relation to audit; empty audits all relations")
+	cmd.Flags().String("subject", "", `subject, as "type" or "type:id", whose relationships are audited; empty audits all subjects`)
+	cmd.Flags().String("tenant", datastore.DefaultTenant, "tenant whose relationships are audited, for datastores which isolate tenants")
+	cmd.Flags().Uint64("limit", 1000, "maximum number of relationships to report")
+	if err := cmd.MarkFlagRequired("resource-type"); err != nil {
+		panic("failed to mark flag as required: " + err.Error())
+	}
+}
+
+func NewAuditCommand(programName string, dsConfig *cmdutil.DatastoreConfig) *cobra.Command {
+	return &cobra.Command{
+		Use:     "audit",
+		Short:   "report who wrote relationships and when",
+		Long:    "Reports the live relationships matching a filter, oldest first, with the time at which each was written and the caller, request ID, reason and labels recorded with the write.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return auditRun(cmd, dsConfig)
+		},
+		Args: cobra.ExactArgs(0),
+	}
+}
+
+func auditRun(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) error {
+	filter := &v1.RelationshipFilter{
+		ResourceType:       cobrautil.MustGetString(cmd, "resource-type"),
+		OptionalResourceId: cobrautil.MustGetString(cmd, "resource-id"),
+		OptionalRelation:   cobrautil.MustGetString(cmd, "relation"),
+	}
+	if subject := cobrautil.MustGetString(cmd, "subject"); subject != "" {
+		parts := strings.SplitN(subject, ":", 2)
+		filter.OptionalSubjectFilter = &v1.SubjectFilter{SubjectType: parts[0]}
+		if len(parts) == 2 {
+			filter.OptionalSubjectFilter.OptionalSubjectId = parts[1]
+		}
+	}
+
+	// Auditing is read-only, so the datastore must not collect garbage in the background.
+	dsConfig.GCInterval = 0
+	ds, err := cmdutil.NewDatastore(dsConfig.ToOption())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init datastore")
+	}
+	defer ds.Close()
+
+	var auditor datastore.TupleAuditor
+	if !datastore.As(ds, &auditor) {
+		return fmt.Errorf("datastore engine %s does not record write transaction metadata", dsConfig.Engine)
+	}
+
+	ctx := datastore.ContextWithTenant(context.Background(), cobrautil.MustGetString(cmd, "tenant"))
+	revision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	audited, err := auditor.AuditTuples(ctx, filter, revision, cobrautil.MustGetUint64(cmd, "limit"))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range audited {
+		metadata := "{}"
+		if entry.Metadata != nil {
+			encoded, err := json.Marshal(entry.Metadata)
+			if err != nil {
+				return err
+			}
+			metadata = string(encoded)
+		}
+		fmt.Printf("%s\t%s\t%s\n", entry.WrittenAt.UTC().Format(time.RFC3339Nano), tuple.String(entry.Tuple), metadata)
+	}
+
+	if len(audited) == 0 {
+		fmt.Println("no matching relationships found")
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+type signalGCDatastore struct {
+	datastore.Datastore
+	collected chan struct{}
+}
+
+func (ds *signalGCDatastore) CollectGarbage(ctx context.Context) (int64, int64, error) {
+	ds.collected <- struct{}{}
+	return 0, 0, nil
+}
+
+func TestGarbageCollectOnSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := &signalGCDatastore{collected: make(chan struct{}, 1)}
+	GarbageCollectOnSignal(ctx, ds)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	select {
+	case <-ds.collected:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "garbage collection was not triggered by the signal")
+	}
+}
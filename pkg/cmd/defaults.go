@@ -7,6 +7,7 @@ import (
 	"net/http/pprof"
 	"os"
 	"os/signal"
+	"runtime"
 	"syscall"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
 )
 
 // ServeExample creates an example usage string with the provided program name.
@@ -33,29 +35,58 @@ func ServeExample(programName string) string {
 	)
 }
 
-// DefaultPreRunE sets up viper, zerolog, and OpenTelemetry flag handling for a
-// command.
+// DefaultPreRunE sets up viper, config file, zerolog, and OpenTelemetry flag handling for
+// a command.
 func DefaultPreRunE(programName string) cobrautil.CobraRunFunc {
 	return cobrautil.CommandStack(
 		cobrautil.SyncViperPreRunE(programName),
+		ConfigFilePreRunE,
 		cobrautil.ZeroLogPreRunE("log", zerolog.InfoLevel),
-		cobrautil.OpenTelemetryPreRunE("otel", zerolog.InfoLevel),
+		TracingPreRunE,
 	)
 }
 
 // MetricsHandler sets up an HTTP server that handles serving Prometheus
 // metrics and pprof endpoints.
 func MetricsHandler() http.Handler {
+	return metricsHandler(true)
+}
+
+func metricsHandler(pprofEnabled bool) http.Handler {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
-	mux.HandleFunc("/debug/pprof/", pprof.Index)
-	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	if pprofEnabled {
+		// The index also serves the named profiles, such as /debug/pprof/heap,
+		// /debug/pprof/goroutine, /debug/pprof/mutex and /debug/pprof/block.
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
 	return mux
 }
 
+// RegisterProfilingFlags adds the flags controlling the pprof endpoints served on the
+// metrics listener.
+func RegisterProfilingFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("metrics-pprof-enabled", true, "serve the pprof endpoints under /debug/pprof/ on the metrics listener")
+	cmd.Flags().Uint32("metrics-pprof-mutex-profile-fraction", 0, "report 1 in every n contended mutex events in the mutex profile (0 leaves the profile empty)")
+	cmd.Flags().Uint32("metrics-pprof-block-profile-rate", 0, "sample one blocking event per n nanoseconds spent blocked in the block profile (0 leaves the profile empty)")
+}
+
+// MetricsHandlerFromFlags sets up the metrics handler as configured by the flags registered
+// by RegisterProfilingFlags, enabling the sampling of the mutex and block profiles of the
+// process if they are served and requested.
+func MetricsHandlerFromFlags(cmd *cobra.Command) http.Handler {
+	pprofEnabled := cobrautil.MustGetBool(cmd, "metrics-pprof-enabled")
+	if pprofEnabled {
+		runtime.SetMutexProfileFraction(int(cobrautil.MustGetUint32(cmd, "metrics-pprof-mutex-profile-fraction")))
+		runtime.SetBlockProfileRate(int(cobrautil.MustGetUint32(cmd, "metrics-pprof-block-profile-rate")))
+	}
+	return metricsHandler(pprofEnabled)
+}
+
 // SignalContextWithGracePeriod creates a new context that will be cancelled
 // when an interrupt/SIGTERM signal is received and the provided grace period
 // subsequently finishes.
@@ -84,3 +115,13 @@ func SignalContextWithGracePeriod(ctx context.Context, gracePeriod time.Duration
 
 	return newCtx
 }
+
+// WriteOutput writes generated output to the file at the provided path, or to stdout if the
+// path is empty.
+func WriteOutput(path string, contents string) error {
+	if path == "" {
+		fmt.Print(contents)
+		return nil
+	}
+	return os.WriteFile(path, []byte(contents), 0o644)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHandlerFromFlags(t *testing.T) {
+	for _, enabled := range []string{"true", "false"} {
+		t.Run("pprof-enabled="+enabled, func(t *testing.T) {
+			require := require.New(t)
+
+			cmd := &cobra.Command{Use: "test"}
+			RegisterProfilingFlags(cmd)
+			require.NoError(cmd.ParseFlags([]string{"--metrics-pprof-enabled=" + enabled}))
+
+			handler := MetricsHandlerFromFlags(cmd)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			require.Equal(http.StatusOK, recorder.Code)
+
+			expectedStatus := http.StatusNotFound
+			if enabled == "true" {
+				expectedStatus = http.StatusOK
+			}
+			recorder = httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine", nil))
+			require.Equal(expectedStatus, recorder.Code)
+		})
+	}
+}
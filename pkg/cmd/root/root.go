@@ -9,7 +9,7 @@ import (
 
 func RegisterFlags(cmd *cobra.Command) {
 	cobrautil.RegisterZeroLogFlags(cmd.PersistentFlags(), "log")
-	cobrautil.RegisterOpenTelemetryFlags(cmd.PersistentFlags(), "otel", cmd.Use)
+	cmdutil.RegisterTracingFlags(cmd)
 }
 
 func NewCommand(programName string) *cobra.Command {
@@ -0,0 +1,292 @@
+package schema
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/jzelinskie/cobrautil"
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/authzed/spicedb/pkg/cmd"
+	"github.com/authzed/spicedb/pkg/graphexport"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/docs"
+	"github.com/authzed/spicedb/pkg/schemadsl/formatter"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/schemadsl/lint"
+	"github.com/authzed/spicedb/pkg/schemadsl/zanzibar"
+)
+
+func NewCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "operate on schema files",
+	}
+}
+
+func RegisterDocsFlags(cmd *cobra.Command) {
+	cmd.Flags().String("format", string(docs.FormatMarkdown), `format of the generated documentation ("markdown", "html")`)
+	cmd.Flags().String("output", "", "file to which the documentation is written; empty writes to stdout")
+}
+
+func NewDocsCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "docs <schema file>...",
+		Short:   "generate documentation of a schema",
+		Long:    "Generates documentation of the object types, relations, permissions, allowed subject types and comments of a schema, so that it can be published for readers who are not familiar with the schema language.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE:    docsRun,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func docsRun(cmd *cobra.Command, args []string) error {
+	format, err := docs.ParseFormat(cobrautil.MustGetString(cmd, "format"))
+	if err != nil {
+		return err
+	}
+
+	defs, err := compileSchemaFiles(args)
+	if err != nil {
+		return err
+	}
+
+	generated, err := docs.Generate(defs, format)
+	if err != nil {
+		return err
+	}
+
+	return cmdutil.WriteOutput(cobrautil.MustGetString(cmd, "output"), generated)
+}
+
+func RegisterGraphFlags(cmd *cobra.Command) {
+	cmd.Flags().String("format", string(graphexport.FormatDOT), `format of the exported graph ("dot", "graphml")`)
+	cmd.Flags().String("output", "", "file to which the graph is written; empty writes to stdout")
+}
+
+func NewGraphCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "graph <schema file>...",
+		Short:   "export the type graph of a schema",
+		Long:    "Exports the graph of the object types of a schema, with an edge for each relation to the subject types which can be written to it and for each permission walking a relation to another type, for rendering by graph visualization tools.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE:    graphRun,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func graphRun(cmd *cobra.Command, args []string) error {
+	format, err := graphexport.ParseFormat(cobrautil.MustGetString(cmd, "format"))
+	if err != nil {
+		return err
+	}
+
+	defs, err := compileSchemaFiles(args)
+	if err != nil {
+		return err
+	}
+
+	rendered, err := graphexport.Render(graphexport.SchemaGraph(defs), format)
+	if err != nil {
+		return err
+	}
+
+	return cmdutil.WriteOutput(cobrautil.MustGetString(cmd, "output"), rendered)
+}
+
+func RegisterLintFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("disable", nil, `rules which are not run, e.g. "unused-relation"`)
+}
+
+func NewLintCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "lint <schema file>...",
+		Short:   "find likely mistakes in a schema",
+		Long:    "Finds likely mistakes and departures from convention in a schema, such as unused relations, permissions which can never be granted, shadowed definition names, permissions recursing without walking a relation and unconventional names. Exits with an error if any are found.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE:    lintRun,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func lintRun(cmd *cobra.Command, args []string) error {
+	known := make(map[lint.Rule]struct{}, len(lint.Rules))
+	for _, rule := range lint.Rules {
+		known[rule] = struct{}{}
+	}
+
+	disabled := make(map[lint.Rule]struct{})
+	for _, rule := range cobrautil.MustGetStringSlice(cmd, "disable") {
+		if _, ok := known[lint.Rule(rule)]; !ok {
+			return fmt.Errorf("unknown lint rule `%s`", rule)
+		}
+		disabled[lint.Rule(rule)] = struct{}{}
+	}
+
+	defs, err := compileSchemaFiles(args)
+	if err != nil {
+		return err
+	}
+
+	var found int
+	for _, finding := range lint.Lint(defs) {
+		if _, ok := disabled[finding.Rule]; ok {
+			continue
+		}
+		fmt.Println(finding.String())
+		found++
+	}
+
+	if found > 0 {
+		return fmt.Errorf("found %d issues in the schema", found)
+	}
+	return nil
+}
+
+func RegisterFormatFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("write", false, "write the formatted schema back to each file rather than to stdout")
+	cmd.Flags().Bool("check", false, "list the files which are not formatted and exit with an error if there are any")
+}
+
+func NewFormatCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "format <schema file>...",
+		Short:   "format schema files in the canonical form",
+		Long:    "Formats schema files in the canonical form of the schema language, with definitions separated by blank lines, statements indented by a tab, redundant parentheses removed and comments kept. Formatting a formatted file leaves it unchanged, so --check can enforce the formatting in CI.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE:    formatRun,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func formatRun(cmd *cobra.Command, args []string) error {
+	write := cobrautil.MustGetBool(cmd, "write")
+	check := cobrautil.MustGetBool(cmd, "check")
+	if write && check {
+		return fmt.Errorf("only one of --write and --check may be given")
+	}
+
+	var unformatted int
+	for _, path := range args {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read schema file %s: %w", path, err)
+		}
+
+		formatted, err := formatter.Format(input.Source(path), string(contents))
+		if err != nil {
+			return fmt.Errorf("unable to format schema file %s: %w", path, err)
+		}
+
+		switch {
+		case check:
+			if formatted != string(contents) {
+				fmt.Println(path)
+				unformatted++
+			}
+		case write:
+			if formatted != string(contents) {
+				if err := cmdutil.WriteOutput(path, formatted); err != nil {
+					return fmt.Errorf("unable to write schema file %s: %w", path, err)
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+
+	if unformatted > 0 {
+		return fmt.Errorf("found %d schema files which are not formatted", unformatted)
+	}
+	return nil
+}
+
+func RegisterImportZanzibarFlags(cmd *cobra.Command) {
+	cmd.Flags().String("format", string(zanzibar.FormatTextProto), `format of the namespace config files ("textproto", "json", "binary")`)
+	cmd.Flags().StringSlice("subject-types", nil, `types of subjects which may be written to a relation, e.g. "doc#owner=user|group#member"`)
+	cmd.Flags().StringSlice("default-subject-types", nil, `types of subjects which may be written to the relations without --subject-types, e.g. "user"`)
+	cmd.Flags().String("output", "", "file to which the schema is written; empty writes to stdout")
+}
+
+func NewImportZanzibarCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "import-zanzibar <namespace config file>...",
+		Short:   "convert Zanzibar-style namespace configs into a schema",
+		Long:    "Converts Zanzibar-style namespace configs, one per file, into a schema, and validates the schema against the type system. As the configs do not declare the types of subjects which may be written to their relations, the types must be supplied. Relations which both store and compute relationships are split into a relation, to which their relationships must be moved, and a permission of the original name; the relations moved are listed on stderr.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE:    importZanzibarRun,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func importZanzibarRun(cmd *cobra.Command, args []string) error {
+	format, err := zanzibar.ParseFormat(cobrautil.MustGetString(cmd, "format"))
+	if err != nil {
+		return err
+	}
+
+	var opts []zanzibar.Option
+	for _, spec := range cobrautil.MustGetStringSlice(cmd, "subject-types") {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid subject types `%s`; must be of the form namespace#relation=type|type", spec)
+		}
+		opts = append(opts, zanzibar.WithSubjectTypes(parts[0], strings.Split(parts[1], "|")...))
+	}
+	if defaults := cobrautil.MustGetStringSlice(cmd, "default-subject-types"); len(defaults) > 0 {
+		opts = append(opts, zanzibar.WithDefaultSubjectTypes(defaults...))
+	}
+
+	configs := make([]*v0.NamespaceDefinition, 0, len(args))
+	for _, path := range args {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read namespace config file %s: %w", path, err)
+		}
+
+		config, err := zanzibar.ParseConfig(contents, format)
+		if err != nil {
+			return fmt.Errorf("unable to parse namespace config file %s: %w", path, err)
+		}
+		configs = append(configs, config)
+	}
+
+	result, err := zanzibar.Convert(configs, opts...)
+	if err != nil {
+		return fmt.Errorf("unable to convert namespace configs: %w", err)
+	}
+
+	moved := make([]string, 0, len(result.MovedRelations))
+	for relation := range result.MovedRelations {
+		moved = append(moved, relation)
+	}
+	sort.Strings(moved)
+	for _, relation := range moved {
+		fmt.Fprintf(cmd.ErrOrStderr(), "relationships of %s must be moved to relation %s\n", relation, result.MovedRelations[relation])
+	}
+
+	return cmdutil.WriteOutput(cobrautil.MustGetString(cmd, "output"), result.Schema+"\n")
+}
+
+func compileSchemaFiles(paths []string) ([]*v0.NamespaceDefinition, error) {
+	schemas := make([]compiler.InputSchema, 0, len(paths))
+	for _, path := range paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read schema file %s: %w", path, err)
+		}
+		schemas = append(schemas, compiler.InputSchema{
+			Source:       input.Source(path),
+			SchemaString: string(contents),
+		})
+	}
+
+	defs, err := compiler.Compile(schemas, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile schema: %w", err)
+	}
+	return defs, nil
+}
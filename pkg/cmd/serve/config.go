@@ -0,0 +1,51 @@
+package serve
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	cmdutil "github.com/authzed/spicedb/pkg/cmd"
+	"github.com/authzed/spicedb/pkg/cmd/root"
+)
+
+func NewConfigCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "config",
+		Short: "operate on configuration files",
+	}
+}
+
+func NewValidateConfigCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate <file>",
+		Short: "validate a configuration file for serve",
+		Long:  "Checks that a configuration file for serve only sets known flags to valid values and, together with the environment, sets every required flag, without starting the server.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validateConfigRun(cmd, programName, args[0])
+		},
+		Args: cobra.ExactArgs(1),
+	}
+}
+
+func validateConfigRun(cmd *cobra.Command, programName, path string) error {
+	// The file is validated by running a serve command which loads it, under a root command
+	// providing the persistent flags, but does not start the server, so that the flags are
+	// parsed and required flags are checked exactly as they would be when serving.
+	rootCmd := root.NewCommand(programName)
+	root.RegisterFlags(rootCmd)
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
+
+	var dsConfig cmdutil.DatastoreConfig
+	serveCmd := NewServeCommand(programName, &dsConfig)
+	RegisterServeFlags(serveCmd, &dsConfig)
+	serveCmd.RunE = func(serveCmd *cobra.Command, _ []string) error {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s is valid\n", path)
+		return nil
+	}
+	rootCmd.AddCommand(serveCmd)
+
+	rootCmd.SetArgs([]string{serveCmd.Name(), "--" + cmdutil.ConfigFileFlag, path})
+	return rootCmd.Execute()
+}
@@ -31,6 +31,7 @@ import (
 func RegisterDevtoolsFlags(cmd *cobra.Command) {
 	cobrautil.RegisterGrpcServerFlags(cmd.Flags(), "grpc", "gRPC", ":50051", true)
 	cobrautil.RegisterHttpServerFlags(cmd.Flags(), "metrics", "metrics", ":9090", true)
+	cmdutil.RegisterProfilingFlags(cmd)
 	cobrautil.RegisterHttpServerFlags(cmd.Flags(), "http", "download", ":8443", false)
 
 	cmd.Flags().String("share-store", "inmemory", "kind of share store to use")
@@ -78,7 +79,7 @@ func runfunc(cmd *cobra.Command, args []string) error {
 
 	// Start the metrics endpoint.
 	metricsSrv := cobrautil.HttpServerFromFlags(cmd, "metrics")
-	metricsSrv.Handler = cmdutil.MetricsHandler()
+	metricsSrv.Handler = cmdutil.MetricsHandlerFromFlags(cmd)
 	go func() {
 		if err := cobrautil.HttpListenFromFlags(cmd, "metrics", metricsSrv, zerolog.InfoLevel); err != nil {
 			log.Fatal().Err(err).Msg("failed while serving metrics")
@@ -100,6 +101,9 @@ func runfunc(cmd *cobra.Command, args []string) error {
 	if err := metricsSrv.Close(); err != nil {
 		log.Fatal().Err(err).Msg("failed while shutting down metrics server")
 	}
+	if err := downloadSrv.Close(); err != nil {
+		log.Fatal().Err(err).Msg("failed while shutting down download http api")
+	}
 
 	return nil
 }
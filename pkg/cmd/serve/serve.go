@@ -2,40 +2,100 @@ package serve
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/authzed/grpcutil"
+	"github.com/dgraph-io/ristretto"
 	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	grpczerolog "github.com/grpc-ecosystem/go-grpc-middleware/providers/zerolog/v2"
 	grpclog "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	grpcprom "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/jzelinskie/cobrautil"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/authzed/spicedb/internal/auth"
 	"github.com/authzed/spicedb/internal/dashboard"
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/internal/datastore/proxy"
+	"github.com/authzed/spicedb/internal/diagnostics"
 	combineddispatch "github.com/authzed/spicedb/internal/dispatch/combined"
+	"github.com/authzed/spicedb/internal/dispatch/mtls"
 	"github.com/authzed/spicedb/internal/gateway"
+	"github.com/authzed/spicedb/internal/middleware/checkmetrics"
+	"github.com/authzed/spicedb/internal/middleware/datastoreid"
+	"github.com/authzed/spicedb/internal/middleware/deadline"
+	"github.com/authzed/spicedb/internal/middleware/dispatchdepth"
+	"github.com/authzed/spicedb/internal/middleware/drain"
+	"github.com/authzed/spicedb/internal/middleware/freshness"
+	"github.com/authzed/spicedb/internal/middleware/loadshed"
+	"github.com/authzed/spicedb/internal/middleware/provenance"
+	"github.com/authzed/spicedb/internal/middleware/ratelimit"
+	"github.com/authzed/spicedb/internal/middleware/recovery"
 	"github.com/authzed/spicedb/internal/middleware/servicespecific"
+	"github.com/authzed/spicedb/internal/middleware/subjectbinding"
 	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/otlpmetrics"
+	"github.com/authzed/spicedb/internal/redact"
 	"github.com/authzed/spicedb/internal/services"
+	v1svc "github.com/authzed/spicedb/internal/services/v1"
 	v1alpha1svc "github.com/authzed/spicedb/internal/services/v1alpha1"
 	cmdutil "github.com/authzed/spicedb/pkg/cmd"
+	"github.com/authzed/spicedb/pkg/middleware/audit"
 	logmw "github.com/authzed/spicedb/pkg/middleware/logging"
+	"github.com/authzed/spicedb/pkg/middleware/priority"
 	"github.com/authzed/spicedb/pkg/middleware/requestid"
 	"github.com/authzed/spicedb/pkg/validationfile"
 )
 
 func RegisterServeFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
+	cmdutil.RegisterConfigFileFlag(cmd)
+
 	// Flags for the gRPC API server
 	cobrautil.RegisterGrpcServerFlags(cmd.Flags(), "grpc", "gRPC", ":50051", true)
+	cmd.Flags().Duration("grpc-watch-heartbeat-interval", v1svc.DefaultWatchHeartbeatInterval, "interval at which watch streams send a checkpoint of the revision through which changes were sent, even without changes (0 disables)")
 	cmd.Flags().String("grpc-preshared-key", "", "preshared key to require for authenticated requests")
-	cmd.Flags().Duration("grpc-shutdown-grace-period", 0*time.Second, "amount of time after receiving sigint to continue serving")
+	cmd.Flags().StringToString("grpc-named-preshared-keys", map[string]string{}, `additional preshared keys which grant the same access as --grpc-preshared-key, by name, e.g. "billing=somekey"; usage of each key is recorded in metrics under its name`)
+	cmd.Flags().String("grpc-preshared-keys-file", "", "file of additional named preshared keys, one name=key per line, which is reloaded while serving so that keys can be rotated and revoked")
+	cmd.Flags().Duration("grpc-preshared-keys-file-reload-interval", 30*time.Second, "interval at which --grpc-preshared-keys-file is checked for changes")
+	cmd.Flags().StringToString("grpc-tenant-preshared-keys", map[string]string{}, `preshared keys which grant access to a single tenant, by tenant name, e.g. "acme=somekey"; requests using the primary preshared key may select a tenant with the x-spicedb-tenant header`)
+	cmd.Flags().StringToString("grpc-subject-bound-preshared-keys", map[string]string{}, `preshared keys which only grant access to check, look up and write relationships on behalf of the subjects of a binding, by binding ("type", "type:id" or "type:prefix*"), e.g. "user:edge-*=somekey"`)
+	cmd.Flags().String("grpc-oidc-issuer", "", "URL of an OIDC issuer whose JWT bearer tokens are accepted in addition to the preshared keys")
+	cmd.Flags().String("grpc-oidc-audience", "", "audience which the tokens of the OIDC issuer must be issued for")
+	cmd.Flags().String("grpc-oidc-principal-claim", auth.DefaultPrincipalClaim, "claim of the tokens of the OIDC issuer which names the principal recorded with writes")
+	cmd.Flags().StringSlice("grpc-oidc-write-scopes", []string{}, "scopes of which the tokens of the OIDC issuer must be granted one to call write APIs; empty allows any token to write")
+	cmd.Flags().Duration("grpc-oidc-key-refresh-interval", 1*time.Hour, "interval at which the keys of the OIDC issuer are fetched")
+	cmd.Flags().Duration("grpc-shutdown-grace-period", 0*time.Second, "amount of time after receiving sigint to continue serving, while reporting not serving through the gRPC health service so that load balancers stop sending requests")
+	cmd.Flags().Duration("grpc-shutdown-drain-timeout", 30*time.Second, "maximum amount of time after the grace period for in-flight requests to complete before their connections are closed")
+	cmd.Flags().Duration("grpc-panic-log-interval", 1*time.Minute, "minimum amount of time between logging panics with the same fingerprint")
+	cmd.Flags().Uint64("grpc-panic-crash-threshold", 0, "number of panics recovered within the crash window after which the server reports itself as not serving (0 to disable)")
+	cmd.Flags().Duration("grpc-panic-crash-window", 5*time.Minute, "sliding window of time within which panics count towards the crash threshold")
+	cmd.Flags().Bool("grpc-enable-reflection", true, "serve gRPC server reflection, without authentication, for tools such as grpcurl")
+	cmd.Flags().String("grpc-ratelimit-global", "", `limit of the rate of all requests together, as "rate[:burst]" in requests per second; empty is unlimited`)
+	cmd.Flags().String("grpc-ratelimit-per-key", "", `limit of the rate of requests of each preshared key or token principal, as "rate[:burst]" in requests per second; empty is unlimited`)
+	cmd.Flags().StringToString("grpc-ratelimit-keys", map[string]string{}, `limits of the rate of requests of the named preshared keys, overriding --grpc-ratelimit-per-key, e.g. "billing=100:200"`)
+	cmd.Flags().String("grpc-ratelimit-per-tenant", "", `limit of the rate of requests of each tenant, as "rate[:burst]" in requests per second; empty is unlimited`)
+	cmd.Flags().StringToString("grpc-ratelimit-tenants", map[string]string{}, `limits of the rate of requests of the named tenants, overriding --grpc-ratelimit-per-tenant`)
+	cmd.Flags().Uint64("grpc-load-shedding-max-in-flight", 0, "number of requests in flight at which the server is fully loaded; lower priority requests are shed from half of it, and writes past it (0 to disable)")
+	cmd.Flags().Uint64("grpc-load-shedding-max-dispatch-in-flight", 0, "number of requests dispatched by peers in flight at which the server is fully loaded (0 to disable)")
+	cmd.Flags().Duration("grpc-load-shedding-max-datastore-pool-wait", 0, "average time spent waiting for datastore connections at which the server is fully loaded (0 to disable)")
+	cmd.Flags().StringToString("grpc-default-deadlines", map[string]string{}, `deadlines given to requests without one, by API family (checks, lookups, reads, writes or watch), e.g. "lookups=30s"`)
+	cmd.Flags().StringToString("grpc-max-deadlines", map[string]string{}, `maximum deadlines of requests, to which longer deadlines are shortened, by API family (checks, lookups, reads, writes or watch), e.g. "lookups=1m,watch=1h"`)
+	cmd.Flags().Duration("grpc-health-datastore-check-interval", 5*time.Second, "interval at which the datastore is checked for readiness, reporting the server as not serving through the gRPC health service while it is not ready (0 to disable)")
 	if err := cmd.MarkFlagRequired("grpc-preshared-key"); err != nil {
 		panic("failed to mark flag as required: " + err.Error())
 	}
@@ -43,9 +103,13 @@ func RegisterServeFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
 	// Flags for the datastore
 	cmdutil.RegisterDatastoreFlags(cmd, dsConfig)
 	cmd.Flags().Bool("datastore-readonly", false, "set the service to read-only mode")
-	cmd.Flags().StringSlice("datastore-bootstrap-files", []string{}, "bootstrap data yaml files to load")
+	cmd.Flags().Bool("datastore-maintenance-mode", false, "start the service in maintenance mode, rejecting writes until it is toggled off by sending SIGUSR2")
+	cmd.Flags().StringSlice("datastore-bootstrap-files", []string{}, "bootstrap data yaml files to load into an empty datastore; a datastore which already holds exactly their data is left as is")
 	cmd.Flags().Bool("datastore-bootstrap-overwrite", false, "overwrite any existing data with bootstrap data")
 
+	cmd.Flags().Bool("datastore-namespace-cache", true, "cache namespace definitions across revisions, invalidating them as namespaces change")
+	cmd.Flags().Uint64("datastore-query-cache-max-bytes", 0, "estimated number of bytes of tuple query results to cache by query and revision, so that repeated identical reads are not sent to the datastore; 0 disables the cache")
+	cmd.Flags().Duration("datastore-standby-max-staleness", 0, "if the datastore becomes unavailable, amount of time for which minimize latency requests continue to be answered from cache at the last known revision, marked stale; 0 disables warm standby")
 	cmd.Flags().Bool("datastore-request-hedging", true, "enable request hedging")
 	cmd.Flags().Duration("datastore-request-hedging-initial-slow-value", 10*time.Millisecond, "initial value to use for slow datastore requests, before statistics have been collected")
 	cmd.Flags().Uint64("datastore-request-hedging-max-requests", 1_000_000, "maximum number of historical requests to consider")
@@ -65,15 +129,44 @@ func RegisterServeFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
 
 	// Flags for configuring dispatch requests
 	cmd.Flags().Uint32("dispatch-max-depth", 50, "maximum recursion depth for nested calls")
-	cmd.Flags().String("dispatch-upstream-addr", "", "upstream grpc address to dispatch to")
+	cmd.Flags().Uint32("dispatch-max-depth-limit", 100, "maximum recursion depth for nested calls which a request may set in place of --dispatch-max-depth")
+	cmd.Flags().String("dispatch-upstream-addr", "", `upstream grpc address to dispatch to, e.g. "kubernetes:///spicedb.default:50053" to discover the peers from the endpoints of a service`)
+	cmd.Flags().StringSlice("dispatch-upstream-peers", []string{}, "static list of the grpc addresses of the peers to dispatch to, as an alternative to --dispatch-upstream-addr")
 	cmd.Flags().String("dispatch-upstream-ca-path", "", "local path to the TLS CA used when connecting to the dispatch cluster")
+	cmd.Flags().String("dispatch-mtls-cert-path", "", "local path to the certificate which the node presents to its peers, both when serving and when dispatching, to enable mutual TLS within the dispatch cluster")
+	cmd.Flags().String("dispatch-mtls-key-path", "", "local path to the key of --dispatch-mtls-cert-path")
+	cmd.Flags().String("dispatch-mtls-ca-path", "", "local path to the bundle of CAs which issue the certificates of the peers of the dispatch cluster")
+	cmd.Flags().StringSlice("dispatch-mtls-allowed-peer-ids", []string{}, `SPIFFE IDs of the peers of the dispatch cluster which are allowed, e.g. "spiffe://example.org/ns/spicedb/*" or a trust domain alone; empty allows any peer with a certificate issued by --dispatch-mtls-ca-path`)
+	cmd.Flags().Duration("dispatch-mtls-reload-interval", 1*time.Minute, "interval at which the dispatch mTLS certificates are checked for changes; they are also reloaded on SIGHUP")
+	cmd.Flags().Uint64("dispatch-cache-max-cost", 1<<24, "maximum cost, roughly the size in bytes, of the check and lookup results held by each dispatch cache")
+	cmd.Flags().Uint64("dispatch-cache-num-counters", 1e4, "number of keys whose access frequency is tracked to decide which dispatch cache entries to keep; about ten times the number of entries expected to fit")
+	cmd.Flags().Uint32("dispatch-cache-ttl-windows", 0, "number of revision quantization windows after which cached dispatch results expire; 0 keeps them until evicted")
+	cmd.Flags().Float64("dispatch-hedging-quantile", 0, "quantile, e.g. 0.95, of the recent latencies of requests dispatched to peers after which they are duplicated to another peer, taking the first answer; 0 disables hedging")
+	cmd.Flags().Duration("dispatch-hedging-initial-slow-value", 10*time.Millisecond, "initial value to use for slow dispatched requests, before statistics have been collected")
+	cmd.Flags().Bool("dispatch-check-prefetch", false, "load the relationships of every relation of a checked object which the check may read in a single query, resolving relations computed from others on the same object without dispatching them")
 
 	// Flags for configuring API behavior
 	cmd.Flags().Bool("disable-v1-schema-api", false, "disables the V1 schema API")
 
+	// Flags for the audit log
+	cmd.Flags().String("audit-log-output", "", `file to which an audit log of the calls which mutate data is appended as lines of JSON; "-" writes to stdout and empty disables the audit log`)
+	cmd.Flags().Bool("audit-log-include-checks", false, "also record checks in the audit log")
+
+	// Flags for telemetry
+	cmd.Flags().String("telemetry-id-obfuscation", "none", "obfuscation applied to object and subject IDs recorded in logs, traces and metrics: none, hash or redact")
+	cmd.Flags().String("telemetry-id-obfuscation-salt", "", "salt used when hashing object and subject IDs recorded in telemetry")
+	cmd.Flags().StringSlice("telemetry-obfuscated-classes", []string{"ids"}, "classes of values obfuscated with --telemetry-id-obfuscation in logs, traces, metrics and the messages of errors: ids, tenants, types or relations")
+
 	// Flags for misc services
 	cobrautil.RegisterHttpServerFlags(cmd.Flags(), "dashboard", "dashboard", ":8080", true)
 	cobrautil.RegisterHttpServerFlags(cmd.Flags(), "metrics", "metrics", ":9090", true)
+	cmdutil.RegisterProfilingFlags(cmd)
+	cmd.Flags().Duration("metrics-diagnostics-timeout", 5*time.Second, "maximum amount of time taken to diagnose the datastore for the diagnostics served at /debug/diagnostics on the metrics server")
+	cmd.Flags().Uint64("metrics-check-permission-max-series", 0, `number of distinct pairs of resource type and permission by which the count, latency and results of checks are recorded in metrics, past which checks are recorded under "other"; 0 disables the metrics`)
+	cmd.Flags().String("metrics-otlp-endpoint", "", `address of an OTLP gRPC endpoint to which metrics are pushed, alongside the Prometheus endpoint, e.g. "otel-collector:4317"`)
+	cmd.Flags().StringToString("metrics-otlp-headers", map[string]string{}, `headers sent with every push of OTLP metrics, e.g. "api-key=somekey"`)
+	cmd.Flags().Bool("metrics-otlp-insecure", false, "push OTLP metrics without TLS")
+	cmd.Flags().Duration("metrics-otlp-interval", 15*time.Second, "interval between pushes of OTLP metrics")
 }
 
 func NewServeCommand(programName string, dsConfig *cmdutil.DatastoreConfig) *cobra.Command {
@@ -99,12 +192,76 @@ func serveRun(ctx context.Context, cmd *cobra.Command, args []string, datastoreO
 		return errors.New("a preshared key must be provided via --grpc-preshared-key to authenticate API requests")
 	}
 
+	obfuscationMode, err := redact.ParseMode(cobrautil.MustGetString(cmd, "telemetry-id-obfuscation"))
+	if err != nil {
+		return err
+	}
+	obfuscatedClasses, err := redact.ParseClasses(cobrautil.MustGetStringSlice(cmd, "telemetry-obfuscated-classes"))
+	if err != nil {
+		return err
+	}
+	redact.Configure(obfuscationMode, cobrautil.MustGetStringExpanded(cmd, "telemetry-id-obfuscation-salt"), obfuscatedClasses...)
+
+	tenantKeys, err := cmd.Flags().GetStringToString("grpc-tenant-preshared-keys")
+	if err != nil {
+		return err
+	}
+	subjectBoundKeys, err := cmd.Flags().GetStringToString("grpc-subject-bound-preshared-keys")
+	if err != nil {
+		return err
+	}
+	namedKeys, err := cmd.Flags().GetStringToString("grpc-named-preshared-keys")
+	if err != nil {
+		return err
+	}
+	keysConfig := auth.PresharedKeys{
+		Primary:          token,
+		Named:            namedKeys,
+		Tenants:          tenantKeys,
+		SubjectBound:     subjectBoundKeys,
+		TenantsSupported: cmdutil.EngineIsolatesTenants(datastoreOpts.Engine),
+	}
+	authenticator, err := auth.NewPresharedKeyAuthenticator(keysConfig)
+	if err != nil {
+		return fmt.Errorf("invalid preshared key configuration for datastore engine %s: %w", datastoreOpts.Engine, err)
+	}
+	if keysFile := cobrautil.MustGetStringExpanded(cmd, "grpc-preshared-keys-file"); keysFile != "" {
+		watcher, err := auth.NewPresharedKeysFileWatcher(authenticator, keysConfig, keysFile)
+		if err != nil {
+			return err
+		}
+		go watcher.Run(ctx, cobrautil.MustGetDuration(cmd, "grpc-preshared-keys-file-reload-interval"))
+	}
+	authFunc := grpcauth.AuthFunc(authenticator.Authenticate)
+
+	if issuer := cobrautil.MustGetStringExpanded(cmd, "grpc-oidc-issuer"); issuer != "" {
+		verifier, err := auth.NewOIDCVerifier(ctx, auth.OIDCConfig{
+			IssuerURL:          issuer,
+			Audience:           cobrautil.MustGetStringExpanded(cmd, "grpc-oidc-audience"),
+			PrincipalClaim:     cobrautil.MustGetString(cmd, "grpc-oidc-principal-claim"),
+			WriteScopes:        cobrautil.MustGetStringSlice(cmd, "grpc-oidc-write-scopes"),
+			KeyRefreshInterval: cobrautil.MustGetDuration(cmd, "grpc-oidc-key-refresh-interval"),
+		})
+		if err != nil {
+			return fmt.Errorf("invalid OIDC configuration: %w", err)
+		}
+		authFunc = auth.RequireOIDCTokens(verifier, authFunc)
+	}
+
 	ds, err := cmdutil.NewDatastore(datastoreOpts.ToOption())
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to init datastore")
 	}
+	cmdutil.GarbageCollectOnSignal(ctx, ds)
 
-	bootstrapFilePaths := cobrautil.MustGetStringSlice(cmd, "datastore-bootstrap-files")
+	// ZedTokens are bound to the unique ID of the datastore, so that those minted against
+	// another datastore, whose revisions are unrelated, are rejected.
+	datastoreID, err := datastore.UniqueID(ctx, ds)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to read unique ID of datastore")
+	}
+
+	bootstrapFilePaths := cobrautil.MustGetStringSliceExpanded(cmd, "datastore-bootstrap-files")
 	if len(bootstrapFilePaths) > 0 {
 		bootstrapOverwrite := cobrautil.MustGetBool(cmd, "datastore-bootstrap-overwrite")
 		revision, err := ds.HeadRevision(context.Background())
@@ -123,10 +280,27 @@ func serveRun(ctx context.Context, cmd *cobra.Command, args []string, datastoreO
 				log.Fatal().Err(err).Msg("failed to load bootstrap files")
 			}
 		} else {
-			return errors.New("cannot apply bootstrap data: schema or tuples already exist in the datastore. Delete existing data or set the flag --datastore-bootstrap-overwrite=true")
+			// A datastore which outlives the server, such as a persisted in-memory one,
+			// may already hold exactly the bootstrap data from a previous start.
+			bootstrapChecksum, err := validationfile.FilesChecksum(bootstrapFilePaths)
+			if err != nil {
+				log.Fatal().Err(err).Msg("failed to load bootstrap files")
+			}
+			existingChecksum, err := validationfile.DatastoreChecksum(context.Background(), ds, revision)
+			if err != nil {
+				log.Fatal().Err(err).Msg("unable to determine datastore state before applying bootstrap data")
+			}
+			if bootstrapChecksum != existingChecksum {
+				return errors.New("cannot apply bootstrap data: schema or tuples already exist in the datastore. Delete existing data or set the flag --datastore-bootstrap-overwrite=true")
+			}
+			log.Info().Str("checksum", bootstrapChecksum).Msg("datastore already contains the bootstrap data")
 		}
 	}
 
+	if cobrautil.MustGetBool(cmd, "datastore-namespace-cache") {
+		ds = proxy.NewNamespaceCachingProxy(ds)
+	}
+
 	if cobrautil.MustGetBool(cmd, "datastore-request-hedging") {
 		initialSlowRequest := cobrautil.MustGetDuration(cmd, "datastore-request-hedging-initial-slow-value")
 		maxRequests := cobrautil.MustGetUint64(cmd, "datastore-request-hedging-max-requests")
@@ -146,9 +320,26 @@ func serveRun(ctx context.Context, cmd *cobra.Command, args []string, datastoreO
 		)
 	}
 
+	if queryCacheMaxBytes := cobrautil.MustGetUint64(cmd, "datastore-query-cache-max-bytes"); queryCacheMaxBytes > 0 {
+		log.Info().Uint64("maxBytes", queryCacheMaxBytes).Msg("query cache enabled")
+		ds = proxy.NewQueryCachingProxy(ds, int64(queryCacheMaxBytes))
+	}
+
+	if standbyMaxStaleness := cobrautil.MustGetDuration(cmd, "datastore-standby-max-staleness"); standbyMaxStaleness > 0 {
+		log.Info().Stringer("maxStaleness", standbyMaxStaleness).Msg("warm standby enabled")
+		ds = proxy.NewStandbyProxy(ds, standbyMaxStaleness)
+	}
+
 	if cobrautil.MustGetBool(cmd, "datastore-readonly") {
 		log.Warn().Msg("setting the service to read-only")
 		ds = proxy.NewReadonlyDatastore(ds)
+	} else {
+		maintenanceDS := proxy.NewMaintenanceDatastore(ds, cobrautil.MustGetBool(cmd, "datastore-maintenance-mode"))
+		if maintenanceDS.Enabled() {
+			log.Warn().Msg("setting the service to maintenance mode")
+		}
+		cmdutil.ToggleMaintenanceOnSignal(ctx, maintenanceDS)
+		ds = maintenanceDS
 	}
 
 	nsCacheExpiration := cobrautil.MustGetDuration(cmd, "ns-cache-expiration")
@@ -161,40 +352,150 @@ func serveRun(ctx context.Context, cmd *cobra.Command, args []string, datastoreO
 		[]float64{.006, .010, .018, .024, .032, .042, .056, .075, .100, .178, .316, .562, 1.000},
 	))
 
+	// The health server is only available once the services are registered, but the
+	// recovery handler must be created first to be part of the middleware.
+	var healthSrv *grpcutil.AuthlessHealthServer
+	panicHandler := recovery.NewHandler(
+		recovery.LogInterval(cobrautil.MustGetDuration(cmd, "grpc-panic-log-interval")),
+		recovery.CrashWindow(cobrautil.MustGetDuration(cmd, "grpc-panic-crash-window")),
+		recovery.CrashThreshold(cobrautil.MustGetUint64(cmd, "grpc-panic-crash-threshold"), func() {
+			if healthSrv != nil {
+				healthSrv.Shutdown()
+			}
+		}),
+	)
+
+	var auditSink audit.Sink
+	if output := cobrautil.MustGetStringExpanded(cmd, "audit-log-output"); output != "" {
+		fileSink, err := audit.NewFileSink(output)
+		if err != nil {
+			return err
+		}
+		defer fileSink.Close()
+		auditSink = fileSink
+	}
+
+	var checkRecorder *checkmetrics.Recorder
+	if maxSeries := cobrautil.MustGetUint64(cmd, "metrics-check-permission-max-series"); maxSeries > 0 {
+		checkRecorder = checkmetrics.NewRecorder(int(maxSeries))
+	}
+
+	// The recovery interceptors come first so that panics in the other interceptors are
+	// recovered as well.
 	middleware := grpc.ChainUnaryInterceptor(
+		recovery.UnaryServerInterceptor(panicHandler),
+		otelgrpc.UnaryServerInterceptor(),
 		requestid.UnaryServerInterceptor(requestid.GenerateIfMissing(true)),
 		logmw.UnaryServerInterceptor(logmw.ExtractMetadataField("x-request-id", "requestID")),
+		priority.UnaryServerInterceptor(),
 		grpclog.UnaryServerInterceptor(grpczerolog.InterceptorLogger(log.Logger)),
-		otelgrpc.UnaryServerInterceptor(),
-		grpcauth.UnaryServerInterceptor(auth.RequirePresharedKey(token)),
+		grpcauth.UnaryServerInterceptor(authFunc),
+		subjectbinding.UnaryServerInterceptor(),
+		provenance.UnaryServerInterceptor(),
+		datastoreid.UnaryServerInterceptor(datastoreID),
 		grpcprom.UnaryServerInterceptor,
+		checkmetrics.UnaryServerInterceptor(checkRecorder),
+		freshness.UnaryServerInterceptor(datastoreOpts.RevisionQuantization, datastoreOpts.GCWindow),
+		dispatchdepth.UnaryServerInterceptor(cobrautil.MustGetUint32(cmd, "dispatch-max-depth-limit")),
 		servicespecific.UnaryServerInterceptor,
 	)
 
 	streamMiddleware := grpc.ChainStreamInterceptor(
+		recovery.StreamServerInterceptor(panicHandler),
+		otelgrpc.StreamServerInterceptor(),
 		requestid.StreamServerInterceptor(requestid.GenerateIfMissing(true)),
 		logmw.StreamServerInterceptor(logmw.ExtractMetadataField("x-request-id", "requestID")),
+		priority.StreamServerInterceptor(),
 		grpclog.StreamServerInterceptor(grpczerolog.InterceptorLogger(log.Logger)),
-		otelgrpc.StreamServerInterceptor(),
-		grpcauth.StreamServerInterceptor(auth.RequirePresharedKey(token)),
+		grpcauth.StreamServerInterceptor(authFunc),
+		subjectbinding.StreamServerInterceptor(),
+		provenance.StreamServerInterceptor(),
+		datastoreid.StreamServerInterceptor(datastoreID),
 		grpcprom.StreamServerInterceptor,
+		dispatchdepth.StreamServerInterceptor(cobrautil.MustGetUint32(cmd, "dispatch-max-depth-limit")),
 		servicespecific.StreamServerInterceptor,
 	)
 
-	grpcServer, err := cobrautil.GrpcServerFromFlags(cmd, "grpc", middleware, streamMiddleware)
+	rateLimitConfig, err := rateLimitConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	deadlineConfig, err := deadlineConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Requests between the peers of the dispatch cluster are not rate limited, and inherit
+	// the deadlines of the requests which dispatched them, so both only apply to the API
+	// server. Neither are they audited, as they are the subproblems of audited requests.
+	limiter := ratelimit.NewLimiter(rateLimitConfig)
+	drainer := drain.NewDrainer()
+	shedder := loadshed.NewShedder(
+		loadshed.MaxInFlight(cobrautil.MustGetUint64(cmd, "grpc-load-shedding-max-in-flight")),
+		loadshed.MaxDispatchInFlight(cobrautil.MustGetUint64(cmd, "grpc-load-shedding-max-dispatch-in-flight")),
+		loadshed.MaxPoolWait(cobrautil.MustGetDuration(cmd, "grpc-load-shedding-max-datastore-pool-wait"), common.PoolAcquireWait),
+	)
+	includeChecks := audit.IncludeChecks(cobrautil.MustGetBool(cmd, "audit-log-include-checks"))
+	grpcServer, err := cobrautil.GrpcServerFromFlags(cmd, "grpc", middleware, streamMiddleware,
+		grpc.ChainUnaryInterceptor(audit.UnaryServerInterceptor(auditSink, includeChecks), deadline.UnaryServerInterceptor(deadlineConfig), ratelimit.UnaryServerInterceptor(limiter), loadshed.UnaryServerInterceptor(shedder)),
+		grpc.ChainStreamInterceptor(audit.StreamServerInterceptor(auditSink, includeChecks), deadline.StreamServerInterceptor(deadlineConfig), ratelimit.StreamServerInterceptor(limiter), loadshed.StreamServerInterceptor(shedder), drain.StreamServerInterceptor(drainer)),
+	)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to create gRPC server")
 	}
 
-	dispatchGrpcServer, err := cobrautil.GrpcServerFromFlags(cmd, "dispatch-cluster", middleware, streamMiddleware)
+	dispatchServerOpts := []grpc.ServerOption{
+		middleware,
+		streamMiddleware,
+		grpc.ChainUnaryInterceptor(loadshed.DispatchUnaryServerInterceptor(shedder)),
+		grpc.ChainStreamInterceptor(loadshed.DispatchStreamServerInterceptor(shedder)),
+	}
+	var dispatchCreds *mtls.Credentials
+	if certPath := cobrautil.MustGetStringExpanded(cmd, "dispatch-mtls-cert-path"); certPath != "" {
+		if cobrautil.MustGetStringExpanded(cmd, "dispatch-cluster-tls-cert-path") != "" {
+			return errors.New("only one of --dispatch-mtls-cert-path and --dispatch-cluster-tls-cert-path may be given")
+		}
+
+		dispatchCreds, err = mtls.NewCredentials(mtls.Config{
+			CertPath:       certPath,
+			KeyPath:        cobrautil.MustGetStringExpanded(cmd, "dispatch-mtls-key-path"),
+			CAPath:         cobrautil.MustGetStringExpanded(cmd, "dispatch-mtls-ca-path"),
+			AllowedPeerIDs: cobrautil.MustGetStringSlice(cmd, "dispatch-mtls-allowed-peer-ids"),
+		})
+		if err != nil {
+			return err
+		}
+		go dispatchCreds.Watch(ctx, cobrautil.MustGetDuration(cmd, "dispatch-mtls-reload-interval"))
+		dispatchServerOpts = append(dispatchServerOpts, grpc.Creds(dispatchCreds.ServerCredentials()))
+	}
+
+	dispatchGrpcServer, err := cobrautil.GrpcServerFromFlags(cmd, "dispatch-cluster", dispatchServerOpts...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to create redispatch gRPC server")
 	}
 
+	var upstreamCreds credentials.TransportCredentials
+	if dispatchCreds != nil {
+		upstreamCreds = dispatchCreds.ClientCredentials()
+	}
+
 	redispatch, err := combineddispatch.NewDispatcher(nsm, ds, dispatchGrpcServer,
 		combineddispatch.UpstreamAddr(cobrautil.MustGetStringExpanded(cmd, "dispatch-upstream-addr")),
+		combineddispatch.UpstreamPeers(cobrautil.MustGetStringSlice(cmd, "dispatch-upstream-peers")),
 		combineddispatch.UpstreamCAPath(cobrautil.MustGetStringExpanded(cmd, "dispatch-upstream-ca-path")),
+		combineddispatch.UpstreamCredentials(upstreamCreds),
 		combineddispatch.GrpcPresharedKey(cobrautil.MustGetStringExpanded(cmd, "grpc-preshared-key")),
+		combineddispatch.PrefetchChecks(cobrautil.MustGetBool(cmd, "dispatch-check-prefetch")),
+		combineddispatch.CacheConfig(&ristretto.Config{
+			NumCounters: int64(cobrautil.MustGetUint64(cmd, "dispatch-cache-num-counters")),
+			MaxCost:     int64(cobrautil.MustGetUint64(cmd, "dispatch-cache-max-cost")),
+			BufferItems: 64,
+			Metrics:     true,
+		}),
+		combineddispatch.HedgingQuantile(cobrautil.MustGetFloat64(cmd, "dispatch-hedging-quantile")),
+		combineddispatch.HedgingInitialSlowValue(cobrautil.MustGetDuration(cmd, "dispatch-hedging-initial-slow-value")),
+		combineddispatch.CacheTTL(time.Duration(cobrautil.MustGetUint32(cmd, "dispatch-cache-ttl-windows"))*datastoreOpts.RevisionQuantization),
 		combineddispatch.GrpcDialOpts(
 			grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
 			grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy":"consistent-hashring"}`),
@@ -214,15 +515,37 @@ func serveRun(ctx context.Context, cmd *cobra.Command, args []string, datastoreO
 		v1SchemaServiceOption = services.V1SchemaServiceDisabled
 	}
 
-	services.RegisterGrpcServices(
+	reflectionOption := services.ReflectionEnabled
+	if !cobrautil.MustGetBool(cmd, "grpc-enable-reflection") {
+		reflectionOption = services.ReflectionDisabled
+	}
+
+	healthSrv = services.RegisterGrpcServices(
 		grpcServer,
 		ds,
 		nsm,
 		redispatch,
 		cobrautil.MustGetUint32(cmd, "dispatch-max-depth"),
+		cobrautil.MustGetDuration(cmd, "grpc-watch-heartbeat-interval"),
 		prefixRequiredOption,
 		v1SchemaServiceOption,
+		reflectionOption,
 	)
+	if interval := cobrautil.MustGetDuration(cmd, "grpc-health-datastore-check-interval"); interval > 0 {
+		go services.NewDatastoreReadinessChecker(healthSrv, ds, interval).Run(ctx)
+	}
+
+	// Report not serving as soon as the shutdown signal is received, for the duration of
+	// the grace period.
+	signalctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	go func() {
+		select {
+		case <-signalctx.Done():
+			healthSrv.Shutdown()
+		case <-ctx.Done():
+		}
+	}()
 	go func() {
 		if err := cobrautil.GrpcListenFromFlags(cmd, "grpc", grpcServer, zerolog.InfoLevel); err != nil {
 			log.Fatal().Err(err).Msg("failed to start gRPC server")
@@ -254,13 +577,27 @@ func serveRun(ctx context.Context, cmd *cobra.Command, args []string, datastoreO
 
 	// Start the metrics endpoint.
 	metricsSrv := cobrautil.HttpServerFromFlags(cmd, "metrics")
-	metricsSrv.Handler = cmdutil.MetricsHandler()
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/", cmdutil.MetricsHandlerFromFlags(cmd))
+	metricsMux.Handle("/debug/dispatch/cache/flush", flushDispatchCacheHandler(redispatch))
+	metricsMux.Handle("/debug/diagnostics", diagnostics.NewHandler(datastoreOpts.Engine, ds, redispatch, cobrautil.MustGetDuration(cmd, "metrics-diagnostics-timeout")))
+	metricsSrv.Handler = metricsMux
 	go func() {
 		if err := cobrautil.HttpListenFromFlags(cmd, "metrics", metricsSrv, zerolog.InfoLevel); err != nil {
 			log.Fatal().Err(err).Msg("failed while serving metrics")
 		}
 	}()
 
+	// Push the metrics over OTLP, if configured.
+	if endpoint := cobrautil.MustGetStringExpanded(cmd, "metrics-otlp-endpoint"); endpoint != "" {
+		exporter, closeExporter, err := otlpExporterFromFlags(cmd, endpoint)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to configure otlp metrics")
+		}
+		defer closeExporter()
+		go exporter.Run(ctx, cobrautil.MustGetDuration(cmd, "metrics-otlp-interval"))
+	}
+
 	// Start a dashboard.
 	dashboardSrv := cobrautil.HttpServerFromFlags(cmd, "dashboard")
 	dashboardSrv.Handler = dashboard.NewHandler(
@@ -276,12 +613,27 @@ func serveRun(ctx context.Context, cmd *cobra.Command, args []string, datastoreO
 	}()
 
 	<-ctx.Done()
-	grpcServer.GracefulStop()
-	dispatchGrpcServer.GracefulStop()
-
-	if err := gatewaySrv.Close(); err != nil {
-		log.Fatal().Err(err).Msg("failed while shutting down rest gateway")
+	healthSrv.Shutdown()
+
+	// Watches would otherwise hold up the graceful stop until the drain timeout, so they
+	// are ended with a final checkpoint from which clients can resume.
+	drainer.Drain()
+
+	drainTimeout := cobrautil.MustGetDuration(cmd, "grpc-shutdown-drain-timeout")
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+	log.Info().Stringer("timeout", drainTimeout).Msg("draining in-flight requests")
+
+	// The gateway proxies to the gRPC server, so it is drained first, and the dispatch
+	// server last, as the requests of the others dispatch to it.
+	if err := gatewaySrv.Shutdown(drainCtx); err != nil {
+		log.Warn().Err(err).Msg("timed out draining rest gateway requests")
+		if err := gatewaySrv.Close(); err != nil {
+			log.Fatal().Err(err).Msg("failed while shutting down rest gateway")
+		}
 	}
+	stopGrpcServer(drainCtx, "grpc", grpcServer)
+	stopGrpcServer(drainCtx, "dispatch", dispatchGrpcServer)
 
 	if err := nsm.Close(); err != nil {
 		log.Fatal().Err(err).Msg("failed while shutting down namespace manager")
@@ -305,3 +657,123 @@ func serveRun(ctx context.Context, cmd *cobra.Command, args []string, datastoreO
 
 	return nil
 }
+
+// stopGrpcServer stops the server gracefully, waiting for in-flight requests to complete
+// until the context is done, after which the remaining ones are canceled.
+func stopGrpcServer(ctx context.Context, name string, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn().Str("server", name).Msg("timed out draining in-flight requests, canceling them")
+		srv.Stop()
+		<-stopped
+	}
+}
+
+// flushDispatchCacheHandler returns a handler which flushes the caches of the dispatcher
+// on POST, to debug results which appear stale.
+func flushDispatchCacheHandler(dispatcher combineddispatch.Dispatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		dispatcher.FlushCaches()
+		log.Info().Str("remoteAddr", r.RemoteAddr).Msg("flushed dispatch caches")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func rateLimitConfigFromFlags(cmd *cobra.Command) (ratelimit.Config, error) {
+	var config ratelimit.Config
+	limits := []struct {
+		flag  string
+		limit *ratelimit.Limit
+	}{
+		{"grpc-ratelimit-global", &config.Global},
+		{"grpc-ratelimit-per-key", &config.PerClient},
+		{"grpc-ratelimit-per-tenant", &config.PerTenant},
+	}
+	for _, l := range limits {
+		value := cobrautil.MustGetString(cmd, l.flag)
+		if value == "" {
+			continue
+		}
+		parsed, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid --%s: %w", l.flag, err)
+		}
+		*l.limit = parsed
+	}
+
+	overrides := []struct {
+		flag   string
+		limits *map[string]ratelimit.Limit
+	}{
+		{"grpc-ratelimit-keys", &config.KeyLimits},
+		{"grpc-ratelimit-tenants", &config.TenantLimits},
+	}
+	for _, o := range overrides {
+		values, err := cmd.Flags().GetStringToString(o.flag)
+		if err != nil {
+			return config, err
+		}
+		*o.limits = make(map[string]ratelimit.Limit, len(values))
+		for name, value := range values {
+			parsed, err := ratelimit.ParseLimit(value)
+			if err != nil {
+				return config, fmt.Errorf("invalid --%s for `%s`: %w", o.flag, name, err)
+			}
+			(*o.limits)[name] = parsed
+		}
+	}
+	return config, nil
+}
+
+func deadlineConfigFromFlags(cmd *cobra.Command) (deadline.Config, error) {
+	defaults, err := cmd.Flags().GetStringToString("grpc-default-deadlines")
+	if err != nil {
+		return nil, err
+	}
+	maximums, err := cmd.Flags().GetStringToString("grpc-max-deadlines")
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := deadline.ParseConfig(defaults, maximums)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --grpc-default-deadlines or --grpc-max-deadlines: %w", err)
+	}
+	return config, nil
+}
+
+func otlpExporterFromFlags(cmd *cobra.Command, endpoint string) (*otlpmetrics.Exporter, func(), error) {
+	headers, err := cmd.Flags().GetStringToString("metrics-otlp-headers")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if cobrautil.MustGetBool(cmd, "metrics-otlp-insecure") {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to connect to otlp endpoint: %w", err)
+	}
+
+	exporter := otlpmetrics.NewExporter(conn, prometheus.DefaultGatherer,
+		otlpmetrics.WithServiceName(cmd.Root().Use),
+		otlpmetrics.WithHeaders(headers),
+	)
+	return exporter, func() { _ = conn.Close() }, nil
+}
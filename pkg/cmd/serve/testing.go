@@ -34,22 +34,24 @@ import (
 	"github.com/authzed/spicedb/internal/middleware/servicespecific"
 	"github.com/authzed/spicedb/internal/namespace"
 	"github.com/authzed/spicedb/internal/services"
+	v1svc "github.com/authzed/spicedb/internal/services/v1"
 	v1alpha1svc "github.com/authzed/spicedb/internal/services/v1alpha1"
 	cmdutil "github.com/authzed/spicedb/pkg/cmd"
 	"github.com/authzed/spicedb/pkg/validationfile"
 )
 
 const (
-	gcWindow                = 1 * time.Hour
-	nsCacheExpiration       = 0 * time.Minute // No caching
-	maxDepth                = 50
-	revisionFuzzingDuration = 10 * time.Millisecond
+	gcWindow          = 1 * time.Hour
+	nsCacheExpiration = 0 * time.Minute // No caching
+	maxDepth          = 50
 )
 
 func RegisterTestingFlags(cmd *cobra.Command) {
 	cobrautil.RegisterGrpcServerFlags(cmd.Flags(), "grpc", "gRPC", ":50051", true)
 	cobrautil.RegisterGrpcServerFlags(cmd.Flags(), "readonly-grpc", "read-only gRPC", ":50052", true)
 	cmd.Flags().StringSlice("load-configs", []string{}, "configuration yaml files to load")
+	cmd.Flags().Duration("revision-quantization-interval", 10*time.Millisecond, "boundary interval to which to round the quantized revision")
+	cmd.Flags().Duration("datastore-idle-timeout", 1*time.Hour, "amount of time after the last request with a token after which its datastore is deleted (0 to keep datastores until shutdown)")
 }
 
 func NewTestingCommand(programName string) *cobra.Command {
@@ -65,9 +67,15 @@ func NewTestingCommand(programName string) *cobra.Command {
 func runTestServer(cmd *cobra.Command, args []string) error {
 	configFilePaths := cobrautil.MustGetStringSliceExpanded(cmd, "load-configs")
 
-	backendMiddleware := &perTokenBackendMiddleware{
-		&sync.Map{},
+	backendMiddleware := newPerTokenBackendMiddleware(
 		configFilePaths,
+		cobrautil.MustGetDuration(cmd, "revision-quantization-interval"),
+	)
+
+	signalctx, _ := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	if idleTimeout := cobrautil.MustGetDuration(cmd, "datastore-idle-timeout"); idleTimeout > 0 {
+		go backendMiddleware.collectIdle(signalctx, idleTimeout)
 	}
 
 	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
@@ -102,13 +110,12 @@ func runTestServer(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
-	signalctx, _ := signal.NotifyContext(context.Background(), os.Interrupt)
-
 	<-signalctx.Done()
 
 	log.Info().Msg("received interrupt")
 	grpcServer.GracefulStop()
 	readonlyServer.GracefulStop()
+	backendMiddleware.close()
 
 	return nil
 }
@@ -125,36 +132,135 @@ type v1DummyBackend struct {
 }
 
 type perTokenBackendMiddleware struct {
-	upstreamByToken *sync.Map
-	configFilePaths []string
+	configFilePaths      []string
+	revisionQuantization time.Duration
+
+	mu              sync.Mutex
+	upstreamByToken map[string]*upstream
+}
+
+func newPerTokenBackendMiddleware(configFilePaths []string, revisionQuantization time.Duration) *perTokenBackendMiddleware {
+	return &perTokenBackendMiddleware{
+		configFilePaths:      configFilePaths,
+		revisionQuantization: revisionQuantization,
+		upstreamByToken:      make(map[string]*upstream),
+	}
 }
 
 type upstream struct {
+	// initialize creates the datastore and clients of the upstream on first use.
+	initialize sync.Once
+	initErr    error
+
 	readonlyClients  map[string]interface{}
 	readwriteClients map[string]interface{}
+	closers          []func()
+
+	// inFlight and lastUsed are guarded by the lock of the middleware, so that upstreams
+	// are never collected while they serve requests.
+	inFlight int
+	lastUsed time.Time
+}
+
+func (u *upstream) close() {
+	for i := len(u.closers) - 1; i >= 0; i-- {
+		u.closers[i]()
+	}
 }
 
 var bypassServiceWhitelist = map[string]struct{}{
 	"/grpc.reflection.v1alpha.ServerReflection/": {},
 }
 
-func (ptbm *perTokenBackendMiddleware) methodForContextAndName(ctx context.Context, grpcMethodName string, forReadonly bool) (reflect.Value, error) {
+// acquire returns the upstream for the token of the request, creating it on first use,
+// and the function to call once the request completes.
+func (ptbm *perTokenBackendMiddleware) acquire(ctx context.Context) (*upstream, func(), error) {
 	// If this would have returned an error, we use the zero value of "" to
 	// create an isolated test server with no auth token required.
 	tokenStr, _ := grpcauth.AuthFromMD(ctx, "bearer")
-	untypedUpstream, hasUpstream := ptbm.upstreamByToken.Load(tokenStr)
-	if !hasUpstream {
+
+	ptbm.mu.Lock()
+	found, ok := ptbm.upstreamByToken[tokenStr]
+	if !ok {
+		found = &upstream{}
+		ptbm.upstreamByToken[tokenStr] = found
+	}
+	found.inFlight++
+	ptbm.mu.Unlock()
+
+	release := func() {
+		ptbm.mu.Lock()
+		defer ptbm.mu.Unlock()
+		found.inFlight--
+		found.lastUsed = time.Now()
+	}
+
+	found.initialize.Do(func() {
 		log.Info().Str("token", tokenStr).Msg("initializing new upstream for token")
-		var err error
-		untypedUpstream, err = ptbm.createUpstream()
-		if err != nil {
-			return reflect.Value{}, fmt.Errorf("unable to initialize upstream: %w", err)
+		found.initErr = ptbm.createUpstream(found)
+		if found.initErr != nil {
+			found.close()
+		}
+	})
+	if found.initErr != nil {
+		release()
+		ptbm.mu.Lock()
+		if ptbm.upstreamByToken[tokenStr] == found {
+			delete(ptbm.upstreamByToken, tokenStr)
+		}
+		ptbm.mu.Unlock()
+		return nil, nil, fmt.Errorf("unable to initialize upstream: %w", found.initErr)
+	}
+
+	return found, release, nil
+}
+
+// collectIdle deletes the upstreams of tokens which have not been used for the idle
+// timeout, until the context is canceled.
+func (ptbm *perTokenBackendMiddleware) collectIdle(ctx context.Context, idleTimeout time.Duration) {
+	ticker := time.NewTicker(idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, idle := range ptbm.removeIdle(time.Now().Add(-idleTimeout)) {
+				idle.close()
+			}
+		}
+	}
+}
+
+// removeIdle removes and returns the upstreams which serve no requests and were last used
+// before the cutoff.
+func (ptbm *perTokenBackendMiddleware) removeIdle(cutoff time.Time) []*upstream {
+	ptbm.mu.Lock()
+	defer ptbm.mu.Unlock()
+
+	var idle []*upstream
+	for token, found := range ptbm.upstreamByToken {
+		if found.inFlight == 0 && found.lastUsed.Before(cutoff) {
+			log.Info().Str("token", token).Msg("deleting idle upstream for token")
+			delete(ptbm.upstreamByToken, token)
+			idle = append(idle, found)
 		}
-		ptbm.upstreamByToken.Store(tokenStr, untypedUpstream)
 	}
+	return idle
+}
+
+func (ptbm *perTokenBackendMiddleware) close() {
+	ptbm.mu.Lock()
+	defer ptbm.mu.Unlock()
 
-	allClients := untypedUpstream.(*upstream)
+	for token, found := range ptbm.upstreamByToken {
+		delete(ptbm.upstreamByToken, token)
+		found.close()
+	}
+}
 
+func methodForName(allClients *upstream, grpcMethodName string, forReadonly bool) (reflect.Value, error) {
 	serviceName, methodName := splitMethodName(grpcMethodName)
 
 	client, ok := allClients.readwriteClients[serviceName]
@@ -176,28 +282,37 @@ type datastoreInfo struct {
 	isReadonly bool
 }
 
-func (ptbm *perTokenBackendMiddleware) createUpstream() (*upstream, error) {
-	readwriteDS, err := memdb.NewMemdbDatastore(0, revisionFuzzingDuration, gcWindow, 0)
+func (ptbm *perTokenBackendMiddleware) createUpstream(allClients *upstream) error {
+	readwriteDS, err := memdb.NewMemdbDatastore(0, ptbm.revisionQuantization, gcWindow, 0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to init datastore: %w", err)
+		return fmt.Errorf("failed to init datastore: %w", err)
 	}
+	allClients.closers = append(allClients.closers, func() {
+		if err := readwriteDS.Close(); err != nil {
+			log.Warn().Err(err).Msg("failed to close upstream datastore")
+		}
+	})
 
 	// Populate the datastore for any configuration files specified.
 	_, _, err = validationfile.PopulateFromFiles(readwriteDS, ptbm.configFilePaths)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load config files: %w", err)
+		return fmt.Errorf("failed to load config files: %w", err)
 	}
 
 	readonlyDS := proxy.NewReadonlyDatastore(readwriteDS)
 
-	allClients := &upstream{}
 	for _, dsInfo := range []datastoreInfo{{readwriteDS, false}, {readonlyDS, true}} {
 		ds := dsInfo.ds
 
 		nsm, err := namespace.NewCachingNamespaceManager(ds, nsCacheExpiration, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to initialize namespace manager: %w", err)
+			return fmt.Errorf("failed to initialize namespace manager: %w", err)
 		}
+		allClients.closers = append(allClients.closers, func() {
+			if err := nsm.Close(); err != nil {
+				log.Warn().Err(err).Msg("failed to close upstream namespace manager")
+			}
+		})
 
 		dispatch := graph.NewLocalOnlyDispatcher(nsm, ds)
 
@@ -212,8 +327,10 @@ func (ptbm *perTokenBackendMiddleware) createUpstream() (*upstream, error) {
 			nsm,
 			dispatch,
 			maxDepth,
+			v1svc.DefaultWatchHeartbeatInterval,
 			v1alpha1svc.PrefixNotRequired,
 			services.V1SchemaServiceEnabled,
+			services.ReflectionEnabled,
 		)
 
 		l := bufconn.Listen(1024 * 1024)
@@ -222,6 +339,7 @@ func (ptbm *perTokenBackendMiddleware) createUpstream() (*upstream, error) {
 				log.Warn().Err(err).Msg("proxy gRPC service did not shutdown cleanly")
 			}
 		}()
+		allClients.closers = append(allClients.closers, grpcServer.Stop)
 
 		conn, err := grpc.DialContext(
 			context.Background(),
@@ -232,8 +350,11 @@ func (ptbm *perTokenBackendMiddleware) createUpstream() (*upstream, error) {
 			grpc.WithTransportCredentials(insecure.NewCredentials()),
 		)
 		if err != nil {
-			return nil, fmt.Errorf("error creating client for new upstream: %w", err)
+			return fmt.Errorf("error creating client for new upstream: %w", err)
 		}
+		allClients.closers = append(allClients.closers, func() {
+			_ = conn.Close()
+		})
 
 		clients := map[string]interface{}{
 			"authzed.api.v0.ACLService":          v0.NewACLServiceClient(conn),
@@ -250,7 +371,7 @@ func (ptbm *perTokenBackendMiddleware) createUpstream() (*upstream, error) {
 		}
 	}
 
-	return allClients, nil
+	return nil
 }
 
 // UnaryServerInterceptor returns a new unary server interceptor that performs per-request exchange of
@@ -263,7 +384,13 @@ func (ptbm *perTokenBackendMiddleware) UnaryServerInterceptor(forReadonly bool)
 			}
 		}
 
-		clientMethod, err := ptbm.methodForContextAndName(ctx, info.FullMethod, forReadonly)
+		allClients, release, err := ptbm.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		clientMethod, err := methodForName(allClients, info.FullMethod, forReadonly)
 		if err != nil {
 			return nil, err
 		}
@@ -301,7 +428,13 @@ func (ptbm *perTokenBackendMiddleware) StreamServerInterceptor(forReadonly bool)
 			panic(fmt.Sprintf("client streaming unsupported for method: %s", info.FullMethod))
 		}
 
-		clientMethod, err := ptbm.methodForContextAndName(ctx, info.FullMethod, forReadonly)
+		allClients, release, err := ptbm.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		clientMethod, err := methodForName(allClients, info.FullMethod, forReadonly)
 		if err != nil {
 			return err
 		}
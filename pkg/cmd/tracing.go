@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jzelinskie/cobrautil"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+// RegisterTracingFlags adds the flags configuring the exporter and the sampling of traces
+// to the persistent flags of the command.
+func RegisterTracingFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.String("otel-provider", "none", `exporter of traces ("none", "otlp", "jaeger", "stdout")`)
+	flags.String("otel-endpoint", "", "endpoint of the collector to which traces are exported: a gRPC address for otlp, or the collector URL for jaeger")
+	flags.Bool("otel-insecure", false, "export traces to the otlp endpoint without TLS")
+	flags.String("otel-service-name", cmd.Use, "service name of the exported traces")
+	flags.String("otel-sampler", "parent-based", `sampler of traces: "always", "never", "ratio" to sample --otel-sample-ratio of traces, or "parent-based" to follow the decision of the caller and otherwise sample by ratio`)
+	flags.Float64("otel-sample-ratio", 1, "ratio of traces sampled by the ratio and parent-based samplers")
+	flags.StringToString("otel-method-sample-ratios", map[string]string{}, `ratios of traces sampled for gRPC methods or services, overriding --otel-sample-ratio, e.g. "authzed.api.v1.PermissionsService/CheckPermission=0.01"`)
+}
+
+// TracingPreRunE configures the global tracer provider as set by the flags registered by
+// RegisterTracingFlags.
+func TracingPreRunE(cmd *cobra.Command, _ []string) error {
+	provider := strings.ToLower(cobrautil.MustGetString(cmd, "otel-provider"))
+	if provider == "none" {
+		return nil
+	}
+
+	methodRatioStrings, err := cmd.Flags().GetStringToString("otel-method-sample-ratios")
+	if err != nil {
+		return err
+	}
+	methodRatios := make(map[string]float64, len(methodRatioStrings))
+	for method, ratioString := range methodRatioStrings {
+		ratio, err := strconv.ParseFloat(ratioString, 64)
+		if err != nil {
+			return fmt.Errorf("invalid sample ratio for %s: %w", method, err)
+		}
+		methodRatios[method] = ratio
+	}
+
+	sampler, err := NewSampler(
+		cobrautil.MustGetString(cmd, "otel-sampler"),
+		cobrautil.MustGetFloat64(cmd, "otel-sample-ratio"),
+		methodRatios,
+	)
+	if err != nil {
+		return err
+	}
+
+	endpoint := cobrautil.MustGetStringExpanded(cmd, "otel-endpoint")
+	var exporter sdktrace.SpanExporter
+	switch provider {
+	case "otlp":
+		opts := []otlptracegrpc.Option{}
+		if endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+		}
+		if cobrautil.MustGetBool(cmd, "otel-insecure") {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(context.Background(), opts...)
+
+	case "jaeger":
+		opts := []jaeger.CollectorEndpointOption{}
+		if endpoint != "" {
+			opts = append(opts, jaeger.WithEndpoint(endpoint))
+		}
+		exporter, err = jaeger.New(jaeger.WithCollectorEndpoint(opts...))
+
+	case "stdout":
+		// Traces are written to stderr, so that they are not mixed with the output of commands.
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+
+	default:
+		return fmt.Errorf("unknown tracing provider: %s", provider)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to create %s trace exporter: %w", provider, err)
+	}
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(cobrautil.MustGetString(cmd, "otel-service-name")),
+		)),
+	))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	log.Info().Str("provider", provider).Str("sampler", sampler.Description()).Msg("configured tracing")
+	return nil
+}
+
+// NewSampler creates the sampler of the kind ("always", "never", "ratio" or "parent-based"),
+// which samples the spans of the gRPC methods or services in methodRatios with their ratio
+// instead. The names of methods are those of their spans, e.g.
+// "authzed.api.v1.PermissionsService/CheckPermission".
+func NewSampler(kind string, ratio float64, methodRatios map[string]float64) (sdktrace.Sampler, error) {
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("sample ratio must be between 0 and 1: %v", ratio)
+	}
+
+	var root sdktrace.Sampler
+	switch kind {
+	case "always":
+		root = sdktrace.AlwaysSample()
+	case "never":
+		root = sdktrace.NeverSample()
+	case "ratio", "parent-based":
+		root = sdktrace.TraceIDRatioBased(ratio)
+	default:
+		return nil, fmt.Errorf("unknown sampler: %s", kind)
+	}
+
+	if len(methodRatios) > 0 {
+		overrides := make(map[string]sdktrace.Sampler, len(methodRatios))
+		for method, methodRatio := range methodRatios {
+			if methodRatio < 0 || methodRatio > 1 {
+				return nil, fmt.Errorf("sample ratio for %s must be between 0 and 1: %v", method, methodRatio)
+			}
+			overrides[strings.TrimPrefix(method, "/")] = sdktrace.TraceIDRatioBased(methodRatio)
+		}
+		root = methodSampler{fallback: root, overrides: overrides}
+	}
+
+	if kind == "parent-based" {
+		return sdktrace.ParentBased(root), nil
+	}
+	return root, nil
+}
+
+// methodSampler samples the spans of gRPC methods, and of the methods of gRPC services,
+// with the samplers overriding them, and other spans with the fallback.
+type methodSampler struct {
+	fallback  sdktrace.Sampler
+	overrides map[string]sdktrace.Sampler
+}
+
+func (ms methodSampler) ShouldSample(parameters sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if sampler, ok := ms.overrides[parameters.Name]; ok {
+		return sampler.ShouldSample(parameters)
+	}
+	if i := strings.LastIndex(parameters.Name, "/"); i >= 0 {
+		if sampler, ok := ms.overrides[parameters.Name[:i]]; ok {
+			return sampler.ShouldSample(parameters)
+		}
+	}
+	return ms.fallback.ShouldSample(parameters)
+}
+
+func (ms methodSampler) Description() string {
+	return fmt.Sprintf("MethodSampler{%s,overrides:%d}", ms.fallback.Description(), len(ms.overrides))
+}
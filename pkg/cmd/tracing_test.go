@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewSampler(t *testing.T) {
+	traceID := trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+
+	testCases := []struct {
+		name         string
+		kind         string
+		ratio        float64
+		methodRatios map[string]float64
+		spanName     string
+		expected     sdktrace.SamplingDecision
+	}{
+		{"always", "always", 0, nil, "authzed.api.v1.PermissionsService/CheckPermission", sdktrace.RecordAndSample},
+		{"never", "never", 1, nil, "authzed.api.v1.PermissionsService/CheckPermission", sdktrace.Drop},
+		{"ratio of zero", "ratio", 0, nil, "authzed.api.v1.PermissionsService/CheckPermission", sdktrace.Drop},
+		{"ratio of one", "parent-based", 1, nil, "authzed.api.v1.PermissionsService/CheckPermission", sdktrace.RecordAndSample},
+		{
+			"method override",
+			"parent-based", 0,
+			map[string]float64{"/authzed.api.v1.PermissionsService/CheckPermission": 1},
+			"authzed.api.v1.PermissionsService/CheckPermission",
+			sdktrace.RecordAndSample,
+		},
+		{
+			"other method",
+			"parent-based", 0,
+			map[string]float64{"authzed.api.v1.PermissionsService/CheckPermission": 1},
+			"authzed.api.v1.PermissionsService/LookupResources",
+			sdktrace.Drop,
+		},
+		{
+			"service override",
+			"ratio", 1,
+			map[string]float64{"authzed.api.v1.PermissionsService": 0},
+			"authzed.api.v1.PermissionsService/LookupResources",
+			sdktrace.Drop,
+		},
+		{
+			"method overrides service",
+			"ratio", 0,
+			map[string]float64{"authzed.api.v1.PermissionsService": 0, "authzed.api.v1.PermissionsService/CheckPermission": 1},
+			"authzed.api.v1.PermissionsService/CheckPermission",
+			sdktrace.RecordAndSample,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sampler, err := NewSampler(tc.kind, tc.ratio, tc.methodRatios)
+			require.NoError(t, err)
+
+			result := sampler.ShouldSample(sdktrace.SamplingParameters{
+				ParentContext: context.Background(),
+				TraceID:       traceID,
+				Name:          tc.spanName,
+			})
+			require.Equal(t, tc.expected, result.Decision)
+		})
+	}
+}
+
+func TestNewSamplerErrors(t *testing.T) {
+	_, err := NewSampler("sometimes", 1, nil)
+	require.Error(t, err)
+
+	_, err = NewSampler("ratio", 2, nil)
+	require.Error(t, err)
+
+	_, err = NewSampler("ratio", 1, map[string]float64{"authzed.api.v1.PermissionsService": -1})
+	require.Error(t, err)
+}
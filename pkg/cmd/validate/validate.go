@@ -0,0 +1,151 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	v0svc "github.com/authzed/spicedb/internal/services/v0"
+	cmdutil "github.com/authzed/spicedb/pkg/cmd"
+	"github.com/authzed/spicedb/pkg/validationfile"
+)
+
+func RegisterValidateFlags(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig) {
+	cmdutil.RegisterDatastoreFlags(cmd, dsConfig)
+}
+
+func NewCommand(programName string, dsConfig *cmdutil.DatastoreConfig) *cobra.Command {
+	return &cobra.Command{
+		Use:     "validate <validation file>...",
+		Short:   "check validation files against the data of a datastore",
+		Long:    "Checks the assertions and expected relations of validation files against the schema and relationships in a datastore, printing how the relations computed from the data differ from those expected. Exits with an error if any check fails.",
+		PreRunE: cmdutil.DefaultPreRunE(programName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validateRun(cmd, dsConfig, args)
+		},
+		Args: cobra.MinimumNArgs(1),
+	}
+}
+
+func validateRun(cmd *cobra.Command, dsConfig *cmdutil.DatastoreConfig, paths []string) error {
+	ctx := context.Background()
+
+	expectationsByPath := make(map[string]validationfile.Expectations, len(paths))
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read validation file: %w", err)
+		}
+
+		expectations, err := validationfile.ParseExpectations(contents)
+		if err != nil {
+			return fmt.Errorf("invalid validation file %s: %w", path, err)
+		}
+		expectationsByPath[path] = expectations
+	}
+
+	// Validation is read-only, so the datastore must not collect garbage in the background.
+	dsConfig.GCInterval = 0
+	ds, err := cmdutil.NewDatastore(dsConfig.ToOption())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init datastore")
+	}
+	defer ds.Close()
+
+	// Every file is checked at the same revision, so that they see the same data.
+	revision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	var failed int
+	for _, path := range paths {
+		expectations := expectationsByPath[path]
+		failures, computed, err := v0svc.RunExpectations(ctx, ds, revision, expectations)
+		if err != nil {
+			return fmt.Errorf("unable to validate %s: %w", path, err)
+		}
+
+		if len(failures) == 0 {
+			continue
+		}
+		printFailures(out, path, failures)
+		printExpectedRelationsDiff(out, path, expectations.ExpectedRelations, computed)
+		failed += len(failures)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed at revision %s", failed, revision)
+	}
+	fmt.Fprintf(out, "all checks passed at revision %s\n", revision)
+	return nil
+}
+
+func printFailures(out io.Writer, path string, failures []*v0.DeveloperError) {
+	for _, failure := range failures {
+		if failure.Line > 0 {
+			fmt.Fprintf(out, "%s:%d:%d: %s\n", path, failure.Line, failure.Column, failure.Message)
+		} else {
+			fmt.Fprintf(out, "%s: %s\n", path, failure.Message)
+		}
+	}
+}
+
+// printExpectedRelationsDiff prints, for each object and relation whose expected relations
+// differ from those computed from the data, the lines of the validation block which are
+// only expected, prefixed by "-", and only computed, prefixed by "+".
+func printExpectedRelationsDiff(out io.Writer, path string, expected, computed validationfile.ValidationMap) {
+	keys := make([]string, 0, len(expected))
+	for key := range expected {
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		onr := validationfile.ObjectRelationString(key)
+		removed, added := diffValidationStrings(expected[onr], computed[onr])
+		if len(removed) == 0 && len(added) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(out, "%s: expected relations of %s differ from the data (-expected +computed):\n", path, key)
+		for _, line := range removed {
+			fmt.Fprintf(out, "  - %s\n", line)
+		}
+		for _, line := range added {
+			fmt.Fprintf(out, "  + %s\n", line)
+		}
+	}
+}
+
+func diffValidationStrings(expected, computed []validationfile.ValidationString) (removed, added []string) {
+	expectedSet := make(map[validationfile.ValidationString]struct{}, len(expected))
+	for _, str := range expected {
+		expectedSet[str] = struct{}{}
+	}
+
+	computedSet := make(map[validationfile.ValidationString]struct{}, len(computed))
+	for _, str := range computed {
+		computedSet[str] = struct{}{}
+		if _, ok := expectedSet[str]; !ok {
+			added = append(added, string(str))
+		}
+	}
+
+	for _, str := range expected {
+		if _, ok := computedSet[str]; !ok {
+			removed = append(removed, string(str))
+		}
+	}
+
+	sort.Strings(removed)
+	sort.Strings(added)
+	return removed, added
+}
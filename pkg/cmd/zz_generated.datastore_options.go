@@ -1,7 +1,10 @@
 // Code generated by github.com/ecordell/optgen. DO NOT EDIT.
 package cmd
 
-import "time"
+import (
+	clock "github.com/benbjohnson/clock"
+	"time"
+)
 
 type DatastoreConfigOption func(d *DatastoreConfig)
 
@@ -85,6 +88,20 @@ func WithSplitQuerySize(splitQuerySize string) DatastoreConfigOption {
 	}
 }
 
+// WithClock returns an option that can set Clock on a DatastoreConfig
+func WithClock(clock clock.Clock) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.Clock = clock
+	}
+}
+
+// WithMaxClockSkew returns an option that can set MaxClockSkew on a DatastoreConfig
+func WithMaxClockSkew(maxClockSkew time.Duration) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.MaxClockSkew = maxClockSkew
+	}
+}
+
 // WithFollowerReadDelay returns an option that can set FollowerReadDelay on a DatastoreConfig
 func WithFollowerReadDelay(followerReadDelay time.Duration) DatastoreConfigOption {
 	return func(d *DatastoreConfig) {
@@ -133,3 +150,108 @@ func WithGCMaxOperationTime(gCMaxOperationTime time.Duration) DatastoreConfigOpt
 		d.GCMaxOperationTime = gCMaxOperationTime
 	}
 }
+
+// WithLowPriorityMaxOpenConns returns an option that can set LowPriorityMaxOpenConns on a DatastoreConfig
+func WithLowPriorityMaxOpenConns(lowPriorityMaxOpenConns int) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.LowPriorityMaxOpenConns = lowPriorityMaxOpenConns
+	}
+}
+
+// WithQueryHints returns an option that can set QueryHints on a DatastoreConfig
+func WithQueryHints(queryHints map[string]string) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.QueryHints = queryHints
+	}
+}
+
+// WithQueryTimeout returns an option that can set QueryTimeout on a DatastoreConfig
+func WithQueryTimeout(queryTimeout time.Duration) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.QueryTimeout = queryTimeout
+	}
+}
+
+// WithPoolerCompat returns an option that can set PoolerCompat on a DatastoreConfig
+func WithPoolerCompat(poolerCompat bool) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.PoolerCompat = poolerCompat
+	}
+}
+
+// WithFailoverCheckInterval returns an option that can set FailoverCheckInterval on a DatastoreConfig
+func WithFailoverCheckInterval(failoverCheckInterval time.Duration) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.FailoverCheckInterval = failoverCheckInterval
+	}
+}
+
+// WithSlowQueryThreshold returns an option that can set SlowQueryThreshold on a DatastoreConfig
+func WithSlowQueryThreshold(slowQueryThreshold time.Duration) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.SlowQueryThreshold = slowQueryThreshold
+	}
+}
+
+// WithSlowQueryExplainSampleRate returns an option that can set SlowQueryExplainSampleRate on a DatastoreConfig
+func WithSlowQueryExplainSampleRate(slowQueryExplainSampleRate float64) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.SlowQueryExplainSampleRate = slowQueryExplainSampleRate
+	}
+}
+
+// WithPingInterval returns an option that can set PingInterval on a DatastoreConfig
+func WithPingInterval(pingInterval time.Duration) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.PingInterval = pingInterval
+	}
+}
+
+// WithMemdbPersistPath returns an option that can set MemdbPersistPath on a DatastoreConfig
+func WithMemdbPersistPath(memdbPersistPath string) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.MemdbPersistPath = memdbPersistPath
+	}
+}
+
+// WithMemdbSnapshotInterval returns an option that can set MemdbSnapshotInterval on a DatastoreConfig
+func WithMemdbSnapshotInterval(memdbSnapshotInterval time.Duration) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.MemdbSnapshotInterval = memdbSnapshotInterval
+	}
+}
+
+// WithSplitQueryParameterCount returns an option that can set SplitQueryParameterCount on a DatastoreConfig
+func WithSplitQueryParameterCount(splitQueryParameterCount int) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.SplitQueryParameterCount = splitQueryParameterCount
+	}
+}
+
+// WithMaxUsersetsPerQuery returns an option that can set MaxUsersetsPerQuery on a DatastoreConfig
+func WithMaxUsersetsPerQuery(maxUsersetsPerQuery int) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.MaxUsersetsPerQuery = maxUsersetsPerQuery
+	}
+}
+
+// WithShardURIs returns an option that can set ShardURIs on a DatastoreConfig
+func WithShardURIs(shardURIs map[string]string) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.ShardURIs = shardURIs
+	}
+}
+
+// WithShardRoutes returns an option that can set ShardRoutes on a DatastoreConfig
+func WithShardRoutes(shardRoutes map[string]string) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.ShardRoutes = shardRoutes
+	}
+}
+
+// WithDisableStats returns an option that can set DisableStats on a DatastoreConfig
+func WithDisableStats(disableStats bool) DatastoreConfigOption {
+	return func(d *DatastoreConfig) {
+		d.DisableStats = disableStats
+	}
+}
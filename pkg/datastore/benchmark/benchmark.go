@@ -0,0 +1,377 @@
+// Package benchmark runs standardized workloads against datastores, measuring latencies
+// and throughputs which are comparable between the datastore drivers.
+//
+// The workloads write their schema and relationships to the datastore, under namespaces
+// prefixed with "benchmark_", so they should only be run against dedicated datastores.
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/namespace"
+	dispatchv1 "github.com/authzed/spicedb/internal/proto/dispatch/v1"
+	ns "github.com/authzed/spicedb/pkg/namespace"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	userNamespace     = "benchmark_user"
+	folderNamespace   = "benchmark_folder"
+	documentNamespace = "benchmark_document"
+
+	// writeBatchSize is the number of relationships written at once when setting up
+	// workloads, which is below the write limits of every datastore.
+	writeBatchSize = 100
+)
+
+var schema = []*v0.NamespaceDefinition{
+	ns.Namespace(userNamespace),
+	resourceNamespace(folderNamespace),
+	resourceNamespace(documentNamespace),
+}
+
+// resourceNamespace defines a namespace whose view permission is granted to its viewers,
+// and to those who can view its parent folder.
+func resourceNamespace(name string) *v0.NamespaceDefinition {
+	return ns.Namespace(
+		name,
+		ns.Relation("parent", nil, ns.AllowedRelation(folderNamespace, tuple.Ellipsis)),
+		ns.Relation("viewer", nil, ns.AllowedRelation(userNamespace, tuple.Ellipsis)),
+		ns.Relation("view", ns.Union(
+			ns.ComputedUserset("viewer"),
+			ns.TupleToUserset("parent", "view"),
+		)),
+	)
+}
+
+// Config sizes the standard workloads.
+type Config struct {
+	// FanOut is the number of relationships read by each operation of the fan-out and
+	// reverse lookup workloads.
+	FanOut int
+
+	// Depth is the number of nested folders traversed by each check of the deep check
+	// workload.
+	Depth int
+
+	// BatchSize is the number of relationships written by each operation of the bulk
+	// write workload.
+	BatchSize int
+}
+
+// DefaultConfig is the configuration of the standard workloads used unless specified.
+var DefaultConfig = Config{
+	FanOut:    1000,
+	Depth:     10,
+	BatchSize: 100,
+}
+
+// Workload is a standardized workload, made of identical operations against a datastore.
+type Workload struct {
+	// Name identifies the workload.
+	Name string
+
+	setup     func(ctx context.Context, ds datastore.Datastore) error
+	operation func(ctx context.Context, t target, i int) error
+}
+
+type target struct {
+	ds         datastore.Datastore
+	dispatcher dispatch.Dispatcher
+	revision   datastore.Revision
+}
+
+// Workloads returns the standard workloads sized by the config:
+//
+// - fan-out-read reads all the viewers of a document
+// - deep-check checks the view permission of a user on a document through nested folders
+// - bulk-write writes batches of new relationships
+// - reverse-lookup reads all the documents a user can view
+func Workloads(config Config) []Workload {
+	return []Workload{
+		{
+			Name: "fan-out-read",
+			setup: func(ctx context.Context, ds datastore.Datastore) error {
+				tuples := make([]*v0.RelationTuple, 0, config.FanOut)
+				for i := 0; i < config.FanOut; i++ {
+					tuples = append(tuples, relationship(documentNamespace, "fanout", "viewer", userNamespace, fmt.Sprintf("fanout_%d", i)))
+				}
+				return write(ctx, ds, tuples)
+			},
+			operation: func(ctx context.Context, t target, _ int) error {
+				iter, err := t.ds.QueryTuples(ctx, &v1.RelationshipFilter{
+					ResourceType:       documentNamespace,
+					OptionalResourceId: "fanout",
+					OptionalRelation:   "viewer",
+				}, t.revision)
+				if err != nil {
+					return err
+				}
+				return drain(iter, config.FanOut)
+			},
+		},
+		{
+			Name: "deep-check",
+			setup: func(ctx context.Context, ds datastore.Datastore) error {
+				tuples := []*v0.RelationTuple{
+					relationship(folderNamespace, "nested_0", "viewer", userNamespace, "deep"),
+					relationship(documentNamespace, "deep", "parent", folderNamespace, fmt.Sprintf("nested_%d", config.Depth-1)),
+				}
+				for i := 1; i < config.Depth; i++ {
+					tuples = append(tuples, relationship(folderNamespace, fmt.Sprintf("nested_%d", i), "parent", folderNamespace, fmt.Sprintf("nested_%d", i-1)))
+				}
+				return write(ctx, ds, tuples)
+			},
+			operation: func(ctx context.Context, t target, _ int) error {
+				resp, err := t.dispatcher.DispatchCheck(ctx, &dispatchv1.DispatchCheckRequest{
+					Metadata: &dispatchv1.ResolverMeta{
+						AtRevision:     t.revision.String(),
+						DepthRemaining: uint32(4*config.Depth + 10),
+					},
+					ObjectAndRelation: tuple.ObjectAndRelation(documentNamespace, "deep", "view"),
+					Subject:           tuple.ObjectAndRelation(userNamespace, "deep", tuple.Ellipsis),
+				})
+				if err != nil {
+					return err
+				}
+				if resp.Membership != dispatchv1.DispatchCheckResponse_MEMBER {
+					return fmt.Errorf("unexpected membership: %s", resp.Membership)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "bulk-write",
+			setup: func(ctx context.Context, ds datastore.Datastore) error { return nil },
+			operation: func(ctx context.Context, t target, i int) error {
+				updates := make([]*v1.RelationshipUpdate, 0, config.BatchSize)
+				for j := 0; j < config.BatchSize; j++ {
+					updates = append(updates, &v1.RelationshipUpdate{
+						Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+						Relationship: tuple.MustToRelationship(relationship(documentNamespace, fmt.Sprintf("bulk_%d", i), "viewer", userNamespace, fmt.Sprintf("bulk_%d", j))),
+					})
+				}
+				_, err := t.ds.WriteTuples(ctx, nil, updates)
+				return err
+			},
+		},
+		{
+			Name: "reverse-lookup",
+			setup: func(ctx context.Context, ds datastore.Datastore) error {
+				tuples := make([]*v0.RelationTuple, 0, config.FanOut)
+				for i := 0; i < config.FanOut; i++ {
+					tuples = append(tuples, relationship(documentNamespace, fmt.Sprintf("reverse_%d", i), "viewer", userNamespace, "reverse"))
+				}
+				return write(ctx, ds, tuples)
+			},
+			operation: func(ctx context.Context, t target, _ int) error {
+				iter, err := t.ds.ReverseQueryTuples(ctx, &v1.SubjectFilter{
+					SubjectType:       userNamespace,
+					OptionalSubjectId: "reverse",
+				}, t.revision, options.WithResRelation(&options.ResourceRelation{
+					Namespace: documentNamespace,
+					Relation:  "viewer",
+				}))
+				if err != nil {
+					return err
+				}
+				return drain(iter, config.FanOut)
+			},
+		},
+	}
+}
+
+// Result is the measurements of a run of a workload.
+type Result struct {
+	Workload    string
+	Operations  int
+	Concurrency int
+	Duration    time.Duration
+	P50         time.Duration
+	P95         time.Duration
+	P99         time.Duration
+	Max         time.Duration
+}
+
+// Throughput returns the number of operations per second.
+func (r Result) Throughput() float64 {
+	return float64(r.Operations) / r.Duration.Seconds()
+}
+
+// Run sets up the workload in the datastore, then runs the number of operations of the
+// workload over concurrency goroutines, returning the measurements of the operations.
+func Run(ctx context.Context, ds datastore.Datastore, workload Workload, operations, concurrency int) (Result, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	t, closer, err := setup(ctx, ds, workload)
+	if err != nil {
+		return Result{}, err
+	}
+	defer closer()
+
+	latencies := make([]time.Duration, operations)
+	errs := make(chan error, concurrency)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for g := 0; g < concurrency; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				operationStart := time.Now()
+				if err := workload.operation(ctx, t, i); err != nil {
+					errs <- fmt.Errorf("operation %d of %s failed: %w", i, workload.Name, err)
+					return
+				}
+				latencies[i] = time.Since(operationStart)
+			}
+		}()
+	}
+
+	var runErr error
+	for i := 0; i < operations && runErr == nil; i++ {
+		select {
+		case indexes <- i:
+		case runErr = <-errs:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	if runErr == nil && len(errs) > 0 {
+		runErr = <-errs
+	}
+	if runErr != nil {
+		return Result{}, runErr
+	}
+
+	result := Result{
+		Workload:    workload.Name,
+		Operations:  operations,
+		Concurrency: concurrency,
+		Duration:    time.Since(start),
+	}
+	if operations > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		result.P50 = percentile(latencies, 0.5)
+		result.P95 = percentile(latencies, 0.95)
+		result.P99 = percentile(latencies, 0.99)
+		result.Max = latencies[len(latencies)-1]
+	}
+	return result, nil
+}
+
+// RunBenchmarks runs the standard workloads as sub-benchmarks, each operation of which is
+// an operation of the workload.
+func RunBenchmarks(b *testing.B, ds datastore.Datastore) {
+	ctx := context.Background()
+	for _, workload := range Workloads(DefaultConfig) {
+		workload := workload
+		b.Run(workload.Name, func(b *testing.B) {
+			t, closer, err := setup(ctx, ds, workload)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer closer()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := workload.operation(ctx, t, i); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func setup(ctx context.Context, ds datastore.Datastore, workload Workload) (target, func(), error) {
+	for _, def := range schema {
+		if _, err := ds.WriteNamespace(ctx, def); err != nil {
+			return target{}, nil, fmt.Errorf("unable to write benchmark schema: %w", err)
+		}
+	}
+
+	if err := workload.setup(ctx, ds); err != nil {
+		return target{}, nil, fmt.Errorf("unable to set up %s: %w", workload.Name, err)
+	}
+
+	revision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return target{}, nil, err
+	}
+
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 0, nil)
+	if err != nil {
+		return target{}, nil, err
+	}
+
+	return target{
+		ds:         ds,
+		dispatcher: graph.NewLocalOnlyDispatcher(nsm, ds),
+		revision:   revision,
+	}, func() { nsm.Close() }, nil
+}
+
+func relationship(resourceType, resourceID, relation, subjectType, subjectID string) *v0.RelationTuple {
+	return &v0.RelationTuple{
+		ObjectAndRelation: tuple.ObjectAndRelation(resourceType, resourceID, relation),
+		User:              tuple.User(tuple.ObjectAndRelation(subjectType, subjectID, tuple.Ellipsis)),
+	}
+}
+
+func write(ctx context.Context, ds datastore.Datastore, tuples []*v0.RelationTuple) error {
+	for start := 0; start < len(tuples); start += writeBatchSize {
+		end := start + writeBatchSize
+		if end > len(tuples) {
+			end = len(tuples)
+		}
+
+		updates := make([]*v1.RelationshipUpdate, 0, end-start)
+		for _, tpl := range tuples[start:end] {
+			updates = append(updates, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+				Relationship: tuple.MustToRelationship(tpl),
+			})
+		}
+		if _, err := ds.WriteTuples(ctx, nil, updates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drain reads all the tuples of the iterator, which must be the expected number.
+func drain(iter datastore.TupleIterator, expected int) error {
+	defer iter.Close()
+
+	var count int
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		count++
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if count != expected {
+		return fmt.Errorf("read %d relationships, expected %d", count, expected)
+	}
+	return nil
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
@@ -0,0 +1,32 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+)
+
+func TestRun(t *testing.T) {
+	config := Config{FanOut: 20, Depth: 5, BatchSize: 10}
+
+	for _, workload := range Workloads(config) {
+		workload := workload
+		t.Run(workload.Name, func(t *testing.T) {
+			require := require.New(t)
+
+			ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+			require.NoError(err)
+			defer ds.Close()
+
+			result, err := Run(context.Background(), ds, workload, 50, 4)
+			require.NoError(err)
+			require.Equal(workload.Name, result.Workload)
+			require.Equal(50, result.Operations)
+			require.True(result.P50 <= result.P95 && result.P95 <= result.P99 && result.P99 <= result.Max)
+			require.Greater(result.Throughput(), 0.0)
+		})
+	}
+}
@@ -1,3 +1,8 @@
+// Package test contains the conformance tests of datastores, covering writes and
+// preconditions, queries and reverse queries, namespaces, watches, revision fuzzing and
+// the expiration of revisions outside the garbage collection window.
+//
+// Datastore implementations verify their compliance by calling RunAll from a test.
 package test
 
 import (
@@ -25,12 +30,22 @@ type DatastoreTester interface {
 	New(revisionFuzzingTimedelta, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error)
 }
 
-// All runs all generic datastore tests on a DatastoreTester.
-func All(t *testing.T, tester DatastoreTester) {
+// DatastoreBuilder is an adapter allowing a function to be used as a DatastoreTester.
+type DatastoreBuilder func(revisionFuzzingTimedelta, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error)
+
+// New calls the function.
+func (db DatastoreBuilder) New(revisionFuzzingTimedelta, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error) {
+	return db(revisionFuzzingTimedelta, gcWindow, watchBufferLength)
+}
+
+// RunAll runs all generic datastore tests on a DatastoreTester, each against a new
+// datastore.
+func RunAll(t *testing.T, tester DatastoreTester) {
 	t.Run("TestSimple", func(t *testing.T) { SimpleTest(t, tester) })
 	t.Run("TestRevisionFuzzing", func(t *testing.T) { RevisionFuzzingTest(t, tester) })
 	t.Run("TestWritePreconditions", func(t *testing.T) { WritePreconditionsTest(t, tester) })
 	t.Run("TestDeletePreconditions", func(t *testing.T) { DeletePreconditionsTest(t, tester) })
+	t.Run("TestFilterPreconditions", func(t *testing.T) { FilterPreconditionsTest(t, tester) })
 	t.Run("TestDeleteRelationships", func(t *testing.T) { DeleteRelationshipsTest(t, tester) })
 	t.Run("TestInvalidReads", func(t *testing.T) { InvalidReadsTest(t, tester) })
 	t.Run("TestNamespaceWrite", func(t *testing.T) { NamespaceWriteTest(t, tester) })
@@ -38,7 +53,12 @@ func All(t *testing.T, tester DatastoreTester) {
 	t.Run("TestEmptyNamespaceDelete", func(t *testing.T) { EmptyNamespaceDeleteTest(t, tester) })
 	t.Run("TestWatch", func(t *testing.T) { WatchTest(t, tester) })
 	t.Run("TestWatchCancel", func(t *testing.T) { WatchCancelTest(t, tester) })
+	t.Run("TestWatchFiltered", func(t *testing.T) { WatchFilteredTest(t, tester) })
 	t.Run("TestUsersets", func(t *testing.T) { UsersetsTest(t, tester) })
+	t.Run("TestOrdering", func(t *testing.T) { OrderingTest(t, tester) })
+	t.Run("TestReverseQuerySubjectIDs", func(t *testing.T) { ReverseQuerySubjectIDsTest(t, tester) })
+	t.Run("TestBulkLoad", func(t *testing.T) { BulkLoadTest(t, tester) })
+	t.Run("TestReverseQueryAtRevision", func(t *testing.T) { ReverseQueryAtRevisionTest(t, tester) })
 }
 
 var testResourceNS = namespace.Namespace(
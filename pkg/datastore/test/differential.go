@@ -0,0 +1,274 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/testutil"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	differentialDefinitions   = 3
+	differentialRelations     = 3
+	differentialRelationships = 200
+	differentialObjects       = 8
+	differentialMaxBatch      = 10
+)
+
+// revisionPair is the revisions of the oracle and the subject after the same write.
+type revisionPair struct {
+	oracle  datastore.Revision
+	subject datastore.Revision
+}
+
+// differentialRun holds the state of a differential test of a subject datastore against
+// an oracle.
+type differentialRun struct {
+	t       *testing.T
+	rand    *rand.Rand
+	oracle  datastore.Datastore
+	subject datastore.Datastore
+
+	schema        []*v0.NamespaceDefinition
+	relationships []*v0.RelationTuple
+	revisions     []revisionPair
+	step          int
+}
+
+// RunDifferential replays the same randomized sequence of schema writes, relationship
+// writes and deletes, and reads at current and past revisions against a datastore of the
+// oracle and one of the subject, failing on any difference between their results. The
+// sequence is determined by the seed, so failures are reproduced by running with the
+// seed logged by the test.
+func RunDifferential(t *testing.T, oracle, subject DatastoreTester, seed int64, steps int) {
+	t.Logf("differential test seed: %d", seed)
+
+	oracleDS, err := oracle.New(0, veryLargeGCWindow, 1)
+	require.NoError(t, err)
+	defer oracleDS.Close()
+
+	subjectDS, err := subject.New(0, veryLargeGCWindow, 1)
+	require.NoError(t, err)
+	defer subjectDS.Close()
+
+	generator := testutil.NewGenerator(seed)
+	schema := generator.Schema(differentialDefinitions, differentialRelations, differentialRelations)
+	run := &differentialRun{
+		t:             t,
+		rand:          rand.New(rand.NewSource(seed)),
+		oracle:        oracleDS,
+		subject:       subjectDS,
+		schema:        schema,
+		relationships: generator.Relationships(schema, differentialRelationships, differentialObjects),
+	}
+
+	for _, def := range schema {
+		run.writeNamespace(def)
+	}
+
+	operations := []func(){
+		run.writeRelationships,
+		run.writeRelationships,
+		run.deleteRelationships,
+		run.deleteByFilter,
+		run.rewriteNamespace,
+		run.queryCurrent,
+		run.queryCurrent,
+		run.queryPast,
+		run.reverseQuery,
+	}
+	for run.step = 0; run.step < steps; run.step++ {
+		operations[run.rand.Intn(len(operations))]()
+	}
+}
+
+func (dr *differentialRun) describe() string {
+	return fmt.Sprintf("step %d", dr.step)
+}
+
+// recordWrite requires that both datastores failed or succeeded in the same write, and
+// that the revisions of successful writes never decrease.
+func (dr *differentialRun) recordWrite(oracleRevision datastore.Revision, oracleErr error, subjectRevision datastore.Revision, subjectErr error) {
+	require.Equal(dr.t, oracleErr == nil, subjectErr == nil, "%s: oracle error %v, subject error %v", dr.describe(), oracleErr, subjectErr)
+	if oracleErr != nil {
+		return
+	}
+
+	if len(dr.revisions) > 0 {
+		last := dr.revisions[len(dr.revisions)-1]
+		require.True(dr.t, oracleRevision.GreaterThanOrEqual(last.oracle), "%s: oracle revision decreased", dr.describe())
+		require.True(dr.t, subjectRevision.GreaterThanOrEqual(last.subject), "%s: subject revision %s decreased from %s", dr.describe(), subjectRevision, last.subject)
+	}
+	dr.revisions = append(dr.revisions, revisionPair{oracleRevision, subjectRevision})
+}
+
+func (dr *differentialRun) writeNamespace(def *v0.NamespaceDefinition) {
+	ctx := context.Background()
+	oracleRevision, oracleErr := dr.oracle.WriteNamespace(ctx, def)
+	subjectRevision, subjectErr := dr.subject.WriteNamespace(ctx, def)
+	dr.recordWrite(oracleRevision, oracleErr, subjectRevision, subjectErr)
+}
+
+func (dr *differentialRun) writeRelationships() {
+	operation := v1.RelationshipUpdate_OPERATION_TOUCH
+	if dr.rand.Intn(3) == 0 {
+		// Creates of existing relationships fail, which both datastores must agree on.
+		operation = v1.RelationshipUpdate_OPERATION_CREATE
+	}
+	dr.update(operation)
+}
+
+func (dr *differentialRun) deleteRelationships() {
+	dr.update(v1.RelationshipUpdate_OPERATION_DELETE)
+}
+
+func (dr *differentialRun) update(operation v1.RelationshipUpdate_Operation) {
+	count := 1 + dr.rand.Intn(differentialMaxBatch)
+	seen := make(map[string]struct{}, count)
+	updates := make([]*v1.RelationshipUpdate, 0, count)
+	for i := 0; i < count; i++ {
+		tpl := dr.relationships[dr.rand.Intn(len(dr.relationships))]
+		if _, ok := seen[tuple.String(tpl)]; ok {
+			continue
+		}
+		seen[tuple.String(tpl)] = struct{}{}
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    operation,
+			Relationship: tuple.MustToRelationship(tpl),
+		})
+	}
+
+	ctx := context.Background()
+	oracleRevision, oracleErr := dr.oracle.WriteTuples(ctx, nil, updates)
+	subjectRevision, subjectErr := dr.subject.WriteTuples(ctx, nil, updates)
+	dr.recordWrite(oracleRevision, oracleErr, subjectRevision, subjectErr)
+}
+
+func (dr *differentialRun) deleteByFilter() {
+	filter := dr.randomFilter()
+
+	ctx := context.Background()
+	oracleRevision, oracleErr := dr.oracle.DeleteRelationships(ctx, nil, filter)
+	subjectRevision, subjectErr := dr.subject.DeleteRelationships(ctx, nil, filter)
+	dr.recordWrite(oracleRevision, oracleErr, subjectRevision, subjectErr)
+}
+
+func (dr *differentialRun) rewriteNamespace() {
+	def := dr.schema[dr.rand.Intn(len(dr.schema))]
+	dr.writeNamespace(def)
+
+	latest := dr.latest()
+	ctx := context.Background()
+	oracleDef, _, oracleErr := dr.oracle.ReadNamespace(ctx, def.Name, latest.oracle)
+	subjectDef, _, subjectErr := dr.subject.ReadNamespace(ctx, def.Name, latest.subject)
+	require.NoError(dr.t, oracleErr, dr.describe())
+	require.NoError(dr.t, subjectErr, dr.describe())
+	require.True(dr.t, proto.Equal(oracleDef, subjectDef), "%s: namespace %s differs", dr.describe(), def.Name)
+}
+
+func (dr *differentialRun) queryCurrent() {
+	ctx := context.Background()
+	oracleRevision, err := dr.oracle.HeadRevision(ctx)
+	require.NoError(dr.t, err, dr.describe())
+	subjectRevision, err := dr.subject.HeadRevision(ctx)
+	require.NoError(dr.t, err, dr.describe())
+
+	dr.query(revisionPair{oracleRevision, subjectRevision})
+}
+
+func (dr *differentialRun) queryPast() {
+	if len(dr.revisions) == 0 {
+		return
+	}
+
+	past := dr.revisions[dr.rand.Intn(len(dr.revisions))]
+	ctx := context.Background()
+	require.NoError(dr.t, dr.oracle.CheckRevision(ctx, past.oracle), dr.describe())
+	require.NoError(dr.t, dr.subject.CheckRevision(ctx, past.subject), "%s: subject revision %s", dr.describe(), past.subject)
+
+	dr.query(past)
+}
+
+func (dr *differentialRun) query(revisions revisionPair) {
+	filter := dr.randomFilter()
+
+	ctx := context.Background()
+	oracleIter, oracleErr := dr.oracle.QueryTuples(ctx, filter, revisions.oracle)
+	subjectIter, subjectErr := dr.subject.QueryTuples(ctx, filter, revisions.subject)
+	dr.requireSameResults(oracleIter, oracleErr, subjectIter, subjectErr)
+}
+
+func (dr *differentialRun) reverseQuery() {
+	tpl := dr.relationships[dr.rand.Intn(len(dr.relationships))]
+	subject := tpl.User.GetUserset()
+	filter := &v1.SubjectFilter{SubjectType: subject.Namespace}
+	if dr.rand.Intn(2) == 0 {
+		filter.OptionalSubjectId = subject.ObjectId
+	}
+
+	var opts []options.ReverseQueryOptionsOption
+	if dr.rand.Intn(2) == 0 {
+		opts = append(opts, options.WithResRelation(&options.ResourceRelation{
+			Namespace: tpl.ObjectAndRelation.Namespace,
+			Relation:  tpl.ObjectAndRelation.Relation,
+		}))
+	}
+
+	latest := dr.latest()
+	ctx := context.Background()
+	oracleIter, oracleErr := dr.oracle.ReverseQueryTuples(ctx, filter, latest.oracle, opts...)
+	subjectIter, subjectErr := dr.subject.ReverseQueryTuples(ctx, filter, latest.subject, opts...)
+	dr.requireSameResults(oracleIter, oracleErr, subjectIter, subjectErr)
+}
+
+func (dr *differentialRun) latest() revisionPair {
+	return dr.revisions[len(dr.revisions)-1]
+}
+
+// randomFilter returns a filter of the resource type of a relationship, and optionally of
+// its resource ID and relation.
+func (dr *differentialRun) randomFilter() *v1.RelationshipFilter {
+	onr := dr.relationships[dr.rand.Intn(len(dr.relationships))].ObjectAndRelation
+	filter := &v1.RelationshipFilter{ResourceType: onr.Namespace}
+	if dr.rand.Intn(2) == 0 {
+		filter.OptionalResourceId = onr.ObjectId
+	}
+	if dr.rand.Intn(2) == 0 {
+		filter.OptionalRelation = onr.Relation
+	}
+	return filter
+}
+
+func (dr *differentialRun) requireSameResults(oracleIter datastore.TupleIterator, oracleErr error, subjectIter datastore.TupleIterator, subjectErr error) {
+	require.NoError(dr.t, oracleErr, dr.describe())
+	require.NoError(dr.t, subjectErr, dr.describe())
+
+	oracleResults := readAll(dr.t, oracleIter)
+	subjectResults := readAll(dr.t, subjectIter)
+	require.Equal(dr.t, oracleResults, subjectResults, "%s: results differ", dr.describe())
+}
+
+// readAll returns the sorted strings of the tuples of the iterator.
+func readAll(t *testing.T, iter datastore.TupleIterator) []string {
+	defer iter.Close()
+
+	results := []string{}
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		results = append(results, tuple.String(tpl))
+	}
+	require.NoError(t, iter.Err())
+
+	sort.Strings(results)
+	return results
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"testing"
 	"time"
@@ -393,6 +394,125 @@ func WritePreconditionsTest(t *testing.T, tester DatastoreTester) {
 	require.NoError(err)
 }
 
+// FilterPreconditionsTest tests whether preconditions with subject filters and resource
+// ID prefixes hold for a particular datastore.
+func FilterPreconditionsTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	ds, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+	defer ds.Close()
+
+	setupDatastore(ds, require)
+
+	ctx := context.Background()
+
+	_, err = ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{{
+		Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+		Relationship: tuple.MustToRelationship(makeTestTuple("first_doc", "owner")),
+	}})
+	require.NoError(err)
+
+	second := tuple.MustToRelationship(makeTestTuple("second", "owner"))
+
+	testCases := []struct {
+		name         string
+		precondition *v1.Precondition
+		expectFailed bool
+	}{
+		{
+			"prefix matches",
+			&v1.Precondition{
+				Operation: v1.Precondition_OPERATION_MUST_MATCH,
+				Filter: datastore.WithResourceIDPrefix(&v1.RelationshipFilter{
+					ResourceType:       testResourceNamespace,
+					OptionalResourceId: "first",
+				}),
+			},
+			false,
+		},
+		{
+			"prefix must not match",
+			&v1.Precondition{
+				Operation: v1.Precondition_OPERATION_MUST_NOT_MATCH,
+				Filter: datastore.WithResourceIDPrefix(&v1.RelationshipFilter{
+					ResourceType:       testResourceNamespace,
+					OptionalResourceId: "first",
+				}),
+			},
+			true,
+		},
+		{
+			"prefix does not match",
+			&v1.Precondition{
+				Operation: v1.Precondition_OPERATION_MUST_MATCH,
+				Filter: datastore.WithResourceIDPrefix(&v1.RelationshipFilter{
+					ResourceType:       testResourceNamespace,
+					OptionalResourceId: "sec",
+				}),
+			},
+			true,
+		},
+		{
+			"prefix is matched literally",
+			&v1.Precondition{
+				Operation: v1.Precondition_OPERATION_MUST_MATCH,
+				Filter: datastore.WithResourceIDPrefix(&v1.RelationshipFilter{
+					ResourceType:       testResourceNamespace,
+					OptionalResourceId: "f_rst",
+				}),
+			},
+			true,
+		},
+		{
+			"prefix and subject match",
+			&v1.Precondition{
+				Operation: v1.Precondition_OPERATION_MUST_MATCH,
+				Filter: datastore.WithResourceIDPrefix(&v1.RelationshipFilter{
+					ResourceType:       testResourceNamespace,
+					OptionalResourceId: "first_",
+					OptionalRelation:   testReaderRelation,
+					OptionalSubjectFilter: &v1.SubjectFilter{
+						SubjectType:       testUserNamespace,
+						OptionalSubjectId: "owner",
+					},
+				}),
+			},
+			false,
+		},
+		{
+			"subject does not match",
+			&v1.Precondition{
+				Operation: v1.Precondition_OPERATION_MUST_MATCH,
+				Filter: &v1.RelationshipFilter{
+					ResourceType: testResourceNamespace,
+					OptionalSubjectFilter: &v1.SubjectFilter{
+						SubjectType:       testUserNamespace,
+						OptionalSubjectId: "someoneelse",
+					},
+				},
+			},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			_, err := ds.WriteTuples(ctx, []*v1.Precondition{tc.precondition}, []*v1.RelationshipUpdate{{
+				Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+				Relationship: second,
+			}})
+			if tc.expectFailed {
+				require.True(errors.As(err, &datastore.ErrPreconditionFailed{}))
+			} else {
+				require.NoError(err)
+			}
+		})
+	}
+}
+
 // DeletePreconditionsTest tests whether or not the requirements for checking
 // preconditions via DeleteRelationships hold for a particular datastore.
 func DeletePreconditionsTest(t *testing.T, tester DatastoreTester) {
@@ -607,6 +727,20 @@ func InvalidReadsTest(t *testing.T, tester DatastoreTester) {
 		require.True(errors.As(err, &revisionErr))
 		require.Equal(datastore.RevisionStale, revisionErr.Reason())
 
+		oldest, newest, ok := revisionErr.ValidWindow()
+		require.True(ok)
+		require.True(oldest.GreaterThan(firstWrite))
+		require.True(newest.GreaterThanOrEqual(nextWrite))
+
+		// Check that queries at the old revision fail, rather than returning partial results
+		_, err = ds.QueryTuples(ctx, &v1.RelationshipFilter{ResourceType: testResourceNamespace}, firstWrite)
+		require.True(errors.As(err, &revisionErr))
+		require.Equal(datastore.RevisionStale, revisionErr.Reason())
+
+		_, err = ds.ReverseQueryTuples(ctx, &v1.SubjectFilter{SubjectType: testUserNamespace}, firstWrite)
+		require.True(errors.As(err, &revisionErr))
+		require.Equal(datastore.RevisionStale, revisionErr.Reason())
+
 		// Check that we can't read a revision that's ahead of the latest
 		err = ds.CheckRevision(ctx, nextWrite.Add(decimal.NewFromInt(1_000_000_000)))
 		require.True(errors.As(err, &revisionErr))
@@ -670,3 +804,221 @@ func UsersetsTest(t *testing.T, tester DatastoreTester) {
 		}
 	})
 }
+
+// OrderingTest tests that tuple queries return tuples in the requested order, comparing
+// object IDs bytewise, and that they can be resumed after a resource ID.
+func OrderingTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	ds, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+	defer ds.Close()
+
+	setupDatastore(ds, require)
+	ctx := context.Background()
+
+	var testTuples []*v0.RelationTuple
+	var usersets []*v0.ObjectAndRelation
+	var updates []*v1.RelationshipUpdate
+	for _, resourceID := range []string{"b", "A", "c"} {
+		for _, userID := range []string{"tom", "Fred", "amy"} {
+			newTuple := makeTestTuple(resourceID, userID)
+			testTuples = append(testTuples, newTuple)
+			if resourceID == "b" {
+				usersets = append(usersets, newTuple.User.GetUserset())
+			}
+			updates = append(updates, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+				Relationship: tuple.MustToRelationship(newTuple),
+			})
+		}
+	}
+
+	revision, err := ds.WriteTuples(ctx, nil, updates)
+	require.NoError(err)
+
+	limit := uint64(4)
+	for _, order := range []options.TupleOrder{options.ByResource, options.BySubject} {
+		expected := make([]*v0.RelationTuple, len(testTuples))
+		copy(expected, testTuples)
+		sort.Slice(expected, func(i, j int) bool {
+			return order.Less(expected[i], expected[j])
+		})
+
+		var afterA []*v0.RelationTuple
+		for _, tpl := range expected {
+			if tpl.ObjectAndRelation.ObjectId > "A" {
+				afterA = append(afterA, tpl)
+			}
+		}
+
+		for _, tc := range []struct {
+			name     string
+			opts     []options.QueryOptionsOption
+			expected []*v0.RelationTuple
+		}{
+			{"all", nil, expected},
+			{"limited", []options.QueryOptionsOption{options.WithLimit(&limit)}, expected[:limit]},
+			{"usersets", []options.QueryOptionsOption{options.SetUsersets(usersets)}, expected},
+			{"after resource", []options.QueryOptionsOption{options.WithAfterResourceID("A")}, afterA},
+			{"after resource limited", []options.QueryOptionsOption{options.WithAfterResourceID("A"), options.WithLimit(&limit)}, afterA[:limit]},
+		} {
+			t.Run(fmt.Sprintf("%d/%s", order, tc.name), func(t *testing.T) {
+				require := require.New(t)
+
+				iter, err := ds.QueryTuples(ctx, &v1.RelationshipFilter{
+					ResourceType: testResourceNamespace,
+				}, revision, append(tc.opts, options.WithOrder(order))...)
+				require.NoError(err)
+				defer iter.Close()
+
+				var found []string
+				for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+					found = append(found, tuple.String(tpl))
+				}
+				require.NoError(iter.Err())
+
+				expectedStrings := make([]string, 0, len(tc.expected))
+				for _, tpl := range tc.expected {
+					expectedStrings = append(expectedStrings, tuple.String(tpl))
+				}
+				require.Equal(expectedStrings, found)
+			})
+		}
+	}
+}
+
+// ReverseQuerySubjectIDsTest tests that reverse queries can be filtered to a set of subject
+// IDs.
+func ReverseQuerySubjectIDsTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	ds, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+	defer ds.Close()
+
+	setupDatastore(ds, require)
+	ctx := context.Background()
+	tRequire := testfixtures.TupleChecker{Require: require, DS: ds}
+
+	tuplesBySubject := map[string][]*v0.RelationTuple{}
+	var updates []*v1.RelationshipUpdate
+	for _, resourceID := range []string{"first", "second"} {
+		for _, userID := range []string{"tom", "fred", "amy", "sarah"} {
+			newTuple := makeTestTuple(resourceID, userID)
+			tuplesBySubject[userID] = append(tuplesBySubject[userID], newTuple)
+			updates = append(updates, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+				Relationship: tuple.MustToRelationship(newTuple),
+			})
+		}
+	}
+
+	revision, err := ds.WriteTuples(ctx, nil, updates)
+	require.NoError(err)
+
+	iter, err := ds.ReverseQueryTuples(ctx, &v1.SubjectFilter{
+		SubjectType: testUserNamespace,
+	}, revision, options.SetSubjectIDs([]string{"tom", "amy", "unknown"}))
+	require.NoError(err)
+	tRequire.VerifyIteratorResults(iter, append(tuplesBySubject["tom"], tuplesBySubject["amy"]...)...)
+
+	iter, err = ds.ReverseQueryTuples(ctx, &v1.SubjectFilter{
+		SubjectType: testUserNamespace,
+	}, revision,
+		options.SetSubjectIDs([]string{"fred", "sarah"}),
+		options.WithResRelation(&options.ResourceRelation{
+			Namespace: testResourceNamespace,
+			Relation:  testReaderRelation,
+		}),
+	)
+	require.NoError(err)
+	tRequire.VerifyIteratorResults(iter, append(tuplesBySubject["fred"], tuplesBySubject["sarah"]...)...)
+
+	iter, err = ds.ReverseQueryTuples(ctx, &v1.SubjectFilter{
+		SubjectType:       testUserNamespace,
+		OptionalSubjectId: "tom",
+	}, revision, options.SetSubjectIDs([]string{"fred"}))
+	require.NoError(err)
+	tRequire.VerifyIteratorResults(iter)
+}
+
+// BulkLoadTest tests that relationships can be bulk loaded, by the datastore's bulk loader if
+// it has one.
+func BulkLoadTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	ds, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+	defer ds.Close()
+
+	startRevision := setupDatastore(ds, require)
+	ctx := context.Background()
+	tRequire := testfixtures.TupleChecker{Require: require, DS: ds}
+
+	var testTuples []*v0.RelationTuple
+	var relationships []*v1.Relationship
+	for i := 0; i < 1000; i++ {
+		newTuple := makeTestTuple(fmt.Sprintf("resource%d", i%10), fmt.Sprintf("user%d", i))
+		testTuples = append(testTuples, newTuple)
+		relationships = append(relationships, tuple.MustToRelationship(newTuple))
+	}
+
+	revision, err := datastore.BulkLoad(ctx, ds, relationships)
+	require.NoError(err)
+	require.True(revision.GreaterThan(startRevision))
+
+	iter, err := ds.QueryTuples(ctx, &v1.RelationshipFilter{
+		ResourceType: testResourceNamespace,
+	}, revision)
+	require.NoError(err)
+	tRequire.VerifyIteratorResults(iter, testTuples...)
+
+	// Relationships which already exist cannot be bulk loaded again.
+	_, err = datastore.BulkLoad(ctx, ds, relationships[:1])
+	require.Error(err)
+}
+
+// ReverseQueryAtRevisionTest tests that reverse queries only find the tuples which were live
+// at the revision at which they are made.
+func ReverseQueryAtRevisionTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	ds, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+	defer ds.Close()
+
+	setupDatastore(ds, require)
+	ctx := context.Background()
+	tRequire := testfixtures.TupleChecker{Require: require, DS: ds}
+
+	first := makeTestTuple("first", "tom")
+	firstRevision, err := ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{{
+		Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+		Relationship: tuple.MustToRelationship(first),
+	}})
+	require.NoError(err)
+
+	second := makeTestTuple("second", "tom")
+	secondRevision, err := ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{
+		{
+			Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+			Relationship: tuple.MustToRelationship(second),
+		},
+		{
+			Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+			Relationship: tuple.MustToRelationship(first),
+		},
+	})
+	require.NoError(err)
+
+	subjectFilter := &v1.SubjectFilter{SubjectType: testUserNamespace, OptionalSubjectId: "tom"}
+
+	iter, err := ds.ReverseQueryTuples(ctx, subjectFilter, firstRevision)
+	require.NoError(err)
+	tRequire.VerifyIteratorResults(iter, first)
+
+	iter, err = ds.ReverseQueryTuples(ctx, subjectFilter, secondRevision)
+	require.NoError(err)
+	tRequire.VerifyIteratorResults(iter, second)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
 	"github.com/authzed/spicedb/pkg/tuple"
 )
 
@@ -105,7 +106,8 @@ func verifyUpdates(
 	errchan <-chan error,
 	expectDisconnect bool,
 ) {
-	for _, expected := range testUpdates {
+	for i := 0; i < len(testUpdates); {
+		expected := testUpdates[i]
 		changeWait := time.NewTimer(5 * time.Second)
 		select {
 		case change, ok := <-changes:
@@ -122,9 +124,15 @@ func verifyUpdates(
 				return
 			}
 
+			// Events without changes only report the progress of the watch.
+			if len(change.Changes) == 0 {
+				continue
+			}
+
 			expectedChangeSet := setOfChangesRel(expected)
 			actualChangeSet := setOfChanges(change.Changes)
 			require.True(expectedChangeSet.IsEqual(actualChangeSet))
+			i++
 		case <-changeWait.C:
 			require.Fail("Timed out")
 		}
@@ -176,6 +184,9 @@ func WatchCancelTest(t *testing.T, tester DatastoreTester) {
 		select {
 		case created, ok := <-changes:
 			if ok {
+				if len(created.Changes) == 0 {
+					continue
+				}
 				require.Equal(
 					[]*v0.RelationTupleUpdate{tuple.Touch(makeTestTuple("test", "test"))},
 					created.Changes,
@@ -198,3 +209,60 @@ func WatchCancelTest(t *testing.T, tester DatastoreTester) {
 		}
 	}
 }
+
+// WatchFilteredTest tests whether filtered watches of a particular datastore only report
+// the changes of the relationships matching their filters.
+func WatchFilteredTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	ds, err := tester.New(0, veryLargeGCWindow, 16)
+	require.NoError(err)
+
+	startWatchRevision := setupDatastore(ds, require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, errchan := ds.Watch(ctx, startWatchRevision, options.WithFilters(&v1.RelationshipFilter{
+		ResourceType:       testResourceNamespace,
+		OptionalResourceId: "matching",
+	}))
+	require.Zero(len(errchan))
+
+	_, err = ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{{
+		Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+		Relationship: makeTestRelationship("other", "user"),
+	}})
+	require.NoError(err)
+
+	matchingRevision, err := ds.WriteTuples(ctx, nil, []*v1.RelationshipUpdate{{
+		Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+		Relationship: makeTestRelationship("matching", "user"),
+	}})
+	require.NoError(err)
+
+	// Events without changes may report the progress of the watch past the filtered out
+	// write, but no event may contain its change.
+	timeout := time.NewTimer(5 * time.Second)
+	defer timeout.Stop()
+	for {
+		select {
+		case change, ok := <-changes:
+			require.True(ok, "watch closed before the matching change was received")
+			if len(change.Changes) == 0 {
+				continue
+			}
+
+			require.Equal(
+				[]*v0.RelationTupleUpdate{tuple.Touch(makeTestTuple("matching", "user"))},
+				change.Changes,
+			)
+			require.True(change.Revision.Equal(matchingRevision))
+			return
+		case err := <-errchan:
+			require.FailNow("unexpected watch error", err)
+		case <-timeout.C:
+			require.FailNow("timed out waiting for the matching change")
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package graphexport exports the type graph of a schema, and the relationships around an
+// object, in formats which can be read by graph visualization tools.
+package graphexport
+
+import (
+	"context"
+	"fmt"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/options"
+)
+
+// NodeKind is the kind of the entity represented by a node.
+type NodeKind string
+
+const (
+	// NodeKindType is an object type of the schema.
+	NodeKindType NodeKind = "type"
+
+	// NodeKindObject is an object found in relationships.
+	NodeKindObject NodeKind = "object"
+)
+
+// Node is a node of an exported graph.
+type Node struct {
+	ID   string
+	Kind NodeKind
+}
+
+// Edge is a directed edge of an exported graph, from a resource to a subject.
+type Edge struct {
+	From  string
+	To    string
+	Label string
+}
+
+// Graph is a graph of object types or of objects, and the relations between them.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+
+	// Truncated is set if the graph was cut short by a bound on its size.
+	Truncated bool
+
+	nodes map[string]struct{}
+	edges map[Edge]struct{}
+}
+
+func newGraph() *Graph {
+	return &Graph{
+		nodes: make(map[string]struct{}),
+		edges: make(map[Edge]struct{}),
+	}
+}
+
+// addNode adds a node, returning false if it was already present.
+func (g *Graph) addNode(id string, kind NodeKind) bool {
+	if _, ok := g.nodes[id]; ok {
+		return false
+	}
+	g.nodes[id] = struct{}{}
+	g.Nodes = append(g.Nodes, Node{ID: id, Kind: kind})
+	return true
+}
+
+func (g *Graph) addEdge(edge Edge) {
+	if _, ok := g.edges[edge]; ok {
+		return
+	}
+	g.edges[edge] = struct{}{}
+	g.Edges = append(g.Edges, edge)
+}
+
+// SchemaGraph builds the type graph of the given compiled definitions. Each relation adds
+// an edge to every subject type which can be written to it, and each permission walking a
+// relation to another type (`parent->view`) adds an edge to the types reachable through
+// that relation.
+func SchemaGraph(definitions []*v0.NamespaceDefinition) *Graph {
+	g := newGraph()
+
+	relations := make(map[string]*v0.Relation)
+	for _, def := range definitions {
+		g.addNode(def.Name, NodeKindType)
+		for _, relation := range def.Relation {
+			relations[def.Name+"#"+relation.Name] = relation
+		}
+	}
+
+	for _, def := range definitions {
+		for _, relation := range def.Relation {
+			for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+				g.addNode(allowed.Namespace, NodeKindType)
+				g.addEdge(Edge{
+					From:  def.Name,
+					To:    allowed.Namespace,
+					Label: relation.Name + allowedRelationSuffix(allowed),
+				})
+			}
+
+			if relation.UsersetRewrite != nil {
+				addArrowEdges(g, relations, def.Name, relation.Name, relation.UsersetRewrite)
+			}
+		}
+	}
+
+	return g
+}
+
+func addArrowEdges(g *Graph, relations map[string]*v0.Relation, namespaceName, permission string, rewrite *v0.UsersetRewrite) {
+	var setOp *v0.SetOperation
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *v0.UsersetRewrite_Union:
+		setOp = rw.Union
+	case *v0.UsersetRewrite_Intersection:
+		setOp = rw.Intersection
+	case *v0.UsersetRewrite_Exclusion:
+		setOp = rw.Exclusion
+	default:
+		return
+	}
+
+	for _, child := range setOp.Child {
+		switch typed := child.ChildType.(type) {
+		case *v0.SetOperation_Child_UsersetRewrite:
+			addArrowEdges(g, relations, namespaceName, permission, typed.UsersetRewrite)
+
+		case *v0.SetOperation_Child_TupleToUserset:
+			tupleset, ok := relations[namespaceName+"#"+typed.TupleToUserset.Tupleset.Relation]
+			if !ok {
+				continue
+			}
+
+			label := fmt.Sprintf("%s (%s->%s)", permission, typed.TupleToUserset.Tupleset.Relation, typed.TupleToUserset.ComputedUserset.Relation)
+			for _, allowed := range tupleset.GetTypeInformation().GetAllowedDirectRelations() {
+				g.addEdge(Edge{From: namespaceName, To: allowed.Namespace, Label: label})
+			}
+		}
+	}
+}
+
+func allowedRelationSuffix(allowed *v0.AllowedRelation) string {
+	if allowed.GetPublicWildcard() != nil {
+		return " (*)"
+	}
+	if allowed.GetRelation() != "" && allowed.GetRelation() != datastore.Ellipsis {
+		return fmt.Sprintf(" (#%s)", allowed.GetRelation())
+	}
+	return ""
+}
+
+// Neighborhood builds the graph of the relationships within the specified number of hops
+// of the object with the given type and ID, following relationships both to their subjects
+// and to their resources. Once maxRelationships relationships are loaded, no more are read
+// and the graph is marked as truncated.
+func Neighborhood(
+	ctx context.Context,
+	ds datastore.GraphDatastore,
+	revision datastore.Revision,
+	objectType, objectID string,
+	depth uint32,
+	maxRelationships uint64,
+) (*Graph, error) {
+	g := newGraph()
+
+	start := &v0.ObjectAndRelation{Namespace: objectType, ObjectId: objectID}
+	g.addNode(nodeID(start), NodeKindObject)
+
+	frontier := []*v0.ObjectAndRelation{start}
+	var loaded uint64
+	for hop := uint32(0); hop < depth && len(frontier) > 0; hop++ {
+		var next []*v0.ObjectAndRelation
+		for _, object := range frontier {
+			if loaded >= maxRelationships {
+				g.Truncated = true
+				return g, nil
+			}
+
+			remaining := maxRelationships - loaded
+			iter, err := ds.QueryTuples(ctx, &v1.RelationshipFilter{
+				ResourceType:       object.Namespace,
+				OptionalResourceId: object.ObjectId,
+			}, revision, options.WithLimit(&remaining))
+			if err != nil {
+				return nil, err
+			}
+			found, err := collect(iter)
+			if err != nil {
+				return nil, err
+			}
+
+			remaining -= uint64(len(found))
+			if remaining > 0 {
+				reverseIter, err := ds.ReverseQueryTuples(ctx, &v1.SubjectFilter{
+					SubjectType:       object.Namespace,
+					OptionalSubjectId: object.ObjectId,
+				}, revision, options.WithReverseLimit(&remaining))
+				if err != nil {
+					return nil, err
+				}
+				reverseFound, err := collect(reverseIter)
+				if err != nil {
+					return nil, err
+				}
+				found = append(found, reverseFound...)
+			}
+
+			for _, tpl := range found {
+				loaded++
+
+				resource := tpl.ObjectAndRelation
+				subject := tpl.User.GetUserset()
+				label := resource.Relation
+				if subject.Relation != datastore.Ellipsis {
+					label = fmt.Sprintf("%s (#%s)", resource.Relation, subject.Relation)
+				}
+				g.addEdge(Edge{From: nodeID(resource), To: nodeID(subject), Label: label})
+
+				for _, neighbor := range []*v0.ObjectAndRelation{resource, subject} {
+					if g.addNode(nodeID(neighbor), NodeKindObject) {
+						next = append(next, neighbor)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return g, nil
+}
+
+func collect(iter datastore.TupleIterator) ([]*v0.RelationTuple, error) {
+	defer iter.Close()
+
+	var found []*v0.RelationTuple
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		found = append(found, tpl)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// nodeID identifies the node of an object, regardless of the relation through which it
+// was reached.
+func nodeID(onr *v0.ObjectAndRelation) string {
+	return fmt.Sprintf("%s:%s", onr.Namespace, onr.ObjectId)
+}
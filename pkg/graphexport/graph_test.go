@@ -0,0 +1,104 @@
+package graphexport
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+const testSchema = `definition user {}
+
+definition document {
+	relation parent: folder
+	relation viewer: user | user:*
+	permission view = viewer + parent->view
+}
+
+definition folder {
+	relation viewer: user | group#member
+	permission view = viewer
+}
+
+definition group {
+	relation member: user
+}`
+
+func TestSchemaGraph(t *testing.T) {
+	require := require.New(t)
+
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: testSchema},
+	}, nil)
+	require.NoError(err)
+
+	g := SchemaGraph(defs)
+	require.Equal([]Node{
+		{"user", NodeKindType},
+		{"document", NodeKindType},
+		{"folder", NodeKindType},
+		{"group", NodeKindType},
+	}, g.Nodes)
+	require.Equal([]Edge{
+		{"document", "folder", "parent"},
+		{"document", "user", "viewer"},
+		{"document", "user", "viewer (*)"},
+		{"document", "folder", "view (parent->view)"},
+		{"folder", "user", "viewer"},
+		{"folder", "group", "viewer (#member)"},
+		{"group", "user", "member"},
+	}, g.Edges)
+
+	dot := DOT(g)
+	require.True(strings.HasPrefix(dot, "digraph spicedb {\n"))
+	require.Contains(dot, `  "document" [shape=box];`)
+	require.Contains(dot, `  "folder" -> "group" [label="viewer (#member)"];`)
+
+	graphML, err := GraphML(g)
+	require.NoError(err)
+	require.Contains(graphML, `<edge source="document" target="folder">`)
+	require.Contains(graphML, `<data key="kind">type</data>`)
+}
+
+func TestNeighborhood(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+
+	ds, revision := testfixtures.StandardDatastoreWithData(rawDS, require)
+
+	g, err := Neighborhood(context.Background(), ds, revision, "folder", "plans", 1, 100)
+	require.NoError(err)
+	require.False(g.Truncated)
+	require.ElementsMatch([]Edge{
+		{"folder:plans", "user:chief_financial_officer", "viewer"},
+		{"document:masterplan", "folder:plans", "parent"},
+		{"document:healthplan", "folder:plans", "parent"},
+	}, g.Edges)
+	require.Len(g.Nodes, 4)
+
+	// The fourth relationship loaded is the viewer relationship of the folder, found again
+	// from its subject, after which loading stops.
+	g, err = Neighborhood(context.Background(), ds, revision, "folder", "plans", 3, 4)
+	require.NoError(err)
+	require.True(g.Truncated)
+	require.Len(g.Edges, 3)
+}
+
+func TestParseFormat(t *testing.T) {
+	require := require.New(t)
+
+	format, err := ParseFormat("GraphML")
+	require.NoError(err)
+	require.Equal(FormatGraphML, format)
+
+	_, err = ParseFormat("svg")
+	require.Error(err)
+}
@@ -0,0 +1,154 @@
+package graphexport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Format is a format in which a graph can be exported.
+type Format string
+
+const (
+	FormatDOT     Format = "dot"
+	FormatGraphML Format = "graphml"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatDOT, FormatGraphML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown graph format `%s`; must be one of: dot, graphml", name)
+	}
+}
+
+// Render renders a graph in the given format.
+func Render(g *Graph, format Format) (string, error) {
+	switch format {
+	case FormatDOT:
+		return DOT(g), nil
+	case FormatGraphML:
+		return GraphML(g)
+	default:
+		return "", fmt.Errorf("unknown graph format `%s`", format)
+	}
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+// DOT renders a graph in the Graphviz DOT language. Object types are drawn as boxes and
+// objects as ellipses.
+func DOT(g *Graph) string {
+	var buf strings.Builder
+	buf.WriteString("digraph spicedb {\n")
+
+	for _, node := range g.Nodes {
+		shape := "ellipse"
+		if node.Kind == NodeKindType {
+			shape = "box"
+		}
+		fmt.Fprintf(&buf, "  %s [shape=%s];\n", dotQuote(node.ID), shape)
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&buf, "  %s -> %s [label=%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(edge.Label))
+	}
+
+	if g.Truncated {
+		buf.WriteString("  label=\"truncated\";\n")
+	}
+
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+const (
+	graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+
+	graphMLKeyKind      = "kind"
+	graphMLKeyLabel     = "label"
+	graphMLKeyTruncated = "truncated"
+)
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Data        []graphMLData `xml:"data"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// GraphML renders a graph as a GraphML document. The kind of each node and the label of
+// each edge are recorded as data.
+func GraphML(g *Graph) (string, error) {
+	doc := graphMLDocument{
+		XMLNS: graphMLNamespace,
+		Keys: []graphMLKey{
+			{ID: graphMLKeyKind, For: "node", AttrName: "kind", AttrType: "string"},
+			{ID: graphMLKeyLabel, For: "edge", AttrName: "label", AttrType: "string"},
+			{ID: graphMLKeyTruncated, For: "graph", AttrName: "truncated", AttrType: "boolean"},
+		},
+		Graph: graphMLGraph{
+			ID:          "spicedb",
+			EdgeDefault: "directed",
+			Data:        []graphMLData{{Key: graphMLKeyTruncated, Value: fmt.Sprint(g.Truncated)}},
+		},
+	}
+
+	for _, node := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID:   node.ID,
+			Data: []graphMLData{{Key: graphMLKeyKind, Value: string(node.Kind)}},
+		})
+	}
+
+	for _, edge := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: edge.From,
+			Target: edge.To,
+			Data:   []graphMLData{{Key: graphMLKeyLabel, Value: edge.Label}},
+		})
+	}
+
+	encoded, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(encoded) + "\n", nil
+}
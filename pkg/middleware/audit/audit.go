@@ -0,0 +1,277 @@
+// Package audit records an audit log of the API calls which mutate data and, optionally,
+// of checks, for compliance purposes.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/auth"
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/middleware/provenance"
+	"github.com/authzed/spicedb/pkg/middleware/requestid"
+)
+
+// Event is the record of a single API call.
+type Event struct {
+	// Time is the time at which the call was received.
+	Time time.Time `json:"time"`
+
+	// Method is the full name of the gRPC method called.
+	Method string `json:"method"`
+
+	// Principal is the verified identity of the client, if its authentication identified
+	// it.
+	Principal string `json:"principal,omitempty"`
+
+	// Caller is the self-reported identity of the client, if any.
+	Caller string `json:"caller,omitempty"`
+
+	// Tenant is the tenant on whose behalf the call was made.
+	Tenant string `json:"tenant"`
+
+	// RequestID is the ID of the request.
+	RequestID string `json:"request_id,omitempty"`
+
+	// Request holds the parameters of the call.
+	Request json.RawMessage `json:"request,omitempty"`
+
+	// Code is the gRPC status code with which the call completed, and Error its message
+	// if it failed.
+	Code  string `json:"code"`
+	Error string `json:"error,omitempty"`
+
+	// Decision is the result of checks, such as `PERMISSIONSHIP_HAS_PERMISSION`.
+	Decision string `json:"decision,omitempty"`
+
+	// Revision is the revision at which the call wrote or checked, as a ZedToken or
+	// Zookie.
+	Revision string `json:"revision,omitempty"`
+
+	// LatencyMillis is the amount of time taken by the call, in milliseconds.
+	LatencyMillis float64 `json:"latency_ms"`
+}
+
+// Sink receives the events of the audit log.
+type Sink interface {
+	// Record records the event. Failures are logged, and do not fail the call.
+	Record(ctx context.Context, event Event) error
+}
+
+// Option instances control how the middleware is initialized.
+type Option func(*auditor)
+
+// IncludeChecks sets whether checks are recorded in addition to calls which mutate data.
+//
+// default: false
+func IncludeChecks(enabled bool) Option {
+	return func(a *auditor) {
+		a.includeChecks = enabled
+	}
+}
+
+type auditor struct {
+	sink          Sink
+	includeChecks bool
+}
+
+func newAuditor(sink Sink, opts []Option) *auditor {
+	a := &auditor{sink: sink}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *auditor) audited(fullMethod string) bool {
+	if auth.IsWriteMethod(fullMethod) {
+		return true
+	}
+	_, isCheck := checkMethods[fullMethod]
+	return a.includeChecks && isCheck
+}
+
+// checkMethods are the public API methods which check permissions. The checks dispatched
+// between the nodes of the cluster to answer them are internal, and are never recorded.
+var checkMethods = map[string]struct{}{
+	"/authzed.api.v1.PermissionsService/CheckPermission":     {},
+	"/authzed.api.v0.ACLService/Check":                       {},
+	"/authzed.api.v0.ACLService/ContentChangeCheck":          {},
+	"/spicedb.v1.BulkPermissionsService/BulkCheckPermission": {},
+}
+
+func (a *auditor) record(ctx context.Context, fullMethod string, start time.Time, req, resp interface{}, err error) {
+	event := newEvent(ctx, fullMethod, req, resp, err)
+	event.Time = start
+	event.LatencyMillis = float64(time.Since(start)) / float64(time.Millisecond)
+	if recordErr := a.sink.Record(ctx, event); recordErr != nil {
+		log.Ctx(ctx).Error().Err(recordErr).Str("method", fullMethod).Msg("unable to record audit event")
+	}
+}
+
+// UnaryServerInterceptor returns a new interceptor which records the calls which mutate
+// data, and optionally checks, to the sink. A nil sink records nothing.
+func UnaryServerInterceptor(sink Sink, opts ...Option) grpc.UnaryServerInterceptor {
+	if sink == nil {
+		return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(ctx, req)
+		}
+	}
+
+	a := newAuditor(sink, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !a.audited(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		a.record(ctx, info.FullMethod, start, req, resp, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which records, when checks are
+// included, each check of bulk check streams to the sink as its own event. No streaming
+// call mutates data. A nil sink records nothing.
+func StreamServerInterceptor(sink Sink, opts ...Option) grpc.StreamServerInterceptor {
+	if sink == nil {
+		return func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return handler(srv, stream)
+		}
+	}
+
+	a := newAuditor(sink, opts)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !a.audited(info.FullMethod) {
+			return handler(srv, stream)
+		}
+
+		audited := &auditedStream{ServerStream: stream, auditor: a, method: info.FullMethod, start: time.Now()}
+		err := handler(srv, audited)
+		audited.finish(err)
+		return err
+	}
+}
+
+// auditedStream pairs the requests received on a stream with the responses sent for them,
+// which are sent in the order of the requests, and records each pair.
+type auditedStream struct {
+	grpc.ServerStream
+	auditor *auditor
+	method  string
+	start   time.Time
+
+	mu      sync.Mutex
+	pending []interface{}
+}
+
+func (s *auditedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.pending = append(s.pending, m)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *auditedStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+
+	s.mu.Lock()
+	var req interface{}
+	if len(s.pending) > 0 {
+		req, s.pending = s.pending[0], s.pending[1:]
+	}
+	s.mu.Unlock()
+
+	s.auditor.record(s.Context(), s.method, s.start, req, m, err)
+	return err
+}
+
+// finish records the requests which were never answered, with the error which ended the
+// stream.
+func (s *auditedStream) finish(err error) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	for _, req := range pending {
+		s.auditor.record(s.Context(), s.method, s.start, req, nil, err)
+	}
+}
+
+func newEvent(ctx context.Context, fullMethod string, req, resp interface{}, err error) Event {
+	event := Event{
+		Method: fullMethod,
+		Tenant: datastore.TenantFromContext(ctx),
+		Code:   status.Code(err).String(),
+	}
+	if err != nil {
+		event.Error = status.Convert(err).Message()
+	}
+
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		event.Principal = principal
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(provenance.CallerMetadataKey); len(values) > 0 {
+			event.Caller = values[0]
+		}
+		if values := md.Get(requestid.RequestIDMetadataKey); len(values) > 0 {
+			event.RequestID = values[0]
+		}
+	}
+
+	if message, ok := req.(proto.Message); ok {
+		encoded, marshalErr := protojson.Marshal(message)
+		if marshalErr == nil {
+			event.Request = encoded
+		}
+	}
+
+	if err == nil {
+		event.Decision = decision(resp)
+		event.Revision = revision(resp)
+	}
+	return event
+}
+
+func decision(resp interface{}) string {
+	switch typed := resp.(type) {
+	case *v1.CheckPermissionResponse:
+		return typed.GetPermissionship().String()
+	case *v0.CheckResponse:
+		return typed.GetMembership().String()
+	default:
+		return ""
+	}
+}
+
+func revision(resp interface{}) string {
+	switch typed := resp.(type) {
+	case interface{ GetWrittenAt() *v1.ZedToken }:
+		return typed.GetWrittenAt().GetToken()
+	case interface{ GetDeletedAt() *v1.ZedToken }:
+		return typed.GetDeletedAt().GetToken()
+	case interface{ GetCheckedAt() *v1.ZedToken }:
+		return typed.GetCheckedAt().GetToken()
+	case interface{ GetRevision() *v0.Zookie }:
+		return typed.GetRevision().GetToken()
+	default:
+		return ""
+	}
+}
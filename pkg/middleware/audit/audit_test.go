@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/auth"
+	"github.com/authzed/spicedb/internal/datastore"
+)
+
+const (
+	checkMethod = "/authzed.api.v1.PermissionsService/CheckPermission"
+	writeMethod = "/authzed.api.v1.PermissionsService/WriteRelationships"
+	readMethod  = "/authzed.api.v1.PermissionsService/ReadRelationships"
+
+	bulkCheckMethod = "/spicedb.v1.BulkPermissionsService/BulkCheckPermission"
+)
+
+type recordingSink struct {
+	events []Event
+}
+
+func (rs *recordingSink) Record(_ context.Context, event Event) error {
+	rs.events = append(rs.events, event)
+	return nil
+}
+
+func call(ctx context.Context, interceptor grpc.UnaryServerInterceptor, method string, req, resp interface{}, err error) {
+	_, _ = interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return resp, err
+	})
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	require := require.New(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-spicedb-caller", "sync-job", "x-request-id", "abc123"))
+	ctx = auth.ContextWithPrincipal(ctx, "user-123")
+
+	writeReq := &v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{{
+		Operation: v1.RelationshipUpdate_OPERATION_TOUCH,
+		Relationship: &v1.Relationship{
+			Resource: &v1.ObjectReference{ObjectType: "document", ObjectId: "doc1"},
+			Relation: "viewer",
+			Subject:  &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "alice"}},
+		},
+	}}}
+	checkResp := &v1.CheckPermissionResponse{
+		CheckedAt:      &v1.ZedToken{Token: "checkedtoken"},
+		Permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
+	}
+
+	sink := &recordingSink{}
+	interceptor := UnaryServerInterceptor(sink)
+	call(ctx, interceptor, writeMethod, writeReq, &v1.WriteRelationshipsResponse{WrittenAt: &v1.ZedToken{Token: "writtentoken"}}, nil)
+	call(ctx, interceptor, writeMethod, writeReq, nil, status.Error(codes.FailedPrecondition, "precondition failed"))
+	call(ctx, interceptor, checkMethod, &v1.CheckPermissionRequest{}, checkResp, nil)
+	call(ctx, interceptor, readMethod, &v1.ReadRelationshipsRequest{}, nil, nil)
+
+	require.Len(sink.events, 2)
+
+	written := sink.events[0]
+	require.Equal(writeMethod, written.Method)
+	require.Equal("user-123", written.Principal)
+	require.Equal("sync-job", written.Caller)
+	require.Equal("abc123", written.RequestID)
+	require.Equal(datastore.DefaultTenant, written.Tenant)
+	require.Equal("OK", written.Code)
+	require.Equal("writtentoken", written.Revision)
+	require.Contains(string(written.Request), `"objectId":"doc1"`)
+	require.False(written.Time.IsZero())
+
+	failed := sink.events[1]
+	require.Equal("FailedPrecondition", failed.Code)
+	require.Equal("precondition failed", failed.Error)
+	require.Empty(failed.Revision)
+
+	sink = &recordingSink{}
+	interceptor = UnaryServerInterceptor(sink, IncludeChecks(true))
+	call(ctx, interceptor, checkMethod, &v1.CheckPermissionRequest{}, checkResp, nil)
+	call(ctx, interceptor, readMethod, &v1.ReadRelationshipsRequest{}, nil, nil)
+
+	// Checks dispatched between the nodes of the cluster are never recorded.
+	call(ctx, interceptor, "/dispatch.v1.DispatchService/DispatchCheck", &v1.CheckPermissionRequest{}, checkResp, nil)
+
+	require.Len(sink.events, 1)
+	require.Equal(checkMethod, sink.events[0].Method)
+	require.Equal("PERMISSIONSHIP_HAS_PERMISSION", sink.events[0].Decision)
+	require.Equal("checkedtoken", sink.events[0].Revision)
+}
+
+type bulkCheckStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	requests []*v1.CheckPermissionRequest
+	sent     []interface{}
+}
+
+func (s *bulkCheckStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *bulkCheckStream) RecvMsg(m interface{}) error {
+	if len(s.requests) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(*v1.CheckPermissionRequest), s.requests[0])
+	s.requests = s.requests[1:]
+	return nil
+}
+
+func (s *bulkCheckStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	require := require.New(t)
+
+	checkFor := func(resourceID string) *v1.CheckPermissionRequest {
+		return &v1.CheckPermissionRequest{
+			Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: resourceID},
+			Permission: "view",
+			Subject:    &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "alice"}},
+		}
+	}
+
+	// Answers the first request, then fails.
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		for {
+			err := stream.RecvMsg(&v1.CheckPermissionRequest{})
+			if err == io.EOF {
+				break
+			}
+			require.NoError(err)
+		}
+		require.NoError(stream.SendMsg(&v1.CheckPermissionResponse{
+			CheckedAt:      &v1.ZedToken{Token: "checkedtoken"},
+			Permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION,
+		}))
+		return status.Error(codes.Unavailable, "unavailable")
+	}
+
+	newStream := func() *bulkCheckStream {
+		return &bulkCheckStream{ctx: context.Background(), requests: []*v1.CheckPermissionRequest{checkFor("doc1"), checkFor("doc2")}}
+	}
+	info := &grpc.StreamServerInfo{FullMethod: bulkCheckMethod}
+
+	sink := &recordingSink{}
+	require.Error(StreamServerInterceptor(sink)(nil, newStream(), info, handler))
+	require.Empty(sink.events)
+
+	require.Error(StreamServerInterceptor(sink, IncludeChecks(true))(nil, newStream(), info, handler))
+	require.Len(sink.events, 2)
+
+	answered := sink.events[0]
+	require.Equal(bulkCheckMethod, answered.Method)
+	require.Equal("OK", answered.Code)
+	require.Equal("PERMISSIONSHIP_NO_PERMISSION", answered.Decision)
+	require.Equal("checkedtoken", answered.Revision)
+	require.Contains(string(answered.Request), `"objectId":"doc1"`)
+
+	unanswered := sink.events[1]
+	require.Equal("Unavailable", unanswered.Code)
+	require.Empty(unanswered.Decision)
+	require.Contains(string(unanswered.Request), `"objectId":"doc2"`)
+}
+
+func TestWriterSink(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+	require.NoError(sink.Record(context.Background(), Event{Method: writeMethod, Code: "OK"}))
+	require.NoError(sink.Record(context.Background(), Event{Method: checkMethod, Code: "OK", Decision: "PERMISSIONSHIP_NO_PERMISSION"}))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(lines, 2)
+
+	var decoded Event
+	require.NoError(json.Unmarshal(lines[1], &decoded))
+	require.Equal(checkMethod, decoded.Method)
+	require.Equal("PERMISSIONSHIP_NO_PERMISSION", decoded.Decision)
+}
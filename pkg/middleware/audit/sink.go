@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// WriterSink is a Sink which writes each event to a writer as a line of JSON.
+type WriterSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewWriterSink creates a sink writing to the writer.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{encoder: json.NewEncoder(w)}
+}
+
+// NewFileSink creates a sink appending to the file at the path, creating it if it does not
+// exist. The special path `-` writes to stdout.
+func NewFileSink(path string) (*WriterSink, error) {
+	if path == "-" {
+		return NewWriterSink(os.Stdout), nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log file: %w", err)
+	}
+
+	sink := NewWriterSink(file)
+	sink.closer = file
+	return sink, nil
+}
+
+// Record implements Sink.
+func (ws *WriterSink) Record(_ context.Context, event Event) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.encoder.Encode(event)
+}
+
+// Close closes the file written by the sink, if it opened one.
+func (ws *WriterSink) Close() error {
+	if ws.closer == nil {
+		return nil
+	}
+	return ws.closer.Close()
+}
@@ -0,0 +1,121 @@
+package priority
+
+import (
+	"context"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// PriorityMetadataKey is the key in which request priority classes are passed to metadata.
+const PriorityMetadataKey = "x-request-priority"
+
+// Class is the priority class of a request.
+type Class string
+
+const (
+	// Interactive requests are latency sensitive and are served from the primary resources.
+	Interactive Class = "interactive"
+
+	// Batch requests are bulk operations, such as exports, which tolerate additional latency.
+	Batch Class = "batch"
+
+	// Background requests are internal or maintenance operations with the lowest priority.
+	Background Class = "background"
+)
+
+// IsLowPriority returns true if the class should be isolated from interactive traffic.
+func (c Class) IsLowPriority() bool {
+	return c == Batch || c == Background
+}
+
+// ParseClass parses a priority class from its string form, returning false if the
+// class is unknown.
+func ParseClass(value string) (Class, bool) {
+	switch Class(value) {
+	case Interactive, Batch, Background:
+		return Class(value), true
+	default:
+		return "", false
+	}
+}
+
+type ctxKeyType struct{}
+
+var priorityKey ctxKeyType = struct{}{}
+
+// ContextWithClass returns a new context which carries the specified priority class.
+func ContextWithClass(ctx context.Context, class Class) context.Context {
+	return context.WithValue(ctx, priorityKey, class)
+}
+
+// FromContext returns the priority class carried by the context, or Interactive if
+// none is present.
+func FromContext(ctx context.Context) Class {
+	if class, ok := ctx.Value(priorityKey).(Class); ok {
+		return class
+	}
+	return Interactive
+}
+
+// Option instances control how the middleware is initialized.
+type Option func(*handlePriority)
+
+// DefaultClass sets the class which is applied when the incoming request does not
+// specify one.
+//
+// default: Interactive
+func DefaultClass(class Class) Option {
+	return func(reporter *handlePriority) {
+		reporter.defaultClass = class
+	}
+}
+
+type handlePriority struct {
+	defaultClass Class
+}
+
+func (r *handlePriority) ServerReporter(ctx context.Context, _ interceptors.CallMeta) (interceptors.Reporter, context.Context) {
+	class := r.defaultClass
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(PriorityMetadataKey); len(values) > 0 {
+			parsed, ok := ParseClass(values[0])
+			if ok {
+				class = parsed
+			} else {
+				log.Ctx(ctx).Debug().Str("priority", values[0]).Msg("ignoring unknown request priority class")
+			}
+		}
+	}
+
+	// Forward the priority class to any dispatched requests so that it is honored
+	// across the cluster.
+	ctx = metadata.AppendToOutgoingContext(ctx, PriorityMetadataKey, string(class))
+	return interceptors.NoopReporter{}, ContextWithClass(ctx, class)
+}
+
+// UnaryServerInterceptor returns a new interceptor which extracts the priority class
+// of requests according to the provided options.
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	return interceptors.UnaryServerInterceptor(createReporter(opts))
+}
+
+// StreamServerInterceptor returns a new interceptor which extracts the priority class
+// of requests according to the provided options.
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	return interceptors.StreamServerInterceptor(createReporter(opts))
+}
+
+func createReporter(opts []Option) *handlePriority {
+	reporter := &handlePriority{
+		defaultClass: Interactive,
+	}
+
+	for _, opt := range opts {
+		opt(reporter)
+	}
+
+	return reporter
+}
@@ -0,0 +1,74 @@
+package priority
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestParseClass(t *testing.T) {
+	require := require.New(t)
+
+	for _, class := range []Class{Interactive, Batch, Background} {
+		parsed, ok := ParseClass(string(class))
+		require.True(ok)
+		require.Equal(class, parsed)
+	}
+
+	_, ok := ParseClass("urgent")
+	require.False(ok)
+	_, ok = ParseClass("")
+	require.False(ok)
+
+	require.False(Interactive.IsLowPriority())
+	require.True(Batch.IsLowPriority())
+	require.True(Background.IsLowPriority())
+}
+
+func TestFromContext(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(Interactive, FromContext(context.Background()))
+	require.Equal(Batch, FromContext(ContextWithClass(context.Background(), Batch)))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	testCases := []struct {
+		name          string
+		incoming      metadata.MD
+		defaultClass  Class
+		expectedClass Class
+	}{
+		{"honors incoming class", metadata.Pairs(PriorityMetadataKey, "batch"), Interactive, Batch},
+		{"ignores unknown class", metadata.Pairs(PriorityMetadataKey, "urgent"), Interactive, Interactive},
+		{"applies default when missing", metadata.Pairs("other", "value"), Background, Background},
+		{"applies default without metadata", nil, Interactive, Interactive},
+		{"incoming class overrides default", metadata.Pairs(PriorityMetadataKey, "interactive"), Background, Interactive},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			ctx := context.Background()
+			if tc.incoming != nil {
+				ctx = metadata.NewIncomingContext(ctx, tc.incoming)
+			}
+
+			interceptor := UnaryServerInterceptor(DefaultClass(tc.defaultClass))
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				require.Equal(tc.expectedClass, FromContext(ctx))
+
+				// The class is forwarded to dispatched requests.
+				outgoing, ok := metadata.FromOutgoingContext(ctx)
+				require.True(ok)
+				require.Equal([]string{string(tc.expectedClass)}, outgoing.Get(PriorityMetadataKey))
+				return nil, nil
+			})
+			require.NoError(err)
+		})
+	}
+}
@@ -2,18 +2,37 @@ package requestid
 
 import (
 	"context"
-	"math/rand"
+	"crypto/rand"
+	"encoding/hex"
 
 	"github.com/authzed/authzed-go/pkg/responsemeta"
-	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors"
+	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // RequestIDMetadataKey is the key in which request IDs are passed to metadata.
 const RequestIDMetadataKey = "x-request-id"
 
+// spanAttributeKey is the attribute of tracing spans in which the request ID is recorded.
+const spanAttributeKey = attribute.Key("request_id")
+
+type ctxKeyType struct{}
+
+var requestIDKey ctxKeyType = struct{}{}
+
+// FromContext returns the ID of the request being handled, if it has one.
+func FromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey).(string)
+	return requestID, ok
+}
+
 // Option instances control how the middleware is initialized.
 type Option func(*handleRequestID)
 
@@ -44,7 +63,8 @@ type handleRequestID struct {
 	requestIDGenerator IDGenerator
 }
 
-func (r *handleRequestID) ServerReporter(ctx context.Context, _ interceptors.CallMeta) (interceptors.Reporter, context.Context) {
+// handle returns the context of the request carrying its ID, if it has or was given one.
+func (r *handleRequestID) handle(ctx context.Context) (context.Context, string, bool) {
 	var requestID string
 	var haveRequestID bool
 	md, ok := metadata.FromIncomingContext(ctx)
@@ -54,6 +74,8 @@ func (r *handleRequestID) ServerReporter(ctx context.Context, _ interceptors.Cal
 		if haveRequestID {
 			requestID = requestIDs[0]
 		}
+	} else {
+		md = metadata.MD{}
 	}
 
 	if !haveRequestID && r.generateIfMissing {
@@ -65,7 +87,13 @@ func (r *handleRequestID) ServerReporter(ctx context.Context, _ interceptors.Cal
 	}
 
 	if haveRequestID {
+		ctx = context.WithValue(ctx, requestIDKey, requestID)
+
+		// Forward the request ID to any dispatched requests so that they can be correlated
+		// across the cluster.
 		ctx = metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, requestID)
+		trace.SpanFromContext(ctx).SetAttributes(spanAttributeKey.String(requestID))
+
 		err := responsemeta.SetResponseHeaderMetadata(ctx, map[responsemeta.ResponseMetadataHeaderKey]string{
 			responsemeta.RequestID: requestID,
 		})
@@ -74,26 +102,67 @@ func (r *handleRequestID) ServerReporter(ctx context.Context, _ interceptors.Cal
 		}
 	}
 
-	return interceptors.NoopReporter{}, ctx
+	return ctx, requestID, haveRequestID
+}
+
+// withRequestInfo adds the request ID to the details of an error, so that it is reported
+// to clients which do not read the response headers.
+func withRequestInfo(err error, requestID string) error {
+	if err == nil {
+		return nil
+	}
+
+	st := status.Convert(err)
+	if st.Code() == codes.OK {
+		return err
+	}
+	for _, detail := range st.Details() {
+		if _, ok := detail.(*errdetails.RequestInfo); ok {
+			return err
+		}
+	}
+
+	withDetails, detailsErr := st.WithDetails(&errdetails.RequestInfo{RequestId: requestID})
+	if detailsErr != nil {
+		return err
+	}
+	return withDetails.Err()
 }
 
 // UnaryServerInterceptor returns a new interceptor which handles request IDs according
 // to the provided options.
 func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
-	return interceptors.UnaryServerInterceptor(createReporter(opts))
+	reporter := createReporter(opts)
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, requestID, haveRequestID := reporter.handle(ctx)
+		resp, err := handler(ctx, req)
+		if haveRequestID {
+			err = withRequestInfo(err, requestID)
+		}
+		return resp, err
+	}
 }
 
 // StreamServerInterceptor returns a new interceptor which handles request IDs according
 // to the provided options.
 func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
-	return interceptors.StreamServerInterceptor(createReporter(opts))
+	reporter := createReporter(opts)
+	return func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrapped := grpcmw.WrapServerStream(stream)
+		ctx, requestID, haveRequestID := reporter.handle(stream.Context())
+		wrapped.WrappedContext = ctx
+
+		err := handler(srv, wrapped)
+		if haveRequestID {
+			err = withRequestInfo(err, requestID)
+		}
+		return err
+	}
 }
 
 func createReporter(opts []Option) *handleRequestID {
 	reporter := &handleRequestID{
-		requestIDGenerator: func() string {
-			return randSeq(32)
-		},
+		requestIDGenerator: generateRequestID,
 	}
 
 	for _, opt := range opts {
@@ -103,12 +172,13 @@ func createReporter(opts []Option) *handleRequestID {
 	return reporter
 }
 
-var letters = []rune("0123456789abcdef")
-
-func randSeq(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+// generateRequestID returns a random 32 character hex string. It is read from crypto/rand
+// so that nodes, whose math/rand sequences are identical unless seeded, do not generate the
+// same IDs.
+func generateRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("unable to generate request ID: " + err.Error())
 	}
-	return string(b)
+	return hex.EncodeToString(b)
 }
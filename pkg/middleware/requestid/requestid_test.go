@@ -0,0 +1,89 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	testCases := []struct {
+		name              string
+		incoming          metadata.MD
+		generateIfMissing bool
+		expectedID        string
+	}{
+		{"honors incoming ID", metadata.Pairs(RequestIDMetadataKey, "incoming"), true, "incoming"},
+		{"generates missing ID", metadata.Pairs("other", "value"), true, "generated"},
+		{"generates without metadata", nil, true, "generated"},
+		{"leaves missing ID", metadata.Pairs("other", "value"), false, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			ctx := context.Background()
+			if tc.incoming != nil {
+				ctx = metadata.NewIncomingContext(ctx, tc.incoming)
+			}
+
+			interceptor := UnaryServerInterceptor(
+				GenerateIfMissing(tc.generateIfMissing),
+				WithIDGenerator(func() string { return "generated" }),
+			)
+
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				requestID, ok := FromContext(ctx)
+				require.Equal(tc.expectedID != "", ok)
+				require.Equal(tc.expectedID, requestID)
+
+				outgoing, _ := metadata.FromOutgoingContext(ctx)
+				incoming, _ := metadata.FromIncomingContext(ctx)
+				if tc.expectedID == "" {
+					require.Empty(outgoing.Get(RequestIDMetadataKey))
+					require.Empty(incoming.Get(RequestIDMetadataKey))
+				} else {
+					require.Equal([]string{tc.expectedID}, outgoing.Get(RequestIDMetadataKey))
+					require.Equal([]string{tc.expectedID}, incoming.Get(RequestIDMetadataKey))
+				}
+				return nil, status.Error(codes.NotFound, "not found")
+			})
+
+			st := status.Convert(err)
+			require.Equal(codes.NotFound, st.Code())
+			require.Equal("not found", st.Message())
+			if tc.expectedID == "" {
+				require.Empty(st.Details())
+				return
+			}
+			require.Len(st.Details(), 1)
+			require.Equal(tc.expectedID, st.Details()[0].(*errdetails.RequestInfo).RequestId)
+		})
+	}
+}
+
+func TestWithRequestInfo(t *testing.T) {
+	require := require.New(t)
+
+	require.NoError(withRequestInfo(nil, "someid"))
+
+	// The request ID of the first node to fail is kept as the error is returned through
+	// the nodes which dispatched to it.
+	err := withRequestInfo(withRequestInfo(status.Error(codes.Internal, "failed"), "first"), "second")
+	st := status.Convert(err)
+	require.Len(st.Details(), 1)
+	require.Equal("first", st.Details()[0].(*errdetails.RequestInfo).RequestId)
+}
+
+func TestGenerateRequestID(t *testing.T) {
+	first, second := generateRequestID(), generateRequestID()
+	require.Len(t, first, 32)
+	require.NotEqual(t, first, second)
+}
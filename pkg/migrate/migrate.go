@@ -33,6 +33,63 @@ type Driver interface {
 	WriteVersion(version, replaced string) error
 }
 
+// StatementRecorder is implemented by drivers which can record the statements executed by
+// migrations instead of executing them, allowing a dry run to report the statements which
+// a live run would execute.
+type StatementRecorder interface {
+	// StartRecording causes the statements executed by migrations to be recorded rather
+	// than executed.
+	StartRecording()
+
+	// StopRecording returns the statements recorded since recording was started, and
+	// resumes executing statements.
+	StopRecording() []string
+}
+
+// Plan describes the migrations performed, or which would be performed, by a run.
+type Plan struct {
+	// StartingVersion is the version of the backing datastore before the run.
+	StartingVersion string `json:"startingVersion"`
+
+	// EndingVersion is the version of the backing datastore after the run, or which it
+	// would have after a live run if the run was dry.
+	EndingVersion string `json:"endingVersion"`
+
+	// DryRun is set if the migrations were only planned.
+	DryRun bool `json:"dryRun"`
+
+	// Migrations are the migrations performed, or to be performed, in order.
+	Migrations []PlannedMigration `json:"migrations"`
+}
+
+// PlannedMigration is a migration performed, or to be performed, by a run.
+type PlannedMigration struct {
+	Version  string `json:"version"`
+	Replaces string `json:"replaces"`
+
+	// Statements are the statements executed by the migration, recorded if the run was dry
+	// and the driver is a StatementRecorder.
+	Statements []string `json:"statements,omitempty"`
+}
+
+// Status describes the version of a backing datastore relative to the head revision of
+// its migrations.
+type Status struct {
+	// CurrentVersion is the version of the backing datastore.
+	CurrentVersion string `json:"currentVersion"`
+
+	// HeadVersion is the latest version known to the migrations.
+	HeadVersion string `json:"headVersion"`
+
+	// Pending are the migrations which must be run to reach the head revision, in order.
+	Pending []PlannedMigration `json:"pending"`
+}
+
+// UpToDate returns true if the backing datastore is at the head revision.
+func (s Status) UpToDate() bool {
+	return len(s.Pending) == 0
+}
+
 type migration struct {
 	version  string
 	replaces string
@@ -79,61 +136,136 @@ func (m *Manager) Register(version, replaces string, up interface{}) error {
 // Run will actually perform the necessary migrations to bring the backing datastore
 // from its current revision to the specified revision.
 func (m *Manager) Run(driver Driver, throughRevision string, dryRun RunType) error {
+	_, err := m.RunWithPlan(driver, throughRevision, dryRun)
+	return err
+}
+
+// RunWithPlan performs the migrations as Run does, returning the plan of the migrations
+// which were performed. In a dry run, the statements of each migration are recorded in
+// the plan if the driver is a StatementRecorder, without being executed.
+func (m *Manager) RunWithPlan(driver Driver, throughRevision string, dryRun RunType) (Plan, error) {
 	starting, err := driver.Version()
 	if err != nil {
-		return fmt.Errorf("unable to compute target revision: %w", err)
+		return Plan{}, fmt.Errorf("unable to compute target revision: %w", err)
 	}
 
 	if strings.ToLower(throughRevision) == Head {
 		throughRevision, err = m.HeadRevision()
 		if err != nil {
-			return fmt.Errorf("unable to compute head revision: %w", err)
+			return Plan{}, fmt.Errorf("unable to compute head revision: %w", err)
 		}
 	}
 
 	toRun, err := collectMigrationsInRange(starting, throughRevision, m.migrations)
 	if err != nil {
-		return fmt.Errorf("unable to compute migration list: %w", err)
+		return Plan{}, fmt.Errorf("unable to compute migration list: %w", err)
+	}
+
+	plan := Plan{
+		StartingVersion: starting,
+		EndingVersion:   starting,
+		DryRun:          bool(dryRun),
+		Migrations:      make([]PlannedMigration, 0, len(toRun)),
 	}
 
 	for _, oneMigration := range toRun {
 		// Check that the migration is actually a function that accepts one parameter, the type of
 		// which is a valid coercion of driver instance.
 		if err := checkTypes(driver, oneMigration.up); err != nil {
-			return fmt.Errorf("unable to validate up migration: %w", err)
+			return plan, fmt.Errorf("unable to validate up migration: %w", err)
 		}
 
 		log.Info().Str("from", oneMigration.replaces).Str("to", oneMigration.version).Msg("planned migration")
+		plan.Migrations = append(plan.Migrations, PlannedMigration{
+			Version:  oneMigration.version,
+			Replaces: oneMigration.replaces,
+		})
 	}
 
-	if !dryRun {
-		for _, migrationToRun := range toRun {
-			// Double check that the current version reported is the one we expect
-			currentVersion, err := driver.Version()
-			if err != nil {
-				return fmt.Errorf("unable to load version from driver: %w", err)
+	if dryRun {
+		if recorder, ok := driver.(StatementRecorder); ok {
+			for index, migrationToRun := range toRun {
+				recorder.StartRecording()
+				err := callUp(driver, migrationToRun)
+				plan.Migrations[index].Statements = recorder.StopRecording()
+				if err != nil {
+					return plan, err
+				}
 			}
+		}
 
-			if migrationToRun.replaces != currentVersion {
-				return fmt.Errorf("migration attempting to run out of order: %s != %s", currentVersion, migrationToRun.replaces)
-			}
+		if len(toRun) > 0 {
+			plan.EndingVersion = toRun[len(toRun)-1].version
+		}
+		return plan, nil
+	}
 
-			log.Info().Str("from", migrationToRun.replaces).Str("to", migrationToRun.version).Msg("migrating")
+	for _, migrationToRun := range toRun {
+		// Double check that the current version reported is the one we expect
+		currentVersion, err := driver.Version()
+		if err != nil {
+			return plan, fmt.Errorf("unable to load version from driver: %w", err)
+		}
 
-			in := []reflect.Value{reflect.ValueOf(driver)}
-			upFunction := reflect.ValueOf(migrationToRun.up)
+		if migrationToRun.replaces != currentVersion {
+			return plan, fmt.Errorf("migration attempting to run out of order: %s != %s", currentVersion, migrationToRun.replaces)
+		}
 
-			errArg := upFunction.Call(in)[0]
-			if !errArg.IsNil() {
-				return fmt.Errorf("error running migration up function: %v", errArg)
-			}
+		log.Info().Str("from", migrationToRun.replaces).Str("to", migrationToRun.version).Msg("migrating")
 
-			if err := driver.WriteVersion(migrationToRun.version, migrationToRun.replaces); err != nil {
-				return fmt.Errorf("error writing migration version to driver: %w", err)
-			}
+		if err := callUp(driver, migrationToRun); err != nil {
+			return plan, err
+		}
+
+		if err := driver.WriteVersion(migrationToRun.version, migrationToRun.replaces); err != nil {
+			return plan, fmt.Errorf("error writing migration version to driver: %w", err)
 		}
+		plan.EndingVersion = migrationToRun.version
+	}
+
+	return plan, nil
+}
+
+// Status returns the version of the backing datastore and the migrations which must be run
+// to bring it to the head revision, without running them.
+func (m *Manager) Status(driver Driver) (Status, error) {
+	current, err := driver.Version()
+	if err != nil {
+		return Status{}, fmt.Errorf("unable to load version from driver: %w", err)
 	}
 
+	head, err := m.HeadRevision()
+	if err != nil {
+		return Status{}, fmt.Errorf("unable to compute head revision: %w", err)
+	}
+
+	toRun, err := collectMigrationsInRange(current, head, m.migrations)
+	if err != nil {
+		return Status{}, fmt.Errorf("unable to compute pending migrations from %s: %w", current, err)
+	}
+
+	status := Status{
+		CurrentVersion: current,
+		HeadVersion:    head,
+		Pending:        make([]PlannedMigration, 0, len(toRun)),
+	}
+	for _, pending := range toRun {
+		status.Pending = append(status.Pending, PlannedMigration{
+			Version:  pending.version,
+			Replaces: pending.replaces,
+		})
+	}
+	return status, nil
+}
+
+func callUp(driver Driver, migrationToRun migration) error {
+	in := []reflect.Value{reflect.ValueOf(driver)}
+	upFunction := reflect.ValueOf(migrationToRun.up)
+
+	errArg := upFunction.Call(in)[0]
+	if !errArg.IsNil() {
+		return fmt.Errorf("error running migration up function: %v", errArg)
+	}
 	return nil
 }
 
@@ -150,3 +150,105 @@ var missingEarlyMigrations = map[string]migration{
 	"789": {"789", "456", nil},
 	"10":  {"10", "789", nil},
 }
+
+type recordingDriver struct {
+	version   string
+	recording bool
+	recorded  []string
+	executed  []string
+}
+
+func (rd *recordingDriver) Version() (string, error) {
+	return rd.version, nil
+}
+
+func (rd *recordingDriver) WriteVersion(version, replaced string) error {
+	rd.version = version
+	return nil
+}
+
+func (rd *recordingDriver) StartRecording() {
+	rd.recording = true
+}
+
+func (rd *recordingDriver) StopRecording() []string {
+	recorded := rd.recorded
+	rd.recording = false
+	rd.recorded = nil
+	return recorded
+}
+
+func (rd *recordingDriver) exec(stmt string) error {
+	if rd.recording {
+		rd.recorded = append(rd.recorded, stmt)
+	} else {
+		rd.executed = append(rd.executed, stmt)
+	}
+	return nil
+}
+
+func TestRunWithPlan(t *testing.T) {
+	require := require.New(t)
+
+	m := NewManager()
+	require.NoError(m.Register("123", "", func(rd *recordingDriver) error {
+		return rd.exec("CREATE TABLE first")
+	}))
+	require.NoError(m.Register("456", "123", func(rd *recordingDriver) error {
+		return rd.exec("CREATE TABLE second")
+	}))
+
+	driver := &recordingDriver{version: "123"}
+	plan, err := m.RunWithPlan(driver, Head, DryRun)
+	require.NoError(err)
+	require.Equal(Plan{
+		StartingVersion: "123",
+		EndingVersion:   "456",
+		DryRun:          true,
+		Migrations: []PlannedMigration{
+			{Version: "456", Replaces: "123", Statements: []string{"CREATE TABLE second"}},
+		},
+	}, plan)
+	require.Equal("123", driver.version)
+	require.Empty(driver.executed)
+
+	driver = &recordingDriver{}
+	plan, err = m.RunWithPlan(driver, Head, LiveRun)
+	require.NoError(err)
+	require.Equal(Plan{
+		StartingVersion: "",
+		EndingVersion:   "456",
+		Migrations: []PlannedMigration{
+			{Version: "123", Replaces: ""},
+			{Version: "456", Replaces: "123"},
+		},
+	}, plan)
+	require.Equal("456", driver.version)
+	require.Equal([]string{"CREATE TABLE first", "CREATE TABLE second"}, driver.executed)
+}
+
+func TestStatus(t *testing.T) {
+	require := require.New(t)
+
+	m := NewManager()
+	require.NoError(m.Register("123", "", func(rd *recordingDriver) error { return nil }))
+	require.NoError(m.Register("456", "123", func(rd *recordingDriver) error { return nil }))
+
+	status, err := m.Status(&recordingDriver{version: "123"})
+	require.NoError(err)
+	require.Equal(Status{
+		CurrentVersion: "123",
+		HeadVersion:    "456",
+		Pending:        []PlannedMigration{{Version: "456", Replaces: "123"}},
+	}, status)
+	require.False(status.UpToDate())
+
+	status, err = m.Status(&recordingDriver{version: "456"})
+	require.NoError(err)
+	require.True(status.UpToDate())
+
+	// A version unknown to the migrations, such as one written by a newer release, has no
+	// path to the head revision.
+	_, err = m.Status(&recordingDriver{version: "789"})
+	require.Error(err)
+}
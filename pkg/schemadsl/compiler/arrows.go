@@ -0,0 +1,179 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+
+	"github.com/authzed/spicedb/pkg/namespace"
+	"github.com/authzed/spicedb/pkg/schemadsl/dslshape"
+)
+
+// maxRelationNameLength is the maximum length of the name of a relation or permission.
+const maxRelationNameLength = 64
+
+// arrowChain is an arrow of more than one hop, such as `parent->org->admin`, which is
+// translated into an arrow from the tupleset relation to a permission synthesized on each
+// of the types allowed on the tupleset relation, walking the remaining relations.
+type arrowChain struct {
+	// node is the arrow expression, against which errors are reported.
+	node *dslNode
+
+	// namespaceName is the definition of the tupleset relation.
+	namespaceName string
+
+	// tuplesetRelation is the relation walked first by the arrow.
+	tuplesetRelation string
+
+	// walked are the relations walked by the synthesized permission; at least two.
+	walked []string
+}
+
+// arrowRelations returns the relations walked by the arrow expression, in order.
+func arrowRelations(arrowNode *dslNode) ([]string, error) {
+	leftChild, err := arrowNode.Lookup(dslshape.NodeExpressionPredicateLeftExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	rightChild, err := arrowNode.Lookup(dslshape.NodeExpressionPredicateRightExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	var relations []string
+	switch leftChild.GetType() {
+	case dslshape.NodeTypeArrowExpression:
+		relations, err = arrowRelations(leftChild)
+		if err != nil {
+			return nil, err
+		}
+
+	case dslshape.NodeTypeIdentifier:
+		tuplesetRelation, err := leftChild.GetString(dslshape.NodeIdentiferPredicateValue)
+		if err != nil {
+			return nil, err
+		}
+		relations = []string{tuplesetRelation}
+
+	default:
+		return nil, leftChild.Errorf("arrows can only walk relations")
+	}
+
+	usersetRelation, err := rightChild.GetString(dslshape.NodeIdentiferPredicateValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(relations, usersetRelation), nil
+}
+
+// synthesizedArrowName returns the name of the permission synthesized to walk the
+// relations.
+func synthesizedArrowName(walked []string) (string, error) {
+	name := "arrow_" + strings.Join(walked, "_")
+	if len(name) > maxRelationNameLength {
+		return "", fmt.Errorf("arrow `%s` is too long to synthesize a permission for", strings.Join(walked, "->"))
+	}
+	return name, nil
+}
+
+// synthesizeArrows adds the permissions walked by the arrows of more than one hop to the
+// definitions. Each permission is added to those of the types allowed on the tupleset
+// relation which have the next relation walked, and is marked with a comment so that it is
+// recognizable when the schema is read back.
+func synthesizeArrows(definitions []*v0.NamespaceDefinition, arrows []arrowChain) error {
+	byName := make(map[string]*v0.NamespaceDefinition, len(definitions))
+	for _, definition := range definitions {
+		byName[definition.Name] = definition
+	}
+
+	synthesized := map[string]struct{}{}
+	for len(arrows) > 0 {
+		current := arrows[0]
+		arrows = arrows[1:]
+
+		// A missing tupleset relation, or one without types, is reported by the
+		// validation of the type system.
+		tupleset := findRelation(byName[current.namespaceName], current.tuplesetRelation)
+		if tupleset == nil || tupleset.TypeInformation == nil {
+			continue
+		}
+
+		name, err := synthesizedArrowName(current.walked)
+		if err != nil {
+			return current.node.Errorf("%w", err)
+		}
+
+		// Unknown types are likewise reported by the validation of the type system.
+		known, found := false, false
+		for _, allowed := range tupleset.TypeInformation.AllowedDirectRelations {
+			target, ok := byName[allowed.Namespace]
+			if !ok {
+				continue
+			}
+			known = true
+
+			if findRelation(target, current.walked[0]) == nil {
+				continue
+			}
+			found = true
+
+			key := target.Name + "#" + name
+			if _, ok := synthesized[key]; ok {
+				continue
+			}
+
+			if findRelation(target, name) != nil {
+				return current.node.Errorf("cannot synthesize permission `%s` under definition `%s`, as a relation or permission of that name exists", name, target.Name)
+			}
+
+			var child *v0.SetOperation_Child
+			if len(current.walked) == 2 {
+				child = namespace.TupleToUserset(current.walked[0], current.walked[1])
+			} else {
+				nextName, err := synthesizedArrowName(current.walked[1:])
+				if err != nil {
+					return current.node.Errorf("%w", err)
+				}
+
+				child = namespace.TupleToUserset(current.walked[0], nextName)
+				arrows = append(arrows, arrowChain{
+					node:             current.node,
+					namespaceName:    target.Name,
+					tuplesetRelation: current.walked[0],
+					walked:           current.walked[1:],
+				})
+			}
+
+			permission := namespace.Relation(name, namespace.Union(child))
+			permission.Metadata, err = namespace.AddComment(permission.Metadata, fmt.Sprintf("// synthesized for arrow %s", strings.Join(current.walked, "->")))
+			if err != nil {
+				return current.node.Errorf("%w", err)
+			}
+
+			synthesized[key] = struct{}{}
+			target.Relation = append(target.Relation, permission)
+		}
+
+		if known && !found {
+			return current.node.Errorf("no type allowed on relation `%s` under definition `%s` has relation/permission `%s` for arrow `%s`", current.tuplesetRelation, current.namespaceName, current.walked[0], strings.Join(append([]string{current.tuplesetRelation}, current.walked...), "->"))
+		}
+	}
+
+	return nil
+}
+
+func findRelation(definition *v0.NamespaceDefinition, relationName string) *v0.Relation {
+	if definition == nil {
+		return nil
+	}
+
+	for _, relation := range definition.Relation {
+		if relation.Name == relationName {
+			return relation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package compiler
+
+import (
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/dslshape"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+// translateBundle translates each of the schemas, with the templates of the schemas it
+// imports, composes the definitions translated with the partial definitions adding to
+// them, and synthesizes the permissions walked by arrows of more than one hop.
+func translateBundle(tctx translationContext, schemas []InputSchema, roots []*dslNode) ([]*v0.NamespaceDefinition, error) {
+	visible, err := visibleSources(schemas, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	templatesBySource := make(map[input.Source]map[string]*dslNode, len(schemas))
+	for index, root := range roots {
+		templates, err := findTemplates(root)
+		if err != nil {
+			return nil, err
+		}
+		templatesBySource[schemas[index].Source] = templates
+	}
+
+	arrows := []arrowChain{}
+	tctx.arrows = &arrows
+
+	translated := []translatedDefinition{}
+	for index, root := range roots {
+		source := schemas[index].Source
+
+		// The templates are collected in the order of the schemas, so that conflicts are
+		// reported against the latest of the templates.
+		tctx.templates = map[string]*dslNode{}
+		for _, schema := range schemas {
+			if _, ok := visible[source][schema.Source]; !ok {
+				continue
+			}
+
+			for name, templateNode := range templatesBySource[schema.Source] {
+				if existing, ok := tctx.templates[name]; ok {
+					return nil, templateNode.Errorf("template `%s` is defined in both `%s` and `%s`", name, nodeSource(existing), schema.Source)
+				}
+				tctx.templates[name] = templateNode
+			}
+		}
+
+		definitions, err := translate(tctx, root)
+		if err != nil {
+			return nil, err
+		}
+		translated = append(translated, definitions...)
+	}
+
+	definitions, err := composeDefinitions(translated, visible)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := synthesizeArrows(definitions, arrows); err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+// visibleSources returns, for each schema, the sources visible to it: its own and those it
+// imports, directly or through other imports.
+func visibleSources(schemas []InputSchema, roots []*dslNode) (map[input.Source]map[input.Source]struct{}, error) {
+	known := make(map[input.Source]struct{}, len(schemas))
+	for _, schema := range schemas {
+		known[schema.Source] = struct{}{}
+	}
+
+	imports := make(map[input.Source][]input.Source, len(schemas))
+	for index, root := range roots {
+		for _, importNode := range root.GetChildren() {
+			if importNode.GetType() != dslshape.NodeTypeImport {
+				continue
+			}
+
+			path, err := importNode.GetString(dslshape.NodeImportPredicatePath)
+			if err != nil {
+				return nil, importNode.Errorf("invalid import: %w", err)
+			}
+
+			if _, ok := known[input.Source(path)]; !ok {
+				return nil, importNode.Errorf("imported schema `%s` not found", path)
+			}
+
+			imports[schemas[index].Source] = append(imports[schemas[index].Source], input.Source(path))
+		}
+	}
+
+	visible := make(map[input.Source]map[input.Source]struct{}, len(schemas))
+	for _, schema := range schemas {
+		reached := map[input.Source]struct{}{}
+
+		var visit func(source input.Source)
+		visit = func(source input.Source) {
+			if _, ok := reached[source]; ok {
+				return
+			}
+			reached[source] = struct{}{}
+			for _, imported := range imports[source] {
+				visit(imported)
+			}
+		}
+
+		visit(schema.Source)
+		visible[schema.Source] = reached
+	}
+	return visible, nil
+}
+
+func findTemplates(root *dslNode) (map[string]*dslNode, error) {
+	templates := map[string]*dslNode{}
+	for _, templateNode := range root.GetChildren() {
+		if templateNode.GetType() != dslshape.NodeTypeTemplate {
+			continue
+		}
+
+		templateName, err := templateNode.GetString(dslshape.NodeTemplatePredicateName)
+		if err != nil {
+			return nil, templateNode.Errorf("invalid template name: %w", err)
+		}
+
+		if _, ok := templates[templateName]; ok {
+			return nil, templateNode.Errorf("found duplicate template `%s`", templateName)
+		}
+
+		templates[templateName] = templateNode
+	}
+	return templates, nil
+}
+
+// composeDefinitions adds the relations and permissions of each partial definition to the
+// definition of the same name, which must be found in the schema of the partial definition
+// or in one it imports.
+func composeDefinitions(translated []translatedDefinition, visible map[input.Source]map[input.Source]struct{}) ([]*v0.NamespaceDefinition, error) {
+	definitions := []*v0.NamespaceDefinition{}
+	byName := map[string]translatedDefinition{}
+	relationSources := map[string]map[string]input.Source{}
+	for _, td := range translated {
+		if td.node.Has(dslshape.NodeDefinitionPredicatePartial) {
+			continue
+		}
+
+		name := td.definition.Name
+		if existing, ok := byName[name]; ok {
+			if nodeSource(existing.node) == nodeSource(td.node) {
+				return nil, td.node.Errorf("found duplicate definition `%s`", name)
+			}
+			return nil, td.node.Errorf("definition `%s` is defined in both `%s` and `%s`", name, nodeSource(existing.node), nodeSource(td.node))
+		}
+
+		byName[name] = td
+		relationSources[name] = map[string]input.Source{}
+		for _, relation := range td.definition.Relation {
+			relationSources[name][relation.Name] = nodeSource(td.node)
+		}
+		definitions = append(definitions, td.definition)
+	}
+
+	for _, td := range translated {
+		if !td.node.Has(dslshape.NodeDefinitionPredicatePartial) {
+			continue
+		}
+
+		name, source := td.definition.Name, nodeSource(td.node)
+		base, ok := byName[name]
+		if !ok {
+			return nil, td.node.Errorf("partial definition `%s` adds to no definition", name)
+		}
+
+		if _, ok := visible[source][nodeSource(base.node)]; !ok {
+			return nil, td.node.Errorf("partial definition `%s` adds to the definition in `%s`, which is not imported", name, nodeSource(base.node))
+		}
+
+		for _, relation := range td.definition.Relation {
+			if existing, ok := relationSources[name][relation.Name]; ok {
+				return nil, td.node.Errorf("relation/permission `%s` of definition `%s` is defined in both `%s` and `%s`", relation.Name, name, existing, source)
+			}
+
+			relationSources[name][relation.Name] = source
+			base.definition.Relation = append(base.definition.Relation, relation)
+		}
+	}
+
+	return definitions, nil
+}
+
+func nodeSource(node *dslNode) input.Source {
+	source, _ := node.GetString(dslshape.NodePredicateSource)
+	return input.Source(source)
+}
@@ -33,11 +33,16 @@ type errorWithNode struct {
 }
 
 // Compile compilers the input schema(s) into a set of namespace definition protos.
+//
+// The schemas are compiled as a bundle: each may import the others by their source, making
+// the templates of the imported schemas available to it, and may declare partial
+// definitions, which add relations and permissions to a definition of the same name in
+// itself or in a schema it imports.
 func Compile(schemas []InputSchema, objectTypePrefix *string) ([]*v0.NamespaceDefinition, error) {
 	mapper := newPositionMapper(schemas)
 
-	// Parse and translate the various schemas.
-	definitions := []*v0.NamespaceDefinition{}
+	// Parse the various schemas.
+	roots := make([]*dslNode, 0, len(schemas))
 	for _, schema := range schemas {
 		root := parser.Parse(createAstNode, schema.Source, schema.SchemaString).(*dslNode)
 		errs := root.FindAll(dslshape.NodeTypeError)
@@ -46,19 +51,20 @@ func Compile(schemas []InputSchema, objectTypePrefix *string) ([]*v0.NamespaceDe
 			return []*v0.NamespaceDefinition{}, err
 		}
 
-		translatedDefs, err := translate(translationContext{
-			objectTypePrefix: objectTypePrefix,
-		}, root)
-		if err != nil {
-			var errorWithNode errorWithNode
-			if errors.As(err, &errorWithNode) {
-				err = toContextError(errorWithNode.error.Error(), errorWithNode.node, mapper)
-			}
+		roots = append(roots, root)
+	}
 
-			return []*v0.NamespaceDefinition{}, err
+	// Translate and compose the schemas.
+	definitions, err := translateBundle(translationContext{
+		objectTypePrefix: objectTypePrefix,
+	}, schemas, roots)
+	if err != nil {
+		var errorWithNode errorWithNode
+		if errors.As(err, &errorWithNode) {
+			err = toContextError(errorWithNode.error.Error(), errorWithNode.node, mapper)
 		}
 
-		definitions = append(definitions, translatedDefs...)
+		return []*v0.NamespaceDefinition{}, err
 	}
 
 	return definitions, nil
@@ -295,34 +295,117 @@ func TestCompile(t *testing.T) {
 		{
 			"multiarrow permission",
 			&someTenant,
-			`definition arrowed {
-				relation somerel: something;
-				permission foos = somerel->brel->crel
+			`definition org {
+				relation admin: user
+			}
+
+			definition folder {
+				relation org: org
+			}
+
+			definition arrowed {
+				relation parent: folder
+				permission foos = parent->org->admin
 			}`,
-			"parse error in `multiarrow permission`, line 3, column 23: Nested arrows not yet supported",
-			[]*v0.NamespaceDefinition{},
+			"",
+			[]*v0.NamespaceDefinition{
+				namespace.Namespace("sometenant/org",
+					namespace.Relation("admin", nil,
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+				),
+				namespace.Namespace("sometenant/folder",
+					namespace.Relation("org", nil,
+						namespace.AllowedRelation("sometenant/org", "..."),
+					),
+					namespace.RelationWithComment("arrow_org_admin", "// synthesized for arrow org->admin",
+						namespace.Union(
+							namespace.TupleToUserset("org", "admin"),
+						),
+					),
+				),
+				namespace.Namespace("sometenant/arrowed",
+					namespace.Relation("parent", nil,
+						namespace.AllowedRelation("sometenant/folder", "..."),
+					),
+					namespace.Relation("foos",
+						namespace.Union(
+							namespace.TupleToUserset("parent", "arrow_org_admin"),
+						),
+					),
+				),
+			},
 		},
+		{
+			"three hop arrow permission",
+			&someTenant,
+			`definition org {
+				relation admin: user
+			}
 
-		/*
-			 * TODO: uncomment once supported and remove the test above
-			{
-				"multiarrow permission",
-				`definition arrowed {
-					relation somerel: something;
-					permission foo = somerel->brel->crel
-				}`,
-				"",
-				[]*v0.NamespaceDefinition{
-					namespace.Namespace("sometenant/arrowed",
-						namespace.Relation("foo",
-							namespace.Union(
-								namespace.TupleToUserset("bar", "baz"),
-							),
+			definition folder {
+				relation org: org
+			}
+
+			definition document {
+				relation folder: folder
+			}
+
+			definition arrowed {
+				relation doc: document
+				permission foos = doc->folder->org->admin
+			}`,
+			"",
+			[]*v0.NamespaceDefinition{
+				namespace.Namespace("sometenant/org",
+					namespace.Relation("admin", nil,
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+				),
+				namespace.Namespace("sometenant/folder",
+					namespace.Relation("org", nil,
+						namespace.AllowedRelation("sometenant/org", "..."),
+					),
+					namespace.RelationWithComment("arrow_org_admin", "// synthesized for arrow org->admin",
+						namespace.Union(
+							namespace.TupleToUserset("org", "admin"),
+						),
+					),
+				),
+				namespace.Namespace("sometenant/document",
+					namespace.Relation("folder", nil,
+						namespace.AllowedRelation("sometenant/folder", "..."),
+					),
+					namespace.RelationWithComment("arrow_folder_org_admin", "// synthesized for arrow folder->org->admin",
+						namespace.Union(
+							namespace.TupleToUserset("folder", "arrow_org_admin"),
 						),
 					),
-				},
+				),
+				namespace.Namespace("sometenant/arrowed",
+					namespace.Relation("doc", nil,
+						namespace.AllowedRelation("sometenant/document", "..."),
+					),
+					namespace.Relation("foos",
+						namespace.Union(
+							namespace.TupleToUserset("doc", "arrow_folder_org_admin"),
+						),
+					),
+				),
 			},
-		*/
+		},
+		{
+			"multiarrow permission without walked relation",
+			&someTenant,
+			`definition folder {}
+
+			definition arrowed {
+				relation parent: folder
+				permission foos = parent->org->admin
+			}`,
+			"parse error in `multiarrow permission without walked relation`, line 5, column 23: no type allowed on relation `parent` under definition `sometenant/arrowed` has relation/permission `org` for arrow `parent->org->admin`",
+			[]*v0.NamespaceDefinition{},
+		},
 
 		{
 			"expression permission",
@@ -479,6 +562,81 @@ func TestCompile(t *testing.T) {
 				),
 			},
 		},
+		{
+			"template use",
+			&someTenant,
+			`template ownable(ownertype) {
+				relation owner: ownertype
+				permission manage = owner
+			}
+
+			definition document {
+				use ownable(user)
+				relation viewer: user
+			}`,
+			"",
+			[]*v0.NamespaceDefinition{
+				namespace.Namespace("sometenant/document",
+					namespace.RelationWithComment("owner", "// expanded from template ownable(user)", nil,
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+					namespace.RelationWithComment("manage", "// expanded from template ownable(user)",
+						namespace.Union(
+							namespace.ComputedUserset("owner"),
+						),
+					),
+					namespace.Relation("viewer", nil,
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+				),
+			},
+		},
+		{
+			"template with multiple parameters",
+			&someTenant,
+			`template shared(ownertype, viewertype) {
+				relation owner: ownertype
+				relation viewer: viewertype | ownertype
+			}
+
+			definition folder {
+				use shared(user, team)
+			}`,
+			"",
+			[]*v0.NamespaceDefinition{
+				namespace.Namespace("sometenant/folder",
+					namespace.RelationWithComment("owner", "// expanded from template shared(user, team)", nil,
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+					namespace.RelationWithComment("viewer", "// expanded from template shared(user, team)", nil,
+						namespace.AllowedRelation("sometenant/team", "..."),
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+				),
+			},
+		},
+		{
+			"unknown template",
+			&someTenant,
+			`definition document {
+				use ownable(user)
+			}`,
+			"parse error in `unknown template`, line 2, column 5: template `ownable` not found",
+			[]*v0.NamespaceDefinition{},
+		},
+		{
+			"template argument count mismatch",
+			&someTenant,
+			`template ownable(ownertype) {
+				relation owner: ownertype
+			}
+
+			definition document {
+				use ownable(user, team)
+			}`,
+			"parse error in `template argument count mismatch`, line 6, column 5: template `ownable` expects 1 argument(s), found 2",
+			[]*v0.NamespaceDefinition{},
+		},
 	}
 
 	for _, test := range tests {
@@ -498,3 +656,146 @@ func TestCompile(t *testing.T) {
 		})
 	}
 }
+
+func TestCompileBundle(t *testing.T) {
+	baseSchema := InputSchema{input.Source("base.zed"), `template ownable(ownertype) {
+				relation owner: ownertype
+			}
+
+			definition user {}
+
+			definition document {
+				relation viewer: user
+			}`}
+
+	tests := []struct {
+		name          string
+		schemas       []InputSchema
+		expectedError string
+		expectedProto []*v0.NamespaceDefinition
+	}{
+		{
+			"imported templates and partial definitions",
+			[]InputSchema{
+				baseSchema,
+				{input.Source("teams.zed"), `import "base.zed"
+
+				definition team {
+					use ownable(user)
+				}
+
+				partial definition document {
+					relation team: team
+					permission view = viewer + team->owner
+				}`},
+			},
+			"",
+			[]*v0.NamespaceDefinition{
+				namespace.Namespace("user"),
+				namespace.Namespace("document",
+					namespace.Relation("viewer", nil,
+						namespace.AllowedRelation("user", "..."),
+					),
+					namespace.Relation("team", nil,
+						namespace.AllowedRelation("team", "..."),
+					),
+					namespace.Relation("view",
+						namespace.Union(
+							namespace.ComputedUserset("viewer"),
+							namespace.TupleToUserset("team", "owner"),
+						),
+					),
+				),
+				namespace.Namespace("team",
+					namespace.RelationWithComment("owner", "// expanded from template ownable(user)", nil,
+						namespace.AllowedRelation("user", "..."),
+					),
+				),
+			},
+		},
+		{
+			"unknown import",
+			[]InputSchema{
+				{input.Source("teams.zed"), `import "missing.zed"`},
+			},
+			"parse error in `teams.zed`, line 1, column 1: imported schema `missing.zed` not found",
+			nil,
+		},
+		{
+			"template not imported",
+			[]InputSchema{
+				baseSchema,
+				{input.Source("teams.zed"), `definition team {
+					use ownable(user)
+				}`},
+			},
+			"parse error in `teams.zed`, line 2, column 6: template `ownable` not found",
+			nil,
+		},
+		{
+			"conflicting templates",
+			[]InputSchema{
+				baseSchema,
+				{input.Source("teams.zed"), `import "base.zed"
+template ownable(ownertype) {}`},
+			},
+			"parse error in `teams.zed`, line 2, column 1: template `ownable` is defined in both `base.zed` and `teams.zed`",
+			nil,
+		},
+		{
+			"conflicting definitions",
+			[]InputSchema{
+				baseSchema,
+				{input.Source("teams.zed"), `definition user {}`},
+			},
+			"parse error in `teams.zed`, line 1, column 1: definition `user` is defined in both `base.zed` and `teams.zed`",
+			nil,
+		},
+		{
+			"partial definition of nothing",
+			[]InputSchema{
+				{input.Source("teams.zed"), `partial definition team {}`},
+			},
+			"parse error in `teams.zed`, line 1, column 1: partial definition `team` adds to no definition",
+			nil,
+		},
+		{
+			"partial definition not imported",
+			[]InputSchema{
+				baseSchema,
+				{input.Source("teams.zed"), `partial definition document {}`},
+			},
+			"parse error in `teams.zed`, line 1, column 1: partial definition `document` adds to the definition in `base.zed`, which is not imported",
+			nil,
+		},
+		{
+			"conflicting relations",
+			[]InputSchema{
+				baseSchema,
+				{input.Source("teams.zed"), `import "base.zed"
+partial definition document {
+	relation viewer: user
+}`},
+			},
+			"parse error in `teams.zed`, line 2, column 1: relation/permission `viewer` of definition `document` is defined in both `base.zed` and `teams.zed`",
+			nil,
+		},
+	}
+
+	emptyDefaultPrefix := ""
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defs, err := Compile(test.schemas, &emptyDefaultPrefix)
+
+			if test.expectedError != "" {
+				require.Error(err)
+				require.Equal(test.expectedError, err.Error())
+			} else {
+				require.Nil(err)
+				require.Equal(test.expectedProto, defs)
+			}
+		})
+	}
+}
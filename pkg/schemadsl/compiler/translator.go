@@ -14,6 +14,20 @@ import (
 
 type translationContext struct {
 	objectTypePrefix *string
+
+	// templates are the templates defined in the schema, by name.
+	templates map[string]*dslNode
+
+	// templateArgs are the object types bound to the parameters of the template
+	// currently being expanded, if any.
+	templateArgs map[string]string
+
+	// namespaceName is the full name of the definition currently being translated.
+	namespaceName string
+
+	// arrows collects the arrows of more than one hop found while translating, for
+	// which permissions are synthesized once all definitions are composed.
+	arrows *[]arrowChain
 }
 
 func (tctx translationContext) namespacePath(namespaceName string) (string, error) {
@@ -35,15 +49,25 @@ func (tctx translationContext) namespacePath(namespaceName string) (string, erro
 
 const Ellipsis = "..."
 
-func translate(tctx translationContext, root *dslNode) ([]*v0.NamespaceDefinition, error) {
-	definitions := []*v0.NamespaceDefinition{}
+// translatedDefinition is a definition translated from a schema, along with its node.
+type translatedDefinition struct {
+	definition *v0.NamespaceDefinition
+	node       *dslNode
+}
+
+func translate(tctx translationContext, root *dslNode) ([]translatedDefinition, error) {
+	definitions := []translatedDefinition{}
 	for _, definitionNode := range root.GetChildren() {
+		if definitionNode.GetType() != dslshape.NodeTypeDefinition {
+			continue
+		}
+
 		definition, err := translateDefinition(tctx, definitionNode)
 		if err != nil {
-			return []*v0.NamespaceDefinition{}, err
+			return []translatedDefinition{}, err
 		}
 
-		definitions = append(definitions, definition)
+		definitions = append(definitions, translatedDefinition{definition, definitionNode})
 	}
 
 	return definitions, nil
@@ -55,12 +79,28 @@ func translateDefinition(tctx translationContext, defNode *dslNode) (*v0.Namespa
 		return nil, defNode.Errorf("invalid definition name: %w", err)
 	}
 
+	nspath, err := tctx.namespacePath(definitionName)
+	if err != nil {
+		return nil, defNode.Errorf("%w", err)
+	}
+	tctx.namespaceName = nspath
+
 	relationsAndPermissions := []*v0.Relation{}
 	for _, relationOrPermissionNode := range defNode.GetChildren() {
 		if relationOrPermissionNode.GetType() == dslshape.NodeTypeComment {
 			continue
 		}
 
+		if relationOrPermissionNode.GetType() == dslshape.NodeTypeUse {
+			expanded, err := translateUse(tctx, relationOrPermissionNode)
+			if err != nil {
+				return nil, err
+			}
+
+			relationsAndPermissions = append(relationsAndPermissions, expanded...)
+			continue
+		}
+
 		relationOrPermission, err := translateRelationOrPermission(tctx, relationOrPermissionNode)
 		if err != nil {
 			return nil, err
@@ -69,11 +109,6 @@ func translateDefinition(tctx translationContext, defNode *dslNode) (*v0.Namespa
 		relationsAndPermissions = append(relationsAndPermissions, relationOrPermission)
 	}
 
-	nspath, err := tctx.namespacePath(definitionName)
-	if err != nil {
-		return nil, defNode.Errorf("%w", err)
-	}
-
 	if len(relationsAndPermissions) == 0 {
 		ns := namespace.Namespace(nspath)
 		ns.Metadata = addComments(ns.Metadata, defNode)
@@ -97,6 +132,80 @@ func translateDefinition(tctx translationContext, defNode *dslNode) (*v0.Namespa
 	return ns, nil
 }
 
+// translateUse expands the relations and permissions of the template referenced
+// by a use node, with the template's parameters bound to the supplied object types.
+// Each expanded relation and permission is annotated with a comment recording the
+// template from which it was expanded, so that expansions remain visible when
+// the schema is read back.
+func translateUse(tctx translationContext, useNode *dslNode) ([]*v0.Relation, error) {
+	templateName, err := useNode.GetString(dslshape.NodeTemplatePredicateName)
+	if err != nil {
+		return nil, useNode.Errorf("invalid template name: %w", err)
+	}
+
+	templateNode, ok := tctx.templates[templateName]
+	if !ok {
+		return nil, useNode.Errorf("template `%s` not found", templateName)
+	}
+
+	params := templateNode.List(dslshape.NodeTemplatePredicateParameter)
+	args := useNode.List(dslshape.NodeUsePredicateArgument)
+	if len(params) != len(args) {
+		return nil, useNode.Errorf("template `%s` expects %d argument(s), found %d", templateName, len(params), len(args))
+	}
+
+	templateArgs := make(map[string]string, len(params))
+	argNames := make([]string, 0, len(args))
+	for index, paramNode := range params {
+		paramName, err := paramNode.GetString(dslshape.NodeIdentiferPredicateValue)
+		if err != nil {
+			return nil, paramNode.Errorf("invalid template parameter: %w", err)
+		}
+
+		argNode := args[index]
+		if argNode.Has(dslshape.NodeSpecificReferencePredicateRelation) || argNode.Has(dslshape.NodeSpecificReferencePredicateWildcard) {
+			return nil, argNode.Errorf("template arguments must be object types")
+		}
+
+		argPath, err := argNode.GetString(dslshape.NodeSpecificReferencePredicateType)
+		if err != nil {
+			return nil, argNode.Errorf("invalid template argument: %w", err)
+		}
+
+		nspath, err := tctx.namespacePath(argPath)
+		if err != nil {
+			return nil, argNode.Errorf("%w", err)
+		}
+
+		templateArgs[paramName] = nspath
+		argNames = append(argNames, argPath)
+	}
+
+	tctx.templateArgs = templateArgs
+	expansionComment := fmt.Sprintf("// expanded from template %s(%s)", templateName, strings.Join(argNames, ", "))
+
+	expanded := []*v0.Relation{}
+	for _, relationOrPermissionNode := range templateNode.GetChildren() {
+		if relationOrPermissionNode.GetType() == dslshape.NodeTypeComment {
+			continue
+		}
+
+		relationOrPermission, err := translateRelationOrPermission(tctx, relationOrPermissionNode)
+		if err != nil {
+			return nil, err
+		}
+
+		relationOrPermission.Metadata, err = namespace.AddComment(relationOrPermission.Metadata, expansionComment)
+		if err != nil {
+			return nil, relationOrPermissionNode.Errorf("%w", err)
+		}
+
+		expanded = append(expanded, relationOrPermission)
+	}
+
+	return expanded, nil
+}
+
 func addComments(mdmsg *v0.Metadata, dslNode *dslNode) *v0.Metadata {
 	for _, child := range dslNode.GetChildren() {
 		if child.GetType() == dslshape.NodeTypeComment {
@@ -260,31 +369,29 @@ func translateExpressionOperation(tctx translationContext, expressionOpNode *dsl
 		return namespace.ComputedUserset(referencedRelationName), nil
 
 	case dslshape.NodeTypeArrowExpression:
-		leftChild, err := expressionOpNode.Lookup(dslshape.NodeExpressionPredicateLeftExpr)
-		if err != nil {
-			return nil, err
-		}
-
-		rightChild, err := expressionOpNode.Lookup(dslshape.NodeExpressionPredicateRightExpr)
+		chain, err := arrowRelations(expressionOpNode)
 		if err != nil {
 			return nil, err
 		}
 
-		if leftChild.GetType() != dslshape.NodeTypeIdentifier {
-			return nil, leftChild.Errorf("Nested arrows not yet supported")
+		if len(chain) == 2 {
+			return namespace.TupleToUserset(chain[0], chain[1]), nil
 		}
 
-		tuplesetRelation, err := leftChild.GetString(dslshape.NodeIdentiferPredicateValue)
+		// An arrow of more than one hop walks the tupleset relation to a permission
+		// synthesized on the types it allows, which walks the rest of the arrow.
+		synthesizedName, err := synthesizedArrowName(chain[1:])
 		if err != nil {
-			return nil, err
+			return nil, expressionOpNode.Errorf("%w", err)
 		}
 
-		usersetRelation, err := rightChild.GetString(dslshape.NodeIdentiferPredicateValue)
-		if err != nil {
-			return nil, err
-		}
-
-		return namespace.TupleToUserset(tuplesetRelation, usersetRelation), nil
+		*tctx.arrows = append(*tctx.arrows, arrowChain{
+			node:             expressionOpNode,
+			namespaceName:    tctx.namespaceName,
+			tuplesetRelation: chain[0],
+			walked:           chain[1:],
+		})
+		return namespace.TupleToUserset(chain[0], synthesizedName), nil
 
 	case dslshape.NodeTypeUnionExpression:
 		fallthrough
@@ -336,9 +443,13 @@ func translateSpecificTypeReference(tctx translationContext, typeRefNode *dslNod
 		return nil, typeRefNode.Errorf("invalid type name: %w", err)
 	}
 
-	nspath, err := tctx.namespacePath(typePath)
-	if err != nil {
-		return nil, typeRefNode.Errorf("%w", err)
+	// Template parameters take precedence over object types of the same name.
+	nspath, ok := tctx.templateArgs[typePath]
+	if !ok {
+		nspath, err = tctx.namespacePath(typePath)
+		if err != nil {
+			return nil, typeRefNode.Errorf("%w", err)
+		}
 	}
 
 	if typeRefNode.Has(dslshape.NodeSpecificReferencePredicateWildcard) {
@@ -0,0 +1,281 @@
+// Package docs generates human-readable documentation of a compiled schema.
+package docs
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+
+	"github.com/authzed/spicedb/pkg/graph"
+	"github.com/authzed/spicedb/pkg/namespace"
+)
+
+// Ellipsis is the relation name for terminal subjects.
+const Ellipsis = "..."
+
+// Operation is the set operation applied to the children of an expression.
+type Operation string
+
+const (
+	OperationUnion        Operation = "union"
+	OperationIntersection Operation = "intersection"
+	OperationExclusion    Operation = "exclusion"
+)
+
+// Definition is the documentation of an object type.
+type Definition struct {
+	Name        string
+	Comment     string
+	Relations   []Relation
+	Permissions []Relation
+}
+
+// Relation is the documentation of a relation or permission of an object type.
+type Relation struct {
+	Name    string
+	Comment string
+
+	// SubjectTypes are the types of subjects which can be written directly to a relation,
+	// or which can be reached through the expression of a permission.
+	SubjectTypes []string
+
+	// Expression is the expression computing a permission, or nil for a relation.
+	Expression *Expression
+}
+
+// Expression is a node of the expression tree computing a permission. Leaves have a
+// Reference, and all other nodes have an Operation over their Children.
+type Expression struct {
+	Operation Operation
+	Children  []*Expression
+
+	// Reference is a relation or permission, either of the same object (`viewer`) or of
+	// the objects found through a relation (`parent->view`).
+	Reference string
+}
+
+// Document builds the documentation of the given compiled definitions.
+func Document(definitions []*v0.NamespaceDefinition) []Definition {
+	resolver := newSubjectTypeResolver(definitions)
+
+	documented := make([]Definition, 0, len(definitions))
+	for _, def := range definitions {
+		docDef := Definition{
+			Name:    def.Name,
+			Comment: cleanComments(namespace.GetComments(def.Metadata)),
+		}
+
+		for _, relation := range def.Relation {
+			docRelation := Relation{
+				Name:         relation.Name,
+				Comment:      cleanComments(namespace.GetComments(relation.Metadata)),
+				SubjectTypes: resolver.subjectTypes(def.Name, relation.Name),
+			}
+
+			if relation.UsersetRewrite != nil && !graph.HasThis(relation.UsersetRewrite) {
+				docRelation.Expression = rewriteExpression(relation.UsersetRewrite)
+				docDef.Permissions = append(docDef.Permissions, docRelation)
+			} else {
+				docDef.Relations = append(docDef.Relations, docRelation)
+			}
+		}
+
+		documented = append(documented, docDef)
+	}
+
+	return documented
+}
+
+func rewriteExpression(rewrite *v0.UsersetRewrite) *Expression {
+	var operation Operation
+	var setOp *v0.SetOperation
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *v0.UsersetRewrite_Union:
+		operation, setOp = OperationUnion, rw.Union
+	case *v0.UsersetRewrite_Intersection:
+		operation, setOp = OperationIntersection, rw.Intersection
+	case *v0.UsersetRewrite_Exclusion:
+		operation, setOp = OperationExclusion, rw.Exclusion
+	default:
+		return &Expression{Reference: "<unknown>"}
+	}
+
+	children := make([]*Expression, 0, len(setOp.Child))
+	for _, child := range setOp.Child {
+		children = append(children, childExpression(child))
+	}
+
+	// A permission referencing a single relation is compiled to a union of one child,
+	// which reads better as the child alone.
+	if len(children) == 1 {
+		return children[0]
+	}
+
+	return &Expression{Operation: operation, Children: children}
+}
+
+func childExpression(child *v0.SetOperation_Child) *Expression {
+	switch typed := child.ChildType.(type) {
+	case *v0.SetOperation_Child_UsersetRewrite:
+		return rewriteExpression(typed.UsersetRewrite)
+	case *v0.SetOperation_Child_ComputedUserset:
+		return &Expression{Reference: typed.ComputedUserset.Relation}
+	case *v0.SetOperation_Child_TupleToUserset:
+		return &Expression{
+			Reference: typed.TupleToUserset.Tupleset.Relation + "->" + typed.TupleToUserset.ComputedUserset.Relation,
+		}
+	case *v0.SetOperation_Child_XThis:
+		return &Expression{Reference: "_this"}
+	default:
+		return &Expression{Reference: "<unknown>"}
+	}
+}
+
+type relationKey struct {
+	namespace string
+	relation  string
+}
+
+// subjectTypeResolver finds the subject types reachable through each relation and
+// permission of a schema.
+type subjectTypeResolver struct {
+	relations map[relationKey]*v0.Relation
+	resolved  map[relationKey][]string
+	visiting  map[relationKey]struct{}
+}
+
+func newSubjectTypeResolver(definitions []*v0.NamespaceDefinition) *subjectTypeResolver {
+	relations := make(map[relationKey]*v0.Relation)
+	for _, def := range definitions {
+		for _, relation := range def.Relation {
+			relations[relationKey{def.Name, relation.Name}] = relation
+		}
+	}
+
+	return &subjectTypeResolver{
+		relations: relations,
+		resolved:  make(map[relationKey][]string),
+		visiting:  make(map[relationKey]struct{}),
+	}
+}
+
+func (r *subjectTypeResolver) subjectTypes(namespaceName, relationName string) []string {
+	key := relationKey{namespaceName, relationName}
+	if resolved, ok := r.resolved[key]; ok {
+		return resolved
+	}
+
+	// Recursive permissions reach no further subject types through themselves.
+	if _, ok := r.visiting[key]; ok {
+		return nil
+	}
+	r.visiting[key] = struct{}{}
+	defer delete(r.visiting, key)
+
+	relation, ok := r.relations[key]
+	if !ok {
+		return nil
+	}
+
+	found := make(map[string]struct{})
+	if relation.UsersetRewrite == nil {
+		r.addDirectTypes(found, relation)
+	} else {
+		r.addRewriteTypes(found, namespaceName, relation, relation.UsersetRewrite)
+	}
+
+	subjectTypes := make([]string, 0, len(found))
+	for subjectType := range found {
+		subjectTypes = append(subjectTypes, subjectType)
+	}
+	sort.Strings(subjectTypes)
+
+	// Results found while resolving another relation may be missing the subject types of
+	// relations which were being visited, so only complete results are reused.
+	if len(r.visiting) == 1 {
+		r.resolved[key] = subjectTypes
+	}
+	return subjectTypes
+}
+
+func (r *subjectTypeResolver) addDirectTypes(found map[string]struct{}, relation *v0.Relation) {
+	for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+		found[allowedRelationString(allowed)] = struct{}{}
+	}
+}
+
+func (r *subjectTypeResolver) addRewriteTypes(found map[string]struct{}, namespaceName string, relation *v0.Relation, rewrite *v0.UsersetRewrite) {
+	var children []*v0.SetOperation_Child
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *v0.UsersetRewrite_Union:
+		children = rw.Union.Child
+	case *v0.UsersetRewrite_Intersection:
+		children = rw.Intersection.Child
+	case *v0.UsersetRewrite_Exclusion:
+		// Subjects reached through the excluded children are never granted.
+		if len(rw.Exclusion.Child) > 0 {
+			children = rw.Exclusion.Child[:1]
+		}
+	}
+
+	for _, child := range children {
+		switch typed := child.ChildType.(type) {
+		case *v0.SetOperation_Child_UsersetRewrite:
+			r.addRewriteTypes(found, namespaceName, relation, typed.UsersetRewrite)
+		case *v0.SetOperation_Child_XThis:
+			r.addDirectTypes(found, relation)
+		case *v0.SetOperation_Child_ComputedUserset:
+			for _, subjectType := range r.subjectTypes(namespaceName, typed.ComputedUserset.Relation) {
+				found[subjectType] = struct{}{}
+			}
+		case *v0.SetOperation_Child_TupleToUserset:
+			tupleset, ok := r.relations[relationKey{namespaceName, typed.TupleToUserset.Tupleset.Relation}]
+			if !ok {
+				continue
+			}
+			for _, allowed := range tupleset.GetTypeInformation().GetAllowedDirectRelations() {
+				for _, subjectType := range r.subjectTypes(allowed.Namespace, typed.TupleToUserset.ComputedUserset.Relation) {
+					found[subjectType] = struct{}{}
+				}
+			}
+		}
+	}
+}
+
+func allowedRelationString(allowed *v0.AllowedRelation) string {
+	if allowed.GetPublicWildcard() != nil {
+		return allowed.Namespace + ":*"
+	}
+	if allowed.GetRelation() != "" && allowed.GetRelation() != Ellipsis {
+		return fmt.Sprintf("%s#%s", allowed.Namespace, allowed.GetRelation())
+	}
+	return allowed.Namespace
+}
+
+// cleanComments strips the comment markers from the comments found in a schema,
+// returning their text separated by newlines.
+func cleanComments(comments []string) string {
+	var lines []string
+	for _, comment := range comments {
+		stripped := strings.TrimSpace(comment)
+		switch {
+		case strings.HasPrefix(stripped, "//"):
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(stripped, "//")))
+
+		case strings.HasPrefix(stripped, "/*"):
+			stripped = strings.TrimPrefix(stripped, "/**")
+			stripped = strings.TrimPrefix(stripped, "/*")
+			stripped = strings.TrimSuffix(stripped, "*/")
+
+			scanner := bufio.NewScanner(strings.NewReader(strings.TrimSpace(stripped)))
+			for scanner.Scan() {
+				lines = append(lines, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "*")))
+			}
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,115 @@
+package docs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+const testSchema = `/** user represents a person */
+definition user {}
+
+definition document {
+	// parent is the folder containing the document
+	relation parent: folder
+	relation viewer: user | user:*
+	relation banned: user
+
+	/**
+	 * view is granted to viewers of the document
+	 * and of its folder
+	 */
+	permission view = (viewer + parent->view) - banned
+}
+
+definition folder {
+	relation viewer: user | group#member
+	permission view = viewer + view_via_self
+	permission view_via_self = view
+}
+
+definition group {
+	relation member: user
+}`
+
+func TestMarkdown(t *testing.T) {
+	require := require.New(t)
+
+	defs, err := compiler.Compile([]compiler.InputSchema{{
+		Source:       input.Source("schema"),
+		SchemaString: testSchema,
+	}}, nil)
+	require.NoError(err)
+
+	generated, err := Generate(defs, FormatMarkdown)
+	require.NoError(err)
+	require.Equal("# Schema\n"+
+		"\n## `user`\n"+
+		"\nuser represents a person\n"+
+		"\n## `document`\n"+
+		"\n### Relations\n"+
+		"\n#### `parent`\n"+
+		"\nparent is the folder containing the document\n"+
+		"\nSubject types: `folder`\n"+
+		"\n#### `viewer`\n"+
+		"\nSubject types: `user`, `user:*`\n"+
+		"\n#### `banned`\n"+
+		"\nSubject types: `user`\n"+
+		"\n### Permissions\n"+
+		"\n#### `view`\n"+
+		"\nview is granted to viewers of the document\nand of its folder\n"+
+		"\nSubject types: `group#member`, `user`, `user:*`\n"+
+		"\n- exclusion of:\n"+
+		"  - union of:\n"+
+		"    - `viewer`\n"+
+		"    - `parent->view`\n"+
+		"  - `banned`\n"+
+		"\n## `folder`\n"+
+		"\n### Relations\n"+
+		"\n#### `viewer`\n"+
+		"\nSubject types: `group#member`, `user`\n"+
+		"\n### Permissions\n"+
+		"\n#### `view`\n"+
+		"\nSubject types: `group#member`, `user`\n"+
+		"\n- union of:\n"+
+		"  - `viewer`\n"+
+		"  - `view_via_self`\n"+
+		"\n#### `view_via_self`\n"+
+		"\nSubject types: `group#member`, `user`\n"+
+		"\n- `view`\n"+
+		"\n## `group`\n"+
+		"\n### Relations\n"+
+		"\n#### `member`\n"+
+		"\nSubject types: `user`\n",
+		generated,
+	)
+}
+
+func TestHTMLEscapesComments(t *testing.T) {
+	require := require.New(t)
+
+	defs, err := compiler.Compile([]compiler.InputSchema{{
+		Source:       input.Source("schema"),
+		SchemaString: "// users are <b>people</b>\ndefinition user {}",
+	}}, nil)
+	require.NoError(err)
+
+	generated, err := Generate(defs, FormatHTML)
+	require.NoError(err)
+	require.Contains(generated, "<h2><code>user</code></h2>")
+	require.Contains(generated, "<p>users are &lt;b&gt;people&lt;/b&gt;</p>")
+}
+
+func TestParseFormat(t *testing.T) {
+	require := require.New(t)
+
+	format, err := ParseFormat("HTML")
+	require.NoError(err)
+	require.Equal(FormatHTML, format)
+
+	_, err = ParseFormat("pdf")
+	require.Error(err)
+}
@@ -0,0 +1,155 @@
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+)
+
+// Format is a format in which documentation can be generated.
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatMarkdown, FormatHTML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown documentation format `%s`; must be one of: markdown, html", name)
+	}
+}
+
+// Generate generates documentation of the given compiled definitions in the given format.
+func Generate(definitions []*v0.NamespaceDefinition, format Format) (string, error) {
+	documented := Document(definitions)
+	switch format {
+	case FormatMarkdown:
+		return Markdown(documented), nil
+	case FormatHTML:
+		return HTML(documented)
+	default:
+		return "", fmt.Errorf("unknown documentation format `%s`", format)
+	}
+}
+
+// Markdown renders the documentation of a schema as Markdown.
+func Markdown(definitions []Definition) string {
+	var buf strings.Builder
+	buf.WriteString("# Schema\n")
+
+	for _, def := range definitions {
+		fmt.Fprintf(&buf, "\n## `%s`\n", def.Name)
+		if def.Comment != "" {
+			fmt.Fprintf(&buf, "\n%s\n", def.Comment)
+		}
+
+		if len(def.Relations) > 0 {
+			buf.WriteString("\n### Relations\n")
+			for _, relation := range def.Relations {
+				writeMarkdownRelation(&buf, relation)
+			}
+		}
+
+		if len(def.Permissions) > 0 {
+			buf.WriteString("\n### Permissions\n")
+			for _, permission := range def.Permissions {
+				writeMarkdownRelation(&buf, permission)
+			}
+		}
+	}
+
+	return buf.String()
+}
+
+func writeMarkdownRelation(buf *strings.Builder, relation Relation) {
+	fmt.Fprintf(buf, "\n#### `%s`\n", relation.Name)
+	if relation.Comment != "" {
+		fmt.Fprintf(buf, "\n%s\n", relation.Comment)
+	}
+
+	buf.WriteString("\nSubject types: ")
+	if len(relation.SubjectTypes) == 0 {
+		buf.WriteString("none")
+	}
+	for index, subjectType := range relation.SubjectTypes {
+		if index > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(buf, "`%s`", subjectType)
+	}
+	buf.WriteString("\n")
+
+	if relation.Expression != nil {
+		buf.WriteString("\n")
+		writeMarkdownExpression(buf, relation.Expression, 0)
+	}
+}
+
+func writeMarkdownExpression(buf *strings.Builder, expr *Expression, depth int) {
+	buf.WriteString(strings.Repeat("  ", depth))
+	if expr.Operation == "" {
+		fmt.Fprintf(buf, "- `%s`\n", expr.Reference)
+		return
+	}
+
+	fmt.Fprintf(buf, "- %s of:\n", expr.Operation)
+	for _, child := range expr.Children {
+		writeMarkdownExpression(buf, child, depth+1)
+	}
+}
+
+var htmlTemplate = template.Must(template.New("schema").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Schema</title>
+</head>
+<body>
+<h1>Schema</h1>
+{{- range .}}
+<section id="{{.Name}}">
+<h2><code>{{.Name}}</code></h2>
+{{- with .Comment}}
+<p>{{.}}</p>
+{{- end}}
+{{- with .Relations}}
+<h3>Relations</h3>
+{{- range .}}{{template "relation" .}}{{end}}
+{{- end}}
+{{- with .Permissions}}
+<h3>Permissions</h3>
+{{- range .}}{{template "relation" .}}{{end}}
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+{{define "relation"}}
+<h4><code>{{.Name}}</code></h4>
+{{- with .Comment}}
+<p>{{.}}</p>
+{{- end}}
+<p>Subject types: {{range $index, $type := .SubjectTypes}}{{if $index}}, {{end}}<code>{{$type}}</code>{{else}}none{{end}}</p>
+{{- with .Expression}}
+<ul>{{template "expression" .}}</ul>
+{{- end}}
+{{- end}}
+{{define "expression"}}<li>{{if .Operation}}{{.Operation}} of:<ul>{{range .Children}}{{template "expression" .}}{{end}}</ul>{{else}}<code>{{.Reference}}</code>{{end}}</li>{{end}}
+`))
+
+// HTML renders the documentation of a schema as a standalone HTML page.
+func HTML(definitions []Definition) (string, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, definitions); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -13,6 +13,9 @@ const (
 	NodeTypeComment                 // A single or multiline comment
 
 	NodeTypeDefinition // A definition.
+	NodeTypeTemplate   // A parameterized template of relations and permissions.
+	NodeTypeUse        // A use of a template within a definition.
+	NodeTypeImport     // An import of another schema source.
 
 	NodeTypeRelation   // A relation
 	NodeTypePermission // A permission
@@ -67,6 +70,30 @@ const (
 	// The name of the definition
 	NodeDefinitionPredicateName = "definition-name"
 
+	// Whether the definition is partial, adding relations and permissions to the
+	// definition of the same name.
+	NodeDefinitionPredicatePartial = "definition-partial"
+
+	//
+	// NodeTypeImport
+	//
+
+	// The name of the imported schema source.
+	NodeImportPredicatePath = "import-path"
+
+	//
+	// NodeTypeTemplate + NodeTypeUse
+	//
+
+	// The name of the template
+	NodeTemplatePredicateName = "template-name"
+
+	// A parameter of the template, as an identifier node.
+	NodeTemplatePredicateParameter = "template-parameter"
+
+	// An argument to the template, as a specific type reference node.
+	NodeUsePredicateArgument = "template-argument"
+
 	//
 	// NodeTypeRelation + NodeTypePermission
 	//
@@ -12,20 +12,23 @@ func _() {
 	_ = x[NodeTypeFile-1]
 	_ = x[NodeTypeComment-2]
 	_ = x[NodeTypeDefinition-3]
-	_ = x[NodeTypeRelation-4]
-	_ = x[NodeTypePermission-5]
-	_ = x[NodeTypeTypeReference-6]
-	_ = x[NodeTypeSpecificTypeReference-7]
-	_ = x[NodeTypeUnionExpression-8]
-	_ = x[NodeTypeIntersectExpression-9]
-	_ = x[NodeTypeExclusionExpression-10]
-	_ = x[NodeTypeArrowExpression-11]
-	_ = x[NodeTypeIdentifier-12]
+	_ = x[NodeTypeTemplate-4]
+	_ = x[NodeTypeUse-5]
+	_ = x[NodeTypeImport-6]
+	_ = x[NodeTypeRelation-7]
+	_ = x[NodeTypePermission-8]
+	_ = x[NodeTypeTypeReference-9]
+	_ = x[NodeTypeSpecificTypeReference-10]
+	_ = x[NodeTypeUnionExpression-11]
+	_ = x[NodeTypeIntersectExpression-12]
+	_ = x[NodeTypeExclusionExpression-13]
+	_ = x[NodeTypeArrowExpression-14]
+	_ = x[NodeTypeIdentifier-15]
 }
 
-const _NodeType_name = "NodeTypeErrorNodeTypeFileNodeTypeCommentNodeTypeDefinitionNodeTypeRelationNodeTypePermissionNodeTypeTypeReferenceNodeTypeSpecificTypeReferenceNodeTypeUnionExpressionNodeTypeIntersectExpressionNodeTypeExclusionExpressionNodeTypeArrowExpressionNodeTypeIdentifier"
+const _NodeType_name = "NodeTypeErrorNodeTypeFileNodeTypeCommentNodeTypeDefinitionNodeTypeTemplateNodeTypeUseNodeTypeImportNodeTypeRelationNodeTypePermissionNodeTypeTypeReferenceNodeTypeSpecificTypeReferenceNodeTypeUnionExpressionNodeTypeIntersectExpressionNodeTypeExclusionExpressionNodeTypeArrowExpressionNodeTypeIdentifier"
 
-var _NodeType_index = [...]uint16{0, 13, 25, 40, 58, 74, 92, 113, 142, 165, 192, 219, 242, 260}
+var _NodeType_index = [...]uint16{0, 13, 25, 40, 58, 74, 85, 99, 115, 133, 154, 183, 206, 233, 260, 283, 301}
 
 func (i NodeType) String() string {
 	if i < 0 || i >= NodeType(len(_NodeType_index)-1) {
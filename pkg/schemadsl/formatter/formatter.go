@@ -0,0 +1,311 @@
+// Package formatter formats schema source in the canonical form of the schema language.
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/dslshape"
+	"github.com/authzed/spicedb/pkg/schemadsl/generator"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/schemadsl/lexer"
+	"github.com/authzed/spicedb/pkg/schemadsl/parser"
+)
+
+// Format formats the schema source in the canonical form: imports, definitions and templates
+// in the order written, separated by a blank line except between imports, with the
+// relations, permissions and uses of templates of definitions indented by a tab, one per
+// line. Parentheses are kept in expressions only where they group the expression or mix
+// operators, and comments are kept with the statements they describe.
+//
+// Formatting canonical source returns it unchanged. Source which does not parse is
+// rejected; as the source is formatted alone, it is not otherwise checked against the
+// schemas it imports.
+func Format(source input.Source, schema string) (string, error) {
+	root := parser.Parse(createAstNode, source, schema).(*formatNode)
+	if errorNode := root.findError(); errorNode != nil {
+		line, column, err := input.CreateSourcePositionMapper([]byte(schema)).RunePositionToLineAndCol(errorNode.start())
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("parse error in `%s`, line %d, column %d: %s", source, line+1, column+1, errorNode.getString(dslshape.NodePredicateErrorMessage))
+	}
+
+	f := &formatter{
+		schema:   schema,
+		comments: lexComments(source, schema),
+	}
+	f.emitFile(root)
+	return f.buf.String(), nil
+}
+
+// comment is a comment found in the source, with the byte positions of its first and last
+// characters.
+type comment struct {
+	value string
+	start int
+	end   int
+}
+
+func lexComments(source input.Source, schema string) []comment {
+	lx := lexer.NewPeekableLexer(lexer.Lex(source, schema))
+	defer lx.Close()
+
+	var comments []comment
+	for {
+		token := lx.NextToken()
+		switch token.Kind {
+		case lexer.TokenTypeSinglelineComment, lexer.TokenTypeMultilineComment:
+			comments = append(comments, comment{
+				value: token.Value,
+				start: int(token.Position),
+				end:   int(token.Position) + len(token.Value) - 1,
+			})
+
+		case lexer.TokenTypeEOF, lexer.TokenTypeError:
+			return comments
+		}
+	}
+}
+
+// placement is where the comments of a definition or template are emitted.
+type placement struct {
+	// leading are the comments emitted above each statement. Comments found within a
+	// statement are emitted above it too, after those found before it.
+	leading map[*formatNode][]comment
+
+	// trailing are the single line comments found after a statement on the same line,
+	// which are emitted at the end of its line.
+	trailing map[*formatNode]comment
+
+	// closing are the comments found after the last statement of the body.
+	closing []comment
+}
+
+type formatter struct {
+	schema   string
+	comments []comment
+	buf      strings.Builder
+}
+
+func (f *formatter) emitFile(root *formatNode) {
+	remaining := f.comments
+	var previous *formatNode
+	for _, topLevel := range root.statements() {
+		// Imports are written together, without blank lines between them.
+		isImport := topLevel.nodeType == dslshape.NodeTypeImport
+		if previous != nil && !(isImport && previous.nodeType == dslshape.NodeTypeImport) {
+			f.buf.WriteString("\n")
+		}
+		previous = topLevel
+
+		var leading, within []comment
+		for len(remaining) > 0 && remaining[0].start <= topLevel.end() {
+			if remaining[0].end < topLevel.start() {
+				leading = append(leading, remaining[0])
+			} else {
+				within = append(within, remaining[0])
+			}
+			remaining = remaining[1:]
+		}
+
+		if isImport {
+			f.emitComments(append(leading, within...), 0)
+			f.buf.WriteString(fmt.Sprintf("import \"%s\"\n", topLevel.getString(dslshape.NodeImportPredicatePath)))
+			continue
+		}
+
+		f.emitComments(leading, 0)
+		f.emitTopLevel(topLevel, f.place(topLevel, within))
+	}
+
+	if len(remaining) > 0 && f.buf.Len() > 0 {
+		f.buf.WriteString("\n")
+	}
+	f.emitComments(remaining, 0)
+}
+
+// place determines where the comments found within the definition or template are emitted.
+func (f *formatter) place(topLevel *formatNode, comments []comment) placement {
+	p := placement{
+		leading:  make(map[*formatNode][]comment),
+		trailing: make(map[*formatNode]comment),
+	}
+
+	statements := topLevel.statements()
+	for _, c := range comments {
+		var previous, next *formatNode
+		var isWithin bool
+		for _, statement := range statements {
+			if statement.start() <= c.start && c.start <= statement.end() {
+				next, isWithin = statement, true
+				break
+			}
+			if statement.end() < c.start {
+				previous = statement
+				continue
+			}
+			next = statement
+			break
+		}
+
+		_, hasTrailing := p.trailing[previous]
+		isSameLine := previous != nil && !isWithin && !strings.Contains(f.schema[previous.end()+1:c.start], "\n")
+		switch {
+		case isSameLine && !hasTrailing && strings.HasPrefix(c.value, "//"):
+			p.trailing[previous] = c
+		case next != nil:
+			p.leading[next] = append(p.leading[next], c)
+		default:
+			p.closing = append(p.closing, c)
+		}
+	}
+	return p
+}
+
+func (f *formatter) emitTopLevel(topLevel *formatNode, p placement) {
+	switch topLevel.nodeType {
+	case dslshape.NodeTypeDefinition:
+		if topLevel.getString(dslshape.NodeDefinitionPredicatePartial) == "true" {
+			f.buf.WriteString("partial ")
+		}
+		f.buf.WriteString("definition ")
+		f.buf.WriteString(topLevel.getString(dslshape.NodeDefinitionPredicateName))
+
+	case dslshape.NodeTypeTemplate:
+		var parameters []string
+		for _, parameter := range topLevel.list(dslshape.NodeTemplatePredicateParameter) {
+			parameters = append(parameters, parameter.getString(dslshape.NodeIdentiferPredicateValue))
+		}
+		f.buf.WriteString(fmt.Sprintf("template %s(%s)", topLevel.getString(dslshape.NodeTemplatePredicateName), strings.Join(parameters, ", ")))
+	}
+
+	statements := topLevel.statements()
+	if len(statements) == 0 && len(p.closing) == 0 {
+		f.buf.WriteString(" {}\n")
+		return
+	}
+
+	f.buf.WriteString(" {\n")
+	previousEnd := -1
+	for _, statement := range statements {
+		leading := p.leading[statement]
+		start := statement.start()
+		if len(leading) > 0 && leading[0].start < start {
+			start = leading[0].start
+		}
+		if previousEnd >= 0 && f.hasBlankLine(previousEnd, start) {
+			f.buf.WriteString("\n")
+		}
+
+		f.emitComments(leading, 1)
+		f.buf.WriteString("\t")
+		f.buf.WriteString(statementSource(statement))
+
+		previousEnd = statement.end()
+		if trailing, ok := p.trailing[statement]; ok {
+			f.buf.WriteString(" ")
+			f.buf.WriteString(strings.TrimSuffix(generator.GenerateComment(trailing.value), "\n"))
+			previousEnd = trailing.end
+		}
+		f.buf.WriteString("\n")
+	}
+
+	if len(p.closing) > 0 && previousEnd >= 0 && f.hasBlankLine(previousEnd, p.closing[0].start) {
+		f.buf.WriteString("\n")
+	}
+	f.emitComments(p.closing, 1)
+	f.buf.WriteString("}\n")
+}
+
+// hasBlankLine returns whether the source between the positions contains a blank line,
+// which is kept between statements.
+func (f *formatter) hasBlankLine(end int, start int) bool {
+	return strings.Count(f.schema[end+1:start], "\n") > 1
+}
+
+func (f *formatter) emitComments(comments []comment, indentationLevel int) {
+	indentation := strings.Repeat("\t", indentationLevel)
+	for _, c := range comments {
+		for _, line := range strings.Split(strings.TrimSuffix(generator.GenerateComment(c.value), "\n"), "\n") {
+			f.buf.WriteString(indentation)
+			f.buf.WriteString(line)
+			f.buf.WriteString("\n")
+		}
+	}
+}
+
+func statementSource(statement *formatNode) string {
+	switch statement.nodeType {
+	case dslshape.NodeTypeRelation:
+		var allowedTypes []string
+		for _, specificType := range statement.lookup(dslshape.NodeRelationPredicateAllowedTypes).list(dslshape.NodeTypeReferencePredicateType) {
+			allowedTypes = append(allowedTypes, specificTypeSource(specificType))
+		}
+		return fmt.Sprintf("relation %s: %s", statement.getString(dslshape.NodePredicateName), strings.Join(allowedTypes, " | "))
+
+	case dslshape.NodeTypePermission:
+		expression := statement.lookup(dslshape.NodePermissionPredicateComputeExpression)
+		return fmt.Sprintf("permission %s = %s", statement.getString(dslshape.NodePredicateName), expressionSource(expression))
+
+	case dslshape.NodeTypeUse:
+		var arguments []string
+		for _, argument := range statement.list(dslshape.NodeUsePredicateArgument) {
+			arguments = append(arguments, specificTypeSource(argument))
+		}
+		return fmt.Sprintf("use %s(%s)", statement.getString(dslshape.NodeTemplatePredicateName), strings.Join(arguments, ", "))
+
+	default:
+		panic(fmt.Sprintf("unknown statement type %s", statement.nodeType))
+	}
+}
+
+func specificTypeSource(specificType *formatNode) string {
+	source := specificType.getString(dslshape.NodeSpecificReferencePredicateType)
+	if specificType.getString(dslshape.NodeSpecificReferencePredicateWildcard) == "true" {
+		return source + ":*"
+	}
+	if relation := specificType.getString(dslshape.NodeSpecificReferencePredicateRelation); relation != "" {
+		return source + "#" + relation
+	}
+	return source
+}
+
+// binaryOperators are the operators of the binary expressions.
+var binaryOperators = map[dslshape.NodeType]string{
+	dslshape.NodeTypeExclusionExpression: "-",
+	dslshape.NodeTypeIntersectExpression: "&",
+	dslshape.NodeTypeUnionExpression:     "+",
+	dslshape.NodeTypeArrowExpression:     "->",
+}
+
+func expressionSource(expression *formatNode) string {
+	if expression.nodeType == dslshape.NodeTypeIdentifier {
+		return expression.getString(dslshape.NodeIdentiferPredicateValue)
+	}
+
+	left := operandSource(expression, expression.lookup(dslshape.NodeExpressionPredicateLeftExpr), false)
+	right := operandSource(expression, expression.lookup(dslshape.NodeExpressionPredicateRightExpr), true)
+	if expression.nodeType == dslshape.NodeTypeArrowExpression {
+		return left + binaryOperators[expression.nodeType] + right
+	}
+	return fmt.Sprintf("%s %s %s", left, binaryOperators[expression.nodeType], right)
+}
+
+// operandSource returns the source of an operand of the expression, parenthesized unless it
+// is an identifier, an arrow or the left operand of the same operator. Operators are parsed
+// as left associative, so the parentheses kept are exactly those which either change the
+// grouping of the expression or make the precedence of mixed operators explicit.
+func operandSource(expression *formatNode, operand *formatNode, isRight bool) string {
+	source := expressionSource(operand)
+	switch {
+	case operand.nodeType == dslshape.NodeTypeIdentifier:
+		return source
+	case operand.nodeType == dslshape.NodeTypeArrowExpression && expression.nodeType != dslshape.NodeTypeArrowExpression:
+		return source
+	case operand.nodeType == expression.nodeType && !isRight:
+		return source
+	default:
+		return "(" + source + ")"
+	}
+}
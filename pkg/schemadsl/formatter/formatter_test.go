@@ -0,0 +1,202 @@
+package formatter
+
+import (
+	"testing"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		name     string
+		schema   string
+		expected string
+	}{
+		{
+			"empty",
+			"",
+			"",
+		},
+		{
+			"canonical",
+			`definition user {}
+
+definition document {
+	relation viewer: user
+	permission view = viewer
+}
+`,
+			`definition user {}
+
+definition document {
+	relation viewer: user
+	permission view = viewer
+}
+`,
+		},
+		{
+			"whitespace and terminators",
+			`definition user {}   definition document {
+			relation owner: user; relation viewer: user|user:*|group#member
+
+
+			permission view = viewer+owner
+			}
+			definition group { relation member: user
+			}`,
+			`definition user {}
+
+definition document {
+	relation owner: user
+	relation viewer: user | user:* | group#member
+
+	permission view = viewer + owner
+}
+
+definition group {
+	relation member: user
+}
+`,
+		},
+		{
+			"expressions",
+			`definition user {}
+
+			definition document {
+				relation parent: document
+				relation owner: user
+				relation viewer: user
+				permission view = ((viewer) + owner)
+				permission grouped = (viewer + owner) + parent->view
+				permission edit = owner - (viewer - owner)
+				permission mixed = (viewer & owner) + parent->(view)
+				permission nested = viewer - (owner + viewer)
+			}`,
+			`definition user {}
+
+definition document {
+	relation parent: document
+	relation owner: user
+	relation viewer: user
+	permission view = viewer + owner
+	permission grouped = viewer + owner + parent->view
+	permission edit = owner - (viewer - owner)
+	permission mixed = (viewer & owner) + parent->view
+	permission nested = viewer - (owner + viewer)
+}
+`,
+		},
+		{
+			"comments",
+			`/* the user */ definition user {}
+			definition document {
+			// the owner
+			relation owner: user; relation viewer: user //anyone
+
+
+			permission view = viewer + /* or */ owner
+			// nothing after
+			}
+			// the end`,
+			`/* the user */
+definition user {}
+
+definition document {
+	// the owner
+	relation owner: user
+	relation viewer: user // anyone
+
+	/* or */
+	permission view = viewer + owner
+	// nothing after
+}
+
+// the end
+`,
+		},
+		{
+			"templates",
+			`template ownable( ownertype ) { relation owner: ownertype
+			permission manage = owner
+			}
+			definition user {}
+			definition document { use ownable( user );
+			}`,
+			`template ownable(ownertype) {
+	relation owner: ownertype
+	permission manage = owner
+}
+
+definition user {}
+
+definition document {
+	use ownable(user)
+}
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			formatted, err := Format(input.Source("schema"), tc.schema)
+			require.NoError(err)
+			require.Equal(tc.expected, formatted)
+
+			reformatted, err := Format(input.Source("schema"), formatted)
+			require.NoError(err)
+			require.Equal(formatted, reformatted)
+
+			original := compileWithoutComments(t, tc.schema)
+			compiled := compileWithoutComments(t, formatted)
+			require.Equal(len(original), len(compiled))
+			for index := range original {
+				require.True(proto.Equal(original[index], compiled[index]), "expected %v, found %v", original[index], compiled[index])
+			}
+		})
+	}
+}
+
+func TestFormatImports(t *testing.T) {
+	formatted, err := Format(input.Source("teams.zed"), `import "base.zed"
+	import  "users.zed"
+	partial   definition document { relation team: team
+	}`)
+	require.NoError(t, err)
+	require.Equal(t, `import "base.zed"
+import "users.zed"
+
+partial definition document {
+	relation team: team
+}
+`, formatted)
+}
+
+func TestFormatInvalidSchema(t *testing.T) {
+	_, err := Format(input.Source("schema"), "definition document { relation viewer }")
+	require.EqualError(t, err, "parse error in `schema`, line 1, column 39: Expected one of: [TokenTypeColon], found: TokenTypeRightBrace")
+}
+
+func compileWithoutComments(t *testing.T, schema string) []*v0.NamespaceDefinition {
+	emptyDefaultPrefix := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{{
+		Source:       input.Source("schema"),
+		SchemaString: schema,
+	}}, &emptyDefaultPrefix)
+	require.NoError(t, err)
+
+	for _, def := range defs {
+		def.Metadata = nil
+		for _, relation := range def.Relation {
+			relation.Metadata = nil
+		}
+	}
+	return defs
+}
@@ -0,0 +1,94 @@
+package formatter
+
+import (
+	"github.com/authzed/spicedb/pkg/schemadsl/dslshape"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/schemadsl/parser"
+)
+
+type formatNode struct {
+	nodeType   dslshape.NodeType
+	properties map[string]interface{}
+	children   map[string][]*formatNode
+}
+
+func createAstNode(source input.Source, kind dslshape.NodeType) parser.AstNode {
+	return &formatNode{
+		nodeType:   kind,
+		properties: make(map[string]interface{}),
+		children:   make(map[string][]*formatNode),
+	}
+}
+
+func (fn *formatNode) Connect(predicate string, other parser.AstNode) parser.AstNode {
+	fn.children[predicate] = append(fn.children[predicate], other.(*formatNode))
+	return fn
+}
+
+func (fn *formatNode) Decorate(property string, value string) parser.AstNode {
+	fn.properties[property] = value
+	return fn
+}
+
+func (fn *formatNode) DecorateWithInt(property string, value int) parser.AstNode {
+	fn.properties[property] = value
+	return fn
+}
+
+func (fn *formatNode) getString(predicateName string) string {
+	value, _ := fn.properties[predicateName].(string)
+	return value
+}
+
+func (fn *formatNode) getInt(predicateName string) int {
+	value, _ := fn.properties[predicateName].(int)
+	return value
+}
+
+func (fn *formatNode) list(predicateName string) []*formatNode {
+	return fn.children[predicateName]
+}
+
+func (fn *formatNode) lookup(predicateName string) *formatNode {
+	children := fn.children[predicateName]
+	if len(children) == 0 {
+		return nil
+	}
+	return children[0]
+}
+
+// statements returns the definitions, templates, relations, permissions and uses of
+// templates under the node, without its comments.
+func (fn *formatNode) statements() []*formatNode {
+	var statements []*formatNode
+	for _, child := range fn.list(dslshape.NodePredicateChild) {
+		if child.nodeType != dslshape.NodeTypeComment {
+			statements = append(statements, child)
+		}
+	}
+	return statements
+}
+
+// findError returns the first error node found under the node, if any.
+func (fn *formatNode) findError() *formatNode {
+	if fn.nodeType == dslshape.NodeTypeError {
+		return fn
+	}
+
+	for _, children := range fn.children {
+		for _, child := range children {
+			if found := child.findError(); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func (fn *formatNode) start() int {
+	return fn.getInt(dslshape.NodePredicateStartRune)
+}
+
+func (fn *formatNode) end() int {
+	return fn.getInt(dslshape.NodePredicateEndRune)
+}
@@ -29,6 +29,20 @@ func GenerateSource(namespace *v0.NamespaceDefinition) (string, bool) {
 	return generator.buf.String(), !generator.hasIssue
 }
 
+// GenerateComment generates the canonical form of the given single or multiline comment, as
+// emitted above the definitions and relations of generated source.
+func GenerateComment(comment string) string {
+	generator := &sourceGenerator{
+		indentationLevel: 0,
+		hasNewline:       true,
+		hasBlankline:     true,
+		hasNewScope:      true,
+	}
+
+	generator.appendComment(comment)
+	return generator.buf.String()
+}
+
 func (sg *sourceGenerator) emitNamespace(namespace *v0.NamespaceDefinition) {
 	sg.emitComments(namespace.Metadata)
 	sg.append("definition ")
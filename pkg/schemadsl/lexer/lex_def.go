@@ -50,6 +50,9 @@ const (
 	TokenTypeHash       // #
 	TokenTypeEllipsis   // ...
 	TokenTypeStar       // *
+	TokenTypeComma      // ,
+
+	TokenTypeString // "foo/bar.zed"
 )
 
 // keywords contains the full set of keywords supported.
@@ -69,6 +72,8 @@ var syntheticPredecessors = map[TokenType]bool{
 	TokenTypeRightParen: true,
 
 	TokenTypeStar: true,
+
+	TokenTypeString: true,
 }
 
 // lexerEntrypoint scans until EOFRUNE
@@ -115,6 +120,12 @@ Loop:
 		case r == '*':
 			l.emit(TokenTypeStar)
 
+		case r == ',':
+			l.emit(TokenTypeComma)
+
+		case r == '"':
+			return lexStringLiteral
+
 		case r == '.':
 			if l.acceptString("..") {
 				l.emit(TokenTypeEllipsis)
@@ -197,6 +208,22 @@ func lexMultilineComment(l *Lexer) stateFn {
 	}
 }
 
+// lexStringLiteral scans until the closing quote of the string literal, which may not span
+// lines.
+func lexStringLiteral(l *Lexer) stateFn {
+	for {
+		r := l.next()
+		switch {
+		case r == '"':
+			l.emit(TokenTypeString)
+			return lexSource
+
+		case r == EOFRUNE || isNewline(r):
+			return l.errorf("Unterminated string literal")
+		}
+	}
+}
+
 // lexIdentifierOrKeyword searches for a keyword or literal identifier.
 func lexIdentifierOrKeyword(l *Lexer) stateFn {
 	for {
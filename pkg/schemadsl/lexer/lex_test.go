@@ -38,8 +38,12 @@ var lexerTests = []lexerTest{
 	{"left paren", "(", []Lexeme{{TokenTypeLeftParen, 0, "("}, tEOF}},
 	{"right paren", ")", []Lexeme{{TokenTypeRightParen, 0, ")"}, tEOF}},
 
+	{"string", `"foo/bar.zed"`, []Lexeme{{TokenTypeString, 0, `"foo/bar.zed"`}, tEOF}},
+	{"unterminated string", "\"foo\n", []Lexeme{{TokenTypeError, 0, "Unterminated string literal"}}},
+
 	{"semicolon", ";", []Lexeme{{TokenTypeSemicolon, 0, ";"}, tEOF}},
 	{"star", "*", []Lexeme{{TokenTypeStar, 0, "*"}, tEOF}},
+	{"comma", ",", []Lexeme{{TokenTypeComma, 0, ","}, tEOF}},
 
 	{"right arrow", "->", []Lexeme{{TokenTypeRightArrow, 0, "->"}, tEOF}},
 
@@ -0,0 +1,364 @@
+// Package lint finds likely mistakes and departures from convention in a compiled schema.
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+
+	"github.com/authzed/spicedb/pkg/graph"
+)
+
+// Rule is a check made by the linter.
+type Rule string
+
+const (
+	// RuleUnusedRelation flags relations which no permission reads and which are not
+	// allowed as the subjects of any relation.
+	RuleUnusedRelation Rule = "unused-relation"
+
+	// RuleEmptyPermission flags permissions which can never be granted to any subject,
+	// whatever relationships are written.
+	RuleEmptyPermission Rule = "empty-permission"
+
+	// RuleShadowedName flags object definitions which share their name with one under
+	// another prefix, and so are easily mistaken for one another.
+	RuleShadowedName Rule = "shadowed-name"
+
+	// RuleUnboundedRecursion flags permissions which reference themselves through other
+	// permissions of the same object alone, and so recurse until the maximum depth of
+	// dispatch is exceeded rather than until the relationships run out.
+	RuleUnboundedRecursion Rule = "unbounded-recursion"
+
+	// RuleNamingConvention flags names which depart from the conventions of the schema
+	// language: snake_case names without repeated underscores, and object definitions
+	// which are either all or none under a prefix.
+	RuleNamingConvention Rule = "naming-convention"
+)
+
+// Rules are all of the rules run by the linter.
+var Rules = []Rule{
+	RuleUnusedRelation,
+	RuleEmptyPermission,
+	RuleShadowedName,
+	RuleUnboundedRecursion,
+	RuleNamingConvention,
+}
+
+// Finding is an issue found by the linter.
+type Finding struct {
+	// Rule is the rule which found the issue.
+	Rule Rule
+
+	// Definition is the name of the object definition in which the issue was found.
+	Definition string
+
+	// Relation is the name of the relation or permission in which the issue was found, if
+	// any.
+	Relation string
+
+	// Message describes the issue.
+	Message string
+}
+
+// String returns the finding as `definition#relation: message (rule)`.
+func (f Finding) String() string {
+	location := f.Definition
+	if f.Relation != "" {
+		location = fmt.Sprintf("%s#%s", location, f.Relation)
+	}
+	return fmt.Sprintf("%s: %s (%s)", location, f.Message, f.Rule)
+}
+
+type relationKey struct {
+	namespace string
+	relation  string
+}
+
+type linter struct {
+	definitions []*v0.NamespaceDefinition
+	relations   map[relationKey]*v0.Relation
+	findings    []Finding
+}
+
+func newLinter(definitions []*v0.NamespaceDefinition) *linter {
+	l := &linter{
+		definitions: definitions,
+		relations:   make(map[relationKey]*v0.Relation),
+	}
+	for _, def := range definitions {
+		for _, relation := range def.Relation {
+			l.relations[relationKey{def.Name, relation.Name}] = relation
+		}
+	}
+	return l
+}
+
+// Lint runs every rule over the given compiled definitions, returning the issues found in
+// the order of the definitions and of their relations.
+func Lint(definitions []*v0.NamespaceDefinition) []Finding {
+	l := newLinter(definitions)
+
+	l.lintDefinitionNames()
+
+	used := l.usedRelations()
+	nonEmpty := l.nonEmptyRelations()
+	for _, def := range definitions {
+		l.lintName(def.Name, "", def.Name)
+		for _, relation := range def.Relation {
+			l.lintName(def.Name, relation.Name, relation.Name)
+
+			// Relations written to and computed at once, which can only be defined outside
+			// of the schema language, are checked as permissions.
+			key := relationKey{def.Name, relation.Name}
+			if relation.UsersetRewrite == nil {
+				if _, ok := used[key]; !ok {
+					l.add(RuleUnusedRelation, def.Name, relation.Name, "relation is not used by any permission or allowed as a subject")
+				}
+				continue
+			}
+
+			if _, ok := nonEmpty[key]; !ok {
+				l.add(RuleEmptyPermission, def.Name, relation.Name, "permission can never be granted to any subject")
+			}
+			if cycle := l.computedCycle(def.Name, relation.Name); cycle != nil {
+				l.add(RuleUnboundedRecursion, def.Name, relation.Name,
+					fmt.Sprintf("permission references itself without walking a relation: %s", strings.Join(cycle, " -> ")))
+			}
+		}
+	}
+
+	return l.findings
+}
+
+func (l *linter) add(rule Rule, definition, relation, message string) {
+	l.findings = append(l.findings, Finding{
+		Rule:       rule,
+		Definition: definition,
+		Relation:   relation,
+		Message:    message,
+	})
+}
+
+func splitPrefix(name string) (string, string) {
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		return name[:index], name[index+1:]
+	}
+	return "", name
+}
+
+func (l *linter) lintDefinitionNames() {
+	byBaseName := make(map[string][]string)
+	var prefixed, unprefixed []string
+	for _, def := range l.definitions {
+		prefix, baseName := splitPrefix(def.Name)
+		byBaseName[baseName] = append(byBaseName[baseName], def.Name)
+		if prefix == "" {
+			unprefixed = append(unprefixed, def.Name)
+		} else {
+			prefixed = append(prefixed, def.Name)
+		}
+	}
+
+	for _, def := range l.definitions {
+		_, baseName := splitPrefix(def.Name)
+		if len(byBaseName[baseName]) < 2 {
+			continue
+		}
+
+		var others []string
+		for _, other := range byBaseName[baseName] {
+			if other != def.Name {
+				others = append(others, fmt.Sprintf("`%s`", other))
+			}
+		}
+		l.add(RuleShadowedName, def.Name, "", fmt.Sprintf("definition shares its name with %s", strings.Join(others, ", ")))
+	}
+
+	if len(prefixed) > 0 && len(unprefixed) > 0 {
+		for _, name := range unprefixed {
+			l.add(RuleNamingConvention, name, "", "definition is not under a prefix, whereas others of the schema are")
+		}
+	}
+}
+
+func (l *linter) lintName(definition, relation, name string) {
+	_, name = splitPrefix(name)
+	if strings.Contains(name, "__") {
+		l.add(RuleNamingConvention, definition, relation, fmt.Sprintf("name `%s` contains repeated underscores", name))
+	}
+}
+
+// UnusedRelations returns the relations and permissions of the definitions which are not
+// read by any permission, nor allowed as the subjects of a relation, in the order of the
+// definitions and of their relations.
+func UnusedRelations(definitions []*v0.NamespaceDefinition) []*v0.RelationReference {
+	l := newLinter(definitions)
+
+	used := l.usedRelations()
+	var unused []*v0.RelationReference
+	for _, def := range definitions {
+		for _, relation := range def.Relation {
+			if _, ok := used[relationKey{def.Name, relation.Name}]; !ok {
+				unused = append(unused, &v0.RelationReference{Namespace: def.Name, Relation: relation.Name})
+			}
+		}
+	}
+	return unused
+}
+
+// usedRelations returns the relations read by a permission, or allowed as the subjects of
+// a relation.
+func (l *linter) usedRelations() map[relationKey]struct{} {
+	used := make(map[relationKey]struct{})
+	for _, def := range l.definitions {
+		for _, relation := range def.Relation {
+			for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+				if allowed.GetRelation() != "" {
+					used[relationKey{allowed.Namespace, allowed.GetRelation()}] = struct{}{}
+				}
+			}
+
+			graph.WalkRewrite(relation.UsersetRewrite, func(child *v0.SetOperation_Child) interface{} {
+				switch typed := child.ChildType.(type) {
+				case *v0.SetOperation_Child_ComputedUserset:
+					used[relationKey{def.Name, typed.ComputedUserset.Relation}] = struct{}{}
+				case *v0.SetOperation_Child_TupleToUserset:
+					tupleset := relationKey{def.Name, typed.TupleToUserset.Tupleset.Relation}
+					used[tupleset] = struct{}{}
+					for _, allowed := range l.relations[tupleset].GetTypeInformation().GetAllowedDirectRelations() {
+						used[relationKey{allowed.Namespace, typed.TupleToUserset.ComputedUserset.Relation}] = struct{}{}
+					}
+				}
+				return nil
+			})
+		}
+	}
+	return used
+}
+
+// nonEmptyRelations returns the relations and permissions which can be granted to some
+// subject. They are found as a fixpoint, so that permissions granted only through
+// themselves are found to be empty.
+func (l *linter) nonEmptyRelations() map[relationKey]struct{} {
+	nonEmpty := make(map[relationKey]struct{})
+	for changed := true; changed; {
+		changed = false
+		for _, def := range l.definitions {
+			for _, relation := range def.Relation {
+				key := relationKey{def.Name, relation.Name}
+				if _, ok := nonEmpty[key]; ok {
+					continue
+				}
+
+				found := len(relation.GetTypeInformation().GetAllowedDirectRelations()) > 0
+				if relation.UsersetRewrite != nil {
+					found = l.rewriteNonEmpty(nonEmpty, def.Name, relation, relation.UsersetRewrite)
+				}
+				if found {
+					nonEmpty[key] = struct{}{}
+					changed = true
+				}
+			}
+		}
+	}
+	return nonEmpty
+}
+
+func (l *linter) rewriteNonEmpty(nonEmpty map[relationKey]struct{}, namespaceName string, relation *v0.Relation, rewrite *v0.UsersetRewrite) bool {
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *v0.UsersetRewrite_Union:
+		for _, child := range rw.Union.Child {
+			if l.childNonEmpty(nonEmpty, namespaceName, relation, child) {
+				return true
+			}
+		}
+		return false
+
+	case *v0.UsersetRewrite_Intersection:
+		for _, child := range rw.Intersection.Child {
+			if !l.childNonEmpty(nonEmpty, namespaceName, relation, child) {
+				return false
+			}
+		}
+		return len(rw.Intersection.Child) > 0
+
+	case *v0.UsersetRewrite_Exclusion:
+		// Subjects are only ever removed by the excluded children.
+		return len(rw.Exclusion.Child) > 0 && l.childNonEmpty(nonEmpty, namespaceName, relation, rw.Exclusion.Child[0])
+
+	default:
+		return false
+	}
+}
+
+func (l *linter) childNonEmpty(nonEmpty map[relationKey]struct{}, namespaceName string, relation *v0.Relation, child *v0.SetOperation_Child) bool {
+	switch typed := child.ChildType.(type) {
+	case *v0.SetOperation_Child_XThis:
+		return len(relation.GetTypeInformation().GetAllowedDirectRelations()) > 0
+	case *v0.SetOperation_Child_UsersetRewrite:
+		return l.rewriteNonEmpty(nonEmpty, namespaceName, relation, typed.UsersetRewrite)
+	case *v0.SetOperation_Child_ComputedUserset:
+		_, ok := nonEmpty[relationKey{namespaceName, typed.ComputedUserset.Relation}]
+		return ok
+	case *v0.SetOperation_Child_TupleToUserset:
+		tupleset := l.relations[relationKey{namespaceName, typed.TupleToUserset.Tupleset.Relation}]
+		for _, allowed := range tupleset.GetTypeInformation().GetAllowedDirectRelations() {
+			if _, ok := nonEmpty[relationKey{allowed.Namespace, typed.TupleToUserset.ComputedUserset.Relation}]; ok {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// computedCycle returns the cycle of permissions of the object, referenced without walking
+// a relation, through which the permission references itself, if any.
+func (l *linter) computedCycle(namespaceName, relationName string) []string {
+	var path []string
+	visited := make(map[string]struct{})
+
+	var visit func(current string) bool
+	visit = func(current string) bool {
+		path = append(path, current)
+		if current == relationName && len(path) > 1 {
+			return true
+		}
+		if _, ok := visited[current]; ok {
+			path = path[:len(path)-1]
+			return false
+		}
+		visited[current] = struct{}{}
+
+		relation, ok := l.relations[relationKey{namespaceName, current}]
+		if ok {
+			for _, next := range computedReferences(relation.UsersetRewrite) {
+				if visit(next) {
+					return true
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if visit(relationName) {
+		return path
+	}
+	return nil
+}
+
+func computedReferences(rewrite *v0.UsersetRewrite) []string {
+	var references []string
+	graph.WalkRewrite(rewrite, func(child *v0.SetOperation_Child) interface{} {
+		if computed, ok := child.ChildType.(*v0.SetOperation_Child_ComputedUserset); ok {
+			references = append(references, computed.ComputedUserset.Relation)
+		}
+		return nil
+	})
+	return references
+}
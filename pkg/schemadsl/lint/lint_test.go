@@ -0,0 +1,131 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+func TestLint(t *testing.T) {
+	testCases := []struct {
+		name     string
+		schema   string
+		expected []string
+	}{
+		{
+			"clean schema",
+			`definition user {}
+
+			definition group {
+				relation member: user | group#member
+			}
+
+			definition document {
+				relation parent: folder
+				relation viewer: user | group#member
+				permission view = viewer + parent->view
+			}
+
+			definition folder {
+				relation viewer: user
+				permission view = viewer
+			}`,
+			nil,
+		},
+		{
+			"unused relation",
+			`definition user {}
+
+			definition document {
+				relation viewer: user
+				relation reader: user
+				permission view = viewer
+			}`,
+			[]string{
+				"document#reader: relation is not used by any permission or allowed as a subject (unused-relation)",
+			},
+		},
+		{
+			"empty permissions",
+			`definition user {}
+
+			definition document {
+				relation viewer: user
+				relation banned: user
+				permission view = viewer - banned
+				permission nothing = view & loop
+				permission loop = loop_again
+				permission loop_again = loop
+			}`,
+			[]string{
+				"document#nothing: permission can never be granted to any subject (empty-permission)",
+				"document#loop: permission can never be granted to any subject (empty-permission)",
+				"document#loop: permission references itself without walking a relation: loop -> loop_again -> loop (unbounded-recursion)",
+				"document#loop_again: permission can never be granted to any subject (empty-permission)",
+				"document#loop_again: permission references itself without walking a relation: loop_again -> loop -> loop_again (unbounded-recursion)",
+			},
+		},
+		{
+			"recursion through a relation is bounded",
+			`definition user {}
+
+			definition folder {
+				relation parent: folder
+				relation viewer: user
+				permission view = viewer + parent->view
+			}`,
+			nil,
+		},
+		{
+			"shadowed and unprefixed definitions",
+			`definition user {}
+
+			definition example/user {}
+
+			definition example/document {
+				relation viewer: user | example/user
+				permission view = viewer
+			}`,
+			[]string{
+				"user: definition shares its name with `example/user` (shadowed-name)",
+				"example/user: definition shares its name with `user` (shadowed-name)",
+				"user: definition is not under a prefix, whereas others of the schema are (naming-convention)",
+			},
+		},
+		{
+			"repeated underscores",
+			`definition user {}
+
+			definition document {
+				relation the__viewer: user
+				permission view = the__viewer
+			}`,
+			[]string{
+				"document#the__viewer: name `the__viewer` contains repeated underscores (naming-convention)",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			emptyDefaultPrefix := ""
+			defs, err := compiler.Compile([]compiler.InputSchema{{
+				Source:       input.Source("schema"),
+				SchemaString: tc.schema,
+			}}, &emptyDefaultPrefix)
+			require.NoError(err)
+
+			var found []string
+			for _, finding := range Lint(defs) {
+				found = append(found, finding.String())
+			}
+			require.Equal(tc.expected, found)
+		})
+	}
+}
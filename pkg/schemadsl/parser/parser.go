@@ -3,6 +3,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/authzed/spicedb/pkg/schemadsl/dslshape"
 	"github.com/authzed/spicedb/pkg/schemadsl/input"
@@ -51,13 +52,17 @@ Loop:
 			break Loop
 		}
 
-		// The top level of the DSL is a set of definitions:
+		// The top level of the DSL is a set of definitions and templates:
 		// definition foobar { ... }
+		// template foobar(sometype) { ... }
 
 		switch {
 		case p.isKeyword("definition"):
 			rootNode.Connect(dslshape.NodePredicateChild, p.consumeDefinition())
 
+		case p.isContextualKeyword("template"):
+			rootNode.Connect(dslshape.NodePredicateChild, p.consumeTemplate())
+
 		default:
 			p.emitErrorf("Unexpected token at root level: %v", p.currentToken.Kind)
 			break Loop
@@ -67,12 +72,38 @@ Loop:
 	return rootNode
 }
 
-// consumeDefinition attempts to consume a single schema definition.
+// consumeImport consumes the import of another schema source, whose templates and
+// definitions become visible to this one.
+// ```import "foo/bar.zed"```
+func (p *sourceParser) consumeImport() AstNode {
+	importNode := p.startNode(dslshape.NodeTypeImport)
+	defer p.finishNode()
+
+	// import ...
+	p.consumeContextualKeyword("import")
+	path, ok := p.consume(lexer.TokenTypeString)
+	if !ok {
+		return importNode
+	}
+
+	importNode.Decorate(dslshape.NodeImportPredicatePath, strings.Trim(path.Value, `"`))
+	return importNode
+}
+
+// consumeDefinition attempts to consume a single schema definition, or a partial
+// definition adding relations and permissions to the definition of the same name.
 // ```definition somedef { ... }````
+// ```partial definition somedef { ... }````
 func (p *sourceParser) consumeDefinition() AstNode {
 	defNode := p.startNode(dslshape.NodeTypeDefinition)
 	defer p.finishNode()
 
+	// partial ...
+	if p.isContextualKeyword("partial") {
+		p.consumeToken()
+		defNode.Decorate(dslshape.NodeDefinitionPredicatePartial, "true")
+	}
+
 	// definition ...
 	p.consumeKeyword("definition")
 	definitionName, ok := p.consumeTypePath()
@@ -81,11 +112,68 @@ func (p *sourceParser) consumeDefinition() AstNode {
 	}
 
 	defNode.Decorate(dslshape.NodeDefinitionPredicateName, definitionName)
+	p.consumeDefinitionBody(defNode, true)
+	return defNode
+}
+
+// consumeTemplate attempts to consume a single template, whose relations and
+// permissions are expanded into each definition which uses it.
+// ```template sometemplate(someparam, anotherparam) { ... }````
+func (p *sourceParser) consumeTemplate() AstNode {
+	templateNode := p.startNode(dslshape.NodeTypeTemplate)
+	defer p.finishNode()
+
+	// template ...
+	p.consumeContextualKeyword("template")
+	templateName, ok := p.consumeIdentifier()
+	if !ok {
+		return templateNode
+	}
+
+	templateNode.Decorate(dslshape.NodeTemplatePredicateName, templateName)
+
+	// (
+	_, ok = p.consume(lexer.TokenTypeLeftParen)
+	if !ok {
+		return templateNode
+	}
+
+	// Parameters.
+	for {
+		// )
+		if _, ok := p.tryConsume(lexer.TokenTypeRightParen); ok {
+			break
+		}
+
+		paramNode, ok := p.tryConsumeIdentifierLiteral()
+		if !ok {
+			p.emitErrorf("Expected template parameter, found token %v", p.currentToken.Kind)
+			return templateNode
+		}
+
+		templateNode.Connect(dslshape.NodeTemplatePredicateParameter, paramNode)
 
+		if _, ok := p.tryConsume(lexer.TokenTypeComma); !ok {
+			_, ok = p.consume(lexer.TokenTypeRightParen)
+			if !ok {
+				return templateNode
+			}
+			break
+		}
+	}
+
+	p.consumeDefinitionBody(templateNode, false)
+	return templateNode
+}
+
+// consumeDefinitionBody consumes the relations and permissions of a definition or
+// template, along with any uses of templates if allowed.
+// ```{ relation foo: bar; permission baz = foo }```
+func (p *sourceParser) consumeDefinitionBody(parentNode AstNode, allowUse bool) {
 	// {
-	_, ok = p.consume(lexer.TokenTypeLeftBrace)
+	_, ok := p.consume(lexer.TokenTypeLeftBrace)
 	if !ok {
-		return defNode
+		return
 	}
 
 	// Relations and permissions.
@@ -97,12 +185,16 @@ func (p *sourceParser) consumeDefinition() AstNode {
 
 		// relation ...
 		// permission ...
+		// use ...
 		switch {
 		case p.isKeyword("relation"):
-			defNode.Connect(dslshape.NodePredicateChild, p.consumeRelation())
+			parentNode.Connect(dslshape.NodePredicateChild, p.consumeRelation())
 
 		case p.isKeyword("permission"):
-			defNode.Connect(dslshape.NodePredicateChild, p.consumePermission())
+			parentNode.Connect(dslshape.NodePredicateChild, p.consumePermission())
+
+		case allowUse && p.isContextualKeyword("use"):
+			parentNode.Connect(dslshape.NodePredicateChild, p.consumeUse())
 		}
 
 		ok := p.consumeStatementTerminator()
@@ -110,8 +202,48 @@ func (p *sourceParser) consumeDefinition() AstNode {
 			break
 		}
 	}
+}
 
-	return defNode
+// consumeUse consumes the use of a template.
+// ```use sometemplate(sometype, anothertype)```
+func (p *sourceParser) consumeUse() AstNode {
+	useNode := p.startNode(dslshape.NodeTypeUse)
+	defer p.finishNode()
+
+	// use ...
+	p.consumeContextualKeyword("use")
+	templateName, ok := p.consumeIdentifier()
+	if !ok {
+		return useNode
+	}
+
+	useNode.Decorate(dslshape.NodeTemplatePredicateName, templateName)
+
+	// (
+	_, ok = p.consume(lexer.TokenTypeLeftParen)
+	if !ok {
+		return useNode
+	}
+
+	// Arguments.
+	for {
+		// )
+		if _, ok := p.tryConsume(lexer.TokenTypeRightParen); ok {
+			break
+		}
+
+		useNode.Connect(dslshape.NodeUsePredicateArgument, p.consumeSpecificType())
+
+		if _, ok := p.tryConsume(lexer.TokenTypeComma); !ok {
+			_, ok = p.consume(lexer.TokenTypeRightParen)
+			if !ok {
+				return useNode
+			}
+			break
+		}
+	}
+
+	return useNode
 }
 
 // consumeRelation consumes a relation.
@@ -167,6 +167,24 @@ func (p *sourceParser) isKeyword(keyword string) bool {
 	return p.isToken(lexer.TokenTypeKeyword) && p.currentToken.Value == keyword
 }
 
+// isContextualKeyword returns true if the current token is an identifier matching the
+// given keyword. Contextual keywords are only treated as keywords in specific positions,
+// which allows them to remain valid as relation and permission names.
+func (p *sourceParser) isContextualKeyword(keyword string) bool {
+	return p.isToken(lexer.TokenTypeIdentifier) && p.currentToken.Value == keyword
+}
+
+// consumeContextualKeyword consumes an expected contextual keyword or adds an error node.
+func (p *sourceParser) consumeContextualKeyword(keyword string) bool {
+	if !p.isContextualKeyword(keyword) {
+		p.emitErrorf("Expected keyword %s, found token %v", keyword, p.currentToken.Kind)
+		return false
+	}
+
+	p.consumeToken()
+	return true
+}
+
 // emitErrorf creates a new error node and attachs it as a child of the current
 // node.
 func (p *sourceParser) emitErrorf(format string, args ...interface{}) {
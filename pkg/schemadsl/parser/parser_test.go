@@ -4,15 +4,13 @@ import (
 	"container/list"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"sort"
 	"strings"
 	"testing"
 
-	"github.com/stretchr/testify/assert"
-
 	"github.com/authzed/spicedb/pkg/schemadsl/dslshape"
 	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/testutil"
 )
 
 type testNode struct {
@@ -35,20 +33,8 @@ func (pt *parserTest) input() string {
 	return string(b)
 }
 
-func (pt *parserTest) tree() string {
-	b, err := ioutil.ReadFile(fmt.Sprintf("tests/%s.zed.expected", pt.filename))
-	if err != nil {
-		panic(err)
-	}
-
-	return string(b)
-}
-
-func (pt *parserTest) writeTree(value string) {
-	err := ioutil.WriteFile(fmt.Sprintf("tests/%s.zed.expected", pt.filename), []byte(value), 0o600)
-	if err != nil {
-		panic(err)
-	}
+func (pt *parserTest) treePath() string {
+	return fmt.Sprintf("tests/%s.zed.expected", pt.filename)
 }
 
 func createAstNode(source input.Source, kind dslshape.NodeType) AstNode {
@@ -108,24 +94,14 @@ func TestParser(t *testing.T) {
 		{"multiple parens test", "multiparen"},
 		{"wildcard test", "wildcard"},
 		{"broken wildcard test", "brokenwildcard"},
+		{"imports and partials test", "imports"},
 	}
 
 	for _, test := range parserTests {
 		t.Run(test.name, func(t *testing.T) {
 			root := Parse(createAstNode, input.Source(test.name), test.input())
 			parseTree := getParseTree((root).(*testNode), 0)
-			assert := assert.New(t)
-
-			found := strings.TrimSpace(parseTree)
-
-			if os.Getenv("REGEN") == "true" {
-				test.writeTree(found)
-			} else {
-				expected := strings.TrimSpace(test.tree())
-				if !assert.Equal(expected, found, test.name) {
-					t.Log(parseTree)
-				}
-			}
+			testutil.RequireGoldenFile(t, test.treePath(), parseTree, strings.TrimSpace)
 		})
 	}
 }
@@ -0,0 +1,332 @@
+// Package zanzibar converts Zanzibar-style namespace configs into schema source.
+//
+// Zanzibar namespace configs have the shape of SpiceDB's v0 namespace definitions, but do
+// not declare the types of subjects which may be written to their relations, and may
+// combine relationships written to a relation with those computed from other relations.
+// The former are supplied as options, and the latter are split into a relation holding
+// the relationships written and a permission of the original name.
+package zanzibar
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/graph"
+	nspkg "github.com/authzed/spicedb/pkg/namespace"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/generator"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+// Format is an encoding of namespace configs.
+type Format string
+
+const (
+	FormatTextProto Format = "textproto"
+	FormatJSON      Format = "json"
+	FormatBinary    Format = "binary"
+)
+
+// DirectRelationSuffix is appended to the name of a relation which combines the
+// relationships written to it with computed ones, to name the relation to which its
+// relationships are written once split from the permission computing them.
+const DirectRelationSuffix = "_direct"
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatTextProto, FormatJSON, FormatBinary:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown namespace config format `%s`; must be one of: textproto, json, binary", name)
+	}
+}
+
+// ParseConfig parses a single namespace config in the given format. Fields unknown to
+// SpiceDB, such as those of extensions to Zanzibar, are ignored.
+func ParseConfig(contents []byte, format Format) (*v0.NamespaceDefinition, error) {
+	config := &v0.NamespaceDefinition{}
+
+	var err error
+	switch format {
+	case FormatTextProto:
+		err = prototext.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(contents, config)
+	case FormatJSON:
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(contents, config)
+	case FormatBinary:
+		err = proto.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(contents, config)
+	default:
+		return nil, fmt.Errorf("unknown namespace config format `%s`", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse namespace config: %w", err)
+	}
+	return config, nil
+}
+
+// Option is an option for Convert.
+type Option func(*converter)
+
+// WithSubjectTypes sets the types of subjects which may be written to a relation, given as
+// `namespace#relation`. Each type is an object type, such as `user`, a relation of an
+// object type, such as `group#member`, or all objects of a type, such as `user:*`.
+func WithSubjectTypes(relation string, subjectTypes ...string) Option {
+	return func(c *converter) {
+		c.subjectTypes[relation] = append(c.subjectTypes[relation], subjectTypes...)
+	}
+}
+
+// WithDefaultSubjectTypes sets the types of subjects which may be written to the relations
+// without types set by WithSubjectTypes.
+func WithDefaultSubjectTypes(subjectTypes ...string) Option {
+	return func(c *converter) {
+		c.defaultSubjectTypes = append(c.defaultSubjectTypes, subjectTypes...)
+	}
+}
+
+// Result is the outcome of converting namespace configs.
+type Result struct {
+	// Schema is the schema source of the converted definitions.
+	Schema string
+
+	// Definitions are the definitions compiled from the schema.
+	Definitions []*v0.NamespaceDefinition
+
+	// MovedRelations maps each relation, as `namespace#relation`, whose relationships must
+	// be written to another relation of the converted schema to the name of the other
+	// relation.
+	MovedRelations map[string]string
+}
+
+type converter struct {
+	subjectTypes        map[string][]string
+	defaultSubjectTypes []string
+	movedRelations      map[string]string
+}
+
+// Convert converts the namespace configs into schema source, and validates the definitions
+// compiled from it against the type system.
+func Convert(configs []*v0.NamespaceDefinition, opts ...Option) (Result, error) {
+	c := &converter{
+		subjectTypes:   make(map[string][]string),
+		movedRelations: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	sources := make([]string, 0, len(configs))
+	for _, config := range configs {
+		converted, err := c.convertNamespace(config)
+		if err != nil {
+			return Result{}, err
+		}
+
+		source, ok := generator.GenerateSource(converted)
+		if !ok {
+			return Result{}, fmt.Errorf("namespace `%s` cannot be expressed as schema", config.Name)
+		}
+		sources = append(sources, source)
+	}
+	schema := strings.Join(sources, "\n\n")
+
+	emptyDefaultPrefix := ""
+	definitions, err := compiler.Compile([]compiler.InputSchema{{
+		Source:       input.Source("converted"),
+		SchemaString: schema,
+	}}, &emptyDefaultPrefix)
+	if err != nil {
+		return Result{}, fmt.Errorf("converted schema does not compile: %w", err)
+	}
+
+	for _, definition := range definitions {
+		ts, err := namespace.BuildNamespaceTypeSystemForDefs(definition, definitions)
+		if err != nil {
+			return Result{}, err
+		}
+
+		if err := ts.Validate(context.Background()); err != nil {
+			return Result{}, err
+		}
+	}
+
+	return Result{
+		Schema:         schema,
+		Definitions:    definitions,
+		MovedRelations: c.movedRelations,
+	}, nil
+}
+
+func (c *converter) convertNamespace(config *v0.NamespaceDefinition) (*v0.NamespaceDefinition, error) {
+	// Relationships written to a relation which also computes relationships are written
+	// to a relation of their own, which the permission of the original name reads.
+	moved := make(map[string]string)
+	for _, relation := range config.Relation {
+		if relation.UsersetRewrite != nil && graph.HasThis(relation.UsersetRewrite) {
+			moved[relation.Name] = relation.Name + DirectRelationSuffix
+			c.movedRelations[config.Name+"#"+relation.Name] = moved[relation.Name]
+		}
+	}
+
+	converted := &v0.NamespaceDefinition{Name: config.Name}
+	for _, relation := range config.Relation {
+		if relation.UsersetRewrite == nil {
+			direct, err := c.directRelation(config.Name, relation.Name, relation.Name)
+			if err != nil {
+				return nil, err
+			}
+			converted.Relation = append(converted.Relation, direct)
+			continue
+		}
+
+		if directName, ok := moved[relation.Name]; ok {
+			direct, err := c.directRelation(config.Name, relation.Name, directName)
+			if err != nil {
+				return nil, err
+			}
+			converted.Relation = append(converted.Relation, direct)
+		}
+
+		rewrite, err := rewriteConverter{config.Name, relation.Name, moved}.convertRewrite(relation.UsersetRewrite)
+		if err != nil {
+			return nil, err
+		}
+
+		converted.Relation = append(converted.Relation, nspkg.Relation(relation.Name, rewrite))
+	}
+	return converted, nil
+}
+
+// directRelation returns the relation to which the relationships of the relation of the
+// config are written, with the types of subjects configured for the relation.
+func (c *converter) directRelation(namespaceName, configRelation, name string) (*v0.Relation, error) {
+	key := namespaceName + "#" + configRelation
+	subjectTypes, ok := c.subjectTypes[key]
+	if !ok {
+		subjectTypes = c.defaultSubjectTypes
+	}
+	if len(subjectTypes) == 0 {
+		return nil, fmt.Errorf("no subject types are known for relation `%s`; they must be supplied", key)
+	}
+
+	allowed := make([]*v0.AllowedRelation, 0, len(subjectTypes))
+	for _, subjectType := range subjectTypes {
+		allowedRelation, err := parseSubjectType(subjectType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subject type for relation `%s`: %w", key, err)
+		}
+		allowed = append(allowed, allowedRelation)
+	}
+
+	// Subject types are sorted so that conversion is deterministic.
+	sort.SliceStable(allowed, func(i, j int) bool {
+		return allowed[i].Namespace < allowed[j].Namespace
+	})
+	return nspkg.Relation(name, nil, allowed...), nil
+}
+
+func parseSubjectType(subjectType string) (*v0.AllowedRelation, error) {
+	if strings.HasSuffix(subjectType, ":*") {
+		return nspkg.AllowedPublicNamespace(strings.TrimSuffix(subjectType, ":*")), nil
+	}
+
+	parts := strings.Split(subjectType, "#")
+	switch len(parts) {
+	case 1:
+		return nspkg.AllowedRelation(parts[0], generator.Ellipsis), nil
+	case 2:
+		return nspkg.AllowedRelation(parts[0], parts[1]), nil
+	default:
+		return nil, fmt.Errorf("`%s` is not a subject type", subjectType)
+	}
+}
+
+// rewriteConverter converts the rewrite of a relation of a config.
+type rewriteConverter struct {
+	namespaceName string
+	relationName  string
+
+	// moved maps the relations of the namespace whose relationships are written to
+	// another relation to the name of the other relation.
+	moved map[string]string
+}
+
+func (rc rewriteConverter) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("relation `%s#%s` %s", rc.namespaceName, rc.relationName, fmt.Sprintf(format, args...))
+}
+
+func (rc rewriteConverter) convertRewrite(rewrite *v0.UsersetRewrite) (*v0.UsersetRewrite, error) {
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *v0.UsersetRewrite_Union:
+		children, err := rc.convertChildren(rw.Union.Child)
+		if err != nil {
+			return nil, err
+		}
+		return nspkg.Union(children[0], children[1:]...), nil
+
+	case *v0.UsersetRewrite_Intersection:
+		children, err := rc.convertChildren(rw.Intersection.Child)
+		if err != nil {
+			return nil, err
+		}
+		return nspkg.Intersection(children[0], children[1:]...), nil
+
+	case *v0.UsersetRewrite_Exclusion:
+		children, err := rc.convertChildren(rw.Exclusion.Child)
+		if err != nil {
+			return nil, err
+		}
+		return nspkg.Exclusion(children[0], children[1:]...), nil
+
+	default:
+		return nil, rc.errorf("has an unknown rewrite")
+	}
+}
+
+func (rc rewriteConverter) convertChildren(children []*v0.SetOperation_Child) ([]*v0.SetOperation_Child, error) {
+	if len(children) == 0 {
+		return nil, rc.errorf("has an empty set operation")
+	}
+
+	converted := make([]*v0.SetOperation_Child, 0, len(children))
+	for _, child := range children {
+		switch typed := child.ChildType.(type) {
+		case *v0.SetOperation_Child_XThis:
+			converted = append(converted, nspkg.ComputedUserset(rc.moved[rc.relationName]))
+
+		case *v0.SetOperation_Child_ComputedUserset:
+			if typed.ComputedUserset.Object != v0.ComputedUserset_TUPLE_OBJECT {
+				return nil, rc.errorf("computes a userset of the subjects of relationships, which is not supported")
+			}
+			converted = append(converted, nspkg.ComputedUserset(typed.ComputedUserset.Relation))
+
+		case *v0.SetOperation_Child_TupleToUserset:
+			// The userset is always computed on the subjects of the relationships of the
+			// tupleset, which are read from the relation to which they are written.
+			tupleset := typed.TupleToUserset.Tupleset.Relation
+			if moved, ok := rc.moved[tupleset]; ok {
+				tupleset = moved
+			}
+			converted = append(converted, nspkg.TupleToUserset(tupleset, typed.TupleToUserset.ComputedUserset.Relation))
+
+		case *v0.SetOperation_Child_UsersetRewrite:
+			rewrite, err := rc.convertRewrite(typed.UsersetRewrite)
+			if err != nil {
+				return nil, err
+			}
+			converted = append(converted, nspkg.Rewrite(rewrite))
+
+		default:
+			return nil, rc.errorf("has an unknown set operation child")
+		}
+	}
+	return converted, nil
+}
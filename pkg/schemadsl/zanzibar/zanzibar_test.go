@@ -0,0 +1,152 @@
+package zanzibar
+
+import (
+	"testing"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/stretchr/testify/require"
+)
+
+const folderConfig = `name: "folder"
+
+relation { name: "parent" }
+
+relation {
+  name: "viewer"
+  userset_rewrite {
+    union {
+      child { _this {} }
+      child {
+        tuple_to_userset {
+          tupleset { relation: "parent" }
+          computed_userset {
+            object: TUPLE_USERSET_OBJECT
+            relation: "viewer"
+          }
+        }
+      }
+    }
+  }
+}`
+
+const docConfig = `name: "doc"
+
+relation { name: "owner" }
+
+relation {
+  name: "editor"
+  userset_rewrite {
+    union {
+      child { _this {} }
+      child { computed_userset { relation: "owner" } }
+    }
+  }
+}
+
+relation { name: "parent" }
+
+relation {
+  name: "viewer"
+  userset_rewrite {
+    union {
+      child { _this {} }
+      child { computed_userset { relation: "editor" } }
+      child {
+        tuple_to_userset {
+          tupleset { relation: "parent" }
+          computed_userset {
+            object: TUPLE_USERSET_OBJECT
+            relation: "viewer"
+          }
+        }
+      }
+    }
+  }
+}`
+
+func parseConfigs(t *testing.T, contents ...string) []*v0.NamespaceDefinition {
+	configs := make([]*v0.NamespaceDefinition, 0, len(contents))
+	for _, content := range contents {
+		config, err := ParseConfig([]byte(content), FormatTextProto)
+		require.NoError(t, err)
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+func TestConvert(t *testing.T) {
+	require := require.New(t)
+
+	result, err := Convert(
+		parseConfigs(t, `name: "user"`, folderConfig, docConfig),
+		WithDefaultSubjectTypes("user"),
+		WithSubjectTypes("folder#parent", "folder"),
+		WithSubjectTypes("doc#parent", "folder"),
+		WithSubjectTypes("doc#viewer", "user", "user:*"),
+	)
+	require.NoError(err)
+	require.Equal(`definition user {}
+
+definition folder {
+	relation parent: folder
+	relation viewer_direct: user
+	permission viewer = viewer_direct + parent->viewer
+}
+
+definition doc {
+	relation owner: user
+	relation editor_direct: user
+	permission editor = editor_direct + owner
+	relation parent: folder
+	relation viewer_direct: user | user:*
+	permission viewer = viewer_direct + editor + parent->viewer
+}`, result.Schema)
+	require.Equal(map[string]string{
+		"folder#viewer": "viewer_direct",
+		"doc#editor":    "editor_direct",
+		"doc#viewer":    "viewer_direct",
+	}, result.MovedRelations)
+	require.Len(result.Definitions, 3)
+}
+
+func TestConvertErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		configs       []string
+		options       []Option
+		expectedError string
+	}{
+		{
+			"missing subject types",
+			[]string{`name: "user"`, `name: "doc" relation { name: "owner" }`},
+			nil,
+			"no subject types are known for relation `doc#owner`; they must be supplied",
+		},
+		{
+			"userset of the subjects",
+			[]string{`name: "user"`, `name: "doc"
+			relation { name: "owner" }
+			relation {
+			  name: "editor"
+			  userset_rewrite { union { child { computed_userset { object: TUPLE_USERSET_OBJECT relation: "owner" } } } }
+			}`},
+			[]Option{WithDefaultSubjectTypes("user")},
+			"relation `doc#editor` computes a userset of the subjects of relationships, which is not supported",
+		},
+		{
+			"unknown subject type",
+			[]string{`name: "doc" relation { name: "owner" }`},
+			[]Option{WithDefaultSubjectTypes("user")},
+			"could not lookup definition `user` for relation `owner`: unknown definition user",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Convert(parseConfigs(t, tc.configs...), tc.options...)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// Package testserver runs a fully wired SpiceDB, backed by an in-memory datastore and
+// served over an in-memory listener, inside Go tests of the clients of its API.
+package testserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/middleware/servicespecific"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/services"
+	v1svc "github.com/authzed/spicedb/internal/services/v1"
+	v1alpha1svc "github.com/authzed/spicedb/internal/services/v1alpha1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	defaultMaxDepth = 50
+	gcWindow        = 1 * time.Hour
+	bufferSize      = 1024 * 1024
+)
+
+type config struct {
+	maxDepth      uint32
+	schema        string
+	relationships []string
+}
+
+// Option configures the server created by New.
+type Option func(*config)
+
+// WithMaxDepth sets the maximum depth of the dispatches of the server, which defaults
+// to 50.
+func WithMaxDepth(maxDepth uint32) Option {
+	return func(c *config) {
+		c.maxDepth = maxDepth
+	}
+}
+
+// WithSchema sets the schema written to the server when it is created.
+func WithSchema(schema string) Option {
+	return func(c *config) {
+		c.schema = schema
+	}
+}
+
+// WithRelationships sets the relationships, in their string form such as
+// "document:readme#viewer@user:tom", written to the server when it is created.
+func WithRelationships(relationships ...string) Option {
+	return func(c *config) {
+		c.relationships = append(c.relationships, relationships...)
+	}
+}
+
+// Server is a SpiceDB running in-process, with clients of its v1 API.
+type Server struct {
+	Conn        *grpc.ClientConn
+	Permissions v1.PermissionsServiceClient
+	Schema      v1.SchemaServiceClient
+	Watch       v1.WatchServiceClient
+}
+
+// New starts a server with an empty datastore, which is stopped when the test completes.
+func New(t *testing.T, options ...Option) *Server {
+	c := &config{maxDepth: defaultMaxDepth}
+	for _, option := range options {
+		option(c)
+	}
+
+	require := require.New(t)
+
+	ds, err := memdb.NewMemdbDatastore(0, 0, gcWindow, 0)
+	require.NoError(err)
+
+	nsm, err := namespace.NewCachingNamespaceManager(ds, 0, nil)
+	require.NoError(err)
+
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(servicespecific.UnaryServerInterceptor),
+		grpc.StreamInterceptor(servicespecific.StreamServerInterceptor),
+	)
+	services.RegisterGrpcServices(
+		grpcServer,
+		ds,
+		nsm,
+		graph.NewLocalOnlyDispatcher(nsm, ds),
+		c.maxDepth,
+		v1svc.DefaultWatchHeartbeatInterval,
+		v1alpha1svc.PrefixNotRequired,
+		services.V1SchemaServiceEnabled,
+		services.ReflectionDisabled,
+	)
+
+	lis := bufconn.Listen(bufferSize)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			panic("failed to shutdown cleanly: " + err.Error())
+		}
+	}()
+
+	conn, err := grpc.Dial("", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		grpcServer.Stop()
+		lis.Close()
+		nsm.Close()
+		ds.Close()
+	})
+
+	server := &Server{
+		Conn:        conn,
+		Permissions: v1.NewPermissionsServiceClient(conn),
+		Schema:      v1.NewSchemaServiceClient(conn),
+		Watch:       v1.NewWatchServiceClient(conn),
+	}
+
+	ctx := context.Background()
+	if c.schema != "" {
+		require.NoError(server.WriteSchema(ctx, c.schema))
+	}
+	if len(c.relationships) > 0 {
+		_, err := server.WriteRelationships(ctx, c.relationships...)
+		require.NoError(err)
+	}
+
+	return server
+}
+
+// WriteSchema replaces the schema of the server.
+func (s *Server) WriteSchema(ctx context.Context, schema string) error {
+	_, err := s.Schema.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: schema})
+	return err
+}
+
+// WriteRelationships touches the relationships, given in their string form such as
+// "document:readme#viewer@user:tom", and returns the token of the write.
+func (s *Server) WriteRelationships(ctx context.Context, relationships ...string) (*v1.ZedToken, error) {
+	updates := make([]*v1.RelationshipUpdate, 0, len(relationships))
+	for _, relationship := range relationships {
+		parsed := tuple.ParseRel(relationship)
+		if parsed == nil {
+			return nil, fmt.Errorf("invalid relationship: %s", relationship)
+		}
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: parsed,
+		})
+	}
+
+	resp, err := s.Permissions.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+	if err != nil {
+		return nil, err
+	}
+	return resp.WrittenAt, nil
+}
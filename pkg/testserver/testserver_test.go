@@ -0,0 +1,47 @@
+package testserver
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `definition user {}
+
+definition document {
+	relation viewer: user
+	permission view = viewer
+}`
+
+func TestServer(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	server := New(t, WithSchema(testSchema), WithRelationships("document:readme#viewer@user:tom"))
+
+	token, err := server.WriteRelationships(ctx, "document:readme#viewer@user:sarah")
+	require.NoError(err)
+
+	for _, tc := range []struct {
+		user     string
+		expected v1.CheckPermissionResponse_Permissionship
+	}{
+		{"tom", v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION},
+		{"sarah", v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION},
+		{"fred", v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION},
+	} {
+		resp, err := server.Permissions.CheckPermission(ctx, &v1.CheckPermissionRequest{
+			Consistency: &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: token}},
+			Resource:    &v1.ObjectReference{ObjectType: "document", ObjectId: "readme"},
+			Permission:  "view",
+			Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: tc.user}},
+		})
+		require.NoError(err)
+		require.Equal(tc.expected, resp.Permissionship, tc.user)
+	}
+
+	_, err = server.WriteRelationships(ctx, "not a relationship")
+	require.Error(err)
+}
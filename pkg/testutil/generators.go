@@ -0,0 +1,198 @@
+package testutil
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+
+	ns "github.com/authzed/spicedb/pkg/namespace"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// SubjectDefinitionName is the name of the definition without relations which generated
+// schemas always contain, used as the type of the terminal subjects of relationships.
+const SubjectDefinitionName = "user"
+
+// Generator generates random valid schemas and relationships for property-based tests.
+// Generators created with the same seed generate the same schemas and relationships.
+type Generator struct {
+	seed int64
+	rand *rand.Rand
+}
+
+// NewGenerator creates a Generator with the given seed.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{seed: seed, rand: rand.New(rand.NewSource(seed))}
+}
+
+// NewRandomGenerator creates a Generator seeded with the current time. Tests should log
+// the Seed of the generator, so that failures can be reproduced with NewGenerator.
+func NewRandomGenerator() *Generator {
+	return NewGenerator(time.Now().UnixNano())
+}
+
+// Seed returns the seed of the generator.
+func (g *Generator) Seed() int64 {
+	return g.seed
+}
+
+// Schema generates a schema of the subject definition and definitionCount definitions,
+// each with relationCount relations and permissionCount permissions.
+//
+// Relations allow the subject definition and the definitions generated before their own,
+// either directly or through one of their relations, so that the schema has no cycles.
+// Permissions are unions, intersections or exclusions of the relations and the previous
+// permissions of their definition, and of arrows through their relations.
+func (g *Generator) Schema(definitionCount, relationCount, permissionCount int) []*v0.NamespaceDefinition {
+	if relationCount < 1 {
+		relationCount = 1
+	}
+
+	schema := []*v0.NamespaceDefinition{ns.Namespace(SubjectDefinitionName)}
+	for i := 0; i < definitionCount; i++ {
+		previous := schema
+		relations := make([]*v0.Relation, 0, relationCount+permissionCount)
+
+		for j := 0; j < relationCount; j++ {
+			relations = append(relations, ns.Relation(fmt.Sprintf("rel%d", j), nil, g.allowedRelations(previous)...))
+		}
+
+		for j := 0; j < permissionCount; j++ {
+			relations = append(relations, ns.Relation(fmt.Sprintf("perm%d", j), g.rewrite(relations, previous)))
+		}
+
+		schema = append(schema, ns.Namespace(fmt.Sprintf("resource%d", i), relations...))
+	}
+	return schema
+}
+
+// allowedRelations picks between one and three distinct subject types among the given
+// definitions.
+func (g *Generator) allowedRelations(definitions []*v0.NamespaceDefinition) []*v0.AllowedRelation {
+	var candidates []*v0.AllowedRelation
+	for _, def := range definitions {
+		candidates = append(candidates, ns.AllowedRelation(def.Name, tuple.Ellipsis))
+		for _, relation := range def.Relation {
+			candidates = append(candidates, ns.AllowedRelation(def.Name, relation.Name))
+		}
+	}
+
+	count := 1 + g.rand.Intn(3)
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+
+	allowed := make([]*v0.AllowedRelation, 0, count)
+	for _, index := range g.rand.Perm(len(candidates))[:count] {
+		allowed = append(allowed, candidates[index])
+	}
+	return allowed
+}
+
+// rewrite generates the rewrite of a permission over the relations and permissions
+// defined before it.
+func (g *Generator) rewrite(relations []*v0.Relation, definitions []*v0.NamespaceDefinition) *v0.UsersetRewrite {
+	first := g.child(relations, definitions)
+	rest := make([]*v0.SetOperation_Child, g.rand.Intn(3))
+	for i := range rest {
+		rest[i] = g.child(relations, definitions)
+	}
+
+	switch g.rand.Intn(3) {
+	case 0:
+		return ns.Union(first, rest...)
+	case 1:
+		return ns.Intersection(first, rest...)
+	default:
+		return ns.Exclusion(first, rest...)
+	}
+}
+
+// child generates either a computed userset of one of the relations, or an arrow from one
+// of the relations (which are never permissions) to a relation or permission of one of
+// the definitions it allows.
+func (g *Generator) child(relations []*v0.Relation, definitions []*v0.NamespaceDefinition) *v0.SetOperation_Child {
+	if g.rand.Intn(3) == 0 {
+		var tuplesets []*v0.Relation
+		for _, relation := range relations {
+			if relation.UsersetRewrite == nil {
+				tuplesets = append(tuplesets, relation)
+			}
+		}
+
+		tupleset := tuplesets[g.rand.Intn(len(tuplesets))]
+		allowed := tupleset.TypeInformation.AllowedDirectRelations
+		target := findDefinition(definitions, allowed[g.rand.Intn(len(allowed))].Namespace)
+		if target != nil && len(target.Relation) > 0 {
+			return ns.TupleToUserset(tupleset.Name, target.Relation[g.rand.Intn(len(target.Relation))].Name)
+		}
+	}
+
+	return ns.ComputedUserset(relations[g.rand.Intn(len(relations))].Name)
+}
+
+// Relationships generates up to count distinct relationships of the relations of the
+// schema, between objects whose IDs are drawn from objectCount IDs per definition. Fewer
+// relationships are generated if the schema and objects do not allow count of them.
+func (g *Generator) Relationships(schema []*v0.NamespaceDefinition, count, objectCount int) []*v0.RelationTuple {
+	type directRelation struct {
+		namespace string
+		relation  *v0.Relation
+	}
+
+	var directRelations []directRelation
+	for _, def := range schema {
+		for _, relation := range def.Relation {
+			if len(relation.GetTypeInformation().GetAllowedDirectRelations()) > 0 {
+				directRelations = append(directRelations, directRelation{def.Name, relation})
+			}
+		}
+	}
+	if len(directRelations) == 0 || objectCount < 1 {
+		return nil
+	}
+
+	objectID := func() string {
+		return fmt.Sprintf("obj%d", g.rand.Intn(objectCount))
+	}
+
+	generated := make(map[string]struct{}, count)
+	tuples := make([]*v0.RelationTuple, 0, count)
+	for attempt := 0; attempt < count*10 && len(tuples) < count; attempt++ {
+		resource := directRelations[g.rand.Intn(len(directRelations))]
+		allowed := resource.relation.TypeInformation.AllowedDirectRelations
+		subject := allowed[g.rand.Intn(len(allowed))]
+
+		tpl := &v0.RelationTuple{
+			ObjectAndRelation: &v0.ObjectAndRelation{
+				Namespace: resource.namespace,
+				ObjectId:  objectID(),
+				Relation:  resource.relation.Name,
+			},
+			User: &v0.User{UserOneof: &v0.User_Userset{Userset: &v0.ObjectAndRelation{
+				Namespace: subject.Namespace,
+				ObjectId:  objectID(),
+				Relation:  subject.GetRelation(),
+			}}},
+		}
+
+		key := tuple.String(tpl)
+		if _, ok := generated[key]; ok {
+			continue
+		}
+		generated[key] = struct{}{}
+		tuples = append(tuples, tpl)
+	}
+	return tuples
+}
+
+func findDefinition(definitions []*v0.NamespaceDefinition, name string) *v0.NamespaceDefinition {
+	for _, def := range definitions {
+		if def.Name == name {
+			return def
+		}
+	}
+	return nil
+}
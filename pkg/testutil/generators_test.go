@@ -0,0 +1,77 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	first, second := NewGenerator(42), NewGenerator(42)
+
+	firstSchema, secondSchema := first.Schema(4, 3, 3), second.Schema(4, 3, 3)
+	require.Equal(t, firstSchema, secondSchema)
+	require.Equal(t, first.Relationships(firstSchema, 50, 5), second.Relationships(secondSchema, 50, 5))
+}
+
+func TestGeneratedSchemasAndRelationshipsAreValid(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		generator := NewRandomGenerator()
+		t.Logf("seed: %d", generator.Seed())
+
+		require := require.New(t)
+		ctx := context.Background()
+
+		schema := generator.Schema(5, 3, 3)
+		require.Len(schema, 6)
+		typeSystems := make(map[string]*namespace.NamespaceTypeSystem, len(schema))
+		for _, def := range schema {
+			ts, err := namespace.BuildNamespaceTypeSystemForDefs(def, schema)
+			require.NoError(err)
+			require.NoError(ts.Validate(ctx))
+			typeSystems[def.Name] = ts
+		}
+
+		rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+		require.NoError(err)
+		ds := testfixtures.NewValidatingDatastore(rawDS)
+
+		for _, def := range schema {
+			_, err := ds.WriteNamespace(ctx, def)
+			require.NoError(err)
+		}
+
+		tuples := generator.Relationships(schema, 100, 10)
+		require.NotEmpty(tuples)
+
+		seen := make(map[string]struct{}, len(tuples))
+		updates := make([]*v1.RelationshipUpdate, 0, len(tuples))
+		for _, tpl := range tuples {
+			allowed, err := typeSystems[tpl.ObjectAndRelation.Namespace].IsAllowedDirectRelation(
+				tpl.ObjectAndRelation.Relation,
+				tpl.User.GetUserset().Namespace,
+				tpl.User.GetUserset().Relation,
+			)
+			require.NoError(err)
+			require.Equal(namespace.DirectRelationValid, allowed, tuple.String(tpl))
+
+			seen[tuple.String(tpl)] = struct{}{}
+			updates = append(updates, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+				Relationship: tuple.MustToRelationship(tpl),
+			})
+		}
+		require.Len(seen, len(tuples))
+
+		_, err = ds.WriteTuples(ctx, nil, updates)
+		require.NoError(err)
+		require.NoError(ds.Close())
+	}
+}
@@ -0,0 +1,49 @@
+package testutil
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "regenerate the golden files of tests from their actual output")
+
+// Normalizer rewrites the nondeterministic parts of an output, such as revisions, so
+// that it can be compared with a golden file.
+type Normalizer func(string) string
+
+// ReplacePattern returns a Normalizer replacing the matches of the pattern.
+func ReplacePattern(pattern *regexp.Regexp, replacement string) Normalizer {
+	return func(s string) string {
+		return pattern.ReplaceAllString(s, replacement)
+	}
+}
+
+// RequireGoldenFile requires that the output is equal to the contents of the golden file
+// at the path, after both are normalized. When tests are run with the -update flag, the
+// golden file is instead written with the normalized output.
+func RequireGoldenFile(t *testing.T, path string, actual string, normalizers ...Normalizer) {
+	actual = normalize(actual, normalizers)
+
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(actual), 0o600))
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "missing golden file, run the test with -update to create it")
+	require.Equal(t, normalize(string(expected), normalizers), actual, "output differs from golden file %s", path)
+}
+
+func normalize(s string, normalizers []Normalizer) string {
+	for _, normalizer := range normalizers {
+		s = normalizer(s)
+	}
+	return s
+}
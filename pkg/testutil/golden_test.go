@@ -0,0 +1,19 @@
+package testutil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireGoldenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.golden")
+	require.NoError(t, ioutil.WriteFile(path, []byte("written at revision 1234.0000000000\n"), 0o600))
+
+	RequireGoldenFile(t, path, "written at revision 5678.0000000000\n",
+		ReplacePattern(regexp.MustCompile(`\d+\.\d+`), "<revision>"),
+	)
+}
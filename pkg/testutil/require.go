@@ -4,9 +4,15 @@ package testutil
 
 import (
 	"reflect"
+	"sort"
 	"testing"
 
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 // RequireEqualEmptyNil is a version of require.Equal, but considers nil
@@ -30,3 +36,71 @@ func hasLength(k reflect.Kind) bool {
 	}
 	return false
 }
+
+// RequireProtoEqual is a version of require.Equal for protocol buffer messages, which
+// compares them with proto.Equal and reports their differences in the text format.
+func RequireProtoEqual(t *testing.T, expected, actual proto.Message, msgAndArgs ...interface{}) {
+	if proto.Equal(expected, actual) {
+		return
+	}
+	require.Equal(t, prototext.Format(expected), prototext.Format(actual), msgAndArgs...)
+	require.Fail(t, "protos are not equal", msgAndArgs...)
+}
+
+// RequireTupleSetsEqual requires that the tuples are the same, in any order, and reports
+// their differences as the strings of the tuples.
+func RequireTupleSetsEqual(t *testing.T, expected, actual []*v0.RelationTuple, msgAndArgs ...interface{}) {
+	require.Equal(t, sortedTupleStrings(expected), sortedTupleStrings(actual), msgAndArgs...)
+}
+
+func sortedTupleStrings(tuples []*v0.RelationTuple) []string {
+	strs := make([]string, 0, len(tuples))
+	for _, tpl := range tuples {
+		strs = append(strs, tuple.String(tpl))
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+// RequireExpandTreesEquivalent requires that the expansion trees are equal, except for
+// the order of the children of unions and intersections, of the excluded children of
+// exclusions, and of the subjects of leaves.
+func RequireExpandTreesEquivalent(t *testing.T, expected, actual *v0.RelationTupleTreeNode, msgAndArgs ...interface{}) {
+	RequireProtoEqual(t, canonicalizeTree(expected), canonicalizeTree(actual), msgAndArgs...)
+}
+
+// canonicalizeTree returns a copy of the tree, with the children and subjects whose order
+// is not significant sorted.
+func canonicalizeTree(node *v0.RelationTupleTreeNode) *v0.RelationTupleTreeNode {
+	if node == nil {
+		return nil
+	}
+
+	canonical := proto.Clone(node).(*v0.RelationTupleTreeNode)
+	canonicalizeNode(canonical)
+	return canonical
+}
+
+func canonicalizeNode(node *v0.RelationTupleTreeNode) {
+	switch typed := node.NodeType.(type) {
+	case *v0.RelationTupleTreeNode_IntermediateNode:
+		children := typed.IntermediateNode.ChildNodes
+		for _, child := range children {
+			canonicalizeNode(child)
+		}
+
+		unordered := children
+		if typed.IntermediateNode.Operation == v0.SetOperationUserset_EXCLUSION && len(children) > 0 {
+			unordered = children[1:]
+		}
+		sort.SliceStable(unordered, func(i, j int) bool {
+			return prototext.Format(unordered[i]) < prototext.Format(unordered[j])
+		})
+
+	case *v0.RelationTupleTreeNode_LeafNode:
+		users := typed.LeafNode.Users
+		sort.SliceStable(users, func(i, j int) bool {
+			return tuple.StringONR(users[i].GetUserset()) < tuple.StringONR(users[j].GetUserset())
+		})
+	}
+}
@@ -1,6 +1,15 @@
 package testutil
 
-import "testing"
+import (
+	"testing"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/pkg/graph"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
 
 func TestRequireEqualEmptyNil(t *testing.T) {
 	RequireEqualEmptyNil(t, []int(nil), []int(nil))
@@ -8,3 +17,39 @@ func TestRequireEqualEmptyNil(t *testing.T) {
 	RequireEqualEmptyNil(t, []int{}, []int(nil))
 	RequireEqualEmptyNil(t, []int{}, []int{})
 }
+
+func TestRequireProtoEqual(t *testing.T) {
+	onr := tuple.ParseONR("document:first#viewer")
+	RequireProtoEqual(t, onr, proto.Clone(onr))
+}
+
+func TestRequireTupleSetsEqual(t *testing.T) {
+	first := tuple.Parse("document:first#viewer@user:tom#...")
+	second := tuple.Parse("document:second#viewer@user:fred#...")
+
+	RequireTupleSetsEqual(t, nil, []*v0.RelationTuple{})
+	RequireTupleSetsEqual(t, []*v0.RelationTuple{first, second}, []*v0.RelationTuple{second, first})
+}
+
+func TestRequireExpandTreesEquivalent(t *testing.T) {
+	start := tuple.ParseONR("document:first#view")
+	tom := tuple.User(tuple.ParseSubjectONR("user:tom"))
+	fred := tuple.User(tuple.ParseSubjectONR("user:fred"))
+	sarah := tuple.User(tuple.ParseSubjectONR("user:sarah"))
+
+	RequireExpandTreesEquivalent(t,
+		graph.Union(start, graph.Leaf(start, tom, fred), graph.Leaf(nil, sarah)),
+		graph.Union(start, graph.Leaf(nil, sarah), graph.Leaf(start, fred, tom)),
+	)
+
+	RequireExpandTreesEquivalent(t,
+		graph.Exclusion(start, graph.Leaf(start, tom), graph.Leaf(nil, fred), graph.Leaf(nil, sarah)),
+		graph.Exclusion(start, graph.Leaf(start, tom), graph.Leaf(nil, sarah), graph.Leaf(nil, fred)),
+	)
+
+	// The base of an exclusion is not interchangeable with the excluded children.
+	require.False(t, proto.Equal(
+		canonicalizeTree(graph.Exclusion(start, graph.Leaf(nil, tom), graph.Leaf(nil, fred))),
+		canonicalizeTree(graph.Exclusion(start, graph.Leaf(nil, fred), graph.Leaf(nil, tom))),
+	))
+}
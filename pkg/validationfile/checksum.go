@@ -0,0 +1,95 @@
+package validationfile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// Checksum returns a checksum of the namespace definitions and relation tuples, which does
+// not depend on the order in which they are given.
+func Checksum(nsDefs []*v0.NamespaceDefinition, tuples []*v0.RelationTuple) (string, error) {
+	sortedDefs := make([]*v0.NamespaceDefinition, len(nsDefs))
+	copy(sortedDefs, nsDefs)
+	sort.Slice(sortedDefs, func(i, j int) bool {
+		return sortedDefs[i].Name < sortedDefs[j].Name
+	})
+
+	tupleStrings := make([]string, 0, len(tuples))
+	for _, tpl := range tuples {
+		tupleStrings = append(tupleStrings, tuple.String(tpl))
+	}
+	sort.Strings(tupleStrings)
+
+	hasher := sha256.New()
+	for _, nsDef := range sortedDefs {
+		serialized, err := proto.MarshalOptions{Deterministic: true}.Marshal(nsDef)
+		if err != nil {
+			return "", err
+		}
+		hasher.Write(serialized)
+	}
+	for _, tplString := range tupleStrings {
+		hasher.Write([]byte(tplString))
+		hasher.Write([]byte{'\n'})
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// FilesChecksum returns the Checksum of the namespace definitions and relation tuples which
+// PopulateFromFiles would load from the validation file(s) specified.
+func FilesChecksum(filePaths []string) (string, error) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	if err != nil {
+		return "", err
+	}
+	defer ds.Close()
+
+	if _, _, err := PopulateFromFiles(ds, filePaths); err != nil {
+		return "", err
+	}
+
+	revision, err := ds.HeadRevision(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return DatastoreChecksum(context.Background(), ds, revision)
+}
+
+// DatastoreChecksum returns the Checksum of the namespace definitions and relation tuples in
+// the datastore at the revision.
+func DatastoreChecksum(ctx context.Context, ds datastore.Datastore, revision decimal.Decimal) (string, error) {
+	nsDefs, err := ds.ListNamespaces(ctx, revision)
+	if err != nil {
+		return "", err
+	}
+
+	var tuples []*v0.RelationTuple
+	for _, nsDef := range nsDefs {
+		it, err := ds.QueryTuples(ctx, &v1.RelationshipFilter{ResourceType: nsDef.Name}, revision)
+		if err != nil {
+			return "", err
+		}
+
+		for tpl := it.Next(); tpl != nil; tpl = it.Next() {
+			tuples = append(tuples, tpl)
+		}
+		it.Close()
+		if it.Err() != nil {
+			return "", it.Err()
+		}
+	}
+
+	return Checksum(nsDefs, tuples)
+}
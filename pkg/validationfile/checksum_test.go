@@ -0,0 +1,49 @@
+package validationfile
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestChecksum(t *testing.T) {
+	require := require.New(t)
+
+	expected, err := FilesChecksum([]string{"testdata/loader_no_comment.yaml"})
+	require.NoError(err)
+
+	// Comments do not change the data which is loaded.
+	withComments, err := FilesChecksum([]string{"testdata/loader_with_comment.yaml"})
+	require.NoError(err)
+	require.Equal(expected, withComments)
+
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC, 0)
+	require.NoError(err)
+	defer ds.Close()
+
+	revision, err := ds.HeadRevision(context.Background())
+	require.NoError(err)
+	empty, err := DatastoreChecksum(context.Background(), ds, revision)
+	require.NoError(err)
+	require.NotEqual(expected, empty)
+
+	_, revision, err = PopulateFromFiles(ds, []string{"testdata/loader_no_comment.yaml"})
+	require.NoError(err)
+	populated, err := DatastoreChecksum(context.Background(), ds, revision)
+	require.NoError(err)
+	require.Equal(expected, populated)
+
+	revision, err = ds.WriteTuples(context.Background(), nil, []*v1.RelationshipUpdate{{
+		Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+		Relationship: tuple.ParseRel("example/project:pied_piper#reader@example/user:someoneelse"),
+	}})
+	require.NoError(err)
+	modified, err := DatastoreChecksum(context.Background(), ds, revision)
+	require.NoError(err)
+	require.NotEqual(expected, modified)
+}
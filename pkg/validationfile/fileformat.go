@@ -91,7 +91,10 @@ func ParseAssertionsBlock(contents []byte) (Assertions, error) {
 		return Assertions{}, fmt.Errorf("expected object at top level")
 	}
 
-	mapping := node.Content[0]
+	return parseAssertions(node.Content[0]), nil
+}
+
+func parseAssertions(mapping *yamlv3.Node) Assertions {
 	key := ""
 	var parsed Assertions
 	for _, child := range mapping.Content {
@@ -121,7 +124,38 @@ func ParseAssertionsBlock(contents []byte) (Assertions, error) {
 		}
 	}
 
-	return parsed, nil
+	return parsed
+}
+
+// Expectations are the expected relations and assertions of a validation file, which are
+// checked against the data of a datastore.
+type Expectations struct {
+	// ExpectedRelations is the `validation` block of the file.
+	ExpectedRelations ValidationMap
+
+	// Assertions is the `assertions` block of the file, with line numbers relative to the
+	// file.
+	Assertions Assertions
+}
+
+// ParseExpectations parses the expected relations and assertions of a YAML validation
+// file.
+func ParseExpectations(contents []byte) (Expectations, error) {
+	var file struct {
+		Validation ValidationMap `yaml:"validation"`
+		Assertions yamlv3.Node   `yaml:"assertions"`
+	}
+	if err := yamlv3.Unmarshal(contents, &file); err != nil {
+		return Expectations{}, err
+	}
+
+	expectations := Expectations{ExpectedRelations: file.Validation}
+	if file.Assertions.Kind == yamlv3.MappingNode {
+		expectations.Assertions = parseAssertions(&file.Assertions)
+	} else if file.Assertions.Kind != 0 && file.Assertions.Tag != "!!null" {
+		return Expectations{}, fmt.Errorf("expected object for assertions")
+	}
+	return expectations, nil
 }
 
 // ValidationMap is a map from an Object Relation (as a Relationship) to the
@@ -153,3 +153,36 @@ func TestAssertions(t *testing.T) {
 		})
 	}
 }
+
+func TestParseExpectations(t *testing.T) {
+	require := require.New(t)
+
+	expectations, err := ParseExpectations([]byte(`schema: >-
+  definition user {}
+relationships: >-
+  document:readme#viewer@user:alice
+assertions:
+  assertTrue:
+    - document:readme#viewer@user:alice
+  assertFalse:
+    - document:readme#viewer@user:bob
+validation:
+  document:readme#viewer:
+    - "[user:alice] is <document:readme#viewer>"
+`))
+	require.NoError(err)
+	require.Equal(ValidationMap{
+		"document:readme#viewer": {"[user:alice] is <document:readme#viewer>"},
+	}, expectations.ExpectedRelations)
+	require.Equal(Assertions{
+		AssertTrue:  []Assertion{{"document:readme#viewer@user:alice", 7, 7}},
+		AssertFalse: []Assertion{{"document:readme#viewer@user:bob", 9, 7}},
+	}, expectations.Assertions)
+
+	expectations, err = ParseExpectations([]byte("schema: >-\n  definition user {}\n"))
+	require.NoError(err)
+	require.Equal(Expectations{}, expectations)
+
+	_, err = ParseExpectations([]byte("assertions: [document:readme#viewer@user:alice]\n"))
+	require.Error(err)
+}
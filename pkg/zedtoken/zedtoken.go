@@ -23,12 +23,37 @@ const (
 // zedtoken argument to Decode
 var ErrNilZedToken = errors.New("zedtoken pointer was nil")
 
+// ErrForeignDatastore is returned when a zedtoken minted against one datastore is decoded
+// for another, whose revisions are unrelated.
+type ErrForeignDatastore struct {
+	error
+	tokenDatastoreID string
+	datastoreID      string
+}
+
+// TokenDatastoreID is the ID of the datastore against which the zedtoken was minted.
+func (err ErrForeignDatastore) TokenDatastoreID() string {
+	return err.tokenDatastoreID
+}
+
+// DatastoreID is the ID of the datastore for which the zedtoken was decoded.
+func (err ErrForeignDatastore) DatastoreID() string {
+	return err.datastoreID
+}
+
 // NewFromRevision generates an encoded zedtoken from an integral revision.
 func NewFromRevision(revision decimal.Decimal) *v1.ZedToken {
+	return NewFromRevisionForDatastore(revision, "")
+}
+
+// NewFromRevisionForDatastore generates an encoded zedtoken from an integral revision of the
+// datastore with the ID. Tokens for an empty ID can be decoded for any datastore.
+func NewFromRevisionForDatastore(revision decimal.Decimal, datastoreID string) *v1.ZedToken {
 	toEncode := &zedtoken.DecodedZedToken{
 		VersionOneof: &zedtoken.DecodedZedToken_V1{
 			V1: &zedtoken.DecodedZedToken_V1ZedToken{
-				Revision: revision.String(),
+				Revision:    revision.String(),
+				DatastoreId: datastoreID,
 			},
 		},
 	}
@@ -70,6 +95,14 @@ func Decode(encoded *v1.ZedToken) (*zedtoken.DecodedZedToken, error) {
 
 // DecodeRevision converts and extracts the revision from a zedtoken or legacy zookie.
 func DecodeRevision(encoded *v1.ZedToken) (decimal.Decimal, error) {
+	return DecodeRevisionForDatastore(encoded, "")
+}
+
+// DecodeRevisionForDatastore converts and extracts the revision from a zedtoken or legacy
+// zookie, which is expected to have been minted against the datastore with the ID. Tokens
+// minted against another datastore are rejected with ErrForeignDatastore, unless either ID
+// is empty.
+func DecodeRevisionForDatastore(encoded *v1.ZedToken, datastoreID string) (decimal.Decimal, error) {
 	decoded, err := Decode(encoded)
 	if err != nil {
 		return decimal.Zero, err
@@ -79,6 +112,15 @@ func DecodeRevision(encoded *v1.ZedToken) (decimal.Decimal, error) {
 	case *zedtoken.DecodedZedToken_DeprecatedV1Zookie:
 		return decimal.NewFromInt(int64(ver.DeprecatedV1Zookie.Revision)), nil
 	case *zedtoken.DecodedZedToken_V1:
+		tokenDatastoreID := ver.V1.DatastoreId
+		if tokenDatastoreID != "" && datastoreID != "" && tokenDatastoreID != datastoreID {
+			return decimal.Zero, ErrForeignDatastore{
+				error:            fmt.Errorf("zedtoken was minted against datastore %s, not this datastore %s", tokenDatastoreID, datastoreID),
+				tokenDatastoreID: tokenDatastoreID,
+				datastoreID:      datastoreID,
+			}
+		}
+
 		parsed, err := decimal.NewFromString(ver.V1.Revision)
 		if err != nil {
 			return decimal.Zero, fmt.Errorf(errDecodeError, err)
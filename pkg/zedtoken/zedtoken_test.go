@@ -148,3 +148,44 @@ func TestDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestDatastoreBinding(t *testing.T) {
+	testCases := []struct {
+		name             string
+		tokenDatastoreID string
+		datastoreID      string
+		expectForeign    bool
+	}{
+		{"same datastore", "abc", "abc", false},
+		{"other datastore", "abc", "def", true},
+		{"unbound token", "", "abc", false},
+		{"unidentified datastore", "abc", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			rev := decimal.NewFromInt(42)
+			decoded, err := DecodeRevisionForDatastore(NewFromRevisionForDatastore(rev, tc.tokenDatastoreID), tc.datastoreID)
+			if tc.expectForeign {
+				var foreignErr ErrForeignDatastore
+				require.ErrorAs(err, &foreignErr)
+				require.Equal(tc.tokenDatastoreID, foreignErr.TokenDatastoreID())
+				require.Equal(tc.datastoreID, foreignErr.DatastoreID())
+				return
+			}
+
+			require.NoError(err)
+			require.True(rev.Equal(decoded))
+		})
+	}
+
+	// Tokens are bound to a datastore by a field which older decoders ignore.
+	decoded, err := DecodeRevision(&v1.ZedToken{Token: "GggKATESA2FiYw=="})
+	require.NoError(t, err)
+	require.True(t, decimal.NewFromInt(1).Equal(decoded))
+
+	_, err = DecodeRevisionForDatastore(&v1.ZedToken{Token: "GggKATESA2FiYw=="}, "def")
+	require.ErrorAs(t, err, &ErrForeignDatastore{})
+}